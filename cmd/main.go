@@ -2,12 +2,18 @@ package main
 
 import (
 	"fmt"
-	"log"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"mms-backend/config"
 	"mms-backend/controllers"
+	"mms-backend/jobs"
+	"mms-backend/metrics"
 	"mms-backend/middleware"
+	"mms-backend/migrations"
 	"mms-backend/models"
 	"mms-backend/repositories"
 	"mms-backend/routes"
@@ -16,33 +22,66 @@ import (
 	"mms-backend/websocket"
 )
 
+// @title MMS Backend API
+// @version 1.0
+// @description Messaging backend: authentication, direct and group messages, WebSocket events.
+// @host localhost:8080
+// @BasePath /api
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description Type "Bearer" followed by a space and the JWT token.
 func main() {
 	// Load configuration
 	cfg, err := config.LoadConfig()
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+		fatal("Failed to load configuration", err)
 	}
 
-	log.Println("Configuration loaded successfully")
+	// JSON logs in production, text elsewhere
+	utils.InitLogger(cfg.Server.Environment)
+
+	// Catch missing or placeholder secrets before anything uses them
+	if err := config.Validate(cfg); err != nil {
+		fatal("Refusing to start", err)
+	}
+	utils.Logger.Info("Configuration loaded", "environment", cfg.Server.Environment)
+
+	// Register Prometheus metrics served at /metrics
+	metrics.Init()
 
 	// Initialize database
 	db, err := config.InitDatabase(&cfg.Database)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		fatal("Failed to connect to database", err)
 	}
 
-	log.Println("Database connected successfully")
+	utils.Logger.Info("Database connected")
 
 	// Run migrations
 	if err := runMigrations(db); err != nil {
-		log.Fatalf("Failed to run migrations: %v", err)
+		fatal("Failed to run migrations", err)
+	}
+	if err := migrations.Run(db); err != nil {
+		fatal("Failed to run SQL migrations", err)
 	}
 
-	log.Println("Database migrations completed")
+	utils.Logger.Info("Database migrations completed")
 
 	// Load translations
 	i18n := utils.GetI18n()
-	log.Printf("Loaded translations for languages: %v", i18n.SupportedLanguages())
+	utils.Logger.Info("Loaded translations", "languages", i18n.SupportedLanguages())
+	for _, key := range i18n.Validate() {
+		utils.Logger.Warn("Translation key missing from some languages", "key", key)
+	}
+
+	if cfg.I18n.Watch {
+		if err := i18n.WatchTranslations(nil); err != nil {
+			utils.Logger.Warn("Failed to watch translations", "error", err)
+		} else {
+			utils.Logger.Info("Watching locales/ for translation changes")
+		}
+	}
 
 	// Initialize repositories
 	userRepo := repositories.NewUserRepository(db)
@@ -50,47 +89,168 @@ func main() {
 	groupRepo := repositories.NewGroupRepository(db)
 	groupMessageRepo := repositories.NewGroupMessageRepository(db)
 	notificationRepo := repositories.NewNotificationRepository(db)
+	idempotencyRepo := repositories.NewIdempotencyRepository(db)
+	contentFilterRepo := repositories.NewContentFilterRepository(db)
+	passwordResetRepo := repositories.NewPasswordResetRepository(db)
+
+	// Creators from before the owner role existed become owners of their groups
+	if promoted, err := groupRepo.PromoteCreatorsToOwner(); err != nil {
+		fatal("Failed to migrate group owners", err)
+	} else if promoted > 0 {
+		utils.Logger.Info("Promoted group creators to owner", "count", promoted)
+	}
 
 	// Initialize WebSocket hub first (needed by services)
 	hub := websocket.NewHub()
+	// Presence events only reach users sharing a group or conversation
+	hub.SetPresenceLoader(services.NewPresenceService(groupRepo, messageRepo))
+	hub.SetBlockChecker(userRepo)
+	hub.SetMaxConnectionsPerUser(cfg.Server.WSMaxConnections)
+	// Persist online status and last_seen as users connect and disconnect
+	hub.OnClientConnect = func(userID uuid.UUID) {
+		if err := userRepo.UpdateOnlineStatus(userID, true); err != nil {
+			utils.Logger.Error("Failed to update online status", "user_id", userID, "error", err)
+		}
+	}
+	hub.OnClientDisconnect = func(userID uuid.UUID) {
+		if err := userRepo.UpdateOnlineStatus(userID, false); err != nil {
+			utils.Logger.Error("Failed to update online status", "user_id", userID, "error", err)
+		}
+	}
 	go hub.Run()
-	wsHandler := websocket.NewHandler(hub)
+	wsHandler := websocket.NewHandler(hub, websocket.CompressionOptions{
+		Enabled: cfg.Server.WSCompressionEnabled,
+		Level:   cfg.Server.CompressionLevel,
+	})
 
 	// Initialize services
-	pushService := services.NewPushService(cfg)
-	authService := services.NewAuthService(userRepo)
-	messageService := services.NewMessageService(messageRepo, userRepo, notificationRepo, pushService, hub)
-	groupService := services.NewGroupService(groupRepo, groupMessageRepo, userRepo, notificationRepo, pushService)
+	pushService := services.NewPushService(cfg, userRepo, notificationRepo)
+	// Message sends queue their push notifications for background workers
+	pushQueue := jobs.NewPushQueue(jobs.PushQueueSize)
+	pushQueue.Start(cfg.Push.Workers, pushService)
+	pushService.SetQueue(pushQueue)
+	authService := services.NewAuthService(userRepo, passwordResetRepo, services.NewSMTPEmailService(cfg.SMTP), services.NewGoogleTokenVerifier(), cfg.Google.ClientID)
+	unreadService := services.NewUnreadService(messageRepo, groupMessageRepo, hub)
+	contentFilterService := services.NewContentFilterService(contentFilterRepo)
+	storage, err := services.NewStorageService(cfg.Storage)
+	if err != nil {
+		fatal("Failed to configure storage", err)
+	}
+	attachmentService := services.NewAttachmentService(storage, cfg.Storage.PublicURL)
+	var cache services.CacheService
+	if cfg.Redis.URL != "" {
+		if cache, err = services.NewRedisCache(cfg.Redis.URL); err != nil {
+			fatal("Failed to connect to Redis", err)
+		}
+	}
+	messageService := services.NewMessageService(messageRepo, userRepo, notificationRepo, pushService, unreadService, contentFilterService, attachmentService, cache, hub)
+	// Clients ack new_message events, which marks them delivered
+	hub.SetDeliveryAcknowledger(messageService)
+	groupService := services.NewGroupService(groupRepo, groupMessageRepo, userRepo, notificationRepo, pushService, unreadService, hub)
+	wsHandler.SetMissedMessagesLoader(services.NewMissedMessagesService(messageService, groupService))
+	adminService := services.NewAdminService(messageRepo, userRepo, hub)
+	notificationService := services.NewNotificationService(notificationRepo)
+	userService := services.NewUserService(userRepo, storage)
+
+	// Expire timed group and conversation mutes in the background
+	go groupService.RunMuteExpiry(time.Minute, nil)
+	go messageService.RunMuteExpiry(time.Minute, nil)
+
+	// Permanently remove accounts deleted more than 30 days ago
+	go userService.RunAccountPurge(time.Hour, nil)
+
+	// Send scheduled messages once they are due
+	go messageService.RunScheduledMessages(services.ScheduledMessagePollInterval, nil)
+
+	// Index messages stored before full-text search existed
+	go messageService.BackfillSearchIndex(500)
+	go groupService.BackfillSearchIndex(500)
+
+	// Purge expired idempotency keys in the background
+	go runIdempotencyKeyCleanup(idempotencyRepo, time.Hour)
+
+	// Purge expired password reset tokens in the background
+	go runPasswordResetTokenCleanup(passwordResetRepo, time.Hour)
 
 	// Initialize controllers
 	authController := controllers.NewAuthController(authService)
-	userController := controllers.NewUserController(userRepo)
-	messageController := controllers.NewMessageController(messageService)
-	groupController := controllers.NewGroupController(groupService)
+	userController := controllers.NewUserController(userService)
+	messageController := controllers.NewMessageController(messageService, attachmentService, idempotencyRepo)
+	groupController := controllers.NewGroupController(groupService, idempotencyRepo)
+	adminController := controllers.NewAdminController(adminService, contentFilterService)
+	notificationController := controllers.NewNotificationController(notificationService)
+	sqlDB, err := db.DB()
+	if err != nil {
+		fatal("Failed to get database handle", err)
+	}
+	healthController := controllers.NewHealthController(sqlDB)
+
+	// Keep a user's reads on the primary database briefly after they write
+	stickyTracker := middleware.NewStickyTracker(cfg.Database.Resolver.StickyWindow)
 
-	log.Println("WebSocket hub started")
+	utils.Logger.Info("WebSocket hub started")
 
 	// Set up Gin router
 	if cfg.Server.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	router := gin.Default()
+	// Structured access log in place of gin.Default's logger
+	router := gin.New()
+	router.Use(middleware.RequestLogger(), gin.Recovery())
 
 	// Apply middleware
-	router.Use(middleware.CORSMiddleware())
+	router.Use(middleware.CORSMiddleware(cfg.Server))
 
 	// Set up routes
-	routes.SetupRoutes(router, authController, userController, messageController, groupController, wsHandler)
+	routes.SetupRoutes(router, authController, userController, messageController, groupController, adminController, notificationController, healthController, wsHandler, stickyTracker, userRepo)
+	routes.SetupSwagger(router)
+
+	// Serve attachments stored on local disk
+	if local, ok := storage.(*services.LocalStorage); ok && strings.HasPrefix(cfg.Storage.PublicURL, "/") {
+		router.Static(cfg.Storage.PublicURL, local.Dir())
+	}
 
 	// Start server
 	port := cfg.Server.Port
-	log.Printf("Starting MMS Backend server on port %s", port)
-	log.Printf("Environment: %s", cfg.Server.Environment)
-	log.Printf("WebSocket endpoint: ws://localhost:%s/api/v1/ws", port)
+	utils.Logger.Info("Starting MMS Backend server",
+		"port", port,
+		"environment", cfg.Server.Environment,
+		"websocket_endpoint", "ws://localhost:"+port+"/api/v1/ws",
+	)
 
 	if err := router.Run(":" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+		fatal("Failed to start server", err)
+	}
+}
+
+// runIdempotencyKeyCleanup periodically deletes idempotency keys past their TTL
+func runIdempotencyKeyCleanup(repo *repositories.IdempotencyRepository, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		removed, err := repo.DeleteExpired()
+		if err != nil {
+			utils.Logger.Error("Failed to delete expired idempotency keys", "error", err)
+		} else if removed > 0 {
+			utils.Logger.Info("Deleted expired idempotency keys", "count", removed)
+		}
+	}
+}
+
+// runPasswordResetTokenCleanup periodically deletes password reset tokens past their TTL
+func runPasswordResetTokenCleanup(repo *repositories.PasswordResetRepository, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		removed, err := repo.DeleteExpired()
+		if err != nil {
+			utils.Logger.Error("Failed to delete expired password reset tokens", "error", err)
+		} else if removed > 0 {
+			utils.Logger.Info("Deleted expired password reset tokens", "count", removed)
+		}
 	}
 }
 
@@ -105,20 +265,19 @@ func runMigrations(db interface{}) error {
 		return fmt.Errorf("database does not support migrations")
 	}
 
-	log.Println("Running database migrations...")
+	utils.Logger.Info("Running database migrations")
 
 	// Auto-migrate all models
-	if err := migrator.AutoMigrate(
-		&models.User{},
-		&models.Message{},
-		&models.Group{},
-		&models.GroupMember{},
-		&models.GroupMessage{},
-		&models.Notification{},
-	); err != nil {
+	if err := migrator.AutoMigrate(models.All()...); err != nil {
 		return err
 	}
 
-	log.Println("Migrations completed successfully")
+	utils.Logger.Info("Migrations completed")
 	return nil
 }
+
+// fatal logs err and exits, like log.Fatalf
+func fatal(msg string, err error) {
+	utils.Logger.Error(msg, "error", err)
+	os.Exit(1)
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+
+	"mms-backend/config"
+	"mms-backend/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Re-encrypts every stored encrypted column from one ENCRYPTION_KEY to another.
+//
+//	go run ./cmd/migrate-encryption --old-key "$OLD_KEY" --new-key "$NEW_KEY"
+//
+// The servers keep running: they must already encrypt with the new key and still decrypt with
+// the old one (ENCRYPTION_KEY=new, ENCRYPTION_KEY_PREVIOUS=old), which the tool checks against
+// its own configuration, so nothing is written with the old key while it runs.
+//
+// Values that already decrypt with the new key are left alone, so an interrupted run can simply
+// be started again. Rows that fail are logged and skipped; the run carries on with the rest.
+func main() {
+	oldKey := flag.String("old-key", "", "encryption key the data is currently encrypted with")
+	newKey := flag.String("new-key", "", "encryption key to re-encrypt the data with")
+	batchSize := flag.Int("batch-size", 500, "rows re-encrypted per transaction")
+	flag.Parse()
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fatal("Failed to load configuration", err)
+	}
+	utils.InitLogger(cfg.Server.Environment)
+
+	switch {
+	case *oldKey == "" || *newKey == "":
+		fatal("Invalid flags", errors.New("--old-key and --new-key are required"))
+	case *oldKey == *newKey:
+		fatal("Invalid flags", errors.New("--old-key and --new-key must differ"))
+	case *batchSize <= 0:
+		fatal("Invalid flags", errors.New("--batch-size must be positive"))
+	case cfg.Security.EncryptionKey != *newKey || cfg.Security.PreviousEncryptionKey != *oldKey:
+		fatal("Servers must switch keys first", errors.New(
+			"set ENCRYPTION_KEY to the new key and ENCRYPTION_KEY_PREVIOUS to the old one, restart the servers and run this again"))
+	}
+
+	db, err := config.InitDatabase(&cfg.Database)
+	if err != nil {
+		fatal("Failed to connect to database", err)
+	}
+
+	m := &migrator{db: db, oldKey: *oldKey, newKey: *newKey, batchSize: *batchSize}
+	failed := 0
+	for _, target := range encryptedColumns {
+		stats, err := m.migrateTable(target)
+		if err != nil {
+			fatal("Failed to re-encrypt "+target.table, err)
+		}
+		utils.Logger.Info("Re-encrypted table", "table", target.table,
+			"rows", stats.rows, "updated", stats.updated, "already_migrated", stats.skipped, "failed", stats.failed)
+		failed += stats.failed
+	}
+
+	if failed > 0 {
+		utils.Logger.Warn("Some values could not be re-encrypted; see the errors above", "failed", failed)
+		os.Exit(1)
+	}
+	utils.Logger.Info("All encrypted data now uses the new key; unset ENCRYPTION_KEY_PREVIOUS and restart the servers")
+}
+
+// encryptedTable lists the encrypted columns of a table keyed by a UUID id column
+type encryptedTable struct {
+	table   string
+	columns []string
+}
+
+// encryptedColumns holds every column written with utils.Encrypt
+var encryptedColumns = []encryptedTable{
+	{"messages", []string{"content", "previous_content", "reply_preview"}},
+	{"group_messages", []string{"content", "previous_content"}},
+	{"conversation_drafts", []string{"content"}},
+	{"group_drafts", []string{"content"}},
+	{"scheduled_messages", []string{"content"}},
+	{"users", []string{"totp_secret"}},
+}
+
+// migrator re-encrypts tables batch by batch
+type migrator struct {
+	db        *gorm.DB
+	oldKey    string
+	newKey    string
+	batchSize int
+}
+
+// tableStats counts what happened to the rows of a table
+type tableStats struct {
+	rows    int
+	updated int
+	skipped int // Every value already used the new key
+	failed  int
+}
+
+// migrateTable walks a table in id order, re-encrypting each batch in its own transaction
+func (m *migrator) migrateTable(target encryptedTable) (tableStats, error) {
+	var stats tableStats
+	columns := append([]string{"id"}, target.columns...)
+
+	lastID := uuid.Nil
+	for {
+		var rows []map[string]interface{}
+		err := m.db.Table(target.table).
+			Select(columns).
+			Where("id > ?", lastID).
+			Order("id").
+			Limit(m.batchSize).
+			Find(&rows).Error
+		if err != nil {
+			return stats, err
+		}
+		if len(rows) == 0 {
+			return stats, nil
+		}
+
+		err = m.db.Transaction(func(tx *gorm.DB) error {
+			for _, row := range rows {
+				m.migrateRow(tx, target, row, &stats)
+			}
+			return nil
+		})
+		if err != nil {
+			return stats, err
+		}
+
+		last, err := uuid.Parse(toString(rows[len(rows)-1]["id"]))
+		if err != nil {
+			return stats, err
+		}
+		lastID = last
+		utils.Logger.Info("Re-encryption progress", "table", target.table, "rows", stats.rows)
+	}
+}
+
+// migrateRow re-encrypts the columns of one row. A failure is logged and counted without
+// aborting the batch: the update runs in a savepoint of the batch transaction.
+func (m *migrator) migrateRow(tx *gorm.DB, target encryptedTable, row map[string]interface{}, stats *tableStats) {
+	stats.rows++
+	id := toString(row["id"])
+
+	updates := make(map[string]interface{})
+	for _, column := range target.columns {
+		value := toString(row[column])
+		if value == "" {
+			continue
+		}
+		reencrypted, changed, err := m.reencrypt(value)
+		if err != nil {
+			utils.Logger.Error("Failed to re-encrypt value", "table", target.table, "id", id, "column", column, "error", err)
+			stats.failed++
+			return
+		}
+		if changed {
+			updates[column] = reencrypted
+		}
+	}
+
+	if len(updates) == 0 {
+		stats.skipped++
+		return
+	}
+
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		return tx.Table(target.table).Where("id = ?", id).Updates(updates).Error
+	})
+	if err != nil {
+		utils.Logger.Error("Failed to update row", "table", target.table, "id", id, "error", err)
+		stats.failed++
+		return
+	}
+	stats.updated++
+}
+
+// reencrypt returns value encrypted with the new key, and false if it already was
+func (m *migrator) reencrypt(value string) (string, bool, error) {
+	if _, err := utils.DecryptWithVersion(value, m.newKey); err == nil {
+		return value, false, nil
+	}
+
+	plainText, err := utils.DecryptWithVersion(value, m.oldKey)
+	if err != nil {
+		return "", false, err
+	}
+	reencrypted, err := utils.EncryptWithVersion(plainText, m.newKey)
+	if err != nil {
+		return "", false, err
+	}
+	return reencrypted, true, nil
+}
+
+// toString converts a scanned column value to a string; NULL becomes ""
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case [16]byte:
+		return uuid.UUID(v).String()
+	}
+	return ""
+}
+
+// fatal logs err and exits, like log.Fatalf
+func fatal(msg string, err error) {
+	utils.Logger.Error(msg, "error", err)
+	os.Exit(1)
+}
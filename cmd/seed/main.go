@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+
+	"mms-backend/config"
+	"mms-backend/migrations"
+	"mms-backend/models"
+	"mms-backend/seeds"
+	"mms-backend/utils"
+)
+
+// Seeds the development database with users, conversations and groups.
+//
+//	go run ./cmd/seed           # insert missing seed rows
+//	go run ./cmd/seed --reset   # drop every table first
+func main() {
+	reset := flag.Bool("reset", false, "drop and recreate every table before seeding")
+	flag.Parse()
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fatal("Failed to load configuration", err)
+	}
+	utils.InitLogger(cfg.Server.Environment)
+	if cfg.Server.Environment == "production" {
+		fatal("Refusing to seed a production database", errors.New("ENV is production"))
+	}
+
+	db, err := config.InitDatabase(&cfg.Database)
+	if err != nil {
+		fatal("Failed to connect to database", err)
+	}
+
+	if *reset {
+		utils.Logger.Info("Dropping and recreating all tables")
+		if err := seeds.Reset(db); err != nil {
+			fatal("Failed to reset database", err)
+		}
+	} else {
+		if err := db.AutoMigrate(models.All()...); err != nil {
+			fatal("Failed to run migrations", err)
+		}
+		if err := migrations.Run(db); err != nil {
+			fatal("Failed to run SQL migrations", err)
+		}
+	}
+
+	if err := seeds.Run(db); err != nil {
+		fatal("Failed to seed database", err)
+	}
+
+	utils.Logger.Info("Seed data ready; every seed_* user signs in with the seed password", "password", seeds.Password)
+}
+
+// fatal logs err and exits, like log.Fatalf
+func fatal(msg string, err error) {
+	utils.Logger.Error(msg, "error", err)
+	os.Exit(1)
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigDatabasePool(t *testing.T) {
+	tests := []struct {
+		name             string
+		env              map[string]string
+		maxOpen, maxIdle int
+		lifetime         time.Duration
+	}{
+		{"defaults", nil, 25, 5, 5 * time.Minute},
+		{"configured", map[string]string{
+			"DB_MAX_OPEN_CONNS":    "50",
+			"DB_MAX_IDLE_CONNS":    "0",
+			"DB_CONN_MAX_LIFETIME": "300s",
+		}, 50, 0, 300 * time.Second},
+		{"invalid values fall back", map[string]string{
+			"DB_MAX_OPEN_CONNS":    "0",
+			"DB_MAX_IDLE_CONNS":    "-1",
+			"DB_CONN_MAX_LIFETIME": "300",
+		}, 25, 5, 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"DB_MAX_OPEN_CONNS", "DB_MAX_IDLE_CONNS", "DB_CONN_MAX_LIFETIME"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			cfg, err := LoadConfig()
+			if err != nil {
+				t.Fatalf("LoadConfig: %v", err)
+			}
+			db := cfg.Database
+			if db.MaxOpenConns != tt.maxOpen || db.MaxIdleConns != tt.maxIdle || db.ConnMaxLifetime != tt.lifetime {
+				t.Errorf("got pool %d/%d/%s, want %d/%d/%s",
+					db.MaxOpenConns, db.MaxIdleConns, db.ConnMaxLifetime, tt.maxOpen, tt.maxIdle, tt.lifetime)
+			}
+		})
+	}
+}
+
+func TestLoadConfigReplicaDSNs(t *testing.T) {
+	tests := []struct {
+		name      string
+		dsns, dsn string
+		want      []string
+	}{
+		{"none", "", "", nil},
+		{"list", "host=a, host=b", "", []string{"host=a", "host=b"}},
+		{"single", "", "host=c", []string{"host=c"}},
+		{"list wins", "host=a", "host=c", []string{"host=a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DB_REPLICA_DSNS", tt.dsns)
+			t.Setenv("DB_REPLICA_DSN", tt.dsn)
+
+			cfg, err := LoadConfig()
+			if err != nil {
+				t.Fatalf("LoadConfig: %v", err)
+			}
+			if !slices.Equal(cfg.Database.Resolver.ReplicaDSNs, tt.want) {
+				t.Errorf("got replicas %q, want %q", cfg.Database.Resolver.ReplicaDSNs, tt.want)
+			}
+		})
+	}
+}
@@ -1,11 +1,12 @@
 package config
 
 import (
-	"log"
+	"log/slog"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+	"gorm.io/plugin/dbresolver"
 )
 
 var DB *gorm.DB
@@ -28,7 +29,32 @@ func InitDatabase(config *DatabaseConfig) (*gorm.DB, error) {
 		return nil, err
 	}
 
-	log.Println("Database connection established successfully")
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(config.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(config.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(config.ConnMaxLifetime)
+
+	// Route reads to replicas when any are configured; writes and
+	// dbresolver.Write clauses always use the primary
+	if len(config.Resolver.ReplicaDSNs) > 0 {
+		replicas := make([]gorm.Dialector, 0, len(config.Resolver.ReplicaDSNs))
+		for _, replicaDSN := range config.Resolver.ReplicaDSNs {
+			replicas = append(replicas, postgres.Open(replicaDSN))
+		}
+		resolver := dbresolver.Register(dbresolver.Config{Replicas: replicas})
+		if err := db.Use(resolver); err != nil {
+			return nil, err
+		}
+		resolver.SetMaxOpenConns(config.MaxOpenConns).
+			SetMaxIdleConns(config.MaxIdleConns).
+			SetConnMaxLifetime(config.ConnMaxLifetime)
+		slog.Info("Registered read replicas", "count", len(replicas))
+	}
+
+	slog.Info("Database connection established")
 	DB = db
 	return db, nil
 }
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DefaultJWTSecret is the placeholder JWT_SECRET falls back to; the server refuses to start with it
+const DefaultJWTSecret = "default-secret-change-me"
+
+// minEncryptionKeyLength is the shortest ENCRYPTION_KEY accepted
+const minEncryptionKeyLength = 16
+
+// Validate checks the settings the server can't run safely without. It reports every problem
+// at once, joined into one error, rather than only the first.
+func Validate(cfg *Config) error {
+	var errs []error
+
+	if cfg.JWT.Secret == "" || cfg.JWT.Secret == DefaultJWTSecret {
+		errs = append(errs, errors.New("JWT_SECRET must be set to a secret value"))
+	}
+	if len(cfg.Security.EncryptionKey) < minEncryptionKeyLength {
+		errs = append(errs, fmt.Errorf("ENCRYPTION_KEY must be at least %d characters", minEncryptionKeyLength))
+	}
+	if cfg.Security.PreviousEncryptionKey != "" && cfg.Security.PreviousEncryptionKey == cfg.Security.EncryptionKey {
+		errs = append(errs, errors.New("ENCRYPTION_KEY_PREVIOUS must differ from ENCRYPTION_KEY"))
+	}
+	if cfg.Database.Password == "" && cfg.Server.Environment != "test" {
+		errs = append(errs, errors.New("DB_PASSWORD must be set"))
+	}
+	if cfg.Server.Environment == "production" && len(cfg.Server.AllowedOrigins) == 0 {
+		errs = append(errs, errors.New("ALLOWED_ORIGINS must list at least one origin in production"))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
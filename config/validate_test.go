@@ -0,0 +1,70 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func validConfig() *Config {
+	return &Config{
+		Database: DatabaseConfig{Password: "secret"},
+		Server:   ServerConfig{Environment: "development"},
+		JWT:      JWTConfig{Secret: "a-real-secret"},
+		Security: SecurityConfig{EncryptionKey: "0123456789abcdef"},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		errors []string // Substrings expected in the error; none means valid
+	}{
+		{"valid", func(c *Config) {}, nil},
+		{"placeholder JWT secret", func(c *Config) { c.JWT.Secret = DefaultJWTSecret }, []string{"JWT_SECRET"}},
+		{"short encryption key", func(c *Config) { c.Security.EncryptionKey = "short" }, []string{"ENCRYPTION_KEY"}},
+		{"previous encryption key", func(c *Config) { c.Security.PreviousEncryptionKey = "fedcba9876543210" }, nil},
+		{"previous encryption key unchanged", func(c *Config) {
+			c.Security.PreviousEncryptionKey = c.Security.EncryptionKey
+		}, []string{"ENCRYPTION_KEY_PREVIOUS"}},
+		{"missing DB password", func(c *Config) { c.Database.Password = "" }, []string{"DB_PASSWORD"}},
+		{"no DB password in tests", func(c *Config) {
+			c.Database.Password = ""
+			c.Server.Environment = "test"
+		}, nil},
+		{"production without origins", func(c *Config) { c.Server.Environment = "production" }, []string{"ALLOWED_ORIGINS"}},
+		{"production with origins", func(c *Config) {
+			c.Server.Environment = "production"
+			c.Server.AllowedOrigins = []string{"https://app.example.com"}
+		}, nil},
+		{"every problem reported", func(c *Config) {
+			c.JWT.Secret = DefaultJWTSecret
+			c.Security.EncryptionKey = ""
+			c.Database.Password = ""
+			c.Server.Environment = "production"
+		}, []string{"JWT_SECRET", "ENCRYPTION_KEY", "DB_PASSWORD", "ALLOWED_ORIGINS"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(cfg)
+
+			err := Validate(cfg)
+			if len(tt.errors) == 0 {
+				if err != nil {
+					t.Fatalf("expected a valid config, got %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected a validation error")
+			}
+			for _, expected := range tt.errors {
+				if !strings.Contains(err.Error(), expected) {
+					t.Errorf("expected %q in %q", expected, err.Error())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,352 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"mms-backend/middleware"
+	"mms-backend/services"
+	"mms-backend/utils"
+)
+
+// AdminController handles administrative endpoints
+type AdminController struct {
+	adminService         *services.AdminService
+	contentFilterService *services.ContentFilterService
+}
+
+// NewAdminController creates a new admin controller
+func NewAdminController(adminService *services.AdminService, contentFilterService *services.ContentFilterService) *AdminController {
+	return &AdminController{
+		adminService:         adminService,
+		contentFilterService: contentFilterService,
+	}
+}
+
+// Broadcast sends a system message to every user
+// @Summary Send a system broadcast
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body services.BroadcastRequest true "Broadcast Request"
+// @Success 201 {object} map[string]int
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/v1/broadcast [post]
+func (ctrl *AdminController) Broadcast(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	var req services.BroadcastRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	recipients, err := ctrl.adminService.SendSystemBroadcast(userID, req.Content, req.Priority)
+	if err != nil {
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, services.ErrNotAdmin):
+			status = http.StatusForbidden
+		case recipients > 0:
+			// Some messages were stored before the failure
+			status = http.StatusInternalServerError
+		}
+		c.JSON(status, gin.H{
+			"error":      err.Error(),
+			"recipients": recipients,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":    "broadcast sent",
+		"recipients": recipients,
+	})
+}
+
+// ReloadTranslations re-reads the locale files without restarting the server
+// @Summary Reload translations
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/v1/i18n/reload [get]
+func (ctrl *AdminController) ReloadTranslations(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	if err := ctrl.adminService.RequireAdmin(userID); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	i18n := utils.GetI18n()
+	if err := i18n.ReloadTranslations(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	languages := i18n.SupportedLanguages()
+	sort.Strings(languages)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "translations reloaded",
+		"data":    languages,
+	})
+}
+
+// PasswordMigrationStats reports how many users still have a bcrypt password hash
+// @Summary Password hash migration progress
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} services.PasswordMigrationStats
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/v1/password-migration-stats [get]
+func (ctrl *AdminController) PasswordMigrationStats(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	if err := ctrl.adminService.RequireAdmin(userID); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	stats, err := ctrl.adminService.GetPasswordMigrationStats()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": stats,
+	})
+}
+
+// SearchUsers finds users by username, email, phone or ID with their full account details
+// @Summary Search users (admin)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param q query string false "Matches username, email, phone or user ID"
+// @Param limit query int false "Limit" default(20)
+// @Param offset query int false "Offset" default(0)
+// @Success 200 {array} models.AdminUser
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/v1/users/search [get]
+func (ctrl *AdminController) SearchUsers(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	if err := ctrl.adminService.RequireAdmin(userID); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	result, err := ctrl.adminService.SearchUsers(c.Query("q"), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": result.Users,
+		"meta": utils.NewMeta(result.Total, result.Limit, result.Offset),
+	})
+}
+
+// AddContentFilter adds a moderation rule applied to direct message content
+// @Summary Add a content filter rule
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body services.AddContentFilterRequest true "Content filter rule"
+// @Success 201 {object} models.ContentFilter
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/v1/content-filter [post]
+func (ctrl *AdminController) AddContentFilter(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	if err := ctrl.adminService.RequireAdmin(userID); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	var req services.AddContentFilterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	filter, err := ctrl.contentFilterService.AddRule(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "content filter added",
+		"data":    filter,
+	})
+}
+
+// ListContentFilters lists the moderation rules
+// @Summary List content filter rules
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.ContentFilter
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/v1/content-filter [get]
+func (ctrl *AdminController) ListContentFilters(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	if err := ctrl.adminService.RequireAdmin(userID); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	filters, err := ctrl.contentFilterService.ListRules()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": filters,
+	})
+}
+
+// DeleteContentFilter removes a moderation rule
+// @Summary Delete a content filter rule
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Content filter ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/v1/content-filter/{id} [delete]
+func (ctrl *AdminController) DeleteContentFilter(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	if err := ctrl.adminService.RequireAdmin(userID); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	filterID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid content filter ID",
+		})
+		return
+	}
+
+	if err := ctrl.contentFilterService.DeleteRule(filterID); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrContentFilterNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "content filter deleted",
+	})
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -28,7 +29,8 @@ func NewAuthController(authService *services.AuthService) *AuthController {
 // @Produce json
 // @Param request body services.SignupRequest true "Signup Request"
 // @Success 201 {object} services.AuthResponse
-// @Router /auth/signup [post]
+// @Failure 400 {object} map[string]string
+// @Router /v1/auth/signup [post]
 func (ctrl *AuthController) Signup(c *gin.Context) {
 	var req services.SignupRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -41,7 +43,7 @@ func (ctrl *AuthController) Signup(c *gin.Context) {
 	response, err := ctrl.authService.Signup(req)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
+			"error": authErrorMessage(c.GetString("lang"), err),
 		})
 		return
 	}
@@ -60,7 +62,9 @@ func (ctrl *AuthController) Signup(c *gin.Context) {
 // @Produce json
 // @Param request body services.LoginRequest true "Login Request"
 // @Success 200 {object} services.AuthResponse
-// @Router /auth/login [post]
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /v1/auth/login [post]
 func (ctrl *AuthController) Login(c *gin.Context) {
 	var req services.LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -70,7 +74,42 @@ func (ctrl *AuthController) Login(c *gin.Context) {
 		return
 	}
 
-	response, err := ctrl.authService.Login(req)
+	response, err := ctrl.authService.Login(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": authErrorMessage(c.GetString("lang"), err),
+		})
+		return
+	}
+
+	lang := response.User.Language
+	c.JSON(http.StatusOK, gin.H{
+		"message": utils.T(lang, "login_success"),
+		"data":    response,
+	})
+}
+
+// GoogleLogin handles Google sign-in
+// @Summary Login with Google
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body services.GoogleLoginRequest true "Google Login Request"
+// @Success 200 {object} services.AuthResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /v1/auth/google [post]
+// @Router /v1/auth/oauth/google [post]
+func (ctrl *AuthController) GoogleLogin(c *gin.Context) {
+	var req services.GoogleLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	response, err := ctrl.authService.GoogleLogin(req.IDToken)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"error": err.Error(),
@@ -85,7 +124,253 @@ func (ctrl *AuthController) Login(c *gin.Context) {
 	})
 }
 
+// SetupTwoFactor generates a TOTP secret for the current user
+// @Summary Set up two-factor authentication
+// @Description Returns the secret and a QR code (base64 PNG) for an authenticator app. 2FA stays off until /auth/2fa/verify.
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} services.TwoFactorSetupResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /v1/auth/2fa/setup [post]
+func (ctrl *AuthController) SetupTwoFactor(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	setup, err := ctrl.authService.SetupTwoFactor(userID)
+	if err != nil {
+		c.JSON(twoFactorErrorStatus(err, http.StatusInternalServerError), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": setup,
+	})
+}
+
+// VerifyTwoFactor turns on two-factor authentication once a code from the new secret checks out
+// @Summary Enable two-factor authentication
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body services.TwoFactorCodeRequest true "Code from the authenticator app"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /v1/auth/2fa/verify [post]
+func (ctrl *AuthController) VerifyTwoFactor(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	var req services.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := ctrl.authService.VerifyTwoFactor(userID, req.Code); err != nil {
+		c.JSON(twoFactorErrorStatus(err, http.StatusInternalServerError), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "two-factor authentication enabled",
+	})
+}
+
+// DisableTwoFactor turns off two-factor authentication
+// @Summary Disable two-factor authentication
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body services.TwoFactorCodeRequest true "Current code from the authenticator app"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /v1/auth/2fa/disable [post]
+func (ctrl *AuthController) DisableTwoFactor(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	var req services.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := ctrl.authService.DisableTwoFactor(userID, req.Code); err != nil {
+		c.JSON(twoFactorErrorStatus(err, http.StatusInternalServerError), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "two-factor authentication disabled",
+	})
+}
+
+// ConfirmTwoFactor completes a login that returned pending_2fa
+// @Summary Complete a two-factor login
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body services.TwoFactorConfirmRequest true "Two-factor token from login and a TOTP code"
+// @Success 200 {object} services.AuthResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /v1/auth/2fa/confirm [post]
+func (ctrl *AuthController) ConfirmTwoFactor(c *gin.Context) {
+	var req services.TwoFactorConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	response, err := ctrl.authService.ConfirmTwoFactor(req)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	lang := response.User.Language
+	c.JSON(http.StatusOK, gin.H{
+		"message": utils.T(lang, "login_success"),
+		"data":    response,
+	})
+}
+
+// twoFactorErrorStatus maps a two-factor setting error to 400, or fallback for others. A wrong
+// code is a 400 here rather than a 401, which clients take to mean their session expired.
+func twoFactorErrorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidTOTPCode),
+		errors.Is(err, services.ErrTOTPNotSetUp),
+		errors.Is(err, services.ErrTOTPAlreadyEnabled),
+		errors.Is(err, services.ErrTOTPNotEnabled):
+		return http.StatusBadRequest
+	}
+	return fallback
+}
+
+// authErrorKeys holds the translation key of each signup and login error that has one
+var authErrorKeys = []struct {
+	err error
+	key string
+}{
+	{services.ErrInvalidCredentials, "invalid_credentials"},
+	{services.ErrUsernameTaken, "username_taken"},
+	{services.ErrEmailTaken, "email_taken"},
+	{services.ErrPhoneTaken, "phone_taken"},
+	{utils.ErrInvalidEmail, "invalid_email"},
+	{utils.ErrInvalidUsername, "invalid_username"},
+	{utils.ErrUsernameTooShort, "username_too_short"},
+	{utils.ErrPasswordTooShort, "password_too_short"},
+	{utils.ErrInvalidPhone, "invalid_phone"},
+}
+
+// authErrorMessage translates a signup or login error to lang. Errors without a
+// translation are returned as is, in English.
+func authErrorMessage(lang string, err error) string {
+	for _, e := range authErrorKeys {
+		if errors.Is(err, e.err) {
+			return utils.TOrDefault(lang, e.key, err.Error())
+		}
+	}
+	return err.Error()
+}
+
+// ForgotPassword emails a password reset code
+// @Summary Request a password reset
+// @Description Always succeeds for well-formed requests so accounts cannot be discovered by email
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body services.ForgotPasswordRequest true "Forgot Password Request"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/auth/forgot-password [post]
+func (ctrl *AuthController) ForgotPassword(c *gin.Context) {
+	var req services.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := ctrl.authService.RequestPasswordReset(req.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to send reset email"})
+		return
+	}
+
+	lang := c.GetString("lang")
+	c.JSON(http.StatusOK, gin.H{"message": utils.T(lang, "password_reset_requested")})
+}
+
+// ResetPassword sets a new password using an emailed reset code
+// @Summary Reset password
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body services.ResetPasswordRequest true "Reset Password Request"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Router /v1/auth/reset-password [post]
+func (ctrl *AuthController) ResetPassword(c *gin.Context) {
+	var req services.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := ctrl.authService.ResetPassword(req.Token, req.Password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	lang := c.GetString("lang")
+	c.JSON(http.StatusOK, gin.H{"message": utils.T(lang, "password_reset_success")})
+}
+
 // CheckUsername checks username availability
+// @Summary Check username availability
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body object true "Username to check"
+// @Success 200 {object} map[string]bool
+// @Failure 400 {object} map[string]string
+// @Router /v1/auth/check-username [post]
 func (ctrl *AuthController) CheckUsername(c *gin.Context) {
 	var req struct {
 		Username string `json:"username" binding:"required"`
@@ -105,6 +390,14 @@ func (ctrl *AuthController) CheckUsername(c *gin.Context) {
 }
 
 // CheckEmail checks email availability
+// @Summary Check email availability
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body object true "Email to check"
+// @Success 200 {object} map[string]bool
+// @Failure 400 {object} map[string]string
+// @Router /v1/auth/check-email [post]
 func (ctrl *AuthController) CheckEmail(c *gin.Context) {
 	var req struct {
 		Email string `json:"email" binding:"required"`
@@ -124,6 +417,14 @@ func (ctrl *AuthController) CheckEmail(c *gin.Context) {
 }
 
 // CheckPhone checks phone availability
+// @Summary Check phone availability
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body object true "Phone to check"
+// @Success 200 {object} map[string]bool
+// @Failure 400 {object} map[string]string
+// @Router /v1/auth/check-phone [post]
 func (ctrl *AuthController) CheckPhone(c *gin.Context) {
 	var req struct {
 		Phone string `json:"phone" binding:"required"`
@@ -148,7 +449,8 @@ func (ctrl *AuthController) CheckPhone(c *gin.Context) {
 // @Produce json
 // @Security BearerAuth
 // @Success 200 {object} models.PublicUser
-// @Router /auth/me [get]
+// @Failure 401 {object} map[string]string
+// @Router /v1/auth/me [get]
 func (ctrl *AuthController) GetMe(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
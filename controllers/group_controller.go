@@ -1,24 +1,30 @@
 package controllers
 
 import (
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"mms-backend/middleware"
+	"mms-backend/repositories"
 	"mms-backend/services"
+	"mms-backend/utils"
 )
 
 // GroupController handles group endpoints
 type GroupController struct {
-	groupService *services.GroupService
+	groupService    *services.GroupService
+	idempotencyRepo *repositories.IdempotencyRepository
 }
 
 // NewGroupController creates a new group controller
-func NewGroupController(groupService *services.GroupService) *GroupController {
+func NewGroupController(groupService *services.GroupService, idempotencyRepo *repositories.IdempotencyRepository) *GroupController {
 	return &GroupController{
-		groupService: groupService,
+		groupService:    groupService,
+		idempotencyRepo: idempotencyRepo,
 	}
 }
 
@@ -30,7 +36,9 @@ func NewGroupController(groupService *services.GroupService) *GroupController {
 // @Security BearerAuth
 // @Param request body services.CreateGroupRequest true "Group Request"
 // @Success 201 {object} models.Group
-// @Router /groups [post]
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /v1/groups [post]
 func (ctrl *GroupController) CreateGroup(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
@@ -69,7 +77,11 @@ func (ctrl *GroupController) CreateGroup(c *gin.Context) {
 // @Security BearerAuth
 // @Param group_id path string true "Group ID"
 // @Success 200 {object} models.Group
-// @Router /groups/{group_id} [get]
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /v1/groups/{group_id} [get]
 func (ctrl *GroupController) GetGroup(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
@@ -90,7 +102,7 @@ func (ctrl *GroupController) GetGroup(c *gin.Context) {
 
 	group, err := ctrl.groupService.GetGroup(groupID, userID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
+		c.JSON(groupErrorStatus(err, http.StatusNotFound), gin.H{
 			"error": err.Error(),
 		})
 		return
@@ -107,9 +119,15 @@ func (ctrl *GroupController) GetGroup(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Param Idempotency-Key header string false "Client key (max 64 chars) to safely retry the request"
 // @Param request body services.SendGroupMessageRequest true "Message Request"
+// @Success 200 {object} models.GroupMessageResponse "Replayed response for a known Idempotency-Key"
 // @Success 201 {object} models.GroupMessageResponse
-// @Router /groups/messages [post]
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string "The first request with this Idempotency-Key is still in progress"
+// @Router /v1/groups/messages [post]
 func (ctrl *GroupController) SendGroupMessage(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
@@ -119,8 +137,14 @@ func (ctrl *GroupController) SendGroupMessage(c *gin.Context) {
 		return
 	}
 
+	idempotencyKey, answered := replayIdempotentResponse(c, ctrl.idempotencyRepo, userID)
+	if answered {
+		return
+	}
+
 	var req services.SendGroupMessageRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
+		releaseIdempotencyKey(ctrl.idempotencyRepo, userID, idempotencyKey)
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": err.Error(),
 		})
@@ -129,16 +153,20 @@ func (ctrl *GroupController) SendGroupMessage(c *gin.Context) {
 
 	message, err := ctrl.groupService.SendGroupMessage(userID, req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
+		releaseIdempotencyKey(ctrl.idempotencyRepo, userID, idempotencyKey)
+		c.JSON(groupErrorStatus(err, http.StatusBadRequest), gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
+	response := gin.H{
 		"message": "message sent successfully",
 		"data":    message,
-	})
+	}
+	storeIdempotentResponse(ctrl.idempotencyRepo, userID, idempotencyKey, response)
+
+	c.JSON(http.StatusCreated, response)
 }
 
 // GetGroupMessages gets messages for a group
@@ -150,7 +178,10 @@ func (ctrl *GroupController) SendGroupMessage(c *gin.Context) {
 // @Param limit query int false "Limit" default(50)
 // @Param offset query int false "Offset" default(0)
 // @Success 200 {array} models.GroupMessageResponse
-// @Router /groups/{group_id}/messages [get]
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /v1/groups/{group_id}/messages [get]
 func (ctrl *GroupController) GetGroupMessages(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
@@ -174,7 +205,7 @@ func (ctrl *GroupController) GetGroupMessages(c *gin.Context) {
 
 	messages, err := ctrl.groupService.GetGroupMessages(groupID, userID, limit, offset)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
+		c.JSON(groupErrorStatus(err, http.StatusBadRequest), gin.H{
 			"error": err.Error(),
 		})
 		return
@@ -185,14 +216,23 @@ func (ctrl *GroupController) GetGroupMessages(c *gin.Context) {
 	})
 }
 
-// GetUserGroups gets all groups for the current user
-// @Summary Get user groups
+// ExportGroupMessages downloads a group's message history as JSON or CSV
+// @Summary Export group messages
+// @Description Members export the messages they sent with for_self=true; the full history requires the admin role. Limited to 100000 messages.
 // @Tags groups
 // @Produce json
+// @Produce text/csv
 // @Security BearerAuth
-// @Success 200 {array} models.Group
-// @Router /groups/my [get]
-func (ctrl *GroupController) GetUserGroups(c *gin.Context) {
+// @Param group_id path string true "Group ID"
+// @Param format query string false "json or csv" default(json)
+// @Param for_self query bool false "Only export my own messages"
+// @Success 200 {array} services.GroupMessageExport
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Router /v1/groups/{group_id}/messages/export [get]
+func (ctrl *GroupController) ExportGroupMessages(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{
@@ -201,28 +241,54 @@ func (ctrl *GroupController) GetUserGroups(c *gin.Context) {
 		return
 	}
 
-	groups, err := ctrl.groupService.GetUserGroups(userID)
+	groupID, err := uuid.Parse(c.Param("group_id"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid group id",
+		})
+		return
+	}
+
+	format := c.DefaultQuery("format", "json")
+	forSelf, _ := strconv.ParseBool(c.Query("for_self"))
+
+	data, contentType, err := ctrl.groupService.ExportGroupMessages(userID, groupID, format, forSelf)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrInvalidExportFormat):
+			status = http.StatusBadRequest
+		case errors.Is(err, services.ErrExportTooLarge):
+			status = http.StatusRequestEntityTooLarge
+		}
+		c.JSON(groupErrorStatus(err, status), gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"data": groups,
-	})
+	c.Header("Content-Disposition", "attachment; filename=group-"+groupID.String()+"-messages."+format)
+	c.Data(http.StatusOK, contentType, data)
 }
 
-// DeleteGroup deletes a group
-// @Summary Delete a group
+// SearchGroupMessages runs a full-text search over a group's messages
+// @Summary Search group messages
+// @Description Matches whole words in the group's messages, newest first. Each hit includes a snippet of the text around the match.
 // @Tags groups
 // @Produce json
 // @Security BearerAuth
 // @Param group_id path string true "Group ID"
-// @Success 200 {object} map[string]string
-// @Router /groups/{group_id} [delete]
-func (ctrl *GroupController) DeleteGroup(c *gin.Context) {
+// @Param q query string true "Words to search for"
+// @Param after query string false "Only messages created after this RFC3339 time"
+// @Param before query string false "Only messages created before this RFC3339 time"
+// @Param limit query int false "Limit (max 100)" default(20)
+// @Param offset query int false "Offset" default(0)
+// @Success 200 {array} models.GroupMessageSearchResult
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /v1/groups/{group_id}/messages/search [get]
+func (ctrl *GroupController) SearchGroupMessages(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{
@@ -231,8 +297,7 @@ func (ctrl *GroupController) DeleteGroup(c *gin.Context) {
 		return
 	}
 
-	groupIDStr := c.Param("group_id")
-	groupID, err := uuid.Parse(groupIDStr)
+	groupID, err := uuid.Parse(c.Param("group_id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "invalid group id",
@@ -240,36 +305,102 @@ func (ctrl *GroupController) DeleteGroup(c *gin.Context) {
 		return
 	}
 
-	if err := ctrl.groupService.DeleteGroup(groupID, userID); err != nil {
+	opts, err := parseSearchOptions(c)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
 
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	result, err := ctrl.groupService.SearchGroupMessages(groupID, userID, opts, limit, offset)
+	if err != nil {
+		c.JSON(groupErrorStatus(err, http.StatusBadRequest), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"message": "group deleted successfully",
+		"data": result.Results,
+		"meta": utils.NewMeta(result.Total, result.Limit, result.Offset),
 	})
 }
 
-// GetGroupMembers gets all members of a group
-// @Summary Get group members
+// EditGroupMessage updates the content of a group message sent by the current user
+// @Summary Edit a group message
 // @Tags groups
+// @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param group_id path string true "Group ID"
-// @Success 200 {array} models.PublicUser
-// @Router /groups/{group_id}/members [get]
-func (ctrl *GroupController) GetGroupMembers(c *gin.Context) {
-	groupIDStr := c.Param("group_id")
-	groupID, err := uuid.Parse(groupIDStr)
+// @Param message_id path string true "Group message ID"
+// @Param request body services.EditGroupMessageRequest true "Edit Request"
+// @Success 200 {object} models.GroupMessageResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /v1/groups/messages/{message_id} [patch]
+func (ctrl *GroupController) EditGroupMessage(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	messageID, err := uuid.Parse(c.Param("message_id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid group id",
+			"error": "invalid message id",
+		})
+		return
+	}
+
+	var req services.EditGroupMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	message, err := ctrl.groupService.EditGroupMessage(messageID, userID, req)
+	if err != nil {
+		fallback := http.StatusBadRequest
+		if err.Error() == "group message not found" {
+			fallback = http.StatusNotFound
+		}
+		c.JSON(groupErrorStatus(err, fallback), gin.H{
+			"error": err.Error(),
 		})
 		return
 	}
 
+	c.JSON(http.StatusOK, gin.H{
+		"message": "message updated",
+		"data":    message,
+	})
+}
+
+// DeleteGroupMessage marks a group message as deleted
+// @Summary Delete a group message
+// @Description The sender can delete their own messages and group admins any message. The message stays in the history as "[message deleted]".
+// @Tags groups
+// @Produce json
+// @Security BearerAuth
+// @Param message_id path string true "Group message ID"
+// @Success 200 {object} models.GroupMessageResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /v1/groups/messages/{message_id} [delete]
+func (ctrl *GroupController) DeleteGroupMessage(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{
@@ -278,45 +409,89 @@ func (ctrl *GroupController) GetGroupMembers(c *gin.Context) {
 		return
 	}
 
-	members, err := ctrl.groupService.GetGroupMembers(groupID, userID)
+	messageID, err := uuid.Parse(c.Param("message_id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
+			"error": "invalid message id",
 		})
 		return
 	}
 
-	// Convert to public users
-	publicUsers := make([]interface{}, 0, len(members))
-	for _, member := range members {
-		publicUsers = append(publicUsers, member.ToPublicUser())
+	message, err := ctrl.groupService.DeleteGroupMessage(messageID, userID)
+	if err != nil {
+		fallback := http.StatusBadRequest
+		if err.Error() == "group message not found" {
+			fallback = http.StatusNotFound
+		}
+		c.JSON(groupErrorStatus(err, fallback), gin.H{
+			"error": err.Error(),
+		})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"data": publicUsers,
+		"message": "message deleted",
+		"data":    message,
 	})
 }
 
-// AddGroupMember adds a member to a group
-// @Summary Add group member
+// GetGroupMessageReaders lists members who read a group message
+// @Summary Get group message readers
 // @Tags groups
-// @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param group_id path string true "Group ID"
-// @Param request body object true "Member info"
-// @Success 200 {object} object
-// @Router /groups/{group_id}/members [post]
-func (ctrl *GroupController) AddGroupMember(c *gin.Context) {
-	groupIDStr := c.Param("group_id")
-	groupID, err := uuid.Parse(groupIDStr)
+// @Param message_id path string true "Group message ID"
+// @Success 200 {array} models.ReadReceipt
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /v1/groups/messages/{message_id}/readers [get]
+func (ctrl *GroupController) GetGroupMessageReaders(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	messageID, err := uuid.Parse(c.Param("message_id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid group id",
+			"error": "invalid message id",
+		})
+		return
+	}
+
+	readers, err := ctrl.groupService.GetMessageReaders(messageID, userID)
+	if err != nil {
+		c.JSON(groupErrorStatus(err, http.StatusNotFound), gin.H{
+			"error": err.Error(),
 		})
 		return
 	}
 
+	c.JSON(http.StatusOK, gin.H{
+		"data": readers,
+	})
+}
+
+// AddGroupMessageReaction reacts to a group message with an emoji
+// @Summary React to a group message
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param message_id path string true "Group message ID"
+// @Param request body services.AddReactionRequest true "Reaction Request"
+// @Success 200 {object} map[string]int
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /v1/groups/messages/{message_id}/reactions [post]
+func (ctrl *GroupController) AddGroupMessageReaction(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{
@@ -325,64 +500,220 @@ func (ctrl *GroupController) AddGroupMember(c *gin.Context) {
 		return
 	}
 
-	var req struct {
-		UserID string `json:"user_id" binding:"required"`
+	messageID, err := uuid.Parse(c.Param("message_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid message id",
+		})
+		return
 	}
+
+	var req services.AddReactionRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid request body",
+			"error": err.Error(),
 		})
 		return
 	}
 
-	memberID, err := uuid.Parse(req.UserID)
+	reactions, err := ctrl.groupService.AddGroupMessageReaction(messageID, userID, req.Emoji)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid user id",
+		c.JSON(groupReactionErrorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "reaction added",
+		"data":    reactions,
+	})
+}
+
+// RemoveGroupMessageReaction withdraws the current user's emoji reaction from a group message
+// @Summary Remove a group message reaction
+// @Tags groups
+// @Produce json
+// @Security BearerAuth
+// @Param message_id path string true "Group message ID"
+// @Param emoji path string true "Emoji (URL-encoded)"
+// @Success 200 {object} map[string]int
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /v1/groups/messages/{message_id}/reactions/{emoji} [delete]
+func (ctrl *GroupController) RemoveGroupMessageReaction(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
 		})
 		return
 	}
 
-	if err := ctrl.groupService.AddMember(groupID, memberID, userID); err != nil {
+	messageID, err := uuid.Parse(c.Param("message_id"))
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid message id",
+		})
+		return
+	}
+
+	reactions, err := ctrl.groupService.RemoveGroupMessageReaction(messageID, userID, c.Param("emoji"))
+	if err != nil {
+		c.JSON(groupReactionErrorStatus(err), gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "member added successfully",
+		"message": "reaction removed",
+		"data":    reactions,
 	})
 }
 
-// RemoveGroupMember removes a member from a group
-// @Summary Remove group member
+// groupReactionErrorStatus maps group reaction errors to their HTTP status
+func groupReactionErrorStatus(err error) int {
+	fallback := http.StatusBadRequest
+	if errors.Is(err, services.ErrReactionNotFound) || err.Error() == "group message not found" {
+		fallback = http.StatusNotFound
+	}
+	return groupErrorStatus(err, fallback)
+}
+
+// GetUserGroups gets all groups for the current user with their unread message counts
+// @Summary Get user groups
 // @Tags groups
 // @Produce json
 // @Security BearerAuth
-// @Param group_id path string true "Group ID"
-// @Param user_id path string true "User ID to remove"
-// @Success 200 {object} object
-// @Router /groups/{group_id}/members/{user_id} [delete]
-func (ctrl *GroupController) RemoveGroupMember(c *gin.Context) {
-	groupIDStr := c.Param("group_id")
-	groupID, err := uuid.Parse(groupIDStr)
+// @Param include_archived query bool false "Include archived groups" default(false)
+// @Param limit query int false "Limit; all groups when omitted" default(0)
+// @Param offset query int false "Offset" default(0)
+// @Success 200 {array} models.GroupSummary
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/groups/my [get]
+func (ctrl *GroupController) GetUserGroups(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	includeArchived, _ := strconv.ParseBool(c.DefaultQuery("include_archived", "false"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	groups, err := ctrl.groupService.GetUserGroups(userID, includeArchived, limit, offset)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid group id",
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
 		})
 		return
 	}
 
-	memberIDStr := c.Param("user_id")
-	memberID, err := uuid.Parse(memberIDStr)
+	total, err := ctrl.groupService.CountUserGroups(userID, includeArchived)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid user id",
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": groups,
+		"meta": utils.NewMeta(total, limit, offset),
+	})
+}
+
+// DiscoverGroups lists public groups, optionally searched by name and filtered by language
+// @Summary Discover public groups
+// @Tags groups
+// @Produce json
+// @Security BearerAuth
+// @Param q query string false "Case-insensitive name search"
+// @Param language query string false "Group language, e.g. en"
+// @Param limit query int false "Limit" default(20)
+// @Param offset query int false "Offset" default(0)
+// @Success 200 {array} models.GroupWithCount
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/groups/discover [get]
+func (ctrl *GroupController) DiscoverGroups(c *gin.Context) {
+	if _, exists := middleware.GetUserID(c); !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	groups, err := ctrl.groupService.DiscoverGroups(c.Query("q"), c.Query("language"), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": groups,
+	})
+}
+
+// ListPublicGroups lists public groups with their member counts, optionally searched by name
+// and description
+// @Summary List public groups
+// @Tags groups
+// @Produce json
+// @Security BearerAuth
+// @Param q query string false "Case-insensitive name or description search"
+// @Param limit query int false "Limit" default(20)
+// @Param offset query int false "Offset" default(0)
+// @Success 200 {array} models.PublicGroupSummary
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/groups/public [get]
+func (ctrl *GroupController) ListPublicGroups(c *gin.Context) {
+	if _, exists := middleware.GetUserID(c); !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	groups, err := ctrl.groupService.ListPublicGroups(c.Query("q"), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
 		})
 		return
 	}
 
+	c.JSON(http.StatusOK, gin.H{
+		"data": groups,
+	})
+}
+
+// GetArchivedGroups gets the groups the current user archived
+// @Summary Get archived groups
+// @Tags groups
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.Group
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/groups/archived [get]
+func (ctrl *GroupController) GetArchivedGroups(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{
@@ -391,15 +722,1106 @@ func (ctrl *GroupController) RemoveGroupMember(c *gin.Context) {
 		return
 	}
 
-	if err := ctrl.groupService.RemoveMember(groupID, memberID, userID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
+	groups, err := ctrl.groupService.GetArchivedGroups(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "member removed successfully",
+		"data": groups,
 	})
 }
 
+// ArchiveGroup hides a group from the current user's active list
+// @Summary Archive a group
+// @Tags groups
+// @Produce json
+// @Security BearerAuth
+// @Param group_id path string true "Group ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /v1/groups/{group_id}/archive [post]
+func (ctrl *GroupController) ArchiveGroup(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid group id",
+		})
+		return
+	}
+
+	if err := ctrl.groupService.ArchiveGroup(userID, groupID); err != nil {
+		c.JSON(groupErrorStatus(err, http.StatusBadRequest), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "group archived",
+	})
+}
+
+// UnarchiveGroup restores an archived group to the current user's active list
+// @Summary Unarchive a group
+// @Tags groups
+// @Produce json
+// @Security BearerAuth
+// @Param group_id path string true "Group ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /v1/groups/{group_id}/archive [delete]
+func (ctrl *GroupController) UnarchiveGroup(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid group id",
+		})
+		return
+	}
+
+	if err := ctrl.groupService.UnarchiveGroup(userID, groupID); err != nil {
+		c.JSON(groupErrorStatus(err, http.StatusBadRequest), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "group unarchived",
+	})
+}
+
+// MuteGroup mutes notifications of a group for the current user
+// @Summary Mute a group
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param group_id path string true "Group ID"
+// @Param request body services.MuteGroupRequest false "Mute end time (omit or null for indefinitely)"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /v1/groups/{group_id}/mute [put]
+func (ctrl *GroupController) MuteGroup(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid group id",
+		})
+		return
+	}
+
+	// An empty body mutes indefinitely
+	var req services.MuteGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := ctrl.groupService.MuteGroup(userID, groupID, req.Until); err != nil {
+		c.JSON(groupErrorStatus(err, http.StatusBadRequest), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "group muted",
+	})
+}
+
+// UnmuteGroup restores notifications of a group for the current user
+// @Summary Unmute a group
+// @Tags groups
+// @Produce json
+// @Security BearerAuth
+// @Param group_id path string true "Group ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /v1/groups/{group_id}/mute [delete]
+func (ctrl *GroupController) UnmuteGroup(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid group id",
+		})
+		return
+	}
+
+	if err := ctrl.groupService.UnmuteGroup(userID, groupID); err != nil {
+		c.JSON(groupErrorStatus(err, http.StatusBadRequest), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "group unmuted",
+	})
+}
+
+// SaveGroupDraft stores the current user's unsent draft for a group
+// @Summary Save group draft
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param group_id path string true "Group ID"
+// @Param request body services.SaveDraftRequest true "Draft Request"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /v1/groups/{group_id}/draft [put]
+func (ctrl *GroupController) SaveGroupDraft(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid group id",
+		})
+		return
+	}
+
+	var req services.SaveDraftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := ctrl.groupService.SaveGroupDraft(userID, groupID, req.Content); err != nil {
+		c.JSON(groupErrorStatus(err, http.StatusBadRequest), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "draft saved",
+	})
+}
+
+// GetGroupUnreadCount returns how many messages of a group the current user has not read
+// @Summary Get group unread count
+// @Tags groups
+// @Produce json
+// @Security BearerAuth
+// @Param group_id path string true "Group ID"
+// @Success 200 {object} map[string]int64
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/groups/{group_id}/unread/count [get]
+func (ctrl *GroupController) GetGroupUnreadCount(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid group id",
+		})
+		return
+	}
+
+	count, err := ctrl.groupService.GetGroupUnreadCount(groupID, userID)
+	if err != nil {
+		c.JSON(groupErrorStatus(err, http.StatusInternalServerError), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"count": count,
+	})
+}
+
+// GetGroupDraft returns the current user's unsent draft for a group
+// @Summary Get group draft
+// @Tags groups
+// @Produce json
+// @Security BearerAuth
+// @Param group_id path string true "Group ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /v1/groups/{group_id}/draft [get]
+func (ctrl *GroupController) GetGroupDraft(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid group id",
+		})
+		return
+	}
+
+	content, err := ctrl.groupService.GetGroupDraft(userID, groupID)
+	if err != nil {
+		c.JSON(groupErrorStatus(err, http.StatusInternalServerError), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"content": content,
+		},
+	})
+}
+
+// DeleteGroupDraft discards the current user's unsent draft for a group
+// @Summary Delete group draft
+// @Tags groups
+// @Produce json
+// @Security BearerAuth
+// @Param group_id path string true "Group ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /v1/groups/{group_id}/draft [delete]
+func (ctrl *GroupController) DeleteGroupDraft(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid group id",
+		})
+		return
+	}
+
+	if err := ctrl.groupService.DeleteGroupDraft(userID, groupID); err != nil {
+		c.JSON(groupErrorStatus(err, http.StatusInternalServerError), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "draft deleted",
+	})
+}
+
+// DeleteGroup deletes a group
+// @Summary Delete a group
+// @Tags groups
+// @Produce json
+// @Security BearerAuth
+// @Param group_id path string true "Group ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /v1/groups/{group_id} [delete]
+func (ctrl *GroupController) DeleteGroup(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	groupIDStr := c.Param("group_id")
+	groupID, err := uuid.Parse(groupIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid group id",
+		})
+		return
+	}
+
+	if err := ctrl.groupService.DeleteGroup(groupID, userID); err != nil {
+		c.JSON(groupErrorStatus(err, http.StatusBadRequest), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "group deleted successfully",
+	})
+}
+
+// GetGroupMembers gets all members of a group
+// @Summary Get group members
+// @Tags groups
+// @Produce json
+// @Security BearerAuth
+// @Param group_id path string true "Group ID"
+// @Success 200 {array} models.PublicUser
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /v1/groups/{group_id}/members [get]
+func (ctrl *GroupController) GetGroupMembers(c *gin.Context) {
+	groupIDStr := c.Param("group_id")
+	groupID, err := uuid.Parse(groupIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid group id",
+		})
+		return
+	}
+
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	members, err := ctrl.groupService.GetGroupMembers(groupID, userID)
+	if err != nil {
+		c.JSON(groupErrorStatus(err, http.StatusBadRequest), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// Convert to public users
+	publicUsers := make([]interface{}, 0, len(members))
+	for _, member := range members {
+		publicUsers = append(publicUsers, member.ToPublicUser())
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": publicUsers,
+	})
+}
+
+// GetGroupPermissions returns the minimum role required for each group action
+// @Summary Get group permissions
+// @Tags groups
+// @Produce json
+// @Security BearerAuth
+// @Param group_id path string true "Group ID"
+// @Success 200 {object} models.GroupPermissions
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /v1/groups/{group_id}/permissions [get]
+func (ctrl *GroupController) GetGroupPermissions(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid group id",
+		})
+		return
+	}
+
+	permissions, err := ctrl.groupService.GetGroupPermissions(userID, groupID)
+	if err != nil {
+		c.JSON(groupErrorStatus(err, http.StatusInternalServerError), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": permissions,
+	})
+}
+
+// UpdateGroupPermissions changes the minimum role required for group actions (owner only)
+// @Summary Update group permissions
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param group_id path string true "Group ID"
+// @Param request body services.GroupPermissionsRequest true "Permissions Request"
+// @Success 200 {object} models.GroupPermissions
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /v1/groups/{group_id}/permissions [patch]
+func (ctrl *GroupController) UpdateGroupPermissions(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid group id",
+		})
+		return
+	}
+
+	var req services.GroupPermissionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := ctrl.groupService.SetPermissions(userID, groupID, req); err != nil {
+		c.JSON(groupErrorStatus(err, http.StatusBadRequest), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	permissions, err := ctrl.groupService.GetPermissions(groupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "permissions updated",
+		"data":    permissions,
+	})
+}
+
+// TransferOwnership hands the group over to another member
+// @Summary Transfer group ownership
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param group_id path string true "Group ID"
+// @Param request body object true "New owner (user_id)"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /v1/groups/{group_id}/transfer [post]
+// @Router /v1/groups/{group_id}/owner [put]
+func (ctrl *GroupController) TransferOwnership(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid group id",
+		})
+		return
+	}
+
+	var req struct {
+		UserID string `json:"user_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid request body",
+		})
+		return
+	}
+
+	newOwnerID, err := uuid.Parse(req.UserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid user id",
+		})
+		return
+	}
+
+	if err := ctrl.groupService.TransferOwnership(groupID, userID, newOwnerID); err != nil {
+		c.JSON(groupErrorStatus(err, http.StatusBadRequest), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "ownership transferred",
+	})
+}
+
+// CreateInviteLink creates a time-limited link to join a group
+// @Summary Create a group invite link
+// @Description Admins only. Anyone holding the token can join with POST /v1/groups/join/{token} until the link expires or reaches max_uses.
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param group_id path string true "Group ID"
+// @Param request body services.CreateInviteRequest false "Expiry (default 24 hours, max 720) and maximum number of joins (0 for unlimited)"
+// @Success 201 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/groups/{group_id}/invite [post]
+func (ctrl *GroupController) CreateInviteLink(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid group id",
+		})
+		return
+	}
+
+	// An empty body creates a link valid for a day, without a use limit
+	var req services.CreateInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	token, err := ctrl.groupService.GenerateInviteLink(groupID, userID, req.ExpiresInHours, req.MaxUses)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInviteExpiry) || errors.Is(err, services.ErrInviteMaxUses) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(groupErrorStatus(err, status), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "invite link created",
+		"data": gin.H{
+			"token": token,
+		},
+	})
+}
+
+// JoinGroupByInvite adds the current user to the group of an invite link
+// @Summary Join a group with an invite link
+// @Tags groups
+// @Produce json
+// @Security BearerAuth
+// @Param token path string true "Invite token"
+// @Success 200 {object} models.Group
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/groups/join/{token} [post]
+func (ctrl *GroupController) JoinGroupByInvite(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	group, err := ctrl.groupService.JoinGroupByInvite(c.Param("token"), userID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidInvite) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "joined group",
+		"data":    group,
+	})
+}
+
+// AddGroupMember adds a member to a group
+// @Summary Add group member
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param group_id path string true "Group ID"
+// @Param request body object true "Member info"
+// @Success 200 {object} object
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /v1/groups/{group_id}/members [post]
+func (ctrl *GroupController) AddGroupMember(c *gin.Context) {
+	groupIDStr := c.Param("group_id")
+	groupID, err := uuid.Parse(groupIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid group id",
+		})
+		return
+	}
+
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	var req struct {
+		UserID string `json:"user_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid request body",
+		})
+		return
+	}
+
+	memberID, err := uuid.Parse(req.UserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid user id",
+		})
+		return
+	}
+
+	if err := ctrl.groupService.AddMember(groupID, userID, memberID); err != nil {
+		c.JSON(groupErrorStatus(err, http.StatusBadRequest), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "member added successfully",
+	})
+}
+
+// BulkAddGroupMembers adds several users to a group at once
+// @Summary Add several group members
+// @Description Users that are already members are skipped; users that cannot be added are listed in errors.
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param group_id path string true "Group ID"
+// @Param request body services.BulkAddMembersRequest true "User IDs (max 100)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /v1/groups/{group_id}/members/bulk [post]
+func (ctrl *GroupController) BulkAddGroupMembers(c *gin.Context) {
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid group id",
+		})
+		return
+	}
+
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	var req services.BulkAddMembersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	added, bulkErrors, err := ctrl.groupService.BulkAddMembers(userID, groupID, req.UserIDs)
+	if err != nil {
+		c.JSON(groupErrorStatus(err, http.StatusBadRequest), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if bulkErrors == nil {
+		bulkErrors = []services.BulkAddError{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"added":  added,
+		"errors": bulkErrors,
+	})
+}
+
+// RemoveGroupMember removes a member from a group
+// @Summary Remove group member
+// @Tags groups
+// @Produce json
+// @Security BearerAuth
+// @Param group_id path string true "Group ID"
+// @Param user_id path string true "User ID to remove"
+// @Success 200 {object} object
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /v1/groups/{group_id}/members/{user_id} [delete]
+func (ctrl *GroupController) RemoveGroupMember(c *gin.Context) {
+	groupIDStr := c.Param("group_id")
+	groupID, err := uuid.Parse(groupIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid group id",
+		})
+		return
+	}
+
+	memberIDStr := c.Param("user_id")
+	memberID, err := uuid.Parse(memberIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid user id",
+		})
+		return
+	}
+
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	if err := ctrl.groupService.RemoveMember(groupID, userID, memberID); err != nil {
+		c.JSON(groupErrorStatus(err, http.StatusBadRequest), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "member removed successfully",
+	})
+}
+
+// UpdateMemberRole promotes or demotes a group member
+// @Summary Change a group member's role
+// @Description Admins set the role of members they outrank to admin, moderator or member. Only the owner can promote admins.
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param group_id path string true "Group ID"
+// @Param user_id path string true "Member user ID"
+// @Param request body services.UpdateMemberRoleRequest true "New role"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /v1/groups/{group_id}/members/{user_id}/role [patch]
+func (ctrl *GroupController) UpdateMemberRole(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid group id",
+		})
+		return
+	}
+
+	memberID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid user id",
+		})
+		return
+	}
+
+	var req services.UpdateMemberRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid request body",
+		})
+		return
+	}
+
+	if err := ctrl.groupService.UpdateMemberRole(groupID, userID, memberID, req); err != nil {
+		status := groupErrorStatus(err, http.StatusBadRequest)
+		if err.Error() == "member not found" {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "member role updated",
+	})
+}
+
+// PinMessage pins a message at the top of a group
+// @Summary Pin a group message
+// @Description Replaces the group's pinned message. Who may pin follows the group's pin_messages permission.
+// @Tags groups
+// @Produce json
+// @Security BearerAuth
+// @Param group_id path string true "Group ID"
+// @Param message_id path string true "Message ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /v1/groups/{group_id}/pin/{message_id} [put]
+func (ctrl *GroupController) PinMessage(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid group id",
+		})
+		return
+	}
+
+	messageID, err := uuid.Parse(c.Param("message_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid message id",
+		})
+		return
+	}
+
+	if err := ctrl.groupService.PinMessage(groupID, messageID, userID); err != nil {
+		status := groupErrorStatus(err, http.StatusBadRequest)
+		if err.Error() == "group message not found" {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "message pinned",
+	})
+}
+
+// UnpinMessage clears a group's pinned message
+// @Summary Unpin the group's pinned message
+// @Tags groups
+// @Produce json
+// @Security BearerAuth
+// @Param group_id path string true "Group ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /v1/groups/{group_id}/pin [delete]
+func (ctrl *GroupController) UnpinMessage(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid group id",
+		})
+		return
+	}
+
+	if err := ctrl.groupService.UnpinMessage(groupID, userID); err != nil {
+		c.JSON(groupErrorStatus(err, http.StatusBadRequest), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "message unpinned",
+	})
+}
+
+// UpdateGroupSettings changes a group's settings
+// @Summary Update group settings
+// @Description Admins toggle announcements-only mode (only moderators and above can post) and change the description or type
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param group_id path string true "Group ID"
+// @Param request body services.GroupSettings true "Settings to change"
+// @Success 200 {object} models.Group
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /v1/groups/{group_id}/settings [patch]
+func (ctrl *GroupController) UpdateGroupSettings(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid group id",
+		})
+		return
+	}
+
+	var req services.GroupSettings
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid request body",
+		})
+		return
+	}
+
+	group, err := ctrl.groupService.UpdateSettings(groupID, userID, req)
+	if err != nil {
+		c.JSON(groupErrorStatus(err, http.StatusBadRequest), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "settings updated",
+		"data":    group,
+	})
+}
+
+// groupErrorStatus maps group permission errors to 403, falling back to the given status
+func groupErrorStatus(err error, fallback int) int {
+	if errors.Is(err, services.ErrNotGroupMember) ||
+		errors.Is(err, services.ErrNotGroupAdmin) ||
+		errors.Is(err, services.ErrNotGroupOwner) ||
+		errors.Is(err, services.ErrOutranked) ||
+		errors.Is(err, services.ErrGroupPermission) ||
+		errors.Is(err, services.ErrNotGroupSender) ||
+		errors.Is(err, services.ErrCannotDelete) ||
+		errors.Is(err, services.ErrAnnouncementsOnly) {
+		return http.StatusForbidden
+	}
+	return fallback
+}
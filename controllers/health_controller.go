@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"mms-backend/utils"
+)
+
+// dbPingTimeout bounds how long GET /health/db waits for the database
+const dbPingTimeout = 2 * time.Second
+
+// HealthController handles dependency health checks
+type HealthController struct {
+	db *sql.DB
+}
+
+// NewHealthController creates a new health controller checking the primary database db
+func NewHealthController(db *sql.DB) *HealthController {
+	return &HealthController{
+		db: db,
+	}
+}
+
+// DatabaseHealth pings the primary database and reports its connection pool
+// @Summary Database health check
+// @Description Pings the primary database with a 2 second timeout and reports its connection pool usage
+// @Tags health
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 503 {object} map[string]interface{}
+// @Router /health/db [get]
+func (ctrl *HealthController) DatabaseHealth(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), dbPingTimeout)
+	defer cancel()
+	pingErr := ctrl.db.PingContext(ctx)
+
+	stats := ctrl.db.Stats()
+	pool := gin.H{
+		"max_open_connections": stats.MaxOpenConnections,
+		"open_connections":     stats.OpenConnections,
+		"in_use":               stats.InUse,
+		"idle":                 stats.Idle,
+		"wait_count":           stats.WaitCount,
+	}
+
+	if pingErr != nil {
+		// The driver's error may name hosts and users, so it only goes to the logs
+		utils.LoggerFromContext(c.Request.Context()).Error("Database health check failed", "error", pingErr)
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status": "unavailable",
+			"error":  "database unreachable",
+			"pool":   pool,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+		"pool":   pool,
+	})
+}
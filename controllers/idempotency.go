@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"mms-backend/repositories"
+	"mms-backend/utils"
+)
+
+const (
+	// IdempotencyKeyHeader lets clients retry a send without creating duplicates
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	maxIdempotencyKeyLength = 64
+	idempotencyKeyTTL       = 24 * time.Hour
+
+	// idempotencyReservationTTL bounds how long a key stays reserved without a response, e.g.
+	// when the server died mid-request
+	idempotencyReservationTTL = time.Minute
+)
+
+// replayIdempotentResponse reserves the request's Idempotency-Key, or writes the cached response
+// when the key was already answered and a 409 while its first request is still in flight.
+// It returns the reserved key and whether the request was already answered.
+func replayIdempotentResponse(c *gin.Context, repo *repositories.IdempotencyRepository, userID uuid.UUID) (string, bool) {
+	key := c.GetHeader(IdempotencyKeyHeader)
+	if key == "" || repo == nil {
+		return "", false
+	}
+
+	if len(key) > maxIdempotencyKeyLength {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "idempotency key must be at most 64 characters",
+		})
+		return "", true
+	}
+
+	// Reserving first means two concurrent requests with the same key can't both be handled
+	reserved, err := repo.Reserve(userID, key, idempotencyReservationTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return "", true
+	}
+	if reserved {
+		return key, false
+	}
+
+	cached, err := repo.Get(userID, key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return "", true
+	}
+	if cached == nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "a request with this idempotency key is still in progress",
+		})
+		return "", true
+	}
+	// The response holds the message in clear, so it is stored encrypted like the message itself
+	data, err := utils.Decrypt(string(cached))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to read cached response",
+		})
+		return "", true
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", []byte(data))
+	return "", true
+}
+
+// storeIdempotentResponse caches a successful response, encrypted, under the request's Idempotency-Key
+func storeIdempotentResponse(repo *repositories.IdempotencyRepository, userID uuid.UUID, key string, body gin.H) {
+	if key == "" || repo == nil {
+		return
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		releaseIdempotencyKey(repo, userID, key)
+		return
+	}
+	encrypted, err := utils.Encrypt(string(data))
+	if err != nil {
+		releaseIdempotencyKey(repo, userID, key)
+		return
+	}
+	_ = repo.Set(userID, key, []byte(encrypted), idempotencyKeyTTL)
+}
+
+// releaseIdempotencyKey frees the request's Idempotency-Key after a failure so the client can retry
+func releaseIdempotencyKey(repo *repositories.IdempotencyRepository, userID uuid.UUID, key string) {
+	if key == "" || repo == nil {
+		return
+	}
+	_ = repo.Release(userID, key)
+}
@@ -1,37 +1,1025 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"mms-backend/middleware"
+	"mms-backend/models"
+	"mms-backend/repositories"
 	"mms-backend/services"
+	"mms-backend/utils"
 )
 
 // MessageController handles message endpoints
 type MessageController struct {
-	messageService *services.MessageService
+	messageService    *services.MessageService
+	attachmentService *services.AttachmentService
+	idempotencyRepo   *repositories.IdempotencyRepository
 }
 
 // NewMessageController creates a new message controller
-func NewMessageController(messageService *services.MessageService) *MessageController {
+func NewMessageController(messageService *services.MessageService, attachmentService *services.AttachmentService, idempotencyRepo *repositories.IdempotencyRepository) *MessageController {
 	return &MessageController{
-		messageService: messageService,
+		messageService:    messageService,
+		attachmentService: attachmentService,
+		idempotencyRepo:   idempotencyRepo,
 	}
 }
 
+// readService returns the message service to read with, pinned to the primary
+// database when the client asked to see its own recent writes
+func (ctrl *MessageController) readService(c *gin.Context) *services.MessageService {
+	if middleware.ShouldReadPrimary(c) {
+		return ctrl.messageService.WithPrimary()
+	}
+	return ctrl.messageService
+}
+
 // SendMessage sends a message to another user
 // @Summary Send a message
 // @Tags messages
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param request body services.SendMessageRequest true "Message Request"
-// @Success 201 {object} models.MessageResponse
-// @Router /messages [post]
-func (ctrl *MessageController) SendMessage(c *gin.Context) {
+// @Param Idempotency-Key header string false "Client key (max 64 chars) to safely retry the request"
+// @Param request body services.SendMessageRequest true "Message Request"
+// @Success 200 {object} models.MessageResponse "Replayed response for a known Idempotency-Key"
+// @Success 201 {object} models.MessageResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string "The first request with this Idempotency-Key is still in progress"
+// @Router /v1/messages [post]
+func (ctrl *MessageController) SendMessage(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	idempotencyKey, answered := replayIdempotentResponse(c, ctrl.idempotencyRepo, userID)
+	if answered {
+		return
+	}
+
+	var req services.SendMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		releaseIdempotencyKey(ctrl.idempotencyRepo, userID, idempotencyKey)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	message, err := ctrl.messageService.SendMessage(c.Request.Context(), userID, req)
+	if err != nil {
+		releaseIdempotencyKey(ctrl.idempotencyRepo, userID, idempotencyKey)
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrUserBlocked) {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	response := gin.H{
+		"message": "message sent successfully",
+		"data":    message,
+	}
+	storeIdempotentResponse(ctrl.idempotencyRepo, userID, idempotencyKey, response)
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// ScheduleMessage schedules a text message to be sent later
+// @Summary Schedule a message
+// @Description The message is sent within 30 seconds after send_at, which must be in the future and within a year
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body services.ScheduleMessageRequest true "Scheduled Message Request"
+// @Success 201 {object} models.ScheduledMessageResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /v1/messages/scheduled [post]
+func (ctrl *MessageController) ScheduleMessage(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	var req services.ScheduleMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	scheduled, err := ctrl.messageService.ScheduleMessage(userID, req)
+	if err != nil {
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, services.ErrUserBlocked):
+			status = http.StatusForbidden
+		case err.Error() == "receiver not found":
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "message scheduled",
+		"data":    scheduled,
+	})
+}
+
+// GetScheduledMessages lists the current user's pending scheduled messages
+// @Summary Get scheduled messages
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.ScheduledMessageResponse
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/messages/scheduled [get]
+func (ctrl *MessageController) GetScheduledMessages(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	messages, err := ctrl.messageService.GetScheduledMessages(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": messages,
+	})
+}
+
+// CancelScheduledMessage cancels one of the current user's pending scheduled messages
+// @Summary Cancel a scheduled message
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Scheduled message ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /v1/messages/scheduled/{id} [delete]
+func (ctrl *MessageController) CancelScheduledMessage(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid scheduled message id",
+		})
+		return
+	}
+
+	if err := ctrl.messageService.CancelScheduledMessage(userID, id); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrScheduledMessageNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "scheduled message cancelled",
+	})
+}
+
+// ForwardMessage forwards one of the current user's messages to other users
+// @Summary Forward a message
+// @Description Sends a copy of a message the user sent or received to up to 20 users. The copies have is_forwarded set.
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body services.ForwardMessageRequest true "Forward Request"
+// @Success 201 {array} models.MessageResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /v1/messages/forward [post]
+func (ctrl *MessageController) ForwardMessage(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	var req services.ForwardMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	messages, err := ctrl.messageService.ForwardMessage(userID, req.SourceMessageID, req.ReceiverIDs)
+	if err != nil {
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, services.ErrNotConversationMember), errors.Is(err, services.ErrUserBlocked):
+			status = http.StatusForbidden
+		case err.Error() == "message not found":
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "message forwarded successfully",
+		"data":    messages,
+	})
+}
+
+// UploadAttachment stores a file to attach to a message
+// @Summary Upload a message attachment
+// @Description Accepts images, audio and PDF files up to 25 MB. Send the returned url as attachment_url.
+// @Tags messages
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param file formData file true "Attachment"
+// @Success 201 {object} services.AttachmentResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Failure 415 {object} map[string]string
+// @Router /v1/messages/attachments [post]
+func (ctrl *MessageController) UploadAttachment(c *gin.Context) {
+	if _, exists := middleware.GetUserID(c); !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	// Leave room for the multipart framing around the file itself
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, services.MaxAttachmentSize+1<<20)
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error": services.ErrAttachmentTooLarge.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "file is required",
+		})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	defer file.Close()
+
+	attachment, err := ctrl.attachmentService.Upload(file, header.Size)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrAttachmentTooLarge):
+			status = http.StatusRequestEntityTooLarge
+		case errors.Is(err, services.ErrUnsupportedAttachment):
+			status = http.StatusUnsupportedMediaType
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": attachment,
+	})
+}
+
+// GetConversation retrieves messages between current user and another user
+// @Summary Get conversation
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Description Pages go from newest to oldest: pass the returned next_cursor as before to load older messages.
+// @Description next_cursor is null once the start of the conversation is reached.
+// @Param user_id path string true "User ID"
+// @Param before query string false "Only messages older than this message ID or RFC3339 time"
+// @Param limit query int false "Limit" default(50)
+// @Param offset query int false "Deprecated: offset pagination skips or repeats messages that arrive while scrolling; use before"
+// @Success 200 {array} models.MessageResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/messages/conversation/{user_id} [get]
+func (ctrl *MessageController) GetConversation(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	otherUserIDStr := c.Param("user_id")
+	otherUserID, err := uuid.Parse(otherUserIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid user id",
+		})
+		return
+	}
+
+	messages, nextCursor, status, err := ctrl.conversationPage(c, userID, otherUserID)
+	if err != nil {
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":        messages,
+		"next_cursor": nextCursor,
+	})
+}
+
+// conversationPage loads the page of a conversation asked for by the query string: cursor-based with
+// before, or the deprecated offset pagination when offset is given. On failure it returns the HTTP status to use.
+func (ctrl *MessageController) conversationPage(c *gin.Context, userID, otherUserID uuid.UUID) ([]models.MessageResponse, *uuid.UUID, int, error) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+
+	if rawOffset, ok := c.GetQuery("offset"); ok {
+		c.Header("Deprecation", "true")
+		offset, _ := strconv.Atoi(rawOffset)
+		messages, err := ctrl.readService(c).GetConversation(userID, otherUserID, limit, offset)
+		if err != nil {
+			return nil, nil, http.StatusInternalServerError, err
+		}
+		return messages, nil, http.StatusOK, nil
+	}
+
+	var cursor services.ConversationCursor
+	if before := c.Query("before"); before != "" {
+		if id, err := uuid.Parse(before); err == nil {
+			cursor.BeforeID = id
+		} else if t, err := time.Parse(time.RFC3339, before); err == nil {
+			cursor.BeforeTime = &t
+		} else {
+			return nil, nil, http.StatusBadRequest, errors.New("before must be a message ID or an RFC3339 timestamp")
+		}
+	}
+
+	messages, nextCursor, err := ctrl.readService(c).GetConversationBefore(userID, otherUserID, cursor, limit)
+	if err != nil {
+		if err.Error() == "message not found" {
+			return nil, nil, http.StatusBadRequest, errors.New("before refers to an unknown message")
+		}
+		return nil, nil, http.StatusInternalServerError, err
+	}
+	return messages, nextCursor, http.StatusOK, nil
+}
+
+// SearchConversation searches messages exchanged with another user
+// @Summary Search conversation messages
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Param user_id path string true "User ID"
+// @Param q query string true "Search query"
+// @Param after query string false "Only messages created after this RFC3339 time"
+// @Param before query string false "Only messages created before this RFC3339 time"
+// @Success 200 {array} models.MessageResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/messages/conversation/{user_id}/search [get]
+func (ctrl *MessageController) SearchConversation(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	otherUserID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid user id",
+		})
+		return
+	}
+
+	opts, err := parseSearchOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	messages, err := ctrl.messageService.SearchConversation(userID, otherUserID, opts)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": messages,
+	})
+}
+
+// ExportConversation downloads every message of a conversation as JSON or CSV
+// @Summary Export a conversation
+// @Description Limited to 5 exports per hour per user by default.
+// @Tags messages
+// @Produce json
+// @Produce text/csv
+// @Security BearerAuth
+// @Param user_id path string true "Other User ID"
+// @Param format query string false "json or csv" default(json)
+// @Success 200 {array} services.ConversationMessageExport
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/messages/conversation/{user_id}/export [get]
+func (ctrl *MessageController) ExportConversation(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	otherUserID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid user id",
+		})
+		return
+	}
+
+	format := c.DefaultQuery("format", "json")
+
+	data, contentType, err := ctrl.messageService.ExportConversation(userID, otherUserID, format)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidExportFormat) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	filename := "conversation-" + time.Now().UTC().Format("2006-01-02") + "." + format
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// parseSearchOptions reads the q, before and after query parameters of a message search
+func parseSearchOptions(c *gin.Context) (services.SearchOptions, error) {
+	opts := services.SearchOptions{Query: c.Query("q")}
+
+	var err error
+	if opts.Before, err = parseTimeQuery(c, "before"); err != nil {
+		return opts, err
+	}
+	if opts.After, err = parseTimeQuery(c, "after"); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// parseTimeQuery parses an optional RFC3339 query parameter
+func parseTimeQuery(c *gin.Context, name string) (*time.Time, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an RFC3339 timestamp", name)
+	}
+	return &parsed, nil
+}
+
+// MarkAsRead marks messages in a conversation as read
+// @Summary Mark messages as read
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Param user_id path string true "Sender User ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/messages/read/{user_id} [put]
+func (ctrl *MessageController) MarkAsRead(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	senderIDStr := c.Param("user_id")
+	senderID, err := uuid.Parse(senderIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid user id",
+		})
+		return
+	}
+
+	if err := ctrl.messageService.MarkAsRead(userID, senderID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "marked as read",
+	})
+}
+
+// MarkAllAsRead marks every message the current user received as read
+// @Summary Mark all conversations as read
+// @Description Each sender whose messages were unread gets one all_conversations_read WebSocket event
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/messages/read-all [put]
+func (ctrl *MessageController) MarkAllAsRead(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	if err := ctrl.messageService.MarkAllConversationsAsRead(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "all marked as read",
+	})
+}
+
+// MarkAsDelivered acknowledges delivery of messages from another user
+// @Summary Mark messages as delivered
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Param user_id path string true "Sender User ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/messages/delivered/{user_id} [put]
+func (ctrl *MessageController) MarkAsDelivered(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	senderID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid user id",
+		})
+		return
+	}
+
+	if err := ctrl.messageService.MarkAsDelivered(userID, senderID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "marked as delivered",
+	})
+}
+
+// GetUnreadCount gets unread message count
+// @Summary Get unread message count
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]int64
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/messages/unread/count [get]
+func (ctrl *MessageController) GetUnreadCount(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	count, err := ctrl.messageService.GetUnreadCount(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"count": count,
+	})
+}
+
+// GetUnreadTotal gets unread counts across all conversations and groups
+// @Summary Get total unread count
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} services.UnreadCounts
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/messages/unread/total [get]
+func (ctrl *MessageController) GetUnreadTotal(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	counts, err := ctrl.messageService.GetUnreadTotals(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, counts)
+}
+
+// GetRecentConversations gets recent conversations
+// @Summary Get recent conversations
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Param limit query int false "Limit" default(20)
+// @Param label query string false "Only conversations with this label"
+// @Param include_archived query bool false "Include archived conversations" default(false)
+// @Success 200 {array} models.PublicUser
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/messages/conversations [get]
+func (ctrl *MessageController) GetRecentConversations(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	includeArchived, _ := strconv.ParseBool(c.DefaultQuery("include_archived", "false"))
+
+	var users []models.ConversationSummary
+	var err error
+	if label := c.Query("label"); label != "" {
+		users, err = ctrl.readService(c).GetLabeledConversations(userID, label, limit)
+	} else {
+		users, err = ctrl.readService(c).GetRecentConversations(c.Request.Context(), userID, limit, includeArchived)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	unread, err := ctrl.messageService.GetUnreadTotals(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":         users,
+		"total_unread": unread.Total,
+	})
+}
+
+// GetArchivedConversations gets the conversations the current user archived
+// @Summary Get archived conversations
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Param limit query int false "Limit" default(20)
+// @Success 200 {array} models.ConversationSummary
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/messages/conversations/archived [get]
+func (ctrl *MessageController) GetArchivedConversations(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	conversations, err := ctrl.readService(c).GetArchivedConversations(userID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": conversations,
+	})
+}
+
+// SearchConversations searches recent conversations by partner username or email
+// @Summary Search conversations
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Param q query string true "Partner name or email"
+// @Param limit query int false "Limit" default(20)
+// @Success 200 {array} models.ConversationSummary
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/messages/conversations/search [get]
+func (ctrl *MessageController) SearchConversations(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	query := c.Query("q")
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "search query required",
+		})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	conversations, err := ctrl.messageService.SearchConversations(userID, query, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": conversations,
+	})
+}
+
+// SearchMessages runs a full-text search over the current user's direct messages
+// @Summary Search messages
+// @Description Matches whole words in the messages the user sent or received, newest first. Each hit includes the conversation partner.
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Param q query string true "Words to search for"
+// @Param limit query int false "Limit (max 100)" default(20)
+// @Param offset query int false "Offset" default(0)
+// @Success 200 {array} models.MessageSearchResult
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/messages/search [get]
+func (ctrl *MessageController) SearchMessages(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	query := c.Query("q")
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "search query required",
+		})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	result, err := ctrl.messageService.SearchMessages(userID, query, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": result.Results,
+		"meta": utils.NewMeta(result.Total, result.Limit, result.Offset),
+	})
+}
+
+// EditMessage updates a message content
+// @Summary Edit a message
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param message_id path string true "Message ID"
+// @Param request body services.EditMessageRequest true "Edit Request"
+// @Success 200 {object} models.MessageResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /v1/messages/{message_id} [put]
+func (ctrl *MessageController) EditMessage(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	messageID, err := uuid.Parse(c.Param("message_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid message id",
+		})
+		return
+	}
+
+	var req services.EditMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	message, err := ctrl.messageService.EditMessage(messageID, userID, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "message updated",
+		"data":    message,
+	})
+}
+
+// DeleteMessage marks a message as deleted
+// @Summary Delete a message
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Param message_id path string true "Message ID"
+// @Success 200 {object} models.MessageResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /v1/messages/{message_id} [delete]
+func (ctrl *MessageController) DeleteMessage(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	messageID, err := uuid.Parse(c.Param("message_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid message id",
+		})
+		return
+	}
+
+	message, err := ctrl.messageService.DeleteMessage(messageID, userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "message deleted",
+		"data":    message,
+	})
+}
+
+// SaveDraft stores the unsent draft for a conversation
+// @Summary Save conversation draft
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param user_id path string true "Partner User ID"
+// @Param request body services.SaveDraftRequest true "Draft Request"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /v1/messages/conversation/{user_id}/draft [put]
+func (ctrl *MessageController) SaveDraft(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{
@@ -40,7 +1028,15 @@ func (ctrl *MessageController) SendMessage(c *gin.Context) {
 		return
 	}
 
-	var req services.SendMessageRequest
+	partnerID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid user id",
+		})
+		return
+	}
+
+	var req services.SaveDraftRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": err.Error(),
@@ -48,31 +1044,73 @@ func (ctrl *MessageController) SendMessage(c *gin.Context) {
 		return
 	}
 
-	message, err := ctrl.messageService.SendMessage(userID, req)
+	if err := ctrl.messageService.SaveDraft(userID, partnerID, req.Content); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "draft saved",
+	})
+}
+
+// GetDraft returns the unsent draft for a conversation
+// @Summary Get conversation draft
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Param user_id path string true "Partner User ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/messages/conversation/{user_id}/draft [get]
+func (ctrl *MessageController) GetDraft(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	partnerID, err := uuid.Parse(c.Param("user_id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid user id",
+		})
+		return
+	}
+
+	content, err := ctrl.messageService.GetDraft(userID, partnerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "message sent successfully",
-		"data":    message,
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"content": content,
+		},
 	})
 }
 
-// GetConversation retrieves messages between current user and another user
-// @Summary Get conversation
+// DeleteDraft discards the unsent draft for a conversation
+// @Summary Delete conversation draft
 // @Tags messages
 // @Produce json
 // @Security BearerAuth
-// @Param user_id path string true "User ID"
-// @Param limit query int false "Limit" default(50)
-// @Param offset query int false "Offset" default(0)
-// @Success 200 {array} models.MessageResponse
-// @Router /messages/conversation/{user_id} [get]
-func (ctrl *MessageController) GetConversation(c *gin.Context) {
+// @Param user_id path string true "Partner User ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/messages/conversation/{user_id}/draft [delete]
+func (ctrl *MessageController) DeleteDraft(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{
@@ -81,8 +1119,7 @@ func (ctrl *MessageController) GetConversation(c *gin.Context) {
 		return
 	}
 
-	otherUserIDStr := c.Param("user_id")
-	otherUserID, err := uuid.Parse(otherUserIDStr)
+	partnerID, err := uuid.Parse(c.Param("user_id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "invalid user id",
@@ -90,11 +1127,103 @@ func (ctrl *MessageController) GetConversation(c *gin.Context) {
 		return
 	}
 
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
-	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err := ctrl.messageService.DeleteDraft(userID, partnerID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "draft deleted",
+	})
+}
+
+// MuteConversation mutes notifications of a conversation for the current user
+// @Summary Mute a conversation
+// @Description Messages still arrive, but no notification or push is sent for them
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param user_id path string true "Partner User ID"
+// @Param request body services.MuteConversationRequest false "Mute end time (omit or null for indefinitely)"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /v1/messages/conversation/{user_id}/mute [post]
+func (ctrl *MessageController) MuteConversation(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	partnerID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid user id",
+		})
+		return
+	}
+
+	// An empty body mutes indefinitely
+	var req services.MuteConversationRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := ctrl.messageService.MuteConversation(userID, partnerID, req.Until); err != nil {
+		status := http.StatusBadRequest
+		if err.Error() == "user not found" {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "conversation muted",
+	})
+}
+
+// UnmuteConversation restores notifications of a conversation for the current user
+// @Summary Unmute a conversation
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Param user_id path string true "Partner User ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/messages/conversation/{user_id}/mute [delete]
+func (ctrl *MessageController) UnmuteConversation(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
 
-	messages, err := ctrl.messageService.GetConversation(userID, otherUserID, limit, offset)
+	partnerID, err := uuid.Parse(c.Param("user_id"))
 	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid user id",
+		})
+		return
+	}
+
+	if err := ctrl.messageService.UnmuteConversation(userID, partnerID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": err.Error(),
 		})
@@ -102,19 +1231,23 @@ func (ctrl *MessageController) GetConversation(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"data": messages,
+		"message": "conversation unmuted",
 	})
 }
 
-// MarkAsRead marks messages in a conversation as read
-// @Summary Mark messages as read
+// ArchiveConversation hides a conversation from the current user's recent conversations
+// @Summary Archive a conversation
+// @Description The messages are kept; the conversation is listed under /messages/conversations/archived
 // @Tags messages
 // @Produce json
 // @Security BearerAuth
-// @Param user_id path string true "Sender User ID"
+// @Param user_id path string true "Partner User ID"
 // @Success 200 {object} map[string]string
-// @Router /messages/read/{user_id} [put]
-func (ctrl *MessageController) MarkAsRead(c *gin.Context) {
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /v1/messages/conversation/{user_id}/archive [post]
+func (ctrl *MessageController) ArchiveConversation(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{
@@ -123,8 +1256,7 @@ func (ctrl *MessageController) MarkAsRead(c *gin.Context) {
 		return
 	}
 
-	senderIDStr := c.Param("user_id")
-	senderID, err := uuid.Parse(senderIDStr)
+	partnerID, err := uuid.Parse(c.Param("user_id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "invalid user id",
@@ -132,7 +1264,51 @@ func (ctrl *MessageController) MarkAsRead(c *gin.Context) {
 		return
 	}
 
-	if err := ctrl.messageService.MarkAsRead(userID, senderID); err != nil {
+	if err := ctrl.messageService.ArchiveConversation(userID, partnerID); err != nil {
+		status := http.StatusBadRequest
+		if err.Error() == "user not found" {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "conversation archived",
+	})
+}
+
+// UnarchiveConversation restores a conversation to the current user's recent conversations
+// @Summary Unarchive a conversation
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Param user_id path string true "Partner User ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/messages/conversation/{user_id}/archive [delete]
+func (ctrl *MessageController) UnarchiveConversation(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	partnerID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid user id",
+		})
+		return
+	}
+
+	if err := ctrl.messageService.UnarchiveConversation(userID, partnerID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": err.Error(),
 		})
@@ -140,18 +1316,24 @@ func (ctrl *MessageController) MarkAsRead(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "marked as read",
+		"message": "conversation unarchived",
 	})
 }
 
-// GetUnreadCount gets unread message count
-// @Summary Get unread message count
+// AddLabel puts a label on a conversation
+// @Summary Add conversation label
+// @Description Re-adding an existing label updates its color. A conversation has at most 5 labels.
 // @Tags messages
+// @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Success 200 {object} map[string]int64
-// @Router /messages/unread/count [get]
-func (ctrl *MessageController) GetUnreadCount(c *gin.Context) {
+// @Param user_id path string true "Partner User ID"
+// @Param request body services.AddLabelRequest true "Label (max 20 characters) and hex color"
+// @Success 200 {array} models.Label
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /v1/messages/conversation/{user_id}/labels [post]
+func (ctrl *MessageController) AddLabel(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{
@@ -160,7 +1342,30 @@ func (ctrl *MessageController) GetUnreadCount(c *gin.Context) {
 		return
 	}
 
-	count, err := ctrl.messageService.GetUnreadCount(userID)
+	partnerID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid user id",
+		})
+		return
+	}
+
+	var req services.AddLabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := ctrl.messageService.AddLabel(userID, partnerID, req.Label, req.Color); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	labels, err := ctrl.messageService.GetLabels(userID, partnerID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": err.Error(),
@@ -169,19 +1374,24 @@ func (ctrl *MessageController) GetUnreadCount(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"count": count,
+		"message": "label added",
+		"data":    labels,
 	})
 }
 
-// GetRecentConversations gets recent conversations
-// @Summary Get recent conversations
+// RemoveLabel takes a label off a conversation
+// @Summary Remove conversation label
 // @Tags messages
 // @Produce json
 // @Security BearerAuth
-// @Param limit query int false "Limit" default(20)
-// @Success 200 {array} models.PublicUser
-// @Router /messages/conversations [get]
-func (ctrl *MessageController) GetRecentConversations(c *gin.Context) {
+// @Param user_id path string true "Partner User ID"
+// @Param label path string true "Label"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /v1/messages/conversation/{user_id}/labels/{label} [delete]
+func (ctrl *MessageController) RemoveLabel(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{
@@ -190,9 +1400,49 @@ func (ctrl *MessageController) GetRecentConversations(c *gin.Context) {
 		return
 	}
 
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	partnerID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid user id",
+		})
+		return
+	}
+
+	if err := ctrl.messageService.RemoveLabel(userID, partnerID, c.Param("label")); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrLabelNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "label removed",
+	})
+}
+
+// GetLabels returns every distinct label the current user has created
+// @Summary List my conversation labels
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.Label
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/messages/labels [get]
+func (ctrl *MessageController) GetLabels(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
 
-	users, err := ctrl.messageService.GetRecentConversations(userID, limit)
+	labels, err := ctrl.readService(c).GetAllLabels(userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": err.Error(),
@@ -201,12 +1451,24 @@ func (ctrl *MessageController) GetRecentConversations(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"data": users,
+		"data": labels,
 	})
 }
 
-// EditMessage updates a message content
-func (ctrl *MessageController) EditMessage(c *gin.Context) {
+// AddReaction reacts to a direct message with an emoji
+// @Summary React to a message
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param message_id path string true "Message ID"
+// @Param request body services.AddReactionRequest true "Reaction Request"
+// @Success 200 {object} map[string]int
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /v1/messages/{message_id}/reactions [post]
+func (ctrl *MessageController) AddReaction(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{
@@ -223,7 +1485,7 @@ func (ctrl *MessageController) EditMessage(c *gin.Context) {
 		return
 	}
 
-	var req services.EditMessageRequest
+	var req services.AddReactionRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": err.Error(),
@@ -231,22 +1493,34 @@ func (ctrl *MessageController) EditMessage(c *gin.Context) {
 		return
 	}
 
-	message, err := ctrl.messageService.EditMessage(messageID, userID, req)
+	reactions, err := ctrl.messageService.AddReaction(messageID, userID, req.Emoji)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
+		c.JSON(reactionErrorStatus(err), gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "message updated",
-		"data":    message,
+		"message": "reaction added",
+		"data":    reactions,
 	})
 }
 
-// DeleteMessage marks a message as deleted
-func (ctrl *MessageController) DeleteMessage(c *gin.Context) {
+// RemoveReaction withdraws the current user's emoji reaction from a direct message
+// @Summary Remove a reaction
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Param message_id path string true "Message ID"
+// @Param emoji path string true "Emoji (URL-encoded)"
+// @Success 200 {object} map[string]int
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /v1/messages/{message_id}/reactions/{emoji} [delete]
+func (ctrl *MessageController) RemoveReaction(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{
@@ -263,16 +1537,27 @@ func (ctrl *MessageController) DeleteMessage(c *gin.Context) {
 		return
 	}
 
-	message, err := ctrl.messageService.DeleteMessage(messageID, userID)
+	reactions, err := ctrl.messageService.RemoveReaction(messageID, userID, c.Param("emoji"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
+		c.JSON(reactionErrorStatus(err), gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "message deleted",
-		"data":    message,
+		"message": "reaction removed",
+		"data":    reactions,
 	})
 }
+
+// reactionErrorStatus maps reaction errors to their HTTP status
+func reactionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrNotConversationMember):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrReactionNotFound), err.Error() == "message not found":
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,383 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"mms-backend/middleware"
+	"mms-backend/services"
+	"mms-backend/utils"
+)
+
+// NotificationController handles notification endpoints
+type NotificationController struct {
+	notificationService *services.NotificationService
+}
+
+// NewNotificationController creates a new notification controller
+func NewNotificationController(notificationService *services.NotificationService) *NotificationController {
+	return &NotificationController{
+		notificationService: notificationService,
+	}
+}
+
+// GetNotifications lists the current user's notifications, newest first
+// @Summary List notifications
+// @Tags notifications
+// @Produce json
+// @Security BearerAuth
+// @Param limit query int false "Limit" default(20)
+// @Param offset query int false "Offset" default(0)
+// @Success 200 {array} models.Notification
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/notifications [get]
+func (ctrl *NotificationController) GetNotifications(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	notifications, err := ctrl.notificationService.GetUserNotifications(userID, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	total, err := ctrl.notificationService.CountUserNotifications(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": notifications,
+		"meta": utils.NewMeta(total, limit, offset),
+	})
+}
+
+// GetUnreadNotifications lists the current user's unread notifications, newest first
+// @Summary List unread notifications
+// @Tags notifications
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.Notification
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/notifications/unread [get]
+func (ctrl *NotificationController) GetUnreadNotifications(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	notifications, err := ctrl.notificationService.GetUnreadNotifications(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": notifications,
+	})
+}
+
+// GetUnreadCount returns how many of the current user's notifications are unread
+// @Summary Count unread notifications
+// @Tags notifications
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]int
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/notifications/unread/count [get]
+func (ctrl *NotificationController) GetUnreadCount(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	count, err := ctrl.notificationService.GetUnreadCount(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"unread_count": count,
+	})
+}
+
+// MarkAsRead marks one of the current user's notifications as read
+// @Summary Mark a notification as read
+// @Tags notifications
+// @Produce json
+// @Security BearerAuth
+// @Param notification_id path string true "Notification ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/notifications/{notification_id}/read [put]
+func (ctrl *NotificationController) MarkAsRead(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	notificationID, err := uuid.Parse(c.Param("notification_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid notification id",
+		})
+		return
+	}
+
+	if err := ctrl.notificationService.MarkAsRead(userID, notificationID); err != nil {
+		c.JSON(notificationErrorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "notification marked as read",
+	})
+}
+
+// DeleteNotification deletes one of the current user's notifications
+// @Summary Delete a notification
+// @Tags notifications
+// @Produce json
+// @Security BearerAuth
+// @Param notification_id path string true "Notification ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/notifications/{notification_id} [delete]
+func (ctrl *NotificationController) DeleteNotification(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	notificationID, err := uuid.Parse(c.Param("notification_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid notification id",
+		})
+		return
+	}
+
+	if err := ctrl.notificationService.DeleteNotification(userID, notificationID); err != nil {
+		c.JSON(notificationErrorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "notification deleted",
+	})
+}
+
+// notificationErrorStatus maps a notification service error to its HTTP status
+func notificationErrorStatus(err error) int {
+	if errors.Is(err, services.ErrNotificationNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// MarkAllAsRead marks every notification of the current user as read
+// @Summary Mark all notifications as read
+// @Tags notifications
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/notifications/read-all [put]
+func (ctrl *NotificationController) MarkAllAsRead(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	if err := ctrl.notificationService.MarkAllAsRead(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "all notifications marked as read",
+	})
+}
+
+// BatchMarkAsRead marks selected notifications of the current user as read
+// @Summary Mark selected notifications as read
+// @Tags notifications
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body services.BatchReadRequest true "Notification IDs (max 100)"
+// @Success 200 {object} map[string]int
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/notifications/batch-read [post]
+func (ctrl *NotificationController) BatchMarkAsRead(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	var req services.BatchReadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	marked, err := ctrl.notificationService.MarkBatchAsRead(userID, req.IDs)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrBatchReadSize) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"marked": marked,
+	})
+}
+
+// GetPreferences lists the current user's push notification preferences, one per notification type
+// @Summary Get notification preferences
+// @Tags notifications
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.NotificationPreference
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/notifications/preferences [get]
+func (ctrl *NotificationController) GetPreferences(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	preferences, err := ctrl.notificationService.GetPreferences(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": preferences,
+	})
+}
+
+// UpdatePreference changes whether the current user gets push notifications of a type
+// @Summary Update a notification preference
+// @Tags notifications
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body services.UpdatePreferenceRequest true "Preference for one notification type"
+// @Success 200 {array} models.NotificationPreference
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/notifications/preferences [put]
+func (ctrl *NotificationController) UpdatePreference(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	var req services.UpdatePreferenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := ctrl.notificationService.UpdatePreference(userID, req.Type, *req.Enabled, *req.SoundEnabled); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrUnknownNotificationType) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	preferences, err := ctrl.notificationService.GetPreferences(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "notification preference updated",
+		"data":    preferences,
+	})
+}
@@ -1,23 +1,35 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"mms-backend/repositories"
+	"mms-backend/middleware"
+	"mms-backend/models"
+	"mms-backend/services"
+	"mms-backend/utils"
 )
 
+// maxPhoneLookup caps the number of phone numbers in one contact lookup
+const maxPhoneLookup = 50
+
+// LookupByPhoneRequest represents a contact list lookup request
+type LookupByPhoneRequest struct {
+	Phones []string `json:"phones" binding:"required"`
+}
+
 // UserController handles user endpoints
 type UserController struct {
-	userRepo *repositories.UserRepository
+	userService *services.UserService
 }
 
 // NewUserController creates a new user controller
-func NewUserController(userRepo *repositories.UserRepository) *UserController {
+func NewUserController(userService *services.UserService) *UserController {
 	return &UserController{
-		userRepo: userRepo,
+		userService: userService,
 	}
 }
 
@@ -28,7 +40,9 @@ func NewUserController(userRepo *repositories.UserRepository) *UserController {
 // @Security BearerAuth
 // @Param user_id path string true "User ID"
 // @Success 200 {object} models.PublicUser
-// @Router /users/{user_id} [get]
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /v1/users/{user_id} [get]
 func (ctrl *UserController) GetUser(c *gin.Context) {
 	userIDStr := c.Param("user_id")
 	userID, err := uuid.Parse(userIDStr)
@@ -39,7 +53,7 @@ func (ctrl *UserController) GetUser(c *gin.Context) {
 		return
 	}
 
-	user, err := ctrl.userRepo.FindByID(userID)
+	user, err := ctrl.userService.GetUser(userID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": "user not found",
@@ -52,16 +66,74 @@ func (ctrl *UserController) GetUser(c *gin.Context) {
 	})
 }
 
-// SearchUsers searches for users by username or email
+// LookupByPhone finds which phone numbers of a contact list belong to users
+// @Summary Look up users by phone number
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body LookupByPhoneRequest true "Phone numbers (max 50)"
+// @Success 200 {object} map[string]models.PublicUser
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/users/lookup-by-phone [post]
+func (ctrl *UserController) LookupByPhone(c *gin.Context) {
+	var req LookupByPhoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if len(req.Phones) == 0 || len(req.Phones) > maxPhoneLookup {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "phones must contain between 1 and 50 numbers",
+		})
+		return
+	}
+
+	users, err := ctrl.userService.LookupByPhone(req.Phones)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// Keyed by the number as the client sent it so it can match its contacts
+	found := make(map[string]models.PublicUser, len(users))
+	for phone, user := range users {
+		found[phone] = user.ToPublicUser()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": found,
+	})
+}
+
+// SearchUsers searches for users by username, email, bio or phone number
 // @Summary Search users
+// @Description Users who blocked the caller are not returned
 // @Tags users
 // @Produce json
 // @Security BearerAuth
 // @Param q query string true "Search query"
 // @Param limit query int false "Limit" default(10)
+// @Param offset query int false "Offset" default(0)
 // @Success 200 {array} models.PublicUser
-// @Router /users/search [get]
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/users/search [get]
 func (ctrl *UserController) SearchUsers(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
 	query := c.Query("q")
 	if query == "" {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -71,8 +143,9 @@ func (ctrl *UserController) SearchUsers(c *gin.Context) {
 	}
 
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
 
-	users, err := ctrl.userRepo.Search(query, limit)
+	users, total, err := ctrl.userService.SearchUsers(userID, query, limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": err.Error(),
@@ -88,23 +161,34 @@ func (ctrl *UserController) SearchUsers(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"data": publicUsers,
+		"meta": utils.NewMeta(total, limit, offset),
 	})
 }
 
 // ListUsers lists all users with pagination
 // @Summary List users
+// @Description Users who blocked the caller are not returned
 // @Tags users
 // @Produce json
 // @Security BearerAuth
 // @Param limit query int false "Limit" default(20)
 // @Param offset query int false "Offset" default(0)
 // @Success 200 {array} models.PublicUser
-// @Router /users [get]
+// @Failure 500 {object} map[string]string
+// @Router /v1/users [get]
 func (ctrl *UserController) ListUsers(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
 	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
 
-	users, err := ctrl.userRepo.List(limit, offset)
+	users, total, err := ctrl.userService.ListUsers(userID, limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": err.Error(),
@@ -120,6 +204,312 @@ func (ctrl *UserController) ListUsers(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"data": publicUsers,
+		"meta": utils.NewMeta(total, limit, offset),
+	})
+}
+
+
+// UpdateProfile updates the current user's username, avatar, language or bio
+// @Summary Update own profile
+// @Description Only the fields present in the body are changed. The username can be changed once every 30 days.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body services.UpdateProfileRequest true "Profile fields"
+// @Success 200 {object} models.PublicUser
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/users/me [patch]
+func (ctrl *UserController) UpdateProfile(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	var req services.UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	user, err := ctrl.userService.UpdateProfile(userID, req)
+	if err != nil {
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, services.ErrUsernameTaken):
+			status = http.StatusConflict
+		case errors.Is(err, services.ErrUsernameChangeCooldown):
+			status = http.StatusTooManyRequests
+		case err.Error() == "user not found":
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": user.ToPublicUser(),
+	})
+}
+
+// UpdateDND updates the current user's do-not-disturb schedule
+// @Summary Update do-not-disturb schedule
+// @Description Push notifications are not sent from start_hour up to end_hour in the given time zone. Only the fields present in the body are changed.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body services.UpdateDNDRequest true "Schedule fields"
+// @Success 200 {object} services.DNDSettings
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/users/me/dnd [patch]
+func (ctrl *UserController) UpdateDND(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	var req services.UpdateDNDRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	settings, err := ctrl.userService.UpdateDND(userID, req)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrInvalidDNDHour), errors.Is(err, services.ErrInvalidTimezone):
+			status = http.StatusBadRequest
+		case err.Error() == "user not found":
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": settings,
 	})
 }
 
+// DeleteAccount deletes the current user's account
+// @Summary Delete account
+// @Description The account can no longer log in or be found, and its messages show "[deleted user]" as the sender. Everything is permanently removed 30 days later.
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/users/me [delete]
+func (ctrl *UserController) DeleteAccount(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	if err := ctrl.userService.DeleteAccount(userID); err != nil {
+		status := http.StatusInternalServerError
+		if err.Error() == "user not found" {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "account deleted",
+	})
+}
+
+// UploadAvatar replaces the current user's avatar with an uploaded image
+// @Summary Upload an avatar
+// @Description Accepts JPEG, PNG or WebP images up to 5 MB, stored cropped to 256x256 with a 64x64 thumbnail
+// @Tags users
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param file formData file true "Avatar image"
+// @Success 200 {object} services.AvatarResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Failure 415 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/users/me/avatar [post]
+func (ctrl *UserController) UploadAvatar(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	// Leave room for the multipart framing around the file itself
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, services.MaxAvatarSize+1<<20)
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error": services.ErrAvatarTooLarge.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "file is required",
+		})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	defer file.Close()
+
+	avatar, err := ctrl.userService.UploadAvatar(userID, file, header.Size)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrAvatarTooLarge):
+			status = http.StatusRequestEntityTooLarge
+		case errors.Is(err, services.ErrUnsupportedAvatar):
+			status = http.StatusUnsupportedMediaType
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": avatar,
+	})
+}
+
+// BlockUser blocks a user, preventing direct messages in both directions
+// @Summary Block a user
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Param user_id path string true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/users/{user_id}/block [post]
+func (ctrl *UserController) BlockUser(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	blockedID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid user id",
+		})
+		return
+	}
+
+	if err := ctrl.userService.BlockUser(userID, blockedID); err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrCannotBlockSelf):
+			status = http.StatusBadRequest
+		case err.Error() == "user not found":
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "user blocked",
+	})
+}
+
+// UnblockUser lifts a block on a user
+// @Summary Unblock a user
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Param user_id path string true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/users/{user_id}/block [delete]
+func (ctrl *UserController) UnblockUser(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	blockedID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid user id",
+		})
+		return
+	}
+
+	if err := ctrl.userService.UnblockUser(userID, blockedID); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrBlockNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "user unblocked",
+	})
+}
@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"mms-backend/middleware"
+	"mms-backend/utils"
+)
+
+// V2GroupController serves API v2 group endpoints, reusing v1 handlers where the shape is unchanged
+type V2GroupController struct {
+	*GroupController
+}
+
+// NewV2GroupController creates a new v2 group controller on top of the v1 one
+func NewV2GroupController(groupController *GroupController) *V2GroupController {
+	return &V2GroupController{
+		GroupController: groupController,
+	}
+}
+
+// GetGroup gets a group by ID with its member count
+// @Summary Get a group (v2)
+// @Tags groups
+// @Produce json
+// @Security BearerAuth
+// @Param group_id path string true "Group ID"
+// @Success 200 {object} models.GroupWithCount
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /v2/groups/{group_id} [get]
+func (ctrl *V2GroupController) GetGroup(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	groupIDStr := c.Param("group_id")
+	groupID, err := uuid.Parse(groupIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid group id",
+		})
+		return
+	}
+
+	group, err := ctrl.groupService.GetGroupWithCount(groupID, userID)
+	if err != nil {
+		c.JSON(groupErrorStatus(err, http.StatusNotFound), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": group,
+	})
+}
+
+// GetUserGroups gets all groups for the current user with their member counts
+// @Summary Get user groups (v2)
+// @Tags groups
+// @Produce json
+// @Security BearerAuth
+// @Param include_archived query bool false "Include archived groups" default(false)
+// @Param limit query int false "Limit; all groups when omitted" default(0)
+// @Param offset query int false "Offset" default(0)
+// @Success 200 {array} models.GroupWithCount
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v2/groups/my [get]
+func (ctrl *V2GroupController) GetUserGroups(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	includeArchived, _ := strconv.ParseBool(c.DefaultQuery("include_archived", "false"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	groups, err := ctrl.groupService.GetUserGroupsWithCount(userID, includeArchived, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	total, err := ctrl.groupService.CountUserGroups(userID, includeArchived)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": groups,
+		"meta": utils.NewMeta(total, limit, offset),
+	})
+}
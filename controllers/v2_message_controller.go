@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"mms-backend/middleware"
+	"mms-backend/models"
+)
+
+// V2MessageController serves API v2 message endpoints, reusing v1 handlers where the shape is unchanged
+type V2MessageController struct {
+	*MessageController
+}
+
+// NewV2MessageController creates a new v2 message controller on top of the v1 one
+func NewV2MessageController(messageController *MessageController) *V2MessageController {
+	return &V2MessageController{
+		MessageController: messageController,
+	}
+}
+
+// GetConversation retrieves messages between current user and another user in the v2 shape
+// @Summary Get conversation (v2)
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Description Pages go from newest to oldest: pass the returned next_cursor as before to load older messages.
+// @Description next_cursor is null once the start of the conversation is reached.
+// @Param user_id path string true "User ID"
+// @Param before query string false "Only messages older than this message ID or RFC3339 time"
+// @Param limit query int false "Limit" default(50)
+// @Param offset query int false "Deprecated: offset pagination skips or repeats messages that arrive while scrolling; use before"
+// @Success 200 {array} models.MessageResponseV2
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v2/messages/conversation/{user_id} [get]
+func (ctrl *V2MessageController) GetConversation(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	otherUserIDStr := c.Param("user_id")
+	otherUserID, err := uuid.Parse(otherUserIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid user id",
+		})
+		return
+	}
+
+	messages, nextCursor, status, err := ctrl.conversationPage(c, userID, otherUserID)
+	if err != nil {
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	response := make([]models.MessageResponseV2, 0, len(messages))
+	for _, message := range messages {
+		response = append(response, message.ToV2())
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":        response,
+		"next_cursor": nextCursor,
+	})
+}
+
+// SearchConversation searches messages exchanged with another user in the v2 shape
+// @Summary Search conversation messages (v2)
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Param user_id path string true "User ID"
+// @Param q query string true "Search query"
+// @Param after query string false "Only messages created after this RFC3339 time"
+// @Param before query string false "Only messages created before this RFC3339 time"
+// @Success 200 {array} models.MessageResponseV2
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /v2/messages/conversation/{user_id}/search [get]
+func (ctrl *V2MessageController) SearchConversation(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	otherUserID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid user id",
+		})
+		return
+	}
+
+	opts, err := parseSearchOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	messages, err := ctrl.messageService.SearchConversation(userID, otherUserID, opts)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	response := make([]models.MessageResponseV2, 0, len(messages))
+	for _, message := range messages {
+		response = append(response, message.ToV2())
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": response,
+	})
+}
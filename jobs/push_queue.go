@@ -0,0 +1,68 @@
+package jobs
+
+import (
+	"mms-backend/models"
+	"mms-backend/utils"
+)
+
+// PushQueueSize is how many push jobs may wait for a worker before new ones are dropped
+const PushQueueSize = 1000
+
+// PushJob is a push notification waiting to be sent
+type PushJob struct {
+	User   *models.User
+	Type   models.NotificationType // Which of the user's notification preferences applies
+	Title  string
+	Body   string
+	Data   map[string]interface{}
+	Silent bool // Data-only push that wakes the app without showing anything; Type, Title and Body are unused
+}
+
+// PushSender delivers push jobs, e.g. services.PushService
+type PushSender interface {
+	SendPush(job PushJob) error
+}
+
+// PushQueue hands push notifications to background workers so sending a message doesn't wait
+// on FCM or APNs
+type PushQueue struct {
+	jobs chan PushJob
+}
+
+// NewPushQueue creates a queue holding up to size jobs
+func NewPushQueue(size int) *PushQueue {
+	return &PushQueue{
+		jobs: make(chan PushJob, size),
+	}
+}
+
+// Enqueue queues job without blocking. When the queue is full the job is dropped with a
+// warning and false is returned: a missed push is better than a slow send.
+func (q *PushQueue) Enqueue(job PushJob) bool {
+	select {
+	case q.jobs <- job:
+		return true
+	default:
+		utils.Logger.Warn("Push queue full, dropping notification", "user_id", job.User.ID, "type", job.Type)
+		return false
+	}
+}
+
+// Start runs workers goroutines sending queued jobs with sender; values below one start one
+func (q *PushQueue) Start(workers int, sender PushSender) {
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		go q.work(sender)
+	}
+}
+
+// work sends queued jobs one at a time, forever
+func (q *PushQueue) work(sender PushSender) {
+	for job := range q.jobs {
+		if err := sender.SendPush(job); err != nil {
+			utils.Logger.Warn("Failed to send push notification", "user_id", job.User.ID, "type", job.Type, "error", err)
+		}
+	}
+}
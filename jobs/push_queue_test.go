@@ -0,0 +1,98 @@
+package jobs
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"mms-backend/models"
+
+	"github.com/google/uuid"
+)
+
+// fakeSender records the jobs it is given; release, when set, holds every send until closed
+type fakeSender struct {
+	mu      sync.Mutex
+	sent    []PushJob
+	release chan struct{}
+	done    chan struct{}
+}
+
+func (s *fakeSender) SendPush(job PushJob) error {
+	if s.release != nil {
+		<-s.release
+	}
+	s.mu.Lock()
+	s.sent = append(s.sent, job)
+	s.mu.Unlock()
+	s.done <- struct{}{}
+	return nil
+}
+
+func newJob(title string) PushJob {
+	return PushJob{User: &models.User{ID: uuid.New()}, Type: models.NotificationTypeMessage, Title: title}
+}
+
+func waitSent(t *testing.T, sender *fakeSender, count int) {
+	t.Helper()
+	for i := 0; i < count; i++ {
+		select {
+		case <-sender.done:
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for push %d of %d", i+1, count)
+		}
+	}
+}
+
+func TestPushQueueSendsJobs(t *testing.T) {
+	queue := NewPushQueue(10)
+	sender := &fakeSender{done: make(chan struct{}, 10)}
+	queue.Start(3, sender)
+
+	for _, title := range []string{"a", "b", "c", "d"} {
+		if !queue.Enqueue(newJob(title)) {
+			t.Fatalf("job %s was dropped", title)
+		}
+	}
+	waitSent(t, sender, 4)
+
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+	titles := map[string]bool{}
+	for _, job := range sender.sent {
+		titles[job.Title] = true
+	}
+	if len(titles) != 4 {
+		t.Fatalf("expected 4 distinct jobs, got %v", sender.sent)
+	}
+}
+
+func TestPushQueueDropsWhenFull(t *testing.T) {
+	queue := NewPushQueue(2)
+	sender := &fakeSender{release: make(chan struct{}), done: make(chan struct{}, 10)}
+	queue.Start(1, sender)
+
+	// The worker takes the first job and blocks on it, so two more fill the buffer
+	if !queue.Enqueue(newJob("busy")) {
+		t.Fatal("first job was dropped")
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(queue.jobs) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for the worker to take the first job")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	for _, title := range []string{"queued 1", "queued 2"} {
+		if !queue.Enqueue(newJob(title)) {
+			t.Fatalf("job %s was dropped", title)
+		}
+	}
+
+	if queue.Enqueue(newJob("overflow")) {
+		t.Fatal("expected the job to be dropped while the queue is full")
+	}
+
+	close(sender.release)
+	waitSent(t, sender, 3)
+}
@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// HTTPRequestsTotal counts handled HTTP requests by method, route and status
+	HTTPRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Total number of HTTP requests handled.",
+	}, []string{"method", "path", "status"})
+
+	// HTTPRequestDuration observes how long HTTP requests take by method and route
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Duration of HTTP requests in seconds.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "path"})
+
+	// WebSocketConnectedClients is the number of open WebSocket connections
+	WebSocketConnectedClients = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "websocket_connected_clients",
+		Help: "Number of open WebSocket connections.",
+	})
+
+	// MessagesSentTotal counts direct messages sent
+	MessagesSentTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "messages_sent_total",
+		Help: "Total number of direct messages sent.",
+	})
+)
+
+var initOnce sync.Once
+
+// Init registers the application metrics with the default Prometheus registry, which /metrics
+// serves. Metrics are recorded either way; calling Init again does nothing.
+func Init() {
+	initOnce.Do(func() {
+		prometheus.MustRegister(
+			HTTPRequestsTotal,
+			HTTPRequestDuration,
+			WebSocketConnectedClients,
+			MessagesSentTotal,
+		)
+	})
+}
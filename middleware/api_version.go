@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIVersionHeader is the response header carrying the API version that served the request
+const APIVersionHeader = "X-API-Version"
+
+// APIVersionMiddleware sets the X-API-Version header from the request path (v1 for unversioned routes)
+func APIVersionMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		version := "1"
+		if strings.HasPrefix(c.Request.URL.Path, "/api/v2/") || c.Request.URL.Path == "/api/v2" {
+			version = "2"
+		}
+		c.Writer.Header().Set(APIVersionHeader, version)
+
+		c.Next()
+	}
+}
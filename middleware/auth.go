@@ -52,6 +52,8 @@ func AuthMiddleware() gin.HandlerFunc {
 		c.Set("user_id", claims.UserID)
 		c.Set("username", claims.Username)
 		c.Set("email", claims.Email)
+		// ...and on the request context, so service logs name the user
+		c.Request = c.Request.WithContext(utils.WithUserID(c.Request.Context(), claims.UserID))
 
 		c.Next()
 	}
@@ -1,23 +1,54 @@
 package middleware
 
 import (
+	"net/http"
+	"strings"
+
 	"github.com/gin-gonic/gin"
+	"mms-backend/config"
+)
+
+const (
+	corsAllowHeaders  = "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Read-Your-Writes, X-Request-ID, Idempotency-Key"
+	corsExposeHeaders = "X-Read-Your-Writes, X-Request-ID"
+	corsAllowMethods  = "POST, OPTIONS, GET, PUT, DELETE, PATCH"
 )
 
-// CORSMiddleware handles CORS
-func CORSMiddleware() gin.HandlerFunc {
+// CORSMiddleware handles CORS. Requests from an origin in server.AllowedOrigins get it reflected
+// back in Access-Control-Allow-Origin; other origins get no such header, so browsers block them.
+// Outside production an empty list allows every origin ("*"). Preflight OPTIONS requests are
+// answered with 204.
+func CORSMiddleware(server config.ServerConfig) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(server.AllowedOrigins))
+	for _, origin := range server.AllowedOrigins {
+		allowed[strings.TrimRight(origin, "/")] = true
+	}
+	allowAll := allowed["*"] || (len(allowed) == 0 && server.Environment != "production")
+
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, PATCH")
+		header := c.Writer.Header()
+		origin := c.GetHeader("Origin")
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
+		switch {
+		case allowAll:
+			header.Set("Access-Control-Allow-Origin", "*")
+		case origin != "" && allowed[origin]:
+			header.Set("Access-Control-Allow-Origin", origin)
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+		// The response depends on the Origin header unless every origin gets the same answer
+		if !allowAll {
+			header.Add("Vary", "Origin")
+		}
+		header.Set("Access-Control-Allow-Headers", corsAllowHeaders)
+		header.Set("Access-Control-Expose-Headers", corsExposeHeaders)
+		header.Set("Access-Control-Allow-Methods", corsAllowMethods)
+
+		if c.Request.Method == http.MethodOptions {
+			c.AbortWithStatus(http.StatusNoContent)
 			return
 		}
 
 		c.Next()
 	}
 }
-
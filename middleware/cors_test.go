@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"mms-backend/config"
+)
+
+func serveCORS(server config.ServerConfig, method, origin string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(CORSMiddleware(server))
+	router.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(method, "/", nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCORSMiddlewareOrigins(t *testing.T) {
+	production := config.ServerConfig{
+		Environment:    "production",
+		AllowedOrigins: []string{"https://app.example.com", "https://admin.example.com/"},
+	}
+
+	tests := []struct {
+		name   string
+		server config.ServerConfig
+		origin string
+		want   string // Expected Access-Control-Allow-Origin, empty for none
+	}{
+		{"allowed origin reflected", production, "https://app.example.com", "https://app.example.com"},
+		{"trailing slash in config", production, "https://admin.example.com", "https://admin.example.com"},
+		{"unknown origin", production, "https://evil.example.com", ""},
+		{"no origin", production, "", ""},
+		{"production without list", config.ServerConfig{Environment: "production"}, "https://app.example.com", ""},
+		{"development falls back to any", config.ServerConfig{Environment: "development"}, "http://localhost:3000", "*"},
+		{"test falls back to any", config.ServerConfig{Environment: "test"}, "http://localhost:3000", "*"},
+		{"development with list", config.ServerConfig{Environment: "development", AllowedOrigins: []string{"http://localhost:3000"}}, "http://localhost:5173", ""},
+		{"wildcard entry", config.ServerConfig{Environment: "production", AllowedOrigins: []string{"*"}}, "https://any.example.com", "*"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveCORS(tt.server, http.MethodGet, tt.origin)
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d", w.Code)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.want {
+				t.Errorf("expected Access-Control-Allow-Origin %q, got %q", tt.want, got)
+			}
+			if tt.want != "" && tt.want != "*" && w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+				t.Error("expected credentials to be allowed for a listed origin")
+			}
+		})
+	}
+}
+
+func TestCORSMiddlewarePreflight(t *testing.T) {
+	server := config.ServerConfig{Environment: "production", AllowedOrigins: []string{"https://app.example.com"}}
+	w := serveCORS(server, http.MethodOptions, "https://app.example.com")
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("unexpected Access-Control-Allow-Origin %q", got)
+	}
+	if !strings.Contains(w.Header().Get("Access-Control-Allow-Headers"), "Authorization") {
+		t.Error("expected Authorization in Access-Control-Allow-Headers")
+	}
+	for _, method := range []string{"GET", "POST", "PUT", "PATCH", "DELETE"} {
+		if !strings.Contains(w.Header().Get("Access-Control-Allow-Methods"), method) {
+			t.Errorf("expected %s in Access-Control-Allow-Methods", method)
+		}
+	}
+	if w.Header().Get("Vary") != "Origin" {
+		t.Errorf("expected Vary: Origin, got %q", w.Header().Get("Vary"))
+	}
+}
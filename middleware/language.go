@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"mms-backend/utils"
+)
+
+// languageKey is the context key holding the language responses are translated to
+const languageKey = "lang"
+
+// LanguageStore looks up the language a user chose
+type LanguageStore interface {
+	GetLanguage(userID uuid.UUID) (string, error)
+}
+
+// LanguageMiddleware sets "lang" from the first tag of the Accept-Language header, e.g.
+// "fr-FR,fr;q=0.9" gives "fr". Without a usable tag it is empty and utils.T uses the default.
+func LanguageMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(languageKey, parseAcceptLanguage(c.GetHeader("Accept-Language")))
+		c.Next()
+	}
+}
+
+// UserLanguage replaces the header's language with the authenticated user's stored one.
+// It must run after AuthMiddleware; a nil store leaves the header's language in place.
+func UserLanguage(store LanguageStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := GetUserID(c)
+		if store == nil || !ok {
+			c.Next()
+			return
+		}
+
+		language, err := store.GetLanguage(userID)
+		if err != nil {
+			utils.Logger.Warn("Failed to load user language", "user_id", userID, "error", err)
+		} else if language != "" {
+			c.Set(languageKey, language)
+		}
+		c.Next()
+	}
+}
+
+// parseAcceptLanguage returns the lowercased primary subtag of the header's first language tag
+func parseAcceptLanguage(header string) string {
+	tag, _, _ := strings.Cut(header, ",")
+	tag, _, _ = strings.Cut(tag, ";")
+	tag = strings.TrimSpace(tag)
+	if tag == "*" {
+		return ""
+	}
+
+	primary, _, _ := strings.Cut(strings.ReplaceAll(tag, "_", "-"), "-")
+	return strings.ToLower(primary)
+}
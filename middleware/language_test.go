@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// fakeLanguageStore returns a fixed language, or err when set
+type fakeLanguageStore struct {
+	language string
+	err      error
+}
+
+func (s fakeLanguageStore) GetLanguage(uuid.UUID) (string, error) {
+	return s.language, s.err
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	cases := map[string]string{
+		"":                        "",
+		"fr":                      "fr",
+		"fr-FR,fr;q=0.9,en;q=0.8": "fr",
+		"ES_es":                   "es",
+		" de-CH ; q=0.7, en":      "de",
+		"*":                       "",
+	}
+	for header, want := range cases {
+		if got := parseAcceptLanguage(header); got != want {
+			t.Errorf("parseAcceptLanguage(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestUserLanguage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	serve := func(store LanguageStore, authenticated bool) string {
+		router := gin.New()
+		router.Use(LanguageMiddleware(), func(c *gin.Context) {
+			if authenticated {
+				c.Set("user_id", uuid.New())
+			}
+		}, UserLanguage(store))
+
+		var lang string
+		router.GET("/", func(c *gin.Context) {
+			lang = c.GetString("lang")
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Language", "fr-FR")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+		return lang
+	}
+
+	if got := serve(fakeLanguageStore{language: "es"}, true); got != "es" {
+		t.Fatalf("expected the stored language, got %q", got)
+	}
+	if got := serve(fakeLanguageStore{language: "es"}, false); got != "fr" {
+		t.Fatalf("expected the header's language without a user, got %q", got)
+	}
+	if got := serve(fakeLanguageStore{}, true); got != "fr" {
+		t.Fatalf("expected the header's language when none is stored, got %q", got)
+	}
+	if got := serve(fakeLanguageStore{err: errors.New("db down")}, true); got != "fr" {
+		t.Fatalf("expected the header's language when the lookup fails, got %q", got)
+	}
+	if got := serve(nil, true); got != "fr" {
+		t.Fatalf("expected the header's language without a store, got %q", got)
+	}
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"mms-backend/metrics"
+)
+
+// unmatchedPath labels requests that matched no route, so unknown URLs don't each get a series
+const unmatchedPath = "unmatched"
+
+// MetricsMiddleware records the count and duration of every request, labelled with the
+// route template (e.g. /api/v1/groups/:group_id) rather than the raw URL
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		path := c.FullPath()
+		if path == "" {
+			path = unmatchedPath
+		}
+		method := c.Request.Method
+
+		metrics.HTTPRequestsTotal.WithLabelValues(method, path, strconv.Itoa(c.Writer.Status())).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(method, path).Observe(time.Since(start).Seconds())
+	}
+}
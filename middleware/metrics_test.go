@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"mms-backend/metrics"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(MetricsMiddleware())
+	router.GET("/items/:id", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	requests := metrics.HTTPRequestsTotal.WithLabelValues("GET", "/items/:id", "204")
+	unmatched := metrics.HTTPRequestsTotal.WithLabelValues("GET", unmatchedPath, "404")
+	before, beforeUnmatched := testutil.ToFloat64(requests), testutil.ToFloat64(unmatched)
+
+	for _, path := range []string{"/items/1", "/items/2", "/nowhere"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// Requests are grouped by route template, not by URL
+	if got := testutil.ToFloat64(requests) - before; got != 2 {
+		t.Errorf("expected 2 requests on /items/:id, got %v", got)
+	}
+	if got := testutil.ToFloat64(unmatched) - beforeUnmatched; got != 1 {
+		t.Errorf("expected 1 unmatched request, got %v", got)
+	}
+	if count := testutil.CollectAndCount(metrics.HTTPRequestDuration, "http_request_duration_seconds"); count == 0 {
+		t.Error("expected request durations to be observed")
+	}
+}
@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimiter counts requests per key with a sliding window counter: the previous
+// window's count is weighted by how much of it still overlaps the sliding window
+type RateLimiter struct {
+	limit   int
+	window  time.Duration
+	entries sync.Map // string -> *rateWindow
+	now     func() time.Time
+}
+
+// rateWindow holds the request counts of one key for the current and previous fixed windows
+type rateWindow struct {
+	mu       sync.Mutex
+	start    time.Time // Start of the current fixed window
+	current  int
+	previous int
+}
+
+// NewRateLimiter creates a limiter allowing limit requests per key within window
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		limit:  limit,
+		window: window,
+		now:    time.Now,
+	}
+}
+
+// Allow records a request for key. When the limit is exceeded the request is not
+// counted and Allow returns false with how long the caller should wait.
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	now := l.now()
+	value, _ := l.entries.LoadOrStore(key, &rateWindow{start: now.Truncate(l.window)})
+	entry := value.(*rateWindow)
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	windowStart := now.Truncate(l.window)
+	switch elapsed := windowStart.Sub(entry.start); {
+	case elapsed == l.window:
+		entry.previous, entry.current = entry.current, 0
+		entry.start = windowStart
+	case elapsed > l.window:
+		entry.previous, entry.current = 0, 0
+		entry.start = windowStart
+	}
+
+	overlap := 1 - float64(now.Sub(entry.start))/float64(l.window)
+	estimate := float64(entry.previous)*overlap + float64(entry.current)
+	if estimate+1 > float64(l.limit) {
+		return false, entry.start.Add(l.window).Sub(now)
+	}
+
+	entry.current++
+	return true, 0
+}
+
+// evictExpired forgets keys with no requests in the last two windows, whose counts can no longer matter
+func (l *RateLimiter) evictExpired() {
+	cutoff := l.now().Add(-2 * l.window)
+	l.entries.Range(func(key, value interface{}) bool {
+		entry := value.(*rateWindow)
+		entry.mu.Lock()
+		expired := entry.start.Before(cutoff)
+		entry.mu.Unlock()
+		if expired {
+			l.entries.CompareAndDelete(key, value)
+		}
+		return true
+	})
+}
+
+// RunEviction removes stale keys every interval until stop is closed (nil runs forever)
+func (l *RateLimiter) RunEviction(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			l.evictExpired()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// RateLimitMiddleware allows limit requests per window for each user, or for each client IP on
+// routes without authentication, answering 429 with Retry-After beyond that. A limit of zero
+// or less disables it. Place it after AuthMiddleware on protected routes so requests are keyed
+// by user.
+func RateLimitMiddleware(limit int, window time.Duration) gin.HandlerFunc {
+	if limit <= 0 || window <= 0 {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	limiter := NewRateLimiter(limit, window)
+	go limiter.RunEviction(window, nil)
+
+	return rateLimitHandler(limiter)
+}
+
+func rateLimitHandler(limiter *RateLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := "ip:" + c.ClientIP()
+		if userID, ok := GetUserID(c); ok {
+			key = "user:" + userID.String()
+		}
+
+		allowed, retryAfter := limiter.Allow(key)
+		if !allowed {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error": "too many requests",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
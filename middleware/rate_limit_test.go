@@ -0,0 +1,162 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func newTestLimiter(limit int, window time.Duration) (*RateLimiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	limiter := NewRateLimiter(limit, window)
+	limiter.now = clock.Now
+	return limiter, clock
+}
+
+func TestRateLimiterSlidingWindow(t *testing.T) {
+	limiter, clock := newTestLimiter(4, time.Minute)
+
+	for i := 0; i < 4; i++ {
+		if allowed, _ := limiter.Allow("a"); !allowed {
+			t.Fatalf("request %d should be allowed", i+1)
+		}
+	}
+	allowed, retryAfter := limiter.Allow("a")
+	if allowed {
+		t.Fatal("fifth request in the window should be limited")
+	}
+	if retryAfter <= 0 || retryAfter > time.Minute {
+		t.Fatalf("unexpected retry after %v", retryAfter)
+	}
+	if allowed, _ := limiter.Allow("b"); !allowed {
+		t.Fatal("other keys have their own budget")
+	}
+
+	// Halfway through the next window half of the previous window's requests still count
+	clock.now = clock.now.Add(90 * time.Second)
+	for i := 0; i < 2; i++ {
+		if allowed, _ := limiter.Allow("a"); !allowed {
+			t.Fatalf("request %d after the window slid should be allowed", i+1)
+		}
+	}
+	if allowed, _ := limiter.Allow("a"); allowed {
+		t.Fatal("the previous window's weighted requests should still count")
+	}
+
+	// Two windows later nothing from before counts
+	clock.now = clock.now.Add(2 * time.Minute)
+	for i := 0; i < 4; i++ {
+		if allowed, _ := limiter.Allow("a"); !allowed {
+			t.Fatalf("request %d in a fresh window should be allowed", i+1)
+		}
+	}
+}
+
+func TestRateLimiterEviction(t *testing.T) {
+	limiter, clock := newTestLimiter(1, time.Minute)
+	limiter.Allow("old")
+
+	clock.now = clock.now.Add(time.Minute)
+	limiter.Allow("recent")
+
+	clock.now = clock.now.Add(2 * time.Minute)
+	limiter.evictExpired()
+
+	if _, ok := limiter.entries.Load("old"); ok {
+		t.Fatal("keys idle for two windows should be evicted")
+	}
+	if _, ok := limiter.entries.Load("recent"); !ok {
+		t.Fatal("keys used within two windows should be kept")
+	}
+}
+
+func TestRateLimiterConcurrent(t *testing.T) {
+	limiter := NewRateLimiter(50, time.Hour)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ok, _ := limiter.Allow("shared"); ok {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Requests may straddle a window boundary, which can only lower the count
+	if allowed > 50 {
+		t.Fatalf("allowed %d requests, limit is 50", allowed)
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter, _ := newTestLimiter(2, time.Minute)
+	userID := uuid.New()
+
+	router := gin.New()
+	router.POST("/login", rateLimitHandler(limiter), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.POST("/messages", func(c *gin.Context) {
+		c.Set("user_id", userID)
+	}, rateLimitHandler(limiter), func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+
+	do := func(path, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Unauthenticated requests are keyed by IP
+	do("/login", "203.0.113.1:1000")
+	do("/login", "203.0.113.1:1001")
+	w := do("/login", "203.0.113.1:1002")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Fatal("429 responses should carry Retry-After")
+	}
+	if w := do("/login", "203.0.113.2:1000"); w.Code != http.StatusOK {
+		t.Fatalf("another IP should not be limited, got %d", w.Code)
+	}
+
+	// Authenticated requests are keyed by user, whatever the address
+	do("/messages", "198.51.100.1:1000")
+	do("/messages", "198.51.100.2:1000")
+	if w := do("/messages", "198.51.100.3:1000"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the user to be limited across addresses, got %d", w.Code)
+	}
+}
+
+func TestRateLimitMiddlewareDisabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/", RateLimitMiddleware(0, time.Minute), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for i := 0; i < 5; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("a zero limit should disable limiting, got %d", w.Code)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"mms-backend/utils"
+)
+
+// RequestIDHeader carries the correlation ID of a request, both ways
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDKey is the Gin context key holding the request ID
+const requestIDKey = "request_id"
+
+// maxRequestIDLength bounds client-supplied IDs so they can't bloat every log line
+const maxRequestIDLength = 128
+
+// RequestIDMiddleware tags each request with the X-Request-ID it came with, or a new UUID, and
+// echoes it in the response. The ID is stored on the Gin context and on the request's
+// context, where utils.LoggerFromContext picks it up.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+
+		c.Set(requestIDKey, requestID)
+		c.Request = c.Request.WithContext(utils.WithRequestID(c.Request.Context(), requestID))
+		c.Writer.Header().Set(RequestIDHeader, requestID)
+
+		c.Next()
+	}
+}
+
+// GetRequestID returns the ID RequestIDMiddleware gave the request
+func GetRequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// validRequestID accepts non-empty IDs of printable ASCII, so they are safe to log as is
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(requestID); i++ {
+		if requestID[i] < 0x21 || requestID[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"mms-backend/utils"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestIDMiddleware())
+
+	var fromGin, fromContext string
+	router.GET("/", func(c *gin.Context) {
+		fromGin = GetRequestID(c)
+		fromContext = utils.RequestIDFromContext(c.Request.Context())
+	})
+
+	serve := func(sent string) string {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if sent != "" {
+			req.Header.Set(RequestIDHeader, sent)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Header().Get(RequestIDHeader)
+	}
+
+	if got := serve("abc-123"); got != "abc-123" {
+		t.Fatalf("expected the incoming ID to be echoed, got %q", got)
+	}
+	if fromGin != "abc-123" || fromContext != "abc-123" {
+		t.Fatalf("expected the ID on the Gin and request contexts, got %q and %q", fromGin, fromContext)
+	}
+
+	for _, sent := range []string{"", "two words", "line\nbreak", strings.Repeat("a", maxRequestIDLength+1)} {
+		got := serve(sent)
+		if _, err := uuid.Parse(got); err != nil {
+			t.Errorf("expected a generated UUID for %q, got %q", sent, got)
+		}
+		if fromContext != got {
+			t.Errorf("expected the generated ID on the request context, got %q", fromContext)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"mms-backend/utils"
+)
+
+// RequestLogger writes one structured access log line per request with its method, path,
+// status and duration, plus the request_id and user_id once the inner middleware set them
+func RequestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		attrs := []any{
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", c.Writer.Status(),
+			"duration", time.Since(start),
+			"client_ip", c.ClientIP(),
+		}
+		if requestID := GetRequestID(c); requestID != "" {
+			attrs = append(attrs, "request_id", requestID)
+		}
+		if userID, ok := GetUserID(c); ok {
+			attrs = append(attrs, "user_id", userID)
+		}
+		if errors := c.Errors.ByType(gin.ErrorTypePrivate).String(); errors != "" {
+			attrs = append(attrs, "errors", errors)
+		}
+
+		level := slog.LevelInfo
+		if c.Writer.Status() >= 500 {
+			level = slog.LevelError
+		}
+		utils.Logger.Log(c.Request.Context(), level, "request", attrs...)
+	}
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"mms-backend/utils"
+)
+
+func TestRequestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	previous := utils.Logger
+	utils.Logger = slog.New(slog.NewJSONHandler(&buf, nil))
+	t.Cleanup(func() { utils.Logger = previous })
+
+	userID := uuid.New()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestLogger(), RequestIDMiddleware())
+	router.GET("/boom", func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Status(http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/boom", nil)
+	req.Header.Set(RequestIDHeader, "req-42")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid log line %q: %v", buf.String(), err)
+	}
+	expected := map[string]interface{}{
+		"level":      "ERROR",
+		"method":     "GET",
+		"path":       "/boom",
+		"status":     float64(500),
+		"request_id": "req-42",
+		"user_id":    userID.String(),
+	}
+	for key, value := range expected {
+		if entry[key] != value {
+			t.Errorf("expected %s=%v, got %v", key, value, entry[key])
+		}
+	}
+	if _, ok := entry["duration"]; !ok {
+		t.Error("expected a duration field")
+	}
+}
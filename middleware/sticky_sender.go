@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ReadYourWritesHeader marks responses to mutating requests; clients echo it on
+// follow-up reads that must observe the write
+const ReadYourWritesHeader = "X-Read-Your-Writes"
+
+// readPrimaryKey is the context key set when a read must go to the primary database
+const readPrimaryKey = "readPrimary"
+
+// DefaultStickyWindow is how long a user's reads stick to the primary after a write
+const DefaultStickyWindow = 5 * time.Second
+
+// StickyTracker remembers when each user last mutated data
+type StickyTracker struct {
+	window    time.Duration
+	lastWrite sync.Map // uuid.UUID -> time.Time
+	now       func() time.Time
+}
+
+// NewStickyTracker creates a tracker whose sticky window lasts window
+func NewStickyTracker(window time.Duration) *StickyTracker {
+	return &StickyTracker{
+		window: window,
+		now:    time.Now,
+	}
+}
+
+// MarkWrite records a mutation by userID
+func (t *StickyTracker) MarkWrite(userID uuid.UUID) {
+	t.lastWrite.Store(userID, t.now())
+}
+
+// IsSticky reports whether userID mutated data within the window, forgetting expired entries
+func (t *StickyTracker) IsSticky(userID uuid.UUID) bool {
+	value, ok := t.lastWrite.Load(userID)
+	if !ok {
+		return false
+	}
+
+	if t.now().Sub(value.(time.Time)) < t.window {
+		return true
+	}
+	t.lastWrite.CompareAndDelete(userID, value)
+	return false
+}
+
+// StickySender tags mutating responses with X-Read-Your-Writes and routes a user's
+// reads to the primary while they are within the sticky window. It must run after
+// AuthMiddleware.
+func StickySender(tracker *StickyTracker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, authenticated := GetUserID(c)
+		if !authenticated {
+			c.Next()
+			return
+		}
+
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			if c.GetHeader(ReadYourWritesHeader) == "true" && tracker.IsSticky(userID) {
+				c.Set(readPrimaryKey, true)
+			}
+			c.Next()
+		default:
+			c.Writer.Header().Set(ReadYourWritesHeader, "true")
+			c.Next()
+			if c.Writer.Status() < http.StatusBadRequest {
+				tracker.MarkWrite(userID)
+			}
+		}
+	}
+}
+
+// ShouldReadPrimary reports whether StickySender routed this request to the primary database
+func ShouldReadPrimary(c *gin.Context) bool {
+	return c.GetBool(readPrimaryKey)
+}
@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// fakeClock lets tests move the tracker's notion of now
+type fakeClock struct {
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	return f.now
+}
+
+func newTestTracker(window time.Duration) (*StickyTracker, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	tracker := NewStickyTracker(window)
+	tracker.now = clock.Now
+	return tracker, clock
+}
+
+func TestStickyTrackerWindowExpiry(t *testing.T) {
+	tracker, clock := newTestTracker(5 * time.Second)
+	userID := uuid.New()
+
+	if tracker.IsSticky(userID) {
+		t.Fatal("user without writes should not be sticky")
+	}
+
+	tracker.MarkWrite(userID)
+	clock.now = clock.now.Add(4999 * time.Millisecond)
+	if !tracker.IsSticky(userID) {
+		t.Fatal("user should be sticky inside the window")
+	}
+
+	clock.now = clock.now.Add(time.Millisecond)
+	if tracker.IsSticky(userID) {
+		t.Fatal("user should not be sticky once the window has elapsed")
+	}
+	if _, ok := tracker.lastWrite.Load(userID); ok {
+		t.Fatal("expired entry should be forgotten")
+	}
+}
+
+func TestStickyTrackerWriteExtendsWindow(t *testing.T) {
+	tracker, clock := newTestTracker(5 * time.Second)
+	userID := uuid.New()
+
+	tracker.MarkWrite(userID)
+	clock.now = clock.now.Add(4 * time.Second)
+	tracker.MarkWrite(userID)
+	clock.now = clock.now.Add(4 * time.Second)
+
+	if !tracker.IsSticky(userID) {
+		t.Fatal("a second write should restart the window")
+	}
+	if tracker.IsSticky(uuid.New()) {
+		t.Fatal("other users should not be sticky")
+	}
+}
+
+func TestStickySenderMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tracker, clock := newTestTracker(5 * time.Second)
+	userID := uuid.New()
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", userID)
+	}, StickySender(tracker))
+	router.POST("/items", func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+	router.POST("/invalid", func(c *gin.Context) {
+		c.Status(http.StatusBadRequest)
+	})
+	router.GET("/items", func(c *gin.Context) {
+		if ShouldReadPrimary(c) {
+			c.String(http.StatusOK, "primary")
+			return
+		}
+		c.String(http.StatusOK, "replica")
+	})
+
+	do := func(method, path string, readYourWrites bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if readYourWrites {
+			req.Header.Set(ReadYourWritesHeader, "true")
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("POST", "/invalid", false); w.Header().Get(ReadYourWritesHeader) != "true" {
+		t.Fatal("mutating responses should carry the read-your-writes header")
+	}
+	if w := do("GET", "/items", true); w.Body.String() != "replica" {
+		t.Fatal("failed mutations should not make reads sticky")
+	}
+
+	do("POST", "/items", false)
+	if w := do("GET", "/items", false); w.Body.String() != "replica" {
+		t.Fatal("reads without the header should use the replica")
+	}
+	if w := do("GET", "/items", true); w.Body.String() != "primary" {
+		t.Fatal("reads with the header inside the window should use the primary")
+	}
+
+	clock.now = clock.now.Add(5 * time.Second)
+	if w := do("GET", "/items", true); w.Body.String() != "replica" {
+		t.Fatal("reads after the window should use the replica")
+	}
+}
@@ -0,0 +1,7 @@
+-- BRIN index on messages.created_at.
+--
+-- Messages are append-only, so created_at grows with the physical row order
+-- and a BRIN index covers time-range scans (retention jobs, date filters) at a
+-- fraction of the size of a B-tree. See docs/database_indexes.md for the full
+-- list of message indexes and why each one exists.
+CREATE INDEX IF NOT EXISTS idx_messages_created_at_brin ON messages USING BRIN (created_at);
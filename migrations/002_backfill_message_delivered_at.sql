@@ -0,0 +1,5 @@
+-- Backfill messages.delivered_at for messages read before delivery receipts existed.
+--
+-- A read message was necessarily delivered; read_at is the closest known time.
+UPDATE messages SET delivered_at = read_at
+WHERE is_read = true AND delivered_at IS NULL AND read_at IS NOT NULL;
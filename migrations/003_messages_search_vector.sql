@@ -0,0 +1,10 @@
+-- Full-text search over direct messages.
+--
+-- Message content is encrypted at rest, so PostgreSQL cannot derive the
+-- vector from the content column with a generated column or a trigger.
+-- MessageService sets it from the plaintext when a message is sent or
+-- edited and empties it on deletion; NULL marks rows not indexed yet, which
+-- MessageService.BackfillSearchIndex fills in. The 'simple' configuration is
+-- used because messages are written in several languages.
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS search_vector tsvector;
+CREATE INDEX IF NOT EXISTS idx_messages_search_vector ON messages USING GIN (search_vector);
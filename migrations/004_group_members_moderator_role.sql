@@ -0,0 +1,7 @@
+-- Allow the moderator group role.
+--
+-- AutoMigrate only creates check constraints that are missing, so databases
+-- created before the moderator role still carry the old definition of
+-- chk_group_members_role. Recreating it is safe to repeat.
+ALTER TABLE group_members DROP CONSTRAINT IF EXISTS chk_group_members_role;
+ALTER TABLE group_members ADD CONSTRAINT chk_group_members_role CHECK (role IN ('owner', 'admin', 'moderator', 'member'));
@@ -0,0 +1,13 @@
+-- Foreign key from groups.pinned_message_id to group_messages.
+--
+-- It is not declared on the Group model because group_messages already
+-- references groups, and AutoMigrate cannot order two tables that point at
+-- each other. Deleting a pinned message unpins it.
+DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'fk_groups_pinned_message') THEN
+		ALTER TABLE groups ADD CONSTRAINT fk_groups_pinned_message
+			FOREIGN KEY (pinned_message_id) REFERENCES group_messages (id) ON DELETE SET NULL;
+	END IF;
+END
+$$;
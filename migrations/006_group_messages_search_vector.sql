@@ -0,0 +1,6 @@
+-- Full-text search over group messages, maintained like messages.search_vector
+-- (see 003_messages_search_vector.sql): GroupService sets it from the
+-- plaintext when a message is sent or edited and empties it on deletion, and
+-- GroupService.BackfillSearchIndex fills in the rows that are still NULL.
+ALTER TABLE group_messages ADD COLUMN IF NOT EXISTS search_vector tsvector;
+CREATE INDEX IF NOT EXISTS idx_group_messages_search_vector ON group_messages USING GIN (search_vector);
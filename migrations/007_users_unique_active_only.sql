@@ -0,0 +1,12 @@
+-- Usernames and emails are only unique among accounts that are not deleted.
+--
+-- A deleted account keeps its row for 30 days before PurgeDeleted removes it,
+-- and every lookup skips it, so signing up again with its email or username
+-- passed the availability checks and then hit the old full unique index.
+-- AutoMigrate creates the partial indexes on new databases but leaves
+-- existing indexes of the same name alone.
+DROP INDEX IF EXISTS idx_users_email;
+CREATE UNIQUE INDEX idx_users_email ON users (email) WHERE deleted_at IS NULL;
+
+DROP INDEX IF EXISTS idx_username_lower;
+CREATE UNIQUE INDEX idx_username_lower ON users (username) WHERE deleted_at IS NULL;
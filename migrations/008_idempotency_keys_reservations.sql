@@ -0,0 +1,3 @@
+-- An idempotency key is reserved, with no response yet, before the request it
+-- guards is handled, so a retry arriving meanwhile can't send a second message.
+ALTER TABLE idempotency_keys ALTER COLUMN response_body DROP NOT NULL;
@@ -0,0 +1,9 @@
+-- New groups let any member add members. Groups that existed before that
+-- default changed, and never customised their permissions, keep admin-only
+-- adds: they get an explicit permissions row saying so.
+ALTER TABLE group_permissions ALTER COLUMN add_members SET DEFAULT 'member';
+INSERT INTO group_permissions (group_id, send_messages, add_members, pin_messages, edit_group_info, updated_at)
+SELECT g.id, 'member', 'admin', 'member', 'member', NOW()
+FROM groups g
+WHERE NOT EXISTS (SELECT 1 FROM group_permissions p WHERE p.group_id = g.id)
+ON CONFLICT (group_id) DO NOTHING;
@@ -0,0 +1,5 @@
+-- Cached send responses hold the message content, so they are now stored
+-- encrypted in a text column. Responses cached in clear are dropped: at worst a
+-- retry of one of them sends again instead of being replayed.
+DELETE FROM idempotency_keys WHERE response_body IS NOT NULL;
+ALTER TABLE idempotency_keys ALTER COLUMN response_body TYPE text;
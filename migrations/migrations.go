@@ -0,0 +1,70 @@
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// files holds the raw SQL migrations that AutoMigrate cannot express
+//
+//go:embed *.sql
+var files embed.FS
+
+// SchemaMigration records a SQL migration that has been applied
+type SchemaMigration struct {
+	Name      string    `gorm:"type:varchar(255);primary_key"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// Run applies every embedded SQL migration not yet recorded in schema_migrations, in
+// file name order. Each file runs in its own transaction with its bookkeeping row.
+// Files should still be idempotent (CREATE INDEX IF NOT EXISTS, ...) so databases
+// migrated before the bookkeeping table existed can replay them safely.
+func Run(db *gorm.DB) error {
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return err
+	}
+
+	names, err := fs.Glob(files, "*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	var applied []string
+	if err := db.Model(&SchemaMigration{}).Pluck("name", &applied).Error; err != nil {
+		return err
+	}
+	done := make(map[string]bool, len(applied))
+	for _, name := range applied {
+		done[name] = true
+	}
+
+	for _, name := range names {
+		if done[name] {
+			continue
+		}
+
+		sql, err := files.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(string(sql)).Error; err != nil {
+				return err
+			}
+			return tx.Clauses(clause.OnConflict{DoNothing: true}).
+				Create(&SchemaMigration{Name: name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ArchivedConversation hides a direct conversation from a user's recent conversations without deleting its messages
+type ArchivedConversation struct {
+	ID         uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	UserID     uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_archived_conversation_user_partner" json:"user_id"`
+	PartnerID  uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_archived_conversation_user_partner" json:"partner_id"`
+	ArchivedAt time.Time `gorm:"not null" json:"archived_at"`
+
+	// Relationships
+	User    User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+	Partner User `gorm:"foreignKey:PartnerID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// BeforeCreate hook to generate UUID and archive time before creating an archive entry
+func (a *ArchivedConversation) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	if a.ArchivedAt.IsZero() {
+		a.ArchivedAt = time.Now()
+	}
+	return nil
+}
+
+// TableName specifies the table name for ArchivedConversation model
+func (ArchivedConversation) TableName() string {
+	return "archived_conversations"
+}
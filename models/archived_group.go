@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ArchivedGroup hides a group from a member's active group list without leaving it
+type ArchivedGroup struct {
+	ID         uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	UserID     uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_archived_user_group" json:"user_id"`
+	GroupID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_archived_user_group;index" json:"group_id"`
+	ArchivedAt time.Time `gorm:"not null" json:"archived_at"`
+
+	// Relationships
+	User  User  `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+	Group Group `gorm:"foreignKey:GroupID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// BeforeCreate hook to generate UUID and archive time before creating an archive entry
+func (a *ArchivedGroup) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	if a.ArchivedAt.IsZero() {
+		a.ArchivedAt = time.Now()
+	}
+	return nil
+}
+
+// TableName specifies the table name for ArchivedGroup model
+func (ArchivedGroup) TableName() string {
+	return "archived_groups"
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// FilterSeverity controls what happens when message content matches a content filter
+type FilterSeverity string
+
+const (
+	FilterSeverityLow    FilterSeverity = "low"    // Masked
+	FilterSeverityMedium FilterSeverity = "medium" // Masked
+	FilterSeverityHigh   FilterSeverity = "high"   // Message rejected
+)
+
+// IsValid reports whether s is a known severity
+func (s FilterSeverity) IsValid() bool {
+	switch s {
+	case FilterSeverityLow, FilterSeverityMedium, FilterSeverityHigh:
+		return true
+	}
+	return false
+}
+
+// ContentFilter is a moderation rule applied to message content: a word matched as a whole
+// word ignoring case, or a regular expression when IsRegex is set
+type ContentFilter struct {
+	ID        uuid.UUID      `gorm:"type:uuid;primary_key" json:"id"`
+	Word      string         `gorm:"type:varchar(255);not null" json:"word"`
+	Severity  FilterSeverity `gorm:"type:varchar(10);not null;default:'low'" json:"severity"`
+	IsRegex   bool           `gorm:"not null;default:false" json:"is_regex"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// BeforeCreate hook to generate UUID before creating a content filter
+func (f *ContentFilter) BeforeCreate(tx *gorm.DB) error {
+	if f.ID == uuid.Nil {
+		f.ID = uuid.New()
+	}
+	if f.Severity == "" {
+		f.Severity = FilterSeverityLow
+	}
+	return nil
+}
+
+// TableName specifies the table name for ContentFilter model
+func (ContentFilter) TableName() string {
+	return "content_filters"
+}
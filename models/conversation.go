@@ -8,10 +8,16 @@ import (
 
 // ConversationSummary represents a conversation overview between the current user and another user
 type ConversationSummary struct {
-	User                PublicUser `json:"user"`
-	LastMessage         string     `json:"last_message,omitempty"`
-	LastMessageTime     *time.Time `json:"last_message_time,omitempty"`
-	LastMessageSenderID uuid.UUID  `json:"last_message_sender_id"`
-	LastMessageIsRead   bool       `json:"last_message_is_read"`
-	UnreadCount         int64      `json:"unread_count"`
+	User                PublicUser    `json:"user"`
+	LastMessage         string        `json:"last_message,omitempty"`
+	LastMessageTime     *time.Time    `json:"last_message_time,omitempty"`
+	LastMessageSenderID uuid.UUID     `json:"last_message_sender_id"`
+	LastMessageStatus   MessageStatus `json:"last_message_status,omitempty"`
+	UnreadCount         int64         `json:"unread_count"`
+	TotalMessages       int64         `json:"total_messages"` // Deleted messages included
+	Draft               string        `json:"draft,omitempty"`
+	Labels              []Label       `json:"labels"`
+	IsMuted             bool          `json:"is_muted"`
+	MutedUntil          *time.Time    `json:"muted_until,omitempty"`
+	IsArchived          bool          `json:"is_archived"`
 }
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ConversationDraft stores a user's unsent message to a conversation partner
+type ConversationDraft struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_conversation_draft_user_partner" json:"user_id"`
+	PartnerID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_conversation_draft_user_partner" json:"partner_id"`
+	Content   string    `gorm:"type:text;not null" json:"content"` // Encrypted content
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Relationships
+	User    User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+	Partner User `gorm:"foreignKey:PartnerID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// BeforeCreate hook to generate UUID before creating a draft
+func (d *ConversationDraft) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	return nil
+}
+
+// TableName specifies the table name for ConversationDraft model
+func (ConversationDraft) TableName() string {
+	return "conversation_drafts"
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ConversationLabel is a tag a user puts on a conversation to organize their inbox
+type ConversationLabel struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_conversation_label_user_partner_label" json:"user_id"`
+	PartnerID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_conversation_label_user_partner_label" json:"partner_id"`
+	Label     string    `gorm:"type:varchar(20);not null;uniqueIndex:idx_conversation_label_user_partner_label" json:"label"`
+	Color     string    `gorm:"type:varchar(7);not null" json:"color"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Relationships
+	User    User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+	Partner User `gorm:"foreignKey:PartnerID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// Label is a conversation label as shown to its owner
+type Label struct {
+	Label string `json:"label"`
+	Color string `json:"color"`
+}
+
+// BeforeCreate hook to generate UUID before creating a label
+func (l *ConversationLabel) BeforeCreate(tx *gorm.DB) error {
+	if l.ID == uuid.Nil {
+		l.ID = uuid.New()
+	}
+	return nil
+}
+
+// TableName specifies the table name for ConversationLabel model
+func (ConversationLabel) TableName() string {
+	return "conversation_labels"
+}
+
+// ToLabel converts a ConversationLabel to Label
+func (l *ConversationLabel) ToLabel() Label {
+	return Label{
+		Label: l.Label,
+		Color: l.Color,
+	}
+}
@@ -22,13 +22,52 @@ type Group struct {
 	Description string    `gorm:"type:text" json:"description"`
 	Type        GroupType `gorm:"type:varchar(20);not null;default:'private'" json:"type"`
 	Avatar      string    `gorm:"type:varchar(500)" json:"avatar"`
+	Language    string    `gorm:"type:varchar(10);default:'en';index" json:"language"` // Creator's language at creation, used by discover
 	CreatedBy   uuid.UUID `gorm:"type:uuid;not null" json:"created_by"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+
+	// Settings
+	AnnouncementsOnly bool `gorm:"not null;default:false" json:"announcements_only"` // Only moderators and above can post
+
+	// Pinned message; the foreign key to group_messages is added by a SQL migration
+	PinnedMessageID *uuid.UUID `gorm:"type:uuid" json:"pinned_message_id"`
+	PinnedAt        *time.Time `json:"pinned_at"`
 	
 	// Relationships
 	Creator User          `gorm:"foreignKey:CreatedBy;constraint:OnDelete:CASCADE" json:"creator,omitempty"`
 	Members []GroupMember `gorm:"foreignKey:GroupID" json:"members,omitempty"`
+
+	// PinnedMessage is the decrypted pinned message, filled in by GroupService.GetGroup
+	PinnedMessage *GroupMessageResponse `gorm:"-" json:"pinned_message,omitempty"`
+}
+
+// GroupWithCount is a group together with its member count and the requester's mute state,
+// draft and unread message count (API v2)
+type GroupWithCount struct {
+	Group
+	MemberCount int64      `json:"member_count"`
+	IsMuted     bool       `json:"is_muted"`
+	MutedUntil  *time.Time `json:"muted_until"`
+	Draft       string     `json:"draft,omitempty"`
+	UnreadCount int64      `json:"unread_count"`
+}
+
+// GroupSummary is a group in the requester's group list with their unread message count
+type GroupSummary struct {
+	Group
+	UnreadCount int64 `json:"unread_count"`
+}
+
+// PublicGroupSummary is a public group as listed by GET /groups/public, without its members
+type PublicGroupSummary struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Avatar      string    `json:"avatar"`
+	Language    string    `json:"language"`
+	MemberCount int64     `json:"member_count"`
+	CreatedAt   time.Time `json:"created_at"`
 }
 
 // BeforeCreate hook to generate UUID before creating group
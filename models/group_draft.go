@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// GroupDraft stores a member's unsent message to a group
+type GroupDraft struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_group_draft_user_group" json:"user_id"`
+	GroupID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_group_draft_user_group;index" json:"group_id"`
+	Content   string    `gorm:"type:text;not null" json:"content"` // Encrypted content
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Relationships
+	User  User  `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+	Group Group `gorm:"foreignKey:GroupID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// BeforeCreate hook to generate UUID before creating a draft
+func (d *GroupDraft) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	return nil
+}
+
+// TableName specifies the table name for GroupDraft model
+func (GroupDraft) TableName() string {
+	return "group_drafts"
+}
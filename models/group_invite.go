@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// GroupInvite is a link that lets anyone holding its token join a group until it expires
+// or has been used MaxUses times
+type GroupInvite struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	GroupID   uuid.UUID `gorm:"type:uuid;not null;index" json:"group_id"`
+	Token     string    `gorm:"type:varchar(64);not null;uniqueIndex" json:"token"`
+	CreatedBy uuid.UUID `gorm:"type:uuid;not null" json:"created_by"`
+	ExpiresAt time.Time `gorm:"not null" json:"expires_at"`
+	MaxUses   int       `gorm:"not null" json:"max_uses"` // 0 means unlimited
+	UsedCount int       `gorm:"not null" json:"used_count"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Relationships
+	Group Group `gorm:"foreignKey:GroupID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// IsUsable reports whether the invite can still be used to join at now
+func (i *GroupInvite) IsUsable(now time.Time) bool {
+	return now.Before(i.ExpiresAt) && (i.MaxUses == 0 || i.UsedCount < i.MaxUses)
+}
+
+// BeforeCreate hook to generate UUID before creating a group invite
+func (i *GroupInvite) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == uuid.Nil {
+		i.ID = uuid.New()
+	}
+	return nil
+}
+
+// TableName specifies the table name for GroupInvite model
+func (GroupInvite) TableName() string {
+	return "group_invites"
+}
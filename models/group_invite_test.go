@@ -0,0 +1,32 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGroupInviteIsUsable(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		expiresAt time.Time
+		maxUses   int
+		usedCount int
+		want      bool
+	}{
+		{"fresh", now.Add(time.Hour), 5, 0, true},
+		{"last use left", now.Add(time.Hour), 5, 4, true},
+		{"used up", now.Add(time.Hour), 5, 5, false},
+		{"unlimited", now.Add(time.Hour), 0, 1000, true},
+		{"expired", now.Add(-time.Second), 0, 0, false},
+		{"expires now", now, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		invite := GroupInvite{ExpiresAt: tt.expiresAt, MaxUses: tt.maxUses, UsedCount: tt.usedCount}
+		if got := invite.IsUsable(now); got != tt.want {
+			t.Errorf("%s: IsUsable = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
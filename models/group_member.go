@@ -7,20 +7,58 @@ import (
 	"gorm.io/gorm"
 )
 
-// MemberRole defines the role of a group member
+// MemberRole defines the role of a group member, ordered owner > admin > moderator > member
 type MemberRole string
 
 const (
-	MemberRoleAdmin  MemberRole = "admin"
-	MemberRoleMember MemberRole = "member"
+	MemberRoleOwner     MemberRole = "owner"
+	MemberRoleAdmin     MemberRole = "admin"
+	MemberRoleModerator MemberRole = "moderator"
+	MemberRoleMember    MemberRole = "member"
 )
 
+// rank orders roles from least to most privileged; unknown roles rank below member
+func (r MemberRole) rank() int {
+	switch r {
+	case MemberRoleOwner:
+		return 4
+	case MemberRoleAdmin:
+		return 3
+	case MemberRoleModerator:
+		return 2
+	case MemberRoleMember:
+		return 1
+	}
+	return 0
+}
+
+// IsValid reports whether r is a known role
+func (r MemberRole) IsValid() bool {
+	return r.rank() > 0
+}
+
+// AtLeast reports whether r grants at least the privileges of min
+func (r MemberRole) AtLeast(min MemberRole) bool {
+	return r.IsValid() && r.rank() >= min.rank()
+}
+
+// Outranks reports whether r is strictly more privileged than other
+func (r MemberRole) Outranks(other MemberRole) bool {
+	return r.IsValid() && r.rank() > other.rank()
+}
+
+// CanModerate reports whether a role may remove members and delete their messages
+// (moderators, admins and the owner)
+func CanModerate(role MemberRole) bool {
+	return role.AtLeast(MemberRoleModerator)
+}
+
 // GroupMember represents a member in a group
 type GroupMember struct {
 	ID       uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
 	GroupID  uuid.UUID  `gorm:"type:uuid;not null;index" json:"group_id"`
 	UserID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
-	Role     MemberRole `gorm:"type:varchar(20);not null;default:'member'" json:"role"`
+	Role     MemberRole `gorm:"type:varchar(20);not null;default:'member';check:chk_group_members_role,role IN ('owner','admin','moderator','member')" json:"role"`
 	JoinedAt time.Time  `json:"joined_at"`
 	
 	// Relationships
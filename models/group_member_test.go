@@ -0,0 +1,92 @@
+package models
+
+import "testing"
+
+func TestMemberRoleAtLeast(t *testing.T) {
+	tests := []struct {
+		role MemberRole
+		min  MemberRole
+		want bool
+	}{
+		{MemberRoleOwner, MemberRoleOwner, true},
+		{MemberRoleOwner, MemberRoleAdmin, true},
+		{MemberRoleOwner, MemberRoleMember, true},
+		{MemberRoleAdmin, MemberRoleOwner, false},
+		{MemberRoleAdmin, MemberRoleAdmin, true},
+		{MemberRoleAdmin, MemberRoleModerator, true},
+		{MemberRoleAdmin, MemberRoleMember, true},
+		{MemberRoleModerator, MemberRoleAdmin, false},
+		{MemberRoleModerator, MemberRoleModerator, true},
+		{MemberRoleModerator, MemberRoleMember, true},
+		{MemberRoleMember, MemberRoleOwner, false},
+		{MemberRoleMember, MemberRoleAdmin, false},
+		{MemberRoleMember, MemberRoleModerator, false},
+		{MemberRoleMember, MemberRoleMember, true},
+		{MemberRole(""), MemberRoleMember, false},
+		{MemberRole("superuser"), MemberRoleMember, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.role.AtLeast(tt.min); got != tt.want {
+			t.Errorf("%q.AtLeast(%q) = %v, want %v", tt.role, tt.min, got, tt.want)
+		}
+	}
+}
+
+func TestMemberRoleOutranks(t *testing.T) {
+	tests := []struct {
+		role  MemberRole
+		other MemberRole
+		want  bool
+	}{
+		{MemberRoleOwner, MemberRoleAdmin, true},
+		{MemberRoleOwner, MemberRoleMember, true},
+		{MemberRoleOwner, MemberRoleOwner, false},
+		{MemberRoleAdmin, MemberRoleModerator, true},
+		{MemberRoleAdmin, MemberRoleMember, true},
+		{MemberRoleAdmin, MemberRoleAdmin, false},
+		{MemberRoleAdmin, MemberRoleOwner, false},
+		{MemberRoleModerator, MemberRoleMember, true},
+		{MemberRoleModerator, MemberRoleModerator, false},
+		{MemberRoleModerator, MemberRoleAdmin, false},
+		{MemberRoleMember, MemberRoleMember, false},
+		{MemberRoleMember, MemberRole("unknown"), true},
+		{MemberRole("unknown"), MemberRole("unknown"), false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.role.Outranks(tt.other); got != tt.want {
+			t.Errorf("%q.Outranks(%q) = %v, want %v", tt.role, tt.other, got, tt.want)
+		}
+	}
+}
+
+func TestCanModerate(t *testing.T) {
+	tests := map[MemberRole]bool{
+		MemberRoleOwner:       true,
+		MemberRoleAdmin:       true,
+		MemberRoleModerator:   true,
+		MemberRoleMember:      false,
+		MemberRole(""):        false,
+		MemberRole("unknown"): false,
+	}
+
+	for role, want := range tests {
+		if got := CanModerate(role); got != want {
+			t.Errorf("CanModerate(%q) = %v, want %v", role, got, want)
+		}
+	}
+}
+
+func TestMemberRoleIsValid(t *testing.T) {
+	for _, role := range []MemberRole{MemberRoleOwner, MemberRoleAdmin, MemberRoleModerator, MemberRoleMember} {
+		if !role.IsValid() {
+			t.Errorf("%q should be valid", role)
+		}
+	}
+	for _, role := range []MemberRole{"", "Owner", "Moderator"} {
+		if role.IsValid() {
+			t.Errorf("%q should not be valid", role)
+		}
+	}
+}
@@ -9,13 +9,21 @@ import (
 
 // GroupMessage represents a message in a group
 type GroupMessage struct {
-	ID        uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
-	GroupID   uuid.UUID `gorm:"type:uuid;not null;index" json:"group_id"`
-	SenderID  uuid.UUID `gorm:"type:uuid;not null;index" json:"sender_id"`
-	Content   string    `gorm:"type:text;not null" json:"content"` // Encrypted content
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	
+	ID              uuid.UUID       `gorm:"type:uuid;primary_key" json:"id"`
+	GroupID         uuid.UUID       `gorm:"type:uuid;not null;index" json:"group_id"`
+	SenderID        uuid.UUID       `gorm:"type:uuid;not null;index" json:"sender_id"`
+	Content         string          `gorm:"type:text;not null" json:"content"` // Encrypted content
+	ContentType     string          `gorm:"type:varchar(20);default:'text'" json:"content_type"`
+	Priority        MessagePriority `gorm:"type:varchar(10);not null;default:'normal'" json:"priority"`
+	Edited          bool            `gorm:"default:false" json:"edited"`
+	EditedAt        *time.Time      `json:"edited_at"`
+	PreviousContent string          `gorm:"type:text" json:"previous_content"` // Encrypted previous content
+	IsDeleted       bool            `gorm:"default:false" json:"is_deleted"`
+	DeletedAt       *time.Time      `json:"deleted_at"`
+	DeletedBy       *uuid.UUID      `gorm:"type:uuid" json:"deleted_by"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+
 	// Relationships
 	Group  Group `gorm:"foreignKey:GroupID;constraint:OnDelete:CASCADE" json:"group,omitempty"`
 	Sender User  `gorm:"foreignKey:SenderID;constraint:OnDelete:CASCADE" json:"sender,omitempty"`
@@ -26,6 +34,12 @@ func (gm *GroupMessage) BeforeCreate(tx *gorm.DB) error {
 	if gm.ID == uuid.Nil {
 		gm.ID = uuid.New()
 	}
+	if gm.Priority == "" {
+		gm.Priority = MessagePriorityNormal
+	}
+	if gm.ContentType == "" {
+		gm.ContentType = "text"
+	}
 	return nil
 }
 
@@ -36,11 +50,26 @@ func (GroupMessage) TableName() string {
 
 // GroupMessageResponse is the structure returned to clients
 type GroupMessageResponse struct {
-	ID        uuid.UUID  `json:"id"`
-	GroupID   uuid.UUID  `json:"group_id"`
-	SenderID  uuid.UUID  `json:"sender_id"`
-	Content   string     `json:"content"` // Decrypted content
-	CreatedAt time.Time  `json:"created_at"`
-	Sender    PublicUser `json:"sender,omitempty"`
+	ID              uuid.UUID       `json:"id"`
+	GroupID         uuid.UUID       `json:"group_id"`
+	SenderID        uuid.UUID       `json:"sender_id"`
+	Content         string          `json:"content"` // Decrypted content
+	ContentType     string          `json:"content_type"`
+	Priority        MessagePriority `json:"priority"`
+	Edited          bool            `json:"edited"`
+	EditedAt        *time.Time      `json:"edited_at"`
+	PreviousContent string          `json:"previous_content"`
+	IsDeleted       bool            `json:"is_deleted"`
+	DeletedAt       *time.Time      `json:"deleted_at"`
+	DeletedBy       *uuid.UUID      `json:"deleted_by"`
+	Reactions       map[string]int  `json:"reactions,omitempty"` // Emoji -> count, omitted when there are none
+	CreatedAt       time.Time       `json:"created_at"`
+	Sender          PublicUser      `json:"sender,omitempty"`
+	ReadBy          []ReadReceipt   `json:"read_by"`
 }
 
+// GroupMessageSearchResult is a group message matching a full-text search, with the text around the match
+type GroupMessageSearchResult struct {
+	GroupMessageResponse
+	Snippet string `json:"snippet"`
+}
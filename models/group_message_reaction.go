@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// GroupMessageReaction is an emoji reaction by a member on a group message.
+// A member can react with several emoji, but only once with each.
+type GroupMessageReaction struct {
+	ID             uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	GroupMessageID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_group_message_reactions_unique,priority:1" json:"group_message_id"`
+	UserID         uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_group_message_reactions_unique,priority:2" json:"user_id"`
+	Emoji          string    `gorm:"type:varchar(10);not null;uniqueIndex:idx_group_message_reactions_unique,priority:3" json:"emoji"`
+	CreatedAt      time.Time `json:"created_at"`
+
+	// Relationships
+	GroupMessage GroupMessage `gorm:"foreignKey:GroupMessageID;constraint:OnDelete:CASCADE" json:"-"`
+	User         User         `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// BeforeCreate hook to generate UUID before creating a reaction
+func (r *GroupMessageReaction) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+// TableName specifies the table name for GroupMessageReaction model
+func (GroupMessageReaction) TableName() string {
+	return "group_message_reactions"
+}
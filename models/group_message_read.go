@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// GroupMessageRead records that a member has seen a group message
+type GroupMessageRead struct {
+	ID             uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	GroupMessageID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_group_message_reader" json:"group_message_id"`
+	ReaderID       uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_group_message_reader;index" json:"reader_id"`
+	ReadAt         time.Time `gorm:"not null" json:"read_at"`
+
+	// Relationships
+	GroupMessage GroupMessage `gorm:"foreignKey:GroupMessageID;constraint:OnDelete:CASCADE" json:"-"`
+	Reader       User         `gorm:"foreignKey:ReaderID;constraint:OnDelete:CASCADE" json:"reader,omitempty"`
+}
+
+// BeforeCreate hook to generate UUID and read time before creating a read receipt
+func (r *GroupMessageRead) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	if r.ReadAt.IsZero() {
+		r.ReadAt = time.Now()
+	}
+	return nil
+}
+
+// TableName specifies the table name for GroupMessageRead model
+func (GroupMessageRead) TableName() string {
+	return "group_message_reads"
+}
+
+// ReadReceipt tells who read a group message and when
+type ReadReceipt struct {
+	Reader PublicUser `json:"reader"`
+	ReadAt time.Time  `json:"read_at"`
+}
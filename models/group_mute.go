@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// GroupMute silences notifications of a group for a member, indefinitely when MutedUntil is nil
+type GroupMute struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
+	UserID     uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_group_mute_user_group" json:"user_id"`
+	GroupID    uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_group_mute_user_group;index" json:"group_id"`
+	MutedUntil *time.Time `gorm:"index" json:"muted_until"`
+	CreatedAt  time.Time  `json:"created_at"`
+
+	// Relationships
+	User  User  `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+	Group Group `gorm:"foreignKey:GroupID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// BeforeCreate hook to generate UUID before creating a group mute
+func (m *GroupMute) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == uuid.Nil {
+		m.ID = uuid.New()
+	}
+	return nil
+}
+
+// TableName specifies the table name for GroupMute model
+func (GroupMute) TableName() string {
+	return "group_mutes"
+}
+
+// IsActive reports whether the mute is still in effect at the given time
+func (m *GroupMute) IsActive(now time.Time) bool {
+	return m.MutedUntil == nil || m.MutedUntil.After(now)
+}
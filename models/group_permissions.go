@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GroupPermissions holds the minimum role required for each action in a group
+type GroupPermissions struct {
+	GroupID       uuid.UUID  `gorm:"type:uuid;primary_key" json:"group_id"`
+	SendMessages  MemberRole `gorm:"type:varchar(20);not null;default:'member'" json:"send_messages"`
+	AddMembers    MemberRole `gorm:"type:varchar(20);not null;default:'member'" json:"add_members"`
+	PinMessages   MemberRole `gorm:"type:varchar(20);not null;default:'member'" json:"pin_messages"`
+	EditGroupInfo MemberRole `gorm:"type:varchar(20);not null;default:'member'" json:"edit_group_info"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+
+	// Relationships
+	Group Group `gorm:"foreignKey:GroupID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// DefaultGroupPermissions returns the permissions of a group that never customised them
+func DefaultGroupPermissions(groupID uuid.UUID) GroupPermissions {
+	return GroupPermissions{
+		GroupID:       groupID,
+		SendMessages:  MemberRoleMember,
+		AddMembers:    MemberRoleMember,
+		PinMessages:   MemberRoleMember,
+		EditGroupInfo: MemberRoleMember,
+	}
+}
+
+// TableName specifies the table name for GroupPermissions model
+func (GroupPermissions) TableName() string {
+	return "group_permissions"
+}
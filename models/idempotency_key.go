@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IdempotencyKey caches the response of a non-idempotent request so client retries can be replayed
+type IdempotencyKey struct {
+	Key          string    `gorm:"type:varchar(128);primary_key" json:"key"` // "<user_id>:<Idempotency-Key header>"
+	UserID       uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	ResponseBody *string   `gorm:"type:text" json:"-"` // Encrypted like message content; nil while the first request is still being handled
+	ExpiresAt    time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for IdempotencyKey model
+func (IdempotencyKey) TableName() string {
+	return "idempotency_keys"
+}
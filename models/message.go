@@ -1,6 +1,7 @@
 package models
 
 import (
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
@@ -9,24 +10,62 @@ import (
 
 // Message represents a direct message between two users
 type Message struct {
-	ID              uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
-	SenderID        uuid.UUID  `gorm:"type:uuid;not null;index" json:"sender_id"`
-	ReceiverID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"receiver_id"`
-	Content         string     `gorm:"type:text;not null" json:"content"` // Encrypted content
-	IsRead          bool       `gorm:"default:false" json:"is_read"`
-	ReadAt          *time.Time `json:"read_at"`
-	IsDeleted       bool       `gorm:"default:false" json:"is_deleted"`
-	DeletedAt       *time.Time `json:"deleted_at"`
-	DeletedBy       *uuid.UUID `gorm:"type:uuid" json:"deleted_by"`
-	Edited          bool       `gorm:"default:false" json:"edited"`
-	EditedAt        *time.Time `json:"edited_at"`
-	PreviousContent string     `gorm:"type:text" json:"previous_content"` // Encrypted previous content
-	CreatedAt       time.Time  `json:"created_at"`
-	UpdatedAt       time.Time  `json:"updated_at"`
+	ID              uuid.UUID       `gorm:"type:uuid;primary_key" json:"id"`
+	SenderID        uuid.UUID       `gorm:"type:uuid;not null;index;index:idx_messages_conversation,priority:1;index:idx_msg_sender_created,priority:1" json:"sender_id"`
+	ReceiverID      uuid.UUID       `gorm:"type:uuid;not null;index;index:idx_messages_conversation,priority:2;index:idx_msg_receiver_created,priority:1" json:"receiver_id"`
+	Content         string          `gorm:"type:text;not null" json:"content"` // Encrypted content
+	ContentType     string          `gorm:"type:varchar(20);default:'text'" json:"content_type"`
+	IsRead          bool            `gorm:"default:false" json:"is_read"`
+	ReadAt          *time.Time      `json:"read_at"`
+	DeliveredAt     *time.Time      `json:"delivered_at"`
+	IsDeleted       bool            `gorm:"default:false" json:"is_deleted"`
+	DeletedAt       *time.Time      `json:"deleted_at"`
+	DeletedBy       *uuid.UUID      `gorm:"type:uuid" json:"deleted_by"`
+	Edited          bool            `gorm:"default:false" json:"edited"`
+	EditedAt        *time.Time      `json:"edited_at"`
+	PreviousContent string          `gorm:"type:text" json:"previous_content"` // Encrypted previous content
+	Priority        MessagePriority `gorm:"type:varchar(10);not null;default:'normal'" json:"priority"`
+	AttachmentURL   string          `gorm:"type:varchar(512)" json:"attachment_url,omitempty"`
+	AttachmentType  string          `gorm:"type:varchar(20)" json:"attachment_type,omitempty"`
+	ReplyToID       *uuid.UUID      `gorm:"type:uuid;index" json:"reply_to_id"`
+	ReplyPreview    string          `gorm:"type:text" json:"reply_preview"` // Encrypted start of the replied-to message, as it was when replying
+	IsForwarded     bool            `gorm:"default:false" json:"is_forwarded"`
+	ForwardedFromID *uuid.UUID      `gorm:"type:uuid" json:"forwarded_from_id"` // The message this one is a forwarded copy of
+	CreatedAt       time.Time       `gorm:"index:idx_messages_conversation,priority:3,sort:desc;index:idx_msg_sender_created,priority:2,sort:desc;index:idx_msg_receiver_created,priority:2,sort:desc" json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
 
 	// Relationships
-	Sender   User `gorm:"foreignKey:SenderID;constraint:OnDelete:CASCADE" json:"sender,omitempty"`
-	Receiver User `gorm:"foreignKey:ReceiverID;constraint:OnDelete:CASCADE" json:"receiver,omitempty"`
+	Sender   User     `gorm:"foreignKey:SenderID;constraint:OnDelete:CASCADE" json:"sender,omitempty"`
+	Receiver User     `gorm:"foreignKey:ReceiverID;constraint:OnDelete:CASCADE" json:"receiver,omitempty"`
+	ReplyTo  *Message `gorm:"foreignKey:ReplyToID;constraint:OnDelete:SET NULL" json:"-"`
+}
+
+// ReplyPreviewLength is the number of characters of a replied-to message kept with the reply
+const ReplyPreviewLength = 100
+
+// Attachment types, derived from the uploaded file's MIME type
+const (
+	AttachmentTypeImage    = "image"
+	AttachmentTypeAudio    = "audio"
+	AttachmentTypeDocument = "document" // PDF
+)
+
+// MessagePriority controls how prominently clients display a message
+type MessagePriority string
+
+const (
+	MessagePriorityNormal MessagePriority = "normal"
+	MessagePriorityHigh   MessagePriority = "high"
+	MessagePriorityUrgent MessagePriority = "urgent"
+)
+
+// IsValid reports whether p is a known priority
+func (p MessagePriority) IsValid() bool {
+	switch p {
+	case MessagePriorityNormal, MessagePriorityHigh, MessagePriorityUrgent:
+		return true
+	}
+	return false
 }
 
 // BeforeCreate hook to generate UUID before creating message
@@ -34,6 +73,12 @@ func (m *Message) BeforeCreate(tx *gorm.DB) error {
 	if m.ID == uuid.Nil {
 		m.ID = uuid.New()
 	}
+	if m.Priority == "" {
+		m.Priority = MessagePriorityNormal
+	}
+	if m.ContentType == "" {
+		m.ContentType = "text"
+	}
 	return nil
 }
 
@@ -44,18 +89,138 @@ func (Message) TableName() string {
 
 // MessageResponse is the structure returned to clients (with decrypted content)
 type MessageResponse struct {
-	ID              uuid.UUID  `json:"id"`
-	SenderID        uuid.UUID  `json:"sender_id"`
-	ReceiverID      uuid.UUID  `json:"receiver_id"`
-	Content         string     `json:"content"` // Decrypted content
-	IsRead          bool       `json:"is_read"`
-	ReadAt          *time.Time `json:"read_at"`
-	IsDeleted       bool       `json:"is_deleted"`
-	DeletedAt       *time.Time `json:"deleted_at"`
-	DeletedBy       *uuid.UUID `json:"deleted_by"`
-	Edited          bool       `json:"edited"`
-	EditedAt        *time.Time `json:"edited_at"`
-	PreviousContent string     `json:"previous_content"`
-	CreatedAt       time.Time  `json:"created_at"`
-	Sender          PublicUser `json:"sender,omitempty"`
+	ID              uuid.UUID        `json:"id"`
+	SenderID        uuid.UUID        `json:"sender_id"`
+	ReceiverID      uuid.UUID        `json:"receiver_id"`
+	Content         string           `json:"content"` // Decrypted content
+	ContentType     string           `json:"content_type"`
+	IsRead          bool             `json:"is_read"`
+	ReadAt          *time.Time       `json:"read_at"`
+	IsDelivered     bool             `json:"is_delivered"`
+	DeliveredAt     *time.Time       `json:"delivered_at"`
+	IsDeleted       bool             `json:"is_deleted"`
+	DeletedAt       *time.Time       `json:"deleted_at"`
+	DeletedBy       *uuid.UUID       `json:"deleted_by"`
+	Edited          bool             `json:"edited"`
+	EditedAt        *time.Time       `json:"edited_at"`
+	PreviousContent string           `json:"previous_content"`
+	Priority        MessagePriority  `json:"priority"`
+	AttachmentURL   string           `json:"attachment_url,omitempty"`
+	AttachmentType  string           `json:"attachment_type,omitempty"`
+	Reactions       map[string]int   `json:"reactions,omitempty"` // Emoji -> count, omitted when there are none
+	ReplyTo         *MessageResponse `json:"reply_to,omitempty"`  // The message replied to; content is its preview
+	IsForwarded     bool             `json:"is_forwarded"`
+	ForwardedFromID *uuid.UUID       `json:"forwarded_from_id,omitempty"`
+	CreatedAt       time.Time        `json:"created_at"`
+	Sender          PublicUser       `json:"sender,omitempty"`
+}
+
+// MessageSearchResult is a message matching a full-text search, with the other participant of its conversation
+type MessageSearchResult struct {
+	MessageResponse
+	Partner PublicUser `json:"partner"`
+}
+
+// MessageStatus is the delivery state of a direct message exposed by API v2
+type MessageStatus string
+
+const (
+	MessageStatusSent      MessageStatus = "sent"
+	MessageStatusDelivered MessageStatus = "delivered"
+	MessageStatusRead      MessageStatus = "read"
+)
+
+// StatusOf derives a message's delivery state from its read flag and delivery time
+func StatusOf(isRead bool, deliveredAt *time.Time) MessageStatus {
+	switch {
+	case isRead:
+		return MessageStatusRead
+	case deliveredAt != nil:
+		return MessageStatusDelivered
+	default:
+		return MessageStatusSent
+	}
+}
+
+// ReactionSummary aggregates reactions on a message by emoji
+type ReactionSummary struct {
+	Emoji string `json:"emoji"`
+	Count int    `json:"count"`
+}
+
+// ToReactionSummaries converts emoji counts to summaries, most used first and then by emoji
+func ToReactionSummaries(counts map[string]int) []ReactionSummary {
+	summaries := make([]ReactionSummary, 0, len(counts))
+	for emoji, count := range counts {
+		summaries = append(summaries, ReactionSummary{Emoji: emoji, Count: count})
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Count != summaries[j].Count {
+			return summaries[i].Count > summaries[j].Count
+		}
+		return summaries[i].Emoji < summaries[j].Emoji
+	})
+	return summaries
+}
+
+// MessageResponseV2 is the API v2 message shape: status replaces is_read and reactions are included
+type MessageResponseV2 struct {
+	ID              uuid.UUID          `json:"id"`
+	SenderID        uuid.UUID          `json:"sender_id"`
+	ReceiverID      uuid.UUID          `json:"receiver_id"`
+	Content         string             `json:"content"`
+	ContentType     string             `json:"content_type"`
+	Status          MessageStatus      `json:"status"`
+	ReadAt          *time.Time         `json:"read_at"`
+	DeliveredAt     *time.Time         `json:"delivered_at"`
+	Reactions       []ReactionSummary  `json:"reactions"`
+	IsDeleted       bool               `json:"is_deleted"`
+	DeletedAt       *time.Time         `json:"deleted_at"`
+	DeletedBy       *uuid.UUID         `json:"deleted_by"`
+	Edited          bool               `json:"edited"`
+	EditedAt        *time.Time         `json:"edited_at"`
+	PreviousContent string             `json:"previous_content"`
+	Priority        MessagePriority    `json:"priority"`
+	AttachmentURL   string             `json:"attachment_url,omitempty"`
+	AttachmentType  string             `json:"attachment_type,omitempty"`
+	ReplyTo         *MessageResponseV2 `json:"reply_to,omitempty"`
+	IsForwarded     bool               `json:"is_forwarded"`
+	ForwardedFromID *uuid.UUID         `json:"forwarded_from_id,omitempty"`
+	CreatedAt       time.Time          `json:"created_at"`
+	Sender          PublicUser         `json:"sender,omitempty"`
+}
+
+// ToV2 converts a v1 message response to the v2 shape
+func (m MessageResponse) ToV2() MessageResponseV2 {
+	var replyTo *MessageResponseV2
+	if m.ReplyTo != nil {
+		reply := m.ReplyTo.ToV2()
+		replyTo = &reply
+	}
+
+	return MessageResponseV2{
+		ID:              m.ID,
+		SenderID:        m.SenderID,
+		ReceiverID:      m.ReceiverID,
+		Content:         m.Content,
+		ContentType:     m.ContentType,
+		Status:          StatusOf(m.IsRead, m.DeliveredAt),
+		ReadAt:          m.ReadAt,
+		DeliveredAt:     m.DeliveredAt,
+		Reactions:       ToReactionSummaries(m.Reactions),
+		IsDeleted:       m.IsDeleted,
+		DeletedAt:       m.DeletedAt,
+		DeletedBy:       m.DeletedBy,
+		Edited:          m.Edited,
+		EditedAt:        m.EditedAt,
+		PreviousContent: m.PreviousContent,
+		Priority:        m.Priority,
+		AttachmentURL:   m.AttachmentURL,
+		AttachmentType:  m.AttachmentType,
+		ReplyTo:         replyTo,
+		IsForwarded:     m.IsForwarded,
+		ForwardedFromID: m.ForwardedFromID,
+		CreatedAt:       m.CreatedAt,
+		Sender:          m.Sender,
+	}
 }
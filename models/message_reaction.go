@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MessageReaction is an emoji reaction by a user on a direct message.
+// A user can react with several emoji, but only once with each.
+type MessageReaction struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	MessageID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_message_reactions_unique,priority:1" json:"message_id"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_message_reactions_unique,priority:2" json:"user_id"`
+	Emoji     string    `gorm:"type:varchar(10);not null;uniqueIndex:idx_message_reactions_unique,priority:3" json:"emoji"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Relationships
+	Message Message `gorm:"foreignKey:MessageID;constraint:OnDelete:CASCADE" json:"-"`
+	User    User    `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// BeforeCreate hook to generate UUID before creating a reaction
+func (r *MessageReaction) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+// TableName specifies the table name for MessageReaction model
+func (MessageReaction) TableName() string {
+	return "message_reactions"
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStatusOf(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		isRead      bool
+		deliveredAt *time.Time
+		want        MessageStatus
+	}{
+		{false, nil, MessageStatusSent},
+		{false, &now, MessageStatusDelivered},
+		{true, &now, MessageStatusRead},
+		{true, nil, MessageStatusRead}, // Read before delivery receipts existed
+	}
+
+	for _, tt := range tests {
+		if got := StatusOf(tt.isRead, tt.deliveredAt); got != tt.want {
+			t.Errorf("StatusOf(%v, %v) = %q, want %q", tt.isRead, tt.deliveredAt, got, tt.want)
+		}
+	}
+}
+
+func TestToReactionSummaries(t *testing.T) {
+	got := ToReactionSummaries(map[string]int{"😂": 1, "👍": 3, "❤️": 1})
+	want := []ReactionSummary{{"👍", 3}, {"❤️", 1}, {"😂", 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ToReactionSummaries() = %v, want %v", got, want)
+	}
+
+	if got := ToReactionSummaries(nil); got == nil || len(got) != 0 {
+		t.Errorf("ToReactionSummaries(nil) = %#v, want an empty slice", got)
+	}
+}
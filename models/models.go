@@ -0,0 +1,31 @@
+package models
+
+// All returns every model managed by AutoMigrate, parents before the tables that reference them
+func All() []interface{} {
+	return []interface{}{
+		&User{},
+		&UserBlock{},
+		&Message{},
+		&MessageReaction{},
+		&ScheduledMessage{},
+		&Group{},
+		&GroupMember{},
+		&GroupMessage{},
+		&GroupMessageRead{},
+		&GroupMessageReaction{},
+		&ArchivedGroup{},
+		&GroupMute{},
+		&ConversationDraft{},
+		&ConversationLabel{},
+		&MutedConversation{},
+		&ArchivedConversation{},
+		&GroupDraft{},
+		&GroupPermissions{},
+		&GroupInvite{},
+		&IdempotencyKey{},
+		&Notification{},
+		&NotificationPreference{},
+		&ContentFilter{},
+		&PasswordResetToken{},
+	}
+}
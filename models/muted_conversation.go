@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MutedConversation silences notifications of a direct conversation for a user, indefinitely when MutedUntil is nil
+type MutedConversation struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
+	UserID     uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_muted_conversation_user_partner" json:"user_id"`
+	PartnerID  uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_muted_conversation_user_partner" json:"partner_id"`
+	MutedUntil *time.Time `gorm:"index" json:"muted_until"`
+	CreatedAt  time.Time  `json:"created_at"`
+
+	// Relationships
+	User    User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+	Partner User `gorm:"foreignKey:PartnerID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// BeforeCreate hook to generate UUID before creating a conversation mute
+func (m *MutedConversation) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == uuid.Nil {
+		m.ID = uuid.New()
+	}
+	return nil
+}
+
+// TableName specifies the table name for MutedConversation model
+func (MutedConversation) TableName() string {
+	return "muted_conversations"
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// NotificationTypes lists every notification type, in the order preferences are returned
+var NotificationTypes = []NotificationType{
+	NotificationTypeMessage,
+	NotificationTypeGroupMessage,
+	NotificationTypeGroupInvite,
+	NotificationTypeSystem,
+}
+
+// IsValid reports whether t is a known notification type
+func (t NotificationType) IsValid() bool {
+	for _, known := range NotificationTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// NotificationPreference controls the push notifications a user gets for one notification type.
+// Types without a stored preference are pushed with sound.
+type NotificationPreference struct {
+	ID           uuid.UUID        `gorm:"type:uuid;primary_key" json:"-"`
+	UserID       uuid.UUID        `gorm:"type:uuid;not null;uniqueIndex:idx_notification_pref_user_type" json:"user_id"`
+	Type         NotificationType `gorm:"type:varchar(50);not null;uniqueIndex:idx_notification_pref_user_type" json:"type"`
+	Enabled      bool             `gorm:"not null" json:"enabled"`
+	SoundEnabled bool             `gorm:"not null" json:"sound_enabled"`
+	UpdatedAt    time.Time        `json:"updated_at"`
+
+	// Relationships
+	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// DefaultNotificationPreference is the preference of a user who never changed it for a type
+func DefaultNotificationPreference(userID uuid.UUID, ntype NotificationType) NotificationPreference {
+	return NotificationPreference{
+		UserID:       userID,
+		Type:         ntype,
+		Enabled:      true,
+		SoundEnabled: true,
+	}
+}
+
+// BeforeCreate hook to generate UUID before creating a notification preference
+func (p *NotificationPreference) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return nil
+}
+
+// TableName specifies the table name for NotificationPreference model
+func (NotificationPreference) TableName() string {
+	return "notification_preferences"
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PasswordResetToken is a single-use token emailed to a user who forgot their password.
+// Only the SHA-256 hash of the token is stored, so a database leak cannot be used to reset accounts.
+type PasswordResetToken struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	TokenHash string     `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null;index" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// BeforeCreate hook to generate UUID
+func (t *PasswordResetToken) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
+
+// TableName specifies the table name for PasswordResetToken model
+func (PasswordResetToken) TableName() string {
+	return "password_reset_tokens"
+}
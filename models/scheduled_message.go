@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ScheduledMessageStatus is where a scheduled message is in its lifecycle
+type ScheduledMessageStatus string
+
+const (
+	ScheduledMessagePending   ScheduledMessageStatus = "pending"
+	ScheduledMessageSent      ScheduledMessageStatus = "sent"
+	ScheduledMessageCancelled ScheduledMessageStatus = "cancelled"
+	ScheduledMessageFailed    ScheduledMessageStatus = "failed" // Sending was attempted and rejected, e.g. the receiver blocked the sender
+)
+
+// ScheduledMessage is a direct message to be sent at a later time
+type ScheduledMessage struct {
+	ID           uuid.UUID              `gorm:"type:uuid;primary_key" json:"id"`
+	SenderID     uuid.UUID              `gorm:"type:uuid;not null;index" json:"sender_id"`
+	ReceiverID   uuid.UUID              `gorm:"type:uuid;not null" json:"receiver_id"`
+	Content      string                 `gorm:"type:text;not null" json:"-"` // Encrypted content
+	ScheduledFor time.Time              `gorm:"not null;index:idx_scheduled_messages_due,priority:2" json:"scheduled_for"`
+	Status       ScheduledMessageStatus `gorm:"type:varchar(10);not null;default:'pending';index:idx_scheduled_messages_due,priority:1" json:"status"`
+	MessageID    *uuid.UUID             `gorm:"type:uuid" json:"message_id"` // The message it became once sent
+	Error        string                 `gorm:"type:text" json:"error,omitempty"`
+	CreatedAt    time.Time              `json:"created_at"`
+	UpdatedAt    time.Time              `json:"updated_at"`
+
+	// Relationships
+	Sender   User `gorm:"foreignKey:SenderID;constraint:OnDelete:CASCADE" json:"-"`
+	Receiver User `gorm:"foreignKey:ReceiverID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// BeforeCreate hook to generate UUID before creating a scheduled message
+func (m *ScheduledMessage) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == uuid.Nil {
+		m.ID = uuid.New()
+	}
+	if m.Status == "" {
+		m.Status = ScheduledMessagePending
+	}
+	return nil
+}
+
+// TableName specifies the table name for ScheduledMessage model
+func (ScheduledMessage) TableName() string {
+	return "scheduled_messages"
+}
+
+// ScheduledMessageResponse is a scheduled message as returned to its sender
+type ScheduledMessageResponse struct {
+	ID           uuid.UUID              `json:"id"`
+	ReceiverID   uuid.UUID              `json:"receiver_id"`
+	Content      string                 `json:"content"` // Decrypted content
+	ScheduledFor time.Time              `json:"scheduled_for"`
+	Status       ScheduledMessageStatus `json:"status"`
+	CreatedAt    time.Time              `json:"created_at"`
+}
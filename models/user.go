@@ -9,19 +9,60 @@ import (
 
 // User represents a user in the system
 type User struct {
-	ID        uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
-	Username  string    `gorm:"type:varchar(100);uniqueIndex:idx_username_lower;not null" json:"username"`
-	Email     string    `gorm:"type:varchar(255);uniqueIndex;not null" json:"email"`
-	Phone     string    `gorm:"type:varchar(20);index" json:"phone"`
-	Password  string    `gorm:"type:varchar(255);not null" json:"-"` // Never expose password in JSON
-	Avatar    string    `gorm:"type:varchar(500)" json:"avatar"`
-	Language  string    `gorm:"type:varchar(10);default:'en'" json:"language"` // e.g., 'en', 'fr', 'es'
-	DeviceToken string  `gorm:"type:varchar(500)" json:"-"` // For push notifications
-	Platform    string  `gorm:"type:varchar(20)" json:"-"` // 'ios', 'android'
-	IsOnline    bool    `gorm:"default:false" json:"is_online"`
-	LastSeen    *time.Time `json:"last_seen"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID                uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
+	Username          string     `gorm:"type:varchar(100);uniqueIndex:idx_username_lower,where:deleted_at IS NULL;not null" json:"username"`
+	UsernameChangedAt *time.Time `json:"-"` // Last username change, nil if never changed; see UsernameChangeCooldown
+	Email             string     `gorm:"type:varchar(255);uniqueIndex:idx_users_email,where:deleted_at IS NULL;not null" json:"email"`
+	Phone             string     `gorm:"type:varchar(20);index" json:"phone"`
+	PhoneVisible      bool       `gorm:"not null;default:true" json:"phone_visible"` // Privacy: findable by phone number
+	Password          string     `gorm:"type:varchar(255);not null" json:"-"`        // Never expose password in JSON
+	Avatar            string     `gorm:"type:varchar(500)" json:"avatar"`
+	AvatarThumb       string     `gorm:"type:varchar(500)" json:"avatar_thumb"` // 64x64 version of an uploaded avatar
+	Bio               string     `gorm:"type:varchar(500)" json:"bio"`
+	GoogleID          string     `gorm:"type:varchar(255);index" json:"-"`              // Google account subject, set on Google sign-in
+	TOTPSecret        string     `gorm:"type:text" json:"-"`                            // Encrypted TOTP secret, set by 2FA setup
+	TOTPEnabled       bool       `gorm:"not null;default:false" json:"-"`               // Login requires a TOTP code once the secret is verified
+	Language          string     `gorm:"type:varchar(10);default:'en'" json:"language"` // e.g., 'en', 'fr', 'es'
+	Role              UserRole   `gorm:"type:varchar(20);not null;default:'user'" json:"role"`
+	DeviceToken       string     `gorm:"type:varchar(500)" json:"-"` // For push notifications
+	Platform          string     `gorm:"type:varchar(20)" json:"-"`  // 'ios', 'android'
+	IsOnline          bool       `gorm:"default:false" json:"is_online"`
+	DNDEnabled        bool       `gorm:"not null;default:false" json:"dnd_enabled"`          // Do not disturb: no pushes between the hours below
+	DNDStartHour      int        `gorm:"not null;default:0" json:"dnd_start_hour"`           // 0-23, inclusive
+	DNDEndHour        int        `gorm:"not null;default:0" json:"dnd_end_hour"`             // 0-23, exclusive; before the start hour when crossing midnight
+	DNDTimezone       string     `gorm:"type:varchar(64);default:'UTC'" json:"dnd_timezone"` // IANA name, e.g. 'Europe/Paris'
+	LastSeen          *time.Time `json:"last_seen"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+	DeletedAt         *time.Time `gorm:"index" json:"-"` // Set when the account is deleted; the row is purged 30 days later
+}
+
+// UserRole grants access to administrative endpoints
+type UserRole string
+
+const (
+	UserRoleUser  UserRole = "user"
+	UserRoleAdmin UserRole = "admin"
+)
+
+// DeletedUsername replaces the username of deleted accounts in public views
+const DeletedUsername = "[deleted user]"
+
+// UsernameChangeCooldown is how long a user must wait after changing their username before changing it again
+const UsernameChangeCooldown = 30 * 24 * time.Hour
+
+// UsernameChangeableAt returns when the user may change their username again, or nil if they never changed it
+func (u *User) UsernameChangeableAt() *time.Time {
+	if u.UsernameChangedAt == nil {
+		return nil
+	}
+	at := u.UsernameChangedAt.Add(UsernameChangeCooldown)
+	return &at
+}
+
+// IsAdmin reports whether the user has the admin role
+func (u *User) IsAdmin() bool {
+	return u.Role == UserRoleAdmin
 }
 
 // BeforeCreate hook to generate UUID before creating user
@@ -29,6 +70,9 @@ func (u *User) BeforeCreate(tx *gorm.DB) error {
 	if u.ID == uuid.Nil {
 		u.ID = uuid.New()
 	}
+	if u.Role == "" {
+		u.Role = UserRoleUser
+	}
 	return nil
 }
 
@@ -39,28 +83,88 @@ func (User) TableName() string {
 
 // PublicUser returns a user object safe for public viewing (without sensitive data)
 type PublicUser struct {
-	ID        uuid.UUID  `json:"id"`
-	Username  string     `json:"username"`
-	Email     string     `json:"email"`
-	Phone     string     `json:"phone"`
-	Avatar    string     `json:"avatar"`
-	Language  string     `json:"language"`
-	IsOnline  bool       `json:"is_online"`
-	LastSeen  *time.Time `json:"last_seen"`
-	CreatedAt time.Time  `json:"created_at"`
+	ID                   uuid.UUID  `json:"id"`
+	Username             string     `json:"username"`
+	UsernameChangeableAt *time.Time `json:"username_changeable_at,omitempty"` // When the username may be changed again; absent if it never was
+	Email                string     `json:"email"`
+	Phone                string     `json:"phone"`
+	Avatar               string     `json:"avatar"`
+	AvatarThumb          string     `json:"avatar_thumb"`
+	Bio                  string     `json:"bio"`
+	Language             string     `json:"language"`
+	IsOnline             bool       `json:"is_online"`
+	LastSeen             *time.Time `json:"last_seen"`
+	CreatedAt            time.Time  `json:"created_at"`
 }
 
 // ToPublicUser converts User to PublicUser
 func (u *User) ToPublicUser() PublicUser {
+	if u.DeletedAt != nil {
+		// Keep the ID so clients can group the user's old messages, but nothing identifying
+		return PublicUser{ID: u.ID, Username: DeletedUsername}
+	}
 	return PublicUser{
-		ID:        u.ID,
-		Username:  u.Username,
-		Email:     u.Email,
-		Phone:     u.Phone,
-		Avatar:    u.Avatar,
-		Language:  u.Language,
-		IsOnline:  u.IsOnline,
-		LastSeen:  u.LastSeen,
-		CreatedAt: u.CreatedAt,
+		ID:                   u.ID,
+		Username:             u.Username,
+		UsernameChangeableAt: u.UsernameChangeableAt(),
+		Email:                u.Email,
+		Phone:                u.Phone,
+		Avatar:               u.Avatar,
+		AvatarThumb:          u.AvatarThumb,
+		Bio:                  u.Bio,
+		Language:             u.Language,
+		IsOnline:             u.IsOnline,
+		LastSeen:             u.LastSeen,
+		CreatedAt:            u.CreatedAt,
+	}
+}
+
+// AdminUser is the full account view returned to admins for support. The password hash is
+// never exposed; PasswordScheme only says which algorithm it uses.
+type AdminUser struct {
+	ID             uuid.UUID  `json:"id"`
+	Username       string     `json:"username"`
+	Email          string     `json:"email"`
+	Phone          string     `json:"phone"`
+	PhoneVisible   bool       `json:"phone_visible"`
+	Avatar         string     `json:"avatar"`
+	AvatarThumb    string     `json:"avatar_thumb"`
+	Bio            string     `json:"bio"`
+	Language       string     `json:"language"`
+	Role           UserRole   `json:"role"`
+	GoogleLinked   bool       `json:"google_linked"`
+	TOTPEnabled    bool       `json:"totp_enabled"`
+	PasswordScheme string     `json:"password_scheme"` // argon2id, bcrypt or empty for Google-only accounts
+	DeviceToken    string     `json:"device_token"`
+	Platform       string     `json:"platform"`
+	IsOnline       bool       `json:"is_online"`
+	LastSeen       *time.Time `json:"last_seen"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	DeletedAt      *time.Time `json:"deleted_at,omitempty"`
+}
+
+// ToAdminUser converts User to AdminUser; the caller fills in PasswordScheme
+func (u *User) ToAdminUser() AdminUser {
+	return AdminUser{
+		ID:           u.ID,
+		Username:     u.Username,
+		Email:        u.Email,
+		Phone:        u.Phone,
+		PhoneVisible: u.PhoneVisible,
+		Avatar:       u.Avatar,
+		AvatarThumb:  u.AvatarThumb,
+		Bio:          u.Bio,
+		Language:     u.Language,
+		Role:         u.Role,
+		GoogleLinked: u.GoogleID != "",
+		TOTPEnabled:  u.TOTPEnabled,
+		DeviceToken:  u.DeviceToken,
+		Platform:     u.Platform,
+		IsOnline:     u.IsOnline,
+		LastSeen:     u.LastSeen,
+		CreatedAt:    u.CreatedAt,
+		UpdatedAt:    u.UpdatedAt,
+		DeletedAt:    u.DeletedAt,
 	}
 }
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// UserBlock records that BlockerID blocked BlockedID. Blocked users can't message each other
+// in either direction, and the blocker no longer shows up in the blocked user's listings.
+type UserBlock struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	BlockerID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_user_blocks_unique,priority:1" json:"blocker_id"`
+	BlockedID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_user_blocks_unique,priority:2;index" json:"blocked_id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Relationships
+	Blocker User `gorm:"foreignKey:BlockerID;constraint:OnDelete:CASCADE" json:"-"`
+	Blocked User `gorm:"foreignKey:BlockedID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// BeforeCreate hook to generate UUID before creating a block
+func (b *UserBlock) BeforeCreate(tx *gorm.DB) error {
+	if b.ID == uuid.Nil {
+		b.ID = uuid.New()
+	}
+	return nil
+}
+
+// TableName specifies the table name for UserBlock model
+func (UserBlock) TableName() string {
+	return "user_blocks"
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestToPublicUserHidesDeletedAccounts(t *testing.T) {
+	user := User{
+		ID:       uuid.New(),
+		Username: "alice",
+		Email:    "alice@example.com",
+		Phone:    "+261340000000",
+		Avatar:   "https://example.com/alice.png",
+		Bio:      "hello",
+	}
+	if got := user.ToPublicUser(); got.Username != "alice" || got.Email != user.Email {
+		t.Fatalf("unexpected public user %+v", got)
+	}
+
+	deletedAt := time.Now()
+	user.DeletedAt = &deletedAt
+	got := user.ToPublicUser()
+	if got != (PublicUser{ID: user.ID, Username: DeletedUsername}) {
+		t.Fatalf("expected only the ID and %q, got %+v", DeletedUsername, got)
+	}
+}
+
+func TestUsernameChangeableAt(t *testing.T) {
+	user := User{ID: uuid.New(), Username: "alice"}
+	if at := user.ToPublicUser().UsernameChangeableAt; at != nil {
+		t.Fatalf("expected no cooldown for a username never changed, got %s", at)
+	}
+
+	changedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	user.UsernameChangedAt = &changedAt
+	want := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	if at := user.ToPublicUser().UsernameChangeableAt; at == nil || !at.Equal(want) {
+		t.Fatalf("expected the username to be changeable at %s, got %v", want, at)
+	}
+}
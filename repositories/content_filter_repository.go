@@ -0,0 +1,35 @@
+package repositories
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"mms-backend/models"
+)
+
+// ContentFilterRepository handles database operations for content filters
+type ContentFilterRepository struct {
+	db *gorm.DB
+}
+
+// NewContentFilterRepository creates a new content filter repository
+func NewContentFilterRepository(db *gorm.DB) *ContentFilterRepository {
+	return &ContentFilterRepository{db: db}
+}
+
+// Create stores a new content filter
+func (r *ContentFilterRepository) Create(filter *models.ContentFilter) error {
+	return r.db.Create(filter).Error
+}
+
+// List returns every content filter, oldest first
+func (r *ContentFilterRepository) List() ([]models.ContentFilter, error) {
+	var filters []models.ContentFilter
+	err := r.db.Order("created_at").Find(&filters).Error
+	return filters, err
+}
+
+// Delete removes a content filter and reports how many rows were deleted
+func (r *ContentFilterRepository) Delete(id uuid.UUID) (int64, error) {
+	result := r.db.Where("id = ?", id).Delete(&models.ContentFilter{})
+	return result.RowsAffected, result.Error
+}
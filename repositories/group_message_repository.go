@@ -2,9 +2,11 @@ package repositories
 
 import (
 	"errors"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"mms-backend/models"
 )
 
@@ -48,6 +50,97 @@ func (r *GroupMessageRepository) GetGroupMessages(groupID uuid.UUID, limit, offs
 	return messages, err
 }
 
+// GetGroupMessagesSince returns the non-deleted messages others posted, after the direct or group
+// message since, in the groups userID belongs to, oldest first, up to MaxMessagesSince. Messages
+// from before the user joined a group are left out.
+func (r *GroupMessageRepository) GetGroupMessagesSince(userID, since uuid.UUID) ([]models.GroupMessage, error) {
+	var messages []models.GroupMessage
+	err := r.db.Preload("Sender").
+		Joins("JOIN group_members ON group_members.group_id = group_messages.group_id AND group_members.user_id = ?", userID).
+		Where("group_messages.sender_id <> ?", userID).
+		Where("group_messages.created_at >= group_members.joined_at").
+		Where("group_messages.is_deleted = ?", false).
+		Where(createdAfterMessage("group_messages.created_at"), since, since).
+		Order("group_messages.created_at ASC, group_messages.id ASC").
+		Limit(MaxMessagesSince).
+		Find(&messages).Error
+	return messages, err
+}
+
+// UpdateSearchVector indexes text as the searchable content of a group message; empty text removes it from search
+func (r *GroupMessageRepository) UpdateSearchVector(messageID uuid.UUID, text string) error {
+	return r.db.Exec("UPDATE group_messages SET search_vector = to_tsvector(?::regconfig, ?) WHERE id = ?",
+		messageSearchConfig, text, messageID).Error
+}
+
+// FindUnindexed returns up to limit group messages whose search vector has not been set yet
+func (r *GroupMessageRepository) FindUnindexed(limit int) ([]models.GroupMessage, error) {
+	var messages []models.GroupMessage
+	err := r.db.Where("search_vector IS NULL").
+		Order("created_at").
+		Limit(limit).
+		Find(&messages).Error
+	return messages, err
+}
+
+// groupMessageSearchQuery matches a group's non-deleted messages whose content matches the full-text
+// query, optionally restricted to those created strictly between after and before
+func (r *GroupMessageRepository) groupMessageSearchQuery(groupID uuid.UUID, query string, before, after *time.Time) *gorm.DB {
+	db := r.db.Model(&models.GroupMessage{}).
+		Where("group_id = ? AND is_deleted = ?", groupID, false).
+		Where("search_vector @@ plainto_tsquery(?::regconfig, ?)", messageSearchConfig, query)
+	if after != nil {
+		db = db.Where("created_at > ?", *after)
+	}
+	if before != nil {
+		db = db.Where("created_at < ?", *before)
+	}
+	return db
+}
+
+// SearchGroupMessages finds a group's messages matching a full-text query, newest first
+func (r *GroupMessageRepository) SearchGroupMessages(groupID uuid.UUID, query string, before, after *time.Time, limit, offset int) ([]models.GroupMessage, error) {
+	var messages []models.GroupMessage
+	err := r.groupMessageSearchQuery(groupID, query, before, after).
+		Preload("Sender").
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&messages).Error
+	return messages, err
+}
+
+// SearchGroupMessagesCount returns how many messages SearchGroupMessages matches in total
+func (r *GroupMessageRepository) SearchGroupMessagesCount(groupID uuid.UUID, query string, before, after *time.Time) (int64, error) {
+	var count int64
+	err := r.groupMessageSearchQuery(groupID, query, before, after).Count(&count).Error
+	return count, err
+}
+
+// UpdateContent updates a group message's content and keeps its previous content
+func (r *GroupMessageRepository) UpdateContent(messageID uuid.UUID, newEncrypted, previousEncrypted string) error {
+	return r.db.Model(&models.GroupMessage{}).
+		Where("id = ?", messageID).
+		Updates(map[string]interface{}{
+			"content":          newEncrypted,
+			"previous_content": previousEncrypted,
+			"edited":           true,
+			"edited_at":        time.Now(),
+		}).Error
+}
+
+// SoftDelete marks a group message as deleted by userID without removing it
+func (r *GroupMessageRepository) SoftDelete(messageID, userID uuid.UUID) error {
+	now := time.Now()
+	return r.db.Model(&models.GroupMessage{}).
+		Where("id = ?", messageID).
+		Updates(map[string]interface{}{
+			"is_deleted": true,
+			"deleted_at": now,
+			"deleted_by": userID,
+		}).Error
+}
+
 // Delete deletes a group message
 func (r *GroupMessageRepository) Delete(id uuid.UUID) error {
 	return r.db.Delete(&models.GroupMessage{}, id).Error
@@ -67,3 +160,262 @@ func (r *GroupMessageRepository) GetMessageCount(groupID uuid.UUID) (int64, erro
 	return count, err
 }
 
+// exportQuery selects a group's messages, optionally only those sent by senderID
+func (r *GroupMessageRepository) exportQuery(groupID uuid.UUID, senderID *uuid.UUID) *gorm.DB {
+	query := r.db.Model(&models.GroupMessage{}).Where("group_id = ?", groupID)
+	if senderID != nil {
+		query = query.Where("sender_id = ?", *senderID)
+	}
+	return query
+}
+
+// CountForExport returns how many messages ForEachForExport would visit
+func (r *GroupMessageRepository) CountForExport(groupID uuid.UUID, senderID *uuid.UUID) (int64, error) {
+	var count int64
+	err := r.exportQuery(groupID, senderID).Count(&count).Error
+	return count, err
+}
+
+// ForEachForExport walks a group's messages oldest first, optionally only those sent by
+// senderID, loading them 1000 at a time so the whole history is never held in memory
+func (r *GroupMessageRepository) ForEachForExport(groupID uuid.UUID, senderID *uuid.UUID, fn func([]models.GroupMessage) error) error {
+	const batchSize = 1000
+
+	// Keyset pagination on (created_at, id) keeps the order stable across batches
+	var last *models.GroupMessage
+	for {
+		query := r.exportQuery(groupID, senderID).Preload("Sender")
+		if last != nil {
+			query = query.Where("(created_at, id) > (?, ?)", last.CreatedAt, last.ID)
+		}
+
+		var batch []models.GroupMessage
+		if err := query.Order("created_at ASC, id ASC").Limit(batchSize).Find(&batch).Error; err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := fn(batch); err != nil {
+			return err
+		}
+		if len(batch) < batchSize {
+			return nil
+		}
+		last = &batch[len(batch)-1]
+	}
+}
+
+// unreadQuery selects the group messages from other members the user has not read, in the
+// groups they belong to. Messages sent before the user joined, or deleted, are left out.
+func (r *GroupMessageRepository) unreadQuery(userID uuid.UUID) *gorm.DB {
+	return r.db.Model(&models.GroupMessage{}).
+		Joins("JOIN group_members ON group_members.group_id = group_messages.group_id AND group_members.user_id = ?", userID).
+		Joins("LEFT JOIN group_message_reads ON group_message_reads.group_message_id = group_messages.id AND group_message_reads.reader_id = ?", userID).
+		Where("group_messages.sender_id <> ?", userID).
+		Where("group_messages.created_at >= group_members.joined_at").
+		Where("group_messages.is_deleted = ?", false).
+		Where("group_message_reads.id IS NULL")
+}
+
+// GetAllUnreadCount returns how many messages the user has not read across every group they belong to
+func (r *GroupMessageRepository) GetAllUnreadCount(userID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.unreadQuery(userID).Count(&count).Error
+	return count, err
+}
+
+// GetUnreadCount returns how many messages the user has not read in one group
+func (r *GroupMessageRepository) GetUnreadCount(groupID, userID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.unreadQuery(userID).
+		Where("group_messages.group_id = ?", groupID).
+		Count(&count).Error
+	return count, err
+}
+
+// GetUnreadCounts returns how many messages the user has not read in each of the given groups.
+// Groups without unread messages are absent from the map.
+func (r *GroupMessageRepository) GetUnreadCounts(userID uuid.UUID, groupIDs []uuid.UUID) (map[uuid.UUID]int64, error) {
+	counts := make(map[uuid.UUID]int64, len(groupIDs))
+	if len(groupIDs) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		GroupID uuid.UUID
+		Count   int64
+	}
+	err := r.unreadQuery(userID).
+		Select("group_messages.group_id AS group_id, COUNT(*) AS count").
+		Where("group_messages.group_id IN ?", groupIDs).
+		Group("group_messages.group_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		counts[row.GroupID] = row.Count
+	}
+	return counts, nil
+}
+
+// MarkRead records that a reader has seen a group message (no-op if already read)
+func (r *GroupMessageRepository) MarkRead(readerID, messageID uuid.UUID) error {
+	_, err := r.MarkReadBatch(readerID, []uuid.UUID{messageID})
+	return err
+}
+
+// MarkReadBatch records reads for several messages in one upsert and returns the IDs that were not read before
+func (r *GroupMessageRepository) MarkReadBatch(readerID uuid.UUID, messageIDs []uuid.UUID) ([]uuid.UUID, error) {
+	if len(messageIDs) == 0 {
+		return nil, nil
+	}
+
+	var alreadyRead []uuid.UUID
+	err := r.db.Model(&models.GroupMessageRead{}).
+		Where("reader_id = ? AND group_message_id IN ?", readerID, messageIDs).
+		Pluck("group_message_id", &alreadyRead).Error
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[uuid.UUID]bool, len(alreadyRead))
+	for _, id := range alreadyRead {
+		seen[id] = true
+	}
+
+	now := time.Now()
+	newlyRead := make([]uuid.UUID, 0, len(messageIDs))
+	reads := make([]models.GroupMessageRead, 0, len(messageIDs))
+	for _, id := range messageIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		newlyRead = append(newlyRead, id)
+		reads = append(reads, models.GroupMessageRead{
+			GroupMessageID: id,
+			ReaderID:       readerID,
+			ReadAt:         now,
+		})
+	}
+
+	if len(reads) == 0 {
+		return nil, nil
+	}
+
+	// A concurrent request may have inserted some rows meanwhile: keep the first read
+	err = r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "group_message_id"}, {Name: "reader_id"}},
+		DoNothing: true,
+	}).Create(&reads).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return newlyRead, nil
+}
+
+// GetReaders returns who read a group message, oldest read first
+func (r *GroupMessageRepository) GetReaders(messageID uuid.UUID) ([]models.ReadReceipt, error) {
+	readers, err := r.GetReadersForMessages([]uuid.UUID{messageID})
+	if err != nil {
+		return nil, err
+	}
+	if readers[messageID] == nil {
+		return []models.ReadReceipt{}, nil
+	}
+	return readers[messageID], nil
+}
+
+// GetReadersForMessages returns the read receipts of several group messages keyed by message ID
+func (r *GroupMessageRepository) GetReadersForMessages(messageIDs []uuid.UUID) (map[uuid.UUID][]models.ReadReceipt, error) {
+	receipts := make(map[uuid.UUID][]models.ReadReceipt, len(messageIDs))
+	if len(messageIDs) == 0 {
+		return receipts, nil
+	}
+
+	var reads []models.GroupMessageRead
+	err := r.db.Preload("Reader").
+		Where("group_message_id IN ?", messageIDs).
+		Order("read_at ASC").
+		Find(&reads).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, read := range reads {
+		receipts[read.GroupMessageID] = append(receipts[read.GroupMessageID], models.ReadReceipt{
+			Reader: read.Reader.ToPublicUser(),
+			ReadAt: read.ReadAt,
+		})
+	}
+	return receipts, nil
+}
+
+// AddReaction records a member's emoji reaction on a group message; reacting twice with the same emoji is a no-op
+func (r *GroupMessageRepository) AddReaction(messageID, userID uuid.UUID, emoji string) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.GroupMessageReaction{
+		GroupMessageID: messageID,
+		UserID:         userID,
+		Emoji:          emoji,
+	}).Error
+}
+
+// RemoveReaction deletes a member's emoji reaction on a group message, returning gorm.ErrRecordNotFound if there was none
+func (r *GroupMessageRepository) RemoveReaction(messageID, userID uuid.UUID, emoji string) error {
+	result := r.db.Where("group_message_id = ? AND user_id = ? AND emoji = ?", messageID, userID, emoji).
+		Delete(&models.GroupMessageReaction{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetReactions returns how many members reacted to a group message with each emoji
+func (r *GroupMessageRepository) GetReactions(messageID uuid.UUID) (map[string]int, error) {
+	reactions, err := r.GetReactionsForMessages([]uuid.UUID{messageID})
+	if err != nil {
+		return nil, err
+	}
+	if counts, ok := reactions[messageID]; ok {
+		return counts, nil
+	}
+	return map[string]int{}, nil
+}
+
+// GetReactionsForMessages returns reaction counts by emoji for several group messages in one query, keyed by
+// message ID. Messages without reactions are absent from the map.
+func (r *GroupMessageRepository) GetReactionsForMessages(messageIDs []uuid.UUID) (map[uuid.UUID]map[string]int, error) {
+	reactions := make(map[uuid.UUID]map[string]int)
+	if len(messageIDs) == 0 {
+		return reactions, nil
+	}
+
+	var rows []struct {
+		GroupMessageID uuid.UUID
+		Emoji          string
+		Count          int
+	}
+	err := r.db.Model(&models.GroupMessageReaction{}).
+		Select("group_message_id, emoji, COUNT(*) AS count").
+		Where("group_message_id IN ?", messageIDs).
+		Group("group_message_id, emoji").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		if reactions[row.GroupMessageID] == nil {
+			reactions[row.GroupMessageID] = make(map[string]int)
+		}
+		reactions[row.GroupMessageID][row.Emoji] = row.Count
+	}
+	return reactions, nil
+}
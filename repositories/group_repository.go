@@ -2,9 +2,12 @@ package repositories
 
 import (
 	"errors"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/plugin/dbresolver"
 	"mms-backend/models"
 )
 
@@ -18,6 +21,12 @@ func NewGroupRepository(db *gorm.DB) *GroupRepository {
 	return &GroupRepository{db: db}
 }
 
+// WithPrimary returns a copy of the repository whose queries always run on the primary database,
+// for lookups a write depends on
+func (r *GroupRepository) WithPrimary() *GroupRepository {
+	return &GroupRepository{db: r.db.Clauses(dbresolver.Write).Session(&gorm.Session{})}
+}
+
 // Create creates a new group
 func (r *GroupRepository) Create(group *models.Group) error {
 	return r.db.Create(group).Error
@@ -41,6 +50,16 @@ func (r *GroupRepository) Update(group *models.Group) error {
 	return r.db.Save(group).Error
 }
 
+// UpdateFields updates the given columns of a group
+func (r *GroupRepository) UpdateFields(groupID uuid.UUID, fields map[string]interface{}) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	return r.db.Model(&models.Group{}).
+		Where("id = ?", groupID).
+		Updates(fields).Error
+}
+
 // Delete deletes a group
 func (r *GroupRepository) Delete(id uuid.UUID) error {
 	// Delete all group members first
@@ -48,6 +67,37 @@ func (r *GroupRepository) Delete(id uuid.UUID) error {
 		return err
 	}
 	
+	// Delete mutes
+	if err := r.db.Where("group_id = ?", id).Delete(&models.GroupMute{}).Error; err != nil {
+		return err
+	}
+
+	// Delete archive entries
+	if err := r.db.Where("group_id = ?", id).Delete(&models.ArchivedGroup{}).Error; err != nil {
+		return err
+	}
+
+	// Delete permissions
+	if err := r.db.Where("group_id = ?", id).Delete(&models.GroupPermissions{}).Error; err != nil {
+		return err
+	}
+
+	// Delete drafts
+	if err := r.db.Where("group_id = ?", id).Delete(&models.GroupDraft{}).Error; err != nil {
+		return err
+	}
+
+	// Delete invite links
+	if err := r.db.Where("group_id = ?", id).Delete(&models.GroupInvite{}).Error; err != nil {
+		return err
+	}
+
+	// Delete read receipts of group messages
+	groupMessages := r.db.Model(&models.GroupMessage{}).Select("id").Where("group_id = ?", id)
+	if err := r.db.Where("group_message_id IN (?)", groupMessages).Delete(&models.GroupMessageRead{}).Error; err != nil {
+		return err
+	}
+
 	// Delete all group messages
 	if err := r.db.Where("group_id = ?", id).Delete(&models.GroupMessage{}).Error; err != nil {
 		return err
@@ -64,16 +114,85 @@ func (r *GroupRepository) List(limit, offset int) ([]models.Group, error) {
 	return groups, err
 }
 
-// GetUserGroups returns all groups a user belongs to
-func (r *GroupRepository) GetUserGroups(userID uuid.UUID) ([]models.Group, error) {
+// GetUserGroups returns all groups a user belongs to, optionally including the ones they archived
+func (r *GroupRepository) GetUserGroups(userID uuid.UUID, includeArchived bool, limit, offset int) ([]models.Group, error) {
 	var groups []models.Group
-	err := r.db.Preload("Creator").
+	query := r.userGroupsQuery(userID, includeArchived).Preload("Creator").Order("groups.created_at, groups.id")
+	if limit > 0 {
+		query = query.Limit(limit).Offset(offset)
+	}
+	err := query.Find(&groups).Error
+	return groups, err
+}
+
+// CountUserGroups returns how many groups GetUserGroups lists in total
+func (r *GroupRepository) CountUserGroups(userID uuid.UUID, includeArchived bool) (int64, error) {
+	var count int64
+	err := r.userGroupsQuery(userID, includeArchived).Count(&count).Error
+	return count, err
+}
+
+// userGroupsQuery selects the groups a user belongs to, skipping the ones they archived unless asked
+func (r *GroupRepository) userGroupsQuery(userID uuid.UUID, includeArchived bool) *gorm.DB {
+	query := r.db.Model(&models.Group{}).
 		Joins("JOIN group_members ON group_members.group_id = groups.id").
-		Where("group_members.user_id = ?", userID).
+		Where("group_members.user_id = ?", userID)
+	if !includeArchived {
+		query = query.Where("NOT EXISTS (SELECT 1 FROM archived_groups WHERE archived_groups.group_id = groups.id AND archived_groups.user_id = ?)", userID)
+	}
+	return query
+}
+
+// GetArchivedGroups returns the groups a user archived, most recently archived first
+func (r *GroupRepository) GetArchivedGroups(userID uuid.UUID) ([]models.Group, error) {
+	var groups []models.Group
+	err := r.db.Preload("Creator").
+		Joins("JOIN archived_groups ON archived_groups.group_id = groups.id").
+		Where("archived_groups.user_id = ?", userID).
+		Order("archived_groups.archived_at DESC").
 		Find(&groups).Error
 	return groups, err
 }
 
+// ArchiveGroup archives a group for a user (no-op if already archived)
+func (r *GroupRepository) ArchiveGroup(userID, groupID uuid.UUID) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "group_id"}},
+		DoNothing: true,
+	}).Create(&models.ArchivedGroup{UserID: userID, GroupID: groupID}).Error
+}
+
+// UnarchiveGroup restores an archived group to a user's active list
+func (r *GroupRepository) UnarchiveGroup(userID, groupID uuid.UUID) error {
+	return r.db.Where("user_id = ? AND group_id = ?", userID, groupID).Delete(&models.ArchivedGroup{}).Error
+}
+
+// CountMembers returns the member count of each of the given groups
+func (r *GroupRepository) CountMembers(groupIDs []uuid.UUID) (map[uuid.UUID]int64, error) {
+	counts := make(map[uuid.UUID]int64, len(groupIDs))
+	if len(groupIDs) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		GroupID uuid.UUID
+		Count   int64
+	}
+	err := r.db.Model(&models.GroupMember{}).
+		Select("group_id, COUNT(*) AS count").
+		Where("group_id IN ?", groupIDs).
+		Group("group_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		counts[row.GroupID] = row.Count
+	}
+	return counts, nil
+}
+
 // GetPublicGroups returns all public groups
 func (r *GroupRepository) GetPublicGroups(limit, offset int) ([]models.Group, error) {
 	var groups []models.Group
@@ -85,13 +204,53 @@ func (r *GroupRepository) GetPublicGroups(limit, offset int) ([]models.Group, er
 	return groups, err
 }
 
+// SearchPublicGroups returns public groups whose name or description contains query
+// (case-insensitive), optionally restricted to a language, newest first. Empty filters match
+// every public group.
+func (r *GroupRepository) SearchPublicGroups(query, language string, limit, offset int) ([]models.Group, error) {
+	db := r.db.Preload("Creator").Where("type = ?", models.GroupTypePublic)
+	if query != "" {
+		pattern := "%" + likeEscaper.Replace(query) + "%"
+		db = db.Where("LOWER(name) LIKE LOWER(?) OR LOWER(description) LIKE LOWER(?)", pattern, pattern)
+	}
+	if language != "" {
+		db = db.Where("language = ?", language)
+	}
+
+	var groups []models.Group
+	err := db.Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&groups).Error
+	return groups, err
+}
+
 // AddMember adds a member to a group
 func (r *GroupRepository) AddMember(member *models.GroupMember) error {
 	return r.db.Create(member).Error
 }
 
+// AddMembers inserts several members in batches of 50
+func (r *GroupRepository) AddMembers(members []models.GroupMember) error {
+	if len(members) == 0 {
+		return nil
+	}
+	return r.db.CreateInBatches(members, 50).Error
+}
+
 // RemoveMember removes a member from a group
 func (r *GroupRepository) RemoveMember(groupID, userID uuid.UUID) error {
+	// A former member has nothing left to archive, mute or draft
+	if err := r.UnarchiveGroup(userID, groupID); err != nil {
+		return err
+	}
+	if err := r.UnmuteGroup(userID, groupID); err != nil {
+		return err
+	}
+	if err := r.DeleteDraft(userID, groupID); err != nil {
+		return err
+	}
+
 	return r.db.Where("group_id = ? AND user_id = ?", groupID, userID).
 		Delete(&models.GroupMember{}).Error
 }
@@ -125,15 +284,64 @@ func (r *GroupRepository) IsMember(groupID, userID uuid.UUID) (bool, error) {
 	return count > 0, err
 }
 
-// IsAdmin checks if a user is an admin of a group
-func (r *GroupRepository) IsAdmin(groupID, userID uuid.UUID) (bool, error) {
+// IsOwner checks if a user is the owner of a group
+func (r *GroupRepository) IsOwner(groupID, userID uuid.UUID) (bool, error) {
 	var count int64
 	err := r.db.Model(&models.GroupMember{}).
-		Where("group_id = ? AND user_id = ? AND role = ?", groupID, userID, models.MemberRoleAdmin).
+		Where("group_id = ? AND user_id = ? AND role = ?", groupID, userID, models.MemberRoleOwner).
 		Count(&count).Error
 	return count > 0, err
 }
 
+// GetMemberRole returns a user's role in a group, or an empty role if they are not a member
+func (r *GroupRepository) GetMemberRole(groupID, userID uuid.UUID) (models.MemberRole, error) {
+	var roles []models.MemberRole
+	err := r.db.Model(&models.GroupMember{}).
+		Where("group_id = ? AND user_id = ?", groupID, userID).
+		Limit(1).
+		Pluck("role", &roles).Error
+	if err != nil || len(roles) == 0 {
+		return "", err
+	}
+	return roles[0], nil
+}
+
+// TransferOwnership makes newOwnerID the owner and creator of a group and demotes the current owner to admin
+func (r *GroupRepository) TransferOwnership(groupID, ownerID, newOwnerID uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.GroupMember{}).
+			Where("group_id = ? AND user_id = ?", groupID, ownerID).
+			Update("role", models.MemberRoleAdmin).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.GroupMember{}).
+			Where("group_id = ? AND user_id = ?", groupID, newOwnerID).
+			Update("role", models.MemberRoleOwner).Error; err != nil {
+			return err
+		}
+		// created_by follows the owner so the group's creator is always someone who can manage it
+		return tx.Model(&models.Group{}).
+			Where("id = ?", groupID).
+			Update("created_by", newOwnerID).Error
+	})
+}
+
+// PromoteCreatorsToOwner gives the owner role to group creators that joined before roles were ordered
+func (r *GroupRepository) PromoteCreatorsToOwner() (int64, error) {
+	result := r.db.Exec(`
+		UPDATE group_members SET role = ?
+		FROM groups
+		WHERE group_members.group_id = groups.id
+		  AND group_members.user_id = groups.created_by
+		  AND group_members.role <> ?
+		  AND NOT EXISTS (
+			SELECT 1 FROM group_members owners
+			WHERE owners.group_id = groups.id AND owners.role = ?
+		  )`,
+		models.MemberRoleOwner, models.MemberRoleOwner, models.MemberRoleOwner)
+	return result.RowsAffected, result.Error
+}
+
 // IsCreator checks if a user is the creator of a group
 func (r *GroupRepository) IsCreator(groupID, userID uuid.UUID) (bool, error) {
 	var count int64
@@ -150,3 +358,142 @@ func (r *GroupRepository) GetGroupMembers(groupID uuid.UUID) ([]models.GroupMemb
 	return members, err
 }
 
+// GetCoMemberIDs returns the distinct users sharing at least one group with userID
+func (r *GroupRepository) GetCoMemberIDs(userID uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.db.Model(&models.GroupMember{}).
+		Distinct("user_id").
+		Where("group_id IN (?)", r.db.Model(&models.GroupMember{}).Select("group_id").Where("user_id = ?", userID)).
+		Where("user_id <> ?", userID).
+		Pluck("user_id", &ids).Error
+	return ids, err
+}
+
+// MuteGroup mutes a group for a user until the given time (nil mutes indefinitely), replacing any existing mute
+func (r *GroupRepository) MuteGroup(userID, groupID uuid.UUID, until *time.Time) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "group_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"muted_until"}),
+	}).Create(&models.GroupMute{UserID: userID, GroupID: groupID, MutedUntil: until}).Error
+}
+
+// UnmuteGroup removes a user's mute on a group
+func (r *GroupRepository) UnmuteGroup(userID, groupID uuid.UUID) error {
+	return r.db.Where("user_id = ? AND group_id = ?", userID, groupID).Delete(&models.GroupMute{}).Error
+}
+
+// GetActiveMutes returns the user's mutes still in effect for the given groups, keyed by group ID
+func (r *GroupRepository) GetActiveMutes(userID uuid.UUID, groupIDs []uuid.UUID) (map[uuid.UUID]models.GroupMute, error) {
+	mutes := make(map[uuid.UUID]models.GroupMute, len(groupIDs))
+	if len(groupIDs) == 0 {
+		return mutes, nil
+	}
+
+	var rows []models.GroupMute
+	err := r.db.Where("user_id = ? AND group_id IN ?", userID, groupIDs).
+		Where("muted_until IS NULL OR muted_until > ?", time.Now()).
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, mute := range rows {
+		mutes[mute.GroupID] = mute
+	}
+	return mutes, nil
+}
+
+// DeleteExpiredMutes removes mutes whose end time has passed and returns how many were removed
+func (r *GroupRepository) DeleteExpiredMutes() (int64, error) {
+	result := r.db.Where("muted_until IS NOT NULL AND muted_until <= ?", time.Now()).Delete(&models.GroupMute{})
+	return result.RowsAffected, result.Error
+}
+
+// SaveDraft creates or replaces a member's draft for a group
+func (r *GroupRepository) SaveDraft(userID, groupID uuid.UUID, encryptedContent string) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "group_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"content", "updated_at"}),
+	}).Create(&models.GroupDraft{UserID: userID, GroupID: groupID, Content: encryptedContent}).Error
+}
+
+// GetDrafts returns a member's drafts for the given groups, keyed by group ID
+func (r *GroupRepository) GetDrafts(userID uuid.UUID, groupIDs []uuid.UUID) (map[uuid.UUID]models.GroupDraft, error) {
+	drafts := make(map[uuid.UUID]models.GroupDraft, len(groupIDs))
+	if len(groupIDs) == 0 {
+		return drafts, nil
+	}
+
+	var rows []models.GroupDraft
+	if err := r.db.Where("user_id = ? AND group_id IN ?", userID, groupIDs).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		drafts[row.GroupID] = row
+	}
+	return drafts, nil
+}
+
+// DeleteDraft removes a member's draft for a group
+func (r *GroupRepository) DeleteDraft(userID, groupID uuid.UUID) error {
+	return r.db.Where("user_id = ? AND group_id = ?", userID, groupID).Delete(&models.GroupDraft{}).Error
+}
+
+// GetPermissions returns a group's permissions, falling back to the defaults if none were saved
+func (r *GroupRepository) GetPermissions(groupID uuid.UUID) (*models.GroupPermissions, error) {
+	var permissions models.GroupPermissions
+	err := r.db.Where("group_id = ?", groupID).First(&permissions).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			defaults := models.DefaultGroupPermissions(groupID)
+			return &defaults, nil
+		}
+		return nil, err
+	}
+	return &permissions, nil
+}
+
+// SavePermissions creates or replaces a group's permissions
+func (r *GroupRepository) SavePermissions(permissions *models.GroupPermissions) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "group_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"send_messages", "add_members", "pin_messages", "edit_group_info", "updated_at"}),
+	}).Create(permissions).Error
+}
+
+// SetPinnedMessage pins messageID in a group, or unpins its message when messageID is nil
+func (r *GroupRepository) SetPinnedMessage(groupID uuid.UUID, messageID *uuid.UUID, pinnedAt *time.Time) error {
+	return r.db.Model(&models.Group{}).
+		Where("id = ?", groupID).
+		Updates(map[string]interface{}{
+			"pinned_message_id": messageID,
+			"pinned_at":         pinnedAt,
+		}).Error
+}
+
+// CreateInvite stores a new invite link
+func (r *GroupRepository) CreateInvite(invite *models.GroupInvite) error {
+	return r.db.Create(invite).Error
+}
+
+// FindInviteByToken finds an invite link by its token, or returns nil if there is none
+func (r *GroupRepository) FindInviteByToken(token string) (*models.GroupInvite, error) {
+	var invite models.GroupInvite
+	err := r.db.Where("token = ?", token).First(&invite).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &invite, nil
+}
+
+// UseInvite counts one use of an invite link if it is still usable and reports whether it was.
+// The check and the increment are a single statement, so concurrent joins cannot exceed max_uses.
+func (r *GroupRepository) UseInvite(inviteID uuid.UUID) (bool, error) {
+	result := r.db.Model(&models.GroupInvite{}).
+		Where("id = ? AND expires_at > ? AND (max_uses = 0 OR used_count < max_uses)", inviteID, time.Now()).
+		UpdateColumn("used_count", gorm.Expr("used_count + 1"))
+	return result.RowsAffected == 1, result.Error
+}
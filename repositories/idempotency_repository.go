@@ -0,0 +1,90 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/plugin/dbresolver"
+	"mms-backend/models"
+)
+
+// IdempotencyRepository handles database operations for idempotency keys
+type IdempotencyRepository struct {
+	db *gorm.DB
+}
+
+// NewIdempotencyRepository creates a new idempotency repository. Its queries always run on the
+// primary database: a key reserved there must be seen by the next request's lookup.
+func NewIdempotencyRepository(db *gorm.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db.Clauses(dbresolver.Write).Session(&gorm.Session{})}
+}
+
+// idempotencyStorageKey scopes a client-chosen key to its user so keys never collide across users
+func idempotencyStorageKey(userID uuid.UUID, key string) string {
+	return userID.String() + ":" + key
+}
+
+// Reserve claims a user's key for a request about to be handled, for the given TTL. It reports
+// false when the key is already taken, answered or still in flight; an expired key is reclaimed.
+func (r *IdempotencyRepository) Reserve(userID uuid.UUID, key string, ttl time.Duration) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "key"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"user_id":       userID,
+			"response_body": nil,
+			"expires_at":    time.Now().Add(ttl),
+			"created_at":    time.Now(),
+		}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Lte{Column: clause.Column{Table: models.IdempotencyKey{}.TableName(), Name: "expires_at"}, Value: time.Now()},
+		}},
+	}).Create(&models.IdempotencyKey{
+		Key:       idempotencyStorageKey(userID, key),
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	return result.RowsAffected == 1, result.Error
+}
+
+// Get returns the cached response body for a user's key, or nil if none is stored yet or it expired
+func (r *IdempotencyRepository) Get(userID uuid.UUID, key string) ([]byte, error) {
+	var record models.IdempotencyKey
+	err := r.db.Where("key = ? AND expires_at > ?", idempotencyStorageKey(userID, key), time.Now()).
+		First(&record).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if record.ResponseBody == nil {
+		return nil, nil
+	}
+	return []byte(*record.ResponseBody), nil
+}
+
+// Set stores the response body of a reserved key and keeps it for the given TTL. A key that
+// already has a response keeps the first one.
+func (r *IdempotencyRepository) Set(userID uuid.UUID, key string, responseBody []byte, ttl time.Duration) error {
+	return r.db.Model(&models.IdempotencyKey{}).
+		Where("key = ? AND response_body IS NULL", idempotencyStorageKey(userID, key)).
+		Updates(map[string]interface{}{
+			"response_body": string(responseBody),
+			"expires_at":    time.Now().Add(ttl),
+		}).Error
+}
+
+// Release frees a reserved key whose request failed, so the client can retry it
+func (r *IdempotencyRepository) Release(userID uuid.UUID, key string) error {
+	return r.db.Where("key = ? AND response_body IS NULL", idempotencyStorageKey(userID, key)).
+		Delete(&models.IdempotencyKey{}).Error
+}
+
+// DeleteExpired removes expired keys and returns how many were removed
+func (r *IdempotencyRepository) DeleteExpired() (int64, error) {
+	result := r.db.Where("expires_at <= ?", time.Now()).Delete(&models.IdempotencyKey{})
+	return result.RowsAffected, result.Error
+}
@@ -2,10 +2,13 @@ package repositories
 
 import (
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/plugin/dbresolver"
 	"mms-backend/models"
 )
 
@@ -25,6 +28,11 @@ func NewMessageRepository(db *gorm.DB) *MessageRepository {
 	return &MessageRepository{db: db}
 }
 
+// WithPrimary returns a copy of the repository whose queries always run on the primary database
+func (r *MessageRepository) WithPrimary() *MessageRepository {
+	return &MessageRepository{db: r.db.Clauses(dbresolver.Write).Session(&gorm.Session{})}
+}
+
 // Create creates a new message
 func (r *MessageRepository) Create(message *models.Message) error {
 	return r.db.Create(message).Error
@@ -43,10 +51,12 @@ func (r *MessageRepository) FindByID(id uuid.UUID) (*models.Message, error) {
 	return &message, nil
 }
 
-// GetConversation retrieves messages between two users
+// GetConversation retrieves messages between two users.
+//
+// Deprecated: offsets shift when messages arrive during scrollback; use GetConversationBefore.
 func (r *MessageRepository) GetConversation(userID1, userID2 uuid.UUID, limit, offset int) ([]models.Message, error) {
 	var messages []models.Message
-	err := r.db.Preload("Sender").Preload("Receiver").
+	err := r.db.Preload("Sender").Preload("Receiver").Preload("ReplyTo").
 		Where("(sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?)",
 			userID1, userID2, userID2, userID1).
 		Order("created_at DESC").
@@ -56,6 +66,144 @@ func (r *MessageRepository) GetConversation(userID1, userID2 uuid.UUID, limit, o
 	return messages, err
 }
 
+// conversationQuery selects the messages exchanged between two users, newest first.
+// id breaks ties between messages created in the same instant so pages never overlap.
+func (r *MessageRepository) conversationQuery(userID1, userID2 uuid.UUID, limit int) *gorm.DB {
+	return r.db.Preload("Sender").Preload("Receiver").Preload("ReplyTo").
+		Where("((sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?))",
+			userID1, userID2, userID2, userID1).
+		Order("created_at DESC, id DESC").
+		Limit(limit)
+}
+
+// ForEachInConversation walks the messages exchanged between two users oldest first, loading
+// them 1000 at a time so the whole conversation is never held in memory
+func (r *MessageRepository) ForEachInConversation(userID1, userID2 uuid.UUID, fn func([]models.Message) error) error {
+	const batchSize = 1000
+
+	// Keyset pagination on (created_at, id) keeps the order stable across batches
+	var last *models.Message
+	for {
+		query := r.db.Preload("Sender").
+			Where("((sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?))",
+				userID1, userID2, userID2, userID1)
+		if last != nil {
+			query = query.Where("(created_at, id) > (?, ?)", last.CreatedAt, last.ID)
+		}
+
+		var batch []models.Message
+		if err := query.Order("created_at ASC, id ASC").Limit(batchSize).Find(&batch).Error; err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := fn(batch); err != nil {
+			return err
+		}
+		if len(batch) < batchSize {
+			return nil
+		}
+		last = &batch[len(batch)-1]
+	}
+}
+
+// GetConversationBefore returns up to limit messages between two users that are older than the
+// message beforeID, newest first. A nil beforeID starts from the newest message.
+func (r *MessageRepository) GetConversationBefore(userID1, userID2, beforeID uuid.UUID, limit int) ([]models.Message, error) {
+	query := r.conversationQuery(userID1, userID2, limit)
+	if beforeID != uuid.Nil {
+		var cursor models.Message
+		err := r.db.Select("id", "created_at").
+			Where("id = ? AND ((sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?))",
+				beforeID, userID1, userID2, userID2, userID1).
+			First(&cursor).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.New("message not found")
+			}
+			return nil, err
+		}
+		query = query.Where("(created_at, id) < (?, ?)", cursor.CreatedAt, cursor.ID)
+	}
+
+	var messages []models.Message
+	err := query.Find(&messages).Error
+	return messages, err
+}
+
+// GetConversationBeforeTime returns up to limit messages between two users created strictly before before, newest first
+func (r *MessageRepository) GetConversationBeforeTime(userID1, userID2 uuid.UUID, before time.Time, limit int) ([]models.Message, error) {
+	var messages []models.Message
+	err := r.conversationQuery(userID1, userID2, limit).
+		Where("created_at < ?", before).
+		Find(&messages).Error
+	return messages, err
+}
+
+// SearchConversation retrieves non-deleted messages between two users, newest first,
+// optionally restricted to those created strictly between after and before
+func (r *MessageRepository) SearchConversation(userID1, userID2 uuid.UUID, before, after *time.Time) ([]models.Message, error) {
+	query := r.db.Preload("Sender").Preload("Receiver").
+		Where("((sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?)) AND is_deleted = ?",
+			userID1, userID2, userID2, userID1, false)
+	if after != nil {
+		query = query.Where("created_at > ?", *after)
+	}
+	if before != nil {
+		query = query.Where("created_at < ?", *before)
+	}
+
+	var messages []models.Message
+	err := query.Order("created_at DESC").Find(&messages).Error
+	return messages, err
+}
+
+// messageSearchConfig is the text search configuration of messages.search_vector
+const messageSearchConfig = "simple"
+
+// UpdateSearchVector indexes text as the searchable content of a message; empty text removes it from search
+func (r *MessageRepository) UpdateSearchVector(messageID uuid.UUID, text string) error {
+	return r.db.Exec("UPDATE messages SET search_vector = to_tsvector(?::regconfig, ?) WHERE id = ?",
+		messageSearchConfig, text, messageID).Error
+}
+
+// FindUnindexed returns up to limit messages whose search vector has not been set yet
+func (r *MessageRepository) FindUnindexed(limit int) ([]models.Message, error) {
+	var messages []models.Message
+	err := r.db.Where("search_vector IS NULL").
+		Order("created_at").
+		Limit(limit).
+		Find(&messages).Error
+	return messages, err
+}
+
+// messageSearchQuery matches a user's non-deleted messages whose content matches the full-text query
+func (r *MessageRepository) messageSearchQuery(userID uuid.UUID, query string) *gorm.DB {
+	return r.db.Model(&models.Message{}).
+		Where("(sender_id = ? OR receiver_id = ?) AND is_deleted = ?", userID, userID, false).
+		Where("search_vector @@ plainto_tsquery(?::regconfig, ?)", messageSearchConfig, query)
+}
+
+// SearchMessages finds a user's messages, sent or received, matching a full-text query, newest first
+func (r *MessageRepository) SearchMessages(userID uuid.UUID, query string, limit, offset int) ([]models.Message, error) {
+	var messages []models.Message
+	err := r.messageSearchQuery(userID, query).
+		Preload("Sender").Preload("Receiver").
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&messages).Error
+	return messages, err
+}
+
+// SearchMessagesCount returns how many messages SearchMessages matches in total
+func (r *MessageRepository) SearchMessagesCount(userID uuid.UUID, query string) (int64, error) {
+	var count int64
+	err := r.messageSearchQuery(userID, query).Count(&count).Error
+	return count, err
+}
+
 // GetUserMessages retrieves all messages for a user
 func (r *MessageRepository) GetUserMessages(userID uuid.UUID, limit, offset int) ([]models.Message, error) {
 	var messages []models.Message
@@ -68,24 +216,116 @@ func (r *MessageRepository) GetUserMessages(userID uuid.UUID, limit, offset int)
 	return messages, err
 }
 
+// MaxMessagesSince caps how many messages of each kind a reconnecting client is sent
+const MaxMessagesSince = 500
+
+// createdAfterMessage is a condition on column selecting rows created after the direct or group
+// message with the given ID, so a client can resume from whichever kind it saw last. An unknown
+// ID matches nothing.
+func createdAfterMessage(column string) string {
+	return column + " > COALESCE(" +
+		"(SELECT created_at FROM messages WHERE id = ?), " +
+		"(SELECT created_at FROM group_messages WHERE id = ?))"
+}
+
+// GetDirectMessagesSince returns the non-deleted messages userID received after the message
+// since, oldest first, up to MaxMessagesSince
+func (r *MessageRepository) GetDirectMessagesSince(userID, since uuid.UUID) ([]models.Message, error) {
+	var messages []models.Message
+	err := r.db.Preload("Sender").Preload("Receiver").Preload("ReplyTo").
+		Where("receiver_id = ? AND is_deleted = ?", userID, false).
+		Where(createdAfterMessage("created_at"), since, since).
+		Order("created_at ASC, id ASC").
+		Limit(MaxMessagesSince).
+		Find(&messages).Error
+	return messages, err
+}
+
 // MarkAsRead marks a message as read
 func (r *MessageRepository) MarkAsRead(messageID uuid.UUID) error {
 	return r.db.Model(&models.Message{}).
 		Where("id = ?", messageID).
 		Updates(map[string]interface{}{
-			"is_read": true,
-			"read_at": gorm.Expr("NOW()"),
+			"is_read":      true,
+			"read_at":      gorm.Expr("NOW()"),
+			"delivered_at": gorm.Expr("COALESCE(delivered_at, NOW())"), // Read implies delivered
 		}).Error
 }
 
-// MarkConversationAsRead marks all messages in a conversation as read
-func (r *MessageRepository) MarkConversationAsRead(receiverID, senderID uuid.UUID) error {
-	return r.db.Model(&models.Message{}).
-		Where("receiver_id = ? AND sender_id = ? AND is_read = ?", receiverID, senderID, false).
-		Updates(map[string]interface{}{
-			"is_read": true,
-			"read_at": gorm.Expr("NOW()"),
-		}).Error
+// MarkAsDelivered marks every undelivered message from senderID to receiverID as delivered
+// and returns how many changed
+func (r *MessageRepository) MarkAsDelivered(receiverID, senderID uuid.UUID) (int64, error) {
+	result := r.db.Model(&models.Message{}).
+		Where("receiver_id = ? AND sender_id = ? AND delivered_at IS NULL", receiverID, senderID).
+		Update("delivered_at", gorm.Expr("NOW()"))
+	return result.RowsAffected, result.Error
+}
+
+// DeliveredMessage identifies a message marked delivered by MarkDelivered
+type DeliveredMessage struct {
+	SenderID    uuid.UUID
+	DeliveredAt time.Time
+}
+
+// MarkDelivered marks one message to receiverID as delivered. It returns nil when the message
+// does not exist, was not sent to receiverID or had already been delivered.
+func (r *MessageRepository) MarkDelivered(messageID, receiverID uuid.UUID) (*DeliveredMessage, error) {
+	var delivered []DeliveredMessage
+	err := r.db.Raw(`
+		UPDATE messages
+		SET delivered_at = NOW()
+		WHERE id = ? AND receiver_id = ? AND delivered_at IS NULL
+		RETURNING sender_id, delivered_at`,
+		messageID, receiverID).Scan(&delivered).Error
+	if err != nil || len(delivered) == 0 {
+		return nil, err
+	}
+	return &delivered[0], nil
+}
+
+// ReadMessage identifies a message marked read by MarkConversationAsRead
+type ReadMessage struct {
+	ID     uuid.UUID
+	ReadAt time.Time
+}
+
+// MarkConversationAsRead marks all unread messages from senderID to receiverID as read
+// and returns the messages it changed
+func (r *MessageRepository) MarkConversationAsRead(receiverID, senderID uuid.UUID) ([]ReadMessage, error) {
+	var read []ReadMessage
+	err := r.db.Raw(`
+		UPDATE messages
+		SET is_read = true,
+		    read_at = NOW(),
+		    delivered_at = COALESCE(delivered_at, NOW()) -- Read implies delivered
+		WHERE receiver_id = ? AND sender_id = ? AND is_read = false
+		RETURNING id, read_at`,
+		receiverID, senderID).Scan(&read).Error
+	return read, err
+}
+
+// MarkAllAsRead marks every unread message to receiverID as read and returns, for each sender
+// whose messages changed, the time they were read
+func (r *MessageRepository) MarkAllAsRead(receiverID uuid.UUID) ([]ReadConversation, error) {
+	var read []ReadConversation
+	err := r.db.Raw(`
+		WITH updated AS (
+			UPDATE messages
+			SET is_read = true,
+			    read_at = NOW(),
+			    delivered_at = COALESCE(delivered_at, NOW()) -- Read implies delivered
+			WHERE receiver_id = ? AND is_read = false
+			RETURNING sender_id, read_at
+		)
+		SELECT sender_id, MAX(read_at) AS read_at FROM updated GROUP BY sender_id`,
+		receiverID).Scan(&read).Error
+	return read, err
+}
+
+// ReadConversation identifies a conversation whose messages MarkAllAsRead marked read
+type ReadConversation struct {
+	SenderID uuid.UUID
+	ReadAt   time.Time
 }
 
 // GetUnreadCount returns the count of unread messages for a user
@@ -102,13 +342,36 @@ func (r *MessageRepository) Delete(id uuid.UUID) error {
 	return r.db.Delete(&models.Message{}, id).Error
 }
 
-// GetRecentConversations gets list of conversation partners ordered by last message time
-func (r *MessageRepository) GetRecentConversations(userID uuid.UUID, limit int) ([]ConversationPartner, error) {
+// GetRecentConversations gets list of conversation partners ordered by last message time, skipping the
+// conversations the user archived unless includeArchived is set
+func (r *MessageRepository) GetRecentConversations(userID uuid.UUID, limit int, includeArchived bool) ([]ConversationPartner, error) {
+	var conversations []ConversationPartner
+
+	query := r.db.Model(&models.Message{}).
+		Select("CASE WHEN sender_id = ? THEN receiver_id ELSE sender_id END as user_id, MAX(created_at) as last_message", userID).
+		Where("sender_id = ? OR receiver_id = ?", userID, userID)
+	if !includeArchived {
+		archived := r.db.Model(&models.ArchivedConversation{}).Select("partner_id").Where("user_id = ?", userID)
+		query = query.Where("CASE WHEN sender_id = ? THEN receiver_id ELSE sender_id END NOT IN (?)", userID, archived)
+	}
+
+	err := query.Group("user_id").
+		Order("last_message DESC").
+		Limit(limit).
+		Scan(&conversations).Error
+
+	return conversations, err
+}
+
+// GetArchivedConversations gets the conversations a user archived, ordered by last message time
+func (r *MessageRepository) GetArchivedConversations(userID uuid.UUID, limit int) ([]ConversationPartner, error) {
 	var conversations []ConversationPartner
 
+	archived := r.db.Model(&models.ArchivedConversation{}).Select("partner_id").Where("user_id = ?", userID)
+
 	err := r.db.Model(&models.Message{}).
 		Select("CASE WHEN sender_id = ? THEN receiver_id ELSE sender_id END as user_id, MAX(created_at) as last_message", userID).
-		Where("sender_id = ? OR receiver_id = ?", userID, userID).
+		Where("(sender_id = ? AND receiver_id IN (?)) OR (receiver_id = ? AND sender_id IN (?))", userID, archived, userID, archived).
 		Group("user_id").
 		Order("last_message DESC").
 		Limit(limit).
@@ -117,6 +380,73 @@ func (r *MessageRepository) GetRecentConversations(userID uuid.UUID, limit int)
 	return conversations, err
 }
 
+// ArchiveConversation archives a conversation for a user (no-op if already archived)
+func (r *MessageRepository) ArchiveConversation(userID, partnerID uuid.UUID) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "partner_id"}},
+		DoNothing: true,
+	}).Create(&models.ArchivedConversation{UserID: userID, PartnerID: partnerID}).Error
+}
+
+// UnarchiveConversation restores an archived conversation to a user's recent conversations
+func (r *MessageRepository) UnarchiveConversation(userID, partnerID uuid.UUID) error {
+	return r.db.Where("user_id = ? AND partner_id = ?", userID, partnerID).Delete(&models.ArchivedConversation{}).Error
+}
+
+// GetArchivedPartnerIDs returns which of the given partners the user archived the conversation with
+func (r *MessageRepository) GetArchivedPartnerIDs(userID uuid.UUID, partnerIDs []uuid.UUID) (map[uuid.UUID]bool, error) {
+	archived := make(map[uuid.UUID]bool, len(partnerIDs))
+	if len(partnerIDs) == 0 {
+		return archived, nil
+	}
+
+	var ids []uuid.UUID
+	err := r.db.Model(&models.ArchivedConversation{}).
+		Where("user_id = ? AND partner_id IN ?", userID, partnerIDs).
+		Pluck("partner_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		archived[id] = true
+	}
+	return archived, nil
+}
+
+// GetConversationPartnerIDs returns every user who has exchanged a direct message with userID
+func (r *MessageRepository) GetConversationPartnerIDs(userID uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.db.Raw(`SELECT DISTINCT CASE WHEN sender_id = ? THEN receiver_id ELSE sender_id END
+		FROM messages WHERE sender_id = ? OR receiver_id = ?`, userID, userID, userID).
+		Scan(&ids).Error
+	return ids, err
+}
+
+// likeEscaper escapes LIKE/ILIKE wildcards so user input is matched literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// SearchConversationPartners gets conversation partners whose username or email matches query, ordered by last message time
+func (r *MessageRepository) SearchConversationPartners(userID uuid.UUID, query string, limit int) ([]ConversationPartner, error) {
+	var conversations []ConversationPartner
+
+	partners := r.db.Model(&models.Message{}).
+		Select("CASE WHEN sender_id = ? THEN receiver_id ELSE sender_id END as user_id, created_at", userID).
+		Where("sender_id = ? OR receiver_id = ?", userID, userID)
+
+	searchPattern := "%" + likeEscaper.Replace(query) + "%"
+	err := r.db.Table("(?) as partners", partners).
+		Select("partners.user_id, MAX(partners.created_at) as last_message").
+		Joins("JOIN users ON users.id = partners.user_id").
+		Where("users.username ILIKE ? OR users.email ILIKE ?", searchPattern, searchPattern).
+		Group("partners.user_id").
+		Order("last_message DESC").
+		Limit(limit).
+		Scan(&conversations).Error
+
+	return conversations, err
+}
+
 // GetLastMessageBetween returns the most recent message between two users
 func (r *MessageRepository) GetLastMessageBetween(userID1, userID2 uuid.UUID) (*models.Message, error) {
 	var message models.Message
@@ -146,6 +476,35 @@ func (r *MessageRepository) GetUnreadCountForConversation(receiverID, senderID u
 	return count, err
 }
 
+// GetConversationMessageCounts returns how many messages, deleted ones included, the user
+// exchanged with each of the given partners in a single query. Partners without messages are
+// absent from the map.
+func (r *MessageRepository) GetConversationMessageCounts(userID uuid.UUID, partnerIDs []uuid.UUID) (map[uuid.UUID]int64, error) {
+	counts := make(map[uuid.UUID]int64, len(partnerIDs))
+	if len(partnerIDs) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		PartnerID uuid.UUID
+		Count     int64
+	}
+	err := r.db.Model(&models.Message{}).
+		Select("CASE WHEN sender_id = ? THEN receiver_id ELSE sender_id END AS partner_id, COUNT(*) AS count", userID).
+		Where("(sender_id = ? AND receiver_id IN ?) OR (receiver_id = ? AND sender_id IN ?)",
+			userID, partnerIDs, userID, partnerIDs).
+		Group("partner_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		counts[row.PartnerID] = row.Count
+	}
+	return counts, nil
+}
+
 // UpdateContent updates message content and tracks previous content
 func (r *MessageRepository) UpdateContent(messageID uuid.UUID, newEncryptedContent string, previousEncryptedContent string) error {
 	return r.db.Model(&models.Message{}).
@@ -169,3 +528,317 @@ func (r *MessageRepository) SoftDelete(messageID, userID uuid.UUID) error {
 			"deleted_by": userID,
 		}).Error
 }
+
+// AddReaction records a user's emoji reaction on a message; reacting twice with the same emoji is a no-op
+func (r *MessageRepository) AddReaction(messageID, userID uuid.UUID, emoji string) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.MessageReaction{
+		MessageID: messageID,
+		UserID:    userID,
+		Emoji:     emoji,
+	}).Error
+}
+
+// RemoveReaction deletes a user's emoji reaction on a message, returning gorm.ErrRecordNotFound if there was none
+func (r *MessageRepository) RemoveReaction(messageID, userID uuid.UUID, emoji string) error {
+	result := r.db.Where("message_id = ? AND user_id = ? AND emoji = ?", messageID, userID, emoji).
+		Delete(&models.MessageReaction{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetReactions returns how many users reacted to a message with each emoji
+func (r *MessageRepository) GetReactions(messageID uuid.UUID) (map[string]int, error) {
+	reactions, err := r.GetReactionsForMessages([]uuid.UUID{messageID})
+	if err != nil {
+		return nil, err
+	}
+	if counts, ok := reactions[messageID]; ok {
+		return counts, nil
+	}
+	return map[string]int{}, nil
+}
+
+// GetReactionsForMessages returns reaction counts by emoji for several messages in one query, keyed by message ID.
+// Messages without reactions are absent from the map.
+func (r *MessageRepository) GetReactionsForMessages(messageIDs []uuid.UUID) (map[uuid.UUID]map[string]int, error) {
+	reactions := make(map[uuid.UUID]map[string]int)
+	if len(messageIDs) == 0 {
+		return reactions, nil
+	}
+
+	var rows []struct {
+		MessageID uuid.UUID
+		Emoji     string
+		Count     int
+	}
+	err := r.db.Model(&models.MessageReaction{}).
+		Select("message_id, emoji, COUNT(*) AS count").
+		Where("message_id IN ?", messageIDs).
+		Group("message_id, emoji").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		if reactions[row.MessageID] == nil {
+			reactions[row.MessageID] = make(map[string]int)
+		}
+		reactions[row.MessageID][row.Emoji] = row.Count
+	}
+	return reactions, nil
+}
+
+// SaveDraft creates or replaces a user's draft for a conversation
+func (r *MessageRepository) SaveDraft(userID, partnerID uuid.UUID, encryptedContent string) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "partner_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"content", "updated_at"}),
+	}).Create(&models.ConversationDraft{UserID: userID, PartnerID: partnerID, Content: encryptedContent}).Error
+}
+
+// GetDraft returns a user's draft for a conversation, or nil if there is none
+func (r *MessageRepository) GetDraft(userID, partnerID uuid.UUID) (*models.ConversationDraft, error) {
+	var draft models.ConversationDraft
+	err := r.db.Where("user_id = ? AND partner_id = ?", userID, partnerID).First(&draft).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &draft, nil
+}
+
+// GetDrafts returns a user's drafts for the given partners, keyed by partner ID
+func (r *MessageRepository) GetDrafts(userID uuid.UUID, partnerIDs []uuid.UUID) (map[uuid.UUID]models.ConversationDraft, error) {
+	drafts := make(map[uuid.UUID]models.ConversationDraft, len(partnerIDs))
+	if len(partnerIDs) == 0 {
+		return drafts, nil
+	}
+
+	var rows []models.ConversationDraft
+	if err := r.db.Where("user_id = ? AND partner_id IN ?", userID, partnerIDs).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		drafts[row.PartnerID] = row
+	}
+	return drafts, nil
+}
+
+// MuteConversation mutes a conversation for a user until the given time (nil mutes indefinitely), replacing any existing mute
+func (r *MessageRepository) MuteConversation(userID, partnerID uuid.UUID, until *time.Time) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "partner_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"muted_until"}),
+	}).Create(&models.MutedConversation{UserID: userID, PartnerID: partnerID, MutedUntil: until}).Error
+}
+
+// UnmuteConversation removes a user's mute on a conversation
+func (r *MessageRepository) UnmuteConversation(userID, partnerID uuid.UUID) error {
+	return r.db.Where("user_id = ? AND partner_id = ?", userID, partnerID).Delete(&models.MutedConversation{}).Error
+}
+
+// GetActiveConversationMutes returns the user's mutes still in effect for the given partners, keyed by partner ID
+func (r *MessageRepository) GetActiveConversationMutes(userID uuid.UUID, partnerIDs []uuid.UUID) (map[uuid.UUID]models.MutedConversation, error) {
+	mutes := make(map[uuid.UUID]models.MutedConversation, len(partnerIDs))
+	if len(partnerIDs) == 0 {
+		return mutes, nil
+	}
+
+	var rows []models.MutedConversation
+	err := r.db.Where("user_id = ? AND partner_id IN ?", userID, partnerIDs).
+		Where("muted_until IS NULL OR muted_until > ?", time.Now()).
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, mute := range rows {
+		mutes[mute.PartnerID] = mute
+	}
+	return mutes, nil
+}
+
+// IsMuted reports whether userID currently has the conversation with partnerID muted
+func (r *MessageRepository) IsMuted(userID, partnerID uuid.UUID) (bool, error) {
+	mutes, err := r.GetActiveConversationMutes(userID, []uuid.UUID{partnerID})
+	if err != nil {
+		return false, err
+	}
+	_, muted := mutes[partnerID]
+	return muted, nil
+}
+
+// DeleteExpiredConversationMutes removes conversation mutes whose end time has passed and returns how many were removed
+func (r *MessageRepository) DeleteExpiredConversationMutes() (int64, error) {
+	result := r.db.Where("muted_until IS NOT NULL AND muted_until <= ?", time.Now()).Delete(&models.MutedConversation{})
+	return result.RowsAffected, result.Error
+}
+
+// DeleteDraft removes a user's draft for a conversation
+func (r *MessageRepository) DeleteDraft(userID, partnerID uuid.UUID) error {
+	return r.db.Where("user_id = ? AND partner_id = ?", userID, partnerID).Delete(&models.ConversationDraft{}).Error
+}
+
+// FindLabel returns a user's label on a conversation, or nil if there is none
+func (r *MessageRepository) FindLabel(userID, partnerID uuid.UUID, label string) (*models.ConversationLabel, error) {
+	var row models.ConversationLabel
+	err := r.db.Where("user_id = ? AND partner_id = ? AND label = ?", userID, partnerID, label).First(&row).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &row, nil
+}
+
+// CountLabels returns how many labels a user has put on a conversation
+func (r *MessageRepository) CountLabels(userID, partnerID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.ConversationLabel{}).
+		Where("user_id = ? AND partner_id = ?", userID, partnerID).
+		Count(&count).Error
+	return count, err
+}
+
+// SaveLabel creates a label on a conversation, or updates its color if it already exists
+func (r *MessageRepository) SaveLabel(label *models.ConversationLabel) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "partner_id"}, {Name: "label"}},
+		DoUpdates: clause.AssignmentColumns([]string{"color"}),
+	}).Create(label).Error
+}
+
+// DeleteLabel removes a label from a conversation and returns how many rows were deleted
+func (r *MessageRepository) DeleteLabel(userID, partnerID uuid.UUID, label string) (int64, error) {
+	result := r.db.Where("user_id = ? AND partner_id = ? AND label = ?", userID, partnerID, label).
+		Delete(&models.ConversationLabel{})
+	return result.RowsAffected, result.Error
+}
+
+// GetLabels returns a user's labels on a conversation, oldest first
+func (r *MessageRepository) GetLabels(userID, partnerID uuid.UUID) ([]models.ConversationLabel, error) {
+	var labels []models.ConversationLabel
+	err := r.db.Where("user_id = ? AND partner_id = ?", userID, partnerID).
+		Order("created_at ASC").
+		Find(&labels).Error
+	return labels, err
+}
+
+// GetLabelsForPartners returns a user's labels on the given conversations, keyed by partner ID
+func (r *MessageRepository) GetLabelsForPartners(userID uuid.UUID, partnerIDs []uuid.UUID) (map[uuid.UUID][]models.ConversationLabel, error) {
+	labels := make(map[uuid.UUID][]models.ConversationLabel, len(partnerIDs))
+	if len(partnerIDs) == 0 {
+		return labels, nil
+	}
+
+	var rows []models.ConversationLabel
+	err := r.db.Where("user_id = ? AND partner_id IN ?", userID, partnerIDs).
+		Order("created_at ASC").
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		labels[row.PartnerID] = append(labels[row.PartnerID], row)
+	}
+	return labels, nil
+}
+
+// GetDistinctLabels returns every label a user has created with its most recent color, sorted by name
+func (r *MessageRepository) GetDistinctLabels(userID uuid.UUID) ([]models.Label, error) {
+	var labels []models.Label
+	err := r.db.Model(&models.ConversationLabel{}).
+		Select("DISTINCT ON (label) label, color").
+		Where("user_id = ?", userID).
+		Order("label ASC, created_at DESC").
+		Scan(&labels).Error
+	return labels, err
+}
+
+// GetRecentConversationsWithLabel is GetRecentConversations restricted to conversations the user labelled with label
+func (r *MessageRepository) GetRecentConversationsWithLabel(userID uuid.UUID, label string, limit int) ([]ConversationPartner, error) {
+	var conversations []ConversationPartner
+
+	labelled := r.db.Model(&models.ConversationLabel{}).
+		Select("partner_id").
+		Where("user_id = ? AND label = ?", userID, label)
+
+	err := r.db.Model(&models.Message{}).
+		Select("CASE WHEN sender_id = ? THEN receiver_id ELSE sender_id END as user_id, MAX(created_at) as last_message", userID).
+		Where("(sender_id = ? AND receiver_id IN (?)) OR (receiver_id = ? AND sender_id IN (?))", userID, labelled, userID, labelled).
+		Group("user_id").
+		Order("last_message DESC").
+		Limit(limit).
+		Scan(&conversations).Error
+
+	return conversations, err
+}
+
+
+// CreateScheduled stores a message to send later
+func (r *MessageRepository) CreateScheduled(message *models.ScheduledMessage) error {
+	return r.db.Create(message).Error
+}
+
+// GetPendingScheduled returns a user's scheduled messages not sent yet, soonest first
+func (r *MessageRepository) GetPendingScheduled(senderID uuid.UUID) ([]models.ScheduledMessage, error) {
+	var messages []models.ScheduledMessage
+	err := r.db.Where("sender_id = ? AND status = ?", senderID, models.ScheduledMessagePending).
+		Order("scheduled_for ASC").
+		Find(&messages).Error
+	return messages, err
+}
+
+// CancelScheduled cancels a sender's pending scheduled message, returning gorm.ErrRecordNotFound
+// if they have no such message still pending
+func (r *MessageRepository) CancelScheduled(id, senderID uuid.UUID) error {
+	result := r.db.Model(&models.ScheduledMessage{}).
+		Where("id = ? AND sender_id = ? AND status = ?", id, senderID, models.ScheduledMessagePending).
+		Update("status", models.ScheduledMessageCancelled)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetDueScheduled returns up to limit pending scheduled messages whose time has come, oldest first
+func (r *MessageRepository) GetDueScheduled(now time.Time, limit int) ([]models.ScheduledMessage, error) {
+	var messages []models.ScheduledMessage
+	err := r.db.Where("status = ? AND scheduled_for <= ?", models.ScheduledMessagePending, now).
+		Order("scheduled_for ASC").
+		Limit(limit).
+		Find(&messages).Error
+	return messages, err
+}
+
+// ClaimScheduled marks a pending scheduled message sent before it goes out, so a concurrent
+// cancellation or another server can't send it too. It reports whether the claim succeeded.
+func (r *MessageRepository) ClaimScheduled(id uuid.UUID) (bool, error) {
+	result := r.db.Model(&models.ScheduledMessage{}).
+		Where("id = ? AND status = ?", id, models.ScheduledMessagePending).
+		Update("status", models.ScheduledMessageSent)
+	return result.RowsAffected == 1, result.Error
+}
+
+// CompleteScheduled records the outcome of sending a claimed scheduled message: the message it
+// became, or why it could not be sent
+func (r *MessageRepository) CompleteScheduled(id uuid.UUID, messageID *uuid.UUID, sendErr string) error {
+	updates := map[string]interface{}{"message_id": messageID}
+	if sendErr != "" {
+		updates["status"] = models.ScheduledMessageFailed
+		updates["error"] = sendErr
+	}
+	return r.db.Model(&models.ScheduledMessage{}).Where("id = ?", id).Updates(updates).Error
+}
@@ -0,0 +1,296 @@
+package repositories
+
+// Benchmarks for MessageRepository read paths against a real PostgreSQL database.
+//
+// The database connection uses the same DB_* environment variables as the
+// application (defaults match the integration tests). A dedicated mms_bench
+// database is dropped and recreated for each run. Benchmarks are skipped when
+// PostgreSQL is unreachable.
+//
+// Run with:
+//
+//	go test ./repositories/ -run '^$' -bench . -benchmem
+//
+// Index comparison: every GetConversation benchmark runs twice, once with the
+// idx_messages_conversation composite index (sender_id, receiver_id,
+// created_at DESC) declared on models.Message and once after dropping it.
+// Without the index PostgreSQL plans a scan over the single-column sender_id /
+// receiver_id indexes followed by an explicit sort of every message in the
+// conversation; with it the planner walks the index in created_at order and
+// stops after LIMIT rows. Large offsets (offset=5000) still have to walk and
+// discard the skipped rows in both cases, which is why deep pagination
+// degrades regardless of the index. Compare the WithIndex/WithoutIndex
+// ns/op and allocs/op columns of a local run to quantify the gain.
+//
+// BenchmarkGetRecentConversations_WithIndex / _WithoutIndex compare the
+// idx_msg_sender_created and idx_msg_receiver_created indexes on a 100,000
+// message table and log the EXPLAIN ANALYZE plan of each run (visible with -v).
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"mms-backend/models"
+)
+
+const benchConversationIndex = "idx_messages_conversation"
+
+var (
+	benchDBOnce sync.Once
+	benchDB     *gorm.DB
+	benchDBErr  error
+)
+
+func benchEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// openBenchDB connects to a freshly created benchmark database, skipping if unavailable
+func openBenchDB(b *testing.B) *gorm.DB {
+	b.Helper()
+
+	benchDBOnce.Do(func() {
+		base := fmt.Sprintf("host=%s port=%s user=%s password=%s sslmode=%s",
+			benchEnv("DB_HOST", "localhost"),
+			benchEnv("DB_PORT", "5432"),
+			benchEnv("DB_USER", "postgres"),
+			benchEnv("DB_PASSWORD", "4dmin"),
+			benchEnv("DB_SSLMODE", "disable"),
+		)
+		gormConfig := &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)}
+
+		adminDB, err := gorm.Open(postgres.Open(base+" dbname=postgres"), gormConfig)
+		if err != nil {
+			benchDBErr = err
+			return
+		}
+		sqlDB, _ := adminDB.DB()
+		sqlDB.Exec("DROP DATABASE IF EXISTS mms_bench")
+		sqlDB.Exec("CREATE DATABASE mms_bench")
+		sqlDB.Close()
+
+		benchDB, benchDBErr = gorm.Open(postgres.Open(base+" dbname=mms_bench"), gormConfig)
+		if benchDBErr != nil {
+			return
+		}
+		benchDBErr = benchDB.AutoMigrate(&models.User{}, &models.Message{})
+	})
+
+	if benchDBErr != nil {
+		b.Skipf("PostgreSQL not available: %v", benchDBErr)
+	}
+	return benchDB
+}
+
+func seedBenchUsers(b *testing.B, db *gorm.DB, count int) []models.User {
+	b.Helper()
+
+	users := make([]models.User, 0, count)
+	for i := 0; i < count; i++ {
+		suffix := uuid.New().String()[:8]
+		users = append(users, models.User{
+			Username: "bench_" + suffix,
+			Email:    "bench_" + suffix + "@bench.example.com",
+			Password: "not-a-real-hash",
+		})
+	}
+	if err := db.CreateInBatches(users, 100).Error; err != nil {
+		b.Fatalf("failed to seed users: %v", err)
+	}
+	return users
+}
+
+func seedBenchMessages(b *testing.B, db *gorm.DB, sender, receiver uuid.UUID, count int) {
+	b.Helper()
+
+	messages := make([]models.Message, 0, count)
+	base := time.Now().Add(-time.Duration(count) * time.Second)
+	for i := 0; i < count; i++ {
+		from, to := sender, receiver
+		if i%2 == 1 {
+			from, to = receiver, sender
+		}
+		messages = append(messages, models.Message{
+			SenderID:   from,
+			ReceiverID: to,
+			Content:    "encrypted-placeholder",
+			CreatedAt:  base.Add(time.Duration(i) * time.Second),
+		})
+	}
+	if err := db.CreateInBatches(messages, 500).Error; err != nil {
+		b.Fatalf("failed to seed messages: %v", err)
+	}
+}
+
+// withAndWithoutIndex runs fn once with the composite index and once after dropping it
+func withAndWithoutIndex(b *testing.B, db *gorm.DB, fn func(b *testing.B)) {
+	b.Run("WithIndex", func(b *testing.B) {
+		if err := db.Migrator().CreateIndex(&models.Message{}, benchConversationIndex); err != nil && !db.Migrator().HasIndex(&models.Message{}, benchConversationIndex) {
+			b.Fatalf("failed to create index: %v", err)
+		}
+		db.Exec("ANALYZE messages")
+		fn(b)
+	})
+
+	b.Run("WithoutIndex", func(b *testing.B) {
+		if err := db.Migrator().DropIndex(&models.Message{}, benchConversationIndex); err != nil {
+			b.Fatalf("failed to drop index: %v", err)
+		}
+		defer db.Migrator().CreateIndex(&models.Message{}, benchConversationIndex)
+		db.Exec("ANALYZE messages")
+		fn(b)
+	})
+}
+
+var (
+	conversationOnce sync.Once
+	conversationA    uuid.UUID
+	conversationB    uuid.UUID
+)
+
+func BenchmarkGetConversation(b *testing.B) {
+	db := openBenchDB(b)
+	repo := NewMessageRepository(db)
+
+	conversationOnce.Do(func() {
+		users := seedBenchUsers(b, db, 2)
+		conversationA, conversationB = users[0].ID, users[1].ID
+		seedBenchMessages(b, db, conversationA, conversationB, 10000)
+	})
+
+	for _, offset := range []int{0, 5000} {
+		offset := offset
+		b.Run(fmt.Sprintf("offset=%d", offset), func(b *testing.B) {
+			withAndWithoutIndex(b, db, func(b *testing.B) {
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := repo.GetConversation(conversationA, conversationB, 50, offset); err != nil {
+						b.Fatalf("GetConversation failed: %v", err)
+					}
+				}
+			})
+		})
+	}
+}
+
+var (
+	recentOnce sync.Once
+	recentUser uuid.UUID
+)
+
+func BenchmarkGetRecentConversations(b *testing.B) {
+	db := openBenchDB(b)
+	repo := NewMessageRepository(db)
+
+	recentOnce.Do(func() {
+		users := seedBenchUsers(b, db, 101)
+		recentUser = users[0].ID
+		for _, partner := range users[1:] {
+			seedBenchMessages(b, db, recentUser, partner.ID, 20)
+		}
+	})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.GetRecentConversations(recentUser, 20, false); err != nil {
+			b.Fatalf("GetRecentConversations failed: %v", err)
+		}
+	}
+}
+
+// recentConversationIndexes back the sender_id = ? OR receiver_id = ? filter of
+// GetRecentConversations; see docs/database_indexes.md
+var recentConversationIndexes = []string{"idx_msg_sender_created", "idx_msg_receiver_created"}
+
+// recentConversationsSQL mirrors the query built by GetRecentConversations for EXPLAIN ANALYZE
+const recentConversationsSQL = `SELECT CASE WHEN sender_id = $1 THEN receiver_id ELSE sender_id END AS user_id, MAX(created_at) AS last_message
+FROM messages WHERE sender_id = $1 OR receiver_id = $1
+GROUP BY user_id ORDER BY last_message DESC LIMIT 20`
+
+var (
+	largeRecentOnce sync.Once
+	largeRecentUser uuid.UUID
+)
+
+// seedLargeRecentDataset seeds 100,000 messages: 10,000 between the measured user
+// and 100 partners, and 90,000 exchanged among 90 unrelated pairs
+func seedLargeRecentDataset(b *testing.B, db *gorm.DB) uuid.UUID {
+	b.Helper()
+
+	largeRecentOnce.Do(func() {
+		users := seedBenchUsers(b, db, 281)
+		largeRecentUser = users[0].ID
+		for _, partner := range users[1:101] {
+			seedBenchMessages(b, db, largeRecentUser, partner.ID, 100)
+		}
+		others := users[101:]
+		for i := 0; i+1 < len(others); i += 2 {
+			seedBenchMessages(b, db, others[i].ID, others[i+1].ID, 1000)
+		}
+	})
+	return largeRecentUser
+}
+
+// explainRecentConversations logs the query plan so runs show which indexes were used
+func explainRecentConversations(b *testing.B, db *gorm.DB, userID uuid.UUID) {
+	b.Helper()
+
+	var plan []string
+	if err := db.Raw("EXPLAIN ANALYZE "+recentConversationsSQL, userID).Scan(&plan).Error; err != nil {
+		b.Fatalf("EXPLAIN ANALYZE failed: %v", err)
+	}
+	for _, line := range plan {
+		b.Log(line)
+	}
+}
+
+func benchmarkLargeRecentConversations(b *testing.B, withIndex bool) {
+	db := openBenchDB(b)
+	repo := NewMessageRepository(db)
+	userID := seedLargeRecentDataset(b, db)
+
+	for _, index := range recentConversationIndexes {
+		if withIndex {
+			if !db.Migrator().HasIndex(&models.Message{}, index) {
+				if err := db.Migrator().CreateIndex(&models.Message{}, index); err != nil {
+					b.Fatalf("failed to create %s: %v", index, err)
+				}
+			}
+			continue
+		}
+		if err := db.Migrator().DropIndex(&models.Message{}, index); err != nil {
+			b.Fatalf("failed to drop %s: %v", index, err)
+		}
+		defer db.Migrator().CreateIndex(&models.Message{}, index)
+	}
+	db.Exec("ANALYZE messages")
+	explainRecentConversations(b, db, userID)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.GetRecentConversations(userID, 20, false); err != nil {
+			b.Fatalf("GetRecentConversations failed: %v", err)
+		}
+	}
+}
+
+func BenchmarkGetRecentConversations_WithIndex(b *testing.B) {
+	benchmarkLargeRecentConversations(b, true)
+}
+
+func BenchmarkGetRecentConversations_WithoutIndex(b *testing.B) {
+	benchmarkLargeRecentConversations(b, false)
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"mms-backend/models"
 )
 
@@ -23,6 +24,14 @@ func (r *NotificationRepository) Create(notification *models.Notification) error
 	return r.db.Create(notification).Error
 }
 
+// BulkCreate creates several notifications in batches of 50
+func (r *NotificationRepository) BulkCreate(notifications []models.Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	return r.db.CreateInBatches(notifications, 50).Error
+}
+
 // FindByID finds a notification by ID
 func (r *NotificationRepository) FindByID(id uuid.UUID) (*models.Notification, error) {
 	var notification models.Notification
@@ -47,6 +56,13 @@ func (r *NotificationRepository) GetUserNotifications(userID uuid.UUID, limit, o
 	return notifications, err
 }
 
+// CountUserNotifications returns how many notifications a user has in total
+func (r *NotificationRepository) CountUserNotifications(userID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Notification{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
 // GetUnreadNotifications retrieves unread notifications for a user
 func (r *NotificationRepository) GetUnreadNotifications(userID uuid.UUID) ([]models.Notification, error) {
 	var notifications []models.Notification
@@ -76,6 +92,22 @@ func (r *NotificationRepository) MarkAllAsRead(userID uuid.UUID) error {
 		}).Error
 }
 
+// MarkBatchAsRead marks the given unread notifications of a user as read and returns how
+// many rows changed. IDs that belong to other users or are already read are ignored.
+func (r *NotificationRepository) MarkBatchAsRead(userID uuid.UUID, ids []uuid.UUID) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	result := r.db.Model(&models.Notification{}).
+		Where("user_id = ? AND id IN ? AND read_status = ?", userID, ids, false).
+		Updates(map[string]interface{}{
+			"read_status": true,
+			"read_at":     gorm.Expr("NOW()"),
+		})
+	return result.RowsAffected, result.Error
+}
+
 // Delete deletes a notification
 func (r *NotificationRepository) Delete(id uuid.UUID) error {
 	return r.db.Delete(&models.Notification{}, id).Error
@@ -95,3 +127,30 @@ func (r *NotificationRepository) GetUnreadCount(userID uuid.UUID) (int64, error)
 	return count, err
 }
 
+// GetPreferences returns the notification preferences a user has stored
+func (r *NotificationRepository) GetPreferences(userID uuid.UUID) ([]models.NotificationPreference, error) {
+	var preferences []models.NotificationPreference
+	err := r.db.Where("user_id = ?", userID).Find(&preferences).Error
+	return preferences, err
+}
+
+// FindPreference returns a user's preference for a notification type, or nil if there is none
+func (r *NotificationRepository) FindPreference(userID uuid.UUID, ntype models.NotificationType) (*models.NotificationPreference, error) {
+	var preference models.NotificationPreference
+	err := r.db.Where("user_id = ? AND type = ?", userID, ntype).First(&preference).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &preference, nil
+}
+
+// SavePreference creates or replaces a user's preference for a notification type
+func (r *NotificationRepository) SavePreference(preference *models.NotificationPreference) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "type"}},
+		DoUpdates: clause.AssignmentColumns([]string{"enabled", "sound_enabled", "updated_at"}),
+	}).Create(preference).Error
+}
@@ -0,0 +1,65 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"mms-backend/models"
+)
+
+// PasswordResetRepository handles database operations for password reset tokens
+type PasswordResetRepository struct {
+	db *gorm.DB
+}
+
+// NewPasswordResetRepository creates a new password reset repository
+func NewPasswordResetRepository(db *gorm.DB) *PasswordResetRepository {
+	return &PasswordResetRepository{db: db}
+}
+
+// Create stores a new reset token
+func (r *PasswordResetRepository) Create(token *models.PasswordResetToken) error {
+	return r.db.Create(token).Error
+}
+
+// Consume marks an unused, unexpired token as used and returns it, or nil if no such token exists.
+// The update is conditional so a token can only ever be consumed once, even by concurrent requests.
+func (r *PasswordResetRepository) Consume(tokenHash string) (*models.PasswordResetToken, error) {
+	now := time.Now()
+	var token models.PasswordResetToken
+	err := r.db.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", tokenHash, now).
+		First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	result := r.db.Model(&models.PasswordResetToken{}).
+		Where("id = ? AND used_at IS NULL", token.ID).
+		Update("used_at", now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	token.UsedAt = &now
+	return &token, nil
+}
+
+// InvalidateForUser marks every outstanding token of a user as used
+func (r *PasswordResetRepository) InvalidateForUser(userID uuid.UUID) error {
+	return r.db.Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now()).Error
+}
+
+// DeleteExpired removes expired tokens and returns how many were removed
+func (r *PasswordResetRepository) DeleteExpired() (int64, error) {
+	result := r.db.Where("expires_at <= ?", time.Now()).Delete(&models.PasswordResetToken{})
+	return result.RowsAffected, result.Error
+}
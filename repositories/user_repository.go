@@ -3,12 +3,19 @@ package repositories
 import (
 	"errors"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/plugin/dbresolver"
 	"mms-backend/models"
+	"mms-backend/utils"
 )
 
+// ErrUserNotFound is returned when no account that isn't deleted matches a lookup
+var ErrUserNotFound = errors.New("user not found")
+
 // UserRepository handles database operations for users
 type UserRepository struct {
 	db *gorm.DB
@@ -19,6 +26,12 @@ func NewUserRepository(db *gorm.DB) *UserRepository {
 	return &UserRepository{db: db}
 }
 
+// WithPrimary returns a copy of the repository whose queries always run on the primary database,
+// for lookups a write depends on
+func (r *UserRepository) WithPrimary() *UserRepository {
+	return &UserRepository{db: r.db.Clauses(dbresolver.Write).Session(&gorm.Session{})}
+}
+
 // Create creates a new user
 func (r *UserRepository) Create(user *models.User) error {
 	return r.db.Create(user).Error
@@ -27,10 +40,10 @@ func (r *UserRepository) Create(user *models.User) error {
 // FindByID finds a user by ID
 func (r *UserRepository) FindByID(id uuid.UUID) (*models.User, error) {
 	var user models.User
-	err := r.db.Where("id = ?", id).First(&user).Error
+	err := r.db.Scopes(notDeleted).Where("id = ?", id).First(&user).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("user not found")
+			return nil, ErrUserNotFound
 		}
 		return nil, err
 	}
@@ -40,10 +53,10 @@ func (r *UserRepository) FindByID(id uuid.UUID) (*models.User, error) {
 // FindByEmail finds a user by email
 func (r *UserRepository) FindByEmail(email string) (*models.User, error) {
 	var user models.User
-	err := r.db.Where("LOWER(email) = LOWER(?)", email).First(&user).Error
+	err := r.db.Scopes(notDeleted).Where("LOWER(email) = LOWER(?)", email).First(&user).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("user not found")
+			return nil, ErrUserNotFound
 		}
 		return nil, err
 	}
@@ -53,23 +66,32 @@ func (r *UserRepository) FindByEmail(email string) (*models.User, error) {
 // FindByUsername finds a user by username (case-insensitive)
 func (r *UserRepository) FindByUsername(username string) (*models.User, error) {
 	var user models.User
-	err := r.db.Where("LOWER(username) = LOWER(?)", username).First(&user).Error
+	err := r.db.Scopes(notDeleted).Where("LOWER(username) = LOWER(?)", username).First(&user).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("user not found")
+			return nil, ErrUserNotFound
 		}
 		return nil, err
 	}
 	return &user, nil
 }
 
+// FindByNormalizedPhone finds a user by phone number written in any format NormalizePhone accepts
+func (r *UserRepository) FindByNormalizedPhone(phone string) (*models.User, error) {
+	normalized, err := utils.NormalizePhone(phone)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	return r.FindByPhone(normalized)
+}
+
 // FindByPhone finds a user by phone number
 func (r *UserRepository) FindByPhone(phone string) (*models.User, error) {
 	var user models.User
-	err := r.db.Where("phone = ?", phone).First(&user).Error
+	err := r.db.Scopes(notDeleted).Where("phone = ?", phone).First(&user).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("user not found")
+			return nil, ErrUserNotFound
 		}
 		return nil, err
 	}
@@ -81,28 +103,153 @@ func (r *UserRepository) Update(user *models.User) error {
 	return r.db.Save(user).Error
 }
 
-// Delete deletes a user
+// Delete soft-deletes a user: the account can no longer be found or log in, and its device
+// token is cleared, but its messages stay until PurgeDeleted removes it
 func (r *UserRepository) Delete(id uuid.UUID) error {
-	return r.db.Delete(&models.User{}, id).Error
+	result := r.db.Model(&models.User{}).
+		Scopes(notDeleted).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"deleted_at":   gorm.Expr("NOW()"),
+			"device_token": "",
+			"platform":     "",
+			"is_online":    false,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
 }
 
-// List returns a paginated list of users
-func (r *UserRepository) List(limit, offset int) ([]models.User, error) {
+// PurgeDeleted permanently deletes the users soft-deleted before cutoff, along with everything
+// that cascades from them, and returns how many were removed
+func (r *UserRepository) PurgeDeleted(cutoff time.Time) (int64, error) {
+	result := r.db.Where("deleted_at < ?", cutoff).Delete(&models.User{})
+	return result.RowsAffected, result.Error
+}
+
+// List returns a paginated list of users, leaving out those who blocked viewerID
+func (r *UserRepository) List(viewerID uuid.UUID, limit, offset int) ([]models.User, error) {
 	var users []models.User
-	err := r.db.Limit(limit).Offset(offset).Find(&users).Error
+	err := r.db.Scopes(notDeleted, notBlocking(viewerID)).Limit(limit).Offset(offset).Find(&users).Error
 	return users, err
 }
 
-// Search searches users by username or email
-func (r *UserRepository) Search(query string, limit int) ([]models.User, error) {
-	var users []models.User
+// Count returns the total number of users List can return to viewerID
+func (r *UserRepository) Count(viewerID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.User{}).Scopes(notDeleted, notBlocking(viewerID)).Count(&count).Error
+	return count, err
+}
+
+// notDeleted leaves out soft-deleted users
+func notDeleted(db *gorm.DB) *gorm.DB {
+	return db.Where("users.deleted_at IS NULL")
+}
+
+// notBlocking leaves out the users who blocked viewerID
+func notBlocking(viewerID uuid.UUID) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("NOT EXISTS (SELECT 1 FROM user_blocks WHERE user_blocks.blocker_id = users.id AND user_blocks.blocked_id = ?)", viewerID)
+	}
+}
+
+// ListIDs returns the IDs of every user except excludeID
+func (r *UserRepository) ListIDs(excludeID uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.db.Model(&models.User{}).Scopes(notDeleted).Where("id <> ?", excludeID).Pluck("id", &ids).Error
+	return ids, err
+}
+
+// searchQuery matches users by username, email, bio or phone number. Phone numbers only
+// match users who keep their phone visible.
+func (r *UserRepository) searchQuery(query string) *gorm.DB {
 	searchPattern := "%" + strings.ToLower(query) + "%"
-	err := r.db.Where("LOWER(username) LIKE ? OR LOWER(email) LIKE ?", searchPattern, searchPattern).
+	conditions := r.db.Where("LOWER(username) LIKE ? OR LOWER(email) LIKE ? OR bio ILIKE ?", searchPattern, searchPattern, searchPattern)
+	if phone := utils.StripPhoneFormatting(query); strings.ContainsAny(phone, "0123456789") {
+		conditions = conditions.Or("phone_visible = ? AND phone LIKE ?", true, "%"+likeEscaper.Replace(phone)+"%")
+	}
+	return r.db.Model(&models.User{}).Scopes(notDeleted).Where(conditions)
+}
+
+// Search searches users by username, email, bio or phone number, leaving out those who blocked viewerID
+func (r *UserRepository) Search(viewerID uuid.UUID, query string, limit, offset int) ([]models.User, error) {
+	var users []models.User
+	err := r.searchQuery(query).
+		Scopes(notBlocking(viewerID)).
+		Order("username").
 		Limit(limit).
+		Offset(offset).
 		Find(&users).Error
 	return users, err
 }
 
+// SearchCount returns how many users Search matches in total
+func (r *UserRepository) SearchCount(viewerID uuid.UUID, query string) (int64, error) {
+	var count int64
+	err := r.searchQuery(query).Scopes(notBlocking(viewerID)).Count(&count).Error
+	return count, err
+}
+
+// FindByPhonePrefix finds users whose phone number starts with prefix, shortest number
+// (so an exact match) first. Users who hide their phone number are never returned.
+func (r *UserRepository) FindByPhonePrefix(prefix string, limit int) ([]models.User, error) {
+	var users []models.User
+	prefix = utils.StripPhoneFormatting(prefix)
+	if prefix == "" {
+		return users, nil
+	}
+
+	err := r.db.Scopes(notDeleted).Where("phone_visible = ? AND phone LIKE ?", true, likeEscaper.Replace(prefix)+"%").
+		Order("LENGTH(phone) ASC").
+		Limit(limit).
+		Find(&users).Error
+	return users, err
+}
+
+// UpdateProfile sets the given profile columns of a user
+func (r *UserRepository) UpdateProfile(userID uuid.UUID, fields map[string]interface{}) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	return r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Updates(fields).Error
+}
+
+// Block records that blockerID blocked blockedID; blocking twice is a no-op
+func (r *UserRepository) Block(blockerID, blockedID uuid.UUID) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.UserBlock{
+		BlockerID: blockerID,
+		BlockedID: blockedID,
+	}).Error
+}
+
+// Unblock removes a block, returning gorm.ErrRecordNotFound if there was none
+func (r *UserRepository) Unblock(blockerID, blockedID uuid.UUID) error {
+	result := r.db.Where("blocker_id = ? AND blocked_id = ?", blockerID, blockedID).
+		Delete(&models.UserBlock{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// IsBlocked reports whether blockerID blocked blockedID
+func (r *UserRepository) IsBlocked(blockerID, blockedID uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.UserBlock{}).
+		Where("blocker_id = ? AND blocked_id = ?", blockerID, blockedID).
+		Count(&count).Error
+	return count > 0, err
+}
+
 // UpdateOnlineStatus updates user's online status
 func (r *UserRepository) UpdateOnlineStatus(userID uuid.UUID, isOnline bool) error {
 	return r.db.Model(&models.User{}).
@@ -113,6 +260,80 @@ func (r *UserRepository) UpdateOnlineStatus(userID uuid.UUID, isOnline bool) err
 		}).Error
 }
 
+// GetLanguage returns the language a user chose, reading only that column
+func (r *UserRepository) GetLanguage(userID uuid.UUID) (string, error) {
+	var language string
+	err := r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Pluck("language", &language).Error
+	return language, err
+}
+
+// UpdatePasswordHash replaces a user's password hash
+func (r *UserRepository) UpdatePasswordHash(userID uuid.UUID, newHash string) error {
+	return r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("password", newHash).Error
+}
+
+// UpdateTOTP sets a user's encrypted TOTP secret and whether login requires a code
+func (r *UserRepository) UpdateTOTP(userID uuid.UUID, secret string, enabled bool) error {
+	return r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Updates(map[string]interface{}{"totp_secret": secret, "totp_enabled": enabled}).Error
+}
+
+// CountBcryptPasswords returns how many users still have a bcrypt password hash
+func (r *UserRepository) CountBcryptPasswords() (int64, error) {
+	var count int64
+	err := r.db.Model(&models.User{}).
+		Where("password LIKE ? OR password LIKE ? OR password LIKE ?", "$2a$%", "$2b$%", "$2y$%").
+		Count(&count).Error
+	return count, err
+}
+
+// CountPasswords returns how many users have a password (Google-only accounts have none)
+func (r *UserRepository) CountPasswords() (int64, error) {
+	var count int64
+	err := r.db.Model(&models.User{}).
+		Where("password <> ''").
+		Count(&count).Error
+	return count, err
+}
+
+// adminSearchQuery matches users by username, email, phone or ID. Unlike Search it ignores
+// phone visibility and includes deleted accounts; an empty query matches everyone.
+func (r *UserRepository) adminSearchQuery(query string) *gorm.DB {
+	tx := r.db.Model(&models.User{})
+	if query == "" {
+		return tx
+	}
+	pattern := "%" + likeEscaper.Replace(query) + "%"
+	conditions := r.db.Where("username ILIKE ? OR email ILIKE ? OR id::text ILIKE ?", pattern, pattern, pattern)
+	if phone := utils.StripPhoneFormatting(query); strings.ContainsAny(phone, "0123456789") {
+		conditions = conditions.Or("phone LIKE ?", "%"+likeEscaper.Replace(phone)+"%")
+	}
+	return tx.Where(conditions)
+}
+
+// AdminSearch finds users for the admin console, newest accounts first
+func (r *UserRepository) AdminSearch(query string, limit, offset int) ([]models.User, error) {
+	var users []models.User
+	err := r.adminSearchQuery(query).
+		Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&users).Error
+	return users, err
+}
+
+// AdminCount returns how many users AdminSearch matches in total
+func (r *UserRepository) AdminCount(query string) (int64, error) {
+	var count int64
+	err := r.adminSearchQuery(query).Count(&count).Error
+	return count, err
+}
+
 // UpdateDeviceToken updates user's device token for push notifications
 func (r *UserRepository) UpdateDeviceToken(userID uuid.UUID, deviceToken, platform string) error {
 	return r.db.Model(&models.User{}).
@@ -2,6 +2,8 @@ package routes
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"mms-backend/config"
 	"mms-backend/controllers"
 	"mms-backend/middleware"
 	"mms-backend/websocket"
@@ -14,8 +16,30 @@ func SetupRoutes(
 	userController *controllers.UserController,
 	messageController *controllers.MessageController,
 	groupController *controllers.GroupController,
+	adminController *controllers.AdminController,
+	notificationController *controllers.NotificationController,
+	healthController *controllers.HealthController,
 	wsHandler *websocket.Handler,
+	stickyTracker *middleware.StickyTracker,
+	languageStore middleware.LanguageStore,
 ) {
+	// Every request gets a correlation ID, echoed in X-Request-ID and attached to its logs
+	router.Use(middleware.RequestIDMiddleware())
+
+	// Every response advertises the API version that served it
+	router.Use(middleware.APIVersionMiddleware())
+	router.Use(middleware.MetricsMiddleware())
+
+	// Responses are translated to the Accept-Language header's language; protected routes
+	// override it with the user's stored language
+	router.Use(middleware.LanguageMiddleware())
+
+	// Limiters are shared by v1 and v2 so switching prefix doesn't reset a client's budget
+	limits := config.AppConfig.RateLimit
+	authRateLimit := middleware.RateLimitMiddleware(limits.AuthLimit, limits.AuthWindow)
+	sendRateLimit := middleware.RateLimitMiddleware(limits.MessageLimit, limits.MessageWindow)
+	exportRateLimit := middleware.RateLimitMiddleware(limits.ExportLimit, limits.ExportWindow)
+
 	// Health check
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{
@@ -23,63 +47,245 @@ func SetupRoutes(
 			"message": "MMS Backend is running",
 		})
 	})
+	router.GET("/health/db", healthController.DatabaseHealth)
 
-	// API v1 routes
-	v1 := router.Group("/api/v1")
-	{
+	// Prometheus scrape endpoint
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
+	// The v1 and v2 route tables are the same but for the message and group controllers
+	registerAPI := func(api *gin.RouterGroup, msgCtrl messageHandlers, groupCtrl groupHandlers) {
 		// Public routes (no authentication required)
-		auth := v1.Group("/auth")
+		auth := api.Group("/auth")
 		{
-			auth.POST("/signup", authController.Signup)
-			auth.POST("/login", authController.Login)
+			auth.POST("/signup", authRateLimit, authController.Signup)
+			auth.POST("/login", authRateLimit, authController.Login)
+			auth.POST("/google", authRateLimit, authController.GoogleLogin)
+			auth.POST("/oauth/google", authRateLimit, authController.GoogleLogin)
+			auth.POST("/forgot-password", authRateLimit, authController.ForgotPassword)
+			auth.POST("/reset-password", authRateLimit, authController.ResetPassword)
+			auth.POST("/2fa/confirm", authRateLimit, authController.ConfirmTwoFactor)
 			auth.POST("/check-username", authController.CheckUsername)
 			auth.POST("/check-email", authController.CheckEmail)
 			auth.POST("/check-phone", authController.CheckPhone)
 		}
 
 		// Protected routes (authentication required)
-		protected := v1.Group("")
-		protected.Use(middleware.AuthMiddleware())
+		protected := api.Group("")
+		protected.Use(middleware.AuthMiddleware(), middleware.StickySender(stickyTracker), middleware.UserLanguage(languageStore))
 		{
 			// Auth routes
 			protected.GET("/auth/me", authController.GetMe)
+			protected.POST("/auth/2fa/setup", authController.SetupTwoFactor)
+			protected.POST("/auth/2fa/verify", authRateLimit, authController.VerifyTwoFactor)
+			protected.POST("/auth/2fa/disable", authRateLimit, authController.DisableTwoFactor)
 
 			// User routes
 			users := protected.Group("/users")
 			{
 				users.GET("", userController.ListUsers)
 				users.GET("/search", userController.SearchUsers)
+				users.POST("/lookup-by-phone", userController.LookupByPhone)
+				users.PATCH("/me", userController.UpdateProfile)
+				users.DELETE("/me", userController.DeleteAccount)
+				users.POST("/me/avatar", userController.UploadAvatar)
+				users.PATCH("/me/dnd", userController.UpdateDND)
 				users.GET("/:user_id", userController.GetUser)
+				users.POST("/:user_id/block", userController.BlockUser)
+				users.DELETE("/:user_id/block", userController.UnblockUser)
 			}
 
 			// Message routes
 			messages := protected.Group("/messages")
 			{
-				messages.POST("", messageController.SendMessage)
-				messages.GET("/conversations", messageController.GetRecentConversations)
-				messages.GET("/conversation/:user_id", messageController.GetConversation)
-				messages.PUT("/read/:user_id", messageController.MarkAsRead)
-				messages.GET("/unread/count", messageController.GetUnreadCount)
-				messages.PUT("/:message_id", messageController.EditMessage)
-				messages.DELETE("/:message_id", messageController.DeleteMessage)
+				messages.POST("", sendRateLimit, msgCtrl.SendMessage)
+				messages.POST("/attachments", sendRateLimit, msgCtrl.UploadAttachment)
+				messages.POST("/forward", sendRateLimit, msgCtrl.ForwardMessage)
+				messages.POST("/scheduled", sendRateLimit, msgCtrl.ScheduleMessage)
+				messages.GET("/scheduled", msgCtrl.GetScheduledMessages)
+				messages.DELETE("/scheduled/:id", msgCtrl.CancelScheduledMessage)
+				messages.GET("/conversations", msgCtrl.GetRecentConversations)
+				messages.GET("/conversations/search", msgCtrl.SearchConversations)
+				messages.GET("/conversations/archived", msgCtrl.GetArchivedConversations)
+				messages.GET("/search", msgCtrl.SearchMessages)
+				messages.GET("/conversation/:user_id", msgCtrl.GetConversation)
+				messages.GET("/conversation/:user_id/search", msgCtrl.SearchConversation)
+				messages.GET("/conversation/:user_id/export", exportRateLimit, msgCtrl.ExportConversation)
+				messages.PUT("/conversation/:user_id/draft", msgCtrl.SaveDraft)
+				messages.GET("/conversation/:user_id/draft", msgCtrl.GetDraft)
+				messages.DELETE("/conversation/:user_id/draft", msgCtrl.DeleteDraft)
+				messages.POST("/conversation/:user_id/mute", msgCtrl.MuteConversation)
+				messages.DELETE("/conversation/:user_id/mute", msgCtrl.UnmuteConversation)
+				messages.POST("/conversation/:user_id/archive", msgCtrl.ArchiveConversation)
+				messages.DELETE("/conversation/:user_id/archive", msgCtrl.UnarchiveConversation)
+				messages.POST("/conversation/:user_id/labels", msgCtrl.AddLabel)
+				messages.DELETE("/conversation/:user_id/labels/:label", msgCtrl.RemoveLabel)
+				messages.GET("/labels", msgCtrl.GetLabels)
+				messages.PUT("/read/:user_id", msgCtrl.MarkAsRead)
+				messages.PUT("/read-all", msgCtrl.MarkAllAsRead)
+				messages.PUT("/delivered/:user_id", msgCtrl.MarkAsDelivered)
+				messages.GET("/unread/count", msgCtrl.GetUnreadCount)
+				messages.GET("/unread/total", msgCtrl.GetUnreadTotal)
+				messages.PUT("/:message_id", msgCtrl.EditMessage)
+				messages.DELETE("/:message_id", msgCtrl.DeleteMessage)
+				messages.POST("/:message_id/reactions", msgCtrl.AddReaction)
+				messages.DELETE("/:message_id/reactions/:emoji", msgCtrl.RemoveReaction)
 			}
 
 			// Group routes
 			groups := protected.Group("/groups")
 			{
-				groups.POST("", groupController.CreateGroup)
-				groups.GET("/my", groupController.GetUserGroups)
-				groups.GET("/:group_id", groupController.GetGroup)
-				groups.DELETE("/:group_id", groupController.DeleteGroup)
-				groups.GET("/:group_id/messages", groupController.GetGroupMessages)
-				groups.POST("/messages", groupController.SendGroupMessage)
-				groups.GET("/:group_id/members", groupController.GetGroupMembers)
-				groups.POST("/:group_id/members", groupController.AddGroupMember)
-				groups.DELETE("/:group_id/members/:user_id", groupController.RemoveGroupMember)
+				groups.POST("", groupCtrl.CreateGroup)
+				groups.GET("/my", groupCtrl.GetUserGroups)
+				groups.GET("/archived", groupCtrl.GetArchivedGroups)
+				groups.GET("/discover", groupCtrl.DiscoverGroups)
+				groups.GET("/public", groupCtrl.ListPublicGroups)
+				groups.GET("/:group_id", groupCtrl.GetGroup)
+				groups.DELETE("/:group_id", groupCtrl.DeleteGroup)
+				groups.GET("/:group_id/messages", groupCtrl.GetGroupMessages)
+				groups.GET("/:group_id/messages/search", groupCtrl.SearchGroupMessages)
+				groups.GET("/:group_id/messages/export", groupCtrl.ExportGroupMessages)
+				groups.GET("/:group_id/unread/count", groupCtrl.GetGroupUnreadCount)
+				groups.POST("/messages", sendRateLimit, groupCtrl.SendGroupMessage)
+				groups.PATCH("/messages/:message_id", groupCtrl.EditGroupMessage)
+				groups.DELETE("/messages/:message_id", groupCtrl.DeleteGroupMessage)
+				groups.GET("/messages/:message_id/readers", groupCtrl.GetGroupMessageReaders)
+				groups.POST("/messages/:message_id/reactions", groupCtrl.AddGroupMessageReaction)
+				groups.DELETE("/messages/:message_id/reactions/:emoji", groupCtrl.RemoveGroupMessageReaction)
+				groups.GET("/:group_id/members", groupCtrl.GetGroupMembers)
+				groups.POST("/:group_id/members", groupCtrl.AddGroupMember)
+				groups.POST("/:group_id/members/bulk", groupCtrl.BulkAddGroupMembers)
+				groups.POST("/:group_id/invite", groupCtrl.CreateInviteLink)
+				groups.POST("/join/:token", groupCtrl.JoinGroupByInvite)
+				groups.DELETE("/:group_id/members/:user_id", groupCtrl.RemoveGroupMember)
+				groups.PATCH("/:group_id/members/:user_id/role", groupCtrl.UpdateMemberRole)
+				groups.POST("/:group_id/transfer", groupCtrl.TransferOwnership)
+				groups.PUT("/:group_id/owner", groupCtrl.TransferOwnership)
+				groups.PUT("/:group_id/pin/:message_id", groupCtrl.PinMessage)
+				groups.DELETE("/:group_id/pin", groupCtrl.UnpinMessage)
+				groups.GET("/:group_id/permissions", groupCtrl.GetGroupPermissions)
+				groups.PATCH("/:group_id/permissions", groupCtrl.UpdateGroupPermissions)
+				groups.PATCH("/:group_id/settings", groupCtrl.UpdateGroupSettings)
+				groups.POST("/:group_id/archive", groupCtrl.ArchiveGroup)
+				groups.DELETE("/:group_id/archive", groupCtrl.UnarchiveGroup)
+				groups.PUT("/:group_id/mute", groupCtrl.MuteGroup)
+				groups.DELETE("/:group_id/mute", groupCtrl.UnmuteGroup)
+				groups.PUT("/:group_id/draft", groupCtrl.SaveGroupDraft)
+				groups.GET("/:group_id/draft", groupCtrl.GetGroupDraft)
+				groups.DELETE("/:group_id/draft", groupCtrl.DeleteGroupDraft)
+			}
+
+			// Notification routes
+			notifications := protected.Group("/notifications")
+			{
+				notifications.GET("", notificationController.GetNotifications)
+				notifications.GET("/unread", notificationController.GetUnreadNotifications)
+				notifications.GET("/unread/count", notificationController.GetUnreadCount)
+				notifications.PUT("/read-all", notificationController.MarkAllAsRead)
+				notifications.POST("/batch-read", notificationController.BatchMarkAsRead)
+				notifications.GET("/preferences", notificationController.GetPreferences)
+				notifications.PUT("/preferences", notificationController.UpdatePreference)
+				notifications.PUT("/:notification_id/read", notificationController.MarkAsRead)
+				notifications.DELETE("/:notification_id", notificationController.DeleteNotification)
 			}
 
 			// WebSocket route (protected)
 			protected.GET("/ws", wsHandler.HandleWebSocket)
 		}
 	}
+
+	// API v1 routes
+	registerAPI(router.Group("/api/v1"), messageController, groupController)
+
+	// Admin routes (admin role checked by the admin service)
+	admin := router.Group("/api/admin/v1")
+	admin.Use(middleware.AuthMiddleware(), middleware.StickySender(stickyTracker), middleware.UserLanguage(languageStore))
+	{
+		admin.POST("/broadcast", adminController.Broadcast)
+		admin.GET("/i18n/reload", adminController.ReloadTranslations)
+		admin.GET("/password-migration-stats", adminController.PasswordMigrationStats)
+		admin.GET("/users/search", adminController.SearchUsers)
+		admin.POST("/content-filter", adminController.AddContentFilter)
+		admin.GET("/content-filter", adminController.ListContentFilters)
+		admin.DELETE("/content-filter/:id", adminController.DeleteContentFilter)
+	}
+
+	// API v2 routes: breaking response shape changes, see CHANGELOG.md
+	registerAPI(router.Group("/api/v2"), controllers.NewV2MessageController(messageController), controllers.NewV2GroupController(groupController))
+}
+
+// messageHandlers is implemented by MessageController and its v2 counterpart
+type messageHandlers interface {
+	SendMessage(c *gin.Context)
+	UploadAttachment(c *gin.Context)
+	ForwardMessage(c *gin.Context)
+	ScheduleMessage(c *gin.Context)
+	GetScheduledMessages(c *gin.Context)
+	CancelScheduledMessage(c *gin.Context)
+	GetRecentConversations(c *gin.Context)
+	SearchConversations(c *gin.Context)
+	GetArchivedConversations(c *gin.Context)
+	SearchMessages(c *gin.Context)
+	GetConversation(c *gin.Context)
+	SearchConversation(c *gin.Context)
+	ExportConversation(c *gin.Context)
+	SaveDraft(c *gin.Context)
+	GetDraft(c *gin.Context)
+	DeleteDraft(c *gin.Context)
+	MuteConversation(c *gin.Context)
+	UnmuteConversation(c *gin.Context)
+	ArchiveConversation(c *gin.Context)
+	UnarchiveConversation(c *gin.Context)
+	AddLabel(c *gin.Context)
+	RemoveLabel(c *gin.Context)
+	GetLabels(c *gin.Context)
+	MarkAsRead(c *gin.Context)
+	MarkAllAsRead(c *gin.Context)
+	MarkAsDelivered(c *gin.Context)
+	GetUnreadCount(c *gin.Context)
+	GetUnreadTotal(c *gin.Context)
+	EditMessage(c *gin.Context)
+	DeleteMessage(c *gin.Context)
+	AddReaction(c *gin.Context)
+	RemoveReaction(c *gin.Context)
+}
+
+// groupHandlers is implemented by GroupController and its v2 counterpart
+type groupHandlers interface {
+	CreateGroup(c *gin.Context)
+	GetUserGroups(c *gin.Context)
+	GetArchivedGroups(c *gin.Context)
+	DiscoverGroups(c *gin.Context)
+	ListPublicGroups(c *gin.Context)
+	GetGroup(c *gin.Context)
+	DeleteGroup(c *gin.Context)
+	GetGroupMessages(c *gin.Context)
+	SearchGroupMessages(c *gin.Context)
+	ExportGroupMessages(c *gin.Context)
+	GetGroupUnreadCount(c *gin.Context)
+	SendGroupMessage(c *gin.Context)
+	EditGroupMessage(c *gin.Context)
+	DeleteGroupMessage(c *gin.Context)
+	GetGroupMessageReaders(c *gin.Context)
+	AddGroupMessageReaction(c *gin.Context)
+	RemoveGroupMessageReaction(c *gin.Context)
+	GetGroupMembers(c *gin.Context)
+	AddGroupMember(c *gin.Context)
+	BulkAddGroupMembers(c *gin.Context)
+	CreateInviteLink(c *gin.Context)
+	JoinGroupByInvite(c *gin.Context)
+	RemoveGroupMember(c *gin.Context)
+	UpdateMemberRole(c *gin.Context)
+	TransferOwnership(c *gin.Context)
+	PinMessage(c *gin.Context)
+	UnpinMessage(c *gin.Context)
+	GetGroupPermissions(c *gin.Context)
+	UpdateGroupPermissions(c *gin.Context)
+	UpdateGroupSettings(c *gin.Context)
+	ArchiveGroup(c *gin.Context)
+	UnarchiveGroup(c *gin.Context)
+	MuteGroup(c *gin.Context)
+	UnmuteGroup(c *gin.Context)
+	SaveGroupDraft(c *gin.Context)
+	GetGroupDraft(c *gin.Context)
+	DeleteGroupDraft(c *gin.Context)
 }
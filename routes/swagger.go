@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+	"mms-backend/config"
+	_ "mms-backend/docs" // Generated by `make swagger`
+)
+
+// SetupSwagger serves the generated OpenAPI spec and Swagger UI at /swagger (development only)
+func SetupSwagger(router *gin.Engine) {
+	if config.AppConfig == nil || config.AppConfig.Server.Environment != "development" {
+		return
+	}
+
+	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+}
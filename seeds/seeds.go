@@ -0,0 +1,251 @@
+package seeds
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"mms-backend/migrations"
+	"mms-backend/models"
+	"mms-backend/utils"
+)
+
+// Password is the password of every seeded user
+const Password = "Seed1234!"
+
+// namespace derives the deterministic IDs of seeded rows
+var namespace = uuid.NewSHA1(uuid.NameSpaceOID, []byte("mms-backend/seeds"))
+
+// ID returns the deterministic UUID of a seeded row, e.g. ID("user", "seed_alice")
+func ID(kind, name string) uuid.UUID {
+	return uuid.NewSHA1(namespace, []byte(kind+":"+name))
+}
+
+// seedUser describes one development account
+type seedUser struct {
+	Username string
+	Language string
+	Bio      string
+}
+
+var users = []seedUser{
+	{"seed_alice", "en", "Backend developer, tea over coffee"},
+	{"seed_bruno", "fr", "Photographe amateur"},
+	{"seed_carmen", "es", "Diseñadora de producto"},
+	{"seed_dina", "en", "QA lead"},
+	{"seed_emile", "fr", "Chef de projet"},
+}
+
+// conversation is a scripted exchange between two seeded users; even lines are sent by From
+type conversation struct {
+	From, To string
+	Lines    []string
+	ReadUpTo int // Number of leading messages already read by their receiver
+}
+
+var conversations = []conversation{
+	{"seed_alice", "seed_bruno", []string{"Hi Bruno!", "Salut Alice !", "Did you push the fix?", "Oui, ce matin."}, 4},
+	{"seed_alice", "seed_carmen", []string{"Carmen, can you review the mockups?", "¡Claro! Esta tarde.", "Thanks!"}, 2},
+	{"seed_dina", "seed_alice", []string{"Release candidate is green", "Great, shipping it"}, 1},
+	{"seed_emile", "seed_bruno", []string{"Réunion à 10h ?", "Parfait."}, 0},
+	{"seed_carmen", "seed_dina", []string{"Found a bug in the signup form"}, 0},
+}
+
+// seedGroup describes one development group and its scripted messages
+type seedGroup struct {
+	Name     string
+	Type     models.GroupType
+	Owner    string
+	Members  []string
+	Messages []struct{ From, Content string }
+}
+
+var groups = []seedGroup{
+	{
+		Name:    "Core Team",
+		Type:    models.GroupTypePrivate,
+		Owner:   "seed_alice",
+		Members: []string{"seed_bruno", "seed_carmen", "seed_dina"},
+		Messages: []struct{ From, Content string }{
+			{"seed_alice", "Standup in 5 minutes"},
+			{"seed_dina", "Test suite is green"},
+			{"seed_bruno", "Je termine la revue"},
+		},
+	},
+	{
+		Name:    "Random",
+		Type:    models.GroupTypePublic,
+		Owner:   "seed_emile",
+		Members: []string{"seed_alice", "seed_carmen"},
+		Messages: []struct{ From, Content string }{
+			{"seed_emile", "Bienvenue !"},
+			{"seed_carmen", "¿Alguien para almorzar?"},
+		},
+	},
+}
+
+// Run inserts the development data set. Rows use deterministic IDs and are inserted
+// with ON CONFLICT DO NOTHING, so running it again leaves existing data untouched.
+func Run(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := seedUsers(tx); err != nil {
+			return err
+		}
+		if err := seedConversations(tx); err != nil {
+			return err
+		}
+		return seedGroups(tx)
+	})
+}
+
+// Reset drops every table, recreates the schema and applies the SQL migrations
+func Reset(db *gorm.DB) error {
+	all := models.All()
+	tables := make([]interface{}, 0, len(all)+1)
+	tables = append(tables, &migrations.SchemaMigration{})
+	for i := len(all) - 1; i >= 0; i-- {
+		tables = append(tables, all[i])
+	}
+	if err := db.Migrator().DropTable(tables...); err != nil {
+		return err
+	}
+
+	if err := db.AutoMigrate(all...); err != nil {
+		return err
+	}
+	return migrations.Run(db)
+}
+
+func seedUsers(tx *gorm.DB) error {
+	hashedPassword, err := utils.HashPassword(Password)
+	if err != nil {
+		return err
+	}
+
+	for _, u := range users {
+		user := models.User{
+			ID:       ID("user", u.Username),
+			Username: u.Username,
+			Email:    u.Username + "@seed.example.com",
+			Password: hashedPassword,
+			Language: u.Language,
+			Bio:      u.Bio,
+		}
+		if err := insert(tx, &user); err != nil {
+			return fmt.Errorf("user %s: %w", u.Username, err)
+		}
+	}
+	return nil
+}
+
+func seedConversations(tx *gorm.DB) error {
+	// Spread messages over the last day so conversations have a stable order
+	base := time.Now().Add(-24 * time.Hour).Truncate(time.Minute)
+
+	for i, conv := range conversations {
+		for j, line := range conv.Lines {
+			sender, receiver := conv.From, conv.To
+			if j%2 == 1 {
+				sender, receiver = conv.To, conv.From
+			}
+
+			content, err := utils.Encrypt(line)
+			if err != nil {
+				return err
+			}
+
+			createdAt := base.Add(time.Duration(i)*time.Hour + time.Duration(j)*time.Minute)
+			message := models.Message{
+				ID:         ID("message", fmt.Sprintf("%s-%s-%d", conv.From, conv.To, j)),
+				SenderID:   ID("user", sender),
+				ReceiverID: ID("user", receiver),
+				Content:    content,
+				CreatedAt:  createdAt,
+			}
+			if j < conv.ReadUpTo {
+				readAt := createdAt.Add(time.Minute)
+				message.IsRead = true
+				message.ReadAt = &readAt
+				message.DeliveredAt = &readAt
+			}
+			if err := insert(tx, &message); err != nil {
+				return fmt.Errorf("message %s -> %s: %w", sender, receiver, err)
+			}
+		}
+	}
+	return nil
+}
+
+func seedGroups(tx *gorm.DB) error {
+	base := time.Now().Add(-12 * time.Hour).Truncate(time.Minute)
+
+	for _, g := range groups {
+		groupID := ID("group", g.Name)
+		group := models.Group{
+			ID:        groupID,
+			Name:      g.Name,
+			Type:      g.Type,
+			CreatedBy: ID("user", g.Owner),
+			CreatedAt: base,
+		}
+		if err := insert(tx, &group); err != nil {
+			return fmt.Errorf("group %s: %w", g.Name, err)
+		}
+
+		members := append([]string{g.Owner}, g.Members...)
+		for i, username := range members {
+			role := models.MemberRoleMember
+			if i == 0 {
+				role = models.MemberRoleOwner
+			}
+			member := models.GroupMember{
+				ID:       ID("group_member", g.Name+":"+username),
+				GroupID:  groupID,
+				UserID:   ID("user", username),
+				Role:     role,
+				JoinedAt: base,
+			}
+			if err := insert(tx, &member); err != nil {
+				return fmt.Errorf("group %s member %s: %w", g.Name, username, err)
+			}
+		}
+
+		for i, m := range g.Messages {
+			content, err := utils.Encrypt(m.Content)
+			if err != nil {
+				return err
+			}
+			message := models.GroupMessage{
+				ID:        ID("group_message", fmt.Sprintf("%s-%d", g.Name, i)),
+				GroupID:   groupID,
+				SenderID:  ID("user", m.From),
+				Content:   content,
+				CreatedAt: base.Add(time.Duration(i+1) * time.Minute),
+			}
+			if err := insert(tx, &message); err != nil {
+				return fmt.Errorf("group %s message %d: %w", g.Name, i, err)
+			}
+
+			// The owner has read every message from other members
+			if m.From != g.Owner {
+				read := models.GroupMessageRead{
+					ID:             ID("group_message_read", fmt.Sprintf("%s-%d:%s", g.Name, i, g.Owner)),
+					GroupMessageID: message.ID,
+					ReaderID:       ID("user", g.Owner),
+					ReadAt:         message.CreatedAt.Add(time.Minute),
+				}
+				if err := insert(tx, &read); err != nil {
+					return fmt.Errorf("group %s read receipt %d: %w", g.Name, i, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// insert creates row unless a row with the same primary key or unique key already exists
+func insert(tx *gorm.DB, row interface{}) error {
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(row).Error
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"mms-backend/models"
+	"mms-backend/repositories"
+	"mms-backend/utils"
+	"mms-backend/websocket"
+
+	"github.com/google/uuid"
+)
+
+// broadcastWorkers is the number of goroutines inserting system broadcast messages
+const broadcastWorkers = 8
+
+// ErrNotAdmin is returned when a non-admin calls an admin-only operation
+var ErrNotAdmin = errors.New("admin role required")
+
+// AdminService handles administrative operations
+type AdminService struct {
+	messageRepo *repositories.MessageRepository
+	userRepo    *repositories.UserRepository
+	wsHub       *websocket.Hub
+}
+
+// NewAdminService creates a new admin service
+func NewAdminService(
+	messageRepo *repositories.MessageRepository,
+	userRepo *repositories.UserRepository,
+	wsHub *websocket.Hub,
+) *AdminService {
+	return &AdminService{
+		messageRepo: messageRepo,
+		userRepo:    userRepo,
+		wsHub:       wsHub,
+	}
+}
+
+// RequireAdmin returns ErrNotAdmin unless the user has the admin role
+func (s *AdminService) RequireAdmin(userID uuid.UUID) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil || !user.IsAdmin() {
+		return ErrNotAdmin
+	}
+	return nil
+}
+
+// PasswordMigrationStats reports the progress of the bcrypt to Argon2id migration
+type PasswordMigrationStats struct {
+	Total    int64 `json:"total"`    // Users with a password
+	Bcrypt   int64 `json:"bcrypt"`   // Users still on bcrypt, upgraded at their next login
+	Argon2id int64 `json:"argon2id"` // Users already on Argon2id
+}
+
+// GetPasswordMigrationStats counts password hashes by algorithm
+func (s *AdminService) GetPasswordMigrationStats() (*PasswordMigrationStats, error) {
+	total, err := s.userRepo.CountPasswords()
+	if err != nil {
+		return nil, err
+	}
+	bcrypt, err := s.userRepo.CountBcryptPasswords()
+	if err != nil {
+		return nil, err
+	}
+	return &PasswordMigrationStats{
+		Total:    total,
+		Bcrypt:   bcrypt,
+		Argon2id: total - bcrypt,
+	}, nil
+}
+
+// AdminUserSearch is one page of admin user search results
+type AdminUserSearch struct {
+	Users  []models.AdminUser
+	Total  int64
+	Limit  int
+	Offset int
+}
+
+// SearchUsers finds users by username, email, phone or ID and returns their full account details
+func (s *AdminService) SearchUsers(query string, limit, offset int) (*AdminUserSearch, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	query = utils.SanitizeString(query)
+
+	users, err := s.userRepo.AdminSearch(query, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	total, err := s.userRepo.AdminCount(query)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]models.AdminUser, 0, len(users))
+	for _, user := range users {
+		adminUser := user.ToAdminUser()
+		switch {
+		case user.Password == "":
+		case utils.IsBcryptHash(user.Password):
+			adminUser.PasswordScheme = "bcrypt"
+		default:
+			adminUser.PasswordScheme = "argon2id"
+		}
+		result = append(result, adminUser)
+	}
+
+	return &AdminUserSearch{Users: result, Total: total, Limit: limit, Offset: offset}, nil
+}
+
+// BroadcastRequest represents a system broadcast request
+type BroadcastRequest struct {
+	Content  string `json:"content" binding:"required"`
+	Priority string `json:"priority"` // normal, high or urgent (default normal)
+}
+
+// SendSystemBroadcast sends content as a direct message from the admin to every other user
+// and pushes a system_message event to all connected clients. It returns the recipient count.
+func (s *AdminService) SendSystemBroadcast(adminID uuid.UUID, content string, priority string) (int, error) {
+	if err := s.RequireAdmin(adminID); err != nil {
+		return 0, err
+	}
+
+	if content == "" {
+		return 0, errors.New("content cannot be empty")
+	}
+
+	messagePriority := models.MessagePriorityNormal
+	if priority != "" {
+		messagePriority = models.MessagePriority(priority)
+		if !messagePriority.IsValid() {
+			return 0, ErrInvalidPriority
+		}
+	}
+
+	encryptedContent, err := utils.Encrypt(content)
+	if err != nil {
+		return 0, errors.New("failed to encrypt message")
+	}
+
+	recipients, err := s.userRepo.ListIDs(adminID)
+	if err != nil {
+		return 0, err
+	}
+
+	sent, err := s.createBroadcastMessages(adminID, recipients, encryptedContent, messagePriority)
+
+	if s.wsHub != nil && sent > 0 {
+		event, marshalErr := json.Marshal(websocket.Message{
+			Type:      "system_message",
+			SenderID:  adminID,
+			Content:   content,
+			Data:      map[string]interface{}{"priority": messagePriority},
+			Timestamp: time.Now(),
+		})
+		if marshalErr == nil {
+			s.wsHub.BroadcastToAll(event)
+		}
+	}
+
+	return sent, err
+}
+
+// createBroadcastMessages inserts one message per recipient using a pool of workers.
+// It returns how many messages were stored and the first error encountered.
+func (s *AdminService) createBroadcastMessages(adminID uuid.UUID, recipients []uuid.UUID, encryptedContent string, priority models.MessagePriority) (int, error) {
+	jobs := make(chan uuid.UUID)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		sent     int
+		firstErr error
+	)
+
+	for i := 0; i < broadcastWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for recipientID := range jobs {
+				err := s.messageRepo.Create(&models.Message{
+					SenderID:   adminID,
+					ReceiverID: recipientID,
+					Content:    encryptedContent,
+					Priority:   priority,
+				})
+
+				mu.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = err
+					}
+				} else {
+					sent++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, recipientID := range recipients {
+		jobs <- recipientID
+	}
+	close(jobs)
+	wg.Wait()
+
+	return sent, firstErr
+}
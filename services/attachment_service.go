@@ -0,0 +1,123 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/google/uuid"
+	"mms-backend/models"
+)
+
+// MaxAttachmentSize caps the size of an uploaded attachment
+const MaxAttachmentSize = 25 << 20 // 25 MB
+
+// ErrAttachmentTooLarge is returned for uploads over MaxAttachmentSize
+var ErrAttachmentTooLarge = errors.New("attachment exceeds the 25 MB limit")
+
+// ErrUnsupportedAttachment is returned for files that are not an image, audio or PDF
+var ErrUnsupportedAttachment = errors.New("attachment must be an image, audio file or PDF")
+
+// ErrInvalidAttachmentURL is returned when a message refers to an attachment that was not uploaded here
+var ErrInvalidAttachmentURL = errors.New("attachment_url must be a URL returned by the attachment upload")
+
+// attachmentFormat is an accepted MIME type with the extension it is stored under
+type attachmentFormat struct {
+	ext  string
+	kind string
+}
+
+// attachmentFormats lists accepted MIME types as sniffed by http.DetectContentType
+var attachmentFormats = map[string]attachmentFormat{
+	"image/png":       {".png", models.AttachmentTypeImage},
+	"image/jpeg":      {".jpg", models.AttachmentTypeImage},
+	"image/gif":       {".gif", models.AttachmentTypeImage},
+	"image/webp":      {".webp", models.AttachmentTypeImage},
+	"audio/mpeg":      {".mp3", models.AttachmentTypeAudio},
+	"audio/wave":      {".wav", models.AttachmentTypeAudio},
+	"audio/aiff":      {".aiff", models.AttachmentTypeAudio},
+	"application/ogg": {".ogg", models.AttachmentTypeAudio},
+	"application/pdf": {".pdf", models.AttachmentTypeDocument},
+}
+
+// AttachmentResponse describes an uploaded attachment
+type AttachmentResponse struct {
+	URL      string `json:"url"`
+	Type     string `json:"type"` // image, audio or document
+	MimeType string `json:"mime_type"`
+	Size     int64  `json:"size"`
+}
+
+// AttachmentService validates and stores message attachments
+type AttachmentService struct {
+	storage StorageService
+	baseURL string
+}
+
+// NewAttachmentService creates a new attachment service storing files in storage, which serves them under baseURL
+func NewAttachmentService(storage StorageService, baseURL string) *AttachmentService {
+	return &AttachmentService{
+		storage: storage,
+		baseURL: strings.TrimRight(baseURL, "/"),
+	}
+}
+
+// Upload stores an attachment of the given size. The type is sniffed from the content,
+// never taken from the client, and the file is stored under a random name.
+func (s *AttachmentService) Upload(data io.Reader, size int64) (*AttachmentResponse, error) {
+	if size > MaxAttachmentSize {
+		return nil, ErrAttachmentTooLarge
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(data, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("attachment is empty")
+		}
+		return nil, err
+	}
+	head = head[:n]
+
+	mimeType, _, _ := strings.Cut(http.DetectContentType(head), ";")
+	format, ok := attachmentFormats[mimeType]
+	if !ok {
+		return nil, ErrUnsupportedAttachment
+	}
+
+	// The declared size is what was checked, so never store more than the limit regardless
+	content := io.MultiReader(bytes.NewReader(head), io.LimitReader(data, MaxAttachmentSize-int64(n)))
+	url, err := s.storage.Upload(uuid.New().String()+format.ext, content, mimeType)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AttachmentResponse{
+		URL:      url,
+		Type:     format.kind,
+		MimeType: mimeType,
+		Size:     size,
+	}, nil
+}
+
+// ResolveURL checks that url points to a stored attachment and returns its type
+func (s *AttachmentService) ResolveURL(url string) (string, error) {
+	filename, ok := strings.CutPrefix(url, s.baseURL+"/")
+	if !ok || filename == "" || strings.Contains(filename, "/") {
+		return "", ErrInvalidAttachmentURL
+	}
+
+	ext := path.Ext(filename)
+	if _, err := uuid.Parse(strings.TrimSuffix(filename, ext)); err != nil {
+		return "", ErrInvalidAttachmentURL
+	}
+	for _, format := range attachmentFormats {
+		if format.ext == ext {
+			return format.kind, nil
+		}
+	}
+	return "", ErrInvalidAttachmentURL
+}
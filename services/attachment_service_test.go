@@ -0,0 +1,120 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mms-backend/config"
+	"mms-backend/models"
+)
+
+var (
+	pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	pdfHeader = []byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+)
+
+func newTestAttachmentService(t *testing.T) (*AttachmentService, string) {
+	t.Helper()
+	dir := t.TempDir()
+	return NewAttachmentService(NewLocalStorage(dir, "/uploads"), "/uploads"), dir
+}
+
+func TestAttachmentUpload(t *testing.T) {
+	s, dir := newTestAttachmentService(t)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		kind     string
+		mimeType string
+		ext      string
+	}{
+		{"png", pngHeader, models.AttachmentTypeImage, "image/png", ".png"},
+		{"pdf", pdfHeader, models.AttachmentTypeDocument, "application/pdf", ".pdf"},
+		{"mp3", []byte("ID3\x03\x00\x00\x00\x00\x00\x00"), models.AttachmentTypeAudio, "audio/mpeg", ".mp3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attachment, err := s.Upload(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if err != nil {
+				t.Fatalf("Upload returned error: %v", err)
+			}
+			if attachment.Type != tt.kind || attachment.MimeType != tt.mimeType {
+				t.Errorf("got type %q (%s), want %q (%s)", attachment.Type, attachment.MimeType, tt.kind, tt.mimeType)
+			}
+			if !strings.HasPrefix(attachment.URL, "/uploads/") || !strings.HasSuffix(attachment.URL, tt.ext) {
+				t.Errorf("unexpected URL %q", attachment.URL)
+			}
+
+			stored, err := os.ReadFile(filepath.Join(dir, strings.TrimPrefix(attachment.URL, "/uploads/")))
+			if err != nil {
+				t.Fatalf("stored file missing: %v", err)
+			}
+			if !bytes.Equal(stored, tt.data) {
+				t.Error("stored file differs from the upload")
+			}
+
+			kind, err := s.ResolveURL(attachment.URL)
+			if err != nil || kind != tt.kind {
+				t.Errorf("ResolveURL(%q) = %q, %v; want %q", attachment.URL, kind, err, tt.kind)
+			}
+		})
+	}
+}
+
+func TestAttachmentUploadRejects(t *testing.T) {
+	s, dir := newTestAttachmentService(t)
+
+	if _, err := s.Upload(strings.NewReader("just some text"), 14); !errors.Is(err, ErrUnsupportedAttachment) {
+		t.Errorf("text upload: got %v, want ErrUnsupportedAttachment", err)
+	}
+	if _, err := s.Upload(bytes.NewReader(pngHeader), MaxAttachmentSize+1); !errors.Is(err, ErrAttachmentTooLarge) {
+		t.Errorf("oversized upload: got %v, want ErrAttachmentTooLarge", err)
+	}
+	if _, err := s.Upload(bytes.NewReader(nil), 0); err == nil {
+		t.Error("empty upload should be rejected")
+	}
+
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("rejected uploads left %d files behind", len(entries))
+	}
+}
+
+func TestAttachmentResolveURLRejectsForeignURLs(t *testing.T) {
+	s, _ := newTestAttachmentService(t)
+
+	for _, url := range []string{
+		"https://evil.example.com/uploads/3f1c2a9e-1b2c-4d5e-8f90-123456789abc.png",
+		"/uploads/../secret.png",
+		"/uploads/not-a-uuid.png",
+		"/uploads/3f1c2a9e-1b2c-4d5e-8f90-123456789abc.exe",
+		"/uploads/nested/3f1c2a9e-1b2c-4d5e-8f90-123456789abc.png",
+	} {
+		if _, err := s.ResolveURL(url); !errors.Is(err, ErrInvalidAttachmentURL) {
+			t.Errorf("ResolveURL(%q) = %v, want ErrInvalidAttachmentURL", url, err)
+		}
+	}
+}
+
+func TestNewStorageService(t *testing.T) {
+	if _, err := NewStorageService(configForDriver("ftp")); err == nil {
+		t.Error("unknown driver should be rejected")
+	}
+
+	s3, err := NewStorageService(configForDriver("s3"))
+	if err != nil {
+		t.Fatalf("s3 driver: %v", err)
+	}
+	if _, err := s3.Upload("file.png", bytes.NewReader(pngHeader), "image/png"); !errors.Is(err, ErrS3NotImplemented) {
+		t.Errorf("s3 Upload: got %v, want ErrS3NotImplemented", err)
+	}
+}
+
+func configForDriver(driver string) config.StorageConfig {
+	return config.StorageConfig{Driver: driver, LocalPath: "unused", PublicURL: "/uploads"}
+}
@@ -1,23 +1,79 @@
 package services
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"image/png"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/pquerna/otp/totp"
+	"google.golang.org/api/idtoken"
 	"mms-backend/models"
 	"mms-backend/repositories"
 	"mms-backend/utils"
 )
 
+// passwordResetTTL is how long an emailed password reset token stays valid
+const passwordResetTTL = time.Hour
+
+// ErrInvalidResetToken is returned when a reset token is unknown, expired or already used
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+var (
+	// ErrEmailTaken is returned when signing up with an email already registered
+	ErrEmailTaken = errors.New("email already registered")
+	// ErrPhoneTaken is returned when signing up with a phone number already registered
+	ErrPhoneTaken = errors.New("phone already registered")
+	// ErrInvalidCredentials is returned when no user matches a login's identifier and password
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// TokenVerifier validates a Google ID token for the given audience
+type TokenVerifier interface {
+	Validate(ctx context.Context, idToken, audience string) (*idtoken.Payload, error)
+}
+
+// googleTokenVerifier verifies ID tokens against Google's public keys
+type googleTokenVerifier struct{}
+
+func (googleTokenVerifier) Validate(ctx context.Context, idToken, audience string) (*idtoken.Payload, error) {
+	return idtoken.Validate(ctx, idToken, audience)
+}
+
+// NewGoogleTokenVerifier returns the default Google ID token verifier
+func NewGoogleTokenVerifier() TokenVerifier {
+	return googleTokenVerifier{}
+}
+
 // AuthService handles authentication business logic
 type AuthService struct {
-	userRepo *repositories.UserRepository
+	userRepo       *repositories.UserRepository
+	resetRepo      *repositories.PasswordResetRepository
+	emailService   EmailService
+	googleVerifier TokenVerifier
+	googleClientID string
+
+	// passwordUpgrades tracks background bcrypt to Argon2id rehashes started by Login
+	passwordUpgrades sync.WaitGroup
 }
 
 // NewAuthService creates a new auth service
-func NewAuthService(userRepo *repositories.UserRepository) *AuthService {
+func NewAuthService(userRepo *repositories.UserRepository, resetRepo *repositories.PasswordResetRepository, emailService EmailService, googleVerifier TokenVerifier, googleClientID string) *AuthService {
 	return &AuthService{
-		userRepo: userRepo,
+		userRepo:       userRepo,
+		resetRepo:      resetRepo,
+		emailService:   emailService,
+		googleVerifier: googleVerifier,
+		googleClientID: googleClientID,
 	}
 }
 
@@ -28,6 +84,7 @@ type SignupRequest struct {
 	Phone    string `json:"phone"`
 	Password string `json:"password" binding:"required"`
 	Language string `json:"language"`
+	Bio      string `json:"bio"`
 }
 
 // LoginRequest represents login request data
@@ -36,10 +93,29 @@ type LoginRequest struct {
 	Password   string `json:"password" binding:"required"`
 }
 
-// AuthResponse represents auth response with token and user data
+// GoogleLoginRequest represents Google sign-in request data
+type GoogleLoginRequest struct {
+	IDToken string `json:"id_token" binding:"required"`
+}
+
+// ForgotPasswordRequest represents forgot password request data
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required"`
+}
+
+// ResetPasswordRequest represents reset password request data
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// AuthResponse represents auth response with token and user data. For an account with 2FA
+// the password step returns Pending2FA and a TwoFactorToken instead of Token.
 type AuthResponse struct {
-	Token string            `json:"token"`
-	User  models.PublicUser `json:"user"`
+	Token          string            `json:"token,omitempty"`
+	User           models.PublicUser `json:"user"`
+	Pending2FA     bool              `json:"pending_2fa,omitempty"`
+	TwoFactorToken string            `json:"two_factor_token,omitempty"` // Traded with a TOTP code at /auth/2fa/confirm
 }
 
 // Signup registers a new user
@@ -55,21 +131,29 @@ func (s *AuthService) Signup(req SignupRequest) (*AuthResponse, error) {
 		return nil, err
 	}
 	if req.Phone != "" {
-		if err := utils.ValidatePhone(req.Phone); err != nil {
+		// Store E.164 so the same number is never registered twice in different formats
+		phone, err := utils.NormalizePhone(req.Phone)
+		if err != nil {
 			return nil, err
 		}
+		req.Phone = phone
+	}
+	if err := utils.ValidateBio(req.Bio); err != nil {
+		return nil, err
 	}
 
-	// Check if user already exists
-	if _, err := s.userRepo.FindByUsername(req.Username); err == nil {
-		return nil, errors.New("username already taken")
+	// Check if user already exists, on the primary so a replica lagging behind a signup can't
+	// let a duplicate through
+	primary := s.userRepo.WithPrimary()
+	if _, err := primary.FindByUsername(req.Username); err == nil {
+		return nil, ErrUsernameTaken
 	}
-	if _, err := s.userRepo.FindByEmail(req.Email); err == nil {
-		return nil, errors.New("email already registered")
+	if _, err := primary.FindByEmail(req.Email); err == nil {
+		return nil, ErrEmailTaken
 	}
 	if req.Phone != "" {
-		if _, err := s.userRepo.FindByPhone(req.Phone); err == nil {
-			return nil, errors.New("phone already registered")
+		if _, err := primary.FindByPhone(req.Phone); err == nil {
+			return nil, ErrPhoneTaken
 		}
 	}
 
@@ -95,6 +179,7 @@ func (s *AuthService) Signup(req SignupRequest) (*AuthResponse, error) {
 		Phone:    utils.SanitizeString(req.Phone),
 		Password: hashedPassword,
 		Language: language,
+		Bio:      utils.SanitizeString(req.Bio),
 	}
 
 	if err := s.userRepo.Create(user); err != nil {
@@ -114,7 +199,7 @@ func (s *AuthService) Signup(req SignupRequest) (*AuthResponse, error) {
 }
 
 // Login authenticates a user
-func (s *AuthService) Login(req LoginRequest) (*AuthResponse, error) {
+func (s *AuthService) Login(ctx context.Context, req LoginRequest) (*AuthResponse, error) {
 	// Find user by identifier (email, username, or phone)
 	var user *models.User
 	var err error
@@ -126,19 +211,46 @@ func (s *AuthService) Login(req LoginRequest) (*AuthResponse, error) {
 		user, err = s.userRepo.FindByEmail(identifier)
 	} else if strings.HasPrefix(identifier, "+") {
 		// Try phone
-		user, err = s.userRepo.FindByPhone(identifier)
+		user, err = s.userRepo.FindByNormalizedPhone(identifier)
 	} else {
-		// Try username
+		// Try username, then a phone number written without its country code
 		user, err = s.userRepo.FindByUsername(identifier)
+		if err != nil {
+			if byPhone, phoneErr := s.userRepo.FindByNormalizedPhone(identifier); phoneErr == nil {
+				user, err = byPhone, nil
+			}
+		}
 	}
 
 	if err != nil {
-		return nil, errors.New("invalid credentials")
+		return nil, ErrInvalidCredentials
 	}
 
 	// Check password
 	if !utils.CheckPassword(req.Password, user.Password) {
-		return nil, errors.New("invalid credentials")
+		return nil, ErrInvalidCredentials
+	}
+
+	// Lazily move bcrypt hashes to Argon2id while the plaintext is at hand
+	if utils.IsBcryptHash(user.Password) {
+		s.upgradePasswordHash(ctx, user.ID, req.Password)
+	}
+
+	return s.completeLogin(user)
+}
+
+// completeLogin issues a session token, or a two-factor token when the user has 2FA enabled
+func (s *AuthService) completeLogin(user *models.User) (*AuthResponse, error) {
+	if user.TOTPEnabled {
+		twoFactorToken, err := utils.GenerateTwoFactorToken(user.ID)
+		if err != nil {
+			return nil, err
+		}
+		return &AuthResponse{
+			User:           user.ToPublicUser(),
+			Pending2FA:     true,
+			TwoFactorToken: twoFactorToken,
+		}, nil
 	}
 
 	// Update online status
@@ -156,6 +268,223 @@ func (s *AuthService) Login(req LoginRequest) (*AuthResponse, error) {
 	}, nil
 }
 
+// upgradePasswordHash rehashes a verified password with Argon2id in the background. Failures
+// are logged with ctx's request ID.
+func (s *AuthService) upgradePasswordHash(ctx context.Context, userID uuid.UUID, password string) {
+	s.passwordUpgrades.Add(1)
+	go func() {
+		defer s.passwordUpgrades.Done()
+
+		newHash, err := utils.HashPasswordArgon2(password)
+		if err != nil {
+			utils.LoggerFromContext(ctx).Error("Password upgrade failed", "user_id", userID, "error", err)
+			return
+		}
+		if err := s.userRepo.UpdatePasswordHash(userID, newHash); err != nil {
+			utils.LoggerFromContext(ctx).Error("Password upgrade failed", "user_id", userID, "error", err)
+		}
+	}()
+}
+
+// WaitForPasswordUpgrades blocks until every background password rehash has finished
+func (s *AuthService) WaitForPasswordUpgrades() {
+	s.passwordUpgrades.Wait()
+}
+
+// GoogleLogin authenticates a user with a Google ID token, creating or linking the account as needed
+func (s *AuthService) GoogleLogin(idToken string) (*AuthResponse, error) {
+	if s.googleVerifier == nil || s.googleClientID == "" {
+		return nil, errors.New("google login is not configured")
+	}
+
+	payload, err := s.googleVerifier.Validate(context.Background(), idToken, s.googleClientID)
+	if err != nil {
+		return nil, errors.New("invalid google token")
+	}
+
+	email, _ := payload.Claims["email"].(string)
+	name, _ := payload.Claims["name"].(string)
+	picture, _ := payload.Claims["picture"].(string)
+	emailVerified, _ := payload.Claims["email_verified"].(bool)
+
+	email = strings.ToLower(utils.SanitizeString(email))
+	if email == "" || payload.Subject == "" {
+		return nil, errors.New("invalid google token")
+	}
+	if !emailVerified {
+		return nil, errors.New("google email is not verified")
+	}
+
+	// On the primary, so an account created moments ago is linked rather than duplicated
+	user, err := s.userRepo.WithPrimary().FindByEmail(email)
+	switch {
+	case err == nil:
+		// Link existing account on first Google sign-in
+		if user.GoogleID == "" {
+			user.GoogleID = payload.Subject
+			if user.Avatar == "" {
+				user.Avatar = picture
+			}
+			if err := s.userRepo.Update(user); err != nil {
+				return nil, err
+			}
+		} else if user.GoogleID != payload.Subject {
+			return nil, errors.New("account is linked to a different google account")
+		}
+	case errors.Is(err, repositories.ErrUserNotFound):
+		user, err = s.createGoogleUser(email, name, picture, payload.Subject)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	return s.completeLogin(user)
+}
+
+// createGoogleUser registers a new user from Google profile claims
+func (s *AuthService) createGoogleUser(email, name, picture, googleID string) (*models.User, error) {
+	// Random password the user never sees: Google accounts always sign in through Google
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	hashedPassword, err := utils.HashPassword(hex.EncodeToString(secret))
+	if err != nil {
+		return nil, err
+	}
+
+	username, err := s.uniqueUsername(name, email)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{
+		Username: username,
+		Email:    email,
+		Password: hashedPassword,
+		Avatar:   picture,
+		GoogleID: googleID,
+		Language: "en",
+	}
+
+	if err := s.userRepo.Create(user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// maxGeneratedUsernameLength leaves room for a "_999" suffix within ValidateUsername's limit
+const maxGeneratedUsernameLength = 25
+
+// uniqueUsername derives a username from the Google display name, adding a numeric suffix if taken
+func (s *AuthService) uniqueUsername(name, email string) (string, error) {
+	base := usernameBase(name, email)
+
+	username := base
+	for i := 2; i < 1000; i++ {
+		if err := utils.ValidateUsername(username); err != nil {
+			return "", err
+		}
+		_, err := s.userRepo.WithPrimary().FindByUsername(username)
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			return username, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		username = fmt.Sprintf("%s_%d", base, i)
+	}
+
+	return "", errors.New("could not generate a unique username")
+}
+
+// usernameBase turns a display name, or failing that the email's local part, into the
+// characters ValidateUsername accepts, e.g. "Zoë Martin" becomes "Zo_Martin"
+func usernameBase(name, email string) string {
+	local, _, _ := strings.Cut(email, "@")
+	for _, candidate := range []string{name, local} {
+		// Unaccepted characters become spaces, so words stay separated by a single underscore
+		cleaned := strings.Map(func(r rune) rune {
+			switch {
+			case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+				return r
+			}
+			return ' '
+		}, utils.SanitizeString(candidate))
+		base := strings.Join(strings.Fields(cleaned), "_")
+		if len(base) > maxGeneratedUsernameLength {
+			base = strings.TrimRight(base[:maxGeneratedUsernameLength], "_")
+		}
+		if len(base) >= 3 {
+			return base
+		}
+	}
+	return "user"
+}
+
+// RequestPasswordReset emails a single-use reset token to the account registered with email.
+// Unknown emails succeed silently so the endpoint cannot be used to discover accounts.
+func (s *AuthService) RequestPasswordReset(email string) error {
+	user, err := s.userRepo.FindByEmail(strings.ToLower(utils.SanitizeString(email)))
+	if err != nil {
+		return nil
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(secret)
+
+	if err := s.resetRepo.Create(&models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hashResetToken(token),
+		ExpiresAt: time.Now().Add(passwordResetTTL),
+	}); err != nil {
+		return err
+	}
+
+	subject := utils.T(user.Language, "password_reset_subject")
+	body := utils.T(user.Language, "password_reset_body", user.Username, token, int(passwordResetTTL.Minutes()))
+	return s.emailService.Send(user.Email, subject, body)
+}
+
+// ResetPassword sets a new password for the owner of a valid reset token, then invalidates
+// the token and any other outstanding tokens of that user
+func (s *AuthService) ResetPassword(token, newPassword string) error {
+	// Validate before consuming so a rejected password doesn't burn the token
+	if err := utils.ValidatePassword(newPassword); err != nil {
+		return err
+	}
+
+	resetToken, err := s.resetRepo.Consume(hashResetToken(token))
+	if err != nil {
+		return err
+	}
+	if resetToken == nil {
+		return ErrInvalidResetToken
+	}
+
+	hashedPassword, err := utils.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	if err := s.userRepo.UpdatePasswordHash(resetToken.UserID, hashedPassword); err != nil {
+		return err
+	}
+
+	return s.resetRepo.InvalidateForUser(resetToken.UserID)
+}
+
+// hashResetToken returns the hex SHA-256 of a reset token, the form stored in the database
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 // Logout logs out a user
 func (s *AuthService) Logout(userID string) error {
 	// Update online status
@@ -169,7 +498,7 @@ func (s *AuthService) CheckUsernameAvailability(username string) (bool, error) {
 		return false, err
 	}
 
-	_, err := s.userRepo.FindByUsername(username)
+	_, err := s.userRepo.WithPrimary().FindByUsername(username)
 	if err != nil {
 		if err.Error() == "user not found" {
 			return true, nil
@@ -186,7 +515,7 @@ func (s *AuthService) CheckEmailAvailability(email string) (bool, error) {
 		return false, err
 	}
 
-	_, err := s.userRepo.FindByEmail(email)
+	_, err := s.userRepo.WithPrimary().FindByEmail(email)
 	if err != nil {
 		if err.Error() == "user not found" {
 			return true, nil
@@ -207,7 +536,7 @@ func (s *AuthService) CheckPhoneAvailability(phone string) (bool, error) {
 		return false, err
 	}
 
-	_, err := s.userRepo.FindByPhone(phone)
+	_, err := s.userRepo.WithPrimary().FindByNormalizedPhone(phone)
 	if err != nil {
 		if err.Error() == "user not found" {
 			return true, nil
@@ -232,3 +561,152 @@ func (s *AuthService) ValidateToken(token string) (*models.User, error) {
 
 	return user, nil
 }
+
+// totpIssuer names the service in authenticator apps
+const totpIssuer = "MMS"
+
+// totpQRCodeSize is the width and height in pixels of the setup QR code
+const totpQRCodeSize = 256
+
+var (
+	// ErrInvalidTOTPCode is returned when a two-factor code doesn't match the user's secret
+	ErrInvalidTOTPCode = errors.New("invalid two-factor code")
+	// ErrTOTPNotSetUp is returned when verifying before 2FA setup
+	ErrTOTPNotSetUp = errors.New("two-factor authentication is not set up")
+	// ErrTOTPAlreadyEnabled is returned when setting up 2FA that is already on
+	ErrTOTPAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	// ErrTOTPNotEnabled is returned when disabling 2FA that is off
+	ErrTOTPNotEnabled = errors.New("two-factor authentication is not enabled")
+	// ErrInvalidTwoFactorToken is returned for a missing, expired or forged two-factor token
+	ErrInvalidTwoFactorToken = errors.New("invalid or expired two-factor token")
+)
+
+// TwoFactorSetupResponse holds what an authenticator app needs to add the account
+type TwoFactorSetupResponse struct {
+	Secret     string `json:"secret"`      // Base32, for manual entry
+	OTPAuthURL string `json:"otpauth_url"` // otpauth://totp/... URI encoded in the QR code
+	QRCode     string `json:"qr_code"`     // Base64-encoded PNG
+}
+
+// TwoFactorCodeRequest carries a 6-digit code from the user's authenticator app
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// TwoFactorConfirmRequest completes a login that returned pending_2fa
+type TwoFactorConfirmRequest struct {
+	Token string `json:"token" binding:"required"` // two_factor_token from the login response
+	Code  string `json:"code" binding:"required"`
+}
+
+// SetupTwoFactor generates a new TOTP secret for userID. Login doesn't ask for codes until
+// VerifyTwoFactor confirms the user's app produces them; running setup again replaces the secret.
+func (s *AuthService) SetupTwoFactor(userID uuid.UUID) (*TwoFactorSetupResponse, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{Issuer: totpIssuer, AccountName: user.Email})
+	if err != nil {
+		return nil, err
+	}
+	image, err := key.Image(totpQRCodeSize, totpQRCodeSize)
+	if err != nil {
+		return nil, err
+	}
+	var qrCode bytes.Buffer
+	if err := png.Encode(&qrCode, image); err != nil {
+		return nil, err
+	}
+
+	encryptedSecret, err := utils.Encrypt(key.Secret())
+	if err != nil {
+		return nil, errors.New("failed to encrypt two-factor secret")
+	}
+	if err := s.userRepo.UpdateTOTP(userID, encryptedSecret, false); err != nil {
+		return nil, err
+	}
+
+	return &TwoFactorSetupResponse{
+		Secret:     key.Secret(),
+		OTPAuthURL: key.URL(),
+		QRCode:     base64.StdEncoding.EncodeToString(qrCode.Bytes()),
+	}, nil
+}
+
+// VerifyTwoFactor checks a code against the secret from SetupTwoFactor and turns 2FA on
+func (s *AuthService) VerifyTwoFactor(userID uuid.UUID, code string) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.TOTPEnabled {
+		return ErrTOTPAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return ErrTOTPNotSetUp
+	}
+	if err := checkTOTPCode(user, code); err != nil {
+		return err
+	}
+	return s.userRepo.UpdateTOTP(userID, user.TOTPSecret, true)
+}
+
+// DisableTwoFactor turns 2FA off and forgets the secret. A current code is required so a stolen
+// session token alone can't remove the second factor.
+func (s *AuthService) DisableTwoFactor(userID uuid.UUID, code string) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		return ErrTOTPNotEnabled
+	}
+	if err := checkTOTPCode(user, code); err != nil {
+		return err
+	}
+	return s.userRepo.UpdateTOTP(userID, "", false)
+}
+
+// ConfirmTwoFactor trades the two-factor token from the password step and a valid code for a
+// session token
+func (s *AuthService) ConfirmTwoFactor(req TwoFactorConfirmRequest) (*AuthResponse, error) {
+	userID, err := utils.ValidateTwoFactorToken(req.Token)
+	if err != nil {
+		return nil, ErrInvalidTwoFactorToken
+	}
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil || !user.TOTPEnabled {
+		return nil, ErrInvalidTwoFactorToken
+	}
+	if err := checkTOTPCode(user, req.Code); err != nil {
+		return nil, err
+	}
+
+	_ = s.userRepo.UpdateOnlineStatus(user.ID, true)
+
+	token, err := utils.GenerateToken(user.ID, user.Username, user.Email)
+	if err != nil {
+		return nil, err
+	}
+	return &AuthResponse{
+		Token: token,
+		User:  user.ToPublicUser(),
+	}, nil
+}
+
+// checkTOTPCode validates code against the user's stored secret, allowing one step of clock drift
+func checkTOTPCode(user *models.User, code string) error {
+	secret, err := utils.Decrypt(user.TOTPSecret)
+	if err != nil {
+		return errors.New("failed to decrypt two-factor secret")
+	}
+	if !totp.Validate(strings.TrimSpace(code), secret) {
+		return ErrInvalidTOTPCode
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"mms-backend/utils"
+)
+
+func TestUsernameBase(t *testing.T) {
+	cases := []struct {
+		name, email, want string
+	}{
+		{"Dave Google", "dave@example.com", "Dave_Google"},
+		{"Zoë 🚀 Martin", "zoe@example.com", "Zo_Martin"},
+		{"ゆき", "yuki.google@example.com", "yuki_google"},
+		{"", "x@example.com", "user"},
+		{strings.Repeat("Long Name ", 10), "long@example.com", "Long_Name_Long_Name_Long"},
+	}
+	for _, c := range cases {
+		got := usernameBase(c.name, c.email)
+		if got != c.want {
+			t.Errorf("usernameBase(%q, %q) = %q, want %q", c.name, c.email, got, c.want)
+		}
+		if err := utils.ValidateUsername(got + "_999"); err != nil {
+			t.Errorf("usernameBase(%q, %q) = %q, invalid with a suffix: %v", c.name, c.email, got, err)
+		}
+	}
+}
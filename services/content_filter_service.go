@@ -0,0 +1,206 @@
+package services
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"mms-backend/models"
+	"mms-backend/repositories"
+
+	"github.com/google/uuid"
+)
+
+// contentFilterRefreshInterval is how long loaded filter rules are used before reloading them
+const contentFilterRefreshInterval = 5 * time.Minute
+
+// filterMask replaces content matched by a filter rule
+const filterMask = "***"
+
+// Content filter errors
+var (
+	ErrContentRejected       = errors.New("message contains prohibited content")
+	ErrInvalidFilterSeverity = errors.New("severity must be one of low, medium or high")
+	ErrInvalidFilterPattern  = errors.New("word is not a valid regular expression")
+	ErrContentFilterNotFound = errors.New("content filter not found")
+)
+
+// AddContentFilterRequest represents a request to add a content filter rule
+type AddContentFilterRequest struct {
+	Word     string `json:"word" binding:"required"`
+	Severity string `json:"severity"` // low (default), medium or high
+	IsRegex  bool   `json:"is_regex"`
+}
+
+// FilterRule is a content filter compiled for matching
+type FilterRule struct {
+	ID       uuid.UUID
+	Word     string
+	Severity models.FilterSeverity
+	pattern  *regexp.Regexp
+}
+
+// NewFilterRule compiles a content filter. Plain words match as whole words ignoring case;
+// regex filters are used as written.
+func NewFilterRule(filter models.ContentFilter) (FilterRule, error) {
+	expr := `(?i)\b` + regexp.QuoteMeta(filter.Word) + `\b`
+	if filter.IsRegex {
+		expr = filter.Word
+	}
+
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		return FilterRule{}, ErrInvalidFilterPattern
+	}
+
+	return FilterRule{
+		ID:       filter.ID,
+		Word:     filter.Word,
+		Severity: filter.Severity,
+		pattern:  pattern,
+	}, nil
+}
+
+// FilterViolation records a filter rule that matched message content
+type FilterViolation struct {
+	Word     string                `json:"word"`
+	Severity models.FilterSeverity `json:"severity"`
+}
+
+// ContentFilterService masks or rejects message content matching moderation rules
+type ContentFilterService struct {
+	filterRepo *repositories.ContentFilterRepository
+
+	mu       sync.Mutex
+	rules    []FilterRule
+	loadedAt time.Time
+}
+
+// NewContentFilterService creates a new content filter service
+func NewContentFilterService(filterRepo *repositories.ContentFilterRepository) *ContentFilterService {
+	return &ContentFilterService{
+		filterRepo: filterRepo,
+	}
+}
+
+// Filter masks every match of the filter rules in content with *** and returns the masked
+// content along with the rules that matched
+func (s *ContentFilterService) Filter(content string) (string, []FilterViolation, error) {
+	rules, err := s.loadRules()
+	if err != nil {
+		return content, nil, err
+	}
+
+	filtered, violations := applyFilterRules(rules, content)
+	return filtered, violations, nil
+}
+
+// applyFilterRules masks the matches of each rule in turn and records the rules that matched
+func applyFilterRules(rules []FilterRule, content string) (string, []FilterViolation) {
+	var violations []FilterViolation
+	for _, rule := range rules {
+		if !rule.pattern.MatchString(content) {
+			continue
+		}
+		content = rule.pattern.ReplaceAllLiteralString(content, filterMask)
+		violations = append(violations, FilterViolation{Word: rule.Word, Severity: rule.Severity})
+	}
+	return content, violations
+}
+
+// hasHighSeverity reports whether any violation requires the content to be rejected
+func hasHighSeverity(violations []FilterViolation) bool {
+	for _, violation := range violations {
+		if violation.Severity == models.FilterSeverityHigh {
+			return true
+		}
+	}
+	return false
+}
+
+// loadRules returns the cached rules, reloading them from the database once they are stale
+func (s *ContentFilterService) loadRules() ([]FilterRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.loadedAt.IsZero() && time.Since(s.loadedAt) < contentFilterRefreshInterval {
+		return s.rules, nil
+	}
+
+	filters, err := s.filterRepo.List()
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]FilterRule, 0, len(filters))
+	for _, filter := range filters {
+		rule, err := NewFilterRule(filter)
+		if err != nil {
+			continue // Validated on creation; skip anything edited into an invalid state
+		}
+		rules = append(rules, rule)
+	}
+
+	s.rules = rules
+	s.loadedAt = time.Now()
+	return rules, nil
+}
+
+// invalidate makes the next Filter call reload the rules
+func (s *ContentFilterService) invalidate() {
+	s.mu.Lock()
+	s.loadedAt = time.Time{}
+	s.mu.Unlock()
+}
+
+// AddRule validates and stores a new content filter rule
+func (s *ContentFilterService) AddRule(req AddContentFilterRequest) (*models.ContentFilter, error) {
+	word := req.Word
+	if !req.IsRegex {
+		word = strings.TrimSpace(word)
+	}
+	if word == "" {
+		return nil, errors.New("word is required")
+	}
+
+	filter := &models.ContentFilter{
+		Word:     word,
+		Severity: models.FilterSeverity(req.Severity),
+		IsRegex:  req.IsRegex,
+	}
+	if filter.Severity == "" {
+		filter.Severity = models.FilterSeverityLow
+	}
+	if !filter.Severity.IsValid() {
+		return nil, ErrInvalidFilterSeverity
+	}
+	if _, err := NewFilterRule(*filter); err != nil {
+		return nil, err
+	}
+
+	if err := s.filterRepo.Create(filter); err != nil {
+		return nil, err
+	}
+	s.invalidate()
+	return filter, nil
+}
+
+// ListRules returns every content filter rule
+func (s *ContentFilterService) ListRules() ([]models.ContentFilter, error) {
+	return s.filterRepo.List()
+}
+
+// DeleteRule removes a content filter rule
+func (s *ContentFilterService) DeleteRule(id uuid.UUID) error {
+	deleted, err := s.filterRepo.Delete(id)
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrContentFilterNotFound
+	}
+	s.invalidate()
+	return nil
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"mms-backend/models"
+)
+
+// newTestFilterService returns a service whose rule cache is already loaded, so Filter never hits the database
+func newTestFilterService(t *testing.T, filters ...models.ContentFilter) *ContentFilterService {
+	t.Helper()
+	rules := make([]FilterRule, 0, len(filters))
+	for _, filter := range filters {
+		rule, err := NewFilterRule(filter)
+		if err != nil {
+			t.Fatalf("NewFilterRule(%q) returned error: %v", filter.Word, err)
+		}
+		rules = append(rules, rule)
+	}
+	return &ContentFilterService{rules: rules, loadedAt: time.Now()}
+}
+
+func TestContentFilter_ExactMatch(t *testing.T) {
+	s := newTestFilterService(t, models.ContentFilter{Word: "spam", Severity: models.FilterSeverityLow})
+
+	filtered, violations, err := s.Filter("Buy SPAM now, spam spam! But not spammers.")
+	if err != nil {
+		t.Fatalf("Filter returned error: %v", err)
+	}
+	if want := "Buy *** now, *** ***! But not spammers."; filtered != want {
+		t.Fatalf("filtered = %q, want %q", filtered, want)
+	}
+	if len(violations) != 1 || violations[0].Word != "spam" || violations[0].Severity != models.FilterSeverityLow {
+		t.Fatalf("unexpected violations: %+v", violations)
+	}
+
+	filtered, violations, _ = s.Filter("nothing to see here")
+	if filtered != "nothing to see here" || len(violations) != 0 {
+		t.Fatalf("clean content changed: %q %+v", filtered, violations)
+	}
+}
+
+func TestContentFilter_RegexRule(t *testing.T) {
+	s := newTestFilterService(t, models.ContentFilter{
+		Word:     `\b\d{4}[- ]?\d{4}[- ]?\d{4}[- ]?\d{4}\b`,
+		Severity: models.FilterSeverityMedium,
+		IsRegex:  true,
+	})
+
+	filtered, violations, err := s.Filter("card 4111-1111-1111-1111 and 4111111111111111")
+	if err != nil {
+		t.Fatalf("Filter returned error: %v", err)
+	}
+	if want := "card *** and ***"; filtered != want {
+		t.Fatalf("filtered = %q, want %q", filtered, want)
+	}
+	if len(violations) != 1 || violations[0].Severity != models.FilterSeverityMedium {
+		t.Fatalf("unexpected violations: %+v", violations)
+	}
+}
+
+func TestContentFilter_InvalidRegexRejected(t *testing.T) {
+	_, err := NewFilterRule(models.ContentFilter{Word: "([a-z", IsRegex: true})
+	if !errors.Is(err, ErrInvalidFilterPattern) {
+		t.Fatalf("expected ErrInvalidFilterPattern, got %v", err)
+	}
+
+	// The same text is a valid plain word: it is matched literally
+	if _, err := NewFilterRule(models.ContentFilter{Word: "([a-z"}); err != nil {
+		t.Fatalf("plain word rejected: %v", err)
+	}
+}
+
+func TestContentFilter_SeverityRejection(t *testing.T) {
+	s := newTestFilterService(t,
+		models.ContentFilter{Word: "darn", Severity: models.FilterSeverityLow},
+		models.ContentFilter{Word: "threat", Severity: models.FilterSeverityHigh},
+	)
+
+	_, violations, _ := s.Filter("darn it")
+	if hasHighSeverity(violations) {
+		t.Fatal("low severity match should not reject the message")
+	}
+
+	_, violations, _ = s.Filter("darn, this is a threat")
+	if len(violations) != 2 {
+		t.Fatalf("expected both rules to match, got %+v", violations)
+	}
+	if !hasHighSeverity(violations) {
+		t.Fatal("high severity match should reject the message")
+	}
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+
+	"mms-backend/config"
+)
+
+// ErrEmailNotConfigured is returned when an email is sent without an SMTP server configured
+var ErrEmailNotConfigured = errors.New("email is not configured")
+
+// EmailService sends plain-text emails
+type EmailService interface {
+	Send(to, subject, body string) error
+}
+
+// smtpEmailService sends emails through an SMTP server using PLAIN auth
+type smtpEmailService struct {
+	cfg config.SMTPConfig
+}
+
+// NewSMTPEmailService returns the default EmailService, backed by the configured SMTP server
+func NewSMTPEmailService(cfg config.SMTPConfig) EmailService {
+	return &smtpEmailService{cfg: cfg}
+}
+
+func (s *smtpEmailService) Send(to, subject, body string) error {
+	if s.cfg.Host == "" {
+		return ErrEmailNotConfigured
+	}
+	// Header injection guard: addresses and subject must stay on one line
+	if strings.ContainsAny(to+subject, "\r\n") {
+		return errors.New("invalid email header")
+	}
+
+	var auth smtp.Auth
+	if s.cfg.User != "" {
+		auth = smtp.PlainAuth("", s.cfg.User, s.cfg.Pass, s.cfg.Host)
+	}
+
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s",
+		s.cfg.From, to, subject, body)
+
+	return smtp.SendMail(net.JoinHostPort(s.cfg.Host, s.cfg.Port), auth, s.cfg.From, []string{to}, []byte(msg))
+}
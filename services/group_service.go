@@ -1,15 +1,48 @@
 package services
 
 import (
+	"bytes"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"strconv"
+	"time"
+	"unicode/utf8"
 
 	"mms-backend/models"
 	"mms-backend/repositories"
 	"mms-backend/utils"
+	"mms-backend/websocket"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
+// Permission errors returned by GroupService
+var (
+	ErrNotGroupMember  = errors.New("not a member of this group")
+	ErrNotGroupAdmin   = errors.New("only admins can manage members")
+	ErrNotGroupOwner   = errors.New("only the owner can perform this action")
+	ErrOutranked       = errors.New("cannot manage a member with an equal or higher role")
+	ErrGroupPermission = errors.New("your role in this group does not allow this action")
+	ErrNotGroupSender  = errors.New("only the sender or a group moderator can edit this message")
+	ErrCannotDelete    = errors.New("only the sender or a group moderator can delete this message")
+)
+
+// ErrMessageNotInGroup is returned when a message ID given for a group belongs to another group
+var ErrMessageNotInGroup = errors.New("message does not belong to this group")
+
+// ErrAnnouncementsOnly is returned when a member posts in a group where only moderators and above can
+var ErrAnnouncementsOnly = errors.New("only admins and moderators can post in this group")
+
+// MaxGroupDraftLength caps the number of characters kept in a group draft
+const MaxGroupDraftLength = 10000
+
+// ErrGroupDraftTooLong is returned when a group draft exceeds MaxGroupDraftLength
+var ErrGroupDraftTooLong = errors.New("draft must be at most 10000 characters")
+
 // GroupService handles group business logic
 type GroupService struct {
 	groupRepo        *repositories.GroupRepository
@@ -17,6 +50,8 @@ type GroupService struct {
 	userRepo         *repositories.UserRepository
 	notificationRepo *repositories.NotificationRepository
 	pushService      *PushService
+	unreadService    *UnreadService
+	wsHub            *websocket.Hub
 }
 
 // NewGroupService creates a new group service
@@ -26,6 +61,8 @@ func NewGroupService(
 	userRepo *repositories.UserRepository,
 	notificationRepo *repositories.NotificationRepository,
 	pushService *PushService,
+	unreadService *UnreadService,
+	wsHub *websocket.Hub,
 ) *GroupService {
 	return &GroupService{
 		groupRepo:        groupRepo,
@@ -33,6 +70,8 @@ func NewGroupService(
 		userRepo:         userRepo,
 		notificationRepo: notificationRepo,
 		pushService:      pushService,
+		unreadService:    unreadService,
+		wsHub:            wsHub,
 	}
 }
 
@@ -48,6 +87,69 @@ type CreateGroupRequest struct {
 type SendGroupMessageRequest struct {
 	GroupID uuid.UUID `json:"group_id" binding:"required"`
 	Content string    `json:"content" binding:"required"`
+	// One of text (default), location, contact or audio; see utils.ValidateMessageContent
+	ContentType string `json:"content_type"`
+}
+
+// MaxBulkAddMembers caps the number of users added to a group in one request
+const MaxBulkAddMembers = 100
+
+// ErrBulkAddSize is returned when a bulk add request has no user IDs or more than MaxBulkAddMembers
+var ErrBulkAddSize = errors.New("user_ids must contain between 1 and 100 user ids")
+
+// MaxGroupExportRows caps the number of messages in one group export
+const MaxGroupExportRows = 100000
+
+// Group export errors
+var (
+	ErrInvalidExportFormat = errors.New("format must be json or csv")
+	ErrExportTooLarge      = errors.New("export is limited to 100000 messages")
+)
+
+// GroupMessageExport is one exported group message
+type GroupMessageExport struct {
+	ID             uuid.UUID `json:"id"`
+	SenderUsername string    `json:"sender_username"`
+	Content        string    `json:"content"` // Decrypted content
+	SentAt         time.Time `json:"sent_at"`
+	IsDeleted      bool      `json:"is_deleted"`
+	Edited         bool      `json:"edited"`
+}
+
+// BulkAddMembersRequest represents a request to add several users to a group
+type BulkAddMembersRequest struct {
+	UserIDs []uuid.UUID `json:"user_ids" binding:"required"`
+}
+
+// BulkAddError reports why one user of a bulk add could not be added
+type BulkAddError struct {
+	UserID uuid.UUID `json:"user_id"`
+	Error  string    `json:"error"`
+}
+
+// MuteGroupRequest represents a group mute request; a null until mutes indefinitely
+type MuteGroupRequest struct {
+	Until *time.Time `json:"until"`
+}
+
+// Invite link lifetimes, in hours
+const (
+	DefaultInviteExpiryHours = 24
+	MaxInviteExpiryHours     = 30 * 24
+)
+
+// Invite link errors
+var (
+	ErrInviteExpiry  = errors.New("expires_in_hours must be between 1 and 720")
+	ErrInviteMaxUses = errors.New("max_uses cannot be negative")
+	ErrInvalidInvite = errors.New("invalid or expired invite link")
+)
+
+// CreateInviteRequest represents an invite link request. expires_in_hours defaults to 24;
+// max_uses of 0 allows unlimited joins until the link expires.
+type CreateInviteRequest struct {
+	ExpiresInHours int `json:"expires_in_hours"`
+	MaxUses        int `json:"max_uses"`
 }
 
 // CreateGroup creates a new group
@@ -64,22 +166,26 @@ func (s *GroupService) CreateGroup(creatorID uuid.UUID, req CreateGroupRequest)
 		Type:        req.Type,
 		CreatedBy:   creatorID,
 	}
+	if creator, err := s.userRepo.FindByID(creatorID); err == nil && creator.Language != "" {
+		group.Language = creator.Language
+	}
 
 	if err := s.groupRepo.Create(group); err != nil {
 		return nil, err
 	}
 
-	// Add creator as admin
+	// Add creator as owner
 	creatorMember := &models.GroupMember{
 		GroupID: group.ID,
 		UserID:  creatorID,
-		Role:    models.MemberRoleAdmin,
+		Role:    models.MemberRoleOwner,
 	}
 	if err := s.groupRepo.AddMember(creatorMember); err != nil {
 		return nil, err
 	}
 
 	// Add other members
+	memberIDs := []uuid.UUID{creatorID}
 	for _, memberID := range req.MemberIDs {
 		if memberID == creatorID {
 			continue // Skip creator, already added
@@ -89,7 +195,10 @@ func (s *GroupService) CreateGroup(creatorID uuid.UUID, req CreateGroupRequest)
 			UserID:  memberID,
 			Role:    models.MemberRoleMember,
 		}
-		_ = s.groupRepo.AddMember(member)
+		if err := s.groupRepo.AddMember(member); err != nil {
+			continue
+		}
+		memberIDs = append(memberIDs, memberID)
 
 		// Send notification to invited members
 		user, err := s.userRepo.FindByID(memberID)
@@ -104,6 +213,11 @@ func (s *GroupService) CreateGroup(creatorID uuid.UUID, req CreateGroupRequest)
 		}
 	}
 
+	s.invalidatePresence(append([]uuid.UUID{creatorID}, req.MemberIDs...)...)
+	if s.wsHub != nil {
+		s.wsHub.SyncGroupMembers(group.ID, memberIDs)
+	}
+
 	return group, nil
 }
 
@@ -118,19 +232,40 @@ func (s *GroupService) GetGroup(groupID, userID uuid.UUID) (*models.Group, error
 	if group.Type == models.GroupTypePrivate {
 		isMember, err := s.groupRepo.IsMember(groupID, userID)
 		if err != nil || !isMember {
-			return nil, errors.New("not a member of this group")
+			return nil, ErrNotGroupMember
 		}
 	}
 
+	s.attachPinnedMessage(group)
 	return group, nil
 }
 
+// attachPinnedMessage fills in a group's decrypted pinned message. A pinned message that was
+// deleted since is left out.
+func (s *GroupService) attachPinnedMessage(group *models.Group) {
+	if group.PinnedMessageID == nil {
+		group.PinnedAt = nil
+		return
+	}
+
+	message, err := s.groupMessageRepo.FindByID(*group.PinnedMessageID)
+	if err != nil || message.IsDeleted {
+		return
+	}
+	responses, err := s.buildGroupMessageResponses([]models.GroupMessage{*message})
+	if err != nil || len(responses) == 0 {
+		return
+	}
+	group.PinnedMessage = &responses[0]
+}
+
 // SendGroupMessage sends a message to a group
 func (s *GroupService) SendGroupMessage(senderID uuid.UUID, req SendGroupMessageRequest) (*models.GroupMessageResponse, error) {
-	// Check if user is a member
-	isMember, err := s.groupRepo.IsMember(req.GroupID, senderID)
-	if err != nil || !isMember {
-		return nil, errors.New("not a member of this group")
+	// Check the sender's role against the group's posting permission
+	if err := s.requirePermission(req.GroupID, senderID, func(p *models.GroupPermissions) models.MemberRole {
+		return p.SendMessages
+	}); err != nil {
+		return nil, err
 	}
 
 	// Get group info
@@ -139,12 +274,27 @@ func (s *GroupService) SendGroupMessage(senderID uuid.UUID, req SendGroupMessage
 		return nil, errors.New("group not found")
 	}
 
+	if group.AnnouncementsOnly {
+		role, err := s.groupRepo.GetMemberRole(req.GroupID, senderID)
+		if err != nil || !models.CanModerate(role) {
+			return nil, ErrAnnouncementsOnly
+		}
+	}
+
 	// Get sender info
 	sender, err := s.userRepo.FindByID(senderID)
 	if err != nil {
 		return nil, errors.New("sender not found")
 	}
 
+	contentType := req.ContentType
+	if contentType == "" {
+		contentType = utils.ContentTypeText
+	}
+	if err := utils.ValidateMessageContent(contentType, req.Content); err != nil {
+		return nil, err
+	}
+
 	// Encrypt message content
 	encryptedContent, err := utils.Encrypt(req.Content)
 	if err != nil {
@@ -153,14 +303,19 @@ func (s *GroupService) SendGroupMessage(senderID uuid.UUID, req SendGroupMessage
 
 	// Create group message
 	message := &models.GroupMessage{
-		GroupID:  req.GroupID,
-		SenderID: senderID,
-		Content:  encryptedContent,
+		GroupID:     req.GroupID,
+		SenderID:    senderID,
+		Content:     encryptedContent,
+		ContentType: contentType,
 	}
 
 	if err := s.groupMessageRepo.Create(message); err != nil {
 		return nil, err
 	}
+	s.indexGroupMessage(message.ID, contentType, req.Content)
+
+	// The draft has been sent, so it no longer needs to be kept
+	_ = s.groupRepo.DeleteDraft(senderID, req.GroupID)
 
 	// Notify group members
 	members, err := s.groupRepo.GetGroupMembers(req.GroupID)
@@ -175,6 +330,11 @@ func (s *GroupService) SendGroupMessage(senderID uuid.UUID, req SendGroupMessage
 				continue // Don't notify sender
 			}
 
+			// Muted members still get the message, just not the notification
+			if muted, err := s.IsGroupMuted(member.UserID, req.GroupID); err == nil && muted {
+				continue
+			}
+
 			user, err := s.userRepo.FindByID(member.UserID)
 			if err != nil {
 				continue
@@ -189,29 +349,225 @@ func (s *GroupService) SendGroupMessage(senderID uuid.UUID, req SendGroupMessage
 			}
 			_ = s.notificationRepo.Create(notification)
 
-			// Send push notification
+			// Send push notification in the background
 			if user.DeviceToken != "" {
-				_ = s.pushService.SendGroupMessageNotification(user, group.Name, sender.Username, notificationContent)
+				s.pushService.QueueGroupMessageNotification(user, group.Name, sender.Username, notificationContent)
+
+				// Wake an offline app so the message is already there when it is opened
+				if s.wsHub == nil || !s.wsHub.IsUserOnline(user.ID) {
+					s.pushService.QueueSilentSync(user, map[string]interface{}{
+						"type":       "sync",
+						"message_id": message.ID.String(),
+						"group_id":   req.GroupID.String(),
+					})
+				}
+			}
+		}
+
+		// Deliver the message in real time to connected members
+		s.notifyGroupMembers(members, &websocket.Message{
+			Type:      "new_group_message",
+			SenderID:  senderID,
+			GroupID:   req.GroupID,
+			Content:   req.Content,
+			Data:      map[string]interface{}{"message_id": message.ID},
+			Timestamp: time.Now(),
+		})
+
+		for _, member := range members {
+			if member.UserID != senderID {
+				s.unreadService.PushUnreadCounts(member.UserID)
 			}
 		}
 	}
 
 	return &models.GroupMessageResponse{
-		ID:        message.ID,
-		GroupID:   message.GroupID,
-		SenderID:  message.SenderID,
-		Content:   req.Content,
-		CreatedAt: message.CreatedAt,
-		Sender:    sender.ToPublicUser(),
+		ID:          message.ID,
+		GroupID:     message.GroupID,
+		SenderID:    message.SenderID,
+		Content:     req.Content,
+		ContentType: message.ContentType,
+		Priority:    message.Priority,
+		CreatedAt:   message.CreatedAt,
+		Sender:      sender.ToPublicUser(),
+		ReadBy:      []models.ReadReceipt{},
+	}, nil
+}
+
+// EditGroupMessageRequest represents a group message edit request
+type EditGroupMessageRequest struct {
+	Content string `json:"content" binding:"required"`
+}
+
+// EditGroupMessage replaces the content of a group message, keeping the previous content, and
+// tells the group's members about the edit. The sender edits their own messages; moderators
+// edit those of members they outrank.
+func (s *GroupService) EditGroupMessage(messageID, userID uuid.UUID, req EditGroupMessageRequest) (*models.GroupMessageResponse, error) {
+	if req.Content == "" {
+		return nil, errors.New("content cannot be empty")
+	}
+
+	message, err := s.groupMessageRepo.FindByID(messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	// A user who left the group can no longer change what its members see
+	role, err := s.groupRepo.GetMemberRole(message.GroupID, userID)
+	if err != nil || role == "" {
+		return nil, ErrNotGroupMember
+	}
+
+	if message.SenderID != userID && !s.canModerateMessage(message, role) {
+		return nil, ErrNotGroupSender
+	}
+
+	if message.IsDeleted {
+		return nil, errors.New("cannot edit a deleted message")
+	}
+
+	// An edit keeps the message's content type, so the new content must match it
+	if message.ContentType != "" {
+		if err := utils.ValidateMessageContent(message.ContentType, req.Content); err != nil {
+			return nil, err
+		}
+	}
+
+	previousEncrypted := message.Content
+	previousDecrypted, err := utils.Decrypt(previousEncrypted)
+	if err != nil {
+		previousDecrypted = "[Encrypted]"
+	}
+
+	newEncrypted, err := utils.Encrypt(req.Content)
+	if err != nil {
+		return nil, errors.New("failed to encrypt message")
+	}
+
+	if err := s.groupMessageRepo.UpdateContent(messageID, newEncrypted, previousEncrypted); err != nil {
+		return nil, err
+	}
+	s.indexGroupMessage(messageID, message.ContentType, req.Content)
+	now := time.Now()
+
+	if members, err := s.groupRepo.GetGroupMembers(message.GroupID); err == nil {
+		s.notifyGroupMembers(members, &websocket.Message{
+			Type:     "group_message_edited",
+			SenderID: userID, // Who edited it, not necessarily the sender
+			GroupID:  message.GroupID,
+			Content:  req.Content,
+			Data: map[string]interface{}{
+				"message_id": messageID,
+				"edited_at":  now,
+			},
+			Timestamp: now,
+		})
+	}
+
+	readers, err := s.groupMessageRepo.GetReaders(messageID)
+	if err != nil || readers == nil {
+		readers = []models.ReadReceipt{}
+	}
+
+	return &models.GroupMessageResponse{
+		ID:              message.ID,
+		GroupID:         message.GroupID,
+		SenderID:        message.SenderID,
+		Content:         req.Content,
+		ContentType:     message.ContentType,
+		Priority:        message.Priority,
+		Edited:          true,
+		EditedAt:        &now,
+		PreviousContent: previousDecrypted,
+		CreatedAt:       message.CreatedAt,
+		Sender:          message.Sender.ToPublicUser(),
+		ReadBy:          readers,
+	}, nil
+}
+
+// DeleteGroupMessage marks a group message as deleted. The sender can delete their own
+// messages and moderators those of members they outrank; members get a group_message_deleted event.
+func (s *GroupService) DeleteGroupMessage(messageID, userID uuid.UUID) (*models.GroupMessageResponse, error) {
+	message, err := s.groupMessageRepo.FindByID(messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	role, err := s.groupRepo.GetMemberRole(message.GroupID, userID)
+	if err != nil || role == "" {
+		return nil, ErrNotGroupMember
+	}
+
+	if message.SenderID != userID && !s.canModerateMessage(message, role) {
+		return nil, ErrCannotDelete
+	}
+
+	if message.IsDeleted {
+		return nil, errors.New("message already deleted")
+	}
+
+	if err := s.groupMessageRepo.SoftDelete(messageID, userID); err != nil {
+		return nil, err
+	}
+	s.indexGroupMessage(messageID, message.ContentType, "")
+	now := time.Now()
+
+	if members, err := s.groupRepo.GetGroupMembers(message.GroupID); err == nil {
+		s.notifyGroupMembers(members, &websocket.Message{
+			Type:     "group_message_deleted",
+			SenderID: userID, // Who deleted it, not necessarily the sender
+			GroupID:  message.GroupID,
+			Data: map[string]interface{}{
+				"message_id": messageID,
+				"deleted_at": now,
+			},
+			Timestamp: now,
+		})
+	}
+
+	readers, err := s.groupMessageRepo.GetReaders(messageID)
+	if err != nil || readers == nil {
+		readers = []models.ReadReceipt{}
+	}
+
+	return &models.GroupMessageResponse{
+		ID:          message.ID,
+		GroupID:     message.GroupID,
+		SenderID:    message.SenderID,
+		Content:     "[message deleted]",
+		ContentType: message.ContentType,
+		Priority:    message.Priority,
+		Edited:      message.Edited,
+		EditedAt:    message.EditedAt,
+		IsDeleted:   true,
+		DeletedAt:   &now,
+		DeletedBy:   &userID,
+		CreatedAt:   message.CreatedAt,
+		Sender:      message.Sender.ToPublicUser(),
+		ReadBy:      readers,
 	}, nil
 }
 
+// canModerateMessage reports whether a member with role may act on someone else's message:
+// moderators and above can, as long as they outrank its sender. A sender who left the group
+// has no role and is outranked by every moderator.
+func (s *GroupService) canModerateMessage(message *models.GroupMessage, role models.MemberRole) bool {
+	if !models.CanModerate(role) {
+		return false
+	}
+	senderRole, err := s.groupRepo.GetMemberRole(message.GroupID, message.SenderID)
+	if err != nil {
+		return false
+	}
+	return role.Outranks(senderRole)
+}
+
 // GetGroupMessages retrieves messages for a group
 func (s *GroupService) GetGroupMessages(groupID, userID uuid.UUID, limit, offset int) ([]models.GroupMessageResponse, error) {
 	// Check if user is a member
 	isMember, err := s.groupRepo.IsMember(groupID, userID)
 	if err != nil || !isMember {
-		return nil, errors.New("not a member of this group")
+		return nil, ErrNotGroupMember
 	}
 
 	messages, err := s.groupMessageRepo.GetGroupMessages(groupID, limit, offset)
@@ -219,6 +575,214 @@ func (s *GroupService) GetGroupMessages(groupID, userID uuid.UUID, limit, offset
 		return nil, err
 	}
 
+	// Record reads for messages from other members
+	s.markGroupMessagesRead(userID, messages)
+
+	return s.buildGroupMessageResponses(messages)
+}
+
+// GroupMessageSearch is one page of full-text group message search results
+type GroupMessageSearch struct {
+	Results []models.GroupMessageSearchResult
+	Total   int64
+	Limit   int
+	Offset  int
+}
+
+// searchSnippetRadius is the number of characters kept on each side of the match in a search snippet
+const searchSnippetRadius = 40
+
+// SearchGroupMessages runs a full-text search over a group's messages, newest first, optionally
+// within opts' date range. Only members can search a group.
+func (s *GroupService) SearchGroupMessages(groupID, userID uuid.UUID, opts SearchOptions, limit, offset int) (*GroupMessageSearch, error) {
+	isMember, err := s.groupRepo.IsMember(groupID, userID)
+	if err != nil || !isMember {
+		return nil, ErrNotGroupMember
+	}
+
+	opts.Query = utils.SanitizeString(opts.Query)
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > maxMessageSearchResults {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	messages, err := s.groupMessageRepo.SearchGroupMessages(groupID, opts.Query, opts.Before, opts.After, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	total, err := s.groupMessageRepo.SearchGroupMessagesCount(groupID, opts.Query, opts.Before, opts.After)
+	if err != nil {
+		return nil, err
+	}
+
+	responses, err := s.buildGroupMessageResponses(messages)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]models.GroupMessageSearchResult, 0, len(responses))
+	for _, response := range responses {
+		results = append(results, models.GroupMessageSearchResult{
+			GroupMessageResponse: response,
+			Snippet:              utils.Snippet(response.Content, opts.Query, searchSnippetRadius),
+		})
+	}
+
+	return &GroupMessageSearch{
+		Results: results,
+		Total:   total,
+		Limit:   limit,
+		Offset:  offset,
+	}, nil
+}
+
+// indexGroupMessage updates the full-text search vector of a group message from its plaintext
+// content. Audio content is an attachment key rather than text, so it is never indexed.
+func (s *GroupService) indexGroupMessage(messageID uuid.UUID, contentType, content string) {
+	if contentType == utils.ContentTypeAudio {
+		content = ""
+	}
+	if err := s.groupMessageRepo.UpdateSearchVector(messageID, content); err != nil {
+		utils.Logger.Error("Failed to index group message for search", "message_id", messageID, "error", err)
+	}
+}
+
+// BackfillSearchIndex indexes, batchSize at a time, the group messages stored before full-text search existed
+func (s *GroupService) BackfillSearchIndex(batchSize int) {
+	indexed := 0
+	for {
+		messages, err := s.groupMessageRepo.FindUnindexed(batchSize)
+		if err != nil {
+			utils.Logger.Error("Failed to load group messages to index for search", "error", err)
+			return
+		}
+		if len(messages) == 0 {
+			break
+		}
+
+		for _, msg := range messages {
+			content := ""
+			if !msg.IsDeleted && msg.ContentType != utils.ContentTypeAudio {
+				// Undecryptable content is indexed as empty so the backfill still moves on
+				content, _ = utils.Decrypt(msg.Content)
+			}
+			if err := s.groupMessageRepo.UpdateSearchVector(msg.ID, content); err != nil {
+				utils.Logger.Error("Failed to index group message for search", "message_id", msg.ID, "error", err)
+				return
+			}
+		}
+		indexed += len(messages)
+	}
+
+	if indexed > 0 {
+		utils.Logger.Info("Indexed group messages for search", "count", indexed)
+	}
+}
+
+// ExportGroupMessages serializes a group's message history, oldest first, as "json" or "csv" and
+// returns the content type. Members export the messages they sent (forSelf); exporting the
+// full history requires the admin role.
+func (s *GroupService) ExportGroupMessages(requesterID, groupID uuid.UUID, format string, forSelf bool) ([]byte, string, error) {
+	if format != "json" && format != "csv" {
+		return nil, "", ErrInvalidExportFormat
+	}
+
+	role, err := s.groupRepo.GetMemberRole(groupID, requesterID)
+	if err != nil || role == "" {
+		return nil, "", ErrNotGroupMember
+	}
+
+	var senderID *uuid.UUID
+	if forSelf {
+		senderID = &requesterID
+	} else if !role.AtLeast(models.MemberRoleAdmin) {
+		return nil, "", ErrGroupPermission
+	}
+
+	count, err := s.groupMessageRepo.CountForExport(groupID, senderID)
+	if err != nil {
+		return nil, "", err
+	}
+	if count > MaxGroupExportRows {
+		return nil, "", ErrExportTooLarge
+	}
+
+	rows := make([]GroupMessageExport, 0, count)
+	err = s.groupMessageRepo.ForEachForExport(groupID, senderID, func(messages []models.GroupMessage) error {
+		for _, msg := range messages {
+			content, err := utils.Decrypt(msg.Content)
+			if err != nil {
+				content = "[Encrypted]"
+			}
+			if msg.IsDeleted {
+				content = "[message deleted]"
+			}
+			rows = append(rows, GroupMessageExport{
+				ID:             msg.ID,
+				SenderUsername: msg.Sender.Username,
+				Content:        content,
+				SentAt:         msg.CreatedAt,
+				IsDeleted:      msg.IsDeleted,
+				Edited:         msg.Edited,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	if format == "json" {
+		data, err := json.Marshal(rows)
+		return data, "application/json", err
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write([]string{"id", "sender_username", "content", "sent_at", "is_deleted", "edited"})
+	for _, row := range rows {
+		_ = w.Write([]string{
+			row.ID.String(),
+			row.SenderUsername,
+			row.Content,
+			row.SentAt.UTC().Format(time.RFC3339),
+			strconv.FormatBool(row.IsDeleted),
+			strconv.FormatBool(row.Edited),
+		})
+	}
+	w.Flush()
+	return buf.Bytes(), "text/csv", w.Error()
+}
+
+// GetGroupMessagesSince returns the messages posted in userID's groups after the direct or group
+// message since, oldest first, for a client catching up after a reconnect
+func (s *GroupService) GetGroupMessagesSince(userID, since uuid.UUID) ([]models.GroupMessageResponse, error) {
+	messages, err := s.groupMessageRepo.GetGroupMessagesSince(userID, since)
+	if err != nil {
+		return nil, err
+	}
+	return s.buildGroupMessageResponses(messages)
+}
+
+// buildGroupMessageResponses decrypts group messages and attaches their read receipts
+func (s *GroupService) buildGroupMessageResponses(messages []models.GroupMessage) ([]models.GroupMessageResponse, error) {
+	messageIDs := make([]uuid.UUID, 0, len(messages))
+	for _, msg := range messages {
+		messageIDs = append(messageIDs, msg.ID)
+	}
+	readers, err := s.groupMessageRepo.GetReadersForMessages(messageIDs)
+	if err != nil {
+		return nil, err
+	}
+	reactions, err := s.groupMessageRepo.GetReactionsForMessages(messageIDs)
+	if err != nil {
+		return nil, err
+	}
+
 	// Decrypt messages
 	responses := make([]models.GroupMessageResponse, 0, len(messages))
 	for _, msg := range messages {
@@ -227,90 +791,870 @@ func (s *GroupService) GetGroupMessages(groupID, userID uuid.UUID, limit, offset
 			decryptedContent = "[Encrypted]"
 		}
 
+		readBy := readers[msg.ID]
+		if readBy == nil {
+			readBy = []models.ReadReceipt{}
+		}
+
+		previousContent := ""
+		if msg.PreviousContent != "" {
+			if prev, err := utils.Decrypt(msg.PreviousContent); err == nil {
+				previousContent = prev
+			} else {
+				previousContent = "[Encrypted]"
+			}
+		}
+
+		// Deleted messages keep their place in the history, without their content
+		if msg.IsDeleted {
+			decryptedContent = "[message deleted]"
+			previousContent = ""
+		}
+
 		responses = append(responses, models.GroupMessageResponse{
-			ID:        msg.ID,
-			GroupID:   msg.GroupID,
-			SenderID:  msg.SenderID,
-			Content:   decryptedContent,
-			CreatedAt: msg.CreatedAt,
-			Sender:    msg.Sender.ToPublicUser(),
+			ID:              msg.ID,
+			GroupID:         msg.GroupID,
+			SenderID:        msg.SenderID,
+			Content:         decryptedContent,
+			ContentType:     msg.ContentType,
+			Priority:        msg.Priority,
+			Edited:          msg.Edited,
+			EditedAt:        msg.EditedAt,
+			PreviousContent: previousContent,
+			IsDeleted:       msg.IsDeleted,
+			DeletedAt:       msg.DeletedAt,
+			DeletedBy:       msg.DeletedBy,
+			Reactions:       reactions[msg.ID],
+			CreatedAt:       msg.CreatedAt,
+			Sender:          msg.Sender.ToPublicUser(),
+			ReadBy:          readBy,
 		})
 	}
 
 	return responses, nil
 }
 
-// AddMember adds a member to a group
-func (s *GroupService) AddMember(groupID, userID, newMemberID uuid.UUID) error {
-	// Get group to check creator
-	group, err := s.groupRepo.FindByID(groupID)
+// markGroupMessagesRead records reads by userID and notifies the senders of newly read messages
+func (s *GroupService) markGroupMessagesRead(userID uuid.UUID, messages []models.GroupMessage) {
+	senders := make(map[uuid.UUID]models.GroupMessage, len(messages))
+	unread := make([]uuid.UUID, 0, len(messages))
+	for _, msg := range messages {
+		if msg.SenderID == userID {
+			continue
+		}
+		senders[msg.ID] = msg
+		unread = append(unread, msg.ID)
+	}
+
+	newlyRead, err := s.groupMessageRepo.MarkReadBatch(userID, unread)
 	if err != nil {
+		return
+	}
+	if len(newlyRead) > 0 {
+		s.unreadService.PushUnreadCounts(userID)
+	}
+	if s.wsHub == nil {
+		return
+	}
+
+	now := time.Now()
+	for _, messageID := range newlyRead {
+		msg := senders[messageID]
+		_ = s.wsHub.SendToUser(msg.SenderID, &websocket.Message{
+			Type:     "group_message_read",
+			SenderID: userID,
+			GroupID:  msg.GroupID,
+			Data: map[string]interface{}{
+				"message_id": messageID,
+				"reader_id":  userID,
+				"read_at":    now,
+			},
+			Timestamp: now,
+		})
+	}
+}
+
+// PinMessage pins one of a group's messages for all its members, replacing any pinned message.
+// The group's pin_messages permission decides who may pin.
+func (s *GroupService) PinMessage(groupID, messageID, userID uuid.UUID) error {
+	if err := s.requirePermission(groupID, userID, func(p *models.GroupPermissions) models.MemberRole {
+		return p.PinMessages
+	}); err != nil {
 		return err
 	}
 
-	// Check if requester is an admin or creator
-	isAdmin, err := s.groupRepo.IsAdmin(groupID, userID)
+	message, err := s.groupMessageRepo.FindByID(messageID)
 	if err != nil {
 		return err
 	}
+	if message.GroupID != groupID {
+		return ErrMessageNotInGroup
+	}
+	if message.IsDeleted {
+		return errors.New("cannot pin a deleted message")
+	}
 
-	isCreator := group.CreatedBy == userID
-
-	if !isAdmin && !isCreator {
-		return errors.New("only admins can add members")
+	now := time.Now()
+	if err := s.groupRepo.SetPinnedMessage(groupID, &messageID, &now); err != nil {
+		return err
 	}
 
-	// Add member
-	member := &models.GroupMember{
-		GroupID: groupID,
-		UserID:  newMemberID,
-		Role:    models.MemberRoleMember,
+	if members, err := s.groupRepo.GetGroupMembers(groupID); err == nil {
+		s.notifyGroupMembers(members, &websocket.Message{
+			Type:     "message_pinned",
+			SenderID: userID,
+			GroupID:  groupID,
+			Data: map[string]interface{}{
+				"message_id": messageID,
+				"pinned_at":  now,
+			},
+			Timestamp: now,
+		})
 	}
 
-	return s.groupRepo.AddMember(member)
+	return nil
 }
 
-// RemoveMember removes a member from a group
-func (s *GroupService) RemoveMember(groupID, userID, memberID uuid.UUID) error {
-	// Get group to check creator
+// UnpinMessage clears a group's pinned message; unpinning a group without one is a no-op
+func (s *GroupService) UnpinMessage(groupID, userID uuid.UUID) error {
+	if err := s.requirePermission(groupID, userID, func(p *models.GroupPermissions) models.MemberRole {
+		return p.PinMessages
+	}); err != nil {
+		return err
+	}
+
 	group, err := s.groupRepo.FindByID(groupID)
 	if err != nil {
 		return err
 	}
+	if group.PinnedMessageID == nil {
+		return nil
+	}
 
-	// Check if requester is an admin or creator
-	isAdmin, err := s.groupRepo.IsAdmin(groupID, userID)
-	if err != nil {
+	if err := s.groupRepo.SetPinnedMessage(groupID, nil, nil); err != nil {
 		return err
 	}
 
-	isCreator := group.CreatedBy == userID
+	s.notifyGroupMembers(group.Members, &websocket.Message{
+		Type:      "message_unpinned",
+		SenderID:  userID,
+		GroupID:   groupID,
+		Data:      map[string]interface{}{"message_id": *group.PinnedMessageID},
+		Timestamp: time.Now(),
+	})
 
-	if !isAdmin && !isCreator {
-		return errors.New("only admins can remove members")
+	return nil
+}
+
+// GetMessageReaders lists who read a group message (admins only)
+func (s *GroupService) GetMessageReaders(messageID, userID uuid.UUID) ([]models.ReadReceipt, error) {
+	message, err := s.groupMessageRepo.FindByID(messageID)
+	if err != nil {
+		return nil, err
 	}
 
-	return s.groupRepo.RemoveMember(groupID, memberID)
-}
+	role, err := s.groupRepo.GetMemberRole(message.GroupID, userID)
+	if err != nil || !role.AtLeast(models.MemberRoleAdmin) {
+		return nil, ErrNotGroupAdmin
+	}
 
-// GetUserGroups gets all groups a user belongs to
-func (s *GroupService) GetUserGroups(userID uuid.UUID) ([]models.Group, error) {
-	return s.groupRepo.GetUserGroups(userID)
+	return s.groupMessageRepo.GetReaders(messageID)
 }
 
-// GetGroupMembers gets all members of a group
-func (s *GroupService) GetGroupMembers(groupID, userID uuid.UUID) ([]models.User, error) {
-	// Check if user is a member of the group
-	isMember, err := s.groupRepo.IsMember(groupID, userID)
+// AddGroupMessageReaction reacts to a group message with an emoji and returns the message's updated
+// reaction counts. The other members are notified with a group_reaction_added event.
+func (s *GroupService) AddGroupMessageReaction(messageID, userID uuid.UUID, emoji string) (map[string]int, error) {
+	if err := utils.ValidateEmoji(emoji); err != nil {
+		return nil, err
+	}
+
+	message, err := s.reactableGroupMessage(messageID, userID)
 	if err != nil {
 		return nil, err
 	}
-	if !isMember {
-		return nil, errors.New("only members can view group members")
+	if message.IsDeleted {
+		return nil, errors.New("cannot react to a deleted message")
 	}
 
-	members, err := s.groupRepo.GetGroupMembers(groupID)
-	if err != nil {
+	if err := s.groupMessageRepo.AddReaction(messageID, userID, emoji); err != nil {
+		return nil, err
+	}
+
+	return s.notifyGroupReaction("group_reaction_added", message, userID, emoji)
+}
+
+// RemoveGroupMessageReaction withdraws a member's emoji reaction and returns the message's updated
+// reaction counts. The other members are notified with a group_reaction_removed event.
+func (s *GroupService) RemoveGroupMessageReaction(messageID, userID uuid.UUID, emoji string) (map[string]int, error) {
+	message, err := s.reactableGroupMessage(messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.groupMessageRepo.RemoveReaction(messageID, userID, emoji); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrReactionNotFound
+		}
+		return nil, err
+	}
+
+	return s.notifyGroupReaction("group_reaction_removed", message, userID, emoji)
+}
+
+// reactableGroupMessage loads a group message the user can react to, i.e. one posted in a group they belong to
+func (s *GroupService) reactableGroupMessage(messageID, userID uuid.UUID) (*models.GroupMessage, error) {
+	message, err := s.groupMessageRepo.FindByID(messageID)
+	if err != nil {
+		return nil, err
+	}
+	isMember, err := s.groupRepo.IsMember(message.GroupID, userID)
+	if err != nil || !isMember {
+		return nil, ErrNotGroupMember
+	}
+	return message, nil
+}
+
+// notifyGroupReaction sends a reaction event to the group's other members and returns the current counts
+func (s *GroupService) notifyGroupReaction(eventType string, message *models.GroupMessage, userID uuid.UUID, emoji string) (map[string]int, error) {
+	reactions, err := s.groupMessageRepo.GetReactions(message.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if members, err := s.groupRepo.GetGroupMembers(message.GroupID); err == nil {
+		others := make([]models.GroupMember, 0, len(members))
+		for _, member := range members {
+			if member.UserID != userID {
+				others = append(others, member)
+			}
+		}
+		s.notifyGroupMembers(others, &websocket.Message{
+			Type:     eventType,
+			SenderID: userID,
+			GroupID:  message.GroupID,
+			Data: map[string]interface{}{
+				"group_id":   message.GroupID,
+				"message_id": message.ID,
+				"user_id":    userID,
+				"emoji":      emoji,
+				"reactions":  reactions,
+			},
+			Timestamp: time.Now(),
+		})
+	}
+
+	return reactions, nil
+}
+
+// AddMember adds a member to a group (any member unless the group's permissions restrict it)
+func (s *GroupService) AddMember(groupID, userID, newMemberID uuid.UUID) error {
+	if _, err := s.groupRepo.FindByID(groupID); err != nil {
+		return err
+	}
+
+	if err := s.requirePermission(groupID, userID, func(p *models.GroupPermissions) models.MemberRole {
+		return p.AddMembers
+	}); err != nil {
+		return err
+	}
+
+	alreadyMember, err := s.groupRepo.WithPrimary().IsMember(groupID, newMemberID)
+	if err != nil {
+		return err
+	}
+	if alreadyMember {
+		return errors.New("user is already a member of this group")
+	}
+
+	// Add member
+	member := &models.GroupMember{
+		GroupID: groupID,
+		UserID:  newMemberID,
+		Role:    models.MemberRoleMember,
+	}
+
+	if err := s.groupRepo.AddMember(member); err != nil {
+		return err
+	}
+	s.trackGroupMembers(groupID, newMemberID)
+
+	if members, err := s.groupRepo.GetGroupMembers(groupID); err == nil {
+		s.invalidateMembersPresence(members)
+		s.notifyGroupMembers(members, &websocket.Message{
+			Type:      "group_member_added",
+			SenderID:  userID,
+			GroupID:   groupID,
+			Data:      map[string]interface{}{"user_id": newMemberID},
+			Timestamp: time.Now(),
+		})
+	}
+
+	return nil
+}
+
+// GenerateInviteLink creates an invite link to a group and returns its token. Only admins and
+// the owner can create one; it expires after expiresInHours and is spent after maxUses joins.
+func (s *GroupService) GenerateInviteLink(groupID, adminID uuid.UUID, expiresInHours, maxUses int) (string, error) {
+	if expiresInHours == 0 {
+		expiresInHours = DefaultInviteExpiryHours
+	}
+	if expiresInHours < 1 || expiresInHours > MaxInviteExpiryHours {
+		return "", ErrInviteExpiry
+	}
+	if maxUses < 0 {
+		return "", ErrInviteMaxUses
+	}
+
+	role, err := s.groupRepo.GetMemberRole(groupID, adminID)
+	if err != nil || role == "" {
+		return "", ErrNotGroupMember
+	}
+	if !role.AtLeast(models.MemberRoleAdmin) {
+		return "", ErrNotGroupAdmin
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(secret)
+
+	if err := s.groupRepo.CreateInvite(&models.GroupInvite{
+		GroupID:   groupID,
+		Token:     token,
+		CreatedBy: adminID,
+		ExpiresAt: time.Now().Add(time.Duration(expiresInHours) * time.Hour),
+		MaxUses:   maxUses,
+	}); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// JoinGroupByInvite adds userID to the group of an invite link and returns the group.
+// Joining a group one already belongs to does not use up the link.
+func (s *GroupService) JoinGroupByInvite(token string, userID uuid.UUID) (*models.Group, error) {
+	invite, err := s.groupRepo.FindInviteByToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if invite == nil || !invite.IsUsable(time.Now()) {
+		return nil, ErrInvalidInvite
+	}
+
+	alreadyMember, err := s.groupRepo.WithPrimary().IsMember(invite.GroupID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if alreadyMember {
+		return s.groupRepo.FindByID(invite.GroupID)
+	}
+
+	// The link may have expired or been used up since it was read
+	used, err := s.groupRepo.UseInvite(invite.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		return nil, ErrInvalidInvite
+	}
+
+	if err := s.groupRepo.AddMember(&models.GroupMember{
+		GroupID: invite.GroupID,
+		UserID:  userID,
+		Role:    models.MemberRoleMember,
+	}); err != nil {
+		return nil, err
+	}
+	s.trackGroupMembers(invite.GroupID, userID)
+
+	if members, err := s.groupRepo.GetGroupMembers(invite.GroupID); err == nil {
+		s.invalidateMembersPresence(members)
+		s.notifyGroupMembers(members, &websocket.Message{
+			Type:      "group_member_added",
+			SenderID:  userID,
+			GroupID:   invite.GroupID,
+			Data:      map[string]interface{}{"user_id": userID},
+			Timestamp: time.Now(),
+		})
+	}
+
+	return s.groupRepo.FindByID(invite.GroupID)
+}
+
+// BulkAddMembers adds several users to a group at once. Users that are already members
+// are skipped silently; users that cannot be added are reported in errors.
+func (s *GroupService) BulkAddMembers(requesterID, groupID uuid.UUID, memberIDs []uuid.UUID) (int, []BulkAddError, error) {
+	if len(memberIDs) == 0 || len(memberIDs) > MaxBulkAddMembers {
+		return 0, nil, ErrBulkAddSize
+	}
+
+	group, err := s.groupRepo.FindByID(groupID)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if err := s.requirePermission(groupID, requesterID, func(p *models.GroupPermissions) models.MemberRole {
+		return p.AddMembers
+	}); err != nil {
+		return 0, nil, err
+	}
+
+	existing, err := s.groupRepo.WithPrimary().GetGroupMembers(groupID)
+	if err != nil {
+		return 0, nil, err
+	}
+	skip := make(map[uuid.UUID]bool, len(existing)+len(memberIDs))
+	for _, member := range existing {
+		skip[member.UserID] = true
+	}
+
+	var (
+		bulkErrors    []BulkAddError
+		members       []models.GroupMember
+		notifications []models.Notification
+		addedIDs      []uuid.UUID
+	)
+	for _, memberID := range memberIDs {
+		if skip[memberID] {
+			continue // Already a member or listed twice
+		}
+		skip[memberID] = true
+
+		user, err := s.userRepo.FindByID(memberID)
+		if err != nil {
+			bulkErrors = append(bulkErrors, BulkAddError{UserID: memberID, Error: err.Error()})
+			continue
+		}
+
+		members = append(members, models.GroupMember{
+			GroupID: groupID,
+			UserID:  memberID,
+			Role:    models.MemberRoleMember,
+		})
+		notifications = append(notifications, models.Notification{
+			UserID:      memberID,
+			Type:        models.NotificationTypeGroupInvite,
+			Content:     utils.T(user.Language, "group_invite_notification", group.Name),
+			ReferenceID: &group.ID,
+		})
+		addedIDs = append(addedIDs, memberID)
+	}
+
+	if len(members) == 0 {
+		return 0, bulkErrors, nil
+	}
+
+	if err := s.groupRepo.AddMembers(members); err != nil {
+		return 0, nil, err
+	}
+	s.trackGroupMembers(groupID, addedIDs...)
+	_ = s.notificationRepo.BulkCreate(notifications)
+
+	if all, err := s.groupRepo.GetGroupMembers(groupID); err == nil {
+		s.invalidateMembersPresence(all)
+		s.notifyGroupMembers(all, &websocket.Message{
+			Type:      "group_members_added",
+			SenderID:  requesterID,
+			GroupID:   groupID,
+			Data:      map[string]interface{}{"user_ids": addedIDs},
+			Timestamp: time.Now(),
+		})
+	}
+
+	return len(members), bulkErrors, nil
+}
+
+// RemoveMember removes a member from a group. Moderators and admins remove the members they
+// outrank; only the owner removes admins.
+func (s *GroupService) RemoveMember(groupID, userID, memberID uuid.UUID) error {
+	if _, err := s.groupRepo.FindByID(groupID); err != nil {
+		return err
+	}
+
+	requesterRole, err := s.groupRepo.GetMemberRole(groupID, userID)
+	if err != nil {
+		return err
+	}
+	if !models.CanModerate(requesterRole) {
+		return ErrNotGroupAdmin
+	}
+
+	memberRole, err := s.groupRepo.GetMemberRole(groupID, memberID)
+	if err != nil {
+		return err
+	}
+	if !requesterRole.Outranks(memberRole) {
+		return ErrOutranked
+	}
+
+	members, err := s.groupRepo.GetGroupMembers(groupID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.groupRepo.RemoveMember(groupID, memberID); err != nil {
+		return err
+	}
+	if s.wsHub != nil {
+		s.wsHub.RemoveUserFromGroup(groupID, memberID)
+	}
+
+	// Notify remaining members and the removed user
+	s.invalidateMembersPresence(members)
+	s.notifyGroupMembers(members, &websocket.Message{
+		Type:      "group_member_removed",
+		SenderID:  userID,
+		GroupID:   groupID,
+		Data:      map[string]interface{}{"user_id": memberID},
+		Timestamp: time.Now(),
+	})
+
+	return nil
+}
+
+// GetUserGroups gets the groups a user belongs to; archived groups are only included on request.
+// A limit of zero or less returns every group.
+func (s *GroupService) GetUserGroups(userID uuid.UUID, includeArchived bool, limit, offset int) ([]models.GroupSummary, error) {
+	groups, err := s.groupRepo.GetUserGroups(userID, includeArchived, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	groupIDs := make([]uuid.UUID, 0, len(groups))
+	for _, group := range groups {
+		groupIDs = append(groupIDs, group.ID)
+	}
+
+	unread, err := s.groupMessageRepo.GetUnreadCounts(userID, groupIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]models.GroupSummary, 0, len(groups))
+	for _, group := range groups {
+		result = append(result, models.GroupSummary{
+			Group:       group,
+			UnreadCount: unread[group.ID],
+		})
+	}
+	return result, nil
+}
+
+// GetGroupUnreadCount returns how many messages from other members userID has not read in a group
+func (s *GroupService) GetGroupUnreadCount(groupID, userID uuid.UUID) (int64, error) {
+	isMember, err := s.groupRepo.IsMember(groupID, userID)
+	if err != nil || !isMember {
+		return 0, ErrNotGroupMember
+	}
+	return s.groupMessageRepo.GetUnreadCount(groupID, userID)
+}
+
+// CountUserGroups counts the groups GetUserGroups lists
+func (s *GroupService) CountUserGroups(userID uuid.UUID, includeArchived bool) (int64, error) {
+	return s.groupRepo.CountUserGroups(userID, includeArchived)
+}
+
+// GetArchivedGroups gets the groups a user has archived
+func (s *GroupService) GetArchivedGroups(userID uuid.UUID) ([]models.Group, error) {
+	return s.groupRepo.GetArchivedGroups(userID)
+}
+
+// ArchiveGroup hides a group from the user's active list without leaving it
+func (s *GroupService) ArchiveGroup(userID, groupID uuid.UUID) error {
+	isMember, err := s.groupRepo.IsMember(groupID, userID)
+	if err != nil || !isMember {
+		return ErrNotGroupMember
+	}
+
+	return s.groupRepo.ArchiveGroup(userID, groupID)
+}
+
+// UnarchiveGroup restores an archived group to the user's active list
+func (s *GroupService) UnarchiveGroup(userID, groupID uuid.UUID) error {
+	isMember, err := s.groupRepo.IsMember(groupID, userID)
+	if err != nil || !isMember {
+		return ErrNotGroupMember
+	}
+
+	return s.groupRepo.UnarchiveGroup(userID, groupID)
+}
+
+// GetGroupWithCount retrieves a group by ID along with its member count
+func (s *GroupService) GetGroupWithCount(groupID, userID uuid.UUID) (*models.GroupWithCount, error) {
+	group, err := s.GetGroup(groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	mutes, err := s.groupRepo.GetActiveMutes(userID, []uuid.UUID{groupID})
+	if err != nil {
+		return nil, err
+	}
+
+	drafts, err := s.groupRepo.GetDrafts(userID, []uuid.UUID{groupID})
+	if err != nil {
+		return nil, err
+	}
+
+	unread, err := s.groupMessageRepo.GetUnreadCount(groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.GroupWithCount{
+		Group:       *group,
+		MemberCount: int64(len(group.Members)),
+		UnreadCount: unread,
+	}
+	if mute, ok := mutes[groupID]; ok {
+		result.IsMuted = true
+		result.MutedUntil = mute.MutedUntil
+	}
+	if draft, ok := drafts[groupID]; ok {
+		result.Draft = decryptDraft(draft.Content)
+	}
+	return result, nil
+}
+
+// DiscoverGroups lists public groups with their member counts, optionally filtered by a
+// case-insensitive name or description query and a language. Private groups are never returned.
+func (s *GroupService) DiscoverGroups(query, language string, limit, offset int) ([]models.GroupWithCount, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	groups, err := s.groupRepo.SearchPublicGroups(utils.SanitizeString(query), language, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	groupIDs := make([]uuid.UUID, 0, len(groups))
+	for _, group := range groups {
+		groupIDs = append(groupIDs, group.ID)
+	}
+
+	counts, err := s.groupRepo.CountMembers(groupIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]models.GroupWithCount, 0, len(groups))
+	for _, group := range groups {
+		result = append(result, models.GroupWithCount{
+			Group:       group,
+			MemberCount: counts[group.ID],
+		})
+	}
+	return result, nil
+}
+
+// ListPublicGroups lists public groups matching query in their name or description, without
+// their members or creator
+func (s *GroupService) ListPublicGroups(query string, limit, offset int) ([]models.PublicGroupSummary, error) {
+	groups, err := s.DiscoverGroups(query, "", limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]models.PublicGroupSummary, 0, len(groups))
+	for _, group := range groups {
+		result = append(result, models.PublicGroupSummary{
+			ID:          group.ID,
+			Name:        group.Name,
+			Description: group.Description,
+			Avatar:      group.Avatar,
+			Language:    group.Language,
+			MemberCount: group.MemberCount,
+			CreatedAt:   group.CreatedAt,
+		})
+	}
+	return result, nil
+}
+
+// GetUserGroupsWithCount gets the groups a user belongs to along with their member counts
+func (s *GroupService) GetUserGroupsWithCount(userID uuid.UUID, includeArchived bool, limit, offset int) ([]models.GroupWithCount, error) {
+	groups, err := s.groupRepo.GetUserGroups(userID, includeArchived, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	groupIDs := make([]uuid.UUID, 0, len(groups))
+	for _, group := range groups {
+		groupIDs = append(groupIDs, group.ID)
+	}
+
+	counts, err := s.groupRepo.CountMembers(groupIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	mutes, err := s.groupRepo.GetActiveMutes(userID, groupIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	drafts, err := s.groupRepo.GetDrafts(userID, groupIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	unread, err := s.groupMessageRepo.GetUnreadCounts(userID, groupIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]models.GroupWithCount, 0, len(groups))
+	for _, group := range groups {
+		item := models.GroupWithCount{
+			Group:       group,
+			MemberCount: counts[group.ID],
+			UnreadCount: unread[group.ID],
+		}
+		if mute, ok := mutes[group.ID]; ok {
+			item.IsMuted = true
+			item.MutedUntil = mute.MutedUntil
+		}
+		if draft, ok := drafts[group.ID]; ok {
+			item.Draft = decryptDraft(draft.Content)
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+// SaveGroupDraft stores a member's unsent message for a group, replacing any previous draft.
+// Saving empty content discards the draft.
+func (s *GroupService) SaveGroupDraft(userID, groupID uuid.UUID, content string) error {
+	isMember, err := s.groupRepo.IsMember(groupID, userID)
+	if err != nil || !isMember {
+		return ErrNotGroupMember
+	}
+
+	if content == "" {
+		return s.groupRepo.DeleteDraft(userID, groupID)
+	}
+	if utf8.RuneCountInString(content) > MaxGroupDraftLength {
+		return ErrGroupDraftTooLong
+	}
+
+	encryptedContent, err := utils.Encrypt(content)
+	if err != nil {
+		return errors.New("failed to encrypt draft")
+	}
+
+	return s.groupRepo.SaveDraft(userID, groupID, encryptedContent)
+}
+
+// GetGroupDraft returns a member's decrypted draft for a group, or an empty string if there is none
+func (s *GroupService) GetGroupDraft(userID, groupID uuid.UUID) (string, error) {
+	isMember, err := s.groupRepo.IsMember(groupID, userID)
+	if err != nil || !isMember {
+		return "", ErrNotGroupMember
+	}
+
+	drafts, err := s.groupRepo.GetDrafts(userID, []uuid.UUID{groupID})
+	if err != nil {
+		return "", err
+	}
+
+	draft, ok := drafts[groupID]
+	if !ok {
+		return "", nil
+	}
+	return decryptDraft(draft.Content), nil
+}
+
+// DeleteGroupDraft discards a member's draft for a group
+func (s *GroupService) DeleteGroupDraft(userID, groupID uuid.UUID) error {
+	isMember, err := s.groupRepo.IsMember(groupID, userID)
+	if err != nil || !isMember {
+		return ErrNotGroupMember
+	}
+
+	return s.groupRepo.DeleteDraft(userID, groupID)
+}
+
+// decryptDraft decrypts stored draft content, returning an empty draft if it cannot be read
+func decryptDraft(encrypted string) string {
+	content, err := utils.Decrypt(encrypted)
+	if err != nil {
+		return ""
+	}
+	return content
+}
+
+// MuteGroup silences notifications of a group for the user until the given time (nil mutes indefinitely)
+func (s *GroupService) MuteGroup(userID, groupID uuid.UUID, until *time.Time) error {
+	isMember, err := s.groupRepo.IsMember(groupID, userID)
+	if err != nil || !isMember {
+		return ErrNotGroupMember
+	}
+
+	if until != nil && !until.After(time.Now()) {
+		return errors.New("mute end time must be in the future")
+	}
+
+	return s.groupRepo.MuteGroup(userID, groupID, until)
+}
+
+// UnmuteGroup restores notifications of a group for the user
+func (s *GroupService) UnmuteGroup(userID, groupID uuid.UUID) error {
+	isMember, err := s.groupRepo.IsMember(groupID, userID)
+	if err != nil || !isMember {
+		return ErrNotGroupMember
+	}
+
+	return s.groupRepo.UnmuteGroup(userID, groupID)
+}
+
+// IsGroupMuted reports whether the user currently has the group muted
+func (s *GroupService) IsGroupMuted(userID, groupID uuid.UUID) (bool, error) {
+	mutes, err := s.groupRepo.GetActiveMutes(userID, []uuid.UUID{groupID})
+	if err != nil {
+		return false, err
+	}
+
+	_, muted := mutes[groupID]
+	return muted, nil
+}
+
+// RunMuteExpiry periodically deletes expired group mutes until stop is closed
+func (s *GroupService) RunMuteExpiry(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			removed, err := s.groupRepo.DeleteExpiredMutes()
+			if err != nil {
+				utils.Logger.Error("Failed to expire group mutes", "error", err)
+			} else if removed > 0 {
+				utils.Logger.Info("Expired group mutes", "count", removed)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// GetGroupMembers gets all members of a group
+func (s *GroupService) GetGroupMembers(groupID, userID uuid.UUID) ([]models.User, error) {
+	// Check if user is a member of the group
+	isMember, err := s.groupRepo.IsMember(groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !isMember {
+		return nil, ErrNotGroupMember
+	}
+
+	members, err := s.groupRepo.GetGroupMembers(groupID)
+	if err != nil {
 		return nil, err
 	}
 
@@ -323,17 +1667,302 @@ func (s *GroupService) GetGroupMembers(groupID, userID uuid.UUID) ([]models.User
 	return users, nil
 }
 
+// GroupPermissionsRequest updates the minimum role for each group action; omitted fields are unchanged
+type GroupPermissionsRequest struct {
+	SendMessages  *models.MemberRole `json:"send_messages"`
+	AddMembers    *models.MemberRole `json:"add_members"`
+	PinMessages   *models.MemberRole `json:"pin_messages"`
+	EditGroupInfo *models.MemberRole `json:"edit_group_info"`
+}
+
+// GroupSettings updates a group's settings; omitted fields are unchanged
+type GroupSettings struct {
+	AnnouncementsOnly *bool             `json:"announcements_only"`
+	Description       *string           `json:"description"`
+	Type              *models.GroupType `json:"type"`
+}
+
+// UpdateSettings changes a group's settings (admins and the owner) and returns the updated group
+func (s *GroupService) UpdateSettings(groupID, userID uuid.UUID, settings GroupSettings) (*models.Group, error) {
+	role, err := s.groupRepo.GetMemberRole(groupID, userID)
+	if err != nil || role == "" {
+		return nil, ErrNotGroupMember
+	}
+	if !role.AtLeast(models.MemberRoleAdmin) {
+		return nil, ErrGroupPermission
+	}
+
+	fields := make(map[string]interface{})
+	if settings.AnnouncementsOnly != nil {
+		fields["announcements_only"] = *settings.AnnouncementsOnly
+	}
+	if settings.Description != nil {
+		fields["description"] = utils.SanitizeString(*settings.Description)
+	}
+	if settings.Type != nil {
+		if *settings.Type != models.GroupTypePublic && *settings.Type != models.GroupTypePrivate {
+			return nil, errors.New("type must be public or private")
+		}
+		fields["type"] = *settings.Type
+	}
+
+	if err := s.groupRepo.UpdateFields(groupID, fields); err != nil {
+		return nil, err
+	}
+
+	group, err := s.groupRepo.FindByID(groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fields) > 0 {
+		s.notifyGroupMembers(group.Members, &websocket.Message{
+			Type:      "group_settings_updated",
+			SenderID:  userID,
+			GroupID:   groupID,
+			Data:      map[string]interface{}{"announcements_only": group.AnnouncementsOnly, "type": group.Type},
+			Timestamp: time.Now(),
+		})
+	}
+
+	return group, nil
+}
+
+// GetPermissions returns the minimum role required for each action in a group
+func (s *GroupService) GetPermissions(groupID uuid.UUID) (*models.GroupPermissions, error) {
+	return s.groupRepo.GetPermissions(groupID)
+}
+
+// GetGroupPermissions returns a group's permissions to one of its members
+func (s *GroupService) GetGroupPermissions(userID, groupID uuid.UUID) (*models.GroupPermissions, error) {
+	isMember, err := s.groupRepo.IsMember(groupID, userID)
+	if err != nil || !isMember {
+		return nil, ErrNotGroupMember
+	}
+
+	return s.GetPermissions(groupID)
+}
+
+// SetPermissions updates a group's permissions (owner only)
+func (s *GroupService) SetPermissions(requesterID, groupID uuid.UUID, req GroupPermissionsRequest) error {
+	isOwner, err := s.groupRepo.IsOwner(groupID, requesterID)
+	if err != nil {
+		return err
+	}
+	if !isOwner {
+		return ErrNotGroupOwner
+	}
+
+	permissions, err := s.GetPermissions(groupID)
+	if err != nil {
+		return err
+	}
+
+	fields := []struct {
+		value  *models.MemberRole
+		target *models.MemberRole
+	}{
+		{req.SendMessages, &permissions.SendMessages},
+		{req.AddMembers, &permissions.AddMembers},
+		{req.PinMessages, &permissions.PinMessages},
+		{req.EditGroupInfo, &permissions.EditGroupInfo},
+	}
+	for _, field := range fields {
+		if field.value == nil {
+			continue
+		}
+		if !field.value.IsValid() {
+			return errors.New("role must be one of owner, admin, moderator or member")
+		}
+		*field.target = *field.value
+	}
+
+	return s.groupRepo.SavePermissions(permissions)
+}
+
+// requirePermission checks that userID's role meets the minimum role selected from the group's permissions
+func (s *GroupService) requirePermission(groupID, userID uuid.UUID, required func(*models.GroupPermissions) models.MemberRole) error {
+	role, err := s.groupRepo.GetMemberRole(groupID, userID)
+	if err != nil || role == "" {
+		return ErrNotGroupMember
+	}
+
+	permissions, err := s.GetPermissions(groupID)
+	if err != nil {
+		return err
+	}
+
+	if !role.AtLeast(required(permissions)) {
+		return ErrGroupPermission
+	}
+	return nil
+}
+
+// TransferOwnership hands the group to another member; the previous owner becomes an admin
+func (s *GroupService) TransferOwnership(groupID, ownerID, newOwnerID uuid.UUID) error {
+	isOwner, err := s.groupRepo.IsOwner(groupID, ownerID)
+	if err != nil {
+		return err
+	}
+	if !isOwner {
+		return ErrNotGroupOwner
+	}
+
+	if newOwnerID == ownerID {
+		return errors.New("you already own this group")
+	}
+
+	isMember, err := s.groupRepo.WithPrimary().IsMember(groupID, newOwnerID)
+	if err != nil {
+		return err
+	}
+	if !isMember {
+		return errors.New("new owner must be a member of this group")
+	}
+
+	if err := s.groupRepo.TransferOwnership(groupID, ownerID, newOwnerID); err != nil {
+		return err
+	}
+
+	if members, err := s.groupRepo.GetGroupMembers(groupID); err == nil {
+		s.notifyGroupMembers(members, &websocket.Message{
+			Type:      "group_owner_changed",
+			SenderID:  ownerID,
+			GroupID:   groupID,
+			Data:      map[string]interface{}{"user_id": newOwnerID},
+			Timestamp: time.Now(),
+		})
+	}
+
+	return nil
+}
+
+// UpdateMemberRoleRequest represents a request to promote or demote a group member
+type UpdateMemberRoleRequest struct {
+	Role models.MemberRole `json:"role" binding:"required"`
+}
+
+// UpdateMemberRole promotes or demotes a member. Admins change the roles of members they
+// outrank, to a role below their own; ownership moves with TransferOwnership instead.
+func (s *GroupService) UpdateMemberRole(groupID, requesterID, memberID uuid.UUID, req UpdateMemberRoleRequest) error {
+	if !req.Role.IsValid() || req.Role == models.MemberRoleOwner {
+		return errors.New("role must be one of admin, moderator or member")
+	}
+
+	requesterRole, err := s.groupRepo.GetMemberRole(groupID, requesterID)
+	if err != nil || requesterRole == "" {
+		return ErrNotGroupMember
+	}
+	if !requesterRole.AtLeast(models.MemberRoleAdmin) {
+		return ErrNotGroupAdmin
+	}
+
+	memberRole, err := s.groupRepo.GetMemberRole(groupID, memberID)
+	if err != nil {
+		return err
+	}
+	if memberRole == "" {
+		return errors.New("member not found")
+	}
+	if !requesterRole.Outranks(memberRole) || !requesterRole.Outranks(req.Role) {
+		return ErrOutranked
+	}
+	if memberRole == req.Role {
+		return nil
+	}
+
+	if err := s.groupRepo.UpdateMemberRole(groupID, memberID, req.Role); err != nil {
+		return err
+	}
+
+	if members, err := s.groupRepo.GetGroupMembers(groupID); err == nil {
+		s.notifyGroupMembers(members, &websocket.Message{
+			Type:     "group_member_role_changed",
+			SenderID: requesterID,
+			GroupID:  groupID,
+			Data: map[string]interface{}{
+				"user_id": memberID,
+				"role":    req.Role,
+			},
+			Timestamp: time.Now(),
+		})
+	}
+
+	return nil
+}
+
 // DeleteGroup deletes a group
 func (s *GroupService) DeleteGroup(groupID, userID uuid.UUID) error {
-	group, err := s.groupRepo.FindByID(groupID)
+	if _, err := s.groupRepo.FindByID(groupID); err != nil {
+		return err
+	}
+
+	// Only the owner can delete the group
+	isOwner, err := s.groupRepo.IsOwner(groupID, userID)
 	if err != nil {
 		return err
 	}
+	if !isOwner {
+		return ErrNotGroupOwner
+	}
 
-	// Only creator can delete group
-	if group.CreatedBy != userID {
-		return errors.New("only the creator can delete this group")
+	members, err := s.groupRepo.GetGroupMembers(groupID)
+	if err != nil {
+		return err
 	}
 
-	return s.groupRepo.Delete(groupID)
+	if err := s.groupRepo.Delete(groupID); err != nil {
+		return err
+	}
+	if s.wsHub != nil {
+		s.wsHub.SyncGroupMembers(groupID, nil)
+	}
+
+	s.invalidateMembersPresence(members)
+	s.notifyGroupMembers(members, &websocket.Message{
+		Type:      "group_deleted",
+		SenderID:  userID,
+		GroupID:   groupID,
+		Timestamp: time.Now(),
+	})
+
+	return nil
+}
+
+// trackGroupMembers adds new members of a group to the hub's routing table
+func (s *GroupService) trackGroupMembers(groupID uuid.UUID, userIDs ...uuid.UUID) {
+	if s.wsHub == nil {
+		return
+	}
+	for _, userID := range userIDs {
+		s.wsHub.AddUserToGroup(groupID, userID)
+	}
+}
+
+// invalidateMembersPresence drops cached presence subscribers for every member of a group
+func (s *GroupService) invalidateMembersPresence(members []models.GroupMember) {
+	ids := make([]uuid.UUID, len(members))
+	for i, member := range members {
+		ids[i] = member.UserID
+	}
+	s.invalidatePresence(ids...)
+}
+
+// invalidatePresence drops cached presence subscribers after group memberships change
+func (s *GroupService) invalidatePresence(userIDs ...uuid.UUID) {
+	if s.wsHub == nil {
+		return
+	}
+	s.wsHub.InvalidatePresenceCache(userIDs...)
+}
+
+// notifyGroupMembers pushes a WebSocket event to every member of a group
+func (s *GroupService) notifyGroupMembers(members []models.GroupMember, message *websocket.Message) {
+	if s.wsHub == nil {
+		return
+	}
+
+	for _, member := range members {
+		_ = s.wsHub.SendToUser(member.UserID, message)
+	}
 }
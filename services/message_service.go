@@ -1,203 +1,1483 @@
 package services
 
 import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
 	"errors"
+	"strconv"
+	"strings"
 	"time"
 
+	"mms-backend/metrics"
 	"mms-backend/models"
 	"mms-backend/repositories"
 	"mms-backend/utils"
 	"mms-backend/websocket"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
+// ErrInvalidPriority is returned when a message priority is not one of normal, high or urgent
+var ErrInvalidPriority = errors.New("priority must be one of normal, high or urgent")
+
+// maxConversationSearchLimit caps the number of conversations returned by a search
+const maxConversationSearchLimit = 500
+
+// maxMessageSearchResults caps the number of messages returned by a message search
+const maxMessageSearchResults = 100
+
+// ConversationMessageExport is one exported direct message
+type ConversationMessageExport struct {
+	ID             uuid.UUID `json:"id"`
+	SenderUsername string    `json:"sender_username"`
+	Content        string    `json:"content"` // Decrypted content
+	CreatedAt      time.Time `json:"created_at"`
+	IsRead         bool      `json:"is_read"`
+	Edited         bool      `json:"edited"`
+}
+
+// ErrInvalidDateRange is returned when a search's after bound is not before its before bound
+var ErrInvalidDateRange = errors.New("after must be earlier than before")
+
+// conversationsCacheTTL bounds how stale cached conversation lists can get, e.g. a partner's online status
+const conversationsCacheTTL = 2 * time.Minute
+
+// MaxConversationLabels caps the number of labels a user can put on one conversation
+const MaxConversationLabels = 5
+
+// ErrTooManyLabels is returned when a conversation already has MaxConversationLabels labels
+var ErrTooManyLabels = errors.New("a conversation can have at most 5 labels")
+
+// ErrLabelNotFound is returned when removing a label the conversation does not have
+var ErrLabelNotFound = errors.New("label not found")
+
+// ErrReactionNotFound is returned when removing a reaction the user has not made
+var ErrReactionNotFound = errors.New("reaction not found")
+
+// ErrUserBlocked is returned when either user of a conversation has blocked the other
+var ErrUserBlocked = errors.New("you cannot message this user")
+
+// ErrInvalidReply is returned when a reply references a message outside the conversation
+var ErrInvalidReply = errors.New("reply_to_id must be a message of this conversation")
+
+// MaxForwardRecipients caps the number of users a message can be forwarded to in one request
+const MaxForwardRecipients = 20
+
+// ErrForwardSize is returned when a forward request has no receiver IDs or more than MaxForwardRecipients
+var ErrForwardSize = errors.New("receiver_ids must list between 1 and 20 users")
+
+// ErrNotConversationMember is returned when a user reacts to a message they neither sent nor received
+var ErrNotConversationMember = errors.New("not a participant of this conversation")
+
+// AddLabelRequest represents a conversation label request
+type AddLabelRequest struct {
+	Label string `json:"label" binding:"required"`
+	Color string `json:"color" binding:"required"`
+}
+
+// SearchOptions filters a message search by content and creation time
+type SearchOptions struct {
+	Query  string
+	Before *time.Time // Only messages created strictly before this time
+	After  *time.Time // Only messages created strictly after this time
+}
+
+// Validate checks that the query is present and the date range is not empty
+func (o SearchOptions) Validate() error {
+	if strings.TrimSpace(o.Query) == "" {
+		return errors.New("search query required")
+	}
+	if o.Before != nil && o.After != nil && !o.After.Before(*o.Before) {
+		return ErrInvalidDateRange
+	}
+	return nil
+}
+
+// matches reports whether decrypted message content contains the query, ignoring case
+func (o SearchOptions) matches(content string) bool {
+	return strings.Contains(strings.ToLower(content), strings.ToLower(strings.TrimSpace(o.Query)))
+}
+
 // MessageService handles message business logic
 type MessageService struct {
 	messageRepo      *repositories.MessageRepository
 	userRepo         *repositories.UserRepository
 	notificationRepo *repositories.NotificationRepository
 	pushService      *PushService
+	unreadService    *UnreadService
+	contentFilter    *ContentFilterService
+	attachments      *AttachmentService
+	cache            CacheService
 	wsHub            *websocket.Hub
 }
 
-// NewMessageService creates a new message service
-func NewMessageService(
-	messageRepo *repositories.MessageRepository,
-	userRepo *repositories.UserRepository,
-	notificationRepo *repositories.NotificationRepository,
-	pushService *PushService,
-	wsHub *websocket.Hub,
-) *MessageService {
-	return &MessageService{
-		messageRepo:      messageRepo,
-		userRepo:         userRepo,
-		notificationRepo: notificationRepo,
-		pushService:      pushService,
-		wsHub:            wsHub,
+// NewMessageService creates a new message service
+func NewMessageService(
+	messageRepo *repositories.MessageRepository,
+	userRepo *repositories.UserRepository,
+	notificationRepo *repositories.NotificationRepository,
+	pushService *PushService,
+	unreadService *UnreadService,
+	contentFilter *ContentFilterService,
+	attachments *AttachmentService,
+	cache CacheService,
+	wsHub *websocket.Hub,
+) *MessageService {
+	return &MessageService{
+		messageRepo:      messageRepo,
+		userRepo:         userRepo,
+		notificationRepo: notificationRepo,
+		pushService:      pushService,
+		unreadService:    unreadService,
+		contentFilter:    contentFilter,
+		attachments:      attachments,
+		cache:            cache,
+		wsHub:            wsHub,
+	}
+}
+
+// AddLabel puts a label on a conversation, or changes its color if the conversation already has it
+func (s *MessageService) AddLabel(userID, partnerID uuid.UUID, label, color string) error {
+	label = utils.SanitizeString(label)
+	if err := utils.ValidateLabel(label); err != nil {
+		return err
+	}
+	if err := utils.ValidateColor(color); err != nil {
+		return err
+	}
+
+	if _, err := s.userRepo.FindByID(partnerID); err != nil {
+		return errors.New("user not found")
+	}
+
+	existing, err := s.messageRepo.FindLabel(userID, partnerID, label)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		count, err := s.messageRepo.CountLabels(userID, partnerID)
+		if err != nil {
+			return err
+		}
+		if count >= MaxConversationLabels {
+			return ErrTooManyLabels
+		}
+	}
+
+	if err := s.messageRepo.SaveLabel(&models.ConversationLabel{
+		UserID:    userID,
+		PartnerID: partnerID,
+		Label:     label,
+		Color:     color,
+	}); err != nil {
+		return err
+	}
+	s.invalidateConversations(userID)
+	return nil
+}
+
+// RemoveLabel takes a label off a conversation
+func (s *MessageService) RemoveLabel(userID, partnerID uuid.UUID, label string) error {
+	deleted, err := s.messageRepo.DeleteLabel(userID, partnerID, label)
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrLabelNotFound
+	}
+	s.invalidateConversations(userID)
+	return nil
+}
+
+// MuteConversationRequest represents a conversation mute request; a null until mutes indefinitely
+type MuteConversationRequest struct {
+	Until *time.Time `json:"until"`
+}
+
+// MuteConversation silences notifications of the conversation with partnerID for the user
+// until the given time (nil mutes indefinitely)
+func (s *MessageService) MuteConversation(userID, partnerID uuid.UUID, until *time.Time) error {
+	if userID == partnerID {
+		return errors.New("cannot mute a conversation with yourself")
+	}
+	if _, err := s.userRepo.FindByID(partnerID); err != nil {
+		return errors.New("user not found")
+	}
+
+	if until != nil && !until.After(time.Now()) {
+		return errors.New("mute end time must be in the future")
+	}
+
+	if err := s.messageRepo.MuteConversation(userID, partnerID, until); err != nil {
+		return err
+	}
+	s.invalidateConversations(userID)
+	return nil
+}
+
+// UnmuteConversation restores notifications of the conversation with partnerID for the user
+func (s *MessageService) UnmuteConversation(userID, partnerID uuid.UUID) error {
+	if err := s.messageRepo.UnmuteConversation(userID, partnerID); err != nil {
+		return err
+	}
+	s.invalidateConversations(userID)
+	return nil
+}
+
+// ArchiveConversation hides the conversation with partnerID from the user's recent conversations.
+// Its messages are kept and it stays listed under the archived conversations.
+func (s *MessageService) ArchiveConversation(userID, partnerID uuid.UUID) error {
+	if userID == partnerID {
+		return errors.New("cannot archive a conversation with yourself")
+	}
+	if _, err := s.userRepo.FindByID(partnerID); err != nil {
+		return errors.New("user not found")
+	}
+
+	if err := s.messageRepo.ArchiveConversation(userID, partnerID); err != nil {
+		return err
+	}
+	s.invalidateConversations(userID)
+	return nil
+}
+
+// UnarchiveConversation restores the conversation with partnerID to the user's recent conversations
+func (s *MessageService) UnarchiveConversation(userID, partnerID uuid.UUID) error {
+	if err := s.messageRepo.UnarchiveConversation(userID, partnerID); err != nil {
+		return err
+	}
+	s.invalidateConversations(userID)
+	return nil
+}
+
+// IsMuted reports whether the user currently has the conversation with partnerID muted
+func (s *MessageService) IsMuted(userID, partnerID uuid.UUID) (bool, error) {
+	return s.messageRepo.IsMuted(userID, partnerID)
+}
+
+// RunMuteExpiry periodically deletes expired conversation mutes until stop is closed
+func (s *MessageService) RunMuteExpiry(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			removed, err := s.messageRepo.DeleteExpiredConversationMutes()
+			if err != nil {
+				utils.Logger.Error("Failed to expire conversation mutes", "error", err)
+			} else if removed > 0 {
+				utils.Logger.Info("Expired conversation mutes", "count", removed)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// ScheduledMessagePollInterval is how often due scheduled messages are sent
+const ScheduledMessagePollInterval = 30 * time.Second
+
+// MaxScheduleAhead caps how far in the future a message can be scheduled
+const MaxScheduleAhead = 365 * 24 * time.Hour
+
+// scheduledBatchSize caps the scheduled messages sent in one poll; the rest wait for the next one
+const scheduledBatchSize = 100
+
+// ErrScheduledMessageNotFound is returned when cancelling a scheduled message the user has no pending copy of
+var ErrScheduledMessageNotFound = errors.New("scheduled message not found")
+
+// ErrInvalidScheduleTime is returned when a message is scheduled in the past or beyond MaxScheduleAhead
+var ErrInvalidScheduleTime = errors.New("send_at must be in the future and within a year")
+
+// ScheduleMessageRequest represents a request to send a text message later
+type ScheduleMessageRequest struct {
+	ReceiverID uuid.UUID `json:"receiver_id" binding:"required"`
+	Content    string    `json:"content" binding:"required"`
+	SendAt     time.Time `json:"send_at" binding:"required"`
+}
+
+// ScheduleMessage stores a text message to be sent to the receiver at req.SendAt. The content
+// filter and block checks run again when it is sent.
+func (s *MessageService) ScheduleMessage(senderID uuid.UUID, req ScheduleMessageRequest) (*models.ScheduledMessageResponse, error) {
+	now := time.Now()
+	if !req.SendAt.After(now) || req.SendAt.After(now.Add(MaxScheduleAhead)) {
+		return nil, ErrInvalidScheduleTime
+	}
+	if err := utils.ValidateMessageContent(utils.ContentTypeText, req.Content); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.userRepo.FindByID(req.ReceiverID); err != nil {
+		return nil, errors.New("receiver not found")
+	}
+	if blocked, err := s.isBlockedBetween(senderID, req.ReceiverID); err != nil {
+		return nil, err
+	} else if blocked {
+		return nil, ErrUserBlocked
+	}
+
+	encryptedContent, err := utils.Encrypt(req.Content)
+	if err != nil {
+		return nil, errors.New("failed to encrypt message")
+	}
+
+	scheduled := &models.ScheduledMessage{
+		SenderID:     senderID,
+		ReceiverID:   req.ReceiverID,
+		Content:      encryptedContent,
+		ScheduledFor: req.SendAt,
+	}
+	if err := s.messageRepo.CreateScheduled(scheduled); err != nil {
+		return nil, err
+	}
+
+	return &models.ScheduledMessageResponse{
+		ID:           scheduled.ID,
+		ReceiverID:   scheduled.ReceiverID,
+		Content:      req.Content,
+		ScheduledFor: scheduled.ScheduledFor,
+		Status:       scheduled.Status,
+		CreatedAt:    scheduled.CreatedAt,
+	}, nil
+}
+
+// GetScheduledMessages lists the user's scheduled messages that are still pending, soonest first
+func (s *MessageService) GetScheduledMessages(userID uuid.UUID) ([]models.ScheduledMessageResponse, error) {
+	messages, err := s.messageRepo.GetPendingScheduled(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]models.ScheduledMessageResponse, 0, len(messages))
+	for _, msg := range messages {
+		content, err := utils.Decrypt(msg.Content)
+		if err != nil {
+			content = "[Encrypted]"
+		}
+		responses = append(responses, models.ScheduledMessageResponse{
+			ID:           msg.ID,
+			ReceiverID:   msg.ReceiverID,
+			Content:      content,
+			ScheduledFor: msg.ScheduledFor,
+			Status:       msg.Status,
+			CreatedAt:    msg.CreatedAt,
+		})
+	}
+	return responses, nil
+}
+
+// CancelScheduledMessage cancels one of the user's pending scheduled messages
+func (s *MessageService) CancelScheduledMessage(userID, id uuid.UUID) error {
+	if err := s.messageRepo.CancelScheduled(id, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrScheduledMessageNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// SendDueScheduledMessages sends the scheduled messages whose time has come and returns how many
+// went out. A message that can no longer be sent is marked failed rather than retried.
+func (s *MessageService) SendDueScheduledMessages() (int, error) {
+	due, err := s.messageRepo.GetDueScheduled(time.Now(), scheduledBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, scheduled := range due {
+		claimed, err := s.messageRepo.ClaimScheduled(scheduled.ID)
+		if err != nil {
+			return sent, err
+		}
+		if !claimed {
+			continue // Cancelled meanwhile, or sent by another server
+		}
+
+		var messageID *uuid.UUID
+		sendErr := ""
+		content, err := utils.Decrypt(scheduled.Content)
+		if err == nil {
+			var message *models.MessageResponse
+			message, err = s.SendMessage(context.Background(), scheduled.SenderID, SendMessageRequest{
+				ReceiverID: scheduled.ReceiverID,
+				Content:    content,
+			})
+			if err == nil {
+				messageID = &message.ID
+				sent++
+			}
+		}
+		if err != nil {
+			utils.Logger.Warn("Failed to send scheduled message", "scheduled_message_id", scheduled.ID, "error", err)
+			sendErr = err.Error()
+		}
+
+		if err := s.messageRepo.CompleteScheduled(scheduled.ID, messageID, sendErr); err != nil {
+			utils.Logger.Error("Failed to record scheduled message outcome", "scheduled_message_id", scheduled.ID, "error", err)
+		}
+	}
+	return sent, nil
+}
+
+// RunScheduledMessages sends due scheduled messages every interval until stop is closed
+func (s *MessageService) RunScheduledMessages(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			sent, err := s.SendDueScheduledMessages()
+			if err != nil {
+				utils.Logger.Error("Failed to send scheduled messages", "error", err)
+			} else if sent > 0 {
+				utils.Logger.Info("Sent scheduled messages", "count", sent)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// GetLabels returns the labels the user put on a conversation
+func (s *MessageService) GetLabels(userID, partnerID uuid.UUID) ([]models.Label, error) {
+	labels, err := s.messageRepo.GetLabels(userID, partnerID)
+	if err != nil {
+		return nil, err
+	}
+	return toLabels(labels), nil
+}
+
+// GetAllLabels returns every distinct label the user has created
+func (s *MessageService) GetAllLabels(userID uuid.UUID) ([]models.Label, error) {
+	labels, err := s.messageRepo.GetDistinctLabels(userID)
+	if err != nil {
+		return nil, err
+	}
+	if labels == nil {
+		labels = []models.Label{}
+	}
+	return labels, nil
+}
+
+// toLabels converts conversation labels to their public form, never returning nil
+func toLabels(rows []models.ConversationLabel) []models.Label {
+	labels := make([]models.Label, 0, len(rows))
+	for i := range rows {
+		labels = append(labels, rows[i].ToLabel())
+	}
+	return labels
+}
+
+// WithPrimary returns a copy of the service that reads messages from the primary
+// database, for clients that must observe their own recent writes
+func (s *MessageService) WithPrimary() *MessageService {
+	primary := *s
+	primary.messageRepo = s.messageRepo.WithPrimary()
+	return &primary
+}
+
+// SendMessageRequest represents a message send request
+type SendMessageRequest struct {
+	ReceiverID uuid.UUID `json:"receiver_id" binding:"required"`
+	Content    string    `json:"content"`  // Required unless an attachment is sent, where it is the caption
+	Priority   string    `json:"priority"` // Only honoured for admins; everyone else sends "normal"
+	// One of text (default), location, contact or audio; see utils.ValidateMessageContent
+	ContentType string `json:"content_type"`
+	// URL returned by POST /messages/attachments
+	AttachmentURL string `json:"attachment_url"`
+	// Message of the same conversation this one replies to
+	ReplyToID *uuid.UUID `json:"reply_to_id"`
+}
+
+// SaveDraftRequest represents a draft save request
+type SaveDraftRequest struct {
+	Content string `json:"content"`
+}
+
+// EditMessageRequest represents a message edit request
+type EditMessageRequest struct {
+	Content string `json:"content" binding:"required"`
+}
+
+// SendMessage sends a message from one user to another. ctx only scopes logging to the request.
+func (s *MessageService) SendMessage(ctx context.Context, senderID uuid.UUID, req SendMessageRequest) (*models.MessageResponse, error) {
+	if req.Content == "" && req.AttachmentURL == "" {
+		return nil, errors.New("content or attachment_url is required")
+	}
+
+	var attachmentType string
+	if req.AttachmentURL != "" {
+		if s.attachments == nil {
+			return nil, ErrInvalidAttachmentURL
+		}
+		kind, err := s.attachments.ResolveURL(req.AttachmentURL)
+		if err != nil {
+			return nil, err
+		}
+		attachmentType = kind
+	}
+
+	// Validate receiver exists
+	receiver, err := s.userRepo.FindByID(req.ReceiverID)
+	if err != nil {
+		return nil, errors.New("receiver not found")
+	}
+
+	if blocked, err := s.isBlockedBetween(senderID, req.ReceiverID); err != nil {
+		return nil, err
+	} else if blocked {
+		return nil, ErrUserBlocked
+	}
+
+	// Get sender info
+	sender, err := s.userRepo.FindByID(senderID)
+	if err != nil {
+		return nil, errors.New("sender not found")
+	}
+
+	var replyTo *models.Message
+	if req.ReplyToID != nil {
+		if replyTo, err = s.replyTarget(*req.ReplyToID, senderID, req.ReceiverID); err != nil {
+			return nil, err
+		}
+	}
+
+	// Only admins may raise the priority of a message
+	priority := models.MessagePriorityNormal
+	if sender.IsAdmin() && req.Priority != "" {
+		priority = models.MessagePriority(req.Priority)
+		if !priority.IsValid() {
+			return nil, ErrInvalidPriority
+		}
+	}
+
+	contentType := req.ContentType
+	if contentType == "" {
+		contentType = utils.ContentTypeText
+	}
+
+	// Audio content is an attachment key, so only the other types are moderated
+	if s.contentFilter != nil && contentType != utils.ContentTypeAudio {
+		filtered, violations, err := s.contentFilter.Filter(req.Content)
+		if err != nil {
+			return nil, err
+		}
+		if len(violations) > 0 {
+			utils.LoggerFromContext(ctx).Info("Content filter matched", "sender_id", senderID, "rules", violations)
+			if hasHighSeverity(violations) {
+				return nil, ErrContentRejected
+			}
+			req.Content = filtered
+		}
+	}
+
+	if err := utils.ValidateMessageContent(contentType, req.Content); err != nil {
+		return nil, err
+	}
+
+	// Encrypt message content
+	encryptedContent, err := utils.Encrypt(req.Content)
+	if err != nil {
+		return nil, errors.New("failed to encrypt message")
+	}
+
+	// Create message
+	message := &models.Message{
+		SenderID:       senderID,
+		ReceiverID:     req.ReceiverID,
+		Content:        encryptedContent,
+		ContentType:    contentType,
+		Priority:       priority,
+		AttachmentURL:  req.AttachmentURL,
+		AttachmentType: attachmentType,
+	}
+
+	// The preview is kept with the reply so it still renders if the original is edited
+	if replyTo != nil {
+		encryptedPreview, err := utils.Encrypt(replyPreview(replyTo))
+		if err != nil {
+			return nil, errors.New("failed to encrypt message")
+		}
+		message.ReplyToID = &replyTo.ID
+		message.ReplyPreview = encryptedPreview
+	}
+
+	if err := s.messageRepo.Create(message); err != nil {
+		return nil, err
+	}
+	message.ReplyTo = replyTo
+	metrics.MessagesSentTotal.Inc()
+	s.indexMessage(message.ID, contentType, req.Content)
+
+	// The draft has been sent, so it no longer needs to be kept
+	_ = s.messageRepo.DeleteDraft(senderID, req.ReceiverID)
+
+	// Return decrypted message response
+	response := &models.MessageResponse{
+		ID:              message.ID,
+		SenderID:        message.SenderID,
+		ReceiverID:      message.ReceiverID,
+		Content:         req.Content, // Original unencrypted content
+		ContentType:     message.ContentType,
+		IsRead:          message.IsRead,
+		IsDelivered:     message.DeliveredAt != nil,
+		DeliveredAt:     message.DeliveredAt,
+		CreatedAt:       message.CreatedAt,
+		IsDeleted:       message.IsDeleted,
+		Edited:          message.Edited,
+		PreviousContent: "",
+		Priority:        message.Priority,
+		AttachmentURL:   message.AttachmentURL,
+		AttachmentType:  message.AttachmentType,
+		ReplyTo:         replyResponse(*message),
+		Sender:          sender.ToPublicUser(),
+	}
+
+	s.deliverMessage(response, sender, receiver)
+	return response, nil
+}
+
+// ForwardMessageRequest represents a request to forward a message to other users
+type ForwardMessageRequest struct {
+	SourceMessageID uuid.UUID   `json:"source_message_id" binding:"required"`
+	ReceiverIDs     []uuid.UUID `json:"receiver_ids" binding:"required"`
+}
+
+// ForwardMessage sends a copy of a message the sender sent or received to each of receiverIDs.
+// The copies are marked as forwarded from the source message.
+func (s *MessageService) ForwardMessage(senderID, sourceMessageID uuid.UUID, receiverIDs []uuid.UUID) ([]models.MessageResponse, error) {
+	if len(receiverIDs) == 0 || len(receiverIDs) > MaxForwardRecipients {
+		return nil, ErrForwardSize
+	}
+
+	source, err := s.messageRepo.FindByID(sourceMessageID)
+	if err != nil {
+		return nil, err
+	}
+	if source.SenderID != senderID && source.ReceiverID != senderID {
+		return nil, ErrNotConversationMember
+	}
+	if source.IsDeleted {
+		return nil, errors.New("cannot forward a deleted message")
+	}
+
+	content, err := utils.Decrypt(source.Content)
+	if err != nil {
+		return nil, errors.New("failed to decrypt message")
+	}
+
+	sender, err := s.userRepo.FindByID(senderID)
+	if err != nil {
+		return nil, errors.New("sender not found")
+	}
+
+	// Check every receiver first so a bad one doesn't leave the message forwarded to only some
+	seen := make(map[uuid.UUID]bool, len(receiverIDs))
+	receivers := make([]*models.User, 0, len(receiverIDs))
+	for _, receiverID := range receiverIDs {
+		if seen[receiverID] {
+			continue
+		}
+		seen[receiverID] = true
+
+		receiver, err := s.userRepo.FindByID(receiverID)
+		if err != nil {
+			return nil, errors.New("receiver not found")
+		}
+		if blocked, err := s.isBlockedBetween(senderID, receiverID); err != nil {
+			return nil, err
+		} else if blocked {
+			return nil, ErrUserBlocked
+		}
+		receivers = append(receivers, receiver)
+	}
+
+	responses := make([]models.MessageResponse, 0, len(receivers))
+	for _, receiver := range receivers {
+		encryptedContent, err := utils.Encrypt(content)
+		if err != nil {
+			return nil, errors.New("failed to encrypt message")
+		}
+
+		message := &models.Message{
+			SenderID:        senderID,
+			ReceiverID:      receiver.ID,
+			Content:         encryptedContent,
+			ContentType:     source.ContentType,
+			AttachmentURL:   source.AttachmentURL,
+			AttachmentType:  source.AttachmentType,
+			IsForwarded:     true,
+			ForwardedFromID: &source.ID,
+		}
+		if err := s.messageRepo.Create(message); err != nil {
+			return nil, err
+		}
+		s.indexMessage(message.ID, message.ContentType, content)
+
+		message.Sender = *sender
+		response := toMessageResponse(*message)
+		s.deliverMessage(&response, sender, receiver)
+		responses = append(responses, response)
+	}
+
+	return responses, nil
+}
+
+// deliverMessage tells the receiver about a new direct message: with a notification and push
+// unless they muted the conversation, and in real time over the WebSocket
+func (s *MessageService) deliverMessage(message *models.MessageResponse, sender, receiver *models.User) {
+	s.invalidateConversations(sender.ID, receiver.ID)
+
+	// A first message makes the two users presence subscribers of each other
+	if s.wsHub != nil {
+		s.wsHub.InvalidatePresenceCache(sender.ID, receiver.ID)
+	}
+
+	// Create notification for receiver
+	notificationContent := message.Content
+	if len(notificationContent) > 50 {
+		notificationContent = notificationContent[:50] + "..."
+	}
+	if notificationContent == "" {
+		notificationContent = "[" + message.AttachmentType + "]"
+	}
+
+	// A receiver who muted the conversation still gets the message, just not the notification
+	if muted, err := s.IsMuted(receiver.ID, sender.ID); err != nil || !muted {
+		notification := &models.Notification{
+			UserID:      receiver.ID,
+			Type:        models.NotificationTypeMessage,
+			Content:     utils.T(receiver.Language, "new_message_notification", sender.Username, notificationContent),
+			ReferenceID: &message.ID,
+		}
+		_ = s.notificationRepo.Create(notification)
+
+		// Send push notification in the background
+		if receiver.DeviceToken != "" {
+			s.pushService.QueueMessageNotification(receiver, sender.Username, notificationContent)
+
+			// Wake an offline app so the message is already there when it is opened
+			if s.wsHub == nil || !s.wsHub.IsUserOnline(receiver.ID) {
+				s.pushService.QueueSilentSync(receiver, map[string]interface{}{
+					"type":       "sync",
+					"message_id": message.ID.String(),
+					"sender_id":  sender.ID.String(),
+				})
+			}
+		}
+	}
+
+	// Deliver the message in real time, with the replied-to message so it can be rendered inline
+	if s.wsHub != nil {
+		data := map[string]interface{}{"message_id": message.ID}
+		if message.ReplyTo != nil {
+			data["reply_to"] = message.ReplyTo
+		}
+		if message.IsForwarded {
+			data["is_forwarded"] = true
+		}
+		_ = s.wsHub.SendToUser(receiver.ID, &websocket.Message{
+			Type:       "new_message",
+			SenderID:   sender.ID,
+			ReceiverID: receiver.ID,
+			MessageID:  &message.ID, // Echoed back in the client's ack
+			Content:    message.Content,
+			Data:       data,
+			Timestamp:  time.Now(),
+		})
+	}
+
+	s.unreadService.PushUnreadCounts(receiver.ID)
+}
+
+// replyTarget returns the message a new message from senderID to receiverID replies to.
+// It must belong to their conversation and not be deleted.
+func (s *MessageService) replyTarget(messageID, senderID, receiverID uuid.UUID) (*models.Message, error) {
+	message, err := s.messageRepo.FindByID(messageID)
+	if err != nil {
+		return nil, ErrInvalidReply
+	}
+
+	inConversation := (message.SenderID == senderID && message.ReceiverID == receiverID) ||
+		(message.SenderID == receiverID && message.ReceiverID == senderID)
+	if !inConversation {
+		return nil, ErrInvalidReply
+	}
+
+	if message.IsDeleted {
+		return nil, errors.New("cannot reply to a deleted message")
+	}
+	return message, nil
+}
+
+// replyPreview returns the first models.ReplyPreviewLength characters of a message's decrypted content
+func replyPreview(message *models.Message) string {
+	content, err := utils.Decrypt(message.Content)
+	if err != nil {
+		content = "[Encrypted]"
+	}
+
+	// Audio content is an attachment key, not something to show
+	if message.ContentType == utils.ContentTypeAudio {
+		content = ""
+	}
+	if content == "" && message.AttachmentType != "" {
+		return "[" + message.AttachmentType + "]"
+	}
+
+	if runes := []rune(content); len(runes) > models.ReplyPreviewLength {
+		content = string(runes[:models.ReplyPreviewLength])
+	}
+	return content
+}
+
+// replyResponse describes the message msg replies to, or returns nil if it is not a reply.
+// The content is the preview saved with the reply; the other fields are only known when the
+// replied-to message was loaded along with msg.
+func replyResponse(msg models.Message) *models.MessageResponse {
+	if msg.ReplyToID == nil {
+		return nil
+	}
+
+	preview, err := utils.Decrypt(msg.ReplyPreview)
+	if err != nil {
+		preview = "[Encrypted]"
+	}
+
+	reply := &models.MessageResponse{
+		ID:      *msg.ReplyToID,
+		Content: preview,
+	}
+	if original := msg.ReplyTo; original != nil {
+		reply.SenderID = original.SenderID
+		reply.ReceiverID = original.ReceiverID
+		reply.ContentType = original.ContentType
+		reply.AttachmentType = original.AttachmentType
+		reply.CreatedAt = original.CreatedAt
+		if original.IsDeleted {
+			reply.IsDeleted = true
+			reply.Content = "[message deleted]"
+			reply.AttachmentType = ""
+		}
+	}
+	return reply
+}
+
+// isBlockedBetween reports whether either user has blocked the other
+func (s *MessageService) isBlockedBetween(userID1, userID2 uuid.UUID) (bool, error) {
+	blocked, err := s.userRepo.IsBlocked(userID1, userID2)
+	if err != nil || blocked {
+		return blocked, err
+	}
+	return s.userRepo.IsBlocked(userID2, userID1)
+}
+
+// ConversationCursor marks where a page of conversation history starts; the zero value starts from the newest message
+type ConversationCursor struct {
+	BeforeID   uuid.UUID  // Only messages older than this message
+	BeforeTime *time.Time // Only messages created before this time
+}
+
+// GetConversation retrieves messages between two users.
+//
+// Deprecated: offset pagination skips or repeats messages that arrive during scrollback; use GetConversationBefore.
+func (s *MessageService) GetConversation(userID1, userID2 uuid.UUID, limit, offset int) ([]models.MessageResponse, error) {
+	messages, err := s.messageRepo.GetConversation(userID1, userID2, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return s.toConversationResponses(messages)
+}
+
+// GetConversationBefore returns a page of up to limit messages between two users, newest first, starting at cursor.
+// nextCursor is the ID of the oldest message returned, or nil when there are no older messages.
+func (s *MessageService) GetConversationBefore(userID1, userID2 uuid.UUID, cursor ConversationCursor, limit int) (responses []models.MessageResponse, nextCursor *uuid.UUID, err error) {
+	var messages []models.Message
+	if cursor.BeforeTime != nil {
+		messages, err = s.messageRepo.GetConversationBeforeTime(userID1, userID2, *cursor.BeforeTime, limit)
+	} else {
+		messages, err = s.messageRepo.GetConversationBefore(userID1, userID2, cursor.BeforeID, limit)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	responses, err = s.toConversationResponses(messages)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// A short page reached the start of the conversation
+	if limit > 0 && len(messages) == limit {
+		oldest := messages[len(messages)-1].ID
+		nextCursor = &oldest
+	}
+	return responses, nextCursor, nil
+}
+
+// GetMessagesSince returns the direct messages userID received after the direct or group message
+// since, oldest first, for a client catching up after a reconnect
+func (s *MessageService) GetMessagesSince(userID, since uuid.UUID) ([]models.MessageResponse, error) {
+	messages, err := s.messageRepo.GetDirectMessagesSince(userID, since)
+	if err != nil {
+		return nil, err
+	}
+	return s.toConversationResponses(messages)
+}
+
+// toConversationResponses decrypts a page of messages and attaches their reactions
+func (s *MessageService) toConversationResponses(messages []models.Message) ([]models.MessageResponse, error) {
+	// Decrypt messages and convert to response format
+	responses := make([]models.MessageResponse, 0, len(messages))
+	for _, msg := range messages {
+		responses = append(responses, toMessageResponse(msg))
+	}
+
+	if err := s.attachReactions(responses); err != nil {
+		return nil, err
+	}
+
+	return responses, nil
+}
+
+// attachReactions fills in the reaction counts of each message with a single query
+func (s *MessageService) attachReactions(responses []models.MessageResponse) error {
+	ids := make([]uuid.UUID, len(responses))
+	for i := range responses {
+		ids[i] = responses[i].ID
+	}
+
+	reactions, err := s.messageRepo.GetReactionsForMessages(ids)
+	if err != nil {
+		return err
+	}
+	for i := range responses {
+		responses[i].Reactions = reactions[responses[i].ID]
+	}
+	return nil
+}
+
+// SearchConversation finds messages between two users whose content contains opts.Query, newest first
+func (s *MessageService) SearchConversation(userID, otherUserID uuid.UUID, opts SearchOptions) ([]models.MessageResponse, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Content is encrypted at rest, so the date range is applied in SQL and the query after decryption
+	messages, err := s.messageRepo.SearchConversation(userID, otherUserID, opts.Before, opts.After)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]models.MessageResponse, 0)
+	for _, msg := range messages {
+		response := toMessageResponse(msg)
+		if !opts.matches(response.Content) {
+			continue
+		}
+		responses = append(responses, response)
+		if len(responses) == maxMessageSearchResults {
+			break
+		}
+	}
+
+	return responses, nil
+}
+
+// ExportConversation serializes every message between userID and partnerID, oldest first, as
+// "json" or "csv" and returns the content type
+func (s *MessageService) ExportConversation(userID, partnerID uuid.UUID, format string) ([]byte, string, error) {
+	if format != "json" && format != "csv" {
+		return nil, "", ErrInvalidExportFormat
+	}
+
+	rows := make([]ConversationMessageExport, 0)
+	err := s.messageRepo.ForEachInConversation(userID, partnerID, func(messages []models.Message) error {
+		for _, msg := range messages {
+			content, err := utils.Decrypt(msg.Content)
+			if err != nil {
+				content = "[Encrypted]"
+			}
+			if msg.IsDeleted {
+				content = "[message deleted]"
+			}
+			rows = append(rows, ConversationMessageExport{
+				ID:             msg.ID,
+				SenderUsername: msg.Sender.ToPublicUser().Username,
+				Content:        content,
+				CreatedAt:      msg.CreatedAt,
+				IsRead:         msg.IsRead,
+				Edited:         msg.Edited,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	if format == "json" {
+		data, err := json.Marshal(rows)
+		return data, "application/json", err
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write([]string{"id", "sender_username", "content", "created_at", "is_read", "edited"})
+	for _, row := range rows {
+		_ = w.Write([]string{
+			row.ID.String(),
+			row.SenderUsername,
+			row.Content,
+			row.CreatedAt.UTC().Format(time.RFC3339),
+			strconv.FormatBool(row.IsRead),
+			strconv.FormatBool(row.Edited),
+		})
+	}
+	w.Flush()
+	return buf.Bytes(), "text/csv", w.Error()
+}
+
+// toMessageResponse decrypts a message into its response format
+func toMessageResponse(msg models.Message) models.MessageResponse {
+	decryptedContent, err := utils.Decrypt(msg.Content)
+	if err != nil {
+		// If decryption fails, use placeholder
+		decryptedContent = "[Encrypted]"
+	}
+
+	previousContent := ""
+	if msg.PreviousContent != "" {
+		if prev, err := utils.Decrypt(msg.PreviousContent); err == nil {
+			previousContent = prev
+		}
+	}
+
+	displayContent := decryptedContent
+	attachmentURL, attachmentType := msg.AttachmentURL, msg.AttachmentType
+	if msg.IsDeleted {
+		displayContent = "[message deleted]"
+		attachmentURL, attachmentType = "", ""
+	}
+
+	return models.MessageResponse{
+		ID:              msg.ID,
+		SenderID:        msg.SenderID,
+		ReceiverID:      msg.ReceiverID,
+		Content:         displayContent,
+		ContentType:     msg.ContentType,
+		IsRead:          msg.IsRead,
+		ReadAt:          msg.ReadAt,
+		IsDelivered:     msg.DeliveredAt != nil,
+		DeliveredAt:     msg.DeliveredAt,
+		IsDeleted:       msg.IsDeleted,
+		DeletedAt:       msg.DeletedAt,
+		DeletedBy:       msg.DeletedBy,
+		Edited:          msg.Edited,
+		EditedAt:        msg.EditedAt,
+		PreviousContent: previousContent,
+		Priority:        msg.Priority,
+		AttachmentURL:   attachmentURL,
+		AttachmentType:  attachmentType,
+		ReplyTo:         replyResponse(msg),
+		IsForwarded:     msg.IsForwarded,
+		ForwardedFromID: msg.ForwardedFromID,
+		CreatedAt:       msg.CreatedAt,
+		Sender:          msg.Sender.ToPublicUser(),
+	}
+}
+
+// MarkAsRead marks a message or conversation as read
+func (s *MessageService) MarkAsRead(receiverID, senderID uuid.UUID) error {
+	read, err := s.messageRepo.MarkConversationAsRead(receiverID, senderID)
+	if err != nil {
+		return err
+	}
+	if len(read) > 0 {
+		s.invalidateConversations(receiverID, senderID)
+	}
+
+	// Notify sender via WebSocket which of their messages have been read
+	if len(read) > 0 && s.wsHub != nil {
+		messageIDs := make([]uuid.UUID, len(read))
+		for i, msg := range read {
+			messageIDs[i] = msg.ID
+		}
+		readAt := read[0].ReadAt // NOW() is the same for every row of the update
+
+		readReceipt := websocket.Message{
+			Type:       "message_read",
+			SenderID:   receiverID, // The one who read the messages
+			ReceiverID: senderID,   // The one who sent the messages (to notify)
+			ReadAt:     &readAt,
+			MessageIDs: messageIDs,
+			Timestamp:  time.Now(),
+		}
+		s.wsHub.SendToUser(senderID, &readReceipt)
+	}
+
+	s.unreadService.PushUnreadCounts(receiverID)
+
+	return nil
+}
+
+// MarkAllConversationsAsRead marks every message the user received as read. Each sender whose
+// messages changed gets a single all_conversations_read event instead of per-message receipts.
+func (s *MessageService) MarkAllConversationsAsRead(userID uuid.UUID) error {
+	read, err := s.messageRepo.MarkAllAsRead(userID)
+	if err != nil {
+		return err
+	}
+	if len(read) == 0 {
+		return nil
+	}
+
+	affected := make([]uuid.UUID, 0, len(read)+1)
+	affected = append(affected, userID)
+	for _, conversation := range read {
+		affected = append(affected, conversation.SenderID)
+	}
+	s.invalidateConversations(affected...)
+
+	if s.wsHub != nil {
+		for _, conversation := range read {
+			readAt := conversation.ReadAt
+			_ = s.wsHub.SendToUser(conversation.SenderID, &websocket.Message{
+				Type:       "all_conversations_read",
+				SenderID:   userID, // The one who read the messages
+				ReceiverID: conversation.SenderID,
+				ReadAt:     &readAt,
+				Timestamp:  time.Now(),
+			})
+		}
+	}
+
+	s.unreadService.PushUnreadCounts(userID)
+
+	return nil
+}
+
+// MarkAsDelivered records that receiverID's device received senderID's messages
+func (s *MessageService) MarkAsDelivered(receiverID, senderID uuid.UUID) error {
+	delivered, err := s.messageRepo.MarkAsDelivered(receiverID, senderID)
+	if err != nil {
+		return err
+	}
+	if delivered > 0 {
+		s.invalidateConversations(receiverID, senderID)
+	}
+
+	// Notify sender via WebSocket that their messages have been delivered
+	if delivered > 0 && s.wsHub != nil {
+		deliveryReceipt := websocket.Message{
+			Type:       "message_delivered",
+			SenderID:   receiverID, // The one who received the messages
+			ReceiverID: senderID,   // The one who sent the messages (to notify)
+			Timestamp:  time.Now(),
+		}
+		s.wsHub.SendToUser(senderID, &deliveryReceipt)
 	}
+
+	return nil
 }
 
-// SendMessageRequest represents a message send request
-type SendMessageRequest struct {
-	ReceiverID uuid.UUID `json:"receiver_id" binding:"required"`
-	Content    string    `json:"content" binding:"required"`
+// AcknowledgeDelivery marks a message delivered once its receiver's client acknowledged it.
+// It implements websocket.DeliveryAcknowledger.
+func (s *MessageService) AcknowledgeDelivery(receiverID, messageID uuid.UUID) (*websocket.Delivery, error) {
+	delivered, err := s.messageRepo.MarkDelivered(messageID, receiverID)
+	if err != nil || delivered == nil {
+		return nil, err
+	}
+	s.invalidateConversations(receiverID, delivered.SenderID)
+
+	return &websocket.Delivery{
+		MessageID:   messageID,
+		SenderID:    delivered.SenderID,
+		DeliveredAt: delivered.DeliveredAt,
+	}, nil
 }
 
-// EditMessageRequest represents a message edit request
-type EditMessageRequest struct {
-	Content string `json:"content" binding:"required"`
+// GetUnreadCount gets the count of unread messages for a user
+func (s *MessageService) GetUnreadCount(userID uuid.UUID) (int64, error) {
+	return s.messageRepo.GetUnreadCount(userID)
 }
 
-// SendMessage sends a message from one user to another
-func (s *MessageService) SendMessage(senderID uuid.UUID, req SendMessageRequest) (*models.MessageResponse, error) {
-	// Validate receiver exists
-	receiver, err := s.userRepo.FindByID(req.ReceiverID)
-	if err != nil {
-		return nil, errors.New("receiver not found")
+// GetUnreadTotals gets the user's unread direct and group message counts
+func (s *MessageService) GetUnreadTotals(userID uuid.UUID) (*UnreadCounts, error) {
+	return s.unreadService.GetUnreadCounts(userID)
+}
+
+// GetRecentConversations gets recent conversations for a user, from the cache when possible.
+// Archived conversations are left out unless includeArchived is set; only that default list is cached.
+// ctx only scopes logging to the request.
+func (s *MessageService) GetRecentConversations(ctx context.Context, userID uuid.UUID, limit int, includeArchived bool) ([]models.ConversationSummary, error) {
+	if !includeArchived {
+		if summaries, ok := s.cachedConversations(ctx, userID, limit); ok {
+			return summaries, nil
+		}
 	}
 
-	// Get sender info
-	sender, err := s.userRepo.FindByID(senderID)
+	start := time.Now()
+	partners, err := s.messageRepo.GetRecentConversations(userID, limit, includeArchived)
 	if err != nil {
-		return nil, errors.New("sender not found")
+		return nil, err
 	}
 
-	// Encrypt message content
-	encryptedContent, err := utils.Encrypt(req.Content)
+	summaries, err := s.buildConversationSummaries(userID, partners)
 	if err != nil {
-		return nil, errors.New("failed to encrypt message")
+		return nil, err
 	}
+	utils.LoggerFromContext(ctx).Debug("Built recent conversations", "count", len(summaries), "duration", time.Since(start))
 
-	// Create message
-	message := &models.Message{
-		SenderID:   senderID,
-		ReceiverID: req.ReceiverID,
-		Content:    encryptedContent,
+	if !includeArchived {
+		s.cacheConversations(ctx, userID, limit, summaries)
 	}
+	return summaries, nil
+}
 
-	if err := s.messageRepo.Create(message); err != nil {
+// GetArchivedConversations gets the conversations a user archived, most recently active first
+func (s *MessageService) GetArchivedConversations(userID uuid.UUID, limit int) ([]models.ConversationSummary, error) {
+	partners, err := s.messageRepo.GetArchivedConversations(userID, limit)
+	if err != nil {
 		return nil, err
 	}
 
-	// Create notification for receiver
-	notificationContent := req.Content
-	if len(notificationContent) > 50 {
-		notificationContent = notificationContent[:50] + "..."
+	return s.buildConversationSummaries(userID, partners)
+}
+
+// cachedConversationList is the cached form of a user's recent conversations. The summaries
+// hold decrypted message previews, so they are encrypted before they leave the process.
+type cachedConversationList struct {
+	Limit     int    `json:"limit"`
+	Summaries string `json:"summaries"` // Encrypted JSON
+}
+
+// conversationsCacheKey is the cache key of a user's recent conversations
+func conversationsCacheKey(userID uuid.UUID) string {
+	return "conversations:" + userID.String()
+}
+
+// cachedConversations returns the cached recent conversations of a user if they were cached for the same limit
+func (s *MessageService) cachedConversations(ctx context.Context, userID uuid.UUID, limit int) ([]models.ConversationSummary, bool) {
+	if s.cache == nil {
+		return nil, false
 	}
 
-	notification := &models.Notification{
-		UserID:      req.ReceiverID,
-		Type:        models.NotificationTypeMessage,
-		Content:     utils.T(receiver.Language, "new_message_notification", sender.Username, notificationContent),
-		ReferenceID: &message.ID,
+	var cached cachedConversationList
+	if err := s.cache.Get(conversationsCacheKey(userID), &cached); err != nil {
+		if !errors.Is(err, ErrCacheMiss) {
+			utils.LoggerFromContext(ctx).Warn("Conversation cache read failed", "error", err)
+		}
+		return nil, false
+	}
+	if cached.Limit != limit {
+		return nil, false
 	}
-	_ = s.notificationRepo.Create(notification)
 
-	// Send push notification
-	if receiver.DeviceToken != "" {
-		_ = s.pushService.SendMessageNotification(receiver, sender.Username, notificationContent)
+	data, err := utils.Decrypt(cached.Summaries)
+	if err != nil {
+		return nil, false
+	}
+	var summaries []models.ConversationSummary
+	if err := json.Unmarshal([]byte(data), &summaries); err != nil {
+		return nil, false
 	}
+	return summaries, true
+}
 
-	// Return decrypted message response
-	return &models.MessageResponse{
-		ID:              message.ID,
-		SenderID:        message.SenderID,
-		ReceiverID:      message.ReceiverID,
-		Content:         req.Content, // Original unencrypted content
-		IsRead:          message.IsRead,
-		CreatedAt:       message.CreatedAt,
-		IsDeleted:       message.IsDeleted,
-		Edited:          message.Edited,
-		PreviousContent: "",
-		Sender:          sender.ToPublicUser(),
-	}, nil
+// cacheConversations stores a user's recent conversations; failures only cost a cache miss later
+func (s *MessageService) cacheConversations(ctx context.Context, userID uuid.UUID, limit int, summaries []models.ConversationSummary) {
+	if s.cache == nil {
+		return
+	}
+
+	data, err := json.Marshal(summaries)
+	if err != nil {
+		return
+	}
+	encrypted, err := utils.Encrypt(string(data))
+	if err != nil {
+		return
+	}
+
+	cached := cachedConversationList{Limit: limit, Summaries: encrypted}
+	if err := s.cache.Set(conversationsCacheKey(userID), cached, conversationsCacheTTL); err != nil {
+		utils.LoggerFromContext(ctx).Warn("Conversation cache write failed", "error", err)
+	}
 }
 
-// GetConversation retrieves messages between two users
-func (s *MessageService) GetConversation(userID1, userID2 uuid.UUID, limit, offset int) ([]models.MessageResponse, error) {
-	messages, err := s.messageRepo.GetConversation(userID1, userID2, limit, offset)
+// invalidateConversations drops the cached conversation lists of the given users
+func (s *MessageService) invalidateConversations(userIDs ...uuid.UUID) {
+	if s.cache == nil {
+		return
+	}
+
+	keys := make([]string, 0, len(userIDs))
+	for _, userID := range userIDs {
+		keys = append(keys, conversationsCacheKey(userID))
+	}
+	if err := s.cache.Delete(keys...); err != nil {
+		utils.Logger.Warn("Conversation cache invalidation failed", "user_ids", userIDs, "error", err)
+	}
+}
+
+// MessageSearch is one page of full-text message search results
+type MessageSearch struct {
+	Results []models.MessageSearchResult
+	Total   int64
+	Limit   int
+	Offset  int
+}
+
+// SearchMessages runs a full-text search over the messages the user sent or received, newest first
+func (s *MessageService) SearchMessages(userID uuid.UUID, query string, limit, offset int) (*MessageSearch, error) {
+	query = utils.SanitizeString(query)
+	if query == "" {
+		return nil, errors.New("search query required")
+	}
+	if limit <= 0 || limit > maxMessageSearchResults {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	messages, err := s.messageRepo.SearchMessages(userID, query, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	total, err := s.messageRepo.SearchMessagesCount(userID, query)
 	if err != nil {
 		return nil, err
 	}
 
-	// Decrypt messages and convert to response format
-	responses := make([]models.MessageResponse, 0, len(messages))
+	results := make([]models.MessageSearchResult, 0, len(messages))
 	for _, msg := range messages {
-		decryptedContent, err := utils.Decrypt(msg.Content)
-		if err != nil {
-			// If decryption fails, skip the message or use placeholder
-			decryptedContent = "[Encrypted]"
+		partner := msg.Receiver
+		if msg.ReceiverID == userID {
+			partner = msg.Sender
 		}
-
-		previousContent := ""
-		if msg.PreviousContent != "" {
-			if prev, err := utils.Decrypt(msg.PreviousContent); err == nil {
-				previousContent = prev
-			}
-		}
-
-		displayContent := decryptedContent
-		if msg.IsDeleted {
-			displayContent = "[message deleted]"
-		}
-
-		responses = append(responses, models.MessageResponse{
-			ID:              msg.ID,
-			SenderID:        msg.SenderID,
-			ReceiverID:      msg.ReceiverID,
-			Content:         displayContent,
-			IsRead:          msg.IsRead,
-			ReadAt:          msg.ReadAt,
-			IsDeleted:       msg.IsDeleted,
-			DeletedAt:       msg.DeletedAt,
-			DeletedBy:       msg.DeletedBy,
-			Edited:          msg.Edited,
-			EditedAt:        msg.EditedAt,
-			PreviousContent: previousContent,
-			CreatedAt:       msg.CreatedAt,
-			Sender:          msg.Sender.ToPublicUser(),
+		results = append(results, models.MessageSearchResult{
+			MessageResponse: toMessageResponse(msg),
+			Partner:         partner.ToPublicUser(),
 		})
 	}
 
-	return responses, nil
+	return &MessageSearch{
+		Results: results,
+		Total:   total,
+		Limit:   limit,
+		Offset:  offset,
+	}, nil
 }
 
-// MarkAsRead marks a message or conversation as read
-func (s *MessageService) MarkAsRead(receiverID, senderID uuid.UUID) error {
-	err := s.messageRepo.MarkConversationAsRead(receiverID, senderID)
-	if err != nil {
-		return err
+// indexMessage updates the full-text search vector of a message from its plaintext content.
+// Audio content is an attachment key rather than text, so it is never indexed.
+func (s *MessageService) indexMessage(messageID uuid.UUID, contentType, content string) {
+	if contentType == utils.ContentTypeAudio {
+		content = ""
 	}
+	if err := s.messageRepo.UpdateSearchVector(messageID, content); err != nil {
+		utils.Logger.Error("Failed to index message for search", "message_id", messageID, "error", err)
+	}
+}
 
-	// Notify sender via WebSocket that their messages have been read
-	if s.wsHub != nil {
-		readReceipt := websocket.Message{
-			Type:       "message_read",
-			SenderID:   receiverID, // The one who read the messages
-			ReceiverID: senderID,   // The one who sent the messages (to notify)
-			Timestamp:  time.Now(),
+// BackfillSearchIndex indexes, batchSize at a time, the messages stored before full-text search existed
+func (s *MessageService) BackfillSearchIndex(batchSize int) {
+	indexed := 0
+	for {
+		messages, err := s.messageRepo.FindUnindexed(batchSize)
+		if err != nil {
+			utils.Logger.Error("Failed to load messages to index for search", "error", err)
+			return
 		}
-		s.wsHub.SendToUser(senderID, &readReceipt)
+		if len(messages) == 0 {
+			break
+		}
+
+		for _, msg := range messages {
+			content := ""
+			if !msg.IsDeleted && msg.ContentType != utils.ContentTypeAudio {
+				// Undecryptable content is indexed as empty so the backfill still moves on
+				content, _ = utils.Decrypt(msg.Content)
+			}
+			if err := s.messageRepo.UpdateSearchVector(msg.ID, content); err != nil {
+				utils.Logger.Error("Failed to index message for search", "message_id", msg.ID, "error", err)
+				return
+			}
+		}
+		indexed += len(messages)
 	}
 
-	return nil
+	if indexed > 0 {
+		utils.Logger.Info("Indexed messages for search", "count", indexed)
+	}
 }
 
-// GetUnreadCount gets the count of unread messages for a user
-func (s *MessageService) GetUnreadCount(userID uuid.UUID) (int64, error) {
-	return s.messageRepo.GetUnreadCount(userID)
+// SearchConversations finds recent conversations whose partner username or email contains query
+func (s *MessageService) SearchConversations(userID uuid.UUID, query string, limit int) ([]models.ConversationSummary, error) {
+	query = utils.SanitizeString(query)
+	if query == "" {
+		return nil, errors.New("search query required")
+	}
+	if limit <= 0 || limit > maxConversationSearchLimit {
+		limit = maxConversationSearchLimit
+	}
+
+	partners, err := s.messageRepo.SearchConversationPartners(userID, query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.buildConversationSummaries(userID, partners)
 }
 
-// GetRecentConversations gets recent conversations for a user
-func (s *MessageService) GetRecentConversations(userID uuid.UUID, limit int) ([]models.ConversationSummary, error) {
-	partners, err := s.messageRepo.GetRecentConversations(userID, limit)
+// GetLabeledConversations gets recent conversations the user labelled with label
+func (s *MessageService) GetLabeledConversations(userID uuid.UUID, label string, limit int) ([]models.ConversationSummary, error) {
+	partners, err := s.messageRepo.GetRecentConversationsWithLabel(userID, label, limit)
 	if err != nil {
 		return nil, err
 	}
 
+	return s.buildConversationSummaries(userID, partners)
+}
+
+// buildConversationSummaries loads partner profile, last message and unread count for each partner
+func (s *MessageService) buildConversationSummaries(userID uuid.UUID, partners []repositories.ConversationPartner) ([]models.ConversationSummary, error) {
 	summaries := make([]models.ConversationSummary, 0, len(partners))
 
+	partnerIDs := make([]uuid.UUID, 0, len(partners))
+	for _, partner := range partners {
+		partnerIDs = append(partnerIDs, partner.UserID)
+	}
+	drafts, err := s.messageRepo.GetDrafts(userID, partnerIDs)
+	if err != nil {
+		return nil, err
+	}
+	labels, err := s.messageRepo.GetLabelsForPartners(userID, partnerIDs)
+	if err != nil {
+		return nil, err
+	}
+	mutes, err := s.messageRepo.GetActiveConversationMutes(userID, partnerIDs)
+	if err != nil {
+		return nil, err
+	}
+	archived, err := s.messageRepo.GetArchivedPartnerIDs(userID, partnerIDs)
+	if err != nil {
+		return nil, err
+	}
+	totals, err := s.messageRepo.GetConversationMessageCounts(userID, partnerIDs)
+	if err != nil {
+		return nil, err
+	}
+
 	for _, partner := range partners {
 		user, err := s.userRepo.FindByID(partner.UserID)
 		if err != nil {
@@ -205,7 +1485,14 @@ func (s *MessageService) GetRecentConversations(userID uuid.UUID, limit int) ([]
 		}
 
 		summary := models.ConversationSummary{
-			User: user.ToPublicUser(),
+			User:          user.ToPublicUser(),
+			TotalMessages: totals[partner.UserID],
+			Labels:        toLabels(labels[partner.UserID]),
+			IsArchived:    archived[partner.UserID],
+		}
+		if mute, ok := mutes[partner.UserID]; ok {
+			summary.IsMuted = true
+			summary.MutedUntil = mute.MutedUntil
 		}
 
 		lastMessage, err := s.messageRepo.GetLastMessageBetween(userID, partner.UserID)
@@ -227,7 +1514,7 @@ func (s *MessageService) GetRecentConversations(userID uuid.UUID, limit int) ([]
 			summary.LastMessage = displayContent
 			summary.LastMessageTime = &lastMessage.CreatedAt
 			summary.LastMessageSenderID = lastMessage.SenderID
-			summary.LastMessageIsRead = lastMessage.IsRead
+			summary.LastMessageStatus = models.StatusOf(lastMessage.IsRead, lastMessage.DeliveredAt)
 		}
 
 		unreadCount, err := s.messageRepo.GetUnreadCountForConversation(userID, partner.UserID)
@@ -236,12 +1523,64 @@ func (s *MessageService) GetRecentConversations(userID uuid.UUID, limit int) ([]
 		}
 		summary.UnreadCount = unreadCount
 
+		if draft, ok := drafts[partner.UserID]; ok {
+			if content, err := utils.Decrypt(draft.Content); err == nil {
+				summary.Draft = content
+			}
+		}
+
 		summaries = append(summaries, summary)
 	}
 
 	return summaries, nil
 }
 
+// SaveDraft stores an unsent message for a conversation, replacing any previous draft.
+// Saving empty content discards the draft.
+func (s *MessageService) SaveDraft(userID, partnerID uuid.UUID, content string) error {
+	if content == "" {
+		return s.DeleteDraft(userID, partnerID)
+	}
+
+	if _, err := s.userRepo.FindByID(partnerID); err != nil {
+		return errors.New("user not found")
+	}
+
+	encryptedContent, err := utils.Encrypt(content)
+	if err != nil {
+		return errors.New("failed to encrypt draft")
+	}
+
+	if err := s.messageRepo.SaveDraft(userID, partnerID, encryptedContent); err != nil {
+		return err
+	}
+	s.invalidateConversations(userID)
+	return nil
+}
+
+// GetDraft returns the decrypted draft for a conversation, or an empty string if there is none
+func (s *MessageService) GetDraft(userID, partnerID uuid.UUID) (string, error) {
+	draft, err := s.messageRepo.GetDraft(userID, partnerID)
+	if err != nil || draft == nil {
+		return "", err
+	}
+
+	content, err := utils.Decrypt(draft.Content)
+	if err != nil {
+		return "", errors.New("failed to decrypt draft")
+	}
+	return content, nil
+}
+
+// DeleteDraft discards the draft for a conversation
+func (s *MessageService) DeleteDraft(userID, partnerID uuid.UUID) error {
+	if err := s.messageRepo.DeleteDraft(userID, partnerID); err != nil {
+		return err
+	}
+	s.invalidateConversations(userID)
+	return nil
+}
+
 // EditMessage updates the content of a message
 func (s *MessageService) EditMessage(messageID, userID uuid.UUID, req EditMessageRequest) (*models.MessageResponse, error) {
 	if req.Content == "" {
@@ -261,6 +1600,13 @@ func (s *MessageService) EditMessage(messageID, userID uuid.UUID, req EditMessag
 		return nil, errors.New("cannot edit a deleted message")
 	}
 
+	// An edit keeps the message's content type, so the new content must match it
+	if message.ContentType != "" {
+		if err := utils.ValidateMessageContent(message.ContentType, req.Content); err != nil {
+			return nil, err
+		}
+	}
+
 	previousEncrypted := message.Content
 	previousDecrypted, err := utils.Decrypt(previousEncrypted)
 	if err != nil {
@@ -275,6 +1621,8 @@ func (s *MessageService) EditMessage(messageID, userID uuid.UUID, req EditMessag
 	if err := s.messageRepo.UpdateContent(messageID, newEncrypted, previousEncrypted); err != nil {
 		return nil, err
 	}
+	s.indexMessage(messageID, message.ContentType, req.Content)
+	s.invalidateConversations(message.SenderID, message.ReceiverID)
 
 	now := time.Now()
 	message.Content = newEncrypted
@@ -294,7 +1642,10 @@ func (s *MessageService) EditMessage(messageID, userID uuid.UUID, req EditMessag
 		SenderID:        message.SenderID,
 		ReceiverID:      message.ReceiverID,
 		Content:         req.Content,
+		ContentType:     message.ContentType,
 		IsRead:          message.IsRead,
+		IsDelivered:     message.DeliveredAt != nil,
+		DeliveredAt:     message.DeliveredAt,
 		ReadAt:          message.ReadAt,
 		IsDeleted:       message.IsDeleted,
 		DeletedAt:       message.DeletedAt,
@@ -302,6 +1653,10 @@ func (s *MessageService) EditMessage(messageID, userID uuid.UUID, req EditMessag
 		Edited:          true,
 		EditedAt:        &now,
 		PreviousContent: previousDecrypted,
+		Priority:        message.Priority,
+		AttachmentURL:   message.AttachmentURL,
+		AttachmentType:  message.AttachmentType,
+		ReplyTo:         replyResponse(*message),
 		CreatedAt:       message.CreatedAt,
 		Sender:          sender.ToPublicUser(),
 	}, nil
@@ -325,6 +1680,8 @@ func (s *MessageService) DeleteMessage(messageID, userID uuid.UUID) (*models.Mes
 	if err := s.messageRepo.SoftDelete(messageID, userID); err != nil {
 		return nil, err
 	}
+	s.indexMessage(messageID, message.ContentType, "")
+	s.invalidateConversations(message.SenderID, message.ReceiverID)
 
 	now := time.Now()
 	message.IsDeleted = true
@@ -344,7 +1701,10 @@ func (s *MessageService) DeleteMessage(messageID, userID uuid.UUID) (*models.Mes
 		SenderID:        message.SenderID,
 		ReceiverID:      message.ReceiverID,
 		Content:         "[message deleted]",
+		ContentType:     message.ContentType,
 		IsRead:          message.IsRead,
+		IsDelivered:     message.DeliveredAt != nil,
+		DeliveredAt:     message.DeliveredAt,
 		ReadAt:          message.ReadAt,
 		IsDeleted:       true,
 		DeletedAt:       message.DeletedAt,
@@ -352,7 +1712,98 @@ func (s *MessageService) DeleteMessage(messageID, userID uuid.UUID) (*models.Mes
 		Edited:          message.Edited,
 		EditedAt:        message.EditedAt,
 		PreviousContent: previousDecrypted,
+		Priority:        message.Priority,
+		AttachmentURL:   message.AttachmentURL,
+		AttachmentType:  message.AttachmentType,
+		ReplyTo:         replyResponse(*message),
 		CreatedAt:       message.CreatedAt,
 		Sender:          sender.ToPublicUser(),
 	}, nil
 }
+
+// AddReactionRequest represents a reaction request
+type AddReactionRequest struct {
+	Emoji string `json:"emoji" binding:"required"`
+}
+
+// AddReaction reacts to a direct message with an emoji and returns the message's updated reaction counts.
+// The conversation partner is notified with a reaction_added event.
+func (s *MessageService) AddReaction(messageID, userID uuid.UUID, emoji string) (map[string]int, error) {
+	if err := utils.ValidateEmoji(emoji); err != nil {
+		return nil, err
+	}
+
+	message, err := s.reactableMessage(messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if message.IsDeleted {
+		return nil, errors.New("cannot react to a deleted message")
+	}
+
+	if err := s.messageRepo.AddReaction(messageID, userID, emoji); err != nil {
+		return nil, err
+	}
+
+	return s.notifyReaction("reaction_added", message, userID, emoji)
+}
+
+// RemoveReaction withdraws a user's emoji reaction and returns the message's updated reaction counts.
+// The conversation partner is notified with a reaction_removed event.
+func (s *MessageService) RemoveReaction(messageID, userID uuid.UUID, emoji string) (map[string]int, error) {
+	message, err := s.reactableMessage(messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.messageRepo.RemoveReaction(messageID, userID, emoji); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrReactionNotFound
+		}
+		return nil, err
+	}
+
+	return s.notifyReaction("reaction_removed", message, userID, emoji)
+}
+
+// reactableMessage loads a message the user sent or received
+func (s *MessageService) reactableMessage(messageID, userID uuid.UUID) (*models.Message, error) {
+	message, err := s.messageRepo.FindByID(messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message.SenderID != userID && message.ReceiverID != userID {
+		return nil, ErrNotConversationMember
+	}
+	return message, nil
+}
+
+// notifyReaction sends a reaction event to the other participant and returns the current counts
+func (s *MessageService) notifyReaction(eventType string, message *models.Message, userID uuid.UUID, emoji string) (map[string]int, error) {
+	reactions, err := s.messageRepo.GetReactions(message.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	partnerID := message.SenderID
+	if partnerID == userID {
+		partnerID = message.ReceiverID
+	}
+
+	if s.wsHub != nil && partnerID != userID {
+		_ = s.wsHub.SendToUser(partnerID, &websocket.Message{
+			Type:       eventType,
+			SenderID:   userID,
+			ReceiverID: partnerID,
+			Data: map[string]interface{}{
+				"message_id": message.ID,
+				"user_id":    userID,
+				"emoji":      emoji,
+				"reactions":  reactions,
+			},
+			Timestamp: time.Now(),
+		})
+	}
+
+	return reactions, nil
+}
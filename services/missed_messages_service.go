@@ -0,0 +1,38 @@
+package services
+
+import (
+	"github.com/google/uuid"
+)
+
+// MissedMessagesService gathers the direct and group messages a reconnecting client missed
+type MissedMessagesService struct {
+	messageService *MessageService
+	groupService   *GroupService
+}
+
+// NewMissedMessagesService creates a new missed messages service
+func NewMissedMessagesService(messageService *MessageService, groupService *GroupService) *MissedMessagesService {
+	return &MissedMessagesService{
+		messageService: messageService,
+		groupService:   groupService,
+	}
+}
+
+// LoadMissedMessages returns the messages userID received after lastSeenMessageID, which may
+// be a direct or a group message, as the payload of a pending_messages event
+func (s *MissedMessagesService) LoadMissedMessages(userID, lastSeenMessageID uuid.UUID) (map[string]interface{}, error) {
+	direct, err := s.messageService.GetMessagesSince(userID, lastSeenMessageID)
+	if err != nil {
+		return nil, err
+	}
+
+	group, err := s.groupService.GetGroupMessagesSince(userID, lastSeenMessageID)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"messages":       direct,
+		"group_messages": group,
+	}, nil
+}
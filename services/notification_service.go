@@ -1,11 +1,43 @@
 package services
 
 import (
+	"errors"
+
 	"github.com/google/uuid"
 	"mms-backend/models"
 	"mms-backend/repositories"
 )
 
+// MaxBatchReadIDs caps the number of notifications marked read in one batch
+const MaxBatchReadIDs = 100
+
+// ErrBatchReadSize is returned when a batch read request has no IDs or more than MaxBatchReadIDs
+var ErrBatchReadSize = errors.New("ids must contain between 1 and 100 notification ids")
+
+// ErrUnknownNotificationType is returned when a preference names a type that does not exist
+var ErrUnknownNotificationType = errors.New("unknown notification type")
+
+// ErrNotificationNotFound is returned for a notification that doesn't exist or belongs to someone else
+var ErrNotificationNotFound = errors.New("notification not found")
+
+// DefaultNotificationPageSize and MaxNotificationPageSize bound a page of notifications
+const (
+	DefaultNotificationPageSize = 20
+	MaxNotificationPageSize     = 100
+)
+
+// BatchReadRequest represents a request to mark selected notifications as read
+type BatchReadRequest struct {
+	IDs []uuid.UUID `json:"ids" binding:"required"`
+}
+
+// UpdatePreferenceRequest represents a request to change the preference for one notification type
+type UpdatePreferenceRequest struct {
+	Type         models.NotificationType `json:"type" binding:"required"`
+	Enabled      *bool                   `json:"enabled" binding:"required"`
+	SoundEnabled *bool                   `json:"sound_enabled" binding:"required"`
+}
+
 // NotificationService handles notification business logic
 type NotificationService struct {
 	notificationRepo *repositories.NotificationRepository
@@ -18,18 +50,33 @@ func NewNotificationService(notificationRepo *repositories.NotificationRepositor
 	}
 }
 
-// GetUserNotifications retrieves notifications for a user
+// GetUserNotifications retrieves a page of a user's notifications, newest first. A limit outside
+// 1..MaxNotificationPageSize falls back to DefaultNotificationPageSize.
 func (s *NotificationService) GetUserNotifications(userID uuid.UUID, limit, offset int) ([]models.Notification, error) {
+	if limit <= 0 || limit > MaxNotificationPageSize {
+		limit = DefaultNotificationPageSize
+	}
+	if offset < 0 {
+		offset = 0
+	}
 	return s.notificationRepo.GetUserNotifications(userID, limit, offset)
 }
 
+// CountUserNotifications counts all notifications of a user
+func (s *NotificationService) CountUserNotifications(userID uuid.UUID) (int64, error) {
+	return s.notificationRepo.CountUserNotifications(userID)
+}
+
 // GetUnreadNotifications retrieves unread notifications for a user
 func (s *NotificationService) GetUnreadNotifications(userID uuid.UUID) ([]models.Notification, error) {
 	return s.notificationRepo.GetUnreadNotifications(userID)
 }
 
-// MarkAsRead marks a notification as read
-func (s *NotificationService) MarkAsRead(notificationID uuid.UUID) error {
+// MarkAsRead marks one of userID's notifications as read
+func (s *NotificationService) MarkAsRead(userID, notificationID uuid.UUID) error {
+	if _, err := s.findOwnNotification(userID, notificationID); err != nil {
+		return err
+	}
 	return s.notificationRepo.MarkAsRead(notificationID)
 }
 
@@ -38,13 +85,75 @@ func (s *NotificationService) MarkAllAsRead(userID uuid.UUID) error {
 	return s.notificationRepo.MarkAllAsRead(userID)
 }
 
+// MarkBatchAsRead marks the given notifications of a user as read and returns how many changed
+func (s *NotificationService) MarkBatchAsRead(userID uuid.UUID, ids []uuid.UUID) (int64, error) {
+	if len(ids) == 0 || len(ids) > MaxBatchReadIDs {
+		return 0, ErrBatchReadSize
+	}
+	return s.notificationRepo.MarkBatchAsRead(userID, ids)
+}
+
 // GetUnreadCount gets the count of unread notifications
 func (s *NotificationService) GetUnreadCount(userID uuid.UUID) (int64, error) {
 	return s.notificationRepo.GetUnreadCount(userID)
 }
 
-// DeleteNotification deletes a notification
-func (s *NotificationService) DeleteNotification(notificationID uuid.UUID) error {
+// DeleteNotification deletes one of userID's notifications
+func (s *NotificationService) DeleteNotification(userID, notificationID uuid.UUID) error {
+	if _, err := s.findOwnNotification(userID, notificationID); err != nil {
+		return err
+	}
 	return s.notificationRepo.Delete(notificationID)
 }
 
+// findOwnNotification loads a notification of userID. Someone else's is reported as not found
+// so its existence isn't revealed.
+func (s *NotificationService) findOwnNotification(userID, notificationID uuid.UUID) (*models.Notification, error) {
+	notification, err := s.notificationRepo.FindByID(notificationID)
+	if err != nil {
+		if err.Error() == "notification not found" {
+			return nil, ErrNotificationNotFound
+		}
+		return nil, err
+	}
+	if notification.UserID != userID {
+		return nil, ErrNotificationNotFound
+	}
+	return notification, nil
+}
+
+// GetPreferences returns a user's preference for every notification type, defaults included
+func (s *NotificationService) GetPreferences(userID uuid.UUID) ([]models.NotificationPreference, error) {
+	stored, err := s.notificationRepo.GetPreferences(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	byType := make(map[models.NotificationType]models.NotificationPreference, len(stored))
+	for _, preference := range stored {
+		byType[preference.Type] = preference
+	}
+
+	preferences := make([]models.NotificationPreference, 0, len(models.NotificationTypes))
+	for _, ntype := range models.NotificationTypes {
+		preference, ok := byType[ntype]
+		if !ok {
+			preference = models.DefaultNotificationPreference(userID, ntype)
+		}
+		preferences = append(preferences, preference)
+	}
+	return preferences, nil
+}
+
+// UpdatePreference sets whether a user gets push notifications of a type, and whether they play a sound
+func (s *NotificationService) UpdatePreference(userID uuid.UUID, ntype models.NotificationType, enabled, soundEnabled bool) error {
+	if !ntype.IsValid() {
+		return ErrUnknownNotificationType
+	}
+	return s.notificationRepo.SavePreference(&models.NotificationPreference{
+		UserID:       userID,
+		Type:         ntype,
+		Enabled:      enabled,
+		SoundEnabled: soundEnabled,
+	})
+}
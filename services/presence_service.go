@@ -0,0 +1,48 @@
+package services
+
+import (
+	"mms-backend/repositories"
+
+	"github.com/google/uuid"
+)
+
+// PresenceService decides which users may see each other's online status
+type PresenceService struct {
+	groupRepo   *repositories.GroupRepository
+	messageRepo *repositories.MessageRepository
+}
+
+// NewPresenceService creates a new presence service
+func NewPresenceService(
+	groupRepo *repositories.GroupRepository,
+	messageRepo *repositories.MessageRepository,
+) *PresenceService {
+	return &PresenceService{
+		groupRepo:   groupRepo,
+		messageRepo: messageRepo,
+	}
+}
+
+// LoadPresenceSubscribers returns the users who share a group or a direct conversation with userID
+func (s *PresenceService) LoadPresenceSubscribers(userID uuid.UUID) ([]uuid.UUID, error) {
+	coMembers, err := s.groupRepo.GetCoMemberIDs(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	partners, err := s.messageRepo.GetConversationPartnerIDs(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[uuid.UUID]bool, len(coMembers)+len(partners))
+	subscribers := make([]uuid.UUID, 0, len(coMembers)+len(partners))
+	for _, id := range append(coMembers, partners...) {
+		if id == userID || seen[id] {
+			continue
+		}
+		seen[id] = true
+		subscribers = append(subscribers, id)
+	}
+	return subscribers, nil
+}
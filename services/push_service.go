@@ -3,31 +3,91 @@ package services
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
 
 	"mms-backend/config"
+	"mms-backend/jobs"
 	"mms-backend/models"
+	"mms-backend/repositories"
 	"mms-backend/utils"
+
+	"github.com/google/uuid"
+	"github.com/sideshow/apns2"
+	"github.com/sideshow/apns2/payload"
+	"github.com/sideshow/apns2/token"
 )
 
+// ErrStaleDeviceToken is returned when APNs rejects a device token for good; the token is cleared
+var ErrStaleDeviceToken = errors.New("device token is no longer valid")
+
+// deviceTokenStore clears device tokens that push services reject
+type deviceTokenStore interface {
+	UpdateDeviceToken(userID uuid.UUID, deviceToken, platform string) error
+}
+
+// notificationPreferenceStore looks up which notification types a user wants pushed
+type notificationPreferenceStore interface {
+	FindPreference(userID uuid.UUID, ntype models.NotificationType) (*models.NotificationPreference, error)
+}
+
 // PushService handles push notifications
 type PushService struct {
-	config *config.Config
+	config      *config.Config
+	tokens      deviceTokenStore
+	preferences notificationPreferenceStore // nil pushes every type with sound
+	apns        *apns2.Client               // nil when APNs is not configured
+	queue       *jobs.PushQueue             // nil sends queued notifications synchronously
 }
 
-// NewPushService creates a new push service
-func NewPushService(cfg *config.Config) *PushService {
-	return &PushService{
+// NewPushService creates a new push service. APNs is enabled when a key ID and key path are
+// configured; userRepo is used to clear device tokens APNs no longer accepts, and
+// notificationRepo to honour each user's notification preferences.
+func NewPushService(cfg *config.Config, userRepo *repositories.UserRepository, notificationRepo *repositories.NotificationRepository) *PushService {
+	s := &PushService{
 		config: cfg,
 	}
+	if userRepo != nil {
+		s.tokens = userRepo
+	}
+	if notificationRepo != nil {
+		s.preferences = notificationRepo
+	}
+
+	if cfg.Push.APNSKeyID != "" && cfg.Push.APNSKeyPath != "" {
+		client, err := newAPNSClient(cfg.Push)
+		if err != nil {
+			utils.Logger.Warn("APNs disabled", "error", err)
+		} else {
+			s.apns = client
+		}
+	}
+	return s
+}
+
+// newAPNSClient creates an APNs client authenticated with the .p8 signing key
+func newAPNSClient(cfg config.PushConfig) (*apns2.Client, error) {
+	authKey, err := token.AuthKeyFromFile(cfg.APNSKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load APNs key: %w", err)
+	}
+
+	client := apns2.NewTokenClient(&token.Token{
+		AuthKey: authKey,
+		KeyID:   cfg.APNSKeyID,
+		TeamID:  cfg.APNSTeamID,
+	})
+	if cfg.APNSProduction {
+		return client.Production(), nil
+	}
+	return client.Development(), nil
 }
 
 // FCMPayload represents Firebase Cloud Messaging payload
 type FCMPayload struct {
 	To           string                 `json:"to"`
-	Notification FCMNotification        `json:"notification"`
+	Notification *FCMNotification       `json:"notification,omitempty"` // nil for data-only messages
 	Data         map[string]interface{} `json:"data,omitempty"`
 	Priority     string                 `json:"priority"`
 }
@@ -36,93 +96,184 @@ type FCMPayload struct {
 type FCMNotification struct {
 	Title string `json:"title"`
 	Body  string `json:"body"`
-	Sound string `json:"sound"`
+	Sound string `json:"sound,omitempty"`
 }
 
-// SendMessageNotification sends a push notification for a new message
-func (s *PushService) SendMessageNotification(receiver *models.User, senderName, messagePreview string) error {
-	if receiver.DeviceToken == "" {
-		return fmt.Errorf("no device token for user")
+// preferenceFor returns the receiver's preference for a notification type, or the default one
+func (s *PushService) preferenceFor(receiver *models.User, ntype models.NotificationType) (models.NotificationPreference, error) {
+	if s.preferences != nil {
+		preference, err := s.preferences.FindPreference(receiver.ID, ntype)
+		if err != nil {
+			return models.NotificationPreference{}, err
+		}
+		if preference != nil {
+			return *preference, nil
+		}
 	}
+	return models.DefaultNotificationPreference(receiver.ID, ntype), nil
+}
 
-	title := utils.T(receiver.Language, "message_received", senderName)
-	body := messagePreview
+// SetQueue makes QueueMessageNotification and QueueGroupMessageNotification hand their
+// notifications to queue's workers. Without a queue they send synchronously.
+func (s *PushService) SetQueue(queue *jobs.PushQueue) {
+	s.queue = queue
+}
 
-	switch receiver.Platform {
-	case "android":
-		return s.sendFCM(receiver.DeviceToken, title, body, map[string]interface{}{
-			"type":        "message",
-			"sender_name": senderName,
-		})
-	case "ios":
-		return s.sendAPNS(receiver.DeviceToken, title, body, map[string]interface{}{
+// messagePushJob builds the push notification for a new message
+func messagePushJob(receiver *models.User, senderName, messagePreview string) jobs.PushJob {
+	return jobs.PushJob{
+		User:  receiver,
+		Type:  models.NotificationTypeMessage,
+		Title: utils.T(receiver.Language, "message_received", senderName),
+		Body:  messagePreview,
+		Data: map[string]interface{}{
 			"type":        "message",
 			"sender_name": senderName,
-		})
-	default:
-		// Try FCM as default
-		return s.sendFCM(receiver.DeviceToken, title, body, map[string]interface{}{
-			"type":        "message",
+		},
+	}
+}
+
+// groupMessagePushJob builds the push notification for a new group message
+func groupMessagePushJob(receiver *models.User, groupName, senderName, messagePreview string) jobs.PushJob {
+	return jobs.PushJob{
+		User:  receiver,
+		Type:  models.NotificationTypeGroupMessage,
+		Title: groupName,
+		Body:  fmt.Sprintf("%s: %s", senderName, messagePreview),
+		Data: map[string]interface{}{
+			"type":        "group_message",
+			"group_name":  groupName,
 			"sender_name": senderName,
-		})
+		},
 	}
 }
 
-// SendGroupMessageNotification sends a push notification for a new group message
+// SendMessageNotification sends a push notification for a new message, unless the receiver
+// turned message notifications off or is in their do-not-disturb hours
+func (s *PushService) SendMessageNotification(receiver *models.User, senderName, messagePreview string) error {
+	return s.SendPush(messagePushJob(receiver, senderName, messagePreview))
+}
+
+// SendGroupMessageNotification sends a push notification for a new group message, unless the
+// receiver turned group message notifications off or is in their do-not-disturb hours
 func (s *PushService) SendGroupMessageNotification(receiver *models.User, groupName, senderName, messagePreview string) error {
+	return s.SendPush(groupMessagePushJob(receiver, groupName, senderName, messagePreview))
+}
+
+// QueueMessageNotification is SendMessageNotification in the background
+func (s *PushService) QueueMessageNotification(receiver *models.User, senderName, messagePreview string) {
+	s.enqueue(messagePushJob(receiver, senderName, messagePreview))
+}
+
+// QueueGroupMessageNotification is SendGroupMessageNotification in the background
+func (s *PushService) QueueGroupMessageNotification(receiver *models.User, groupName, senderName, messagePreview string) {
+	s.enqueue(groupMessagePushJob(receiver, groupName, senderName, messagePreview))
+}
+
+// QueueSilentSync is SendSilentSync in the background. It does nothing unless silent pushes are enabled.
+func (s *PushService) QueueSilentSync(receiver *models.User, data map[string]interface{}) {
+	if !s.config.Push.SilentPush {
+		return
+	}
+	s.enqueue(jobs.PushJob{User: receiver, Data: data, Silent: true})
+}
+
+// enqueue hands job to the queue, or sends it right away when there is none
+func (s *PushService) enqueue(job jobs.PushJob) {
+	if s.queue == nil {
+		_ = s.SendPush(job)
+		return
+	}
+
+	// A worker may clear a stale device token on the user, so it gets its own copy
+	user := *job.User
+	job.User = &user
+	s.queue.Enqueue(job)
+}
+
+// SendPush sends a push notification to job.User's device, unless they turned job.Type
+// notifications off or are in their do-not-disturb hours
+func (s *PushService) SendPush(job jobs.PushJob) error {
+	if job.Silent {
+		return s.SendSilentSync(job.User, job.Data)
+	}
+
+	receiver := job.User
 	if receiver.DeviceToken == "" {
 		return fmt.Errorf("no device token for user")
 	}
 
-	title := groupName
-	body := fmt.Sprintf("%s: %s", senderName, messagePreview)
+	if utils.IsInDNDWindow(receiver) {
+		return nil
+	}
+
+	preference, err := s.preferenceFor(receiver, job.Type)
+	if err != nil {
+		return err
+	}
+	if !preference.Enabled {
+		return nil
+	}
 
-	switch receiver.Platform {
-	case "android":
-		return s.sendFCM(receiver.DeviceToken, title, body, map[string]interface{}{
-			"type":        "group_message",
-			"group_name":  groupName,
-			"sender_name": senderName,
-		})
-	case "ios":
-		return s.sendAPNS(receiver.DeviceToken, title, body, map[string]interface{}{
-			"type":        "group_message",
-			"group_name":  groupName,
-			"sender_name": senderName,
-		})
-	default:
-		return s.sendFCM(receiver.DeviceToken, title, body, map[string]interface{}{
-			"type":        "group_message",
-			"group_name":  groupName,
-			"sender_name": senderName,
-		})
+	if receiver.Platform == "ios" {
+		return s.sendAPNS(receiver, job.Title, job.Body, preference.SoundEnabled, job.Data)
 	}
+	// Android, and FCM as the default for other platforms
+	return s.sendFCM(receiver.DeviceToken, job.Title, job.Body, preference.SoundEnabled, job.Data)
 }
 
-// sendFCM sends a notification via Firebase Cloud Messaging
-func (s *PushService) sendFCM(deviceToken, title, body string, data map[string]interface{}) error {
-	if s.config.Push.FCMServerKey == "" {
-		log.Println("FCM server key not configured, skipping push notification")
+// SendSilentSync sends user's device a push that shows nothing but wakes the app so it can
+// fetch new data: content-available on iOS, a data-only message on Android. Since nothing is
+// displayed, do-not-disturb hours and notification preferences don't apply. It does nothing
+// unless silent pushes are enabled.
+func (s *PushService) SendSilentSync(user *models.User, payload map[string]interface{}) error {
+	if !s.config.Push.SilentPush {
 		return nil
 	}
+	if user.DeviceToken == "" {
+		return fmt.Errorf("no device token for user")
+	}
+
+	if user.Platform == "ios" {
+		return s.sendAPNSSilent(user, payload)
+	}
+	return s.sendFCMPayload(FCMPayload{
+		To:       user.DeviceToken,
+		Data:     payload,
+		Priority: "high", // Normal priority messages may be delayed while the device dozes
+	})
+}
 
+// sendFCM sends a notification via Firebase Cloud Messaging
+func (s *PushService) sendFCM(deviceToken, title, body string, sound bool, data map[string]interface{}) error {
 	payload := FCMPayload{
 		To: deviceToken,
-		Notification: FCMNotification{
+		Notification: &FCMNotification{
 			Title: title,
 			Body:  body,
-			Sound: "default",
 		},
 		Data:     data,
 		Priority: "high",
 	}
+	if sound {
+		payload.Notification.Sound = "default"
+	}
+	return s.sendFCMPayload(payload)
+}
+
+// sendFCMPayload posts a message to Firebase Cloud Messaging
+func (s *PushService) sendFCMPayload(payload FCMPayload) error {
+	if s.config.Push.FCMServerKey == "" {
+		utils.Logger.Debug("FCM server key not configured, skipping push notification")
+		return nil
+	}
 
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
 		return err
 	}
 
-	req, err := http.NewRequest("POST", "https://fcm.googleapis.com/fcm/send", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest("POST", s.config.Push.FCMEndpoint, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return err
 	}
@@ -138,27 +289,85 @@ func (s *PushService) sendFCM(deviceToken, title, body string, data map[string]i
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		log.Printf("FCM request failed with status: %d", resp.StatusCode)
+		utils.Logger.Warn("FCM request failed", "status", resp.StatusCode)
 		return fmt.Errorf("FCM request failed with status: %d", resp.StatusCode)
 	}
 
-	log.Println("FCM notification sent successfully")
+	utils.Logger.Debug("FCM notification sent")
 	return nil
 }
 
 // sendAPNS sends a notification via Apple Push Notification Service
-func (s *PushService) sendAPNS(deviceToken, title, body string, data map[string]interface{}) error {
-	// Note: This is a simplified implementation
-	// In production, you should use a proper APNs library like github.com/sideshow/apns2
-	if s.config.Push.APNSKeyID == "" {
-		log.Println("APNs key not configured, skipping push notification")
+func (s *PushService) sendAPNS(receiver *models.User, title, body string, sound bool, data map[string]interface{}) error {
+	if s.apns == nil {
+		utils.Logger.Debug("APNs key not configured, skipping push notification", "user_id", receiver.ID)
 		return nil
 	}
 
-	// TODO: Implement proper APNs integration with JWT authentication
-	// For now, we'll just log that we would send an APNs notification
-	log.Printf("Would send APNs notification to device: %s, title: %s, body: %s", deviceToken, title, body)
-	
-	return nil
+	notificationPayload := payload.NewPayload().
+		AlertTitle(title).
+		AlertBody(body)
+	if sound {
+		notificationPayload.Sound("default")
+	}
+	for key, value := range data {
+		notificationPayload.Custom(key, value)
+	}
+
+	return s.pushAPNS(receiver, &apns2.Notification{
+		DeviceToken: receiver.DeviceToken,
+		Topic:       s.config.Push.APNSBundleID,
+		Payload:     notificationPayload,
+	})
 }
 
+// sendAPNSSilent sends a background push via Apple Push Notification Service. APNs requires
+// the background push type and low priority for pushes without an alert.
+func (s *PushService) sendAPNSSilent(receiver *models.User, data map[string]interface{}) error {
+	if s.apns == nil {
+		utils.Logger.Debug("APNs key not configured, skipping silent push", "user_id", receiver.ID)
+		return nil
+	}
+
+	notificationPayload := payload.NewPayload().ContentAvailable()
+	for key, value := range data {
+		notificationPayload.Custom(key, value)
+	}
+
+	return s.pushAPNS(receiver, &apns2.Notification{
+		DeviceToken: receiver.DeviceToken,
+		Topic:       s.config.Push.APNSBundleID,
+		PushType:    apns2.PushTypeBackground,
+		Priority:    apns2.PriorityLow,
+		Payload:     notificationPayload,
+	})
+}
+
+// pushAPNS sends notification to receiver's device, clearing the device token if APNs rejects it for good
+func (s *PushService) pushAPNS(receiver *models.User, notification *apns2.Notification) error {
+	resp, err := s.apns.Push(notification)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case resp.Sent():
+		utils.Logger.Debug("APNs notification sent", "user_id", receiver.ID)
+		return nil
+
+	// The app was uninstalled, or the token was never valid: stop sending to it
+	case resp.StatusCode == http.StatusGone,
+		resp.StatusCode == http.StatusBadRequest && resp.Reason == apns2.ReasonBadDeviceToken:
+		utils.Logger.Info("APNs rejected device token, clearing it", "user_id", receiver.ID, "status", resp.StatusCode, "reason", resp.Reason)
+		if s.tokens != nil {
+			if err := s.tokens.UpdateDeviceToken(receiver.ID, "", ""); err != nil {
+				return err
+			}
+		}
+		receiver.DeviceToken = ""
+		return ErrStaleDeviceToken
+	}
+
+	utils.Logger.Warn("APNs request failed", "user_id", receiver.ID, "status", resp.StatusCode, "reason", resp.Reason)
+	return fmt.Errorf("APNs request failed with status: %d (%s)", resp.StatusCode, resp.Reason)
+}
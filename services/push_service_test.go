@@ -0,0 +1,337 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"mms-backend/config"
+	"mms-backend/jobs"
+	"mms-backend/models"
+
+	"github.com/google/uuid"
+	"github.com/sideshow/apns2"
+)
+
+// fakeTokenStore records the device tokens PushService clears
+type fakeTokenStore struct {
+	mu      sync.Mutex
+	cleared []uuid.UUID
+}
+
+func (f *fakeTokenStore) UpdateDeviceToken(userID uuid.UUID, deviceToken, platform string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if deviceToken == "" {
+		f.cleared = append(f.cleared, userID)
+	}
+	return nil
+}
+
+// writeAPNSKey writes a freshly generated .p8 signing key and returns its path
+func writeAPNSKey(t *testing.T) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "AuthKey.p8")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewPushServiceAPNS(t *testing.T) {
+	keyPath := writeAPNSKey(t)
+
+	tests := []struct {
+		name string
+		push config.PushConfig
+		host string // Empty when APNs stays disabled
+	}{
+		{"sandbox", config.PushConfig{APNSKeyID: "KEY", APNSTeamID: "TEAM", APNSKeyPath: keyPath}, apns2.HostDevelopment},
+		{"production", config.PushConfig{APNSKeyID: "KEY", APNSTeamID: "TEAM", APNSKeyPath: keyPath, APNSProduction: true}, apns2.HostProduction},
+		{"not configured", config.PushConfig{}, ""},
+		{"missing key file", config.PushConfig{APNSKeyID: "KEY", APNSKeyPath: filepath.Join(t.TempDir(), "missing.p8")}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewPushService(&config.Config{Push: tt.push}, nil, nil)
+			if tt.host == "" {
+				if s.apns != nil {
+					t.Fatal("expected APNs to be disabled")
+				}
+				return
+			}
+			if s.apns == nil || s.apns.Host != tt.host {
+				t.Fatalf("expected APNs client for %s, got %+v", tt.host, s.apns)
+			}
+		})
+	}
+}
+
+// newMockAPNS returns a PushService talking to a fake APNs server that answers with status and reason
+func newMockAPNS(t *testing.T, status int, reason string) (*PushService, *fakeTokenStore, *http.Request, *[]byte) {
+	t.Helper()
+	var (
+		received http.Request
+		body     []byte
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = *r
+		body, _ = io.ReadAll(r.Body)
+
+		w.Header().Set("apns-id", uuid.NewString())
+		w.WriteHeader(status)
+		if reason != "" {
+			_ = json.NewEncoder(w).Encode(map[string]string{"reason": reason})
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	store := &fakeTokenStore{}
+	s := &PushService{
+		config: &config.Config{Push: config.PushConfig{APNSBundleID: "com.example.mms"}},
+		tokens: store,
+		apns:   &apns2.Client{Host: server.URL, HTTPClient: server.Client()},
+	}
+	return s, store, &received, &body
+}
+
+func TestSendAPNS(t *testing.T) {
+	t.Run("Sent", func(t *testing.T) {
+		s, store, req, body := newMockAPNS(t, http.StatusOK, "")
+		receiver := &models.User{ID: uuid.New(), DeviceToken: "abc123", Platform: "ios"}
+
+		if err := s.SendMessageNotification(receiver, "alice", "hello"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if req.URL.Path != "/3/device/abc123" {
+			t.Errorf("unexpected path %s", req.URL.Path)
+		}
+		if topic := req.Header.Get("apns-topic"); topic != "com.example.mms" {
+			t.Errorf("unexpected apns-topic %q", topic)
+		}
+
+		var sent struct {
+			APS struct {
+				Alert struct {
+					Body string `json:"body"`
+				} `json:"alert"`
+			} `json:"aps"`
+			Type       string `json:"type"`
+			SenderName string `json:"sender_name"`
+		}
+		if err := json.Unmarshal(*body, &sent); err != nil {
+			t.Fatalf("invalid payload %s: %v", *body, err)
+		}
+		if sent.APS.Alert.Body != "hello" || sent.Type != "message" || sent.SenderName != "alice" {
+			t.Errorf("unexpected payload %s", *body)
+		}
+		if len(store.cleared) != 0 {
+			t.Error("token must not be cleared after a successful push")
+		}
+	})
+
+	staleTests := []struct {
+		name   string
+		status int
+		reason string
+	}{
+		{"Unregistered", http.StatusGone, apns2.ReasonUnregistered},
+		{"BadDeviceToken", http.StatusBadRequest, apns2.ReasonBadDeviceToken},
+	}
+	for _, tt := range staleTests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, store, _, _ := newMockAPNS(t, tt.status, tt.reason)
+			receiver := &models.User{ID: uuid.New(), DeviceToken: "abc123", Platform: "ios"}
+
+			err := s.SendGroupMessageNotification(receiver, "team", "alice", "hello")
+			if !errors.Is(err, ErrStaleDeviceToken) {
+				t.Fatalf("expected ErrStaleDeviceToken, got %v", err)
+			}
+			if len(store.cleared) != 1 || store.cleared[0] != receiver.ID {
+				t.Errorf("expected the token of %s to be cleared, got %v", receiver.ID, store.cleared)
+			}
+			if receiver.DeviceToken != "" {
+				t.Error("expected the in-memory token to be cleared")
+			}
+		})
+	}
+
+	t.Run("OtherErrorsKeepToken", func(t *testing.T) {
+		s, store, _, _ := newMockAPNS(t, http.StatusBadRequest, apns2.ReasonBadTopic)
+		receiver := &models.User{ID: uuid.New(), DeviceToken: "abc123", Platform: "ios"}
+
+		err := s.SendMessageNotification(receiver, "alice", "hello")
+		if err == nil || errors.Is(err, ErrStaleDeviceToken) {
+			t.Fatalf("expected a plain error, got %v", err)
+		}
+		if len(store.cleared) != 0 {
+			t.Error("token must be kept for errors unrelated to it")
+		}
+	})
+}
+
+// fakePreferenceStore serves fixed notification preferences
+type fakePreferenceStore map[models.NotificationType]models.NotificationPreference
+
+func (f fakePreferenceStore) FindPreference(userID uuid.UUID, ntype models.NotificationType) (*models.NotificationPreference, error) {
+	preference, ok := f[ntype]
+	if !ok {
+		return nil, nil
+	}
+	return &preference, nil
+}
+
+func TestSendHonoursPreferences(t *testing.T) {
+	t.Run("Disabled", func(t *testing.T) {
+		s, _, req, _ := newMockAPNS(t, http.StatusOK, "")
+		s.preferences = fakePreferenceStore{
+			models.NotificationTypeMessage: {Type: models.NotificationTypeMessage, Enabled: false, SoundEnabled: true},
+		}
+		receiver := &models.User{ID: uuid.New(), DeviceToken: "abc123", Platform: "ios"}
+
+		if err := s.SendMessageNotification(receiver, "alice", "hello"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if req.URL != nil {
+			t.Fatal("expected no push for a disabled notification type")
+		}
+
+		// Other types are unaffected
+		if err := s.SendGroupMessageNotification(receiver, "team", "alice", "hello"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if req.URL == nil {
+			t.Fatal("expected a push for group messages")
+		}
+	})
+
+	soundTests := []struct {
+		name  string
+		sound bool
+	}{
+		{"SoundOn", true},
+		{"SoundOff", false},
+	}
+	for _, tt := range soundTests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, _, body := newMockAPNS(t, http.StatusOK, "")
+			s.preferences = fakePreferenceStore{
+				models.NotificationTypeMessage: {Type: models.NotificationTypeMessage, Enabled: true, SoundEnabled: tt.sound},
+			}
+			receiver := &models.User{ID: uuid.New(), DeviceToken: "abc123", Platform: "ios"}
+
+			if err := s.SendMessageNotification(receiver, "alice", "hello"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var sent struct {
+				APS struct {
+					Sound string `json:"sound"`
+				} `json:"aps"`
+			}
+			if err := json.Unmarshal(*body, &sent); err != nil {
+				t.Fatalf("invalid payload %s: %v", *body, err)
+			}
+			if hasSound := sent.APS.Sound != ""; hasSound != tt.sound {
+				t.Errorf("expected sound=%v, got payload %s", tt.sound, *body)
+			}
+		})
+	}
+}
+
+func TestSendSilentSync(t *testing.T) {
+	data := map[string]interface{}{"type": "sync", "message_id": "m1"}
+
+	t.Run("APNs", func(t *testing.T) {
+		s, _, req, body := newMockAPNS(t, http.StatusOK, "")
+		s.config.Push.SilentPush = true
+		receiver := &models.User{ID: uuid.New(), DeviceToken: "abc123", Platform: "ios"}
+
+		if err := s.SendSilentSync(receiver, data); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if pushType := req.Header.Get("apns-push-type"); pushType != "background" {
+			t.Errorf("unexpected apns-push-type %q", pushType)
+		}
+		if priority := req.Header.Get("apns-priority"); priority != "5" {
+			t.Errorf("unexpected apns-priority %q", priority)
+		}
+
+		var sent struct {
+			APS       map[string]interface{} `json:"aps"`
+			Type      string                 `json:"type"`
+			MessageID string                 `json:"message_id"`
+		}
+		if err := json.Unmarshal(*body, &sent); err != nil {
+			t.Fatalf("invalid payload %s: %v", *body, err)
+		}
+		if sent.APS["content-available"] != float64(1) || sent.Type != "sync" || sent.MessageID != "m1" {
+			t.Errorf("unexpected payload %s", *body)
+		}
+		if _, ok := sent.APS["alert"]; ok {
+			t.Errorf("silent push must not have an alert: %s", *body)
+		}
+	})
+
+	t.Run("FCMDataOnly", func(t *testing.T) {
+		var body []byte
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ = io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusOK)
+		}))
+		t.Cleanup(server.Close)
+
+		s := &PushService{config: &config.Config{Push: config.PushConfig{
+			FCMServerKey: "key",
+			FCMEndpoint:  server.URL,
+			SilentPush:   true,
+		}}}
+		receiver := &models.User{ID: uuid.New(), DeviceToken: "android-token", Platform: "android"}
+
+		if err := s.SendPush(jobs.PushJob{User: receiver, Data: data, Silent: true}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var sent map[string]interface{}
+		if err := json.Unmarshal(body, &sent); err != nil {
+			t.Fatalf("invalid payload %s: %v", body, err)
+		}
+		if _, ok := sent["notification"]; ok {
+			t.Errorf("data-only message must not have a notification: %s", body)
+		}
+		if sent["to"] != "android-token" || sent["data"].(map[string]interface{})["type"] != "sync" {
+			t.Errorf("unexpected payload %s", body)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		s, _, req, _ := newMockAPNS(t, http.StatusOK, "")
+		receiver := &models.User{ID: uuid.New(), DeviceToken: "abc123", Platform: "ios"}
+
+		if err := s.SendSilentSync(receiver, data); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if req.URL != nil {
+			t.Fatal("expected no push while silent pushes are disabled")
+		}
+	})
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"mms-backend/config"
+)
+
+// ErrS3NotImplemented is returned by the S3 storage driver until it is implemented
+var ErrS3NotImplemented = errors.New("s3 storage is not implemented yet")
+
+// StorageService stores uploaded files and returns the URL they are served from
+type StorageService interface {
+	Upload(filename string, data io.Reader, mimeType string) (url string, err error)
+}
+
+// NewStorageService returns the storage driver selected by cfg.Driver
+func NewStorageService(cfg config.StorageConfig) (StorageService, error) {
+	switch cfg.Driver {
+	case "", "local":
+		return NewLocalStorage(cfg.LocalPath, cfg.PublicURL), nil
+	case "s3":
+		return &s3Storage{bucket: cfg.S3Bucket, region: cfg.S3Region}, nil
+	}
+	return nil, fmt.Errorf("unknown storage driver %q", cfg.Driver)
+}
+
+// LocalStorage writes files to a directory served by the API under a URL prefix
+type LocalStorage struct {
+	dir     string
+	baseURL string
+}
+
+// NewLocalStorage creates a local disk storage rooted at dir, with files served under baseURL
+func NewLocalStorage(dir, baseURL string) *LocalStorage {
+	return &LocalStorage{dir: dir, baseURL: strings.TrimRight(baseURL, "/")}
+}
+
+// Dir returns the directory files are written to
+func (s *LocalStorage) Dir() string {
+	return s.dir
+}
+
+// Upload writes data to dir/filename, refusing to overwrite an existing file
+func (s *LocalStorage) Upload(filename string, data io.Reader, mimeType string) (string, error) {
+	// Filenames are generated by the server, but never let one escape the storage directory
+	if filename == "" || filename != filepath.Base(filename) || strings.HasPrefix(filename, ".") {
+		return "", errors.New("invalid filename")
+	}
+
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(s.dir, filename)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := io.Copy(file, data); err != nil {
+		file.Close()
+		os.Remove(path)
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+
+	return s.baseURL + "/" + filename, nil
+}
+
+// s3Storage is a placeholder for an S3 bucket driver, selected with STORAGE_DRIVER=s3
+type s3Storage struct {
+	bucket string
+	region string
+}
+
+func (s *s3Storage) Upload(filename string, data io.Reader, mimeType string) (string, error) {
+	return "", ErrS3NotImplemented
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"time"
+
+	"mms-backend/repositories"
+	"mms-backend/websocket"
+
+	"github.com/google/uuid"
+)
+
+// UnreadCounts is the number of unread direct and group messages for a user
+type UnreadCounts struct {
+	Direct int64 `json:"direct"`
+	Groups int64 `json:"groups"`
+	Total  int64 `json:"total"`
+}
+
+// UnreadService computes unread badges and pushes them to connected clients
+type UnreadService struct {
+	messageRepo      *repositories.MessageRepository
+	groupMessageRepo *repositories.GroupMessageRepository
+	wsHub            *websocket.Hub
+}
+
+// NewUnreadService creates a new unread service
+func NewUnreadService(
+	messageRepo *repositories.MessageRepository,
+	groupMessageRepo *repositories.GroupMessageRepository,
+	wsHub *websocket.Hub,
+) *UnreadService {
+	return &UnreadService{
+		messageRepo:      messageRepo,
+		groupMessageRepo: groupMessageRepo,
+		wsHub:            wsHub,
+	}
+}
+
+// GetUnreadCounts returns the user's unread direct and group message counts
+func (s *UnreadService) GetUnreadCounts(userID uuid.UUID) (*UnreadCounts, error) {
+	direct, err := s.messageRepo.GetUnreadCount(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := s.groupMessageRepo.GetAllUnreadCount(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UnreadCounts{
+		Direct: direct,
+		Groups: groups,
+		Total:  direct + groups,
+	}, nil
+}
+
+// PushUnreadCounts sends an unread_count_updated event to the user if they are connected
+func (s *UnreadService) PushUnreadCounts(userID uuid.UUID) {
+	if s == nil || s.wsHub == nil || !s.wsHub.IsUserOnline(userID) {
+		return
+	}
+
+	counts, err := s.GetUnreadCounts(userID)
+	if err != nil {
+		return
+	}
+
+	_ = s.wsHub.SendToUser(userID, &websocket.Message{
+		Type: "unread_count_updated",
+		Data: map[string]interface{}{
+			"direct": counts.Direct,
+			"groups": counts.Groups,
+		},
+		Timestamp: time.Now(),
+	})
+}
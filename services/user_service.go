@@ -0,0 +1,431 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/disintegration/imaging"
+	"github.com/google/uuid"
+	_ "golang.org/x/image/webp" // Registers the WebP decoder used by image.Decode
+	"gorm.io/gorm"
+	"mms-backend/models"
+	"mms-backend/repositories"
+	"mms-backend/utils"
+)
+
+// AccountPurgeDelay is how long a deleted account and its messages are kept before being
+// permanently removed
+const AccountPurgeDelay = 30 * 24 * time.Hour
+
+// maxAvatarURLLength matches the size of the users.avatar column
+const maxAvatarURLLength = 500
+
+// MaxAvatarSize caps the size of an uploaded avatar image
+const MaxAvatarSize = 5 << 20 // 5 MB
+
+// Avatar images are cropped to squares of these sizes
+const (
+	avatarSize      = 256
+	avatarThumbSize = 64
+)
+
+// maxAvatarPixels rejects images whose decoded size would be unreasonable for a 5 MB upload
+const maxAvatarPixels = 50_000_000
+
+// avatarFormats maps the accepted image types, as sniffed from the file's magic bytes,
+// to the format the resized versions are encoded in. WebP can't be encoded, so it becomes PNG.
+var avatarFormats = map[string]imaging.Format{
+	"image/jpeg": imaging.JPEG,
+	"image/png":  imaging.PNG,
+	"image/webp": imaging.PNG,
+}
+
+var (
+	// ErrUsernameTaken is returned when another user already has the requested username
+	ErrUsernameTaken = errors.New("username already taken")
+
+	// ErrUsernameChangeCooldown is returned when the username was changed less than models.UsernameChangeCooldown ago
+	ErrUsernameChangeCooldown = errors.New("username can only be changed once every 30 days")
+
+	// ErrUnsupportedLanguage is returned when a language has no translations
+	ErrUnsupportedLanguage = errors.New("unsupported language")
+
+	// ErrAvatarURLTooLong is returned when an avatar URL does not fit the avatar column
+	ErrAvatarURLTooLong = errors.New("avatar must be at most 500 characters")
+
+	// ErrAvatarTooLarge is returned for avatar uploads over MaxAvatarSize
+	ErrAvatarTooLarge = errors.New("avatar exceeds the 5 MB limit")
+
+	// ErrUnsupportedAvatar is returned for avatar uploads that are not a JPEG, PNG or WebP image
+	ErrUnsupportedAvatar = errors.New("avatar must be a JPEG, PNG or WebP image")
+
+	// ErrCannotBlockSelf is returned when a user tries to block themselves
+	ErrCannotBlockSelf = errors.New("you cannot block yourself")
+
+	// ErrBlockNotFound is returned when unblocking a user who is not blocked
+	ErrBlockNotFound = errors.New("user is not blocked")
+
+	// ErrInvalidDNDHour is returned for do-not-disturb hours outside 0-23
+	ErrInvalidDNDHour = errors.New("do-not-disturb hours must be between 0 and 23")
+
+	// ErrInvalidTimezone is returned for time zones that are not IANA names
+	ErrInvalidTimezone = errors.New("unknown time zone")
+)
+
+// AvatarResponse holds the URLs of an uploaded avatar
+type AvatarResponse struct {
+	AvatarURL    string `json:"avatar_url"`    // 256x256
+	ThumbnailURL string `json:"thumbnail_url"` // 64x64
+}
+
+// UpdateProfileRequest represents a profile update; omitted fields are left unchanged
+type UpdateProfileRequest struct {
+	Username *string `json:"username"`
+	Avatar   *string `json:"avatar"`
+	Language *string `json:"language"`
+	Bio      *string `json:"bio"`
+}
+
+// UpdateDNDRequest represents a do-not-disturb schedule update; omitted fields are left unchanged
+type UpdateDNDRequest struct {
+	Enabled   *bool   `json:"enabled"`
+	StartHour *int    `json:"start_hour"`
+	EndHour   *int    `json:"end_hour"`
+	Timezone  *string `json:"timezone"`
+}
+
+// DNDSettings is a user's do-not-disturb schedule
+type DNDSettings struct {
+	Enabled   bool   `json:"enabled"`
+	StartHour int    `json:"start_hour"`
+	EndHour   int    `json:"end_hour"`
+	Timezone  string `json:"timezone"`
+}
+
+// UserService handles user business logic
+type UserService struct {
+	userRepo *repositories.UserRepository
+	storage  StorageService
+}
+
+// NewUserService creates a new user service storing uploaded avatars in storage
+func NewUserService(userRepo *repositories.UserRepository, storage StorageService) *UserService {
+	return &UserService{
+		userRepo: userRepo,
+		storage:  storage,
+	}
+}
+
+// GetUser returns a user by ID
+func (s *UserService) GetUser(userID uuid.UUID) (*models.User, error) {
+	return s.userRepo.FindByID(userID)
+}
+
+// ListUsers returns a page of the users visible to viewerID and their total number.
+// Users who blocked viewerID are left out.
+func (s *UserService) ListUsers(viewerID uuid.UUID, limit, offset int) ([]models.User, int64, error) {
+	users, err := s.userRepo.List(viewerID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := s.userRepo.Count(viewerID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+// SearchUsers returns a page of users matching query and the total number of matches.
+// Users who blocked viewerID are left out.
+func (s *UserService) SearchUsers(viewerID uuid.UUID, query string, limit, offset int) ([]models.User, int64, error) {
+	users, err := s.userRepo.Search(viewerID, query, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := s.userRepo.SearchCount(viewerID, query)
+	if err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+// LookupByPhone finds the users owning a contact list's phone numbers, keyed by the number as given
+func (s *UserService) LookupByPhone(phones []string) (map[string]models.User, error) {
+	found := make(map[string]models.User, len(phones))
+	for _, phone := range phones {
+		// Complete numbers are matched in E.164; partial ones fall back to a prefix match
+		query := phone
+		if normalized, err := utils.NormalizePhone(phone); err == nil {
+			query = normalized
+		}
+		users, err := s.userRepo.FindByPhonePrefix(query, 1)
+		if err != nil {
+			return nil, err
+		}
+		if len(users) > 0 {
+			found[phone] = users[0]
+		}
+	}
+	return found, nil
+}
+
+// UpdateProfile validates and applies a profile update, returning the updated user
+func (s *UserService) UpdateProfile(userID uuid.UUID, req UpdateProfileRequest) (*models.User, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]interface{})
+
+	if req.Username != nil {
+		username := utils.SanitizeString(*req.Username)
+		if err := utils.ValidateUsername(username); err != nil {
+			return nil, err
+		}
+		// Changing only the case keeps the same username, so it is not held to the cooldown
+		if !strings.EqualFold(username, user.Username) {
+			if _, err := s.userRepo.WithPrimary().FindByUsername(username); err == nil {
+				return nil, ErrUsernameTaken
+			}
+			if at := user.UsernameChangeableAt(); at != nil && time.Now().Before(*at) {
+				return nil, ErrUsernameChangeCooldown
+			}
+
+			now := time.Now()
+			fields["username_changed_at"] = now
+			user.UsernameChangedAt = &now
+		}
+		fields["username"] = username
+		user.Username = username
+	}
+
+	if req.Avatar != nil {
+		avatar := strings.TrimSpace(*req.Avatar)
+		if utf8.RuneCountInString(avatar) > maxAvatarURLLength {
+			return nil, ErrAvatarURLTooLong
+		}
+		fields["avatar"] = avatar
+		user.Avatar = avatar
+
+		// The thumbnail of a previously uploaded avatar no longer matches
+		fields["avatar_thumb"] = ""
+		user.AvatarThumb = ""
+	}
+
+	if req.Language != nil {
+		language := strings.ToLower(strings.TrimSpace(*req.Language))
+		if !isSupportedLanguage(language) {
+			return nil, ErrUnsupportedLanguage
+		}
+		fields["language"] = language
+		user.Language = language
+	}
+
+	if req.Bio != nil {
+		bio := utils.SanitizeString(*req.Bio)
+		if err := utils.ValidateBio(bio); err != nil {
+			return nil, err
+		}
+		fields["bio"] = bio
+		user.Bio = bio
+	}
+
+	if err := s.userRepo.UpdateProfile(userID, fields); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// UpdateDND validates and applies a do-not-disturb schedule update, returning the new schedule
+func (s *UserService) UpdateDND(userID uuid.UUID, req UpdateDNDRequest) (*DNDSettings, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]interface{})
+
+	if req.Enabled != nil {
+		fields["dnd_enabled"] = *req.Enabled
+		user.DNDEnabled = *req.Enabled
+	}
+
+	for _, hour := range []*int{req.StartHour, req.EndHour} {
+		if hour != nil && (*hour < 0 || *hour > 23) {
+			return nil, ErrInvalidDNDHour
+		}
+	}
+	if req.StartHour != nil {
+		fields["dnd_start_hour"] = *req.StartHour
+		user.DNDStartHour = *req.StartHour
+	}
+	if req.EndHour != nil {
+		fields["dnd_end_hour"] = *req.EndHour
+		user.DNDEndHour = *req.EndHour
+	}
+
+	if req.Timezone != nil {
+		timezone := strings.TrimSpace(*req.Timezone)
+		// time.LoadLocation maps "" to UTC; require a name
+		if timezone == "" {
+			return nil, ErrInvalidTimezone
+		}
+		if _, err := time.LoadLocation(timezone); err != nil {
+			return nil, ErrInvalidTimezone
+		}
+		fields["dnd_timezone"] = timezone
+		user.DNDTimezone = timezone
+	}
+
+	if err := s.userRepo.UpdateProfile(userID, fields); err != nil {
+		return nil, err
+	}
+	return &DNDSettings{
+		Enabled:   user.DNDEnabled,
+		StartHour: user.DNDStartHour,
+		EndHour:   user.DNDEndHour,
+		Timezone:  user.DNDTimezone,
+	}, nil
+}
+
+// BlockUser blocks blockedID for blockerID. Blocking an already blocked user is a no-op.
+func (s *UserService) BlockUser(blockerID, blockedID uuid.UUID) error {
+	if blockerID == blockedID {
+		return ErrCannotBlockSelf
+	}
+	if _, err := s.userRepo.FindByID(blockedID); err != nil {
+		return err
+	}
+	return s.userRepo.Block(blockerID, blockedID)
+}
+
+// UnblockUser lifts a block placed by blockerID
+func (s *UserService) UnblockUser(blockerID, blockedID uuid.UUID) error {
+	err := s.userRepo.Unblock(blockerID, blockedID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrBlockNotFound
+	}
+	return err
+}
+
+// DeleteAccount soft-deletes a user's account: it stops appearing in lookups and can no longer
+// log in, pushes stop, and its messages show "[deleted user]" as the sender until the account
+// is purged AccountPurgeDelay later
+func (s *UserService) DeleteAccount(userID uuid.UUID) error {
+	return s.userRepo.Delete(userID)
+}
+
+// PurgeDeletedAccounts permanently removes the accounts deleted more than AccountPurgeDelay ago
+func (s *UserService) PurgeDeletedAccounts() (int64, error) {
+	return s.userRepo.PurgeDeleted(time.Now().Add(-AccountPurgeDelay))
+}
+
+// RunAccountPurge purges expired deleted accounts every interval until stop is closed
+func (s *UserService) RunAccountPurge(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			purged, err := s.PurgeDeletedAccounts()
+			if err != nil {
+				utils.Logger.Error("Failed to purge deleted accounts", "error", err)
+			} else if purged > 0 {
+				utils.Logger.Info("Purged deleted accounts", "count", purged)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// UploadAvatar resizes an uploaded image to the avatar and thumbnail sizes, stores both and
+// makes them the user's avatar. The image type is sniffed from the content, never taken from the client.
+func (s *UserService) UploadAvatar(userID uuid.UUID, data io.Reader, size int64) (*AvatarResponse, error) {
+	if size > MaxAvatarSize {
+		return nil, ErrAvatarTooLarge
+	}
+
+	// Read one byte past the limit so an understated size is still caught
+	content, err := io.ReadAll(io.LimitReader(data, MaxAvatarSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > MaxAvatarSize {
+		return nil, ErrAvatarTooLarge
+	}
+
+	mimeType, _, _ := strings.Cut(http.DetectContentType(content), ";")
+	format, ok := avatarFormats[mimeType]
+	if !ok {
+		return nil, ErrUnsupportedAvatar
+	}
+
+	// Check the dimensions before decoding so a small file can't expand into a huge bitmap
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil || cfg.Width == 0 || cfg.Height == 0 || cfg.Width*cfg.Height > maxAvatarPixels {
+		return nil, ErrUnsupportedAvatar
+	}
+
+	img, err := imaging.Decode(bytes.NewReader(content), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, ErrUnsupportedAvatar
+	}
+
+	name := uuid.New().String()
+	avatarURL, err := s.storeAvatar(img, avatarSize, name, format)
+	if err != nil {
+		return nil, err
+	}
+	thumbURL, err := s.storeAvatar(img, avatarThumbSize, name+"-thumb", format)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepo.UpdateProfile(userID, map[string]interface{}{
+		"avatar":       avatarURL,
+		"avatar_thumb": thumbURL,
+	}); err != nil {
+		return nil, err
+	}
+
+	return &AvatarResponse{
+		AvatarURL:    avatarURL,
+		ThumbnailURL: thumbURL,
+	}, nil
+}
+
+// storeAvatar crops img to a size x size square and stores it under name
+func (s *UserService) storeAvatar(img image.Image, size int, name string, format imaging.Format) (string, error) {
+	var buf bytes.Buffer
+	resized := imaging.Fill(img, size, size, imaging.Center, imaging.Lanczos)
+	if err := imaging.Encode(&buf, resized, format); err != nil {
+		return "", err
+	}
+
+	ext, mimeType := ".png", "image/png"
+	if format == imaging.JPEG {
+		ext, mimeType = ".jpg", "image/jpeg"
+	}
+	return s.storage.Upload(name+ext, &buf, mimeType)
+}
+
+// isSupportedLanguage reports whether there are translations for a language code
+func isSupportedLanguage(language string) bool {
+	for _, supported := range utils.GetI18n().SupportedLanguages() {
+		if language == supported {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+package tests
+
+// Benchmarks for the message hot path.
+//
+// They share TestMain with the integration tests, so running them requires a
+// live PostgreSQL instance reachable with the settings from setupTestEnvironment
+// (ENCRYPTION_KEY is set there as well). Run them with:
+//
+//	make bench
+//
+// Target: SendMessage should sustain at least 500 ops/s (~2ms/op) on a
+// laptop-grade CPU.
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"mms-backend/models"
+	"mms-backend/services"
+	"mms-backend/utils"
+	"mms-backend/websocket"
+)
+
+const benchMessageContent = "Benchmark message content with a realistic length for a chat app."
+
+// seedBenchUser creates a throwaway user for benchmarks
+func seedBenchUser(b *testing.B, prefix string) *models.User {
+	b.Helper()
+
+	suffix := uuid.New().String()[:8]
+	user := &models.User{
+		Username: fmt.Sprintf("%s_%s", prefix, suffix),
+		Email:    fmt.Sprintf("%s_%s@bench.example.com", prefix, suffix),
+		Password: "not-a-real-hash",
+		Language: "en",
+	}
+	if err := testUserRepo.Create(user); err != nil {
+		b.Fatalf("failed to seed user: %v", err)
+	}
+	return user
+}
+
+func BenchmarkSendMessage(b *testing.B) {
+	sender := seedBenchUser(b, "bench_sender")
+	receiver := seedBenchUser(b, "bench_receiver")
+
+	req := services.SendMessageRequest{
+		ReceiverID: receiver.ID,
+		Content:    benchMessageContent,
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := testMessageService.SendMessage(context.Background(), sender.ID, req); err != nil {
+			b.Fatalf("SendMessage failed: %v", err)
+		}
+	}
+	b.StopTimer()
+
+	b.ReportMetric(1, "messages/op")
+	b.ReportMetric(float64(len(benchMessageContent)), "bytes/op")
+}
+
+var (
+	conversationSeedOnce sync.Once
+	conversationSender   *models.User
+	conversationReceiver *models.User
+)
+
+func BenchmarkGetConversation(b *testing.B) {
+	const seededMessages = 1000
+	const pageSize = 50
+
+	conversationSeedOnce.Do(func() {
+		conversationSender = seedBenchUser(b, "bench_conv_a")
+		conversationReceiver = seedBenchUser(b, "bench_conv_b")
+
+		encrypted, err := utils.Encrypt(benchMessageContent)
+		if err != nil {
+			b.Fatalf("failed to encrypt seed content: %v", err)
+		}
+
+		messages := make([]models.Message, 0, seededMessages)
+		base := time.Now().Add(-seededMessages * time.Second)
+		for i := 0; i < seededMessages; i++ {
+			messages = append(messages, models.Message{
+				SenderID:   conversationSender.ID,
+				ReceiverID: conversationReceiver.ID,
+				Content:    encrypted,
+				CreatedAt:  base.Add(time.Duration(i) * time.Second),
+			})
+		}
+		if err := db.CreateInBatches(messages, 200).Error; err != nil {
+			b.Fatalf("failed to seed messages: %v", err)
+		}
+	})
+
+	var returned int
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		messages, err := testMessageService.GetConversation(conversationSender.ID, conversationReceiver.ID, pageSize, 0)
+		if err != nil {
+			b.Fatalf("GetConversation failed: %v", err)
+		}
+		returned = len(messages)
+	}
+	b.StopTimer()
+
+	b.ReportMetric(float64(returned), "messages/op")
+	b.ReportMetric(float64(returned*len(benchMessageContent)), "bytes/op")
+}
+
+func BenchmarkBroadcastToGroup(b *testing.B) {
+	const clientCount = 50
+
+	hub := websocket.NewHub()
+	go hub.Run()
+
+	groupID := uuid.New()
+	done := make(chan struct{})
+	defer close(done)
+
+	for i := 0; i < clientCount; i++ {
+		client := &websocket.Client{
+			Hub:      hub,
+			Send:     make(chan []byte, 256),
+			UserID:   uuid.New(),
+			Username: fmt.Sprintf("bench_client_%d", i),
+		}
+		hub.Register(client)
+		hub.AddUserToGroup(groupID, client.UserID)
+
+		// Drain the send channel like writePump would
+		go func(c *websocket.Client) {
+			for {
+				select {
+				case <-c.Send:
+				case <-done:
+					return
+				}
+			}
+		}(client)
+	}
+
+	// Let the hub process registrations before measuring
+	time.Sleep(100 * time.Millisecond)
+
+	message := &websocket.Message{
+		Type:      "new_group_message",
+		GroupID:   groupID,
+		Content:   benchMessageContent,
+		Timestamp: time.Now(),
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		hub.BroadcastToGroup(groupID, message)
+	}
+	b.StopTimer()
+
+	b.ReportMetric(clientCount, "messages/op")
+	b.ReportMetric(float64(clientCount*len(benchMessageContent)), "bytes/op")
+}
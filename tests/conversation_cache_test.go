@@ -0,0 +1,146 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"mms-backend/services"
+)
+
+// fakeCache is an in-memory CacheService; TTLs are ignored
+type fakeCache struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+}
+
+func (c *fakeCache) Get(key string, dest interface{}) error {
+	c.mu.Lock()
+	data, ok := c.entries[key]
+	c.mu.Unlock()
+	if !ok {
+		return services.ErrCacheMiss
+	}
+	return json.Unmarshal(data, dest)
+}
+
+func (c *fakeCache) Set(key string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = data
+	return nil
+}
+
+func (c *fakeCache) Delete(keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		delete(c.entries, key)
+	}
+	return nil
+}
+
+// has reports whether key is currently cached
+func (c *fakeCache) has(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.entries[key]
+	return ok
+}
+
+var testCache = &fakeCache{entries: make(map[string][]byte)}
+
+// conversationPreviews returns the last message preview of each conversation, keyed by partner ID
+func conversationPreviews(t *testing.T, token string) map[string]string {
+	t.Helper()
+
+	w := makeRequest("GET", "/api/v1/messages/conversations", nil, token)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		Data []struct {
+			User struct {
+				ID string `json:"id"`
+			} `json:"user"`
+			LastMessage string `json:"last_message"`
+		} `json:"data"`
+	}
+	parseResponse(w, &response)
+
+	previews := make(map[string]string, len(response.Data))
+	for _, summary := range response.Data {
+		previews[summary.User.ID] = summary.LastMessage
+	}
+	return previews
+}
+
+func TestConversationCache(t *testing.T) {
+	senderToken, senderID := signupUser(t, "cache_sender")
+	receiverToken, receiverID := signupUser(t, "cache_receiver")
+	senderKey := "conversations:" + senderID
+	receiverKey := "conversations:" + receiverID
+
+	send := func(content string) string {
+		w := makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+			"receiver_id": receiverID,
+			"content":     content,
+		}, senderToken)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		return response["data"].(map[string]interface{})["id"].(string)
+	}
+
+	messageID := send("first cached message")
+
+	assert.Equal(t, "first cached message", conversationPreviews(t, senderToken)[receiverID])
+	assert.Equal(t, "first cached message", conversationPreviews(t, receiverToken)[senderID])
+	require.True(t, testCache.has(senderKey))
+	require.True(t, testCache.has(receiverKey))
+
+	t.Run("PreviewsAreEncrypted", func(t *testing.T) {
+		testCache.mu.Lock()
+		data := string(testCache.entries[senderKey])
+		testCache.mu.Unlock()
+		assert.NotContains(t, data, "first cached message")
+	})
+
+	t.Run("SendInvalidatesBothUsers", func(t *testing.T) {
+		send("second cached message")
+		assert.False(t, testCache.has(senderKey))
+		assert.False(t, testCache.has(receiverKey))
+
+		assert.Equal(t, "second cached message", conversationPreviews(t, senderToken)[receiverID])
+		assert.Equal(t, "second cached message", conversationPreviews(t, receiverToken)[senderID])
+	})
+
+	t.Run("EditInvalidates", func(t *testing.T) {
+		messageID = send("to be edited")
+		conversationPreviews(t, receiverToken)
+
+		w := makeRequest("PUT", "/api/v1/messages/"+messageID, map[string]interface{}{
+			"content": "edited cached message",
+		}, senderToken)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.False(t, testCache.has(receiverKey))
+		assert.Equal(t, "edited cached message", conversationPreviews(t, receiverToken)[senderID])
+	})
+
+	t.Run("DeleteInvalidates", func(t *testing.T) {
+		conversationPreviews(t, receiverToken)
+
+		w := makeRequest("DELETE", "/api/v1/messages/"+messageID, nil, senderToken)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.False(t, testCache.has(receiverKey))
+	})
+}
@@ -0,0 +1,178 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/idtoken"
+
+	"mms-backend/models"
+	"mms-backend/utils"
+)
+
+const testGoogleClientID = "test-google-client-id"
+
+// fakeTokenVerifier resolves ID tokens from an in-memory table instead of Google's keys
+type fakeTokenVerifier struct {
+	payloads map[string]*idtoken.Payload
+}
+
+func (v *fakeTokenVerifier) Validate(ctx context.Context, idToken, audience string) (*idtoken.Payload, error) {
+	if audience != testGoogleClientID {
+		return nil, errors.New("audience mismatch")
+	}
+	payload, ok := v.payloads[idToken]
+	if !ok {
+		return nil, errors.New("unknown token")
+	}
+	return payload, nil
+}
+
+var testGoogleVerifier = &fakeTokenVerifier{payloads: map[string]*idtoken.Payload{}}
+
+// googleToken registers a fake ID token carrying the given profile claims
+func googleToken(token, sub, email, name, picture string) string {
+	testGoogleVerifier.payloads[token] = &idtoken.Payload{
+		Audience: testGoogleClientID,
+		Subject:  sub,
+		Claims: map[string]interface{}{
+			"sub":            sub,
+			"email":          email,
+			"email_verified": true,
+			"name":           name,
+			"picture":        picture,
+		},
+	}
+	return token
+}
+
+func TestGoogleLoginNewAccount(t *testing.T) {
+	token := googleToken("google-new", "google-sub-dave", "dave_google@example.com", "Dave Google", "https://example.com/dave.png")
+
+	w := makeRequest("POST", "/api/v1/auth/google", map[string]interface{}{"id_token": token}, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response map[string]interface{}
+	parseResponse(w, &response)
+	data := response["data"].(map[string]interface{})
+	user := data["user"].(map[string]interface{})
+
+	assert.NotEmpty(t, data["token"])
+	assert.Equal(t, "Dave_Google", user["username"])
+	assert.Equal(t, "https://example.com/dave.png", user["avatar"])
+
+	stored, err := testUserRepo.FindByEmail("dave_google@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "google-sub-dave", stored.GoogleID)
+
+	// Signing in again reuses the same account
+	w = makeRequest("POST", "/api/v1/auth/google", map[string]interface{}{"id_token": token}, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	parseResponse(w, &response)
+	again := response["data"].(map[string]interface{})["user"].(map[string]interface{})
+	assert.Equal(t, user["id"], again["id"])
+}
+
+func TestGoogleLoginUsernameTaken(t *testing.T) {
+	existing := &models.User{
+		Username: "Erin_Google",
+		Email:    "erin_password@example.com",
+		Password: "not-a-real-hash",
+	}
+	require.NoError(t, testUserRepo.Create(existing))
+
+	token := googleToken("google-erin", "google-sub-erin", "erin_google@example.com", "Erin Google", "")
+
+	w := makeRequest("POST", "/api/v1/auth/google", map[string]interface{}{"id_token": token}, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response map[string]interface{}
+	parseResponse(w, &response)
+	user := response["data"].(map[string]interface{})["user"].(map[string]interface{})
+	assert.Equal(t, "Erin_Google_2", user["username"])
+}
+
+func TestGoogleLoginUnusualName(t *testing.T) {
+	name := "Zoë 🚀 " + strings.Repeat("Very Long Display Name ", 10)
+	token := googleToken("google-zoe", "google-sub-zoe", "zoe_google@example.com", name, "")
+
+	w := makeRequest("POST", "/api/v1/auth/google", map[string]interface{}{"id_token": token}, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response map[string]interface{}
+	parseResponse(w, &response)
+	username := response["data"].(map[string]interface{})["user"].(map[string]interface{})["username"].(string)
+	assert.NoError(t, utils.ValidateUsername(username))
+	assert.True(t, strings.HasPrefix(username, "Zo_Very_Long"), username)
+
+	// A name with nothing usable falls back to the email's local part
+	token = googleToken("google-yuki", "google-sub-yuki", "yuki.google@example.com", "ゆき", "")
+	w = makeRequest("POST", "/api/v1/auth/google", map[string]interface{}{"id_token": token}, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	parseResponse(w, &response)
+	assert.Equal(t, "yuki_google", response["data"].(map[string]interface{})["user"].(map[string]interface{})["username"])
+}
+
+func TestGoogleLoginLinksExistingAccount(t *testing.T) {
+	hashed, err := utils.HashPassword("Frank1234!")
+	require.NoError(t, err)
+	existing := &models.User{
+		Username: "frank_test",
+		Email:    "frank_test@example.com",
+		Password: hashed,
+		Language: "fr",
+	}
+	require.NoError(t, testUserRepo.Create(existing))
+
+	token := googleToken("google-frank", "google-sub-frank", "Frank_Test@example.com", "Frank", "https://example.com/frank.png")
+
+	w := makeRequest("POST", "/api/v1/auth/google", map[string]interface{}{"id_token": token}, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response map[string]interface{}
+	parseResponse(w, &response)
+	user := response["data"].(map[string]interface{})["user"].(map[string]interface{})
+	assert.Equal(t, existing.ID.String(), user["id"])
+	assert.Equal(t, "frank_test", user["username"])
+
+	stored, err := testUserRepo.FindByID(existing.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "google-sub-frank", stored.GoogleID)
+	assert.Equal(t, "https://example.com/frank.png", stored.Avatar)
+
+	// Password login keeps working after linking
+	w = makeRequest("POST", "/api/v1/auth/login", map[string]interface{}{
+		"identifier": "frank_test@example.com",
+		"password":   "Frank1234!",
+	}, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// A different Google account with the same email is rejected
+	other := googleToken("google-frank-other", "google-sub-other", "frank_test@example.com", "Frank", "")
+	w = makeRequest("POST", "/api/v1/auth/google", map[string]interface{}{"id_token": other}, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestGoogleLoginInvalidToken(t *testing.T) {
+	w := makeRequest("POST", "/api/v1/auth/google", map[string]interface{}{"id_token": "forged"}, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = makeRequest("POST", "/api/v1/auth/google", map[string]interface{}{}, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGoogleLoginOAuthPath(t *testing.T) {
+	token := googleToken("google-gina", "google-sub-gina", "gina_google@example.com", "Gina Google", "")
+
+	w := makeRequest("POST", "/api/v1/auth/oauth/google", map[string]interface{}{"id_token": token}, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response map[string]interface{}
+	parseResponse(w, &response)
+	assert.NotEmpty(t, response["data"].(map[string]interface{})["token"])
+}
@@ -2,24 +2,44 @@ package tests
 
 import (
 	"bytes"
+	"context"
+	"encoding/csv"
 	"encoding/json"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	gorillaws "github.com/gorilla/websocket"
+	"github.com/pquerna/otp/totp"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 
 	"mms-backend/config"
 	"mms-backend/controllers"
+	"mms-backend/metrics"
 	"mms-backend/middleware"
+	"mms-backend/migrations"
 	"mms-backend/models"
 	"mms-backend/repositories"
 	"mms-backend/routes"
+	"mms-backend/seeds"
 	"mms-backend/services"
+	"mms-backend/utils"
 	"mms-backend/websocket"
 )
 
@@ -27,6 +47,16 @@ var (
 	router     *gin.Engine
 	db         *gorm.DB
 	testServer *httptest.Server
+
+	// Shared with benchmarks so they exercise the same wiring as the HTTP tests
+	testHub            *websocket.Hub
+	testUserRepo       *repositories.UserRepository
+	testMessageRepo    *repositories.MessageRepository
+	testMessageService *services.MessageService
+	testAuthService    *services.AuthService
+
+	// Stands in for FCM so tests can see which devices were pushed
+	fakeFCM *fakePushServer
 )
 
 // Test user credentials
@@ -63,6 +93,7 @@ func TestMain(m *testing.M) {
 
 	// Cleanup
 	cleanupTestDatabase()
+	os.RemoveAll(config.AppConfig.Storage.LocalPath)
 
 	os.Exit(code)
 }
@@ -81,8 +112,23 @@ func setupTestEnvironment() {
 	os.Setenv("JWT_EXPIRY", "24h")
 	os.Setenv("ENCRYPTION_KEY", "test-encryption-key-32-bytes!!")
 
-	// Load config
-	config.LoadConfig()
+	uploadDir, err := os.MkdirTemp("", "mms-test-uploads")
+	if err != nil {
+		panic("failed to create upload directory: " + err.Error())
+	}
+	os.Setenv("STORAGE_DRIVER", "local")
+	os.Setenv("STORAGE_LOCAL_PATH", uploadDir)
+	os.Setenv("STORAGE_PUBLIC_URL", "/uploads")
+
+	fakeFCM = newFakePushServer()
+	os.Setenv("FCM_SERVER_KEY", "test-fcm-server-key")
+	os.Setenv("FCM_ENDPOINT", fakeFCM.URL)
+
+	// Load config; the test settings must pass the same checks as the server's
+	cfg, _ := config.LoadConfig()
+	if err := config.Validate(cfg); err != nil {
+		panic(err.Error())
+	}
 }
 
 func setupTestDatabase() {
@@ -112,20 +158,17 @@ func setupTestDatabase() {
 	}
 
 	// Run migrations
-	db.AutoMigrate(
-		&models.User{},
-		&models.Message{},
-		&models.Group{},
-		&models.GroupMember{},
-		&models.GroupMessage{},
-		&models.Notification{},
-	)
+	db.AutoMigrate(models.All()...)
+	if err := migrations.Run(db); err != nil {
+		panic("failed to run SQL migrations: " + err.Error())
+	}
 }
 
 func setupTestRouter() {
 	gin.SetMode(gin.TestMode)
 	router = gin.New()
-	router.Use(middleware.CORSMiddleware())
+	router.Use(middleware.CORSMiddleware(config.AppConfig.Server))
+	metrics.Init()
 
 	// Initialize repositories
 	userRepo := repositories.NewUserRepository(db)
@@ -133,28 +176,87 @@ func setupTestRouter() {
 	groupRepo := repositories.NewGroupRepository(db)
 	groupMessageRepo := repositories.NewGroupMessageRepository(db)
 	notificationRepo := repositories.NewNotificationRepository(db)
+	idempotencyRepo := repositories.NewIdempotencyRepository(db)
+	contentFilterRepo := repositories.NewContentFilterRepository(db)
+	passwordResetRepo := repositories.NewPasswordResetRepository(db)
+
+	// Initialize WebSocket hub (needed by services)
+	hub := websocket.NewHub()
+	// Presence events only reach users sharing a group or conversation
+	hub.SetPresenceLoader(services.NewPresenceService(groupRepo, messageRepo))
+	hub.SetBlockChecker(userRepo)
+	hub.SetMaxConnectionsPerUser(config.AppConfig.Server.WSMaxConnections)
+	// Persist online status and last_seen as users connect and disconnect
+	hub.OnClientConnect = func(userID uuid.UUID) {
+		if err := userRepo.UpdateOnlineStatus(userID, true); err != nil {
+			utils.Logger.Error("Failed to update online status", "user_id", userID, "error", err)
+		}
+	}
+	hub.OnClientDisconnect = func(userID uuid.UUID) {
+		if err := userRepo.UpdateOnlineStatus(userID, false); err != nil {
+			utils.Logger.Error("Failed to update online status", "user_id", userID, "error", err)
+		}
+	}
+	go hub.Run()
+	wsHandler := websocket.NewHandler(hub, websocket.CompressionOptions{
+		Enabled: config.AppConfig.Server.WSCompressionEnabled,
+		Level:   config.AppConfig.Server.CompressionLevel,
+	})
 
 	// Initialize services
-	pushService := services.NewPushService(config.AppConfig)
-	authService := services.NewAuthService(userRepo)
-	messageService := services.NewMessageService(messageRepo, userRepo, notificationRepo, pushService)
-	groupService := services.NewGroupService(groupRepo, groupMessageRepo, userRepo, notificationRepo, pushService)
+	pushService := services.NewPushService(config.AppConfig, userRepo, notificationRepo)
+	authService := services.NewAuthService(userRepo, passwordResetRepo, testEmailService, testGoogleVerifier, testGoogleClientID)
+	unreadService := services.NewUnreadService(messageRepo, groupMessageRepo, hub)
+	contentFilterService := services.NewContentFilterService(contentFilterRepo)
+	storage := services.NewLocalStorage(config.AppConfig.Storage.LocalPath, config.AppConfig.Storage.PublicURL)
+	attachmentService := services.NewAttachmentService(storage, config.AppConfig.Storage.PublicURL)
+	messageService := services.NewMessageService(messageRepo, userRepo, notificationRepo, pushService, unreadService, contentFilterService, attachmentService, testCache, hub)
+	hub.SetDeliveryAcknowledger(messageService)
+	groupService := services.NewGroupService(groupRepo, groupMessageRepo, userRepo, notificationRepo, pushService, unreadService, hub)
+	wsHandler.SetMissedMessagesLoader(services.NewMissedMessagesService(messageService, groupService))
+	adminService := services.NewAdminService(messageRepo, userRepo, hub)
+	notificationService := services.NewNotificationService(notificationRepo)
+	userService := services.NewUserService(userRepo, storage)
 
 	// Initialize controllers
 	authController := controllers.NewAuthController(authService)
-	userController := controllers.NewUserController(userRepo)
-	messageController := controllers.NewMessageController(messageService)
-	groupController := controllers.NewGroupController(groupService)
+	userController := controllers.NewUserController(userService)
+	messageController := controllers.NewMessageController(messageService, attachmentService, idempotencyRepo)
+	groupController := controllers.NewGroupController(groupService, idempotencyRepo)
+	adminController := controllers.NewAdminController(adminService, contentFilterService)
+	notificationController := controllers.NewNotificationController(notificationService)
+	sqlDB, err := db.DB()
+	if err != nil {
+		panic("failed to get database handle: " + err.Error())
+	}
+	healthController := controllers.NewHealthController(sqlDB)
+	stickyTracker := middleware.NewStickyTracker(middleware.DefaultStickyWindow)
 
-	// Initialize WebSocket
-	hub := websocket.NewHub()
-	wsHandler := websocket.NewHandler(hub)
+	// Every test request comes from the same address, so rate limiting is covered by
+	// the middleware unit tests instead
+	config.AppConfig.RateLimit = config.RateLimitConfig{}
 
 	// Setup routes
-	routes.SetupRoutes(router, authController, userController, messageController, groupController, wsHandler)
+	routes.SetupRoutes(router, authController, userController, messageController, groupController, adminController, notificationController, healthController, wsHandler, stickyTracker, userRepo)
+	router.Static(config.AppConfig.Storage.PublicURL, storage.Dir())
+
+	// Real HTTP server so WebSocket clients can connect
+	testServer = httptest.NewServer(router)
+
+	testHub = hub
+	testUserRepo = userRepo
+	testMessageRepo = messageRepo
+	testMessageService = messageService
+	testAuthService = authService
 }
 
 func cleanupTestDatabase() {
+	if fakeFCM != nil {
+		fakeFCM.Close()
+	}
+	if testServer != nil {
+		testServer.Close()
+	}
 	if db != nil {
 		sqlDB, _ := db.DB()
 		sqlDB.Close()
@@ -163,6 +265,10 @@ func cleanupTestDatabase() {
 
 // Helper functions
 func makeRequest(method, path string, body interface{}, token string) *httptest.ResponseRecorder {
+	return makeRequestWithHeaders(method, path, body, token, nil)
+}
+
+func makeRequestWithHeaders(method, path string, body interface{}, token string, headers map[string]string) *httptest.ResponseRecorder {
 	var reqBody []byte
 	if body != nil {
 		reqBody, _ = json.Marshal(body)
@@ -170,9 +276,14 @@ func makeRequest(method, path string, body interface{}, token string) *httptest.
 
 	req, _ := http.NewRequest(method, path, bytes.NewBuffer(reqBody))
 	req.Header.Set("Content-Type", "application/json")
+	// Tag every test request so its server-side logs can be found
+	req.Header.Set(middleware.RequestIDHeader, uuid.NewString())
 	if token != "" {
 		req.Header.Set("Authorization", "Bearer "+token)
 	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
 
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
@@ -183,6 +294,50 @@ func parseResponse(w *httptest.ResponseRecorder, target interface{}) error {
 	return json.Unmarshal(w.Body.Bytes(), target)
 }
 
+// fakePushServer records the device tokens of FCM pushes it receives
+type fakePushServer struct {
+	*httptest.Server
+	mu     sync.Mutex
+	tokens []string
+}
+
+func newFakePushServer() *fakePushServer {
+	f := &fakePushServer{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload services.FCMPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err == nil {
+			f.mu.Lock()
+			f.tokens = append(f.tokens, payload.To)
+			f.mu.Unlock()
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	return f
+}
+
+// pushedTo reports how many pushes were sent to a device token
+func (f *fakePushServer) pushedTo(token string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	count := 0
+	for _, t := range f.tokens {
+		if t == token {
+			count++
+		}
+	}
+	return count
+}
+
+func parseUUID(t *testing.T, id string) uuid.UUID {
+	t.Helper()
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		t.Fatalf("invalid uuid %q: %v", id, err)
+	}
+	return parsed
+}
+
 // ========================================
 // AUTHENTICATION TESTS
 // ========================================
@@ -320,6 +475,18 @@ func TestGetMe(t *testing.T) {
 	t.Log("✓ Get current user successful")
 }
 
+func TestGetMeBio(t *testing.T) {
+	token, _ := signupWithBio(t, "bio_me", "Coffee lover and weekend climber")
+
+	w := makeRequest("GET", "/api/v1/auth/me", nil, token)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	parseResponse(w, &response)
+	data := response["data"].(map[string]interface{})
+	assert.Equal(t, "Coffee lover and weekend climber", data["bio"])
+}
+
 func TestGetMeWithoutToken(t *testing.T) {
 	w := makeRequest("GET", "/api/v1/auth/me", nil, "")
 	
@@ -342,6 +509,7 @@ func TestListUsers(t *testing.T) {
 	
 	data := response["data"].([]interface{})
 	assert.GreaterOrEqual(t, len(data), 2) // At least Alice and Bob
+	assert.Contains(t, response, "meta")
 	
 	t.Logf("✓ Listed %d users", len(data))
 }
@@ -356,6 +524,7 @@ func TestSearchUsers(t *testing.T) {
 	
 	data := response["data"].([]interface{})
 	assert.GreaterOrEqual(t, len(data), 1)
+	assert.Contains(t, response, "meta")
 	
 	// Verify Bob is in results
 	found := false
@@ -529,6 +698,7 @@ func TestGetUserGroups(t *testing.T) {
 	
 	data := response["data"].([]interface{})
 	assert.GreaterOrEqual(t, len(data), 1)
+	assert.Contains(t, response, "meta")
 	
 	t.Logf("✓ Alice has %d groups", len(data))
 }
@@ -680,7 +850,6309 @@ func TestMessageEncryption(t *testing.T) {
 	// Content in DB should be encrypted (base64, different from original)
 	assert.NotEqual(t, "This message should be encrypted in the database", msg.Content)
 	assert.NotEmpty(t, msg.Content)
+	assert.Nil(t, data["delivered_at"])
+	assert.Nil(t, msg.DeliveredAt)
+
+	// Delivery receipt from the receiver sets delivered_at
+	w = makeRequest("PUT", "/api/v1/messages/delivered/"+aliceID, nil, bobToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+	db.First(&msg, "id = ?", messageID)
+	assert.NotNil(t, msg.DeliveredAt)
+	assert.False(t, msg.IsRead)
 	
 	t.Log("✓ Message is encrypted in database")
 }
 
+
+// ========================================
+// WEBSOCKET TESTS
+// ========================================
+
+// wsTestClient wraps a WebSocket connection and exposes received events on a channel
+type wsTestClient struct {
+	conn   *gorillaws.Conn
+	events chan map[string]interface{}
+}
+
+func dialWebSocket(t *testing.T, token string) *wsTestClient {
+	t.Helper()
+	return dialWebSocketQuery(t, token, "")
+}
+
+// dialWebSocketQuery connects with extra query parameters, e.g. "last_seen_message_id=..."
+func dialWebSocketQuery(t *testing.T, token, query string) *wsTestClient {
+	t.Helper()
+
+	wsURL := "ws" + strings.TrimPrefix(testServer.URL, "http") + "/api/v1/ws?token=" + token
+	if query != "" {
+		wsURL += "&" + query
+	}
+	conn, _, err := gorillaws.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("failed to dial WebSocket: %v", err)
+	}
+
+	client := &wsTestClient{
+		conn:   conn,
+		events: make(chan map[string]interface{}, 64),
+	}
+
+	go func() {
+		defer close(client.events)
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			// The hub may batch several events in one frame, separated by newlines
+			for _, line := range bytes.Split(data, []byte{'\n'}) {
+				var event map[string]interface{}
+				if err := json.Unmarshal(line, &event); err == nil {
+					client.events <- event
+				}
+			}
+		}
+	}()
+
+	return client
+}
+
+// waitForEvent returns the first event of the given type, failing after timeout
+func (c *wsTestClient) waitForEvent(t *testing.T, eventType string, timeout time.Duration) map[string]interface{} {
+	t.Helper()
+
+	deadline := time.After(timeout)
+	for {
+		select {
+		case event, ok := <-c.events:
+			if !ok {
+				t.Fatalf("connection closed while waiting for %s event", eventType)
+			}
+			if event["type"] == eventType {
+				return event
+			}
+		case <-deadline:
+			t.Fatalf("timeout waiting for %s event", eventType)
+		}
+	}
+}
+
+// waitForMessage returns the first new_message event with the given content, skipping
+// events for other messages
+func (c *wsTestClient) waitForMessage(t *testing.T, content string, timeout time.Duration) map[string]interface{} {
+	t.Helper()
+
+	deadline := time.After(timeout)
+	for {
+		select {
+		case event, ok := <-c.events:
+			if !ok {
+				t.Fatalf("connection closed while waiting for message %q", content)
+			}
+			if event["type"] == "new_message" && event["content"] == content {
+				return event
+			}
+		case <-deadline:
+			t.Fatalf("timeout waiting for message %q", content)
+		}
+	}
+}
+
+// expectNoEvent fails if an event of the given type arrives within timeout
+func (c *wsTestClient) expectNoEvent(t *testing.T, eventType string, timeout time.Duration) {
+	t.Helper()
+
+	deadline := time.After(timeout)
+	for {
+		select {
+		case event, ok := <-c.events:
+			if !ok {
+				return
+			}
+			if event["type"] == eventType {
+				t.Fatalf("unexpected %s event: %v", eventType, event)
+			}
+		case <-deadline:
+			return
+		}
+	}
+}
+
+func (c *wsTestClient) send(t *testing.T, payload interface{}) {
+	t.Helper()
+	if err := c.conn.WriteJSON(payload); err != nil {
+		t.Fatalf("failed to write WebSocket frame: %v", err)
+	}
+}
+
+func (c *wsTestClient) close() {
+	c.conn.Close()
+}
+
+// waitForOnline waits until the hub reports the expected online state for a user
+func waitForOnline(t *testing.T, userID string, online bool) {
+	t.Helper()
+
+	id := parseUUID(t, userID)
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if testHub.IsUserOnline(id) == online {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timeout waiting for user %s online=%v", userID, online)
+}
+
+func TestWebSocketMessageDelivery(t *testing.T) {
+	aliceWS := dialWebSocket(t, aliceToken)
+	defer aliceWS.close()
+	bobWS := dialWebSocket(t, bobToken)
+	defer bobWS.close()
+
+	waitForOnline(t, aliceID, true)
+	waitForOnline(t, bobID, true)
+
+	w := makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+		"receiver_id": bobID,
+		"content":     "Hello Bob over WebSocket!",
+	}, aliceToken)
+	if !assert.Equal(t, http.StatusCreated, w.Code) {
+		t.FailNow()
+	}
+
+	event := bobWS.waitForMessage(t, "Hello Bob over WebSocket!", time.Second)
+	assert.Equal(t, aliceID, event["sender_id"])
+
+	// Frames a client sends itself are not relayed: they skip the checks of the API
+	aliceWS.send(t, map[string]interface{}{
+		"type":        "new_message",
+		"receiver_id": bobID,
+		"content":     "Unchecked relay",
+	})
+	bobWS.expectNoEvent(t, "new_message", 200*time.Millisecond)
+
+	t.Log("✓ WebSocket message delivered to receiver")
+}
+
+func TestWebSocketReadReceipt(t *testing.T) {
+	aliceWS := dialWebSocket(t, aliceToken)
+	defer aliceWS.close()
+	waitForOnline(t, aliceID, true)
+
+	messageData := map[string]interface{}{
+		"receiver_id": bobID,
+		"content":     "Please read this",
+	}
+	w := makeRequest("POST", "/api/v1/messages", messageData, aliceToken)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = makeRequest("PUT", "/api/v1/messages/read/"+aliceID, nil, bobToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	event := aliceWS.waitForEvent(t, "message_read", 2*time.Second)
+	assert.Equal(t, bobID, event["sender_id"])
+
+	t.Log("✓ Read receipt delivered over WebSocket")
+}
+
+func TestWebSocketReconnect(t *testing.T) {
+	aliceWS := dialWebSocket(t, aliceToken)
+	defer aliceWS.close()
+	waitForOnline(t, aliceID, true)
+
+	bobWS := dialWebSocket(t, bobToken)
+	waitForOnline(t, bobID, true)
+
+	send := func(content string) string {
+		w := makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+			"receiver_id": bobID,
+			"content":     content,
+		}, aliceToken)
+		if !assert.Equal(t, http.StatusCreated, w.Code) {
+			t.FailNow()
+		}
+		return parseMessageID(t, w)
+	}
+
+	lastSeenID := send("Seen before leaving")
+	bobWS.waitForMessage(t, "Seen before leaving", 2*time.Second)
+	bobWS.close()
+	waitForOnline(t, bobID, false)
+
+	time.Sleep(10 * time.Millisecond)
+	send("Sent while you were away")
+
+	// Nothing is kept in memory for offline users; the reconnect catches up from the database
+	bobWS = dialWebSocketQuery(t, bobToken, "last_seen_message_id="+lastSeenID)
+	defer bobWS.close()
+
+	event := bobWS.waitForEvent(t, "pending_messages", 2*time.Second)
+	var contents []string
+	for _, item := range event["data"].(map[string]interface{})["messages"].([]interface{}) {
+		contents = append(contents, item.(map[string]interface{})["content"].(string))
+	}
+	assert.Equal(t, []string{"Sent while you were away"}, contents)
+
+	t.Log("✓ Missed events delivered after reconnect")
+}
+
+// ========================================
+// GROUP LIFECYCLE TESTS
+// ========================================
+
+var (
+	testUserCarol = map[string]interface{}{
+		"username": "carol_test",
+		"email":    "carol_test@example.com",
+		"password": "Carol1234!",
+		"language": "es",
+	}
+
+	carolToken string
+	carolID    string
+
+	lifecycleGroupID string
+)
+
+// ensureCarol signs up a third user on first use, for tests that need a non-member
+func ensureCarol(t *testing.T) {
+	t.Helper()
+	if carolToken != "" {
+		return
+	}
+
+	w := makeRequest("POST", "/api/v1/auth/signup", testUserCarol, "")
+	if !assert.Equal(t, http.StatusCreated, w.Code) {
+		t.FailNow()
+	}
+
+	var response map[string]interface{}
+	parseResponse(w, &response)
+	data := response["data"].(map[string]interface{})
+	carolToken = data["token"].(string)
+	carolID = data["user"].(map[string]interface{})["id"].(string)
+}
+
+func TestGroupLifecycle(t *testing.T) {
+	aliceWS := dialWebSocket(t, aliceToken)
+	defer aliceWS.close()
+	bobWS := dialWebSocket(t, bobToken)
+	defer bobWS.close()
+	waitForOnline(t, aliceID, true)
+	waitForOnline(t, bobID, true)
+
+	t.Run("CreateGroup", func(t *testing.T) {
+		groupData := map[string]interface{}{
+			"name": "Lifecycle Group",
+			"type": "private",
+		}
+		w := makeRequest("POST", "/api/v1/groups", groupData, aliceToken)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		data := response["data"].(map[string]interface{})
+		lifecycleGroupID = data["id"].(string)
+
+		assert.Equal(t, "Lifecycle Group", data["name"])
+		assert.Equal(t, aliceID, data["created_by"])
+	})
+
+	t.Run("AddMember", func(t *testing.T) {
+		w := makeRequest("POST", "/api/v1/groups/"+lifecycleGroupID+"/members", map[string]string{"user_id": bobID}, aliceToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		event := bobWS.waitForEvent(t, "group_member_added", 2*time.Second)
+		assert.Equal(t, lifecycleGroupID, event["group_id"])
+		assert.Equal(t, bobID, event["data"].(map[string]interface{})["user_id"])
+	})
+
+	t.Run("SendMessage", func(t *testing.T) {
+		messageData := map[string]interface{}{
+			"group_id": lifecycleGroupID,
+			"content":  "Welcome to the lifecycle group",
+		}
+		w := makeRequest("POST", "/api/v1/groups/messages", messageData, aliceToken)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		data := response["data"].(map[string]interface{})
+		assert.Equal(t, lifecycleGroupID, data["group_id"])
+		assert.Equal(t, aliceID, data["sender_id"])
+
+		event := bobWS.waitForEvent(t, "new_group_message", 2*time.Second)
+		assert.Equal(t, "Welcome to the lifecycle group", event["content"])
+		assert.Equal(t, data["id"], event["data"].(map[string]interface{})["message_id"])
+	})
+
+	t.Run("RemoveMember", func(t *testing.T) {
+		w := makeRequest("DELETE", "/api/v1/groups/"+lifecycleGroupID+"/members/"+bobID, nil, aliceToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		event := bobWS.waitForEvent(t, "group_member_removed", 2*time.Second)
+		assert.Equal(t, lifecycleGroupID, event["group_id"])
+
+		assert.False(t, parseGroupMembers(t, lifecycleGroupID, aliceToken)[bobID])
+	})
+
+	t.Run("DeleteGroup", func(t *testing.T) {
+		w := makeRequest("DELETE", "/api/v1/groups/"+lifecycleGroupID, nil, aliceToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		event := aliceWS.waitForEvent(t, "group_deleted", 2*time.Second)
+		assert.Equal(t, lifecycleGroupID, event["group_id"])
+
+		assertGroupRowsDeleted(t, lifecycleGroupID)
+	})
+
+	t.Log("✓ Group lifecycle completed with WebSocket events")
+}
+
+func TestGroupPermissions(t *testing.T) {
+	ensureCarol(t)
+
+	groupData := map[string]interface{}{
+		"name":       "Permissions Group",
+		"type":       "private",
+		"member_ids": []string{bobID},
+	}
+	w := makeRequest("POST", "/api/v1/groups", groupData, aliceToken)
+	if !assert.Equal(t, http.StatusCreated, w.Code) {
+		t.FailNow()
+	}
+	var response map[string]interface{}
+	parseResponse(w, &response)
+	groupID := response["data"].(map[string]interface{})["id"].(string)
+
+	t.Run("NonMemberCannotSendMessage", func(t *testing.T) {
+		messageData := map[string]interface{}{"group_id": groupID, "content": "let me in"}
+		w := makeRequest("POST", "/api/v1/groups/messages", messageData, carolToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("NonAdminCannotAddMemberWhenRestricted", func(t *testing.T) {
+		w := makeRequest("PATCH", "/api/v1/groups/"+groupID+"/permissions", map[string]interface{}{"add_members": "admin"}, aliceToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = makeRequest("POST", "/api/v1/groups/"+groupID+"/members", map[string]string{"user_id": carolID}, bobToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("AdminCanAddMember", func(t *testing.T) {
+		w := makeRequest("POST", "/api/v1/groups/"+groupID+"/members", map[string]string{"user_id": carolID}, aliceToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("NonCreatorCannotDeleteGroup", func(t *testing.T) {
+		w := makeRequest("DELETE", "/api/v1/groups/"+groupID, nil, bobToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("CreatorCanDeleteGroup", func(t *testing.T) {
+		w := makeRequest("DELETE", "/api/v1/groups/"+groupID, nil, aliceToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		assertGroupRowsDeleted(t, groupID)
+	})
+
+	t.Log("✓ Group permission matrix enforced")
+}
+
+// parseGroupMembers returns the set of member IDs of a group
+func parseGroupMembers(t *testing.T, groupID, token string) map[string]bool {
+	t.Helper()
+
+	w := makeRequest("GET", "/api/v1/groups/"+groupID+"/members", nil, token)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	parseResponse(w, &response)
+
+	members := make(map[string]bool)
+	for _, member := range response["data"].([]interface{}) {
+		members[member.(map[string]interface{})["id"].(string)] = true
+	}
+	return members
+}
+
+// assertGroupRowsDeleted checks that deleting a group removed its members and messages
+func assertGroupRowsDeleted(t *testing.T, groupID string) {
+	t.Helper()
+
+	var memberCount int64
+	db.Model(&models.GroupMember{}).Where("group_id = ?", groupID).Count(&memberCount)
+	assert.Equal(t, int64(0), memberCount, "group_members should be empty for deleted group")
+
+	var messageCount int64
+	db.Model(&models.GroupMessage{}).Where("group_id = ?", groupID).Count(&messageCount)
+	assert.Equal(t, int64(0), messageCount, "group_messages should be empty for deleted group")
+}
+
+// ============================================================================
+// API VERSIONING TESTS
+// ============================================================================
+
+func TestAPIVersionHeader(t *testing.T) {
+	w := makeRequest("GET", "/health", nil, "")
+	assert.Equal(t, "1", w.Header().Get("X-API-Version"))
+
+	w = makeRequest("GET", "/api/v1/auth/me", nil, aliceToken)
+	assert.Equal(t, "1", w.Header().Get("X-API-Version"))
+
+	w = makeRequest("GET", "/api/v2/auth/me", nil, aliceToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-API-Version"))
+
+	w = makeRequest("GET", "/api/v2/auth/me", nil, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestConversationV1VersusV2(t *testing.T) {
+	w := makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+		"receiver_id": bobID,
+		"content":     "Versioned hello",
+	}, aliceToken)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var v1 map[string]interface{}
+	w = makeRequest("GET", "/api/v1/messages/conversation/"+bobID, nil, aliceToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+	parseResponse(w, &v1)
+
+	var v2 map[string]interface{}
+	w = makeRequest("GET", "/api/v2/messages/conversation/"+bobID, nil, aliceToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+	parseResponse(w, &v2)
+
+	v1Messages := v1["data"].([]interface{})
+	v2Messages := v2["data"].([]interface{})
+	assert.NotEmpty(t, v1Messages)
+	assert.Equal(t, len(v1Messages), len(v2Messages))
+
+	v1First := v1Messages[0].(map[string]interface{})
+	v2First := v2Messages[0].(map[string]interface{})
+	assert.Equal(t, v1First["id"], v2First["id"])
+	assert.Equal(t, v1First["content"], v2First["content"])
+
+	// v1 keeps is_read and has no v2-only fields
+	assert.Contains(t, v1First, "is_read")
+	assert.NotContains(t, v1First, "status")
+	assert.NotContains(t, v1First, "reactions")
+
+	// v2 replaces is_read with status and always includes reactions
+	assert.NotContains(t, v2First, "is_read")
+	assert.Contains(t, []interface{}{"sent", "delivered", "read"}, v2First["status"])
+	assert.IsType(t, []interface{}{}, v2First["reactions"])
+}
+
+func TestGroupV1VersusV2(t *testing.T) {
+	w := makeRequest("POST", "/api/v2/groups", map[string]interface{}{
+		"name":       "Versioned Group",
+		"type":       "private",
+		"member_ids": []string{bobID},
+	}, aliceToken)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var created map[string]interface{}
+	parseResponse(w, &created)
+	groupID := created["data"].(map[string]interface{})["id"].(string)
+
+	var v1 map[string]interface{}
+	w = makeRequest("GET", "/api/v1/groups/"+groupID, nil, aliceToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+	parseResponse(w, &v1)
+
+	var v2 map[string]interface{}
+	w = makeRequest("GET", "/api/v2/groups/"+groupID, nil, aliceToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+	parseResponse(w, &v2)
+
+	v1Group := v1["data"].(map[string]interface{})
+	v2Group := v2["data"].(map[string]interface{})
+	assert.Equal(t, v1Group["id"], v2Group["id"])
+	assert.NotContains(t, v1Group, "member_count")
+	assert.Equal(t, float64(2), v2Group["member_count"])
+
+	var mine map[string]interface{}
+	w = makeRequest("GET", "/api/v2/groups/my", nil, aliceToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+	parseResponse(w, &mine)
+
+	found := false
+	for _, g := range mine["data"].([]interface{}) {
+		group := g.(map[string]interface{})
+		assert.Contains(t, group, "member_count")
+		if group["id"] == groupID {
+			found = true
+			assert.Equal(t, float64(2), group["member_count"])
+		}
+	}
+	assert.True(t, found, "v2 group list should include the new group")
+
+	w = makeRequest("DELETE", "/api/v2/groups/"+groupID, nil, aliceToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+// ============================================================================
+// CONVERSATION SEARCH TESTS
+// ============================================================================
+
+func TestSearchConversations(t *testing.T) {
+	searcher := &models.User{
+		Username: "convsearch_owner",
+		Email:    "convsearch_owner@example.com",
+		Password: "not-a-real-hash",
+	}
+	assert.NoError(t, testUserRepo.Create(searcher))
+	token, err := utils.GenerateToken(searcher.ID, searcher.Username, searcher.Email)
+	assert.NoError(t, err)
+
+	partners := []string{"searchpartner_amber", "searchpartner_amelia", "searchpartner_brook", "searchpartner_cedar", "searchpartner_delta"}
+	for _, name := range partners {
+		partner := &models.User{
+			Username: name,
+			Email:    name + "@example.com",
+			Password: "not-a-real-hash",
+		}
+		assert.NoError(t, testUserRepo.Create(partner))
+
+		_, err := testMessageService.SendMessage(context.Background(), partner.ID, services.SendMessageRequest{
+			ReceiverID: searcher.ID,
+			Content:    "Hi from " + name,
+		})
+		assert.NoError(t, err)
+	}
+
+	search := func(query string) []map[string]interface{} {
+		w := makeRequest("GET", "/api/v1/messages/conversations/search?q="+query, nil, token)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response map[string]interface{}
+		parseResponse(w, &response)
+
+		results := make([]map[string]interface{}, 0)
+		for _, item := range response["data"].([]interface{}) {
+			results = append(results, item.(map[string]interface{}))
+		}
+		return results
+	}
+
+	// Exact username match
+	results := search("searchpartner_brook")
+	assert.Len(t, results, 1)
+	if len(results) == 1 {
+		user := results[0]["user"].(map[string]interface{})
+		assert.Equal(t, "searchpartner_brook", user["username"])
+		assert.Equal(t, "Hi from searchpartner_brook", results[0]["last_message"])
+		assert.Equal(t, float64(1), results[0]["unread_count"])
+	}
+
+	// Partial, case-insensitive match
+	results = search("PARTNER_AM")
+	assert.Len(t, results, 2)
+	for _, result := range results {
+		username := result["user"].(map[string]interface{})["username"].(string)
+		assert.Contains(t, []string{"searchpartner_amber", "searchpartner_amelia"}, username)
+	}
+
+	// Every seeded partner matches the shared prefix
+	assert.Len(t, search("searchpartner"), len(partners))
+
+	// Wildcards are matched literally
+	assert.Len(t, search("%25"), 0)
+
+	// No match and missing query
+	assert.Len(t, search("nobody"), 0)
+	w := makeRequest("GET", "/api/v1/messages/conversations/search", nil, token)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// ============================================================================
+// GROUP READ RECEIPT TESTS
+// ============================================================================
+
+func TestGroupMessageReadReceipts(t *testing.T) {
+	ensureCarol(t)
+
+	w := makeRequest("POST", "/api/v1/groups", map[string]interface{}{
+		"name":       "Read Receipts Group",
+		"type":       "private",
+		"member_ids": []string{bobID, carolID},
+	}, aliceToken)
+	if !assert.Equal(t, http.StatusCreated, w.Code) {
+		t.FailNow()
+	}
+	var created map[string]interface{}
+	parseResponse(w, &created)
+	groupID := created["data"].(map[string]interface{})["id"].(string)
+	defer makeRequest("DELETE", "/api/v1/groups/"+groupID, nil, aliceToken)
+
+	messageIDs := make([]string, 0, 3)
+	for i := 0; i < 3; i++ {
+		w := makeRequest("POST", "/api/v1/groups/messages", map[string]interface{}{
+			"group_id": groupID,
+			"content":  "Read me please",
+		}, aliceToken)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		messageIDs = append(messageIDs, response["data"].(map[string]interface{})["id"].(string))
+	}
+
+	countReads := func() int64 {
+		var count int64
+		db.Model(&models.GroupMessageRead{}).Where("group_message_id IN ?", messageIDs).Count(&count)
+		return count
+	}
+
+	aliceWS := dialWebSocket(t, aliceToken)
+	defer aliceWS.close()
+	waitForOnline(t, aliceID, true)
+
+	t.Run("BatchUpsertOnFetch", func(t *testing.T) {
+		w := makeRequest("GET", "/api/v1/groups/"+groupID+"/messages", nil, bobToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, int64(3), countReads())
+
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		for _, item := range response["data"].([]interface{}) {
+			readBy := item.(map[string]interface{})["read_by"].([]interface{})
+			assert.Len(t, readBy, 1)
+			reader := readBy[0].(map[string]interface{})["reader"].(map[string]interface{})
+			assert.Equal(t, bobID, reader["id"])
+		}
+
+		// Fetching again does not duplicate reads
+		w = makeRequest("GET", "/api/v1/groups/"+groupID+"/messages", nil, bobToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, int64(3), countReads())
+
+		// The sender's own fetch does not count as a read
+		w = makeRequest("GET", "/api/v1/groups/"+groupID+"/messages", nil, aliceToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, int64(3), countReads())
+
+		w = makeRequest("GET", "/api/v1/groups/"+groupID+"/messages", nil, carolToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, int64(6), countReads())
+	})
+
+	t.Run("WebSocketEvent", func(t *testing.T) {
+		readers := map[string]map[string]bool{}
+		for i := 0; i < 6; i++ {
+			event := aliceWS.waitForEvent(t, "group_message_read", 2*time.Second)
+			assert.Equal(t, groupID, event["group_id"])
+			data := event["data"].(map[string]interface{})
+			messageID := data["message_id"].(string)
+			if readers[messageID] == nil {
+				readers[messageID] = map[string]bool{}
+			}
+			readers[messageID][data["reader_id"].(string)] = true
+		}
+
+		for _, messageID := range messageIDs {
+			assert.True(t, readers[messageID][bobID], "bob read event missing for %s", messageID)
+			assert.True(t, readers[messageID][carolID], "carol read event missing for %s", messageID)
+		}
+	})
+
+	t.Run("ReadersEndpoint", func(t *testing.T) {
+		w := makeRequest("GET", "/api/v1/groups/messages/"+messageIDs[0]+"/readers", nil, aliceToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		assert.Len(t, response["data"].([]interface{}), 2)
+
+		w = makeRequest("GET", "/api/v1/groups/messages/"+messageIDs[0]+"/readers", nil, bobToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
+
+// ============================================================================
+// GROUP ARCHIVING TESTS
+// ============================================================================
+
+// groupIDsFrom extracts the group IDs from a list response
+func groupIDsFrom(t *testing.T, path, token string) map[string]bool {
+	t.Helper()
+
+	w := makeRequest("GET", path, nil, token)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	parseResponse(w, &response)
+
+	ids := make(map[string]bool)
+	for _, group := range response["data"].([]interface{}) {
+		ids[group.(map[string]interface{})["id"].(string)] = true
+	}
+	return ids
+}
+
+func TestGroupArchiving(t *testing.T) {
+	ensureCarol(t)
+
+	w := makeRequest("POST", "/api/v1/groups", map[string]interface{}{
+		"name":       "Archive Group",
+		"type":       "private",
+		"member_ids": []string{bobID},
+	}, aliceToken)
+	if !assert.Equal(t, http.StatusCreated, w.Code) {
+		t.FailNow()
+	}
+	var created map[string]interface{}
+	parseResponse(w, &created)
+	groupID := created["data"].(map[string]interface{})["id"].(string)
+	defer makeRequest("DELETE", "/api/v1/groups/"+groupID, nil, aliceToken)
+
+	w = makeRequest("POST", "/api/v1/groups/"+groupID+"/archive", nil, bobToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Archiving twice is harmless
+	w = makeRequest("POST", "/api/v1/groups/"+groupID+"/archive", nil, bobToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	assert.False(t, groupIDsFrom(t, "/api/v1/groups/my", bobToken)[groupID])
+	assert.True(t, groupIDsFrom(t, "/api/v1/groups/my?include_archived=true", bobToken)[groupID])
+	assert.True(t, groupIDsFrom(t, "/api/v1/groups/archived", bobToken)[groupID])
+
+	// Archiving is per user
+	assert.True(t, groupIDsFrom(t, "/api/v1/groups/my", aliceToken)[groupID])
+	assert.False(t, groupIDsFrom(t, "/api/v1/groups/archived", aliceToken)[groupID])
+
+	// Bob is still a member and still notified about new messages
+	w = makeRequest("POST", "/api/v1/groups/messages", map[string]interface{}{
+		"group_id": groupID,
+		"content":  "Still there?",
+	}, aliceToken)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var sent map[string]interface{}
+	parseResponse(w, &sent)
+	messageID := sent["data"].(map[string]interface{})["id"].(string)
+
+	var notificationCount int64
+	db.Model(&models.Notification{}).Where("user_id = ? AND reference_id = ?", bobID, messageID).Count(&notificationCount)
+	assert.Equal(t, int64(1), notificationCount)
+
+	// Non-members cannot archive
+	w = makeRequest("POST", "/api/v1/groups/"+groupID+"/archive", nil, carolToken)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = makeRequest("DELETE", "/api/v1/groups/"+groupID+"/archive", nil, bobToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	assert.True(t, groupIDsFrom(t, "/api/v1/groups/my", bobToken)[groupID])
+	assert.False(t, groupIDsFrom(t, "/api/v1/groups/archived", bobToken)[groupID])
+}
+
+// ============================================================================
+// GROUP MUTING TESTS
+// ============================================================================
+
+func TestGroupMuting(t *testing.T) {
+	ensureCarol(t)
+
+	bobDevice := "bob-device-" + uuid.New().String()
+	carolDevice := "carol-device-" + uuid.New().String()
+	assert.NoError(t, testUserRepo.UpdateDeviceToken(parseUUID(t, bobID), bobDevice, "android"))
+	assert.NoError(t, testUserRepo.UpdateDeviceToken(parseUUID(t, carolID), carolDevice, "android"))
+	defer testUserRepo.UpdateDeviceToken(parseUUID(t, bobID), "", "")
+	defer testUserRepo.UpdateDeviceToken(parseUUID(t, carolID), "", "")
+
+	w := makeRequest("POST", "/api/v1/groups", map[string]interface{}{
+		"name":       "Mute Group",
+		"type":       "private",
+		"member_ids": []string{bobID, carolID},
+	}, aliceToken)
+	if !assert.Equal(t, http.StatusCreated, w.Code) {
+		t.FailNow()
+	}
+	var created map[string]interface{}
+	parseResponse(w, &created)
+	groupID := created["data"].(map[string]interface{})["id"].(string)
+	defer makeRequest("DELETE", "/api/v1/groups/"+groupID, nil, aliceToken)
+
+	sendAndCountNotifications := func(content string) (bobNotified, carolNotified int64) {
+		w := makeRequest("POST", "/api/v1/groups/messages", map[string]interface{}{
+			"group_id": groupID,
+			"content":  content,
+		}, aliceToken)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		messageID := response["data"].(map[string]interface{})["id"].(string)
+
+		db.Model(&models.Notification{}).Where("user_id = ? AND reference_id = ?", bobID, messageID).Count(&bobNotified)
+		db.Model(&models.Notification{}).Where("user_id = ? AND reference_id = ?", carolID, messageID).Count(&carolNotified)
+		return bobNotified, carolNotified
+	}
+
+	t.Run("MutedMemberSkipsPush", func(t *testing.T) {
+		w := makeRequest("PUT", "/api/v1/groups/"+groupID+"/mute", map[string]interface{}{"until": nil}, bobToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		bobNotified, carolNotified := sendAndCountNotifications("Bob is muted")
+		assert.Equal(t, int64(0), bobNotified)
+		assert.Equal(t, int64(1), carolNotified)
+		assert.Equal(t, 0, fakeFCM.pushedTo(bobDevice))
+		assert.Equal(t, 1, fakeFCM.pushedTo(carolDevice))
+	})
+
+	t.Run("MuteStateInGroupList", func(t *testing.T) {
+		w := makeRequest("GET", "/api/v2/groups/my", nil, bobToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		found := false
+		for _, g := range response["data"].([]interface{}) {
+			group := g.(map[string]interface{})
+			if group["id"] == groupID {
+				found = true
+				assert.Equal(t, true, group["is_muted"])
+				assert.Nil(t, group["muted_until"])
+			}
+		}
+		assert.True(t, found)
+	})
+
+	t.Run("TimedMute", func(t *testing.T) {
+		until := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+		w := makeRequest("PUT", "/api/v1/groups/"+groupID+"/mute", map[string]interface{}{"until": until}, carolToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		_, carolNotified := sendAndCountNotifications("Carol is muted for an hour")
+		assert.Equal(t, int64(0), carolNotified)
+		assert.Equal(t, 1, fakeFCM.pushedTo(carolDevice))
+
+		past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+		w = makeRequest("PUT", "/api/v1/groups/"+groupID+"/mute", map[string]interface{}{"until": past}, carolToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Unmute", func(t *testing.T) {
+		w := makeRequest("DELETE", "/api/v1/groups/"+groupID+"/mute", nil, bobToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = makeRequest("DELETE", "/api/v1/groups/"+groupID+"/mute", nil, carolToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		bobNotified, carolNotified := sendAndCountNotifications("Everyone is back")
+		assert.Equal(t, int64(1), bobNotified)
+		assert.Equal(t, int64(1), carolNotified)
+		assert.Equal(t, 1, fakeFCM.pushedTo(bobDevice))
+		assert.Equal(t, 2, fakeFCM.pushedTo(carolDevice))
+	})
+
+	t.Run("NonMemberCannotMute", func(t *testing.T) {
+		outsider := &models.User{Username: "mute_outsider", Email: "mute_outsider@example.com", Password: "not-a-real-hash"}
+		assert.NoError(t, testUserRepo.Create(outsider))
+		token, _ := utils.GenerateToken(outsider.ID, outsider.Username, outsider.Email)
+
+		w := makeRequest("PUT", "/api/v1/groups/"+groupID+"/mute", nil, token)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
+
+// ============================================================================
+// IDEMPOTENCY KEY TESTS
+// ============================================================================
+
+func TestSendMessageIdempotency(t *testing.T) {
+	key := map[string]string{"Idempotency-Key": "send-" + uuid.New().String()}
+	body := map[string]interface{}{
+		"receiver_id": bobID,
+		"content":     "Idempotent hello " + uuid.New().String(),
+	}
+
+	first := makeRequestWithHeaders("POST", "/api/v1/messages", body, aliceToken, key)
+	assert.Equal(t, http.StatusCreated, first.Code)
+
+	second := makeRequestWithHeaders("POST", "/api/v1/messages", body, aliceToken, key)
+	assert.Equal(t, http.StatusOK, second.Code)
+	assert.JSONEq(t, first.Body.String(), second.Body.String())
+
+	// Content is encrypted at rest, so count the rows sent since the first request instead
+	var count int64
+	db.Model(&models.Message{}).
+		Where("sender_id = ? AND receiver_id = ? AND created_at >= (SELECT created_at FROM messages WHERE id = ?)",
+			aliceID, bobID, parseMessageID(t, first)).
+		Count(&count)
+	assert.Equal(t, int64(1), count)
+
+	t.Run("ResponseEncryptedAtRest", func(t *testing.T) {
+		var record models.IdempotencyKey
+		err := db.Where("key = ?", aliceID+":"+key["Idempotency-Key"]).First(&record).Error
+		if !assert.NoError(t, err) || !assert.NotNil(t, record.ResponseBody) {
+			return
+		}
+		assert.NotContains(t, *record.ResponseBody, body["content"])
+	})
+
+	t.Run("KeysAreScopedPerUser", func(t *testing.T) {
+		w := makeRequestWithHeaders("POST", "/api/v1/messages", map[string]interface{}{
+			"receiver_id": aliceID,
+			"content":     "Same key, different user",
+		}, bobToken, key)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.NotEqual(t, parseMessageID(t, first), parseMessageID(t, w))
+	})
+
+	t.Run("KeyTooLong", func(t *testing.T) {
+		w := makeRequestWithHeaders("POST", "/api/v1/messages", body, aliceToken, map[string]string{
+			"Idempotency-Key": strings.Repeat("k", 65),
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("ConcurrentRetries", func(t *testing.T) {
+		// Retries arriving while the first request is still in flight must not send again
+		key := map[string]string{"Idempotency-Key": "concurrent-" + uuid.New().String()}
+		body := map[string]interface{}{
+			"receiver_id": bobID,
+			"content":     "Concurrent hello",
+		}
+
+		const attempts = 8
+		responses := make([]*httptest.ResponseRecorder, attempts)
+		var wg sync.WaitGroup
+		for i := range responses {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				responses[i] = makeRequestWithHeaders("POST", "/api/v1/messages", body, aliceToken, key)
+			}(i)
+		}
+		wg.Wait()
+
+		created := 0
+		for _, w := range responses {
+			switch w.Code {
+			case http.StatusCreated:
+				created++
+			case http.StatusOK, http.StatusConflict:
+			default:
+				t.Errorf("unexpected status %d: %s", w.Code, w.Body.String())
+			}
+		}
+		assert.Equal(t, 1, created)
+
+		// Once answered, the key replays the response
+		w := makeRequestWithHeaders("POST", "/api/v1/messages", body, aliceToken, key)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("FailedRequestCanBeRetried", func(t *testing.T) {
+		key := map[string]string{"Idempotency-Key": "retry-" + uuid.New().String()}
+
+		w := makeRequestWithHeaders("POST", "/api/v1/messages", map[string]interface{}{
+			"receiver_id": bobID,
+		}, aliceToken, key)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = makeRequestWithHeaders("POST", "/api/v1/messages", map[string]interface{}{
+			"receiver_id": bobID,
+			"content":     "Second try",
+		}, aliceToken, key)
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+
+	t.Run("WithoutKey", func(t *testing.T) {
+		w1 := makeRequest("POST", "/api/v1/messages", body, aliceToken)
+		w2 := makeRequest("POST", "/api/v1/messages", body, aliceToken)
+		assert.Equal(t, http.StatusCreated, w1.Code)
+		assert.Equal(t, http.StatusCreated, w2.Code)
+		assert.NotEqual(t, parseMessageID(t, w1), parseMessageID(t, w2))
+	})
+}
+
+func TestSendGroupMessageIdempotency(t *testing.T) {
+	w := makeRequest("POST", "/api/v1/groups", map[string]interface{}{
+		"name":       "Idempotency Group",
+		"type":       "private",
+		"member_ids": []string{bobID},
+	}, aliceToken)
+	if !assert.Equal(t, http.StatusCreated, w.Code) {
+		t.FailNow()
+	}
+	var created map[string]interface{}
+	parseResponse(w, &created)
+	groupID := created["data"].(map[string]interface{})["id"].(string)
+	defer makeRequest("DELETE", "/api/v1/groups/"+groupID, nil, aliceToken)
+
+	key := map[string]string{"Idempotency-Key": "group-" + uuid.New().String()}
+	body := map[string]interface{}{
+		"group_id": groupID,
+		"content":  "Idempotent group hello",
+	}
+
+	first := makeRequestWithHeaders("POST", "/api/v1/groups/messages", body, aliceToken, key)
+	assert.Equal(t, http.StatusCreated, first.Code)
+
+	second := makeRequestWithHeaders("POST", "/api/v1/groups/messages", body, aliceToken, key)
+	assert.Equal(t, http.StatusOK, second.Code)
+	assert.JSONEq(t, first.Body.String(), second.Body.String())
+
+	var count int64
+	db.Model(&models.GroupMessage{}).Where("group_id = ?", groupID).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
+
+// parseMessageID extracts data.id from a send response
+func parseMessageID(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	var response map[string]interface{}
+	if err := parseResponse(w, &response); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	return response["data"].(map[string]interface{})["id"].(string)
+}
+
+// ============================================================================
+// CONVERSATION DRAFT TESTS
+// ============================================================================
+
+// getDraft fetches the draft content for a conversation
+func getDraft(t *testing.T, path, token string) string {
+	t.Helper()
+
+	w := makeRequest("GET", path, nil, token)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	parseResponse(w, &response)
+	return response["data"].(map[string]interface{})["content"].(string)
+}
+
+func TestConversationDrafts(t *testing.T) {
+	draftPath := "/api/v1/messages/conversation/" + bobID + "/draft"
+
+	t.Run("SaveAndRetrieve", func(t *testing.T) {
+		w := makeRequest("PUT", draftPath, map[string]interface{}{"content": "Half-written reply"}, aliceToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "Half-written reply", getDraft(t, draftPath, aliceToken))
+
+		// Saving again replaces the draft
+		w = makeRequest("PUT", draftPath, map[string]interface{}{"content": "Rewritten reply"}, aliceToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "Rewritten reply", getDraft(t, draftPath, aliceToken))
+
+		var stored models.ConversationDraft
+		db.Where("user_id = ? AND partner_id = ?", aliceID, bobID).First(&stored)
+		assert.NotEqual(t, "Rewritten reply", stored.Content, "draft must be encrypted at rest")
+
+		// Bob does not see Alice's draft
+		assert.Equal(t, "", getDraft(t, "/api/v1/messages/conversation/"+aliceID+"/draft", bobToken))
+	})
+
+	t.Run("IncludedInConversationList", func(t *testing.T) {
+		w := makeRequest("GET", "/api/v1/messages/conversations", nil, aliceToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response map[string]interface{}
+		parseResponse(w, &response)
+
+		found := false
+		for _, item := range response["data"].([]interface{}) {
+			summary := item.(map[string]interface{})
+			if summary["user"].(map[string]interface{})["id"] == bobID {
+				found = true
+				assert.Equal(t, "Rewritten reply", summary["draft"])
+			}
+		}
+		assert.True(t, found, "conversation with bob not listed")
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		w := makeRequest("DELETE", draftPath, nil, aliceToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "", getDraft(t, draftPath, aliceToken))
+	})
+
+	t.Run("DeletedOnSend", func(t *testing.T) {
+		w := makeRequest("PUT", draftPath, map[string]interface{}{"content": "About to send"}, aliceToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+			"receiver_id": bobID,
+			"content":     "About to send",
+		}, aliceToken)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		assert.Equal(t, "", getDraft(t, draftPath, aliceToken))
+	})
+
+	t.Run("UnknownPartner", func(t *testing.T) {
+		w := makeRequest("PUT", "/api/v1/messages/conversation/"+uuid.New().String()+"/draft",
+			map[string]interface{}{"content": "Nobody"}, aliceToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+// ============================================================================
+// GROUP DRAFT TESTS
+// ============================================================================
+
+func TestGroupDrafts(t *testing.T) {
+	ensureCarol(t)
+
+	w := makeRequest("POST", "/api/v1/groups", map[string]interface{}{
+		"name":       "Draft Group",
+		"type":       "private",
+		"member_ids": []string{bobID},
+	}, aliceToken)
+	if !assert.Equal(t, http.StatusCreated, w.Code) {
+		t.FailNow()
+	}
+	var created map[string]interface{}
+	parseResponse(w, &created)
+	groupID := created["data"].(map[string]interface{})["id"].(string)
+	defer makeRequest("DELETE", "/api/v1/groups/"+groupID, nil, aliceToken)
+
+	draftPath := "/api/v1/groups/" + groupID + "/draft"
+
+	t.Run("IsolatedPerMember", func(t *testing.T) {
+		w := makeRequest("PUT", draftPath, map[string]interface{}{"content": "Alice's group draft"}, aliceToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		assert.Equal(t, "Alice's group draft", getDraft(t, draftPath, aliceToken))
+		assert.Equal(t, "", getDraft(t, draftPath, bobToken))
+
+		w = makeRequest("PUT", draftPath, map[string]interface{}{"content": "Bob's group draft"}, bobToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "Alice's group draft", getDraft(t, draftPath, aliceToken))
+		assert.Equal(t, "Bob's group draft", getDraft(t, draftPath, bobToken))
+	})
+
+	t.Run("IncludedInV2Group", func(t *testing.T) {
+		w := makeRequest("GET", "/api/v2/groups/"+groupID, nil, aliceToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		assert.Equal(t, "Alice's group draft", response["data"].(map[string]interface{})["draft"])
+	})
+
+	t.Run("NonMemberRejected", func(t *testing.T) {
+		w := makeRequest("PUT", draftPath, map[string]interface{}{"content": "Not mine"}, carolToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = makeRequest("GET", draftPath, nil, carolToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = makeRequest("DELETE", draftPath, nil, carolToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("TooLong", func(t *testing.T) {
+		w := makeRequest("PUT", draftPath, map[string]interface{}{
+			"content": strings.Repeat("a", 10001),
+		}, aliceToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "Alice's group draft", getDraft(t, draftPath, aliceToken))
+	})
+
+	t.Run("DeletedOnSend", func(t *testing.T) {
+		w := makeRequest("POST", "/api/v1/groups/messages", map[string]interface{}{
+			"group_id": groupID,
+			"content":  "Alice's group draft",
+		}, aliceToken)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		assert.Equal(t, "", getDraft(t, draftPath, aliceToken))
+		assert.Equal(t, "Bob's group draft", getDraft(t, draftPath, bobToken))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		w := makeRequest("DELETE", draftPath, nil, bobToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "", getDraft(t, draftPath, bobToken))
+	})
+}
+
+// ============================================================================
+// MESSAGE PRIORITY & ADMIN BROADCAST TESTS
+// ============================================================================
+
+// signupUser registers a fresh user and returns their token and ID
+func signupUser(t *testing.T, username string) (token, id string) {
+	t.Helper()
+
+	w := makeRequest("POST", "/api/v1/auth/signup", map[string]interface{}{
+		"username": username,
+		"email":    username + "@example.com",
+		"password": "Test1234!",
+	}, "")
+	if !assert.Equal(t, http.StatusCreated, w.Code) {
+		t.FailNow()
+	}
+
+	var response map[string]interface{}
+	parseResponse(w, &response)
+	data := response["data"].(map[string]interface{})
+	return data["token"].(string), data["user"].(map[string]interface{})["id"].(string)
+}
+
+// signupAdmin registers a user and promotes them to the admin role
+func signupAdmin(t *testing.T) (token, id string) {
+	t.Helper()
+
+	token, id = signupUser(t, "admin_test")
+	if err := db.Model(&models.User{}).Where("id = ?", id).Update("role", models.UserRoleAdmin).Error; err != nil {
+		t.Fatalf("failed to promote admin: %v", err)
+	}
+	return token, id
+}
+
+func TestMessagePriority(t *testing.T) {
+	adminToken, _ := signupAdmin(t)
+
+	sendWithPriority := func(token, priority string) *httptest.ResponseRecorder {
+		return makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+			"receiver_id": bobID,
+			"content":     "Priority " + priority,
+			"priority":    priority,
+		}, token)
+	}
+
+	t.Run("RegularUserForcedToNormal", func(t *testing.T) {
+		w := sendWithPriority(aliceToken, "urgent")
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		data := response["data"].(map[string]interface{})
+		assert.Equal(t, "normal", data["priority"])
+
+		var stored models.Message
+		db.First(&stored, "id = ?", data["id"])
+		assert.Equal(t, models.MessagePriorityNormal, stored.Priority)
+	})
+
+	t.Run("AdminCanSetPriority", func(t *testing.T) {
+		w := sendWithPriority(adminToken, "high")
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		assert.Equal(t, "high", response["data"].(map[string]interface{})["priority"])
+
+		w = sendWithPriority(adminToken, "critical")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("BroadcastRequiresAdmin", func(t *testing.T) {
+		w := makeRequest("POST", "/api/admin/v1/broadcast", map[string]interface{}{
+			"content":  "Not allowed",
+			"priority": "urgent",
+		}, aliceToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Broadcast", func(t *testing.T) {
+		aliceWS := dialWebSocket(t, aliceToken)
+		defer aliceWS.close()
+		waitForOnline(t, aliceID, true)
+
+		var userCount int64
+		db.Model(&models.User{}).Count(&userCount)
+
+		w := makeRequest("POST", "/api/admin/v1/broadcast", map[string]interface{}{
+			"content":  "Maintenance tonight",
+			"priority": "urgent",
+		}, adminToken)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		assert.Equal(t, float64(userCount-1), response["recipients"])
+
+		event := aliceWS.waitForEvent(t, "system_message", 2*time.Second)
+		assert.Equal(t, "Maintenance tonight", event["content"])
+		assert.Equal(t, "urgent", event["data"].(map[string]interface{})["priority"])
+
+		w = makeRequest("GET", "/api/v1/messages/conversation/"+event["sender_id"].(string), nil, aliceToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+		parseResponse(w, &response)
+		messages := response["data"].([]interface{})
+		if assert.NotEmpty(t, messages) {
+			latest := messages[0].(map[string]interface{})
+			assert.Equal(t, "Maintenance tonight", latest["content"])
+			assert.Equal(t, "urgent", latest["priority"])
+		}
+	})
+}
+
+// ============================================================================
+// GROUP ROLE HIERARCHY TESTS
+// ============================================================================
+
+// memberRole returns a user's role in a group straight from the database
+func memberRole(t *testing.T, groupID, userID string) models.MemberRole {
+	t.Helper()
+
+	var member models.GroupMember
+	if err := db.Where("group_id = ? AND user_id = ?", groupID, userID).First(&member).Error; err != nil {
+		t.Fatalf("member not found: %v", err)
+	}
+	return member.Role
+}
+
+func TestGroupRoleHierarchy(t *testing.T) {
+	ensureCarol(t)
+
+	w := makeRequest("POST", "/api/v1/groups", map[string]interface{}{
+		"name":       "Hierarchy Group",
+		"type":       "private",
+		"member_ids": []string{bobID, carolID},
+	}, aliceToken)
+	if !assert.Equal(t, http.StatusCreated, w.Code) {
+		t.FailNow()
+	}
+	var created map[string]interface{}
+	parseResponse(w, &created)
+	groupID := created["data"].(map[string]interface{})["id"].(string)
+
+	t.Run("CreatorIsOwner", func(t *testing.T) {
+		assert.Equal(t, models.MemberRoleOwner, memberRole(t, groupID, aliceID))
+		assert.Equal(t, models.MemberRoleMember, memberRole(t, groupID, bobID))
+	})
+
+	t.Run("RoleColumnIsChecked", func(t *testing.T) {
+		err := db.Model(&models.GroupMember{}).
+			Where("group_id = ? AND user_id = ?", groupID, bobID).
+			Update("role", "superuser").Error
+		assert.Error(t, err)
+	})
+
+	t.Run("MemberCannotTransfer", func(t *testing.T) {
+		w := makeRequest("POST", "/api/v1/groups/"+groupID+"/transfer", map[string]string{"user_id": bobID}, bobToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("TransferOwnership", func(t *testing.T) {
+		w := makeRequest("POST", "/api/v1/groups/"+groupID+"/transfer", map[string]string{"user_id": bobID}, aliceToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		assert.Equal(t, models.MemberRoleOwner, memberRole(t, groupID, bobID))
+		assert.Equal(t, models.MemberRoleAdmin, memberRole(t, groupID, aliceID))
+	})
+
+	t.Run("AdminCannotDeleteGroup", func(t *testing.T) {
+		w := makeRequest("DELETE", "/api/v1/groups/"+groupID, nil, aliceToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("AdminCannotRemoveOwner", func(t *testing.T) {
+		w := makeRequest("DELETE", "/api/v1/groups/"+groupID+"/members/"+bobID, nil, aliceToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("AdminCanRemoveMember", func(t *testing.T) {
+		w := makeRequest("DELETE", "/api/v1/groups/"+groupID+"/members/"+carolID, nil, aliceToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("AdminCanAddMember", func(t *testing.T) {
+		w := makeRequest("POST", "/api/v1/groups/"+groupID+"/members", map[string]string{"user_id": carolID}, aliceToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("OwnerCanRemoveAdmin", func(t *testing.T) {
+		w := makeRequest("DELETE", "/api/v1/groups/"+groupID+"/members/"+aliceID, nil, bobToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("OwnerCanDeleteGroup", func(t *testing.T) {
+		w := makeRequest("DELETE", "/api/v1/groups/"+groupID, nil, bobToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		assertGroupRowsDeleted(t, groupID)
+	})
+}
+
+// ============================================================================
+// GROUP PERMISSIONS TESTS
+// ============================================================================
+
+func TestGroupPermissionsMatrix(t *testing.T) {
+	ensureCarol(t)
+
+	w := makeRequest("POST", "/api/v1/groups", map[string]interface{}{
+		"name":       "Announcements",
+		"type":       "private",
+		"member_ids": []string{bobID},
+	}, aliceToken)
+	if !assert.Equal(t, http.StatusCreated, w.Code) {
+		t.FailNow()
+	}
+	var created map[string]interface{}
+	parseResponse(w, &created)
+	groupID := created["data"].(map[string]interface{})["id"].(string)
+	defer makeRequest("DELETE", "/api/v1/groups/"+groupID, nil, aliceToken)
+
+	permissionsPath := "/api/v1/groups/" + groupID + "/permissions"
+	sendAs := func(token string) int {
+		return makeRequest("POST", "/api/v1/groups/messages", map[string]interface{}{
+			"group_id": groupID,
+			"content":  "Announcement",
+		}, token).Code
+	}
+
+	t.Run("Defaults", func(t *testing.T) {
+		w := makeRequest("GET", permissionsPath, nil, bobToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		data := response["data"].(map[string]interface{})
+		assert.Equal(t, "member", data["send_messages"])
+		assert.Equal(t, "member", data["add_members"])
+		assert.Equal(t, "member", data["pin_messages"])
+		assert.Equal(t, "member", data["edit_group_info"])
+
+		assert.Equal(t, http.StatusCreated, sendAs(bobToken))
+	})
+
+	t.Run("NonMemberCannotRead", func(t *testing.T) {
+		w := makeRequest("GET", permissionsPath, nil, carolToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("OnlyOwnerCanUpdate", func(t *testing.T) {
+		w := makeRequest("PATCH", permissionsPath, map[string]interface{}{"send_messages": "admin"}, bobToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = makeRequest("PATCH", permissionsPath, map[string]interface{}{"send_messages": "moderator"}, aliceToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("AdminsOnlyCanPost", func(t *testing.T) {
+		w := makeRequest("PATCH", permissionsPath, map[string]interface{}{"send_messages": "admin"}, aliceToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		data := response["data"].(map[string]interface{})
+		assert.Equal(t, "admin", data["send_messages"])
+		assert.Equal(t, "member", data["add_members"], "omitted fields are unchanged")
+
+		assert.Equal(t, http.StatusForbidden, sendAs(bobToken))
+		assert.Equal(t, http.StatusCreated, sendAs(aliceToken))
+	})
+
+	t.Run("AdminsOnlyCanAddMembers", func(t *testing.T) {
+		w := makeRequest("PATCH", permissionsPath, map[string]interface{}{"add_members": "admin"}, aliceToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = makeRequest("POST", "/api/v1/groups/"+groupID+"/members", map[string]string{"user_id": carolID}, bobToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = makeRequest("PATCH", permissionsPath, map[string]interface{}{"add_members": "member"}, aliceToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = makeRequest("POST", "/api/v1/groups/"+groupID+"/members", map[string]string{"user_id": carolID}, bobToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+// ============================================================================
+// TOTAL UNREAD COUNT TESTS
+// ============================================================================
+
+func TestUnreadTotal(t *testing.T) {
+	danaToken, danaID := signupUser(t, "dana_unread")
+
+	danaWS := dialWebSocket(t, danaToken)
+	defer danaWS.close()
+	waitForOnline(t, danaID, true)
+
+	getTotals := func() map[string]interface{} {
+		w := makeRequest("GET", "/api/v1/messages/unread/total", nil, danaToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		return response
+	}
+
+	t.Run("DirectMessage", func(t *testing.T) {
+		w := makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+			"receiver_id": danaID,
+			"content":     "Hi Dana",
+		}, aliceToken)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		event := danaWS.waitForEvent(t, "unread_count_updated", 2*time.Second)
+		data := event["data"].(map[string]interface{})
+		assert.Equal(t, float64(1), data["direct"])
+		assert.Equal(t, float64(0), data["groups"])
+	})
+
+	var groupID string
+	t.Run("GroupMessages", func(t *testing.T) {
+		w := makeRequest("POST", "/api/v1/groups", map[string]interface{}{
+			"name":       "Unread Group",
+			"type":       "private",
+			"member_ids": []string{danaID},
+		}, aliceToken)
+		if !assert.Equal(t, http.StatusCreated, w.Code) {
+			t.FailNow()
+		}
+		var created map[string]interface{}
+		parseResponse(w, &created)
+		groupID = created["data"].(map[string]interface{})["id"].(string)
+
+		for _, content := range []string{"First", "Second"} {
+			w := makeRequest("POST", "/api/v1/groups/messages", map[string]interface{}{
+				"group_id": groupID,
+				"content":  content,
+			}, aliceToken)
+			assert.Equal(t, http.StatusCreated, w.Code)
+		}
+
+		var data map[string]interface{}
+		for i := 0; i < 2; i++ {
+			data = danaWS.waitForEvent(t, "unread_count_updated", 2*time.Second)["data"].(map[string]interface{})
+		}
+		assert.Equal(t, float64(2), data["groups"])
+
+		totals := getTotals()
+		assert.Equal(t, float64(1), totals["direct"])
+		assert.Equal(t, float64(2), totals["groups"])
+		assert.Equal(t, float64(3), totals["total"])
+	})
+	defer makeRequest("DELETE", "/api/v1/groups/"+groupID, nil, aliceToken)
+
+	t.Run("ConversationListTotal", func(t *testing.T) {
+		w := makeRequest("GET", "/api/v1/messages/conversations", nil, danaToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		assert.Equal(t, float64(3), response["total_unread"])
+	})
+
+	t.Run("ReadingGroupClearsGroupCount", func(t *testing.T) {
+		w := makeRequest("GET", "/api/v1/groups/"+groupID+"/messages", nil, danaToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		data := danaWS.waitForEvent(t, "unread_count_updated", 2*time.Second)["data"].(map[string]interface{})
+		assert.Equal(t, float64(0), data["groups"])
+		assert.Equal(t, float64(1), data["direct"])
+	})
+
+	t.Run("MarkAsReadClearsDirectCount", func(t *testing.T) {
+		w := makeRequest("PUT", "/api/v1/messages/read/"+aliceID, nil, danaToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		data := danaWS.waitForEvent(t, "unread_count_updated", 2*time.Second)["data"].(map[string]interface{})
+		assert.Equal(t, float64(0), data["direct"])
+
+		totals := getTotals()
+		assert.Equal(t, float64(0), totals["total"])
+	})
+}
+
+// ============================================================================
+// TRANSLATION RELOAD TESTS
+// ============================================================================
+
+func TestReloadTranslationsEndpoint(t *testing.T) {
+	w := makeRequest("GET", "/api/admin/v1/i18n/reload", nil, aliceToken)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	adminToken, adminID := signupUser(t, "i18n_admin")
+	if err := db.Model(&models.User{}).Where("id = ?", adminID).Update("role", models.UserRoleAdmin).Error; err != nil {
+		t.Fatalf("failed to promote admin: %v", err)
+	}
+
+	w = makeRequest("GET", "/api/admin/v1/i18n/reload", nil, adminToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	parseResponse(w, &response)
+	assert.Contains(t, response["data"], "en")
+}
+
+// ============================================================================
+// MESSAGE SEARCH TESTS
+// ============================================================================
+
+func TestMessageSearchDateRange(t *testing.T) {
+	partnerToken, partnerID := signupUser(t, "search_partner")
+
+	// Seed one matching message per day in January 2024
+	days := []string{"2024-01-05T12:00:00Z", "2024-01-15T12:00:00Z", "2024-01-25T12:00:00Z"}
+	for i, day := range days {
+		token, receiver := aliceToken, partnerID
+		if i%2 == 1 {
+			token, receiver = partnerToken, aliceID
+		}
+		w := makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+			"receiver_id": receiver,
+			"content":     "Quarterly report " + day,
+		}, token)
+		if !assert.Equal(t, http.StatusCreated, w.Code) {
+			t.FailNow()
+		}
+		createdAt, _ := time.Parse(time.RFC3339, day)
+		db.Model(&models.Message{}).Where("id = ?", parseMessageID(t, w)).Update("created_at", createdAt)
+	}
+	makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+		"receiver_id": partnerID,
+		"content":     "Unrelated",
+	}, aliceToken)
+
+	search := func(query string) (int, []interface{}) {
+		w := makeRequest("GET", "/api/v1/messages/conversation/"+partnerID+"/search?"+query, nil, aliceToken)
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		data, _ := response["data"].([]interface{})
+		return w.Code, data
+	}
+
+	t.Run("NoRange", func(t *testing.T) {
+		code, data := search("q=quarterly")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Len(t, data, 3)
+	})
+
+	t.Run("AfterAndBefore", func(t *testing.T) {
+		code, data := search("q=report&after=2024-01-10T00:00:00Z&before=2024-01-20T00:00:00Z")
+		assert.Equal(t, http.StatusOK, code)
+		if assert.Len(t, data, 1) {
+			assert.Equal(t, "Quarterly report 2024-01-15T12:00:00Z", data[0].(map[string]interface{})["content"])
+		}
+	})
+
+	t.Run("AfterOnly", func(t *testing.T) {
+		code, data := search("q=report&after=2024-01-10T00:00:00Z")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Len(t, data, 2)
+	})
+
+	t.Run("InvalidFormat", func(t *testing.T) {
+		code, _ := search("q=report&before=2024-01-20")
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("AfterNotBeforeBefore", func(t *testing.T) {
+		code, _ := search("q=report&after=2024-01-20T00:00:00Z&before=2024-01-10T00:00:00Z")
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("GroupMessages", func(t *testing.T) {
+		w := makeRequest("POST", "/api/v1/groups", map[string]interface{}{
+			"name":       "Search Group",
+			"type":       "private",
+			"member_ids": []string{partnerID},
+		}, aliceToken)
+		if !assert.Equal(t, http.StatusCreated, w.Code) {
+			t.FailNow()
+		}
+		var created map[string]interface{}
+		parseResponse(w, &created)
+		groupID := created["data"].(map[string]interface{})["id"].(string)
+		defer makeRequest("DELETE", "/api/v1/groups/"+groupID, nil, aliceToken)
+
+		for _, day := range days {
+			w := makeRequest("POST", "/api/v1/groups/messages", map[string]interface{}{
+				"group_id": groupID,
+				"content":  "Standup notes " + day,
+			}, aliceToken)
+			if !assert.Equal(t, http.StatusCreated, w.Code) {
+				t.FailNow()
+			}
+			createdAt, _ := time.Parse(time.RFC3339, day)
+			db.Model(&models.GroupMessage{}).Where("id = ?", parseMessageID(t, w)).Update("created_at", createdAt)
+		}
+
+		path := "/api/v1/groups/" + groupID + "/messages/search?q=standup&before=2024-01-20T00:00:00Z"
+		w = makeRequest("GET", path, nil, partnerToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		assert.Len(t, response["data"], 2)
+
+		ensureCarol(t)
+		w = makeRequest("GET", path, nil, carolToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
+
+// ============================================================================
+// READ-YOUR-WRITES TESTS
+// ============================================================================
+
+func TestReadYourWritesHeader(t *testing.T) {
+	w := makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+		"receiver_id": bobID,
+		"content":     "Read your writes",
+	}, aliceToken)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "true", w.Header().Get(middleware.ReadYourWritesHeader))
+	messageID := parseMessageID(t, w)
+
+	w = makeRequestWithHeaders("GET", "/api/v1/messages/conversation/"+bobID+"?limit=1", nil, aliceToken, map[string]string{
+		middleware.ReadYourWritesHeader: "true",
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(middleware.ReadYourWritesHeader))
+
+	var response map[string]interface{}
+	parseResponse(w, &response)
+	data := response["data"].([]interface{})
+	if assert.Len(t, data, 1) {
+		assert.Equal(t, messageID, data[0].(map[string]interface{})["id"])
+	}
+}
+
+// ============================================================================
+// NOTIFICATION TESTS
+// ============================================================================
+
+// createNotifications inserts count unread notifications for userID
+func createNotifications(t *testing.T, userID string, count int) []uuid.UUID {
+	t.Helper()
+
+	ids := make([]uuid.UUID, 0, count)
+	for i := 0; i < count; i++ {
+		notification := models.Notification{
+			UserID:  uuid.MustParse(userID),
+			Type:    models.NotificationTypeSystem,
+			Content: "Notification",
+		}
+		if err := db.Create(&notification).Error; err != nil {
+			t.Fatalf("failed to create notification: %v", err)
+		}
+		ids = append(ids, notification.ID)
+	}
+	return ids
+}
+
+func TestMarkBatchAsRead(t *testing.T) {
+	repo := repositories.NewNotificationRepository(db)
+	aliceNotifications := createNotifications(t, aliceID, 3)
+	bobNotifications := createNotifications(t, bobID, 2)
+
+	t.Run("PartialMatchCountsOnlyOwnUnread", func(t *testing.T) {
+		ids := []uuid.UUID{aliceNotifications[0], aliceNotifications[1], bobNotifications[0], uuid.New()}
+		marked, err := repo.MarkBatchAsRead(uuid.MustParse(aliceID), ids)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), marked)
+
+		var bobUnread int64
+		db.Model(&models.Notification{}).Where("id IN ? AND read_status = ?", bobNotifications, false).Count(&bobUnread)
+		assert.Equal(t, int64(2), bobUnread)
+	})
+
+	t.Run("AlreadyReadNotCounted", func(t *testing.T) {
+		marked, err := repo.MarkBatchAsRead(uuid.MustParse(aliceID), aliceNotifications)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), marked)
+	})
+
+	t.Run("Endpoint", func(t *testing.T) {
+		w := makeRequest("POST", "/api/v1/notifications/batch-read", map[string]interface{}{
+			"ids": []uuid.UUID{bobNotifications[0], aliceNotifications[0]},
+		}, bobToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		assert.Equal(t, float64(1), response["marked"])
+	})
+
+	t.Run("TooManyIDs", func(t *testing.T) {
+		ids := make([]uuid.UUID, 101)
+		for i := range ids {
+			ids[i] = uuid.New()
+		}
+		w := makeRequest("POST", "/api/v1/notifications/batch-read", map[string]interface{}{
+			"ids": ids,
+		}, bobToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("MarkAll", func(t *testing.T) {
+		w := makeRequest("PUT", "/api/v1/notifications/read-all", nil, bobToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var bobUnread int64
+		db.Model(&models.Notification{}).Where("user_id = ? AND read_status = ?", bobID, false).Count(&bobUnread)
+		assert.Equal(t, int64(0), bobUnread)
+	})
+}
+
+// ============================================================================
+// BIO TESTS
+// ============================================================================
+
+// signupWithBio registers a user with a profile bio
+func signupWithBio(t *testing.T, username, bio string) (token, id string) {
+	t.Helper()
+
+	w := makeRequest("POST", "/api/v1/auth/signup", map[string]interface{}{
+		"username": username,
+		"email":    username + "@example.com",
+		"password": "Test1234!",
+		"bio":      bio,
+	}, "")
+	if !assert.Equal(t, http.StatusCreated, w.Code) {
+		t.FailNow()
+	}
+
+	var response map[string]interface{}
+	parseResponse(w, &response)
+	data := response["data"].(map[string]interface{})
+	return data["token"].(string), data["user"].(map[string]interface{})["id"].(string)
+}
+
+func TestSearchByBio(t *testing.T) {
+	_, userID := signupWithBio(t, "bio_search", "Bird watcher and amateur astronomer")
+
+	w := makeRequest("GET", "/api/v1/users/search?q=AMATEUR%20astronomer", nil, bobToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	parseResponse(w, &response)
+	data := response["data"].([]interface{})
+	if assert.Len(t, data, 1) {
+		user := data[0].(map[string]interface{})
+		assert.Equal(t, userID, user["id"])
+		assert.Equal(t, "Bird watcher and amateur astronomer", user["bio"])
+	}
+}
+
+func TestSignupBioTooLong(t *testing.T) {
+	w := makeRequest("POST", "/api/v1/auth/signup", map[string]interface{}{
+		"username": "long_bio",
+		"email":    "long_bio@example.com",
+		"password": "Test1234!",
+		"bio":      strings.Repeat("é", 501),
+	}, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// ============================================================================
+// DELIVERY TESTS
+// ============================================================================
+
+func TestBackfillDeliveredAt(t *testing.T) {
+	readAt := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	read := models.Message{
+		SenderID:   uuid.MustParse(aliceID),
+		ReceiverID: uuid.MustParse(bobID),
+		Content:    "encrypted-placeholder",
+		IsRead:     true,
+		ReadAt:     &readAt,
+	}
+	unread := models.Message{
+		SenderID:   uuid.MustParse(aliceID),
+		ReceiverID: uuid.MustParse(bobID),
+		Content:    "encrypted-placeholder",
+	}
+	assert.NoError(t, db.Create(&read).Error)
+	assert.NoError(t, db.Create(&unread).Error)
+	defer db.Delete(&models.Message{}, []uuid.UUID{read.ID, unread.ID})
+
+	// Replay the backfill as if the database predated it
+	db.Where("name = ?", "002_backfill_message_delivered_at.sql").Delete(&migrations.SchemaMigration{})
+	assert.NoError(t, migrations.Run(db))
+
+	db.First(&read, "id = ?", read.ID)
+	db.First(&unread, "id = ?", unread.ID)
+	if assert.NotNil(t, read.DeliveredAt) {
+		assert.True(t, readAt.Equal(*read.DeliveredAt))
+	}
+	assert.Nil(t, unread.DeliveredAt)
+
+	// Already-applied migrations are skipped
+	var count int64
+	db.Model(&migrations.SchemaMigration{}).Where("name = ?", "002_backfill_message_delivered_at.sql").Count(&count)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestConversationLastMessageStatus(t *testing.T) {
+	partnerToken, partnerID := signupUser(t, "status_partner")
+
+	status := func() string {
+		w := makeRequest("GET", "/api/v1/messages/conversations", nil, aliceToken)
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		for _, item := range response["data"].([]interface{}) {
+			summary := item.(map[string]interface{})
+			if summary["user"].(map[string]interface{})["id"] == partnerID {
+				return summary["last_message_status"].(string)
+			}
+		}
+		return ""
+	}
+
+	makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+		"receiver_id": partnerID,
+		"content":     "Status check",
+	}, aliceToken)
+	assert.Equal(t, "sent", status())
+
+	makeRequest("PUT", "/api/v1/messages/delivered/"+aliceID, nil, partnerToken)
+	assert.Equal(t, "delivered", status())
+
+	makeRequest("PUT", "/api/v1/messages/read/"+aliceID, nil, partnerToken)
+	assert.Equal(t, "read", status())
+}
+
+// ============================================================================
+// PHONE LOOKUP TESTS
+// ============================================================================
+
+// signupWithPhone registers a user with a phone number
+func signupWithPhone(t *testing.T, username, phone string) string {
+	t.Helper()
+
+	w := makeRequest("POST", "/api/v1/auth/signup", map[string]interface{}{
+		"username": username,
+		"email":    username + "@example.com",
+		"password": "Test1234!",
+		"phone":    phone,
+	}, "")
+	if !assert.Equal(t, http.StatusCreated, w.Code) {
+		t.FailNow()
+	}
+
+	var response map[string]interface{}
+	parseResponse(w, &response)
+	return response["data"].(map[string]interface{})["user"].(map[string]interface{})["id"].(string)
+}
+
+func TestLookupByPhone(t *testing.T) {
+	visibleID := signupWithPhone(t, "phone_visible", "+33612345678")
+	hiddenID := signupWithPhone(t, "phone_hidden", "+33698765432")
+	db.Model(&models.User{}).Where("id = ?", hiddenID).Update("phone_visible", false)
+
+	lookup := func(phones []string) (int, map[string]interface{}) {
+		w := makeRequest("POST", "/api/v1/users/lookup-by-phone", map[string]interface{}{
+			"phones": phones,
+		}, aliceToken)
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		data, _ := response["data"].(map[string]interface{})
+		return w.Code, data
+	}
+
+	t.Run("ExactMatch", func(t *testing.T) {
+		code, data := lookup([]string{"+33612345678", "+10000000000"})
+		assert.Equal(t, http.StatusOK, code)
+		assert.Len(t, data, 1)
+		if user, ok := data["+33612345678"].(map[string]interface{}); assert.True(t, ok) {
+			assert.Equal(t, visibleID, user["id"])
+		}
+	})
+
+	t.Run("PartialPrefixWithFormatting", func(t *testing.T) {
+		code, data := lookup([]string{"+33 6 12-34"})
+		assert.Equal(t, http.StatusOK, code)
+		if user, ok := data["+33 6 12-34"].(map[string]interface{}); assert.True(t, ok) {
+			assert.Equal(t, visibleID, user["id"])
+		}
+	})
+
+	t.Run("HiddenPhoneNotFound", func(t *testing.T) {
+		code, data := lookup([]string{"+33698765432"})
+		assert.Equal(t, http.StatusOK, code)
+		assert.Empty(t, data)
+	})
+
+	t.Run("TooManyPhones", func(t *testing.T) {
+		phones := make([]string, 51)
+		for i := range phones {
+			phones[i] = "+3361234567" + strconv.Itoa(i%10)
+		}
+		code, _ := lookup(phones)
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("SearchMatchesVisiblePhoneOnly", func(t *testing.T) {
+		w := makeRequest("GET", "/api/v1/users/search?q=%2B336", nil, aliceToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		ids := []string{}
+		for _, item := range response["data"].([]interface{}) {
+			ids = append(ids, item.(map[string]interface{})["id"].(string))
+		}
+		assert.Contains(t, ids, visibleID)
+		assert.NotContains(t, ids, hiddenID)
+	})
+}
+
+// ============================================================================
+// SEED TESTS
+// ============================================================================
+
+func TestSeedRun(t *testing.T) {
+	assert.NotPanics(t, func() {
+		assert.NoError(t, seeds.Run(db))
+	})
+
+	countSeeded := func() (users, messages int64) {
+		db.Model(&models.User{}).Where("username LIKE ?", "seed\\_%").Count(&users)
+		db.Model(&models.Message{}).Where("sender_id = ?", seeds.ID("user", "seed_alice")).Count(&messages)
+		return users, messages
+	}
+	users, messages := countSeeded()
+	assert.Equal(t, int64(5), users)
+	assert.Greater(t, messages, int64(0))
+
+	// A second run is a no-op thanks to deterministic IDs
+	assert.NoError(t, seeds.Run(db))
+	usersAgain, messagesAgain := countSeeded()
+	assert.Equal(t, users, usersAgain)
+	assert.Equal(t, messages, messagesAgain)
+
+	// Seeded accounts can sign in
+	w := makeRequest("POST", "/api/v1/auth/login", map[string]interface{}{
+		"identifier": "seed_carmen",
+		"password":   seeds.Password,
+	}, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+// ============================================================================
+// GROUP BULK ADD TESTS
+// ============================================================================
+
+func TestGroupBulkAddMembers(t *testing.T) {
+	_, daveID := signupUser(t, "bulk_dave")
+	_, erinID := signupUser(t, "bulk_erin")
+	_, frankID := signupUser(t, "bulk_frank")
+
+	w := makeRequest("POST", "/api/v1/groups", map[string]interface{}{
+		"name":       "Bulk Group",
+		"type":       "private",
+		"member_ids": []string{bobID},
+	}, aliceToken)
+	if !assert.Equal(t, http.StatusCreated, w.Code) {
+		t.FailNow()
+	}
+	var created map[string]interface{}
+	parseResponse(w, &created)
+	groupID := created["data"].(map[string]interface{})["id"].(string)
+
+	bulkAdd := func(token string, userIDs []string) (int, map[string]interface{}) {
+		w := makeRequest("POST", "/api/v1/groups/"+groupID+"/members/bulk", map[string]interface{}{
+			"user_ids": userIDs,
+		}, token)
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		return w.Code, response
+	}
+
+	t.Run("MemberCannotBulkAdd", func(t *testing.T) {
+		code, _ := bulkAdd(bobToken, []string{daveID})
+		assert.Equal(t, http.StatusForbidden, code)
+	})
+
+	t.Run("FullSuccess", func(t *testing.T) {
+		code, response := bulkAdd(aliceToken, []string{daveID, erinID})
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, float64(2), response["added"])
+		assert.Empty(t, response["errors"])
+		assert.Equal(t, models.MemberRoleMember, memberRole(t, groupID, daveID))
+		assert.Equal(t, models.MemberRoleMember, memberRole(t, groupID, erinID))
+
+		var invites int64
+		db.Model(&models.Notification{}).
+			Where("user_id = ? AND type = ? AND reference_id = ?", daveID, models.NotificationTypeGroupInvite, groupID).
+			Count(&invites)
+		assert.Equal(t, int64(1), invites)
+	})
+
+	t.Run("PartialSuccess", func(t *testing.T) {
+		unknownID := uuid.New().String()
+		code, response := bulkAdd(aliceToken, []string{bobID, frankID, unknownID, frankID})
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, float64(1), response["added"])
+
+		errs := response["errors"].([]interface{})
+		if assert.Len(t, errs, 1) {
+			assert.Equal(t, unknownID, errs[0].(map[string]interface{})["user_id"])
+		}
+		assert.Equal(t, models.MemberRoleMember, memberRole(t, groupID, frankID))
+	})
+
+	t.Run("TooManyUsers", func(t *testing.T) {
+		ids := make([]string, 101)
+		for i := range ids {
+			ids[i] = uuid.New().String()
+		}
+		code, _ := bulkAdd(aliceToken, ids)
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+}
+
+// ============================================================================
+// READ RECEIPT TESTS
+// ============================================================================
+
+func TestReadReceiptMessageIDs(t *testing.T) {
+	readerToken, readerID := signupUser(t, "receipt_reader")
+
+	aliceWS := dialWebSocket(t, aliceToken)
+	defer aliceWS.close()
+	waitForOnline(t, aliceID, true)
+
+	var sent []string
+	for _, content := range []string{"First unread", "Second unread"} {
+		w := makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+			"receiver_id": readerID,
+			"content":     content,
+		}, aliceToken)
+		if !assert.Equal(t, http.StatusCreated, w.Code) {
+			t.FailNow()
+		}
+		sent = append(sent, parseMessageID(t, w))
+	}
+
+	w := makeRequest("PUT", "/api/v1/messages/read/"+aliceID, nil, readerToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	event := aliceWS.waitForEvent(t, "message_read", 2*time.Second)
+	assert.Equal(t, readerID, event["sender_id"])
+	assert.NotEmpty(t, event["read_at"])
+
+	ids := []string{}
+	for _, id := range event["message_ids"].([]interface{}) {
+		ids = append(ids, id.(string))
+	}
+	assert.ElementsMatch(t, sent, ids)
+
+	var message models.Message
+	db.Where("id = ?", sent[0]).First(&message)
+	if assert.NotNil(t, message.ReadAt) {
+		readAt, err := time.Parse(time.RFC3339Nano, event["read_at"].(string))
+		assert.NoError(t, err)
+		assert.WithinDuration(t, *message.ReadAt, readAt, time.Millisecond)
+	}
+}
+
+// ============================================================================
+// CONVERSATION LABEL TESTS
+// ============================================================================
+
+func TestConversationLabels(t *testing.T) {
+	labelerToken, _ := signupUser(t, "label_owner")
+	_, workID := signupUser(t, "label_work")
+	_, familyID := signupUser(t, "label_family")
+
+	for _, partnerID := range []string{workID, familyID} {
+		w := makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+			"receiver_id": partnerID,
+			"content":     "Hello there",
+		}, labelerToken)
+		if !assert.Equal(t, http.StatusCreated, w.Code) {
+			t.FailNow()
+		}
+	}
+
+	addLabel := func(partnerID, label, color string) int {
+		w := makeRequest("POST", "/api/v1/messages/conversation/"+partnerID+"/labels", map[string]string{
+			"label": label,
+			"color": color,
+		}, labelerToken)
+		return w.Code
+	}
+
+	t.Run("InvalidLabel", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, addLabel(workID, "work", "blue"))
+		assert.Equal(t, http.StatusBadRequest, addLabel(workID, strings.Repeat("x", 21), "#1E90FF"))
+	})
+
+	t.Run("FiveLabelCap", func(t *testing.T) {
+		for _, label := range []string{"work", "urgent", "q3", "clients", "billing"} {
+			assert.Equal(t, http.StatusOK, addLabel(workID, label, "#1E90FF"))
+		}
+		assert.Equal(t, http.StatusBadRequest, addLabel(workID, "sixth", "#1E90FF"))
+
+		// Re-adding an existing label only changes its color
+		assert.Equal(t, http.StatusOK, addLabel(workID, "work", "#FF0000"))
+
+		w := makeRequest("DELETE", "/api/v1/messages/conversation/"+workID+"/labels/billing", nil, labelerToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, http.StatusOK, addLabel(workID, "sixth", "#1E90FF"))
+
+		w = makeRequest("DELETE", "/api/v1/messages/conversation/"+workID+"/labels/billing", nil, labelerToken)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("FilterConversationsByLabel", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, addLabel(familyID, "family", "#00FF00"))
+
+		w := makeRequest("GET", "/api/v1/messages/conversations?label=work", nil, labelerToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		data := response["data"].([]interface{})
+		if assert.Len(t, data, 1) {
+			summary := data[0].(map[string]interface{})
+			assert.Equal(t, workID, summary["user"].(map[string]interface{})["id"])
+			assert.Len(t, summary["labels"], 5)
+		}
+
+		w = makeRequest("GET", "/api/v1/messages/conversations", nil, labelerToken)
+		parseResponse(w, &response)
+		assert.Len(t, response["data"], 2)
+	})
+
+	t.Run("ListDistinctLabels", func(t *testing.T) {
+		w := makeRequest("GET", "/api/v1/messages/labels", nil, labelerToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		names := []string{}
+		for _, item := range response["data"].([]interface{}) {
+			names = append(names, item.(map[string]interface{})["label"].(string))
+		}
+		assert.Equal(t, []string{"clients", "family", "q3", "sixth", "urgent", "work"}, names)
+	})
+}
+
+// ============================================================================
+// GROUP EXPORT TESTS
+// ============================================================================
+
+func TestGroupMessageExport(t *testing.T) {
+	w := makeRequest("POST", "/api/v1/groups", map[string]interface{}{
+		"name":       "Export Group",
+		"type":       "private",
+		"member_ids": []string{bobID},
+	}, aliceToken)
+	if !assert.Equal(t, http.StatusCreated, w.Code) {
+		t.FailNow()
+	}
+	var created map[string]interface{}
+	parseResponse(w, &created)
+	groupID := created["data"].(map[string]interface{})["id"].(string)
+
+	// Seed 1000 messages directly: 990 from Alice and 10 from Bob
+	content, err := utils.Encrypt("Exported, with a comma")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	base := time.Now().Add(-time.Hour)
+	messages := make([]models.GroupMessage, 1000)
+	for i := range messages {
+		sender := aliceID
+		if i%100 == 0 {
+			sender = bobID
+		}
+		messages[i] = models.GroupMessage{
+			GroupID:   uuid.MustParse(groupID),
+			SenderID:  uuid.MustParse(sender),
+			Content:   content,
+			CreatedAt: base.Add(time.Duration(i) * time.Millisecond),
+		}
+	}
+	if !assert.NoError(t, db.CreateInBatches(messages, 200).Error) {
+		t.FailNow()
+	}
+
+	export := func(query, token string) (*httptest.ResponseRecorder, [][]string) {
+		w := makeRequest("GET", "/api/v1/groups/"+groupID+"/messages/export?"+query, nil, token)
+		if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") {
+			return w, nil
+		}
+		records, err := csv.NewReader(w.Body).ReadAll()
+		assert.NoError(t, err)
+		return w, records
+	}
+
+	t.Run("AdminExportsFullHistoryAsCSV", func(t *testing.T) {
+		w, records := export("format=csv", aliceToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+		if assert.Len(t, records, 1001) {
+			assert.Equal(t, []string{"id", "sender_username", "content", "sent_at", "is_deleted", "edited"}, records[0])
+			assert.Equal(t, messages[0].ID.String(), records[1][0])
+			assert.Equal(t, "Exported, with a comma", records[1][2])
+			assert.Equal(t, messages[999].ID.String(), records[1000][0])
+		}
+	})
+
+	t.Run("MemberNeedsForSelf", func(t *testing.T) {
+		w, _ := export("format=csv", bobToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w, records := export("format=csv&for_self=true", bobToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Len(t, records, 11)
+	})
+
+	t.Run("JSONFormat", func(t *testing.T) {
+		w, _ := export("format=json&for_self=true", bobToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var rows []map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &rows))
+		if assert.Len(t, rows, 10) {
+			assert.Equal(t, testUserBob["username"], rows[0]["sender_username"])
+		}
+	})
+
+	t.Run("InvalidFormat", func(t *testing.T) {
+		w, _ := export("format=xml", aliceToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("NonMemberForbidden", func(t *testing.T) {
+		ensureCarol(t)
+		w, _ := export("format=csv&for_self=true", carolToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
+
+// ============================================================================
+// GROUP DISCOVER TESTS
+// ============================================================================
+
+func TestDiscoverGroups(t *testing.T) {
+	frToken, frID := signupUser(t, "discover_fr")
+	if err := db.Model(&models.User{}).Where("id = ?", frID).Update("language", "fr").Error; err != nil {
+		t.Fatalf("failed to set language: %v", err)
+	}
+
+	createGroup := func(token, name, groupType string) string {
+		w := makeRequest("POST", "/api/v1/groups", map[string]interface{}{
+			"name": name,
+			"type": groupType,
+		}, token)
+		if !assert.Equal(t, http.StatusCreated, w.Code) {
+			t.FailNow()
+		}
+		var created map[string]interface{}
+		parseResponse(w, &created)
+		return created["data"].(map[string]interface{})["id"].(string)
+	}
+
+	enID := createGroup(aliceToken, "Discover Gophers", "public")
+	frGroupID := createGroup(frToken, "Discover GOPHERS Paris", "public")
+	privateID := createGroup(aliceToken, "Discover Gophers Secret", "private")
+
+	discover := func(query string) map[string]map[string]interface{} {
+		w := makeRequest("GET", "/api/v1/groups/discover?"+query, nil, bobToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		found := map[string]map[string]interface{}{}
+		for _, item := range response["data"].([]interface{}) {
+			group := item.(map[string]interface{})
+			found[group["id"].(string)] = group
+		}
+		return found
+	}
+
+	t.Run("CaseInsensitiveNameSearch", func(t *testing.T) {
+		found := discover("q=discover%20gophers")
+		assert.Contains(t, found, enID)
+		assert.Contains(t, found, frGroupID)
+		assert.NotContains(t, found, privateID)
+		if group, ok := found[enID]; ok {
+			assert.Equal(t, float64(1), group["member_count"])
+		}
+	})
+
+	t.Run("LanguageFilter", func(t *testing.T) {
+		found := discover("q=gophers&language=fr")
+		assert.Contains(t, found, frGroupID)
+		assert.NotContains(t, found, enID)
+		assert.Equal(t, "fr", found[frGroupID]["language"])
+	})
+
+	t.Run("PrivateGroupsNeverListed", func(t *testing.T) {
+		for _, query := range []string{"", "q=secret", "q=Discover%20Gophers%20Secret&language=en"} {
+			assert.NotContains(t, discover(query+"&limit=100"), privateID)
+		}
+	})
+}
+
+func TestListPublicGroups(t *testing.T) {
+	createGroup := func(name, description, groupType string) string {
+		w := makeRequest("POST", "/api/v1/groups", map[string]interface{}{
+			"name":        name,
+			"description": description,
+			"type":        groupType,
+			"member_ids":  []string{bobID},
+		}, aliceToken)
+		if !assert.Equal(t, http.StatusCreated, w.Code) {
+			t.FailNow()
+		}
+		var created map[string]interface{}
+		parseResponse(w, &created)
+		return created["data"].(map[string]interface{})["id"].(string)
+	}
+
+	byNameID := createGroup("Listed Crabcakes Club", "", "public")
+	byDescriptionID := createGroup("Listed Club", "We cook CRABCAKES on Fridays", "public")
+	privateID := createGroup("Listed Crabcakes Secret", "", "private")
+
+	w := makeRequest("GET", "/api/v1/groups/public?q=crabcakes&limit=100", nil, bobToken)
+	if !assert.Equal(t, http.StatusOK, w.Code) {
+		t.FailNow()
+	}
+	var response struct {
+		Data []map[string]interface{} `json:"data"`
+	}
+	parseResponse(w, &response)
+
+	found := map[string]map[string]interface{}{}
+	for _, group := range response.Data {
+		found[group["id"].(string)] = group
+	}
+	assert.Contains(t, found, byNameID)
+	assert.Contains(t, found, byDescriptionID)
+	assert.NotContains(t, found, privateID)
+
+	if group, ok := found[byDescriptionID]; ok {
+		assert.Equal(t, float64(2), group["member_count"])
+		assert.Equal(t, "We cook CRABCAKES on Fridays", group["description"])
+		assert.NotContains(t, group, "members")
+		assert.NotContains(t, group, "creator")
+	}
+
+	t.Run("Paginates", func(t *testing.T) {
+		w := makeRequest("GET", "/api/v1/groups/public?q=crabcakes&limit=1&offset=1", nil, bobToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var page struct {
+			Data []map[string]interface{} `json:"data"`
+		}
+		parseResponse(w, &page)
+		assert.Len(t, page.Data, 1)
+	})
+}
+
+// ============================================================================
+// PHONE NORMALIZATION TESTS
+// ============================================================================
+
+func TestPhoneStoredInE164(t *testing.T) {
+	storedPhone := func(userID string) string {
+		var user models.User
+		if err := db.Where("id = ?", userID).First(&user).Error; err != nil {
+			t.Fatalf("user not found: %v", err)
+		}
+		return user.Phone
+	}
+
+	assert.Equal(t, "+261340000001", storedPhone(aliceID))
+	assert.Equal(t, "+1234567890", storedPhone(bobID), "numbers libphonenumber doesn't know are kept")
+
+	t.Run("FormattedInput", func(t *testing.T) {
+		userID := signupWithPhone(t, "phone_formatted", "+44 7911 123456")
+		assert.Equal(t, "+447911123456", storedPhone(userID))
+
+		userID = signupWithPhone(t, "phone_national", "034 00 000 02")
+		assert.Equal(t, "+261340000002", storedPhone(userID))
+	})
+
+	t.Run("DuplicateInOtherFormat", func(t *testing.T) {
+		for i, phone := range []string{"+261 34 00 000 01", "+1 (234) 567-890"} {
+			w := makeRequest("POST", "/api/v1/auth/signup", map[string]interface{}{
+				"username": "phone_duplicate" + strconv.Itoa(i),
+				"email":    "phone_duplicate" + strconv.Itoa(i) + "@example.com",
+				"password": "Test1234!",
+				"phone":    phone,
+			}, "")
+			assert.Equal(t, http.StatusBadRequest, w.Code, phone)
+		}
+	})
+
+	t.Run("LoginWithLocalFormat", func(t *testing.T) {
+		w := makeRequest("POST", "/api/v1/auth/login", map[string]interface{}{
+			"identifier": "034 00 000 01",
+			"password":   testUserAlice["password"],
+		}, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("InvalidPhoneRejected", func(t *testing.T) {
+		w := makeRequest("POST", "/api/v1/auth/signup", map[string]interface{}{
+			"username": "phone_invalid",
+			"email":    "phone_invalid@example.com",
+			"password": "Test1234!",
+			"phone":    "+999 1234 5678",
+		}, "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+// ============================================================================
+// PASSWORD MIGRATION TESTS
+// ============================================================================
+
+func TestLazyPasswordMigration(t *testing.T) {
+	passwordHash := func(userID string) string {
+		var user models.User
+		if err := db.Where("id = ?", userID).First(&user).Error; err != nil {
+			t.Fatalf("user not found: %v", err)
+		}
+		return user.Password
+	}
+	login := func(username string) {
+		w := makeRequest("POST", "/api/v1/auth/login", map[string]interface{}{
+			"identifier": username,
+			"password":   "Test1234!",
+		}, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		testAuthService.WaitForPasswordUpgrades()
+	}
+
+	t.Run("BcryptUpgradedOnLogin", func(t *testing.T) {
+		_, userID := signupUser(t, "bcrypt_legacy")
+		legacy, err := bcrypt.GenerateFromPassword([]byte("Test1234!"), bcrypt.MinCost)
+		assert.NoError(t, err)
+		assert.NoError(t, testUserRepo.UpdatePasswordHash(parseUUID(t, userID), string(legacy)))
+
+		login("bcrypt_legacy")
+		upgraded := passwordHash(userID)
+		assert.True(t, strings.HasPrefix(upgraded, "$argon2id$"), "hash not upgraded: %s", upgraded)
+
+		// The upgraded hash still accepts the password
+		login("bcrypt_legacy")
+	})
+
+	t.Run("Argon2idLeftAlone", func(t *testing.T) {
+		_, userID := signupUser(t, "argon2_current")
+		before := passwordHash(userID)
+		assert.True(t, strings.HasPrefix(before, "$argon2id$"))
+
+		login("argon2_current")
+		assert.Equal(t, before, passwordHash(userID))
+	})
+
+	t.Run("AdminStats", func(t *testing.T) {
+		token, userID := signupUser(t, "password_stats_admin")
+		db.Model(&models.User{}).Where("id = ?", userID).Update("role", models.UserRoleAdmin)
+
+		var bcryptUsers int64
+		db.Model(&models.User{}).Where("password LIKE ?", "$2%").Count(&bcryptUsers)
+
+		w := makeRequest("GET", "/api/admin/v1/password-migration-stats", nil, token)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response map[string]map[string]interface{}
+		parseResponse(w, &response)
+		assert.Equal(t, float64(bcryptUsers), response["data"]["bcrypt"])
+
+		w = makeRequest("GET", "/api/admin/v1/password-migration-stats", nil, bobToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
+
+// ============================================================================
+// PRESENCE TESTS
+// ============================================================================
+
+func TestPresenceEventsScopedToRelatedUsers(t *testing.T) {
+	memberToken, memberID := signupUser(t, "presence_member")
+	strangerToken, strangerID := signupUser(t, "presence_stranger")
+	subjectToken, subjectID := signupUser(t, "presence_subject")
+
+	// Subject and member share a group; the stranger has no group or conversation with either
+	w := makeRequest("POST", "/api/v1/groups", map[string]interface{}{
+		"name":       "Presence Group",
+		"type":       "private",
+		"member_ids": []string{memberID},
+	}, subjectToken)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	memberWS := dialWebSocket(t, memberToken)
+	defer memberWS.close()
+	strangerWS := dialWebSocket(t, strangerToken)
+	defer strangerWS.close()
+	waitForOnline(t, memberID, true)
+	waitForOnline(t, strangerID, true)
+
+	subjectWS := dialWebSocket(t, subjectToken)
+	waitForOnline(t, subjectID, true)
+
+	joined := memberWS.waitForEvent(t, "user_joined", 2*time.Second)
+	assert.Equal(t, subjectID, joined["data"].(map[string]interface{})["user_id"])
+	strangerWS.expectNoEvent(t, "user_joined", 300*time.Millisecond)
+
+	subjectWS.close()
+	waitForOnline(t, subjectID, false)
+
+	memberWS.waitForEvent(t, "user_left", 2*time.Second)
+	strangerWS.expectNoEvent(t, "user_left", 300*time.Millisecond)
+}
+
+func TestOnlineStatusFollowsWebSocket(t *testing.T) {
+	memberToken, memberID := signupUser(t, "online_member")
+	subjectToken, subjectID := signupUser(t, "online_subject")
+
+	w := makeRequest("POST", "/api/v1/groups", map[string]interface{}{
+		"name":       "Online Status Group",
+		"type":       "private",
+		"member_ids": []string{memberID},
+	}, subjectToken)
+	if !assert.Equal(t, http.StatusCreated, w.Code) {
+		t.FailNow()
+	}
+
+	// Start from offline so the connection is what marks the user online
+	db.Model(&models.User{}).Where("id = ?", subjectID).Update("is_online", false)
+	storedOnline := func(online bool) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			var user models.User
+			db.Select("is_online", "last_seen").First(&user, "id = ?", subjectID)
+			if user.IsOnline == online && user.LastSeen != nil {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("timeout waiting for stored is_online=%v", online)
+	}
+
+	memberWS := dialWebSocket(t, memberToken)
+	defer memberWS.close()
+	waitForOnline(t, memberID, true)
+
+	subjectWS := dialWebSocket(t, subjectToken)
+	storedOnline(true)
+	presence := memberWS.waitForEvent(t, "user_presence", 2*time.Second)
+	data := presence["data"].(map[string]interface{})
+	assert.Equal(t, subjectID, data["user_id"])
+	assert.Equal(t, true, data["is_online"])
+	assert.NotEmpty(t, data["last_seen"])
+
+	subjectWS.close()
+	storedOnline(false)
+	presence = memberWS.waitForEvent(t, "user_presence", 2*time.Second)
+	assert.Equal(t, false, presence["data"].(map[string]interface{})["is_online"])
+
+	w = makeRequest("GET", "/api/v1/users/"+subjectID, nil, memberToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	parseResponse(w, &response)
+	assert.Equal(t, false, response.Data["is_online"])
+}
+
+// ============================================================================
+// CONTENT TYPE TESTS
+// ============================================================================
+
+func TestMessageContentTypes(t *testing.T) {
+	send := func(contentType, content string) *httptest.ResponseRecorder {
+		return makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+			"receiver_id":  bobID,
+			"content":      content,
+			"content_type": contentType,
+		}, aliceToken)
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		content     string
+		wantType    string
+	}{
+		{"DefaultsToText", "", "plain hello", "text"},
+		{"Text", "text", "typed hello", "text"},
+		{"Location", "location", `{"lat": -18.8792, "lng": 47.5079, "label": "Antananarivo"}`, "location"},
+		{"Contact", "contact", `{"name": "Carol", "phone": "+261 34 00 000 03"}`, "contact"},
+		{"Audio", "audio", "media/voice-notes/abc123.ogg", "audio"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := send(tt.contentType, tt.content)
+			if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+				return
+			}
+			var response map[string]map[string]interface{}
+			parseResponse(w, &response)
+			assert.Equal(t, tt.wantType, response["data"]["content_type"])
+			messageID := response["data"]["id"]
+
+			// The conversation returns the content as sent, tagged with its type
+			w = makeRequest("GET", "/api/v1/messages/conversation/"+bobID+"?limit=10", nil, aliceToken)
+			assert.Equal(t, http.StatusOK, w.Code)
+			var conversation map[string][]map[string]interface{}
+			parseResponse(w, &conversation)
+			found := false
+			for _, msg := range conversation["data"] {
+				if msg["id"] == messageID {
+					found = true
+					assert.Equal(t, tt.content, msg["content"])
+					assert.Equal(t, tt.wantType, msg["content_type"])
+				}
+			}
+			assert.True(t, found, "sent message missing from conversation")
+		})
+	}
+
+	t.Run("LocationMissingLatRejected", func(t *testing.T) {
+		w := send("location", `{"lng": 47.5079, "label": "Antananarivo"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("ContactMissingPhoneRejected", func(t *testing.T) {
+		w := send("contact", `{"name": "Carol"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("UnknownTypeRejected", func(t *testing.T) {
+		w := send("video", "clip.mp4")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+// ============================================================================
+// ADMIN USER SEARCH TESTS
+// ============================================================================
+
+func TestAdminSearchUsers(t *testing.T) {
+	adminToken, adminID := signupUser(t, "user_search_admin")
+	db.Model(&models.User{}).Where("id = ?", adminID).Update("role", models.UserRoleAdmin)
+
+	_, targetID := signupUser(t, "support_target")
+	assert.NoError(t, testUserRepo.UpdateDeviceToken(parseUUID(t, targetID), "device-token-123", "android"))
+
+	t.Run("ReturnsFullProfile", func(t *testing.T) {
+		w := makeRequest("GET", "/api/admin/v1/users/search?q=support_target", nil, adminToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Data []map[string]interface{} `json:"data"`
+			Meta map[string]interface{}   `json:"meta"`
+		}
+		parseResponse(w, &response)
+		if !assert.Len(t, response.Data, 1) {
+			return
+		}
+		user := response.Data[0]
+		assert.Equal(t, targetID, user["id"])
+		assert.Equal(t, "device-token-123", user["device_token"])
+		assert.Equal(t, "android", user["platform"])
+		assert.Equal(t, "argon2id", user["password_scheme"])
+		assert.Contains(t, user, "is_online")
+		assert.NotContains(t, user, "password")
+		assert.Equal(t, float64(1), response.Meta["total"])
+	})
+
+	t.Run("MatchesUserID", func(t *testing.T) {
+		w := makeRequest("GET", "/api/admin/v1/users/search?q="+targetID[:13], nil, adminToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Data []map[string]interface{} `json:"data"`
+		}
+		parseResponse(w, &response)
+		if assert.Len(t, response.Data, 1) {
+			assert.Equal(t, targetID, response.Data[0]["id"])
+		}
+	})
+
+	t.Run("Pagination", func(t *testing.T) {
+		w := makeRequest("GET", "/api/admin/v1/users/search?limit=2&offset=1", nil, adminToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Data []map[string]interface{} `json:"data"`
+			Meta map[string]interface{}   `json:"meta"`
+		}
+		parseResponse(w, &response)
+		assert.Len(t, response.Data, 2)
+		assert.Equal(t, float64(2), response.Meta["limit"])
+		assert.Equal(t, float64(1), response.Meta["offset"])
+		assert.GreaterOrEqual(t, response.Meta["total"], float64(3))
+		assert.Equal(t, true, response.Meta["has_more"])
+	})
+
+	t.Run("RegularUserForbidden", func(t *testing.T) {
+		w := makeRequest("GET", "/api/admin/v1/users/search?q=support_target", nil, bobToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
+
+// ============================================================================
+// PAGINATION META TESTS
+// ============================================================================
+
+func TestListEndpointsIncludeMeta(t *testing.T) {
+	type listResponse struct {
+		Data []interface{} `json:"data"`
+		Meta *utils.Meta   `json:"meta"`
+	}
+	get := func(path, token string) listResponse {
+		w := makeRequest("GET", path, nil, token)
+		assert.Equal(t, http.StatusOK, w.Code, path)
+		var response listResponse
+		parseResponse(w, &response)
+		if assert.NotNil(t, response.Meta, "missing meta on %s", path) {
+			assert.GreaterOrEqual(t, response.Meta.Total, int64(len(response.Data)), path)
+		}
+		return response
+	}
+
+	t.Run("Users", func(t *testing.T) {
+		var total int64
+		db.Model(&models.User{}).Count(&total)
+
+		response := get("/api/v1/users?limit=1&offset=0", aliceToken)
+		assert.Len(t, response.Data, 1)
+		assert.Equal(t, total, response.Meta.Total)
+		assert.True(t, response.Meta.HasMore)
+		if assert.NotNil(t, response.Meta.NextOffset) {
+			assert.Equal(t, 1, *response.Meta.NextOffset)
+		}
+
+		// Past the end: nothing returned and no next page
+		response = get("/api/v1/users?limit=10&offset="+strconv.FormatInt(total+5, 10), aliceToken)
+		assert.Empty(t, response.Data)
+		assert.False(t, response.Meta.HasMore)
+		assert.Nil(t, response.Meta.NextOffset)
+	})
+
+	t.Run("UserSearch", func(t *testing.T) {
+		response := get("/api/v1/users/search?q=bob_test&limit=5", aliceToken)
+		assert.Equal(t, int64(len(response.Data)), response.Meta.Total)
+		assert.False(t, response.Meta.HasMore)
+	})
+
+	t.Run("Groups", func(t *testing.T) {
+		all := get("/api/v1/groups/my", aliceToken)
+		assert.Equal(t, int64(len(all.Data)), all.Meta.Total)
+		assert.False(t, all.Meta.HasMore)
+
+		page := get("/api/v1/groups/my?limit=1", aliceToken)
+		assert.Len(t, page.Data, 1)
+		assert.Equal(t, all.Meta.Total, page.Meta.Total)
+		assert.Equal(t, all.Meta.Total > 1, page.Meta.HasMore)
+
+		v2 := get("/api/v2/groups/my", aliceToken)
+		assert.Equal(t, all.Meta.Total, v2.Meta.Total)
+	})
+
+	t.Run("Notifications", func(t *testing.T) {
+		var total int64
+		db.Model(&models.Notification{}).Where("user_id = ?", bobID).Count(&total)
+
+		response := get("/api/v1/notifications?limit=2", bobToken)
+		assert.Equal(t, total, response.Meta.Total)
+		assert.LessOrEqual(t, len(response.Data), 2)
+		assert.Equal(t, total > 2, response.Meta.HasMore)
+	})
+}
+
+// ============================================================================
+// CONTENT FILTER TESTS
+// ============================================================================
+
+func TestContentFilter(t *testing.T) {
+	adminToken, adminID := signupUser(t, "content_filter_admin")
+	db.Model(&models.User{}).Where("id = ?", adminID).Update("role", models.UserRoleAdmin)
+
+	addRule := func(word, severity string, isRegex bool) string {
+		w := makeRequest("POST", "/api/admin/v1/content-filter", map[string]interface{}{
+			"word":     word,
+			"severity": severity,
+			"is_regex": isRegex,
+		}, adminToken)
+		if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+		var response map[string]map[string]interface{}
+		parseResponse(w, &response)
+		return response["data"]["id"].(string)
+	}
+	send := func(content string) *httptest.ResponseRecorder {
+		return makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+			"receiver_id": bobID,
+			"content":     content,
+		}, aliceToken)
+	}
+
+	lowID := addRule("frobnicate", "low", false)
+	highID := addRule(`zorg+\d+`, "high", true)
+	defer func() {
+		makeRequest("DELETE", "/api/admin/v1/content-filter/"+lowID, nil, adminToken)
+		makeRequest("DELETE", "/api/admin/v1/content-filter/"+highID, nil, adminToken)
+	}()
+
+	t.Run("LowSeverityMasked", func(t *testing.T) {
+		w := send("please Frobnicate the widget")
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var response map[string]map[string]interface{}
+		parseResponse(w, &response)
+		assert.Equal(t, "please *** the widget", response["data"]["content"])
+	})
+
+	t.Run("HighSeverityRejected", func(t *testing.T) {
+		w := send("code zorggg42 incoming")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("InvalidRegexRejected", func(t *testing.T) {
+		w := makeRequest("POST", "/api/admin/v1/content-filter", map[string]interface{}{
+			"word":     "([unclosed",
+			"is_regex": true,
+		}, adminToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("ListAndDelete", func(t *testing.T) {
+		w := makeRequest("GET", "/api/admin/v1/content-filter", nil, adminToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response map[string][]map[string]interface{}
+		parseResponse(w, &response)
+		ids := map[interface{}]bool{}
+		for _, filter := range response["data"] {
+			ids[filter["id"]] = true
+		}
+		assert.True(t, ids[lowID] && ids[highID])
+
+		tempID := addRule("temporaryword", "medium", false)
+		w = makeRequest("DELETE", "/api/admin/v1/content-filter/"+tempID, nil, adminToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = makeRequest("DELETE", "/api/admin/v1/content-filter/"+tempID, nil, adminToken)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("RegularUserForbidden", func(t *testing.T) {
+		w := makeRequest("GET", "/api/admin/v1/content-filter", nil, bobToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
+
+
+// ============================================================================
+// REACTION TESTS
+// ============================================================================
+
+func TestMessageReactions(t *testing.T) {
+	senderToken, _ := signupUser(t, "reaction_sender")
+	receiverToken, receiverID := signupUser(t, "reaction_receiver")
+	outsiderToken, _ := signupUser(t, "reaction_outsider")
+
+	w := makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+		"receiver_id": receiverID,
+		"content":     "React to me",
+	}, senderToken)
+	if !assert.Equal(t, http.StatusCreated, w.Code) {
+		t.FailNow()
+	}
+	var sent map[string]map[string]interface{}
+	parseResponse(w, &sent)
+	messageID := sent["data"]["id"].(string)
+	reactionsURL := "/api/v1/messages/" + messageID + "/reactions"
+
+	senderWS := dialWebSocket(t, senderToken)
+	defer senderWS.close()
+
+	t.Run("AddNotifiesPartner", func(t *testing.T) {
+		w := makeRequest("POST", reactionsURL, map[string]interface{}{"emoji": "👍"}, receiverToken)
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+
+		event := senderWS.waitForEvent(t, "reaction_added", 2*time.Second)
+		data := event["data"].(map[string]interface{})
+		assert.Equal(t, messageID, data["message_id"])
+		assert.Equal(t, "👍", data["emoji"])
+	})
+
+	t.Run("CountsInConversation", func(t *testing.T) {
+		makeRequest("POST", reactionsURL, map[string]interface{}{"emoji": "👍"}, senderToken)
+		// Reacting twice with the same emoji doesn't count twice
+		makeRequest("POST", reactionsURL, map[string]interface{}{"emoji": "👍"}, senderToken)
+		makeRequest("POST", reactionsURL, map[string]interface{}{"emoji": "❤️"}, senderToken)
+
+		w := makeRequest("GET", "/api/v1/messages/conversation/"+receiverID, nil, senderToken)
+		if !assert.Equal(t, http.StatusOK, w.Code) {
+			t.FailNow()
+		}
+		var response map[string][]map[string]interface{}
+		parseResponse(w, &response)
+		if !assert.NotEmpty(t, response["data"]) {
+			t.FailNow()
+		}
+		assert.Equal(t, map[string]interface{}{"👍": float64(2), "❤️": float64(1)}, response["data"][0]["reactions"])
+
+		w = makeRequest("GET", "/api/v2/messages/conversation/"+receiverID, nil, senderToken)
+		if !assert.Equal(t, http.StatusOK, w.Code) {
+			t.FailNow()
+		}
+		parseResponse(w, &response)
+		assert.Equal(t, []interface{}{
+			map[string]interface{}{"emoji": "👍", "count": float64(2)},
+			map[string]interface{}{"emoji": "❤️", "count": float64(1)},
+		}, response["data"][0]["reactions"])
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		w := makeRequest("DELETE", reactionsURL+"/"+url.PathEscape("👍"), nil, receiverToken)
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+		var response map[string]map[string]interface{}
+		parseResponse(w, &response)
+		assert.Equal(t, float64(1), response["data"]["👍"])
+
+		senderWS.waitForEvent(t, "reaction_removed", 2*time.Second)
+
+		w = makeRequest("DELETE", reactionsURL+"/"+url.PathEscape("👍"), nil, receiverToken)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		w := makeRequest("POST", reactionsURL, map[string]interface{}{"emoji": "lol"}, receiverToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = makeRequest("POST", reactionsURL, map[string]interface{}{"emoji": "👍"}, outsiderToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = makeRequest("POST", "/api/v1/messages/"+uuid.New().String()+"/reactions", map[string]interface{}{"emoji": "👍"}, receiverToken)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+// ============================================================================
+// ATTACHMENT TESTS
+// ============================================================================
+
+// uploadAttachment posts data as the multipart "file" field of an attachment upload
+func uploadAttachment(t *testing.T, token, filename string, data []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	return uploadFile(t, "/api/v1/messages/attachments", token, filename, data)
+}
+
+// uploadFile posts data as the "file" field of a multipart form to path
+func uploadFile(t *testing.T, path, token, filename string, data []byte) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatalf("failed to build multipart body: %v", err)
+	}
+	part.Write(data)
+	writer.Close()
+
+	req, _ := http.NewRequest("POST", path, &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestMessageAttachments(t *testing.T) {
+	pngData := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR fake image body")
+
+	w := uploadAttachment(t, aliceToken, "photo.png", pngData)
+	if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+		t.FailNow()
+	}
+	var uploaded map[string]map[string]interface{}
+	parseResponse(w, &uploaded)
+	attachmentURL := uploaded["data"]["url"].(string)
+	assert.Equal(t, "image", uploaded["data"]["type"])
+	assert.Equal(t, "image/png", uploaded["data"]["mime_type"])
+
+	t.Run("Served", func(t *testing.T) {
+		w := makeRequest("GET", attachmentURL, nil, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, pngData, w.Body.Bytes())
+	})
+
+	t.Run("SendWithAttachment", func(t *testing.T) {
+		// The caption is optional when an attachment is sent
+		w := makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+			"receiver_id":    bobID,
+			"attachment_url": attachmentURL,
+		}, aliceToken)
+		if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+		var response map[string]map[string]interface{}
+		parseResponse(w, &response)
+		assert.Equal(t, attachmentURL, response["data"]["attachment_url"])
+		assert.Equal(t, "image", response["data"]["attachment_type"])
+
+		w = makeRequest("GET", "/api/v1/messages/conversation/"+bobID, nil, bobToken)
+		var conversation map[string][]map[string]interface{}
+		parseResponse(w, &conversation)
+		if assert.NotEmpty(t, conversation["data"]) {
+			assert.Equal(t, attachmentURL, conversation["data"][0]["attachment_url"])
+		}
+	})
+
+	t.Run("RejectsForeignURL", func(t *testing.T) {
+		w := makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+			"receiver_id":    bobID,
+			"attachment_url": "https://example.com/cat.png",
+		}, aliceToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("RequiresContentOrAttachment", func(t *testing.T) {
+		w := makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+			"receiver_id": bobID,
+		}, aliceToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("RejectsUnsupportedType", func(t *testing.T) {
+		w := uploadAttachment(t, aliceToken, "notes.txt", []byte("plain text is not allowed"))
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	})
+
+	t.Run("RejectsOversizedFile", func(t *testing.T) {
+		big := make([]byte, services.MaxAttachmentSize+1)
+		copy(big, pngData)
+		w := uploadAttachment(t, aliceToken, "huge.png", big)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	})
+
+	t.Run("RequiresAuth", func(t *testing.T) {
+		w := uploadAttachment(t, "", "photo.png", pngData)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+// ============================================================================
+// CONVERSATION CURSOR TESTS
+// ============================================================================
+
+func TestConversationCursorPagination(t *testing.T) {
+	userToken, userID := signupUser(t, "cursor_user")
+	partnerToken, partnerID := signupUser(t, "cursor_partner")
+
+	send := func(content string) {
+		w := makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+			"receiver_id": partnerID,
+			"content":     content,
+		}, userToken)
+		if !assert.Equal(t, http.StatusCreated, w.Code) {
+			t.FailNow()
+		}
+	}
+	for i := 1; i <= 5; i++ {
+		send("cursor message " + strconv.Itoa(i))
+	}
+
+	type page struct {
+		Data       []map[string]interface{} `json:"data"`
+		NextCursor *string                  `json:"next_cursor"`
+	}
+	getPage := func(query string) page {
+		w := makeRequest("GET", "/api/v1/messages/conversation/"+partnerID+"?limit=2"+query, nil, userToken)
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+		var p page
+		parseResponse(w, &p)
+		return p
+	}
+	contents := func(p page) []interface{} {
+		var out []interface{}
+		for _, message := range p.Data {
+			out = append(out, message["content"])
+		}
+		return out
+	}
+
+	first := getPage("")
+	assert.Equal(t, []interface{}{"cursor message 5", "cursor message 4"}, contents(first))
+	if !assert.NotNil(t, first.NextCursor) {
+		t.FailNow()
+	}
+	assert.Equal(t, first.Data[1]["id"], *first.NextCursor)
+
+	// A message arriving mid-scroll shifts offsets but not cursors
+	send("cursor message 6")
+
+	second := getPage("&before=" + *first.NextCursor)
+	assert.Equal(t, []interface{}{"cursor message 3", "cursor message 2"}, contents(second))
+	if !assert.NotNil(t, second.NextCursor) {
+		t.FailNow()
+	}
+
+	last := getPage("&before=" + *second.NextCursor)
+	assert.Equal(t, []interface{}{"cursor message 1"}, contents(last))
+	assert.Nil(t, last.NextCursor, "a short page means the start of the conversation")
+
+	t.Run("TimestampCursor", func(t *testing.T) {
+		createdAt := second.Data[0]["created_at"].(string)
+		p := getPage("&before=" + url.QueryEscape(createdAt))
+		assert.Equal(t, []interface{}{"cursor message 2", "cursor message 1"}, contents(p))
+	})
+
+	t.Run("SameForPartnerAndV2", func(t *testing.T) {
+		w := makeRequest("GET", "/api/v2/messages/conversation/"+partnerID+"?limit=2&before="+*first.NextCursor, nil, userToken)
+		var p page
+		parseResponse(w, &p)
+		assert.Equal(t, contents(second), contents(p))
+
+		w = makeRequest("GET", "/api/v1/messages/conversation/"+userID+"?limit=2&before="+*first.NextCursor, nil, partnerToken)
+		parseResponse(w, &p)
+		assert.Equal(t, contents(second), contents(p))
+	})
+
+	t.Run("InvalidCursor", func(t *testing.T) {
+		w := makeRequest("GET", "/api/v1/messages/conversation/"+partnerID+"?before=yesterday", nil, userToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		// A message from another conversation can't be used as a cursor
+		w = makeRequest("GET", "/api/v1/messages/conversation/"+partnerID+"?before="+uuid.New().String(), nil, userToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("DeprecatedOffset", func(t *testing.T) {
+		w := makeRequest("GET", "/api/v1/messages/conversation/"+partnerID+"?limit=2&offset=2", nil, userToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "true", w.Header().Get("Deprecation"))
+		var p page
+		parseResponse(w, &p)
+		assert.Len(t, p.Data, 2)
+		assert.Nil(t, p.NextCursor)
+	})
+}
+
+// ============================================================================
+// PROFILE UPDATE TESTS
+// ============================================================================
+
+func TestUpdateProfile(t *testing.T) {
+	userToken, userID := signupUser(t, "profile_user")
+	signupUser(t, "profile_taken")
+
+	t.Run("Success", func(t *testing.T) {
+		w := makeRequest("PATCH", "/api/v1/users/me", map[string]interface{}{
+			"username": "profile_renamed",
+			"avatar":   "https://example.com/avatar.png",
+			"language": "fr",
+			"bio":      "Hello there",
+		}, userToken)
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		data := response["data"].(map[string]interface{})
+		assert.Equal(t, "profile_renamed", data["username"])
+		assert.Equal(t, "https://example.com/avatar.png", data["avatar"])
+		assert.Equal(t, "fr", data["language"])
+		assert.Equal(t, "Hello there", data["bio"])
+
+		var user models.User
+		assert.NoError(t, db.First(&user, "id = ?", userID).Error)
+		assert.Equal(t, "profile_renamed", user.Username)
+		assert.Equal(t, "fr", user.Language)
+	})
+
+	t.Run("OmittedFieldsUnchanged", func(t *testing.T) {
+		w := makeRequest("PATCH", "/api/v1/users/me", map[string]interface{}{
+			"bio": "Updated bio",
+		}, userToken)
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		data := response["data"].(map[string]interface{})
+		assert.Equal(t, "profile_renamed", data["username"])
+		assert.Equal(t, "fr", data["language"])
+		assert.Equal(t, "Updated bio", data["bio"])
+	})
+
+	t.Run("DuplicateUsername", func(t *testing.T) {
+		w := makeRequest("PATCH", "/api/v1/users/me", map[string]interface{}{
+			"username": "Profile_Taken",
+		}, userToken)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		// Changing only the case of one's own username is allowed
+		w = makeRequest("PATCH", "/api/v2/users/me", map[string]interface{}{
+			"username": "Profile_Renamed",
+		}, userToken)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+
+	t.Run("UsernameCooldown", func(t *testing.T) {
+		// The rename in Success started the cooldown
+		w := makeRequest("PATCH", "/api/v1/users/me", map[string]interface{}{
+			"username": "profile_again",
+		}, userToken)
+		assert.Equal(t, http.StatusTooManyRequests, w.Code, w.Body.String())
+
+		changedAt := time.Now().Add(-models.UsernameChangeCooldown - time.Hour)
+		assert.NoError(t, db.Model(&models.User{}).Where("id = ?", userID).Update("username_changed_at", changedAt).Error)
+
+		w = makeRequest("PATCH", "/api/v1/users/me", map[string]interface{}{
+			"username": "profile_again",
+		}, userToken)
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+		var response struct {
+			Data models.PublicUser `json:"data"`
+		}
+		parseResponse(w, &response)
+		assert.Equal(t, "profile_again", response.Data.Username)
+		if assert.NotNil(t, response.Data.UsernameChangeableAt) {
+			assert.WithinDuration(t, time.Now().Add(models.UsernameChangeCooldown), *response.Data.UsernameChangeableAt, time.Minute)
+		}
+	})
+
+	t.Run("InvalidFields", func(t *testing.T) {
+		for _, body := range []map[string]interface{}{
+			{"username": "a"},
+			{"username": "not valid!"},
+			{"language": "xx"},
+			{"bio": strings.Repeat("b", utils.MaxBioLength+1)},
+		} {
+			w := makeRequest("PATCH", "/api/v1/users/me", body, userToken)
+			assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		}
+	})
+}
+
+// ============================================================================
+// AVATAR UPLOAD TESTS
+// ============================================================================
+
+// encodeTestImage returns a width x height image encoded as PNG or JPEG
+func encodeTestImage(t *testing.T, format string, width, height int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	var err error
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, img, nil)
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		t.Fatalf("failed to encode test image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// fetchImageSize downloads an image served by the API and returns its dimensions and format
+func fetchImageSize(t *testing.T, url string) (width, height int, format string) {
+	t.Helper()
+
+	w := makeRequest("GET", url, nil, "")
+	if !assert.Equal(t, http.StatusOK, w.Code) {
+		t.FailNow()
+	}
+	cfg, format, err := image.DecodeConfig(w.Body)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return cfg.Width, cfg.Height, format
+}
+
+func TestUploadAvatar(t *testing.T) {
+	userToken, userID := signupUser(t, "avatar_user")
+
+	w := uploadFile(t, "/api/v1/users/me/avatar", userToken, "me.png", encodeTestImage(t, "png", 400, 300))
+	if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		t.FailNow()
+	}
+	var response map[string]map[string]string
+	parseResponse(w, &response)
+	avatarURL := response["data"]["avatar_url"]
+	thumbURL := response["data"]["thumbnail_url"]
+	assert.NotEqual(t, avatarURL, thumbURL)
+
+	width, height, format := fetchImageSize(t, avatarURL)
+	assert.Equal(t, []int{256, 256}, []int{width, height})
+	assert.Equal(t, "png", format)
+	width, height, _ = fetchImageSize(t, thumbURL)
+	assert.Equal(t, []int{64, 64}, []int{width, height})
+
+	var user models.User
+	assert.NoError(t, db.First(&user, "id = ?", userID).Error)
+	assert.Equal(t, avatarURL, user.Avatar)
+	assert.Equal(t, thumbURL, user.AvatarThumb)
+
+	t.Run("JPEG", func(t *testing.T) {
+		w := uploadFile(t, "/api/v2/users/me/avatar", userToken, "me.jpg", encodeTestImage(t, "jpeg", 100, 120))
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+		var response map[string]map[string]string
+		parseResponse(w, &response)
+		_, _, format := fetchImageSize(t, response["data"]["avatar_url"])
+		assert.Equal(t, "jpeg", format)
+	})
+
+	t.Run("RejectsNonImages", func(t *testing.T) {
+		// The file name and extension are ignored; only the content counts
+		w := uploadFile(t, "/api/v1/users/me/avatar", userToken, "me.png", []byte("definitely not an image"))
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+
+		// GIF is a real image, but not an accepted avatar format
+		w = uploadFile(t, "/api/v1/users/me/avatar", userToken, "me.gif", []byte("GIF89a\x01\x00\x01\x00\x00\x00\x00;"))
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+
+		// A PNG signature in front of garbage isn't decodable
+		w = uploadFile(t, "/api/v1/users/me/avatar", userToken, "me.png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR fake image body"))
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	})
+
+	t.Run("RejectsLargeFiles", func(t *testing.T) {
+		w := uploadFile(t, "/api/v1/users/me/avatar", userToken, "big.png", make([]byte, services.MaxAvatarSize+1))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	})
+
+	t.Run("SettingURLClearsThumbnail", func(t *testing.T) {
+		w := makeRequest("PATCH", "/api/v1/users/me", map[string]interface{}{
+			"avatar": "https://example.com/elsewhere.png",
+		}, userToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var user models.User
+		assert.NoError(t, db.First(&user, "id = ?", userID).Error)
+		assert.Equal(t, "", user.AvatarThumb)
+	})
+}
+
+// ============================================================================
+// USER BLOCK TESTS
+// ============================================================================
+
+func TestBlockUser(t *testing.T) {
+	blockerToken, blockerID := signupUser(t, "block_blocker")
+	blockedToken, blockedID := signupUser(t, "block_blocked")
+
+	send := func(token, receiverID string) int {
+		w := makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+			"receiver_id": receiverID,
+			"content":     "hello",
+		}, token)
+		return w.Code
+	}
+
+	// listedIDs returns the IDs of the users a request lists
+	listedIDs := func(path, token string) []string {
+		w := makeRequest("GET", path, nil, token)
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+		var response struct {
+			Data []models.PublicUser `json:"data"`
+		}
+		parseResponse(w, &response)
+		ids := make([]string, 0, len(response.Data))
+		for _, user := range response.Data {
+			ids = append(ids, user.ID.String())
+		}
+		return ids
+	}
+
+	assert.Equal(t, http.StatusCreated, send(blockedToken, blockerID))
+
+	w := makeRequest("POST", "/api/v1/users/"+blockedID+"/block", nil, blockerToken)
+	if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		t.FailNow()
+	}
+
+	// Blocking twice is harmless
+	w = makeRequest("POST", "/api/v2/users/"+blockedID+"/block", nil, blockerToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	t.Run("MessagesRejectedBothWays", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, send(blockedToken, blockerID))
+		assert.Equal(t, http.StatusForbidden, send(blockerToken, blockedID))
+	})
+
+	t.Run("BlockerHiddenFromBlocked", func(t *testing.T) {
+		assert.NotContains(t, listedIDs("/api/v1/users/search?q=block_&limit=50", blockedToken), blockerID)
+		assert.NotContains(t, listedIDs("/api/v1/users?limit=1000", blockedToken), blockerID)
+
+		// The blocker still sees the user they blocked
+		assert.Contains(t, listedIDs("/api/v1/users/search?q=block_&limit=50", blockerToken), blockedID)
+	})
+
+	t.Run("InvalidRequests", func(t *testing.T) {
+		w := makeRequest("POST", "/api/v1/users/"+blockerID+"/block", nil, blockerToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = makeRequest("POST", "/api/v1/users/"+uuid.New().String()+"/block", nil, blockerToken)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		// Only the blocker can lift the block
+		w = makeRequest("DELETE", "/api/v1/users/"+blockerID+"/block", nil, blockedToken)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Unblock", func(t *testing.T) {
+		w := makeRequest("DELETE", "/api/v1/users/"+blockedID+"/block", nil, blockerToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		assert.Equal(t, http.StatusCreated, send(blockedToken, blockerID))
+		assert.Contains(t, listedIDs("/api/v1/users/search?q=block_&limit=50", blockedToken), blockerID)
+
+		w = makeRequest("DELETE", "/api/v1/users/"+blockedID+"/block", nil, blockerToken)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+// ============================================================================
+// CONVERSATION MUTING TESTS
+// ============================================================================
+
+func TestConversationMuting(t *testing.T) {
+	senderToken, senderID := signupUser(t, "mute_sender")
+	receiverToken, receiverID := signupUser(t, "mute_receiver")
+
+	receiverDevice := "receiver-device-" + uuid.New().String()
+	assert.NoError(t, testUserRepo.UpdateDeviceToken(parseUUID(t, receiverID), receiverDevice, "android"))
+
+	mutePath := "/api/v1/messages/conversation/" + senderID + "/mute"
+
+	sendAndCountNotifications := func(content string) (notified int64) {
+		w := makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+			"receiver_id": receiverID,
+			"content":     content,
+		}, senderToken)
+		if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		messageID := response["data"].(map[string]interface{})["id"].(string)
+
+		db.Model(&models.Notification{}).Where("user_id = ? AND reference_id = ?", receiverID, messageID).Count(&notified)
+		return notified
+	}
+
+	// muteState returns is_muted and muted_until of the conversation with the sender in the receiver's list
+	muteState := func() (bool, interface{}) {
+		w := makeRequest("GET", "/api/v1/messages/conversations", nil, receiverToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		for _, item := range response["data"].([]interface{}) {
+			summary := item.(map[string]interface{})
+			if summary["user"].(map[string]interface{})["id"] == senderID {
+				return summary["is_muted"].(bool), summary["muted_until"]
+			}
+		}
+		t.Fatalf("conversation with sender not listed")
+		return false, nil
+	}
+
+	assert.Equal(t, int64(1), sendAndCountNotifications("before muting"))
+	assert.Equal(t, 1, fakeFCM.pushedTo(receiverDevice))
+
+	t.Run("MutedSkipsNotificationAndPush", func(t *testing.T) {
+		w := makeRequest("POST", mutePath, nil, receiverToken)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		assert.Equal(t, int64(0), sendAndCountNotifications("while muted"))
+		assert.Equal(t, 1, fakeFCM.pushedTo(receiverDevice))
+
+		muted, until := muteState()
+		assert.True(t, muted)
+		assert.Nil(t, until)
+
+		// The message itself is still delivered
+		w = makeRequest("GET", "/api/v1/messages/conversation/"+senderID, nil, receiverToken)
+		assert.Contains(t, w.Body.String(), "while muted")
+	})
+
+	t.Run("OnlyMutesForTheMuter", func(t *testing.T) {
+		w := makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+			"receiver_id": senderID,
+			"content":     "reply",
+		}, receiverToken)
+		if !assert.Equal(t, http.StatusCreated, w.Code) {
+			t.FailNow()
+		}
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		messageID := response["data"].(map[string]interface{})["id"].(string)
+
+		var notified int64
+		db.Model(&models.Notification{}).Where("user_id = ? AND reference_id = ?", senderID, messageID).Count(&notified)
+		assert.Equal(t, int64(1), notified)
+	})
+
+	t.Run("TimedMute", func(t *testing.T) {
+		until := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+		w := makeRequest("POST", "/api/v2/messages/conversation/"+senderID+"/mute", map[string]interface{}{"until": until}, receiverToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		assert.Equal(t, int64(0), sendAndCountNotifications("muted for an hour"))
+		muted, mutedUntil := muteState()
+		assert.True(t, muted)
+		assert.NotNil(t, mutedUntil)
+
+		past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+		w = makeRequest("POST", mutePath, map[string]interface{}{"until": past}, receiverToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("ExpiredMuteNotifies", func(t *testing.T) {
+		assert.NoError(t, db.Model(&models.MutedConversation{}).
+			Where("user_id = ? AND partner_id = ?", receiverID, senderID).
+			Update("muted_until", time.Now().Add(-time.Minute)).Error)
+
+		assert.Equal(t, int64(1), sendAndCountNotifications("mute expired"))
+	})
+
+	t.Run("Unmute", func(t *testing.T) {
+		w := makeRequest("POST", mutePath, nil, receiverToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = makeRequest("DELETE", mutePath, nil, receiverToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		assert.Equal(t, int64(1), sendAndCountNotifications("unmuted"))
+		muted, _ := muteState()
+		assert.False(t, muted)
+	})
+
+	t.Run("UnknownUser", func(t *testing.T) {
+		w := makeRequest("POST", "/api/v1/messages/conversation/"+uuid.New().String()+"/mute", nil, receiverToken)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+// ============================================================================
+// FULL-TEXT MESSAGE SEARCH TESTS
+// ============================================================================
+
+func TestSearchMessages(t *testing.T) {
+	userToken, userID := signupUser(t, "fts_user")
+	friendToken, friendID := signupUser(t, "fts_friend")
+	otherToken, otherID := signupUser(t, "fts_other")
+
+	send := func(token, receiverID, content string) string {
+		w := makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+			"receiver_id": receiverID,
+			"content":     content,
+		}, token)
+		if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+		var response map[string]map[string]interface{}
+		parseResponse(w, &response)
+		return response["data"]["id"].(string)
+	}
+
+	type hit struct {
+		ID      string            `json:"id"`
+		Content string            `json:"content"`
+		Partner models.PublicUser `json:"partner"`
+	}
+	search := func(token, query string) ([]hit, utils.Meta) {
+		w := makeRequest("GET", "/api/v1/messages/search?q="+url.QueryEscape(query)+"&limit=10", nil, token)
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+		var response struct {
+			Data []hit      `json:"data"`
+			Meta utils.Meta `json:"meta"`
+		}
+		parseResponse(w, &response)
+		return response.Data, response.Meta
+	}
+
+	send(userToken, friendID, "Pineapple pizza tonight?")
+	send(friendToken, userID, "Only if there is pineapple")
+	send(otherToken, friendID, "pineapple is the best")
+	deletedID := send(userToken, otherID, "secret pineapple plan")
+	editedID := send(userToken, otherID, "banana bread")
+
+	w := makeRequest("DELETE", "/api/v1/messages/"+deletedID, nil, userToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = makeRequest("PUT", "/api/v1/messages/"+editedID, map[string]interface{}{"content": "pineapple bread"}, userToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	hits, meta := search(userToken, "PINEAPPLE")
+	assert.Equal(t, int64(3), meta.Total)
+	if assert.Len(t, hits, 3) {
+		// Newest first, with the other participant as partner
+		assert.Equal(t, "pineapple bread", hits[0].Content)
+		assert.Equal(t, otherID, hits[0].Partner.ID.String())
+		assert.Equal(t, "Only if there is pineapple", hits[1].Content)
+		assert.Equal(t, friendID, hits[1].Partner.ID.String())
+		assert.Equal(t, "Pineapple pizza tonight?", hits[2].Content)
+		assert.Equal(t, friendID, hits[2].Partner.ID.String())
+	}
+
+	t.Run("AllWordsMustMatch", func(t *testing.T) {
+		hits, _ := search(userToken, "pineapple pizza")
+		if assert.Len(t, hits, 1) {
+			assert.Equal(t, "Pineapple pizza tonight?", hits[0].Content)
+		}
+
+		hits, _ = search(userToken, "banana")
+		assert.Empty(t, hits, "edited-away words are no longer indexed")
+	})
+
+	t.Run("OnlyOwnMessages", func(t *testing.T) {
+		hits, _ := search(otherToken, "pineapple")
+		// Other's own message plus the edited one it received; never the deleted one
+		assert.Len(t, hits, 2)
+		for _, h := range hits {
+			assert.NotEqual(t, deletedID, h.ID)
+		}
+	})
+
+	t.Run("QueryRequired", func(t *testing.T) {
+		w := makeRequest("GET", "/api/v2/messages/search", nil, userToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+// ============================================================================
+// MESSAGE REPLY TESTS
+// ============================================================================
+
+func TestMessageReplies(t *testing.T) {
+	userToken, userID := signupUser(t, "reply_user")
+	friendToken, friendID := signupUser(t, "reply_friend")
+	otherToken, otherID := signupUser(t, "reply_other")
+
+	send := func(token string, body map[string]interface{}) map[string]interface{} {
+		w := makeRequest("POST", "/api/v1/messages", body, token)
+		if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+		var response map[string]map[string]interface{}
+		parseResponse(w, &response)
+		return response["data"]
+	}
+	replyOf := func(t *testing.T, token, partnerID, messageID string) map[string]interface{} {
+		w := makeRequest("GET", "/api/v1/messages/conversation/"+partnerID, nil, token)
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+		var response struct {
+			Data []map[string]interface{} `json:"data"`
+		}
+		parseResponse(w, &response)
+		for _, message := range response.Data {
+			if message["id"] == messageID {
+				replyTo, _ := message["reply_to"].(map[string]interface{})
+				return replyTo
+			}
+		}
+		t.Fatalf("message %s not in conversation", messageID)
+		return nil
+	}
+
+	originalContent := strings.Repeat("a", 95) + "bcdefghij"
+	original := send(userToken, map[string]interface{}{"receiver_id": friendID, "content": originalContent})
+	originalID := original["id"].(string)
+	assert.Nil(t, original["reply_to"])
+
+	userWS := dialWebSocket(t, userToken)
+	defer userWS.close()
+	waitForOnline(t, userID, true)
+
+	reply := send(friendToken, map[string]interface{}{
+		"receiver_id": userID,
+		"content":     "Replying to you",
+		"reply_to_id": originalID,
+	})
+	replyID := reply["id"].(string)
+	if replyTo, ok := reply["reply_to"].(map[string]interface{}); assert.True(t, ok) {
+		assert.Equal(t, originalID, replyTo["id"])
+		assert.Equal(t, userID, replyTo["sender_id"])
+		assert.Equal(t, originalContent[:100], replyTo["content"])
+	}
+
+	t.Run("RealTimeEventCarriesReply", func(t *testing.T) {
+		event := userWS.waitForMessage(t, "Replying to you", 2*time.Second)
+		data, _ := event["data"].(map[string]interface{})
+		assert.Equal(t, replyID, data["message_id"])
+		replyTo, _ := data["reply_to"].(map[string]interface{})
+		if assert.NotNil(t, replyTo) {
+			assert.Equal(t, originalID, replyTo["id"])
+			assert.Equal(t, originalContent[:100], replyTo["content"])
+		}
+	})
+
+	t.Run("PreviewSurvivesEdit", func(t *testing.T) {
+		w := makeRequest("PUT", "/api/v1/messages/"+originalID, map[string]interface{}{"content": "rewritten"}, userToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		replyTo := replyOf(t, userToken, friendID, replyID)
+		if assert.NotNil(t, replyTo) {
+			assert.Equal(t, originalContent[:100], replyTo["content"])
+			assert.Equal(t, userID, replyTo["sender_id"])
+		}
+	})
+
+	t.Run("DeletedOriginal", func(t *testing.T) {
+		w := makeRequest("DELETE", "/api/v1/messages/"+originalID, nil, userToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		replyTo := replyOf(t, friendToken, userID, replyID)
+		if assert.NotNil(t, replyTo) {
+			assert.Equal(t, true, replyTo["is_deleted"])
+			assert.Equal(t, "[message deleted]", replyTo["content"])
+		}
+
+		w = makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+			"receiver_id": userID,
+			"content":     "Too late",
+			"reply_to_id": originalID,
+		}, friendToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("OtherConversationRejected", func(t *testing.T) {
+		outsider := send(otherToken, map[string]interface{}{"receiver_id": friendID, "content": "Not for you"})
+
+		w := makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+			"receiver_id": friendID,
+			"content":     "Sneaky reply",
+			"reply_to_id": outsider["id"],
+		}, userToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+			"receiver_id": otherID,
+			"content":     "Unknown reply",
+			"reply_to_id": uuid.New().String(),
+		}, userToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("V2", func(t *testing.T) {
+		w := makeRequest("GET", "/api/v2/messages/conversation/"+userID, nil, friendToken)
+		if !assert.Equal(t, http.StatusOK, w.Code) {
+			return
+		}
+		var response struct {
+			Data []map[string]interface{} `json:"data"`
+		}
+		parseResponse(w, &response)
+		for _, message := range response.Data {
+			if message["id"] == replyID {
+				replyTo, _ := message["reply_to"].(map[string]interface{})
+				if assert.NotNil(t, replyTo) {
+					assert.Equal(t, originalID, replyTo["id"])
+				}
+				return
+			}
+		}
+		t.Errorf("reply %s not in v2 conversation", replyID)
+	})
+}
+
+// ============================================================================
+// MESSAGE FORWARDING TESTS
+// ============================================================================
+
+func TestForwardMessage(t *testing.T) {
+	userToken, userID := signupUser(t, "fwd_user")
+	friendToken, _ := signupUser(t, "fwd_friend")
+	firstToken, firstID := signupUser(t, "fwd_first")
+	_, secondID := signupUser(t, "fwd_second")
+	outsiderToken, _ := signupUser(t, "fwd_outsider")
+
+	w := makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+		"receiver_id": userID,
+		"content":     "Worth sharing",
+	}, friendToken)
+	if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+		t.FailNow()
+	}
+	var sent map[string]map[string]interface{}
+	parseResponse(w, &sent)
+	sourceID := sent["data"]["id"].(string)
+
+	forward := func(token string, receiverIDs ...string) *httptest.ResponseRecorder {
+		return makeRequest("POST", "/api/v1/messages/forward", map[string]interface{}{
+			"source_message_id": sourceID,
+			"receiver_ids":      receiverIDs,
+		}, token)
+	}
+
+	firstWS := dialWebSocket(t, firstToken)
+	defer firstWS.close()
+	waitForOnline(t, firstID, true)
+
+	// The receiver of a message can forward it; duplicates are sent once
+	w = forward(userToken, firstID, secondID, firstID)
+	if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+		t.FailNow()
+	}
+	var response struct {
+		Data []models.MessageResponse `json:"data"`
+	}
+	parseResponse(w, &response)
+	if assert.Len(t, response.Data, 2) {
+		for _, message := range response.Data {
+			assert.Equal(t, userID, message.SenderID.String())
+			assert.Equal(t, "Worth sharing", message.Content)
+			assert.True(t, message.IsForwarded)
+			if assert.NotNil(t, message.ForwardedFromID) {
+				assert.Equal(t, sourceID, message.ForwardedFromID.String())
+			}
+		}
+		assert.Equal(t, firstID, response.Data[0].ReceiverID.String())
+		assert.Equal(t, secondID, response.Data[1].ReceiverID.String())
+	}
+
+	t.Run("StoredEncrypted", func(t *testing.T) {
+		var stored models.Message
+		if assert.NoError(t, db.Where("id = ?", response.Data[0].ID).First(&stored).Error) {
+			assert.NotEqual(t, "Worth sharing", stored.Content)
+			assert.True(t, stored.IsForwarded)
+		}
+	})
+
+	t.Run("RealTimeEvent", func(t *testing.T) {
+		event := firstWS.waitForMessage(t, "Worth sharing", 2*time.Second)
+		assert.Equal(t, userID, event["sender_id"])
+		data, _ := event["data"].(map[string]interface{})
+		assert.Equal(t, true, data["is_forwarded"])
+	})
+
+	t.Run("InConversation", func(t *testing.T) {
+		w := makeRequest("GET", "/api/v1/messages/conversation/"+userID, nil, firstToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var conversation struct {
+			Data []models.MessageResponse `json:"data"`
+		}
+		parseResponse(w, &conversation)
+		if assert.Len(t, conversation.Data, 1) {
+			assert.True(t, conversation.Data[0].IsForwarded)
+			assert.Equal(t, "Worth sharing", conversation.Data[0].Content)
+		}
+	})
+
+	t.Run("OutsiderCannotForward", func(t *testing.T) {
+		w := forward(outsiderToken, firstID)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("InvalidRequests", func(t *testing.T) {
+		w := forward(userToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		receiverIDs := make([]string, services.MaxForwardRecipients+1)
+		for i := range receiverIDs {
+			receiverIDs[i] = uuid.New().String()
+		}
+		w = forward(userToken, receiverIDs...)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		// Nothing is sent when one receiver does not exist
+		w = forward(userToken, secondID, uuid.New().String())
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		var count int64
+		db.Model(&models.Message{}).Where("receiver_id = ? AND is_forwarded = ?", secondID, true).Count(&count)
+		assert.Equal(t, int64(1), count)
+
+		w = makeRequest("POST", "/api/v1/messages/forward", map[string]interface{}{
+			"source_message_id": uuid.New().String(),
+			"receiver_ids":      []string{firstID},
+		}, userToken)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("DeletedSource", func(t *testing.T) {
+		w := makeRequest("DELETE", "/api/v1/messages/"+sourceID, nil, friendToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = forward(userToken, firstID)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+// ============================================================================
+// DELIVERY ACK TESTS
+// ============================================================================
+
+func TestMessageDeliveryAck(t *testing.T) {
+	senderToken, senderID := signupUser(t, "ack_sender")
+	receiverToken, receiverID := signupUser(t, "ack_receiver")
+
+	senderWS := dialWebSocket(t, senderToken)
+	defer senderWS.close()
+	receiverWS := dialWebSocket(t, receiverToken)
+	defer receiverWS.close()
+	waitForOnline(t, senderID, true)
+	waitForOnline(t, receiverID, true)
+
+	w := makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+		"receiver_id": receiverID,
+		"content":     "Did you get this?",
+	}, senderToken)
+	if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+		t.FailNow()
+	}
+	var sent struct {
+		Data models.MessageResponse `json:"data"`
+	}
+	parseResponse(w, &sent)
+	messageID := sent.Data.ID.String()
+	assert.False(t, sent.Data.IsDelivered)
+
+	event := receiverWS.waitForMessage(t, "Did you get this?", 2*time.Second)
+	assert.Equal(t, messageID, event["message_id"])
+
+	isDelivered := func() bool {
+		w := makeRequest("GET", "/api/v1/messages/conversation/"+receiverID, nil, senderToken)
+		var conversation struct {
+			Data []models.MessageResponse `json:"data"`
+		}
+		parseResponse(w, &conversation)
+		for _, message := range conversation.Data {
+			if message.ID.String() == messageID {
+				return message.IsDelivered && message.DeliveredAt != nil
+			}
+		}
+		return false
+	}
+
+	t.Run("OnlyReceiverCanAck", func(t *testing.T) {
+		senderWS.send(t, map[string]interface{}{"type": "ack", "message_id": messageID})
+		senderWS.expectNoEvent(t, "delivery_receipt", 300*time.Millisecond)
+		assert.False(t, isDelivered())
+	})
+
+	t.Run("AckMarksDelivered", func(t *testing.T) {
+		receiverWS.send(t, map[string]interface{}{"type": "ack", "message_id": event["message_id"]})
+
+		receipt := senderWS.waitForEvent(t, "delivery_receipt", 2*time.Second)
+		assert.Equal(t, messageID, receipt["message_id"])
+		assert.Equal(t, receiverID, receipt["sender_id"])
+		data := receipt["data"].(map[string]interface{})
+		assert.Equal(t, messageID, data["message_id"])
+		assert.Equal(t, true, data["is_delivered"])
+		assert.NotEmpty(t, data["delivered_at"])
+		assert.True(t, isDelivered())
+
+		// Acking again changes nothing
+		receiverWS.send(t, map[string]interface{}{"type": "ack", "message_id": messageID})
+		senderWS.expectNoEvent(t, "delivery_receipt", 300*time.Millisecond)
+	})
+}
+
+// ============================================================================
+// NOTIFICATION PREFERENCE TESTS
+// ============================================================================
+
+func TestNotificationPreferences(t *testing.T) {
+	senderToken, _ := signupUser(t, "pref_sender")
+	receiverToken, receiverID := signupUser(t, "pref_receiver")
+
+	receiverDevice := "receiver-device-" + uuid.New().String()
+	assert.NoError(t, testUserRepo.UpdateDeviceToken(parseUUID(t, receiverID), receiverDevice, "android"))
+
+	send := func(content string) {
+		w := makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+			"receiver_id": receiverID,
+			"content":     content,
+		}, senderToken)
+		if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+	}
+
+	// preferences returns the receiver's preferences keyed by type
+	preferences := func(w *httptest.ResponseRecorder) map[string]map[string]interface{} {
+		var response map[string]interface{}
+		parseResponse(w, &response)
+
+		byType := make(map[string]map[string]interface{})
+		for _, item := range response["data"].([]interface{}) {
+			preference := item.(map[string]interface{})
+			byType[preference["type"].(string)] = preference
+		}
+		return byType
+	}
+
+	t.Run("Defaults", func(t *testing.T) {
+		w := makeRequest("GET", "/api/v1/notifications/preferences", nil, receiverToken)
+		if !assert.Equal(t, http.StatusOK, w.Code) {
+			t.FailNow()
+		}
+
+		byType := preferences(w)
+		assert.Len(t, byType, len(models.NotificationTypes))
+		for _, ntype := range models.NotificationTypes {
+			assert.Equal(t, true, byType[string(ntype)]["enabled"], ntype)
+			assert.Equal(t, true, byType[string(ntype)]["sound_enabled"], ntype)
+		}
+
+		send("pushed by default")
+		assert.Equal(t, 1, fakeFCM.pushedTo(receiverDevice))
+	})
+
+	t.Run("DisableMessages", func(t *testing.T) {
+		w := makeRequest("PUT", "/api/v1/notifications/preferences", map[string]interface{}{
+			"type":          "message",
+			"enabled":       false,
+			"sound_enabled": false,
+		}, receiverToken)
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+
+		byType := preferences(w)
+		assert.Equal(t, false, byType["message"]["enabled"])
+		assert.Equal(t, false, byType["message"]["sound_enabled"])
+		assert.Equal(t, true, byType["group_message"]["enabled"])
+
+		send("not pushed")
+		assert.Equal(t, 1, fakeFCM.pushedTo(receiverDevice))
+	})
+
+	t.Run("ReEnableMessages", func(t *testing.T) {
+		w := makeRequest("PUT", "/api/v1/notifications/preferences", map[string]interface{}{
+			"type":          "message",
+			"enabled":       true,
+			"sound_enabled": false,
+		}, receiverToken)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		send("pushed again")
+		assert.Equal(t, 2, fakeFCM.pushedTo(receiverDevice))
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		w := makeRequest("PUT", "/api/v1/notifications/preferences", map[string]interface{}{
+			"type":          "carrier_pigeon",
+			"enabled":       true,
+			"sound_enabled": true,
+		}, receiverToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		// Both flags are required so a partial update cannot silently turn one off
+		w = makeRequest("PUT", "/api/v1/notifications/preferences", map[string]interface{}{
+			"type": "message",
+		}, receiverToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+// ============================================================================
+// DO NOT DISTURB TESTS
+// ============================================================================
+
+func TestDoNotDisturb(t *testing.T) {
+	senderToken, _ := signupUser(t, "dnd_sender")
+	receiverToken, receiverID := signupUser(t, "dnd_receiver")
+
+	receiverDevice := "receiver-device-" + uuid.New().String()
+	assert.NoError(t, testUserRepo.UpdateDeviceToken(parseUUID(t, receiverID), receiverDevice, "android"))
+
+	send := func(content string) {
+		w := makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+			"receiver_id": receiverID,
+			"content":     content,
+		}, senderToken)
+		if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+	}
+
+	t.Run("CurrentHourSuppressesPush", func(t *testing.T) {
+		// A window from this hour to two hours later, crossing midnight late in the day
+		hour := time.Now().UTC().Hour()
+		w := makeRequest("PATCH", "/api/v1/users/me/dnd", map[string]interface{}{
+			"enabled":    true,
+			"start_hour": hour,
+			"end_hour":   (hour + 2) % 24,
+			"timezone":   "UTC",
+		}, receiverToken)
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		data := response["data"].(map[string]interface{})
+		assert.Equal(t, true, data["enabled"])
+		assert.Equal(t, float64(hour), data["start_hour"])
+		assert.Equal(t, "UTC", data["timezone"])
+
+		send("during the night")
+		assert.Equal(t, 0, fakeFCM.pushedTo(receiverDevice))
+	})
+
+	t.Run("DisabledSendsPush", func(t *testing.T) {
+		w := makeRequest("PATCH", "/api/v1/users/me/dnd", map[string]interface{}{
+			"enabled": false,
+		}, receiverToken)
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+
+		// Omitted fields are kept
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		assert.Equal(t, "UTC", response["data"].(map[string]interface{})["timezone"])
+
+		send("wide awake")
+		assert.Equal(t, 1, fakeFCM.pushedTo(receiverDevice))
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, body := range []map[string]interface{}{
+			{"start_hour": 24},
+			{"end_hour": -1},
+			{"timezone": "Mars/Olympus"},
+			{"timezone": ""},
+		} {
+			w := makeRequest("PATCH", "/api/v1/users/me/dnd", body, receiverToken)
+			assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		}
+	})
+}
+
+// ============================================================================
+// GROUP MESSAGE EDIT TESTS
+// ============================================================================
+
+func TestEditGroupMessage(t *testing.T) {
+	senderToken, senderID := signupUser(t, "gedit_sender")
+	memberToken, memberID := signupUser(t, "gedit_member")
+
+	memberWS := dialWebSocket(t, memberToken)
+	defer memberWS.close()
+	waitForOnline(t, memberID, true)
+
+	w := makeRequest("POST", "/api/v1/groups", map[string]interface{}{
+		"name":       "Edit Group",
+		"type":       "private",
+		"member_ids": []string{memberID},
+	}, senderToken)
+	if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+		t.FailNow()
+	}
+	var created map[string]interface{}
+	parseResponse(w, &created)
+	groupID := created["data"].(map[string]interface{})["id"].(string)
+
+	w = makeRequest("POST", "/api/v1/groups/messages", map[string]interface{}{
+		"group_id": groupID,
+		"content":  "Meeting at 10",
+	}, senderToken)
+	if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+		t.FailNow()
+	}
+	var sent map[string]interface{}
+	parseResponse(w, &sent)
+	messageID := sent["data"].(map[string]interface{})["id"].(string)
+	assert.Equal(t, false, sent["data"].(map[string]interface{})["edited"])
+
+	editPath := "/api/v1/groups/messages/" + messageID
+
+	t.Run("OnlySender", func(t *testing.T) {
+		w := makeRequest("PATCH", editPath, map[string]string{"content": "Meeting cancelled"}, memberToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Edit", func(t *testing.T) {
+		w := makeRequest("PATCH", editPath, map[string]string{"content": "Meeting at 11"}, senderToken)
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		data := response["data"].(map[string]interface{})
+		assert.Equal(t, "Meeting at 11", data["content"])
+		assert.Equal(t, "Meeting at 10", data["previous_content"])
+		assert.Equal(t, true, data["edited"])
+		assert.NotNil(t, data["edited_at"])
+
+		event := memberWS.waitForEvent(t, "group_message_edited", 2*time.Second)
+		assert.Equal(t, groupID, event["group_id"])
+		assert.Equal(t, senderID, event["sender_id"])
+		assert.Equal(t, "Meeting at 11", event["content"])
+		assert.Equal(t, messageID, event["data"].(map[string]interface{})["message_id"])
+	})
+
+	t.Run("ListedAsEdited", func(t *testing.T) {
+		w := makeRequest("GET", "/api/v1/groups/"+groupID+"/messages", nil, memberToken)
+		if !assert.Equal(t, http.StatusOK, w.Code) {
+			t.FailNow()
+		}
+
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		messages := response["data"].([]interface{})
+		if !assert.Len(t, messages, 1) {
+			t.FailNow()
+		}
+		message := messages[0].(map[string]interface{})
+		assert.Equal(t, "Meeting at 11", message["content"])
+		assert.Equal(t, "Meeting at 10", message["previous_content"])
+		assert.Equal(t, true, message["edited"])
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		w := makeRequest("PATCH", editPath, map[string]string{}, senderToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = makeRequest("PATCH", "/api/v1/groups/messages/"+uuid.New().String(), map[string]string{"content": "?"}, senderToken)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = makeRequest("PATCH", "/api/v2/groups/messages/not-a-uuid", map[string]string{"content": "?"}, senderToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+// ============================================================================
+// GROUP MESSAGE DELETE TESTS
+// ============================================================================
+
+func TestDeleteGroupMessage(t *testing.T) {
+	adminToken, adminID := signupUser(t, "gdel_admin")
+	memberToken, memberID := signupUser(t, "gdel_member")
+	otherToken, otherID := signupUser(t, "gdel_other")
+
+	memberWS := dialWebSocket(t, memberToken)
+	defer memberWS.close()
+	waitForOnline(t, memberID, true)
+
+	w := makeRequest("POST", "/api/v1/groups", map[string]interface{}{
+		"name":       "Delete Group",
+		"type":       "private",
+		"member_ids": []string{memberID, otherID},
+	}, adminToken)
+	if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+		t.FailNow()
+	}
+	var created map[string]interface{}
+	parseResponse(w, &created)
+	groupID := created["data"].(map[string]interface{})["id"].(string)
+
+	send := func(token, content string) string {
+		w := makeRequest("POST", "/api/v1/groups/messages", map[string]interface{}{
+			"group_id": groupID,
+			"content":  content,
+		}, token)
+		if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		return response["data"].(map[string]interface{})["id"].(string)
+	}
+
+	// listed returns the group's messages as the member sees them, keyed by ID
+	listed := func() map[string]map[string]interface{} {
+		w := makeRequest("GET", "/api/v1/groups/"+groupID+"/messages", nil, memberToken)
+		if !assert.Equal(t, http.StatusOK, w.Code) {
+			t.FailNow()
+		}
+		var response map[string]interface{}
+		parseResponse(w, &response)
+
+		byID := make(map[string]map[string]interface{})
+		for _, item := range response["data"].([]interface{}) {
+			message := item.(map[string]interface{})
+			byID[message["id"].(string)] = message
+		}
+		return byID
+	}
+
+	t.Run("BySender", func(t *testing.T) {
+		messageID := send(memberToken, "oops, wrong group")
+
+		w := makeRequest("DELETE", "/api/v1/groups/messages/"+messageID, nil, memberToken)
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		data := response["data"].(map[string]interface{})
+		assert.Equal(t, "[message deleted]", data["content"])
+		assert.Equal(t, true, data["is_deleted"])
+		assert.Equal(t, memberID, data["deleted_by"])
+
+		event := memberWS.waitForEvent(t, "group_message_deleted", 2*time.Second)
+		assert.Equal(t, groupID, event["group_id"])
+		assert.Equal(t, messageID, event["data"].(map[string]interface{})["message_id"])
+
+		// The row stays in the history without its content
+		message := listed()[messageID]
+		if assert.NotNil(t, message) {
+			assert.Equal(t, "[message deleted]", message["content"])
+			assert.Equal(t, true, message["is_deleted"])
+		}
+
+		w = makeRequest("DELETE", "/api/v1/groups/messages/"+messageID, nil, memberToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = makeRequest("PATCH", "/api/v1/groups/messages/"+messageID, map[string]string{"content": "fixed"}, memberToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("OtherMemberForbidden", func(t *testing.T) {
+		messageID := send(memberToken, "mine to keep")
+
+		w := makeRequest("DELETE", "/api/v1/groups/messages/"+messageID, nil, otherToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, false, listed()[messageID]["is_deleted"])
+	})
+
+	t.Run("ByAdmin", func(t *testing.T) {
+		messageID := send(otherToken, "spam")
+
+		w := makeRequest("DELETE", "/api/v2/groups/messages/"+messageID, nil, adminToken)
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+
+		message := listed()[messageID]
+		if assert.NotNil(t, message) {
+			assert.Equal(t, true, message["is_deleted"])
+			assert.Equal(t, adminID, message["deleted_by"])
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		w := makeRequest("DELETE", "/api/v1/groups/messages/"+uuid.New().String(), nil, adminToken)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+// ============================================================================
+// GROUP UNREAD COUNT TESTS
+// ============================================================================
+
+func TestGroupUnreadCount(t *testing.T) {
+	senderToken, _ := signupUser(t, "gunread_sender")
+	readerToken, readerID := signupUser(t, "gunread_reader")
+	outsiderToken, _ := signupUser(t, "gunread_outsider")
+
+	createGroup := func(name string) string {
+		w := makeRequest("POST", "/api/v1/groups", map[string]interface{}{
+			"name":       name,
+			"type":       "private",
+			"member_ids": []string{readerID},
+		}, senderToken)
+		if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		return response["data"].(map[string]interface{})["id"].(string)
+	}
+	send := func(groupID, content string) {
+		w := makeRequest("POST", "/api/v1/groups/messages", map[string]interface{}{
+			"group_id": groupID,
+			"content":  content,
+		}, senderToken)
+		if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+	}
+	unreadCount := func(groupID string) float64 {
+		w := makeRequest("GET", "/api/v1/groups/"+groupID+"/unread/count", nil, readerToken)
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		return response["count"].(float64)
+	}
+	// listedUnread returns the unread_count of each of the reader's groups
+	listedUnread := func(path string) map[string]float64 {
+		w := makeRequest("GET", path, nil, readerToken)
+		if !assert.Equal(t, http.StatusOK, w.Code) {
+			t.FailNow()
+		}
+		var response map[string]interface{}
+		parseResponse(w, &response)
+
+		counts := make(map[string]float64)
+		for _, item := range response["data"].([]interface{}) {
+			group := item.(map[string]interface{})
+			counts[group["id"].(string)] = group["unread_count"].(float64)
+		}
+		return counts
+	}
+
+	busyID := createGroup("Busy Group")
+	quietID := createGroup("Quiet Group")
+	send(busyID, "first")
+	send(busyID, "second")
+	send(quietID, "only")
+
+	t.Run("Count", func(t *testing.T) {
+		assert.Equal(t, float64(2), unreadCount(busyID))
+		assert.Equal(t, float64(1), unreadCount(quietID))
+
+		// The sender's own messages are never unread for them
+		w := makeRequest("GET", "/api/v1/groups/"+busyID+"/unread/count", nil, senderToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		assert.Equal(t, float64(0), response["count"])
+	})
+
+	t.Run("ListedWithGroups", func(t *testing.T) {
+		for _, path := range []string{"/api/v1/groups/my", "/api/v2/groups/my"} {
+			counts := listedUnread(path)
+			assert.Equal(t, float64(2), counts[busyID], path)
+			assert.Equal(t, float64(1), counts[quietID], path)
+		}
+	})
+
+	t.Run("ReadingClearsCount", func(t *testing.T) {
+		w := makeRequest("GET", "/api/v1/groups/"+busyID+"/messages", nil, readerToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		assert.Equal(t, float64(0), unreadCount(busyID))
+		assert.Equal(t, float64(1), unreadCount(quietID))
+		assert.Equal(t, float64(0), listedUnread("/api/v1/groups/my")[busyID])
+	})
+
+	t.Run("NonMember", func(t *testing.T) {
+		w := makeRequest("GET", "/api/v1/groups/"+busyID+"/unread/count", nil, outsiderToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = makeRequest("GET", "/api/v1/groups/not-a-uuid/unread/count", nil, readerToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+// ============================================================================
+// GROUP INVITE LINK TESTS
+// ============================================================================
+
+func TestGroupInviteLinks(t *testing.T) {
+	adminToken, _ := signupUser(t, "ginvite_admin")
+	memberToken, memberID := signupUser(t, "ginvite_member")
+	firstToken, firstID := signupUser(t, "ginvite_first")
+	secondToken, _ := signupUser(t, "ginvite_second")
+
+	w := makeRequest("POST", "/api/v1/groups", map[string]interface{}{
+		"name":       "Invite Group",
+		"type":       "private",
+		"member_ids": []string{memberID},
+	}, adminToken)
+	if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+		t.FailNow()
+	}
+	var created map[string]interface{}
+	parseResponse(w, &created)
+	groupID := created["data"].(map[string]interface{})["id"].(string)
+	invitePath := "/api/v1/groups/" + groupID + "/invite"
+
+	createInvite := func(body interface{}) string {
+		w := makeRequest("POST", invitePath, body, adminToken)
+		if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		token := response["data"].(map[string]interface{})["token"].(string)
+		assert.Len(t, token, 64)
+		return token
+	}
+
+	t.Run("AdminOnly", func(t *testing.T) {
+		w := makeRequest("POST", invitePath, map[string]int{"expires_in_hours": 1}, memberToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = makeRequest("POST", invitePath, map[string]int{"expires_in_hours": 1}, firstToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("InvalidParameters", func(t *testing.T) {
+		for _, body := range []map[string]int{
+			{"expires_in_hours": -1},
+			{"expires_in_hours": 721},
+			{"max_uses": -1},
+		} {
+			w := makeRequest("POST", invitePath, body, adminToken)
+			assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		}
+	})
+
+	t.Run("SingleUse", func(t *testing.T) {
+		token := createInvite(map[string]int{"expires_in_hours": 1, "max_uses": 1})
+
+		w := makeRequest("POST", "/api/v1/groups/join/"+token, nil, firstToken)
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		assert.Equal(t, groupID, response["data"].(map[string]interface{})["id"])
+		assert.True(t, parseGroupMembers(t, groupID, adminToken)[firstID])
+
+		// The link is used up
+		w = makeRequest("POST", "/api/v1/groups/join/"+token, nil, firstToken)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w = makeRequest("POST", "/api/v1/groups/join/"+token, nil, secondToken)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Unlimited", func(t *testing.T) {
+		token := createInvite(nil)
+
+		// Existing members are not counted
+		w := makeRequest("POST", "/api/v2/groups/join/"+token, nil, memberToken)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		w = makeRequest("POST", "/api/v2/groups/join/"+token, nil, secondToken)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var invite models.GroupInvite
+		if assert.NoError(t, db.Where("token = ?", token).First(&invite).Error) {
+			assert.Equal(t, 1, invite.UsedCount)
+			assert.Equal(t, 0, invite.MaxUses)
+			assert.WithinDuration(t, time.Now().Add(24*time.Hour), invite.ExpiresAt, time.Minute)
+		}
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		token := createInvite(map[string]int{"expires_in_hours": 1})
+		db.Model(&models.GroupInvite{}).Where("token = ?", token).Update("expires_at", time.Now().Add(-time.Minute))
+
+		lateToken, lateID := signupUser(t, "ginvite_late")
+		w := makeRequest("POST", "/api/v1/groups/join/"+token, nil, lateToken)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.False(t, parseGroupMembers(t, groupID, adminToken)[lateID])
+	})
+
+	t.Run("UnknownToken", func(t *testing.T) {
+		w := makeRequest("POST", "/api/v1/groups/join/"+strings.Repeat("0", 64), nil, secondToken)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+// ============================================================================
+// GROUP MODERATOR ROLE TESTS
+// ============================================================================
+
+func TestGroupModeratorRole(t *testing.T) {
+	ownerToken, _ := signupUser(t, "gmod_owner")
+	adminToken, adminID := signupUser(t, "gmod_admin")
+	modToken, modID := signupUser(t, "gmod_mod")
+	memberToken, memberID := signupUser(t, "gmod_member")
+	_, leaverID := signupUser(t, "gmod_leaver")
+	_, outsiderID := signupUser(t, "gmod_outsider")
+
+	memberWS := dialWebSocket(t, memberToken)
+	defer memberWS.close()
+	waitForOnline(t, memberID, true)
+
+	w := makeRequest("POST", "/api/v1/groups", map[string]interface{}{
+		"name":       "Moderated Group",
+		"type":       "private",
+		"member_ids": []string{adminID, modID, memberID, leaverID},
+	}, ownerToken)
+	if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+		t.FailNow()
+	}
+	var created map[string]interface{}
+	parseResponse(w, &created)
+	groupID := created["data"].(map[string]interface{})["id"].(string)
+
+	rolePath := func(userID string) string {
+		return "/api/v1/groups/" + groupID + "/members/" + userID + "/role"
+	}
+	send := func(token, content string) string {
+		w := makeRequest("POST", "/api/v1/groups/messages", map[string]interface{}{
+			"group_id": groupID,
+			"content":  content,
+		}, token)
+		if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		return response["data"].(map[string]interface{})["id"].(string)
+	}
+
+	t.Run("Promote", func(t *testing.T) {
+		w := makeRequest("PATCH", rolePath(adminID), map[string]string{"role": "admin"}, ownerToken)
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+		assert.Equal(t, models.MemberRoleAdmin, memberRole(t, groupID, adminID))
+
+		w = makeRequest("PATCH", "/api/v2/groups/"+groupID+"/members/"+modID+"/role", map[string]string{"role": "moderator"}, adminToken)
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+		assert.Equal(t, models.MemberRoleModerator, memberRole(t, groupID, modID))
+
+		event := memberWS.waitForEvent(t, "group_member_role_changed", 2*time.Second)
+		assert.Equal(t, groupID, event["group_id"])
+	})
+
+	t.Run("RoleChangeRules", func(t *testing.T) {
+		// Only the owner makes admins
+		w := makeRequest("PATCH", rolePath(memberID), map[string]string{"role": "admin"}, adminToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		// Moderators cannot change roles
+		w = makeRequest("PATCH", rolePath(memberID), map[string]string{"role": "moderator"}, modToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		// Ownership moves with a transfer
+		w = makeRequest("PATCH", rolePath(adminID), map[string]string{"role": "owner"}, ownerToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = makeRequest("PATCH", rolePath(memberID), map[string]string{"role": "superuser"}, ownerToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = makeRequest("PATCH", rolePath(outsiderID), map[string]string{"role": "moderator"}, ownerToken)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = makeRequest("PATCH", rolePath("not-a-uuid"), map[string]string{"role": "moderator"}, ownerToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		assert.Equal(t, models.MemberRoleMember, memberRole(t, groupID, memberID))
+	})
+
+	t.Run("ModeratesMessages", func(t *testing.T) {
+		messageID := send(memberToken, "buy cheap watches")
+
+		w := makeRequest("PATCH", "/api/v1/groups/messages/"+messageID, map[string]string{"content": "[removed link]"}, modToken)
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+
+		w = makeRequest("DELETE", "/api/v1/groups/messages/"+messageID, nil, modToken)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		// Messages from higher roles are out of reach
+		adminMessageID := send(adminToken, "welcome everyone")
+		w = makeRequest("DELETE", "/api/v1/groups/messages/"+adminMessageID, nil, modToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = makeRequest("PATCH", "/api/v1/groups/messages/"+adminMessageID, map[string]string{"content": "bye"}, modToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("RemovesMembers", func(t *testing.T) {
+		w := makeRequest("DELETE", "/api/v1/groups/"+groupID+"/members/"+adminID, nil, modToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = makeRequest("DELETE", "/api/v1/groups/"+groupID+"/members/"+leaverID, nil, modToken)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.False(t, parseGroupMembers(t, groupID, ownerToken)[leaverID])
+	})
+
+	t.Run("CannotManageGroup", func(t *testing.T) {
+		w := makeRequest("POST", "/api/v1/groups/"+groupID+"/invite", nil, modToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = makeRequest("DELETE", "/api/v1/groups/"+groupID, nil, modToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Demote", func(t *testing.T) {
+		w := makeRequest("PATCH", rolePath(modID), map[string]string{"role": "member"}, adminToken)
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+		assert.Equal(t, models.MemberRoleMember, memberRole(t, groupID, modID))
+
+		messageID := send(memberToken, "still here")
+		w = makeRequest("DELETE", "/api/v1/groups/messages/"+messageID, nil, modToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
+
+// ============================================================================
+// GROUP OWNER TRANSFER TESTS
+// ============================================================================
+
+func TestPutGroupOwner(t *testing.T) {
+	ownerToken, ownerID := signupUser(t, "gown_owner")
+	heirToken, heirID := signupUser(t, "gown_heir")
+	_, outsiderID := signupUser(t, "gown_outsider")
+
+	heirWS := dialWebSocket(t, heirToken)
+	defer heirWS.close()
+	waitForOnline(t, heirID, true)
+
+	w := makeRequest("POST", "/api/v1/groups", map[string]interface{}{
+		"name":       "Inherited Group",
+		"type":       "private",
+		"member_ids": []string{heirID},
+	}, ownerToken)
+	if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+		t.FailNow()
+	}
+	var created map[string]interface{}
+	parseResponse(w, &created)
+	groupID := created["data"].(map[string]interface{})["id"].(string)
+	ownerPath := "/api/v1/groups/" + groupID + "/owner"
+
+	t.Run("Rejected", func(t *testing.T) {
+		w := makeRequest("PUT", ownerPath, map[string]string{"user_id": heirID}, heirToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = makeRequest("PUT", ownerPath, map[string]string{"user_id": outsiderID}, ownerToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = makeRequest("PUT", ownerPath, map[string]string{}, ownerToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Transfer", func(t *testing.T) {
+		w := makeRequest("PUT", ownerPath, map[string]string{"user_id": heirID}, ownerToken)
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+
+		assert.Equal(t, models.MemberRoleOwner, memberRole(t, groupID, heirID))
+		assert.Equal(t, models.MemberRoleAdmin, memberRole(t, groupID, ownerID))
+
+		var group models.Group
+		if assert.NoError(t, db.First(&group, "id = ?", groupID).Error) {
+			assert.Equal(t, heirID, group.CreatedBy.String())
+		}
+
+		event := heirWS.waitForEvent(t, "group_owner_changed", 2*time.Second)
+		assert.Equal(t, groupID, event["group_id"])
+		assert.Equal(t, heirID, event["data"].(map[string]interface{})["user_id"])
+	})
+
+	t.Run("OnlyNewOwnerDeletes", func(t *testing.T) {
+		w := makeRequest("DELETE", "/api/v1/groups/"+groupID, nil, ownerToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = makeRequest("DELETE", "/api/v2/groups/"+groupID, nil, heirToken)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+}
+
+// ============================================================================
+// GROUP PINNED MESSAGE TESTS
+// ============================================================================
+
+func TestPinGroupMessage(t *testing.T) {
+	ownerToken, _ := signupUser(t, "gpin_owner")
+	memberToken, memberID := signupUser(t, "gpin_member")
+	outsiderToken, _ := signupUser(t, "gpin_outsider")
+
+	memberWS := dialWebSocket(t, memberToken)
+	defer memberWS.close()
+	waitForOnline(t, memberID, true)
+
+	createGroup := func(name string) string {
+		w := makeRequest("POST", "/api/v1/groups", map[string]interface{}{
+			"name":       name,
+			"type":       "private",
+			"member_ids": []string{memberID},
+		}, ownerToken)
+		if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+		var created map[string]interface{}
+		parseResponse(w, &created)
+		return created["data"].(map[string]interface{})["id"].(string)
+	}
+	groupID := createGroup("Pin Group")
+	otherGroupID := createGroup("Other Pin Group")
+
+	send := func(groupID, content string) string {
+		w := makeRequest("POST", "/api/v1/groups/messages", map[string]interface{}{
+			"group_id": groupID,
+			"content":  content,
+		}, ownerToken)
+		if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		return response["data"].(map[string]interface{})["id"].(string)
+	}
+	rulesID := send(groupID, "House rules: be kind")
+
+	// pinned returns the group as the member sees it
+	pinned := func(version string) map[string]interface{} {
+		w := makeRequest("GET", "/api/"+version+"/groups/"+groupID, nil, memberToken)
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		return response["data"].(map[string]interface{})
+	}
+
+	t.Run("Pin", func(t *testing.T) {
+		w := makeRequest("PUT", "/api/v1/groups/"+groupID+"/pin/"+rulesID, nil, ownerToken)
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+
+		event := memberWS.waitForEvent(t, "message_pinned", 2*time.Second)
+		assert.Equal(t, groupID, event["group_id"])
+		assert.Equal(t, rulesID, event["data"].(map[string]interface{})["message_id"])
+
+		for _, version := range []string{"v1", "v2"} {
+			group := pinned(version)
+			assert.Equal(t, rulesID, group["pinned_message_id"], version)
+			assert.NotNil(t, group["pinned_at"], version)
+			if message, ok := group["pinned_message"].(map[string]interface{}); assert.True(t, ok, version) {
+				assert.Equal(t, "House rules: be kind", message["content"], version)
+			}
+		}
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		w := makeRequest("PUT", "/api/v1/groups/"+groupID+"/pin/"+rulesID, nil, outsiderToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		foreignID := send(otherGroupID, "elsewhere")
+		w = makeRequest("PUT", "/api/v1/groups/"+groupID+"/pin/"+foreignID, nil, ownerToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = makeRequest("PUT", "/api/v1/groups/"+groupID+"/pin/"+uuid.New().String(), nil, ownerToken)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = makeRequest("PUT", "/api/v1/groups/"+groupID+"/pin/not-a-uuid", nil, ownerToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		assert.Equal(t, rulesID, pinned("v1")["pinned_message_id"])
+	})
+
+	t.Run("Unpin", func(t *testing.T) {
+		w := makeRequest("DELETE", "/api/v1/groups/"+groupID+"/pin", nil, ownerToken)
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+
+		event := memberWS.waitForEvent(t, "message_unpinned", 2*time.Second)
+		assert.Equal(t, groupID, event["group_id"])
+
+		group := pinned("v1")
+		assert.Nil(t, group["pinned_message_id"])
+		assert.Nil(t, group["pinned_at"])
+		assert.Nil(t, group["pinned_message"])
+
+		// Unpinning again changes nothing
+		w = makeRequest("DELETE", "/api/v2/groups/"+groupID+"/pin", nil, ownerToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("DeletedMessageIsHidden", func(t *testing.T) {
+		messageID := send(groupID, "temporary notice")
+		w := makeRequest("PUT", "/api/v1/groups/"+groupID+"/pin/"+messageID, nil, ownerToken)
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+
+		w = makeRequest("DELETE", "/api/v1/groups/messages/"+messageID, nil, ownerToken)
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+		assert.Nil(t, pinned("v1")["pinned_message"])
+
+		w = makeRequest("PUT", "/api/v1/groups/"+groupID+"/pin/"+messageID, nil, ownerToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+// ============================================================================
+// GROUP SETTINGS TESTS
+// ============================================================================
+
+func TestGroupSettingsAnnouncementsOnly(t *testing.T) {
+	ownerToken, _ := signupUser(t, "gset_owner")
+	modToken, modID := signupUser(t, "gset_mod")
+	memberToken, memberID := signupUser(t, "gset_member")
+
+	w := makeRequest("POST", "/api/v1/groups", map[string]interface{}{
+		"name":       "Announcements",
+		"type":       "private",
+		"member_ids": []string{modID, memberID},
+	}, ownerToken)
+	if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+		t.FailNow()
+	}
+	var created map[string]interface{}
+	parseResponse(w, &created)
+	groupID := created["data"].(map[string]interface{})["id"].(string)
+	assert.Equal(t, false, created["data"].(map[string]interface{})["announcements_only"])
+
+	settingsPath := "/api/v1/groups/" + groupID + "/settings"
+	post := func(token string) int {
+		return makeRequest("POST", "/api/v1/groups/messages", map[string]interface{}{
+			"group_id": groupID,
+			"content":  "hello",
+		}, token).Code
+	}
+
+	w = makeRequest("PATCH", "/api/v1/groups/"+groupID+"/members/"+modID+"/role", map[string]string{"role": "moderator"}, ownerToken)
+	if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		t.FailNow()
+	}
+
+	t.Run("MembersCannotChangeSettings", func(t *testing.T) {
+		w := makeRequest("PATCH", settingsPath, map[string]bool{"announcements_only": true}, memberToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = makeRequest("PATCH", settingsPath, map[string]bool{"announcements_only": true}, modToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("EnableAnnouncementsOnly", func(t *testing.T) {
+		w := makeRequest("PATCH", settingsPath, map[string]interface{}{
+			"announcements_only": true,
+			"description":        "Read-only news",
+		}, ownerToken)
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		data := response["data"].(map[string]interface{})
+		assert.Equal(t, true, data["announcements_only"])
+		assert.Equal(t, "Read-only news", data["description"])
+		assert.Equal(t, "private", data["type"])
+
+		assert.Equal(t, http.StatusForbidden, post(memberToken))
+		assert.Equal(t, http.StatusCreated, post(modToken))
+		assert.Equal(t, http.StatusCreated, post(ownerToken))
+	})
+
+	t.Run("InvalidType", func(t *testing.T) {
+		w := makeRequest("PATCH", settingsPath, map[string]string{"type": "secret"}, ownerToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("DisableAnnouncementsOnly", func(t *testing.T) {
+		w := makeRequest("PATCH", "/api/v2/groups/"+groupID+"/settings", map[string]bool{"announcements_only": false}, ownerToken)
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+
+		assert.Equal(t, http.StatusCreated, post(memberToken))
+	})
+}
+
+// ============================================================================
+// HUB GROUP ROUTING TESTS
+// ============================================================================
+
+func TestHubGroupRoutingFollowsMembership(t *testing.T) {
+	ownerToken, _ := signupUser(t, "ghub_owner")
+	memberToken, memberID := signupUser(t, "ghub_member")
+	lateToken, lateID := signupUser(t, "ghub_late")
+
+	memberWS := dialWebSocket(t, memberToken)
+	defer memberWS.close()
+	waitForOnline(t, memberID, true)
+	lateWS := dialWebSocket(t, lateToken)
+	defer lateWS.close()
+	waitForOnline(t, lateID, true)
+
+	w := makeRequest("POST", "/api/v1/groups", map[string]interface{}{
+		"name":       "Hub Routing",
+		"type":       "private",
+		"member_ids": []string{memberID},
+	}, ownerToken)
+	if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+		t.FailNow()
+	}
+	var created map[string]interface{}
+	parseResponse(w, &created)
+	groupID := created["data"].(map[string]interface{})["id"].(string)
+
+	broadcast := func(content string) {
+		testHub.BroadcastToGroup(parseUUID(t, groupID), &websocket.Message{
+			Type:      "hub_group_ping",
+			GroupID:   parseUUID(t, groupID),
+			Content:   content,
+			Timestamp: time.Now(),
+		})
+	}
+
+	t.Run("Created", func(t *testing.T) {
+		broadcast("after create")
+		event := memberWS.waitForEvent(t, "hub_group_ping", 2*time.Second)
+		assert.Equal(t, "after create", event["content"])
+		lateWS.expectNoEvent(t, "hub_group_ping", 300*time.Millisecond)
+	})
+
+	t.Run("Added", func(t *testing.T) {
+		w := makeRequest("POST", "/api/v1/groups/"+groupID+"/members", map[string]string{"user_id": lateID}, ownerToken)
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+
+		broadcast("after add")
+		lateWS.waitForEvent(t, "hub_group_ping", 2*time.Second)
+	})
+
+	t.Run("Removed", func(t *testing.T) {
+		w := makeRequest("DELETE", "/api/v1/groups/"+groupID+"/members/"+memberID, nil, ownerToken)
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+
+		broadcast("after remove")
+		lateWS.waitForEvent(t, "hub_group_ping", 2*time.Second)
+		memberWS.expectNoEvent(t, "hub_group_ping", 300*time.Millisecond)
+	})
+}
+
+// ============================================================================
+// WEBSOCKET CONNECTION LIMIT TESTS
+// ============================================================================
+
+func TestWebSocketConnectionLimit(t *testing.T) {
+	token, userID := signupUser(t, "ws_limit")
+	limit := config.AppConfig.Server.WSMaxConnections
+
+	connections := make([]*wsTestClient, 0, limit+1)
+	defer func() {
+		for _, ws := range connections {
+			ws.close()
+		}
+	}()
+	for i := 0; i < limit; i++ {
+		connections = append(connections, dialWebSocket(t, token))
+		waitForOnline(t, userID, true)
+		// Let the hub register each connection before the next, so the first stays the oldest
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	connections = append(connections, dialWebSocket(t, token))
+
+	oldest := connections[0]
+	oldest.waitForEvent(t, "connection_evicted", 2*time.Second)
+	select {
+	case _, ok := <-oldest.events:
+		assert.False(t, ok, "expected the evicted connection to be closed")
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for the evicted connection to close")
+	}
+
+	// Every remaining connection still gets the user's events
+	w := makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+		"receiver_id": userID,
+		"content":     "to every device",
+	}, aliceToken)
+	if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+		t.FailNow()
+	}
+	for _, ws := range connections[1:] {
+		ws.waitForMessage(t, "to every device", 2*time.Second)
+	}
+}
+
+// ============================================================================
+// WEBSOCKET MISSED MESSAGES TESTS
+// ============================================================================
+
+func TestWebSocketMissedMessagesOnReconnect(t *testing.T) {
+	senderToken, senderID := signupUser(t, "ws_missed_sender")
+	receiverToken, receiverID := signupUser(t, "ws_missed_receiver")
+
+	w := makeRequest("POST", "/api/v1/groups", map[string]interface{}{
+		"name":       "Missed Group",
+		"type":       "private",
+		"member_ids": []string{receiverID},
+	}, senderToken)
+	if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+		t.FailNow()
+	}
+	var created map[string]interface{}
+	parseResponse(w, &created)
+	groupID := created["data"].(map[string]interface{})["id"].(string)
+
+	sendDirect := func(content string) string {
+		w := makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+			"receiver_id": receiverID,
+			"content":     content,
+		}, senderToken)
+		if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		return response["data"].(map[string]interface{})["id"].(string)
+	}
+
+	lastSeenID := sendDirect("seen before disconnecting")
+	time.Sleep(10 * time.Millisecond)
+	sendDirect("missed direct")
+	w = makeRequest("POST", "/api/v1/groups/messages", map[string]interface{}{
+		"group_id": groupID,
+		"content":  "missed in group",
+	}, senderToken)
+	if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+		t.FailNow()
+	}
+
+	contents := func(items interface{}) []string {
+		var out []string
+		for _, item := range items.([]interface{}) {
+			out = append(out, item.(map[string]interface{})["content"].(string))
+		}
+		return out
+	}
+
+	t.Run("DeliveredFirst", func(t *testing.T) {
+		ws := dialWebSocketQuery(t, receiverToken, "last_seen_message_id="+lastSeenID)
+		defer ws.close()
+
+		select {
+		case event := <-ws.events:
+			if !assert.Equal(t, "pending_messages", event["type"]) {
+				t.FailNow()
+			}
+			data := event["data"].(map[string]interface{})
+			assert.Equal(t, []string{"missed direct"}, contents(data["messages"]))
+			assert.Equal(t, []string{"missed in group"}, contents(data["group_messages"]))
+		case <-time.After(2 * time.Second):
+			t.Fatal("timeout waiting for pending_messages")
+		}
+
+		// Each missed message arrives once, in pending_messages only
+		deadline := time.After(300 * time.Millisecond)
+	drain:
+		for {
+			select {
+			case event, ok := <-ws.events:
+				if !ok {
+					break drain
+				}
+				if event["type"] == "new_message" || event["type"] == "new_group_message" {
+					t.Fatalf("missed message delivered again: %v", event)
+				}
+			case <-deadline:
+				break drain
+			}
+		}
+		ws.close()
+		waitForOnline(t, receiverID, false)
+	})
+
+	t.Run("FromGroupMessage", func(t *testing.T) {
+		var groupMessage models.GroupMessage
+		if !assert.NoError(t, db.Where("group_id = ?", groupID).First(&groupMessage).Error) {
+			t.FailNow()
+		}
+		time.Sleep(10 * time.Millisecond)
+		sendDirect("after the group message")
+
+		ws := dialWebSocketQuery(t, receiverToken, "last_seen_message_id="+groupMessage.ID.String())
+		defer ws.close()
+
+		event := ws.waitForEvent(t, "pending_messages", 2*time.Second)
+		data := event["data"].(map[string]interface{})
+		assert.Equal(t, []string{"after the group message"}, contents(data["messages"]))
+		assert.Empty(t, data["group_messages"])
+		ws.close()
+		waitForOnline(t, receiverID, false)
+	})
+
+	t.Run("InvalidID", func(t *testing.T) {
+		wsURL := "ws" + strings.TrimPrefix(testServer.URL, "http") + "/api/v1/ws?token=" + receiverToken + "&last_seen_message_id=nope"
+		_, resp, err := gorillaws.DefaultDialer.Dial(wsURL, nil)
+		assert.Error(t, err)
+		if assert.NotNil(t, resp) {
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		}
+	})
+
+	_ = senderID
+}
+
+// ============================================================================
+// METRICS TESTS
+// ============================================================================
+
+func TestMetricsEndpoint(t *testing.T) {
+	w := makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+		"receiver_id": bobID,
+		"content":     "counted",
+	}, aliceToken)
+	if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+		t.FailNow()
+	}
+
+	// Scraping needs no token
+	w = makeRequest("GET", "/metrics", nil, "")
+	if !assert.Equal(t, http.StatusOK, w.Code) {
+		t.FailNow()
+	}
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/plain")
+
+	body := w.Body.String()
+	assert.Contains(t, body, `http_requests_total{method="POST",path="/api/v1/messages",status="201"}`)
+	assert.Contains(t, body, `http_request_duration_seconds_bucket{method="POST",path="/api/v1/messages"`)
+	assert.Contains(t, body, "messages_sent_total")
+	assert.Contains(t, body, "websocket_connected_clients")
+}
+
+// ============================================================================
+// REQUEST ID TESTS
+// ============================================================================
+
+func TestRequestID(t *testing.T) {
+	t.Run("Echoed", func(t *testing.T) {
+		w := makeRequestWithHeaders("GET", "/api/v1/auth/me", nil, aliceToken, map[string]string{
+			middleware.RequestIDHeader: "trace-123",
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "trace-123", w.Header().Get(middleware.RequestIDHeader))
+	})
+
+	t.Run("SetByHelper", func(t *testing.T) {
+		// makeRequest sends its own ID, which comes back even on errors
+		w := makeRequest("GET", "/api/v1/auth/me", nil, "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		_, err := uuid.Parse(w.Header().Get(middleware.RequestIDHeader))
+		assert.NoError(t, err)
+	})
+
+	t.Run("GeneratedWhenMissingOrInvalid", func(t *testing.T) {
+		for _, sent := range []string{"", "has spaces", strings.Repeat("x", 200)} {
+			req, _ := http.NewRequest("GET", "/health", nil)
+			if sent != "" {
+				req.Header.Set(middleware.RequestIDHeader, sent)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			got := w.Header().Get(middleware.RequestIDHeader)
+			_, err := uuid.Parse(got)
+			assert.NoError(t, err, "expected a generated ID for %q, got %q", sent, got)
+		}
+	})
+}
+
+// ============================================================================
+// NOTIFICATION ENDPOINT TESTS
+// ============================================================================
+
+func TestNotificationEndpoints(t *testing.T) {
+	ownerToken, ownerID := signupUser(t, "notif_owner")
+	otherToken, _ := signupUser(t, "notif_other")
+	ids := createNotifications(t, ownerID, 3)
+
+	unreadCount := func(t *testing.T) float64 {
+		t.Helper()
+		w := makeRequest("GET", "/api/v1/notifications/unread/count", nil, ownerToken)
+		if !assert.Equal(t, http.StatusOK, w.Code) {
+			t.FailNow()
+		}
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		return response["unread_count"].(float64)
+	}
+
+	t.Run("ListPaginated", func(t *testing.T) {
+		w := makeRequest("GET", "/api/v1/notifications?limit=2&offset=0", nil, ownerToken)
+		if !assert.Equal(t, http.StatusOK, w.Code) {
+			t.FailNow()
+		}
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		assert.Len(t, response["data"], 2)
+		meta := response["meta"].(map[string]interface{})
+		assert.Equal(t, float64(3), meta["total"])
+		assert.Equal(t, true, meta["has_more"])
+	})
+
+	t.Run("MarkOneAsRead", func(t *testing.T) {
+		assert.Equal(t, float64(3), unreadCount(t))
+
+		w := makeRequest("PUT", "/api/v1/notifications/"+ids[0].String()+"/read", nil, ownerToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, float64(2), unreadCount(t))
+
+		w = makeRequest("GET", "/api/v1/notifications/unread", nil, ownerToken)
+		if !assert.Equal(t, http.StatusOK, w.Code) {
+			t.FailNow()
+		}
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		data := response["data"].([]interface{})
+		assert.Len(t, data, 2)
+		for _, item := range data {
+			assert.NotEqual(t, ids[0].String(), item.(map[string]interface{})["id"])
+		}
+	})
+
+	t.Run("OthersNotificationsAreNotFound", func(t *testing.T) {
+		w := makeRequest("PUT", "/api/v1/notifications/"+ids[1].String()+"/read", nil, otherToken)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w = makeRequest("DELETE", "/api/v1/notifications/"+ids[1].String(), nil, otherToken)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, float64(2), unreadCount(t))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		w := makeRequest("DELETE", "/api/v1/notifications/"+ids[1].String(), nil, ownerToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, float64(1), unreadCount(t))
+
+		w = makeRequest("DELETE", "/api/v1/notifications/"+ids[1].String(), nil, ownerToken)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("InvalidID", func(t *testing.T) {
+		w := makeRequest("PUT", "/api/v1/notifications/not-a-uuid/read", nil, ownerToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("RequiresAuth", func(t *testing.T) {
+		w := makeRequest("GET", "/api/v1/notifications/unread/count", nil, "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+// ============================================
+// TWO-FACTOR AUTHENTICATION TESTS
+// ============================================
+
+func TestTwoFactorAuth(t *testing.T) {
+	token, _ := signupUser(t, "totp_user")
+	login := map[string]string{"identifier": "totp_user", "password": "Test1234!"}
+
+	w := makeRequest("POST", "/api/v1/auth/2fa/setup", nil, token)
+	if !assert.Equal(t, http.StatusOK, w.Code) {
+		t.FailNow()
+	}
+	var setup map[string]interface{}
+	parseResponse(w, &setup)
+	data := setup["data"].(map[string]interface{})
+	secret := data["secret"].(string)
+	assert.True(t, strings.HasPrefix(data["otpauth_url"].(string), "otpauth://totp/"))
+	assert.NotEmpty(t, data["qr_code"])
+
+	t.Run("NotEnforcedBeforeVerification", func(t *testing.T) {
+		w := makeRequest("POST", "/api/v1/auth/login", login, "")
+		if !assert.Equal(t, http.StatusOK, w.Code) {
+			t.FailNow()
+		}
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		assert.NotEmpty(t, response["data"].(map[string]interface{})["token"])
+	})
+
+	t.Run("VerifyRejectsWrongCode", func(t *testing.T) {
+		w := makeRequest("POST", "/api/v1/auth/2fa/verify", map[string]string{"code": "000000"}, token)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	code, err := totp.GenerateCode(secret, time.Now())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	w = makeRequest("POST", "/api/v1/auth/2fa/verify", map[string]string{"code": code}, token)
+	if !assert.Equal(t, http.StatusOK, w.Code) {
+		t.FailNow()
+	}
+
+	var twoFactorToken string
+	t.Run("LoginRequiresCode", func(t *testing.T) {
+		w := makeRequest("POST", "/api/v1/auth/login", login, "")
+		if !assert.Equal(t, http.StatusOK, w.Code) {
+			t.FailNow()
+		}
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		data := response["data"].(map[string]interface{})
+		assert.Equal(t, true, data["pending_2fa"])
+		assert.Nil(t, data["token"])
+		twoFactorToken, _ = data["two_factor_token"].(string)
+		if !assert.NotEmpty(t, twoFactorToken) {
+			t.FailNow()
+		}
+
+		// The intermediate token does not grant access to the API
+		w = makeRequest("GET", "/api/v1/auth/me", nil, twoFactorToken)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Confirm", func(t *testing.T) {
+		w := makeRequest("POST", "/api/v1/auth/2fa/confirm", map[string]string{"token": twoFactorToken, "code": "000000"}, "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		code, _ := totp.GenerateCode(secret, time.Now())
+		w = makeRequest("POST", "/api/v1/auth/2fa/confirm", map[string]string{"token": twoFactorToken, "code": code}, "")
+		if !assert.Equal(t, http.StatusOK, w.Code) {
+			t.FailNow()
+		}
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		sessionToken := response["data"].(map[string]interface{})["token"].(string)
+
+		w = makeRequest("GET", "/api/v1/auth/me", nil, sessionToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Disable", func(t *testing.T) {
+		w := makeRequest("POST", "/api/v1/auth/2fa/disable", map[string]string{"code": "000000"}, token)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		code, _ := totp.GenerateCode(secret, time.Now())
+		w = makeRequest("POST", "/api/v1/auth/2fa/disable", map[string]string{"code": code}, token)
+		if !assert.Equal(t, http.StatusOK, w.Code) {
+			t.FailNow()
+		}
+
+		w = makeRequest("POST", "/api/v1/auth/login", login, "")
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		assert.NotEmpty(t, response["data"].(map[string]interface{})["token"])
+	})
+}
+
+// ============================================
+// GROUP MESSAGE REACTION TESTS
+// ============================================
+
+func TestGroupMessageReactions(t *testing.T) {
+	posterToken, _ := signupUser(t, "greaction_poster")
+	reactorToken, reactorID := signupUser(t, "greaction_reactor")
+	outsiderToken, _ := signupUser(t, "greaction_outsider")
+
+	w := makeRequest("POST", "/api/v1/groups", map[string]interface{}{
+		"name":       "Reaction Group",
+		"type":       "private",
+		"member_ids": []string{reactorID},
+	}, posterToken)
+	if !assert.Equal(t, http.StatusCreated, w.Code) {
+		t.FailNow()
+	}
+	var created map[string]map[string]interface{}
+	parseResponse(w, &created)
+	groupID := created["data"]["id"].(string)
+
+	w = makeRequest("POST", "/api/v1/groups/messages", map[string]interface{}{
+		"group_id": groupID,
+		"content":  "React to me",
+	}, posterToken)
+	if !assert.Equal(t, http.StatusCreated, w.Code) {
+		t.FailNow()
+	}
+	var sent map[string]map[string]interface{}
+	parseResponse(w, &sent)
+	messageID := sent["data"]["id"].(string)
+	reactionsURL := "/api/v1/groups/messages/" + messageID + "/reactions"
+
+	posterWS := dialWebSocket(t, posterToken)
+	defer posterWS.close()
+
+	t.Run("AddNotifiesMembers", func(t *testing.T) {
+		w := makeRequest("POST", reactionsURL, map[string]interface{}{"emoji": "👍"}, reactorToken)
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+
+		event := posterWS.waitForEvent(t, "group_reaction_added", 2*time.Second)
+		data := event["data"].(map[string]interface{})
+		assert.Equal(t, groupID, data["group_id"])
+		assert.Equal(t, messageID, data["message_id"])
+		assert.Equal(t, reactorID, data["user_id"])
+		assert.Equal(t, "👍", data["emoji"])
+	})
+
+	t.Run("CountsInHistory", func(t *testing.T) {
+		makeRequest("POST", reactionsURL, map[string]interface{}{"emoji": "👍"}, posterToken)
+		// Reacting twice with the same emoji doesn't count twice
+		makeRequest("POST", reactionsURL, map[string]interface{}{"emoji": "👍"}, posterToken)
+
+		w := makeRequest("GET", "/api/v1/groups/"+groupID+"/messages", nil, reactorToken)
+		if !assert.Equal(t, http.StatusOK, w.Code) {
+			t.FailNow()
+		}
+		var response map[string][]map[string]interface{}
+		parseResponse(w, &response)
+		if !assert.NotEmpty(t, response["data"]) {
+			t.FailNow()
+		}
+		assert.Equal(t, map[string]interface{}{"👍": float64(2)}, response["data"][0]["reactions"])
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		w := makeRequest("DELETE", reactionsURL+"/"+url.PathEscape("👍"), nil, reactorToken)
+		if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+		var response map[string]map[string]interface{}
+		parseResponse(w, &response)
+		assert.Equal(t, float64(1), response["data"]["👍"])
+
+		posterWS.waitForEvent(t, "group_reaction_removed", 2*time.Second)
+
+		w = makeRequest("DELETE", reactionsURL+"/"+url.PathEscape("👍"), nil, reactorToken)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		w := makeRequest("POST", reactionsURL, map[string]interface{}{"emoji": "lol"}, reactorToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = makeRequest("POST", reactionsURL, map[string]interface{}{"emoji": "👍"}, outsiderToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = makeRequest("POST", "/api/v1/groups/messages/"+uuid.New().String()+"/reactions", map[string]interface{}{"emoji": "👍"}, reactorToken)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+// ============================================
+// CONVERSATION ARCHIVE TESTS
+// ============================================
+
+func TestConversationArchive(t *testing.T) {
+	ownerToken, _ := signupUser(t, "archive_owner")
+	_, keptID := signupUser(t, "archive_kept")
+	archivedToken, archivedID := signupUser(t, "archive_hidden")
+
+	for _, partnerID := range []string{keptID, archivedID} {
+		w := makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+			"receiver_id": partnerID,
+			"content":     "Hello",
+		}, ownerToken)
+		if !assert.Equal(t, http.StatusCreated, w.Code) {
+			t.FailNow()
+		}
+	}
+
+	// partnerIDs lists the partners of the conversations returned by path
+	partnerIDs := func(t *testing.T, path string) []string {
+		t.Helper()
+		w := makeRequest("GET", path, nil, ownerToken)
+		if !assert.Equal(t, http.StatusOK, w.Code) {
+			t.FailNow()
+		}
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		ids := []string{}
+		for _, item := range response["data"].([]interface{}) {
+			ids = append(ids, item.(map[string]interface{})["user"].(map[string]interface{})["id"].(string))
+		}
+		return ids
+	}
+
+	archivePath := "/api/v1/messages/conversation/" + archivedID + "/archive"
+	w := makeRequest("POST", archivePath, nil, ownerToken)
+	if !assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		t.FailNow()
+	}
+	// Archiving twice is harmless
+	w = makeRequest("POST", archivePath, nil, ownerToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	t.Run("HiddenFromRecent", func(t *testing.T) {
+		ids := partnerIDs(t, "/api/v1/messages/conversations")
+		assert.Contains(t, ids, keptID)
+		assert.NotContains(t, ids, archivedID)
+
+		ids = partnerIDs(t, "/api/v1/messages/conversations?include_archived=true")
+		assert.Contains(t, ids, keptID)
+		assert.Contains(t, ids, archivedID)
+	})
+
+	t.Run("ArchivedInbox", func(t *testing.T) {
+		assert.Equal(t, []string{archivedID}, partnerIDs(t, "/api/v1/messages/conversations/archived"))
+		assert.Equal(t, []string{archivedID}, partnerIDs(t, "/api/v2/messages/conversations/archived"))
+	})
+
+	t.Run("OnlyForTheArchiver", func(t *testing.T) {
+		w := makeRequest("GET", "/api/v1/messages/conversations", nil, archivedToken)
+		if !assert.Equal(t, http.StatusOK, w.Code) {
+			t.FailNow()
+		}
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		assert.Len(t, response["data"], 1)
+	})
+
+	t.Run("Unarchive", func(t *testing.T) {
+		w := makeRequest("DELETE", archivePath, nil, ownerToken)
+		if !assert.Equal(t, http.StatusOK, w.Code) {
+			t.FailNow()
+		}
+		assert.Contains(t, partnerIDs(t, "/api/v1/messages/conversations"), archivedID)
+		assert.Empty(t, partnerIDs(t, "/api/v1/messages/conversations/archived"))
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		w := makeRequest("POST", "/api/v1/messages/conversation/"+uuid.New().String()+"/archive", nil, ownerToken)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = makeRequest("POST", "/api/v1/messages/conversation/not-a-uuid/archive", nil, ownerToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+// ============================================
+// SCHEDULED MESSAGE TESTS
+// ============================================
+
+func TestScheduledMessages(t *testing.T) {
+	senderToken, _ := signupUser(t, "scheduled_sender")
+	receiverToken, receiverID := signupUser(t, "scheduled_receiver")
+
+	schedule := func(content string, sendAt time.Time) *httptest.ResponseRecorder {
+		return makeRequest("POST", "/api/v1/messages/scheduled", map[string]interface{}{
+			"receiver_id": receiverID,
+			"content":     content,
+			"send_at":     sendAt.Format(time.RFC3339),
+		}, senderToken)
+	}
+	scheduledID := func(t *testing.T, w *httptest.ResponseRecorder) string {
+		t.Helper()
+		if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+		var response map[string]map[string]interface{}
+		parseResponse(w, &response)
+		assert.Equal(t, "pending", response["data"]["status"])
+		return response["data"]["id"].(string)
+	}
+
+	t.Run("Validation", func(t *testing.T) {
+		w := schedule("Too late", time.Now().Add(-time.Minute))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = schedule("Too early", time.Now().Add(2*365*24*time.Hour))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = makeRequest("POST", "/api/v1/messages/scheduled", map[string]interface{}{
+			"receiver_id": uuid.New().String(),
+			"content":     "Nobody",
+			"send_at":     time.Now().Add(time.Hour).Format(time.RFC3339),
+		}, senderToken)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	laterID := scheduledID(t, schedule("See you later", time.Now().Add(time.Hour)))
+	cancelledID := scheduledID(t, schedule("Never mind", time.Now().Add(2*time.Hour)))
+
+	t.Run("ListPending", func(t *testing.T) {
+		w := makeRequest("GET", "/api/v1/messages/scheduled", nil, senderToken)
+		if !assert.Equal(t, http.StatusOK, w.Code) {
+			t.FailNow()
+		}
+		var response map[string][]map[string]interface{}
+		parseResponse(w, &response)
+		if !assert.Len(t, response["data"], 2) {
+			t.FailNow()
+		}
+		assert.Equal(t, laterID, response["data"][0]["id"])
+		assert.Equal(t, "See you later", response["data"][0]["content"])
+
+		// Other users don't see them
+		w = makeRequest("GET", "/api/v1/messages/scheduled", nil, receiverToken)
+		parseResponse(w, &response)
+		assert.Empty(t, response["data"])
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		w := makeRequest("DELETE", "/api/v1/messages/scheduled/"+cancelledID, nil, receiverToken)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = makeRequest("DELETE", "/api/v1/messages/scheduled/"+cancelledID, nil, senderToken)
+		if !assert.Equal(t, http.StatusOK, w.Code) {
+			t.FailNow()
+		}
+
+		w = makeRequest("DELETE", "/api/v1/messages/scheduled/"+cancelledID, nil, senderToken)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("SentWhenDue", func(t *testing.T) {
+		// Bring both messages due; only the one still pending goes out
+		err := db.Model(&models.ScheduledMessage{}).
+			Where("id IN ?", []string{laterID, cancelledID}).
+			Update("scheduled_for", time.Now().Add(-time.Second)).Error
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+
+		_, err = testMessageService.SendDueScheduledMessages()
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+
+		var sent models.ScheduledMessage
+		if !assert.NoError(t, db.First(&sent, "id = ?", laterID).Error) {
+			t.FailNow()
+		}
+		assert.Equal(t, models.ScheduledMessageSent, sent.Status)
+		assert.NotNil(t, sent.MessageID)
+
+		var cancelled models.ScheduledMessage
+		db.First(&cancelled, "id = ?", cancelledID)
+		assert.Equal(t, models.ScheduledMessageCancelled, cancelled.Status)
+
+		w := makeRequest("GET", "/api/v1/messages/conversation/"+receiverID, nil, senderToken)
+		var response map[string][]map[string]interface{}
+		parseResponse(w, &response)
+		contents := []string{}
+		for _, message := range response["data"] {
+			contents = append(contents, message["content"].(string))
+		}
+		assert.Equal(t, []string{"See you later"}, contents)
+
+		w = makeRequest("GET", "/api/v1/messages/scheduled", nil, senderToken)
+		parseResponse(w, &response)
+		assert.Empty(t, response["data"])
+	})
+}
+
+// ============================================
+// MARK ALL AS READ TESTS
+// ============================================
+
+func TestMarkAllConversationsAsRead(t *testing.T) {
+	firstToken, firstID := signupUser(t, "readall_first")
+	secondToken, _ := signupUser(t, "readall_second")
+	readerToken, readerID := signupUser(t, "readall_reader")
+
+	for _, token := range []string{firstToken, firstToken, secondToken} {
+		w := makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+			"receiver_id": readerID,
+			"content":     "Are you there?",
+		}, token)
+		if !assert.Equal(t, http.StatusCreated, w.Code) {
+			t.FailNow()
+		}
+	}
+
+	unreadCount := func() float64 {
+		w := makeRequest("GET", "/api/v1/messages/unread/count", nil, readerToken)
+		var response map[string]interface{}
+		parseResponse(w, &response)
+		return response["count"].(float64)
+	}
+	if !assert.Equal(t, float64(3), unreadCount()) {
+		t.FailNow()
+	}
+
+	firstWS := dialWebSocket(t, firstToken)
+	defer firstWS.close()
+	secondWS := dialWebSocket(t, secondToken)
+	defer secondWS.close()
+
+	w := makeRequest("PUT", "/api/v1/messages/read-all", nil, readerToken)
+	if !assert.Equal(t, http.StatusOK, w.Code) {
+		t.FailNow()
+	}
+	assert.Equal(t, float64(0), unreadCount())
+
+	// Each sender gets one event, whatever the number of messages read
+	event := firstWS.waitForEvent(t, "all_conversations_read", 2*time.Second)
+	assert.Equal(t, readerID, event["sender_id"])
+	assert.NotEmpty(t, event["read_at"])
+	firstWS.expectNoEvent(t, "all_conversations_read", 300*time.Millisecond)
+	secondWS.waitForEvent(t, "all_conversations_read", 2*time.Second)
+
+	w = makeRequest("GET", "/api/v1/messages/conversation/"+firstID, nil, readerToken)
+	var response map[string][]map[string]interface{}
+	parseResponse(w, &response)
+	for _, message := range response["data"] {
+		assert.Equal(t, true, message["is_read"])
+	}
+
+	// Nothing left to read: no events
+	w = makeRequest("PUT", "/api/v1/messages/read-all", nil, readerToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+	firstWS.expectNoEvent(t, "all_conversations_read", 300*time.Millisecond)
+}
+
+// ============================================================================
+// ACCOUNT DELETION TESTS
+// ============================================================================
+
+func TestDeleteAccount(t *testing.T) {
+	leaverToken, leaverID := signupUser(t, "account_leaver")
+	friendToken, friendID := signupUser(t, "account_friend")
+	assert.NoError(t, testUserRepo.UpdateDeviceToken(parseUUID(t, leaverID), "leaver-device", "android"))
+
+	w := makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+		"receiver_id": friendID,
+		"content":     "goodbye soon",
+	}, leaverToken)
+	if !assert.Equal(t, http.StatusCreated, w.Code) {
+		t.FailNow()
+	}
+
+	w = makeRequest("DELETE", "/api/v1/users/me", nil, leaverToken)
+	if !assert.Equal(t, http.StatusOK, w.Code) {
+		t.FailNow()
+	}
+
+	t.Run("SoftDeleted", func(t *testing.T) {
+		var user models.User
+		if !assert.NoError(t, db.First(&user, "id = ?", leaverID).Error) {
+			return
+		}
+		assert.NotNil(t, user.DeletedAt)
+		assert.Empty(t, user.DeviceToken)
+	})
+
+	t.Run("CannotLogIn", func(t *testing.T) {
+		w := makeRequest("POST", "/api/v1/auth/login", map[string]string{
+			"identifier": "account_leaver@example.com",
+			"password":   "Test1234!",
+		}, "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		w := makeRequest("GET", "/api/v1/users/"+leaverID, nil, friendToken)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = makeRequest("DELETE", "/api/v1/users/me", nil, leaverToken)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("MessagesShowDeletedUser", func(t *testing.T) {
+		w := makeRequest("GET", "/api/v1/messages/conversation/"+leaverID, nil, friendToken)
+		if !assert.Equal(t, http.StatusOK, w.Code) {
+			return
+		}
+		var response struct {
+			Data []struct {
+				Content string `json:"content"`
+				Sender  struct {
+					ID       string `json:"id"`
+					Username string `json:"username"`
+					Email    string `json:"email"`
+				} `json:"sender"`
+			} `json:"data"`
+		}
+		parseResponse(w, &response)
+		if !assert.Len(t, response.Data, 1) {
+			return
+		}
+		assert.Equal(t, "goodbye soon", response.Data[0].Content)
+		assert.Equal(t, leaverID, response.Data[0].Sender.ID)
+		assert.Equal(t, models.DeletedUsername, response.Data[0].Sender.Username)
+		assert.Empty(t, response.Data[0].Sender.Email)
+	})
+
+	t.Run("EmailAndUsernameReusable", func(t *testing.T) {
+		// The deleted row is kept until it is purged, but it no longer holds its email or username
+		_, newID := signupUser(t, "account_leaver")
+		assert.NotEqual(t, leaverID, newID)
+	})
+
+	t.Run("PurgedAfter30Days", func(t *testing.T) {
+		userService := services.NewUserService(testUserRepo, nil)
+
+		// Not yet due
+		purged, err := userService.PurgeDeletedAccounts()
+		assert.NoError(t, err)
+		var count int64
+		db.Model(&models.User{}).Where("id = ?", leaverID).Count(&count)
+		assert.Equal(t, int64(1), count)
+
+		db.Model(&models.User{}).Where("id = ?", leaverID).
+			Update("deleted_at", time.Now().Add(-services.AccountPurgeDelay-time.Hour))
+		purged, err = userService.PurgeDeletedAccounts()
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, purged, int64(1))
+
+		db.Model(&models.User{}).Where("id = ?", leaverID).Count(&count)
+		assert.Equal(t, int64(0), count)
+		db.Model(&models.Message{}).Where("sender_id = ?", leaverID).Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
+}
+
+// ============================================================================
+// HEALTH CHECK TESTS
+// ============================================================================
+
+func TestDatabaseHealth(t *testing.T) {
+	w := makeRequest("GET", "/health/db", nil, "")
+	if !assert.Equal(t, http.StatusOK, w.Code) {
+		t.FailNow()
+	}
+
+	var response struct {
+		Status string         `json:"status"`
+		Pool   map[string]int `json:"pool"`
+	}
+	parseResponse(w, &response)
+	assert.Equal(t, "ok", response.Status)
+	assert.GreaterOrEqual(t, response.Pool["open_connections"], 1)
+	assert.Equal(t, response.Pool["open_connections"], response.Pool["in_use"]+response.Pool["idle"])
+}
+
+// ============================================================================
+// CONVERSATION EXPORT TESTS
+// ============================================================================
+
+func TestConversationExport(t *testing.T) {
+	exporterToken, exporterID := signupUser(t, "export_dm_a")
+	_, partnerID := signupUser(t, "export_dm_b")
+
+	// Seed 1001 messages directly so the export spans more than one batch
+	content, err := utils.Encrypt("Exported, with a comma")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	base := time.Now().Add(-time.Hour)
+	messages := make([]models.Message, 1001)
+	for i := range messages {
+		sender, receiver := exporterID, partnerID
+		if i%2 == 1 {
+			sender, receiver = partnerID, exporterID
+		}
+		messages[i] = models.Message{
+			SenderID:   uuid.MustParse(sender),
+			ReceiverID: uuid.MustParse(receiver),
+			Content:    content,
+			IsRead:     i == 0,
+			CreatedAt:  base.Add(time.Duration(i) * time.Millisecond),
+		}
+	}
+	if !assert.NoError(t, db.CreateInBatches(messages, 200).Error) {
+		t.FailNow()
+	}
+
+	export := func(format string) *httptest.ResponseRecorder {
+		return makeRequest("GET", "/api/v1/messages/conversation/"+partnerID+"/export?format="+format, nil, exporterToken)
+	}
+
+	t.Run("CSV", func(t *testing.T) {
+		w := export("csv")
+		if !assert.Equal(t, http.StatusOK, w.Code) {
+			t.FailNow()
+		}
+		assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv"))
+		assert.Regexp(t, `^attachment; filename="conversation-\d{4}-\d{2}-\d{2}\.csv"$`, w.Header().Get("Content-Disposition"))
+
+		records, err := csv.NewReader(w.Body).ReadAll()
+		assert.NoError(t, err)
+		if assert.Len(t, records, 1002) {
+			assert.Equal(t, []string{"id", "sender_username", "content", "created_at", "is_read", "edited"}, records[0])
+			assert.Equal(t, messages[0].ID.String(), records[1][0])
+			assert.Equal(t, "export_dm_a", records[1][1])
+			assert.Equal(t, "Exported, with a comma", records[1][2])
+			assert.Equal(t, "true", records[1][4])
+			assert.Equal(t, "export_dm_b", records[2][1])
+			assert.Equal(t, messages[1000].ID.String(), records[1001][0])
+		}
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		w := export("json")
+		if !assert.Equal(t, http.StatusOK, w.Code) {
+			t.FailNow()
+		}
+		assert.Regexp(t, `filename="conversation-\d{4}-\d{2}-\d{2}\.json"`, w.Header().Get("Content-Disposition"))
+
+		var rows []map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &rows))
+		if assert.Len(t, rows, 1001) {
+			assert.Equal(t, messages[0].ID.String(), rows[0]["id"])
+			assert.Equal(t, "Exported, with a comma", rows[0]["content"])
+			assert.Equal(t, false, rows[1]["is_read"])
+		}
+	})
+
+	t.Run("InvalidFormat", func(t *testing.T) {
+		w := export("xml")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("InvalidUserID", func(t *testing.T) {
+		w := makeRequest("GET", "/api/v1/messages/conversation/not-a-uuid/export", nil, exporterToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+// ============================================================================
+// GROUP MESSAGE SEARCH TESTS
+// ============================================================================
+
+func TestGroupMessageFullTextSearch(t *testing.T) {
+	ownerToken, _ := signupUser(t, "gfts_owner")
+	memberToken, memberID := signupUser(t, "gfts_member")
+	outsiderToken, _ := signupUser(t, "gfts_outsider")
+
+	w := makeRequest("POST", "/api/v1/groups", map[string]interface{}{
+		"name":       "Search Group FTS",
+		"type":       "private",
+		"member_ids": []string{memberID},
+	}, ownerToken)
+	if !assert.Equal(t, http.StatusCreated, w.Code) {
+		t.FailNow()
+	}
+	var created map[string]interface{}
+	parseResponse(w, &created)
+	groupID := created["data"].(map[string]interface{})["id"].(string)
+
+	send := func(token, content string) string {
+		w := makeRequest("POST", "/api/v1/groups/messages", map[string]interface{}{
+			"group_id": groupID,
+			"content":  content,
+		}, token)
+		if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+		return parseMessageID(t, w)
+	}
+
+	type hit struct {
+		ID      string            `json:"id"`
+		Content string            `json:"content"`
+		Snippet string            `json:"snippet"`
+		Sender  models.PublicUser `json:"sender"`
+	}
+	search := func(token, query string) (int, []hit, utils.Meta) {
+		w := makeRequest("GET", "/api/v1/groups/"+groupID+"/messages/search?"+query, nil, token)
+		var response struct {
+			Data []hit      `json:"data"`
+			Meta utils.Meta `json:"meta"`
+		}
+		parseResponse(w, &response)
+		return w.Code, response.Data, response.Meta
+	}
+
+	longMessage := strings.Repeat("Lots of context before the word. ", 5) + "Roadmap review on Friday. " +
+		strings.Repeat("And plenty more after it. ", 5)
+	send(ownerToken, "Roadmap draft is ready")
+	send(memberToken, longMessage)
+	deletedID := send(memberToken, "Old roadmap, please ignore")
+	editedID := send(ownerToken, "Budget numbers")
+	send(ownerToken, "Lunch plans")
+
+	w = makeRequest("DELETE", "/api/v1/groups/messages/"+deletedID, nil, memberToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = makeRequest("PATCH", "/api/v1/groups/messages/"+editedID, map[string]interface{}{"content": "Budget for the roadmap"}, ownerToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	code, hits, meta := search(memberToken, "q=ROADMAP")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, int64(3), meta.Total)
+	if assert.Len(t, hits, 3) {
+		// Newest first; the deleted message is left out
+		assert.Equal(t, editedID, hits[0].ID)
+		assert.Equal(t, "Budget for the roadmap", hits[0].Snippet)
+		assert.Equal(t, "gfts_owner", hits[0].Sender.Username)
+
+		assert.Equal(t, longMessage, hits[1].Content)
+		assert.Contains(t, hits[1].Snippet, "Roadmap review on Friday")
+		assert.True(t, strings.HasPrefix(hits[1].Snippet, "…"))
+		assert.True(t, strings.HasSuffix(hits[1].Snippet, "…"))
+		assert.Equal(t, "gfts_member", hits[1].Sender.Username)
+
+		assert.Equal(t, "Roadmap draft is ready", hits[2].Content)
+	}
+
+	t.Run("Pagination", func(t *testing.T) {
+		_, hits, meta := search(memberToken, "q=roadmap&limit=2&offset=1")
+		assert.Len(t, hits, 2)
+		assert.Equal(t, int64(3), meta.Total)
+		assert.False(t, meta.HasMore)
+
+		_, hits, meta = search(memberToken, "q=roadmap&limit=1")
+		assert.Len(t, hits, 1)
+		assert.True(t, meta.HasMore)
+	})
+
+	t.Run("EditedAwayWordsNoLongerMatch", func(t *testing.T) {
+		_, hits, _ := search(memberToken, "q=numbers")
+		assert.Empty(t, hits)
+	})
+
+	t.Run("QueryRequired", func(t *testing.T) {
+		code, _, _ := search(memberToken, "q=")
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("NonMemberForbidden", func(t *testing.T) {
+		code, _, _ := search(outsiderToken, "q=roadmap")
+		assert.Equal(t, http.StatusForbidden, code)
+	})
+}
+
+// ============================================================================
+// CONVERSATION TOTAL MESSAGES TESTS
+// ============================================================================
+
+func TestConversationTotalMessages(t *testing.T) {
+	userToken, userID := signupUser(t, "total_user")
+	friendToken, friendID := signupUser(t, "total_friend")
+	_, otherID := signupUser(t, "total_other")
+
+	send := func(token, receiverID, content string) string {
+		w := makeRequest("POST", "/api/v1/messages", map[string]interface{}{
+			"receiver_id": receiverID,
+			"content":     content,
+		}, token)
+		if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+			t.FailNow()
+		}
+		return parseMessageID(t, w)
+	}
+
+	send(userToken, friendID, "One")
+	send(userToken, friendID, "Two")
+	deletedID := send(userToken, friendID, "Three")
+	send(friendToken, userID, "Four")
+	send(friendToken, userID, "Five")
+	send(userToken, otherID, "Hello other")
+
+	w := makeRequest("DELETE", "/api/v1/messages/"+deletedID, nil, userToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = makeRequest("GET", "/api/v1/messages/conversations", nil, userToken)
+	if !assert.Equal(t, http.StatusOK, w.Code) {
+		t.FailNow()
+	}
+	var response struct {
+		Data []models.ConversationSummary `json:"data"`
+	}
+	parseResponse(w, &response)
+
+	totals := make(map[uuid.UUID]int64)
+	for _, summary := range response.Data {
+		totals[summary.User.ID] = summary.TotalMessages
+		if summary.User.ID.String() == friendID {
+			assert.Equal(t, int64(2), summary.UnreadCount)
+		}
+	}
+	// Deleted messages still count: they are shown as "[message deleted]"
+	assert.Equal(t, int64(5), totals[uuid.MustParse(friendID)])
+	assert.Equal(t, int64(1), totals[uuid.MustParse(otherID)])
+}
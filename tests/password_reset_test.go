@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"net/http"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"mms-backend/models"
+)
+
+// sentEmail is an email captured by fakeEmailService
+type sentEmail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// fakeEmailService records emails instead of sending them
+type fakeEmailService struct {
+	mu   sync.Mutex
+	sent []sentEmail
+}
+
+func (s *fakeEmailService) Send(to, subject, body string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, sentEmail{To: to, Subject: subject, Body: body})
+	return nil
+}
+
+// sentTo returns every email sent to an address, oldest first
+func (s *fakeEmailService) sentTo(to string) []sentEmail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var emails []sentEmail
+	for _, email := range s.sent {
+		if email.To == to {
+			emails = append(emails, email)
+		}
+	}
+	return emails
+}
+
+var testEmailService = &fakeEmailService{}
+
+var resetTokenPattern = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// requestResetToken asks for a password reset and returns the token from the email it sent
+func requestResetToken(t *testing.T, email string) string {
+	t.Helper()
+
+	before := len(testEmailService.sentTo(email))
+	w := makeRequest("POST", "/api/v1/auth/forgot-password", map[string]interface{}{"email": email}, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	emails := testEmailService.sentTo(email)
+	require.Len(t, emails, before+1)
+	token := resetTokenPattern.FindString(emails[len(emails)-1].Body)
+	require.NotEmpty(t, token, "reset email should contain the token")
+	return token
+}
+
+func TestPasswordReset(t *testing.T) {
+	_, userID := signupUser(t, "reset_user")
+	email := "reset_user@example.com"
+
+	token := requestResetToken(t, email)
+
+	// Only the hash is stored
+	var stored models.PasswordResetToken
+	require.NoError(t, db.Where("user_id = ?", userID).First(&stored).Error)
+	assert.NotEqual(t, token, stored.TokenHash)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), stored.ExpiresAt, time.Minute)
+
+	// Weak passwords are rejected without burning the token
+	w := makeRequest("POST", "/api/v1/auth/reset-password", map[string]interface{}{
+		"token": token, "password": "weak",
+	}, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = makeRequest("POST", "/api/v1/auth/reset-password", map[string]interface{}{
+		"token": token, "password": "NewPass123!",
+	}, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = makeRequest("POST", "/api/v1/auth/login", map[string]interface{}{
+		"identifier": email, "password": "Test1234!",
+	}, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "old password should no longer work")
+
+	w = makeRequest("POST", "/api/v1/auth/login", map[string]interface{}{
+		"identifier": email, "password": "NewPass123!",
+	}, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Tokens are single use
+	w = makeRequest("POST", "/api/v1/auth/reset-password", map[string]interface{}{
+		"token": token, "password": "Other123!",
+	}, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestPasswordResetInvalidatesOtherTokens(t *testing.T) {
+	signupUser(t, "reset_twice")
+	email := "reset_twice@example.com"
+
+	first := requestResetToken(t, email)
+	second := requestResetToken(t, email)
+
+	w := makeRequest("POST", "/api/v1/auth/reset-password", map[string]interface{}{
+		"token": second, "password": "NewPass123!",
+	}, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = makeRequest("POST", "/api/v1/auth/reset-password", map[string]interface{}{
+		"token": first, "password": "Other123!",
+	}, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestPasswordResetExpiredToken(t *testing.T) {
+	_, userID := signupUser(t, "reset_expired")
+
+	token := requestResetToken(t, "reset_expired@example.com")
+	require.NoError(t, db.Model(&models.PasswordResetToken{}).
+		Where("user_id = ?", userID).
+		Update("expires_at", time.Now().Add(-time.Minute)).Error)
+
+	w := makeRequest("POST", "/api/v1/auth/reset-password", map[string]interface{}{
+		"token": token, "password": "NewPass123!",
+	}, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestForgotPasswordUnknownEmail(t *testing.T) {
+	w := makeRequest("POST", "/api/v1/auth/forgot-password", map[string]interface{}{
+		"email": "nobody_here@example.com",
+	}, "")
+	assert.Equal(t, http.StatusOK, w.Code, "unknown emails must not be revealed")
+	assert.Empty(t, testEmailService.sentTo("nobody_here@example.com"))
+}
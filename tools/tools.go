@@ -0,0 +1,8 @@
+//go:build tools
+
+// Package tools pins the versions of code generators used by the Makefile.
+package tools
+
+import (
+	_ "github.com/swaggo/swag/cmd/swag"
+)
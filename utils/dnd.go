@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"time"
+
+	"mms-backend/models"
+)
+
+// IsInDNDWindow reports whether the user's do-not-disturb schedule covers the current hour
+// in their time zone. The window runs from DNDStartHour up to, but excluding, DNDEndHour and
+// crosses midnight when the start hour is after the end hour; equal hours make it empty.
+func IsInDNDWindow(user *models.User) bool {
+	return isInDNDWindow(user, time.Now())
+}
+
+func isInDNDWindow(user *models.User, now time.Time) bool {
+	if !user.DNDEnabled {
+		return false
+	}
+
+	location, err := time.LoadLocation(user.DNDTimezone)
+	if err != nil {
+		return false
+	}
+	hour := now.In(location).Hour()
+
+	start, end := user.DNDStartHour, user.DNDEndHour
+	if start <= end {
+		return hour >= start && hour < end
+	}
+	// e.g. 22 to 7: late evening or early morning
+	return hour >= start || hour < end
+}
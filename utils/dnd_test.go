@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"mms-backend/models"
+)
+
+func TestIsInDNDWindow(t *testing.T) {
+	// 23:30 UTC is 00:30 the next day in Paris (winter time)
+	now := time.Date(2024, time.January, 15, 23, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		enabled  bool
+		start    int
+		end      int
+		timezone string
+		want     bool
+	}{
+		{"disabled", false, 0, 23, "UTC", false},
+		{"same day inside", true, 7, 9, "Asia/Tokyo", true},
+		{"same day start is inclusive", true, 0, 6, "Europe/Paris", true},
+		{"same day end is exclusive", true, 20, 23, "UTC", false},
+		{"midnight crossing before midnight", true, 22, 7, "UTC", true},
+		{"midnight crossing after midnight", true, 22, 7, "Europe/Paris", true},
+		{"midnight crossing outside", true, 1, 0, "Europe/Paris", false},
+		{"midnight crossing end is exclusive", true, 23, 0, "Europe/Paris", false},
+		{"time zone moves hour out of window", true, 22, 0, "Asia/Tokyo", false},
+		{"equal hours are empty", true, 23, 23, "UTC", false},
+		{"empty time zone is UTC", true, 23, 0, "", true},
+		{"unknown time zone", true, 0, 23, "Mars/Olympus", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &models.User{
+				DNDEnabled:   tt.enabled,
+				DNDStartHour: tt.start,
+				DNDEndHour:   tt.end,
+				DNDTimezone:  tt.timezone,
+			}
+			if got := isInDNDWindow(user, now); got != tt.want {
+				t.Fatalf("isInDNDWindow = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -6,33 +6,73 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"io"
+	"strings"
 
 	"mms-backend/config"
 )
 
-// getEncryptionKey derives a 32-byte key from the config
-func getEncryptionKey() []byte {
-	key := config.AppConfig.Security.EncryptionKey
+// CiphertextVersion tags the format written by Encrypt: AES-256-GCM with a random nonce, the key
+// derived with SHA-256, base64 encoded, after the ID of the key, e.g. "v2:<key id>:<base64>".
+// "v1:<base64>" values and untagged ones stored before versioning have the same format without
+// the key ID.
+const CiphertextVersion = "v2"
+
+// legacyCiphertextVersion tags values written before they carried their key ID
+const legacyCiphertextVersion = "v1"
+
+var (
+	// ErrUnsupportedCiphertextVersion is returned when decrypting a value tagged with an unknown version
+	ErrUnsupportedCiphertextVersion = errors.New("unsupported cipher text version")
+
+	// ErrUnknownEncryptionKey is returned when decrypting a value encrypted with none of the given keys
+	ErrUnknownEncryptionKey = errors.New("cipher text encrypted with an unknown key")
+)
+
+// deriveKey derives a 32-byte AES key from a configured encryption key
+func deriveKey(key string) []byte {
 	hash := sha256.Sum256([]byte(key))
 	return hash[:]
 }
 
-// Encrypt encrypts plain text using AES-256-GCM
+// newGCM returns an AES-256-GCM cipher for the given encryption key
+func newGCM(key string) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(deriveKey(key))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// KeyID identifies an encryption key in the values it encrypts without revealing it: the first
+// 8 hex characters of the SHA-256 of the derived AES key
+func KeyID(key string) string {
+	hash := sha256.Sum256(deriveKey(key))
+	return hex.EncodeToString(hash[:4])
+}
+
+// Encrypt encrypts plain text with the configured encryption key
 func Encrypt(plainText string) (string, error) {
+	return EncryptWithVersion(plainText, config.AppConfig.Security.EncryptionKey)
+}
+
+// Decrypt decrypts cipher text produced by Encrypt with the configured encryption key or,
+// while a key rotation is under way, the previous one
+func Decrypt(cipherText string) (string, error) {
+	security := config.AppConfig.Security
+	return DecryptWithKeys(cipherText, security.EncryptionKey, security.PreviousEncryptionKey)
+}
+
+// EncryptWithVersion encrypts plain text using AES-256-GCM with key and tags the result with
+// CiphertextVersion and the key's ID, e.g. "v2:1a2b3c4d:<base64>"
+func EncryptWithVersion(plainText, key string) (string, error) {
 	if plainText == "" {
 		return "", errors.New("plain text cannot be empty")
 	}
 
-	key := getEncryptionKey()
-	
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return "", err
-	}
-
-	aesGCM, err := cipher.NewGCM(block)
+	aesGCM, err := newGCM(key)
 	if err != nil {
 		return "", err
 	}
@@ -45,31 +85,65 @@ func Encrypt(plainText string) (string, error) {
 
 	// Encrypt the data
 	cipherText := aesGCM.Seal(nonce, nonce, []byte(plainText), nil)
-	
+
 	// Encode to base64 for storage
-	return base64.StdEncoding.EncodeToString(cipherText), nil
+	return CiphertextVersion + ":" + KeyID(key) + ":" + base64.StdEncoding.EncodeToString(cipherText), nil
 }
 
-// Decrypt decrypts cipher text using AES-256-GCM
-func Decrypt(cipherText string) (string, error) {
+// DecryptWithVersion decrypts cipher text encrypted with key; see DecryptWithKeys
+func DecryptWithVersion(cipherText, key string) (string, error) {
+	return DecryptWithKeys(cipherText, key)
+}
+
+// DecryptWithKeys decrypts cipher text encrypted with one of keys; empty keys are ignored.
+// CiphertextVersion values name their key; legacy "v1" and untagged values are tried with each.
+func DecryptWithKeys(cipherText string, keys ...string) (string, error) {
 	if cipherText == "" {
 		return "", errors.New("cipher text cannot be empty")
 	}
 
-	key := getEncryptionKey()
-	
-	// Decode from base64
-	data, err := base64.StdEncoding.DecodeString(cipherText)
-	if err != nil {
-		return "", err
+	// The base64 alphabet has no ':', so only a version tag can contain one
+	version, encoded, tagged := strings.Cut(cipherText, ":")
+	switch {
+	case !tagged:
+		encoded = cipherText
+	case version == CiphertextVersion:
+		keyID, data, ok := strings.Cut(encoded, ":")
+		if !ok {
+			return "", errors.New("cipher text has no key ID")
+		}
+		for _, key := range keys {
+			if key != "" && KeyID(key) == keyID {
+				return decryptWithKey(data, key)
+			}
+		}
+		return "", ErrUnknownEncryptionKey
+	case version != legacyCiphertextVersion:
+		return "", ErrUnsupportedCiphertextVersion
+	}
+
+	err := ErrUnknownEncryptionKey
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		var plainText string
+		if plainText, err = decryptWithKey(encoded, key); err == nil {
+			return plainText, nil
+		}
 	}
+	return "", err
+}
 
-	block, err := aes.NewCipher(key)
+// decryptWithKey decrypts an untagged base64 value encrypted with key
+func decryptWithKey(cipherText, key string) (string, error) {
+	// Decode from base64
+	data, err := base64.StdEncoding.DecodeString(cipherText)
 	if err != nil {
 		return "", err
 	}
 
-	aesGCM, err := cipher.NewGCM(block)
+	aesGCM, err := newGCM(key)
 	if err != nil {
 		return "", err
 	}
@@ -87,4 +161,3 @@ func Decrypt(cipherText string) (string, error) {
 
 	return string(plainText), nil
 }
-
@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"mms-backend/config"
+)
+
+func setupEncryptionKey() {
+	config.AppConfig = &config.Config{
+		Security: config.SecurityConfig{
+			EncryptionKey: "fuzz-test-encryption-key",
+		},
+	}
+}
+
+// FuzzEncryptDecrypt checks that any non-empty input survives an encrypt/decrypt round trip
+func FuzzEncryptDecrypt(f *testing.F) {
+	setupEncryptionKey()
+
+	random := make([]byte, 10*1024)
+	if _, err := rand.Read(random); err != nil {
+		f.Fatalf("failed to generate random seed: %v", err)
+	}
+
+	f.Add([]byte(""))
+	f.Add([]byte{0x00})
+	f.Add(random)
+	f.Add([]byte("Bonjour, ça va ? 你好 👋"))
+	f.Add([]byte{0xff, 0xfe, 0xfd, 0xc3, 0x28})
+	f.Add([]byte("$argon2id$..."))
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		plainText := string(b)
+
+		cipherText, err := Encrypt(plainText)
+		if plainText == "" {
+			if err == nil {
+				t.Fatal("expected error when encrypting empty string")
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+
+		decrypted, err := Decrypt(cipherText)
+		if err != nil {
+			t.Fatalf("Decrypt failed: %v", err)
+		}
+		if !bytes.Equal([]byte(decrypted), b) {
+			t.Fatalf("round trip mismatch: got %q, want %q", decrypted, plainText)
+		}
+	})
+}
+
+// FuzzDecrypt checks that malformed cipher text returns an error instead of panicking
+func FuzzDecrypt(f *testing.F) {
+	setupEncryptionKey()
+
+	valid, err := Encrypt("hello world")
+	if err != nil {
+		f.Fatalf("failed to build seed: %v", err)
+	}
+	raw, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(valid, CiphertextVersion+":"))
+
+	f.Add("")
+	f.Add("not base64 !!!")
+	f.Add("====")
+	f.Add(base64.StdEncoding.EncodeToString([]byte("short")))
+	f.Add(base64.StdEncoding.EncodeToString(raw[:len(raw)/2]))
+	f.Add(valid[:len(valid)-4])
+	f.Add(strings.Repeat("A", 64))
+	f.Add("v2:" + base64.StdEncoding.EncodeToString(raw))
+	f.Add(CiphertextVersion + ":")
+
+	f.Fuzz(func(t *testing.T, cipherText string) {
+		plainText, err := Decrypt(cipherText)
+		if err == nil && plainText == "" {
+			t.Fatal("Decrypt returned empty plain text without error")
+		}
+	})
+}
+
+// FuzzSanitizeHTML checks SanitizeString against HTML-like payloads
+func FuzzSanitizeHTML(f *testing.F) {
+	f.Add("<script>alert('xss')</script>")
+	f.Add("  <img src=x onerror=alert(1)>  ")
+	f.Add("<a href=\"javascript:void(0)\">click</a>\x00")
+	f.Add("&lt;b&gt;bold&lt;/b&gt;")
+	f.Add("<div>\x00\x00</div>\n\t")
+
+	f.Fuzz(func(t *testing.T, input string) {
+		sanitized := SanitizeString(input)
+
+		if strings.Contains(sanitized, "\x00") {
+			t.Fatalf("sanitized output contains null byte: %q", sanitized)
+		}
+		if sanitized != strings.TrimSpace(sanitized) {
+			t.Fatalf("sanitized output is not trimmed: %q", sanitized)
+		}
+		if again := SanitizeString(sanitized); again != sanitized {
+			t.Fatalf("SanitizeString is not idempotent: %q -> %q", sanitized, again)
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// legacyEncrypt builds an untagged value the way Encrypt did before cipher texts were versioned
+func legacyEncrypt(t *testing.T, plainText, key string) string {
+	t.Helper()
+	block, err := aes.NewCipher(deriveKey(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	aesGCM, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, aesGCM.NonceSize())
+	return base64.StdEncoding.EncodeToString(aesGCM.Seal(nonce, nonce, []byte(plainText), nil))
+}
+
+func TestEncryptWithVersion(t *testing.T) {
+	cipherText, err := EncryptWithVersion("hello", "first-key")
+	if err != nil {
+		t.Fatalf("EncryptWithVersion: %v", err)
+	}
+	if !strings.HasPrefix(cipherText, CiphertextVersion+":"+KeyID("first-key")+":") {
+		t.Fatalf("expected a %s tag with the key ID, got %q", CiphertextVersion, cipherText)
+	}
+
+	if got, err := DecryptWithVersion(cipherText, "first-key"); err != nil || got != "hello" {
+		t.Fatalf("DecryptWithVersion = %q, %v", got, err)
+	}
+	if _, err := DecryptWithVersion(cipherText, "second-key"); !errors.Is(err, ErrUnknownEncryptionKey) {
+		t.Fatalf("expected ErrUnknownEncryptionKey with the wrong key, got %v", err)
+	}
+}
+
+func TestDecryptWithVersionAcceptsUntagged(t *testing.T) {
+	legacy := legacyEncrypt(t, "stored before versioning", "first-key")
+
+	if got, err := DecryptWithVersion(legacy, "first-key"); err != nil || got != "stored before versioning" {
+		t.Fatalf("DecryptWithVersion = %q, %v", got, err)
+	}
+	if got, err := DecryptWithVersion("v1:"+legacy, "first-key"); err != nil || got != "stored before versioning" {
+		t.Fatalf("DecryptWithVersion(v1) = %q, %v", got, err)
+	}
+}
+
+func TestDecryptWithKeysDuringRotation(t *testing.T) {
+	oldValue, err := EncryptWithVersion("written before", "old-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newValue, err := EncryptWithVersion("written during", "new-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := legacyEncrypt(t, "stored before versioning", "old-key")
+
+	// The server keeps reading both while the tool re-encrypts
+	for value, want := range map[string]string{oldValue: "written before", newValue: "written during", legacy: "stored before versioning"} {
+		if got, err := DecryptWithKeys(value, "new-key", "old-key"); err != nil || got != want {
+			t.Fatalf("DecryptWithKeys(%q) = %q, %v", value, got, err)
+		}
+	}
+
+	// Once the previous key is dropped, values still using it are reported as such
+	if _, err := DecryptWithKeys(oldValue, "new-key", ""); !errors.Is(err, ErrUnknownEncryptionKey) {
+		t.Fatalf("expected ErrUnknownEncryptionKey, got %v", err)
+	}
+	if _, err := DecryptWithKeys(legacy, "new-key"); err == nil {
+		t.Fatal("expected an error for a legacy value under another key")
+	}
+}
+
+func TestDecryptWithVersionRejectsUnknownVersion(t *testing.T) {
+	cipherText, err := EncryptWithVersion("hello", "first-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	future := "v9" + strings.TrimPrefix(cipherText, CiphertextVersion)
+	if _, err := DecryptWithVersion(future, "first-key"); !errors.Is(err, ErrUnsupportedCiphertextVersion) {
+		t.Fatalf("expected ErrUnsupportedCiphertextVersion, got %v", err)
+	}
+}
@@ -3,11 +3,13 @@ package utils
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+
+	"github.com/fsnotify/fsnotify"
 )
 
 // I18n holds translation data for multiple languages
@@ -15,44 +17,79 @@ type I18n struct {
 	translations map[string]map[string]string
 	mu           sync.RWMutex
 	defaultLang  string
+	localesDir   string
 }
 
 var (
 	i18nInstance *I18n
-	once         sync.Once
+	i18nMu       sync.Mutex
 )
 
-// GetI18n returns the singleton instance of I18n
+// GetI18n returns the singleton instance of I18n, loading translations on first use
 func GetI18n() *I18n {
-	once.Do(func() {
-		i18nInstance = &I18n{
-			translations: make(map[string]map[string]string),
-			defaultLang:  "en",
-		}
+	i18nMu.Lock()
+	defer i18nMu.Unlock()
+
+	if i18nInstance == nil {
+		i18nInstance = newI18n("locales")
 		i18nInstance.LoadTranslations()
-	})
+	}
 	return i18nInstance
 }
 
+// newI18n creates an empty I18n reading translation files from localesDir
+func newI18n(localesDir string) *I18n {
+	return &I18n{
+		translations: make(map[string]map[string]string),
+		defaultLang:  "en",
+		localesDir:   localesDir,
+	}
+}
+
 // LoadTranslations loads all translation files from the locales directory
 func (i *I18n) LoadTranslations() {
+	translations, err := i.readTranslations()
+	if err != nil {
+		Logger.Warn("Failed to load translations", "error", err)
+		return
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for lang, messages := range translations {
+		i.translations[lang] = messages
+	}
+}
+
+// ReloadTranslations re-reads the locales directory and replaces every loaded translation.
+// On error the current translations are kept.
+func (i *I18n) ReloadTranslations() error {
+	translations, err := i.readTranslations()
+	if err != nil {
+		return err
+	}
+
 	i.mu.Lock()
 	defer i.mu.Unlock()
 
-	localesDir := "locales"
-	
+	i.translations = translations
+	return nil
+}
+
+// readTranslations parses every .json file of the locales directory, keyed by language code
+func (i *I18n) readTranslations() (map[string]map[string]string, error) {
 	// Check if locales directory exists
-	if _, err := os.Stat(localesDir); os.IsNotExist(err) {
-		log.Printf("Warning: locales directory not found at %s", localesDir)
-		return
+	if _, err := os.Stat(i.localesDir); os.IsNotExist(err) {
+		return nil, fmt.Errorf("locales directory not found at %s", i.localesDir)
 	}
 
-	files, err := os.ReadDir(localesDir)
+	files, err := os.ReadDir(i.localesDir)
 	if err != nil {
-		log.Printf("Error reading locales directory: %v", err)
-		return
+		return nil, fmt.Errorf("error reading locales directory: %w", err)
 	}
 
+	loaded := make(map[string]map[string]string)
 	for _, file := range files {
 		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
 			continue
@@ -60,55 +97,130 @@ func (i *I18n) LoadTranslations() {
 
 		// Extract language code from filename (e.g., "en.json" -> "en")
 		lang := strings.TrimSuffix(file.Name(), ".json")
-		
-		filePath := filepath.Join(localesDir, file.Name())
+
+		filePath := filepath.Join(i.localesDir, file.Name())
 		data, err := os.ReadFile(filePath)
 		if err != nil {
-			log.Printf("Error reading translation file %s: %v", file.Name(), err)
+			Logger.Error("Failed to read translation file", "file", file.Name(), "error", err)
 			continue
 		}
 
 		var translations map[string]string
 		if err := json.Unmarshal(data, &translations); err != nil {
-			log.Printf("Error parsing translation file %s: %v", file.Name(), err)
+			Logger.Error("Failed to parse translation file", "file", file.Name(), "error", err)
 			continue
 		}
 
-		i.translations[lang] = translations
-		log.Printf("Loaded translations for language: %s", lang)
+		loaded[lang] = translations
+		Logger.Debug("Loaded translations", "language", lang)
+	}
+
+	return loaded, nil
+}
+
+// WatchTranslations reloads translations whenever a .json file in the locales directory changes.
+// It runs until stop is closed.
+func (i *I18n) WatchTranslations(stop <-chan struct{}) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
 	}
+	if err := watcher.Add(i.localesDir); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !strings.HasSuffix(event.Name, ".json") ||
+					!event.Has(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) {
+					continue
+				}
+				if err := i.ReloadTranslations(); err != nil {
+					Logger.Error("Failed to reload translations", "error", err)
+				} else {
+					Logger.Info("Reloaded translations", "file", filepath.Base(event.Name))
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				Logger.Error("Translation watcher error", "error", err)
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return nil
 }
 
 // Translate returns the translated message for the given key and language
 func (i *I18n) Translate(lang, key string, args ...interface{}) string {
+	if translation, ok := i.lookup(lang, key, args...); ok {
+		return translation
+	}
+
+	// If no translation found, return the key itself
+	return key
+}
+
+// TranslateOrDefault is like Translate but returns defaultMsg, formatted with args, when no
+// language has the key
+func (i *I18n) TranslateOrDefault(lang, key, defaultMsg string, args ...interface{}) string {
+	if translation, ok := i.lookup(lang, key, args...); ok {
+		return translation
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(defaultMsg, args...)
+	}
+	return defaultMsg
+}
+
+// lookup finds key in lang, then in the default language, and formats it with args
+func (i *I18n) lookup(lang, key string, args ...interface{}) (string, bool) {
 	i.mu.RLock()
 	defer i.mu.RUnlock()
 
-	// Try to get translation for the specified language
-	if langMap, exists := i.translations[lang]; exists {
-		if translation, exists := langMap[key]; exists {
-			// If args are provided, format the translation
-			if len(args) > 0 {
-				return fmt.Sprintf(translation, args...)
-			}
-			return translation
-		}
+	translation, exists := i.translations[lang][key]
+	if !exists && lang != i.defaultLang {
+		// Fallback to default language
+		translation, exists = i.translations[i.defaultLang][key]
+	}
+	if !exists {
+		return "", false
 	}
 
-	// Fallback to default language
-	if lang != i.defaultLang {
-		if langMap, exists := i.translations[i.defaultLang]; exists {
-			if translation, exists := langMap[key]; exists {
-				if len(args) > 0 {
-					return fmt.Sprintf(translation, args...)
-				}
-				return translation
+	// If args are provided, format the translation
+	if len(args) > 0 {
+		return fmt.Sprintf(translation, args...), true
+	}
+	return translation, true
+}
+
+// Validate returns the keys of the default language that at least one other language lacks,
+// sorted
+func (i *I18n) Validate() []string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	var missing []string
+	for key := range i.translations[i.defaultLang] {
+		for lang, messages := range i.translations {
+			if _, exists := messages[key]; !exists && lang != i.defaultLang {
+				missing = append(missing, key)
+				break
 			}
 		}
 	}
-
-	// If no translation found, return the key itself
-	return key
+	sort.Strings(missing)
+	return missing
 }
 
 // T is a shorthand for Translate
@@ -116,6 +228,11 @@ func T(lang, key string, args ...interface{}) string {
 	return GetI18n().Translate(lang, key, args...)
 }
 
+// TOrDefault is a shorthand for TranslateOrDefault, for messages that must never show a raw key
+func TOrDefault(lang, key, defaultMsg string, args ...interface{}) string {
+	return GetI18n().TranslateOrDefault(lang, key, defaultMsg, args...)
+}
+
 // GetLanguageFromPhone attempts to determine language from phone prefix
 func GetLanguageFromPhone(phone string) string {
 	// Remove any spaces or special characters
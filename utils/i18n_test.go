@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func writeLocale(t *testing.T, dir, lang, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, lang+".json"), []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write %s locale: %v", lang, err)
+	}
+}
+
+func sortedLanguages(i *I18n) []string {
+	langs := i.SupportedLanguages()
+	sort.Strings(langs)
+	return langs
+}
+
+func TestReloadTranslationsReplacesState(t *testing.T) {
+	dir := t.TempDir()
+	writeLocale(t, dir, "en", `{"greeting": "Hello"}`)
+	writeLocale(t, dir, "fr", `{"greeting": "Bonjour"}`)
+
+	i := newI18n(dir)
+	if err := i.ReloadTranslations(); err != nil {
+		t.Fatalf("first reload failed: %v", err)
+	}
+	if got := i.Translate("fr", "greeting"); got != "Bonjour" {
+		t.Fatalf("fr greeting = %q, want Bonjour", got)
+	}
+	if got := sortedLanguages(i); len(got) != 2 || got[0] != "en" || got[1] != "fr" {
+		t.Fatalf("languages = %v, want [en fr]", got)
+	}
+
+	writeLocale(t, dir, "en", `{"greeting": "Hi"}`)
+	writeLocale(t, dir, "es", `{"greeting": "Hola"}`)
+	if err := os.Remove(filepath.Join(dir, "fr.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := i.ReloadTranslations(); err != nil {
+		t.Fatalf("second reload failed: %v", err)
+	}
+	if got := i.Translate("en", "greeting"); got != "Hi" {
+		t.Errorf("en greeting = %q, want Hi", got)
+	}
+	if got := i.Translate("es", "greeting"); got != "Hola" {
+		t.Errorf("es greeting = %q, want Hola", got)
+	}
+	// fr was removed, so it falls back to the default language
+	if got := i.Translate("fr", "greeting"); got != "Hi" {
+		t.Errorf("fr greeting = %q, want fallback Hi", got)
+	}
+	if got := sortedLanguages(i); len(got) != 2 || got[0] != "en" || got[1] != "es" {
+		t.Errorf("languages = %v, want [en es]", got)
+	}
+}
+
+func TestReloadTranslationsKeepsStateOnError(t *testing.T) {
+	dir := t.TempDir()
+	writeLocale(t, dir, "en", `{"greeting": "Hello"}`)
+
+	i := newI18n(dir)
+	if err := i.ReloadTranslations(); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+
+	i.localesDir = filepath.Join(dir, "missing")
+	if err := i.ReloadTranslations(); err == nil {
+		t.Fatal("expected an error for a missing locales directory")
+	}
+	if got := i.Translate("en", "greeting"); got != "Hello" {
+		t.Errorf("en greeting = %q, want Hello after failed reload", got)
+	}
+}
+
+func TestWatchTranslationsReloadsOnChange(t *testing.T) {
+	dir := t.TempDir()
+	writeLocale(t, dir, "en", `{"greeting": "Hello"}`)
+
+	i := newI18n(dir)
+	i.LoadTranslations()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	if err := i.WatchTranslations(stop); err != nil {
+		t.Fatalf("failed to watch translations: %v", err)
+	}
+
+	writeLocale(t, dir, "en", `{"greeting": "Howdy"}`)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if i.Translate("en", "greeting") == "Howdy" {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("translation not reloaded, got %q", i.Translate("en", "greeting"))
+}
+
+func TestValidateReportsMissingKeys(t *testing.T) {
+	dir := t.TempDir()
+	writeLocale(t, dir, "en", `{"greeting": "Hello", "farewell": "Bye", "thanks": "Thanks"}`)
+	writeLocale(t, dir, "fr", `{"greeting": "Bonjour", "thanks": "Merci", "extra": "En plus"}`)
+	writeLocale(t, dir, "es", `{"greeting": "Hola", "farewell": "Adiós"}`)
+
+	i := newI18n(dir)
+	i.LoadTranslations()
+
+	got := i.Validate()
+	if len(got) != 2 || got[0] != "farewell" || got[1] != "thanks" {
+		t.Fatalf("missing keys = %v, want [farewell thanks]", got)
+	}
+}
+
+func TestTranslateOrDefault(t *testing.T) {
+	dir := t.TempDir()
+	writeLocale(t, dir, "en", `{"greeting": "Hello %s"}`)
+	writeLocale(t, dir, "fr", `{"greeting": "Bonjour %s"}`)
+
+	i := newI18n(dir)
+	i.LoadTranslations()
+
+	if got := i.TranslateOrDefault("fr", "greeting", "Hi %s", "Ana"); got != "Bonjour Ana" {
+		t.Errorf("fr greeting = %q, want Bonjour Ana", got)
+	}
+	if got := i.TranslateOrDefault("de", "greeting", "Hi %s", "Ana"); got != "Hello Ana" {
+		t.Errorf("de greeting = %q, want the en fallback", got)
+	}
+	if got := i.TranslateOrDefault("fr", "missing", "Hi %s", "Ana"); got != "Hi Ana" {
+		t.Errorf("missing key = %q, want the default message", got)
+	}
+	if got := i.Translate("fr", "missing"); got != "missing" {
+		t.Errorf("Translate of a missing key = %q, want the key", got)
+	}
+}
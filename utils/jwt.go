@@ -9,11 +9,18 @@ import (
 	"mms-backend/config"
 )
 
+// twoFactorPurpose marks the short-lived token issued between password and TOTP checks
+const twoFactorPurpose = "2fa"
+
+// TwoFactorTokenTTL is how long a user has to enter their TOTP code after the password step
+const TwoFactorTokenTTL = 5 * time.Minute
+
 // Claims represents JWT claims structure
 type Claims struct {
 	UserID   uuid.UUID `json:"user_id"`
 	Username string    `json:"username"`
 	Email    string    `json:"email"`
+	Purpose  string    `json:"purpose,omitempty"` // Empty for session tokens
 	jwt.RegisteredClaims
 }
 
@@ -43,8 +50,48 @@ func GenerateToken(userID uuid.UUID, username, email string) (string, error) {
 	return tokenString, nil
 }
 
-// ValidateToken validates a JWT token and returns the claims
+// ValidateToken validates a session JWT and returns the claims. Tokens issued for another
+// purpose, such as the two-factor step, are rejected.
 func ValidateToken(tokenString string) (*Claims, error) {
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != "" {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+// GenerateTwoFactorToken issues the token a user trades, with a TOTP code, for a session token
+func GenerateTwoFactorToken(userID uuid.UUID) (string, error) {
+	now := time.Now()
+	claims := &Claims{
+		UserID:  userID,
+		Purpose: twoFactorPurpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(TwoFactorTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(config.AppConfig.JWT.Secret))
+}
+
+// ValidateTwoFactorToken returns the user a two-factor token was issued to
+func ValidateTwoFactorToken(tokenString string) (uuid.UUID, error) {
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if claims.Purpose != twoFactorPurpose {
+		return uuid.Nil, errors.New("invalid token")
+	}
+	return claims.UserID, nil
+}
+
+// parseToken checks a token's signature and expiry and returns its claims
+func parseToken(tokenString string) (*Claims, error) {
 	cfg := config.AppConfig
 
 	claims := &Claims{}
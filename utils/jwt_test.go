@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"mms-backend/config"
+
+	"github.com/google/uuid"
+)
+
+func setupJWTSecret() {
+	config.AppConfig = &config.Config{
+		JWT: config.JWTConfig{Secret: "jwt-test-secret", Expiry: time.Hour},
+	}
+}
+
+func TestTwoFactorTokenIsNotASessionToken(t *testing.T) {
+	setupJWTSecret()
+	userID := uuid.New()
+
+	token, err := GenerateTwoFactorToken(userID)
+	if err != nil {
+		t.Fatalf("GenerateTwoFactorToken: %v", err)
+	}
+
+	got, err := ValidateTwoFactorToken(token)
+	if err != nil || got != userID {
+		t.Fatalf("expected %s, got %s (%v)", userID, got, err)
+	}
+	if _, err := ValidateToken(token); err == nil {
+		t.Fatal("a two-factor token must not be accepted as a session token")
+	}
+	if _, err := RefreshToken(token); err == nil {
+		t.Fatal("a two-factor token must not be refreshable")
+	}
+}
+
+func TestSessionTokenIsNotATwoFactorToken(t *testing.T) {
+	setupJWTSecret()
+
+	token, err := GenerateToken(uuid.New(), "alice", "alice@example.com")
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	if _, err := ValidateToken(token); err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	if _, err := ValidateTwoFactorToken(token); err == nil {
+		t.Fatal("a session token must not be accepted as a two-factor token")
+	}
+}
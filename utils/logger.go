@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"context"
+	"log/slog"
+	"os"
+
+	"github.com/google/uuid"
+)
+
+// Logger is the application's structured logger. InitLogger configures it at startup; until
+// then it is slog's default logger.
+var Logger = slog.Default()
+
+// InitLogger sets Logger, and slog's default, to JSON output in production and text otherwise.
+// The standard log package goes through it too, so any remaining log.Printf stays structured.
+func InitLogger(environment string) {
+	options := &slog.HandlerOptions{Level: slog.LevelInfo}
+
+	var handler slog.Handler
+	if environment == "production" {
+		handler = slog.NewJSONHandler(os.Stdout, options)
+	} else {
+		handler = slog.NewTextHandler(os.Stdout, options)
+	}
+
+	Logger = slog.New(handler)
+	slog.SetDefault(Logger)
+}
+
+// requestIDKey is the context key holding the ID of the request being served
+type requestIDKey struct{}
+
+// userIDKey is the context key holding the authenticated user of the request
+type userIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request ID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID stored in ctx, or "" if there is none
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// WithUserID returns a copy of ctx carrying the authenticated user's ID
+func WithUserID(ctx context.Context, userID uuid.UUID) context.Context {
+	return context.WithValue(ctx, userIDKey{}, userID)
+}
+
+// UserIDFromContext returns the user ID stored in ctx, or uuid.Nil if there is none
+func UserIDFromContext(ctx context.Context) uuid.UUID {
+	userID, _ := ctx.Value(userIDKey{}).(uuid.UUID)
+	return userID
+}
+
+// LoggerFromContext returns Logger tagged with the request_id and user_id ctx carries
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	logger := Logger
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		logger = logger.With("request_id", requestID)
+	}
+	if userID := UserIDFromContext(ctx); userID != uuid.Nil {
+		logger = logger.With("user_id", userID)
+	}
+	return logger
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// captureLogger points Logger at a JSON buffer for the duration of the test
+func captureLogger(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := Logger
+	Logger = slog.New(slog.NewJSONHandler(&buf, nil))
+	t.Cleanup(func() { Logger = previous })
+	return &buf
+}
+
+func TestLoggerFromContext(t *testing.T) {
+	buf := captureLogger(t)
+	userID := uuid.New()
+
+	ctx := WithUserID(WithRequestID(context.Background(), "req-1"), userID)
+	LoggerFromContext(ctx).Info("hello")
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid log line %q: %v", buf.String(), err)
+	}
+	if entry["request_id"] != "req-1" || entry["user_id"] != userID.String() {
+		t.Fatalf("expected request_id and user_id fields, got %v", entry)
+	}
+
+	// Without them the line has neither field
+	buf.Reset()
+	LoggerFromContext(context.Background()).Info("hello")
+	entry = nil
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid log line %q: %v", buf.String(), err)
+	}
+	if _, ok := entry["request_id"]; ok {
+		t.Errorf("unexpected request_id in %v", entry)
+	}
+	if _, ok := entry["user_id"]; ok {
+		t.Errorf("unexpected user_id in %v", entry)
+	}
+}
@@ -0,0 +1,28 @@
+package utils
+
+// Meta is the pagination metadata returned next to "data" by list endpoints
+type Meta struct {
+	Total      int64 `json:"total"`
+	Limit      int   `json:"limit"`
+	Offset     int   `json:"offset"`
+	HasMore    bool  `json:"has_more"`
+	NextOffset *int  `json:"next_offset"` // null on the last page
+}
+
+// NewMeta describes the page of limit items starting at offset out of total.
+// A limit of zero or less means the whole list was returned.
+func NewMeta(total int64, limit, offset int) Meta {
+	meta := Meta{
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	}
+
+	if limit > 0 && int64(offset)+int64(limit) < total {
+		next := offset + limit
+		meta.HasMore = true
+		meta.NextOffset = &next
+	}
+
+	return meta
+}
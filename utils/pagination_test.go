@@ -0,0 +1,43 @@
+package utils
+
+import "testing"
+
+func TestNewMeta(t *testing.T) {
+	tests := []struct {
+		name     string
+		total    int64
+		limit    int
+		offset   int
+		hasMore  bool
+		nextFrom int
+	}{
+		{"first page", 45, 20, 0, true, 20},
+		{"middle page", 45, 20, 20, true, 40},
+		{"last partial page", 45, 20, 40, false, 0},
+		{"exact multiple of limit", 40, 20, 20, false, 0},
+		{"empty result set", 0, 20, 0, false, 0},
+		{"offset past total", 10, 20, 50, false, 0},
+		{"no limit", 10, 0, 0, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta := NewMeta(tt.total, tt.limit, tt.offset)
+			if meta.Total != tt.total || meta.Limit != tt.limit || meta.Offset != tt.offset {
+				t.Fatalf("unexpected echo of inputs: %+v", meta)
+			}
+			if meta.HasMore != tt.hasMore {
+				t.Fatalf("HasMore = %v, want %v", meta.HasMore, tt.hasMore)
+			}
+			if !tt.hasMore {
+				if meta.NextOffset != nil {
+					t.Fatalf("NextOffset = %d, want nil", *meta.NextOffset)
+				}
+				return
+			}
+			if meta.NextOffset == nil || *meta.NextOffset != tt.nextFrom {
+				t.Fatalf("NextOffset = %v, want %d", meta.NextOffset, tt.nextFrom)
+			}
+		})
+	}
+}
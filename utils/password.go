@@ -1,21 +1,95 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// HashPassword generates a bcrypt hash of the password
+// Argon2id parameters for new password hashes (RFC 9106 second recommended option)
+const (
+	argon2Time    = 3
+	argon2Memory  = 64 * 1024 // KiB
+	argon2Threads = 4
+	argon2KeyLen  = 32
+	argon2SaltLen = 16
+)
+
+// HashPassword hashes a new password with Argon2id
 func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
+	return HashPasswordArgon2(password)
+}
+
+// HashPasswordArgon2 returns an Argon2id hash of the password in the PHC string format
+// ($argon2id$v=19$m=65536,t=3,p=4$salt$hash)
+func HashPasswordArgon2(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
 		return "", err
 	}
-	return string(bytes), nil
+
+	key := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, argon2Memory, argon2Time, argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// IsBcryptHash reports whether hash was produced by bcrypt and should be upgraded
+func IsBcryptHash(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
 }
 
-// CheckPassword compares a password with its hash
+// CheckPassword compares a password with its Argon2id or bcrypt hash
 func CheckPassword(password, hash string) bool {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		return checkArgon2(password, hash)
+	}
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
 
+// checkArgon2 verifies password against a PHC-formatted Argon2id hash using the hash's own parameters
+func checkArgon2(password, hash string) bool {
+	salt, key, params, err := decodeArgon2(hash)
+	if err != nil {
+		return false
+	}
+
+	computed := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(computed, key) == 1
+}
+
+type argon2Params struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+}
+
+func decodeArgon2(hash string) (salt, key []byte, params argon2Params, err error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, nil, params, errors.New("invalid argon2id hash")
+	}
+
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, nil, params, errors.New("unsupported argon2 version")
+	}
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return nil, nil, params, err
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, nil, params, err
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return nil, nil, params, err
+	}
+	return salt, key, params, nil
+}
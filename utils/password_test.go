@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHashPasswordUsesArgon2id(t *testing.T) {
+	hash, err := HashPassword("Secret123!")
+	if err != nil {
+		t.Fatalf("HashPassword returned error: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=65536,t=3,p=4$") {
+		t.Fatalf("unexpected hash format: %q", hash)
+	}
+	if IsBcryptHash(hash) {
+		t.Fatal("argon2id hash reported as bcrypt")
+	}
+	if !CheckPassword("Secret123!", hash) {
+		t.Fatal("CheckPassword rejected the correct password")
+	}
+	if CheckPassword("Secret123?", hash) {
+		t.Fatal("CheckPassword accepted a wrong password")
+	}
+
+	other, _ := HashPassword("Secret123!")
+	if other == hash {
+		t.Fatal("hashes of the same password should use different salts")
+	}
+}
+
+func TestCheckPasswordAcceptsBcrypt(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("Secret123!"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("bcrypt failed: %v", err)
+	}
+	if !IsBcryptHash(string(hash)) {
+		t.Fatal("bcrypt hash not detected")
+	}
+	if !CheckPassword("Secret123!", string(hash)) {
+		t.Fatal("CheckPassword rejected the correct password")
+	}
+	if CheckPassword("Secret123?", string(hash)) {
+		t.Fatal("CheckPassword accepted a wrong password")
+	}
+}
+
+func TestCheckPasswordRejectsMalformedArgon2(t *testing.T) {
+	for _, hash := range []string{"$argon2id$", "$argon2id$v=19$m=x,t=3,p=4$c2FsdA$a2V5", "$argon2id$v=18$m=65536,t=3,p=4$c2FsdA$a2V5"} {
+		if CheckPassword("anything", hash) {
+			t.Errorf("CheckPassword accepted malformed hash %q", hash)
+		}
+	}
+}
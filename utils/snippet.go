@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Snippet returns the part of text around the first word of query it contains, with up to
+// radius characters on each side and an ellipsis where text was cut. Matching ignores case.
+// Text without any of the words is cut from its start.
+func Snippet(text, query string, radius int) string {
+	runes := []rune(text)
+	if len(runes) <= 2*radius {
+		return text
+	}
+
+	lower := lowerRunes(text)
+	start, length := 0, radius // Without a match, keep 2*radius characters from the start
+	found := false
+	words := strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for _, word := range words {
+		needle := lowerRunes(word)
+		if i := indexRunes(lower, needle); i >= 0 && (!found || i < start) {
+			start, length, found = i, len(needle), true
+		}
+	}
+
+	from := max(start-radius, 0)
+	to := min(start+length+radius, len(runes))
+	snippet := strings.TrimSpace(string(runes[from:to]))
+	if from > 0 {
+		snippet = "…" + snippet
+	}
+	if to < len(runes) {
+		snippet += "…"
+	}
+	return snippet
+}
+
+// lowerRunes lowercases s rune by rune, so indexes match those of []rune(s)
+func lowerRunes(s string) []rune {
+	runes := []rune(s)
+	for i, r := range runes {
+		runes[i] = unicode.ToLower(r)
+	}
+	return runes
+}
+
+// indexRunes returns the index of the first occurrence of needle in haystack, or -1
+func indexRunes(haystack, needle []rune) int {
+	if len(needle) == 0 {
+		return -1
+	}
+	for i := 0; i+len(needle) <= len(haystack); i++ {
+		match := true
+		for j, r := range needle {
+			if haystack[i+j] != r {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,31 @@
+package utils
+
+import "testing"
+
+func TestSnippet(t *testing.T) {
+	long := "The weekly sync moved to Thursday because the quarterly REPORT is due on Friday morning"
+
+	tests := []struct {
+		name   string
+		text   string
+		query  string
+		radius int
+		want   string
+	}{
+		{"short text is kept whole", "Standup at nine", "standup", 10, "Standup at nine"},
+		{"match in the middle", long, "report", 10, "…quarterly REPORT is due on…"},
+		{"match at the start", long, "weekly", 10, "The weekly sync move…"},
+		{"match at the end", long, "morning", 10, "…on Friday morning"},
+		{"earliest word wins", long, "friday thursday", 5, "…d to Thursday beca…"},
+		{"no match cuts from the start", long, "budget", 10, "The weekly sync move…"},
+		{"punctuation in the query is ignored", long, "\"report!\"", 10, "…quarterly REPORT is due on…"},
+		{"multi-byte characters", "Réunion demain à l'école avec les élèves de terminale", "école", 6, "…n à l'école avec…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Snippet(tt.text, tt.query, tt.radius); got != tt.want {
+				t.Errorf("Snippet(%q, %d) = %q, want %q", tt.query, tt.radius, got, tt.want)
+			}
+		})
+	}
+}
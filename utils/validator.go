@@ -1,10 +1,24 @@
 package utils
 
 import (
+	"encoding/json"
 	"errors"
 	"regexp"
 	"strings"
 	"unicode"
+	"unicode/utf8"
+
+	"github.com/nyaruka/phonenumbers"
+	"mms-backend/config"
+)
+
+// Validation errors callers can match with errors.Is, e.g. to translate them
+var (
+	ErrInvalidEmail     = errors.New("invalid email format")
+	ErrUsernameTooShort = errors.New("username must be at least 3 characters long")
+	ErrInvalidUsername  = errors.New("username can only contain letters, numbers, underscores, and hyphens")
+	ErrPasswordTooShort = errors.New("password must be at least 8 characters long")
+	ErrInvalidPhone     = errors.New("invalid phone number format")
 )
 
 // ValidateEmail checks if email is valid
@@ -15,7 +29,7 @@ func ValidateEmail(email string) error {
 
 	emailRegex := regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
 	if !emailRegex.MatchString(email) {
-		return errors.New("invalid email format")
+		return ErrInvalidEmail
 	}
 
 	return nil
@@ -28,7 +42,7 @@ func ValidateUsername(username string) error {
 	}
 
 	if len(username) < 3 {
-		return errors.New("username must be at least 3 characters long")
+		return ErrUsernameTooShort
 	}
 
 	if len(username) > 30 {
@@ -38,7 +52,7 @@ func ValidateUsername(username string) error {
 	// Username should only contain alphanumeric characters, underscores, and hyphens
 	usernameRegex := regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
 	if !usernameRegex.MatchString(username) {
-		return errors.New("username can only contain letters, numbers, underscores, and hyphens")
+		return ErrInvalidUsername
 	}
 
 	return nil
@@ -51,7 +65,7 @@ func ValidatePassword(password string) error {
 	}
 
 	if len(password) < 8 {
-		return errors.New("password must be at least 8 characters long")
+		return ErrPasswordTooShort
 	}
 
 	var (
@@ -90,34 +104,209 @@ func ValidatePassword(password string) error {
 	return nil
 }
 
-// ValidatePhone checks if phone number is valid (basic validation)
+// ValidatePhone checks that phone is a valid number; see NormalizePhone
 func ValidatePhone(phone string) error {
 	if phone == "" {
 		return nil // Phone is optional
 	}
 
-	// Remove spaces and dashes
-	phone = strings.ReplaceAll(phone, " ", "")
-	phone = strings.ReplaceAll(phone, "-", "")
+	_, err := NormalizePhone(phone)
+	return err
+}
+
+// NormalizePhone returns phone in E.164 form, e.g. "+1 (234) 567-8900" becomes
+// "+12345678900". Numbers without a country code are read in the default phone
+// region (DEFAULT_PHONE_REGION), so "034 00 000 01" is a Malagasy number by default.
+// A number written with a country code that libphonenumber doesn't know as valid, e.g.
+// "+1234567890", is kept as written, since such numbers were accepted before.
+func NormalizePhone(phone string) (string, error) {
+	var b strings.Builder
+	for i, r := range strings.TrimSpace(phone) {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '+' && i == 0:
+			b.WriteRune(r)
+		}
+	}
+	cleaned := b.String()
+
+	digits := strings.TrimPrefix(cleaned, "+")
+	if len(digits) < 7 || len(digits) > 15 {
+		return "", ErrInvalidPhone
+	}
+
+	number, err := phonenumbers.Parse(cleaned, defaultPhoneRegion())
+	if err != nil {
+		return "", ErrInvalidPhone
+	}
+	if !phonenumbers.IsValidNumber(number) {
+		if strings.HasPrefix(cleaned, "+") && phonenumbers.GetRegionCodeForCountryCode(int(number.GetCountryCode())) != phonenumbers.UNKNOWN_REGION {
+			return cleaned, nil
+		}
+		return "", ErrInvalidPhone
+	}
+	return phonenumbers.Format(number, phonenumbers.E164), nil
+}
+
+// defaultPhoneRegion is the region used for numbers written without a country code
+func defaultPhoneRegion() string {
+	if config.AppConfig != nil && config.AppConfig.Server.DefaultPhoneRegion != "" {
+		return config.AppConfig.Server.DefaultPhoneRegion
+	}
+	return "MG"
+}
+
+// phoneSeparators strips the formatting characters people type in phone numbers
+var phoneSeparators = strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "")
+
+// StripPhoneFormatting removes spaces, dashes, dots and parentheses so partial numbers
+// such as "+33 6 12" can be matched against stored E.164 numbers
+func StripPhoneFormatting(phone string) string {
+	return phoneSeparators.Replace(strings.TrimSpace(phone))
+}
+
+// MaxBioLength is the maximum number of characters in a profile bio
+const MaxBioLength = 500
+
+// ValidateBio checks that a profile bio fits in MaxBioLength characters
+func ValidateBio(bio string) error {
+	if utf8.RuneCountInString(bio) > MaxBioLength {
+		return errors.New("bio must be at most 500 characters")
+	}
+
+	return nil
+}
+
+// MaxLabelLength is the maximum number of characters in a conversation label
+const MaxLabelLength = 20
+
+// ValidateLabel checks that a conversation label is present and fits in MaxLabelLength characters
+func ValidateLabel(label string) error {
+	if label == "" {
+		return errors.New("label is required")
+	}
+
+	if utf8.RuneCountInString(label) > MaxLabelLength {
+		return errors.New("label must be at most 20 characters")
+	}
+
+	return nil
+}
+
+// colorRegex matches a hex color such as #1E90FF
+var colorRegex = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
 
-	// Basic phone validation
-	// Accepts international format: +261345939753
-	// Or local format: 0345939753
-	phoneRegex := regexp.MustCompile(`^(\+?\d{7,15}|0\d{9,14})$`)
-	if !phoneRegex.MatchString(phone) {
-		return errors.New("invalid phone number format")
+// ValidateColor checks that color is a hex color such as #1E90FF
+func ValidateColor(color string) error {
+	if !colorRegex.MatchString(color) {
+		return errors.New("color must be a hex color such as #1E90FF")
 	}
 
 	return nil
 }
 
+// Message content types
+const (
+	ContentTypeText     = "text"
+	ContentTypeLocation = "location"
+	ContentTypeContact  = "contact"
+	ContentTypeAudio    = "audio"
+)
+
+// ErrInvalidContentType is returned for a content type other than text, location, contact or audio
+var ErrInvalidContentType = errors.New("content_type must be one of text, location, contact or audio")
+
+// locationContent is the decrypted content of a location message
+type locationContent struct {
+	Lat   *float64 `json:"lat"`
+	Lng   *float64 `json:"lng"`
+	Label string   `json:"label"`
+}
+
+// contactContent is the decrypted content of a contact message
+type contactContent struct {
+	Name  string `json:"name"`
+	Phone string `json:"phone"`
+}
+
+// ValidateMessageContent checks that content matches the schema of its content type.
+// Location content is {"lat": 0.0, "lng": 0.0, "label": "..."}, contact content is
+// {"name": "...", "phone": "..."} and audio content is a media attachment key.
+func ValidateMessageContent(contentType, content string) error {
+	switch contentType {
+	case ContentTypeText:
+		return nil
+
+	case ContentTypeLocation:
+		var location locationContent
+		if err := json.Unmarshal([]byte(content), &location); err != nil {
+			return errors.New("location content must be a JSON object")
+		}
+		if location.Lat == nil || location.Lng == nil {
+			return errors.New("location content requires lat and lng")
+		}
+		if *location.Lat < -90 || *location.Lat > 90 || *location.Lng < -180 || *location.Lng > 180 {
+			return errors.New("location coordinates are out of range")
+		}
+		return nil
+
+	case ContentTypeContact:
+		var contact contactContent
+		if err := json.Unmarshal([]byte(content), &contact); err != nil {
+			return errors.New("contact content must be a JSON object")
+		}
+		if strings.TrimSpace(contact.Name) == "" || strings.TrimSpace(contact.Phone) == "" {
+			return errors.New("contact content requires name and phone")
+		}
+		return nil
+
+	case ContentTypeAudio:
+		if strings.TrimSpace(content) == "" || strings.ContainsAny(content, " \t\n") {
+			return errors.New("audio content must be a media attachment key")
+		}
+		return nil
+	}
+
+	return ErrInvalidContentType
+}
+
+// maxEmojiLength is the longest reaction accepted, in characters; ZWJ sequences such as
+// family emoji are several characters long
+const maxEmojiLength = 10
+
+// ValidateEmoji checks that a reaction looks like a single emoji rather than text
+func ValidateEmoji(emoji string) error {
+	length := utf8.RuneCountInString(emoji)
+	if length == 0 {
+		return errors.New("emoji is required")
+	}
+	if length > maxEmojiLength {
+		return errors.New("emoji is too long")
+	}
+
+	hasNonASCII := false
+	for _, r := range emoji {
+		if r == utf8.RuneError || unicode.IsLetter(r) || unicode.IsSpace(r) || unicode.IsControl(r) {
+			return errors.New("invalid emoji")
+		}
+		if r > unicode.MaxASCII {
+			hasNonASCII = true
+		}
+	}
+	if !hasNonASCII {
+		return errors.New("invalid emoji")
+	}
+	return nil
+}
+
 // SanitizeString removes potentially harmful characters
 func SanitizeString(input string) string {
+	// Remove null bytes first so they can't shield surrounding whitespace from trimming
+	input = strings.ReplaceAll(input, "\x00", "")
+
 	// Trim whitespace
 	input = strings.TrimSpace(input)
 
-	// Remove null bytes
-	input = strings.ReplaceAll(input, "\x00", "")
-
 	return input
 }
@@ -0,0 +1,93 @@
+package utils
+
+import "testing"
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"US formatted", "+1 (234) 567-8900", "+12345678900"},
+		{"US compact", "+12345678900", "+12345678900"},
+		{"UK mobile", "+44 7911 123456", "+447911123456"},
+		{"UK with dots", "+44.20.7946.0958", "+442079460958"},
+		{"French mobile", "+33 6 12 34 56 78", "+33612345678"},
+		{"French international prefix", "0033612345678", "+33612345678"},
+		{"Madagascar", "+261 34 00 000 01", "+261340000001"},
+		{"Madagascar local", "034 00 000 01", "+261340000001"},
+		{"Unknown range with country code", "+1 234 567 890", "+1234567890"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizePhone(tt.input)
+			if err != nil {
+				t.Fatalf("NormalizePhone(%q) returned error: %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Fatalf("NormalizePhone(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizePhoneRejectsInvalid(t *testing.T) {
+	for _, input := range []string{"", "12345", "+1234567890123456", "+33 1", "not a phone", "+999 1234 5678", "00 12 34"} {
+		if got, err := NormalizePhone(input); err == nil {
+			t.Errorf("NormalizePhone(%q) = %q, want error", input, got)
+		}
+		if input != "" && ValidatePhone(input) == nil {
+			t.Errorf("ValidatePhone(%q) accepted an invalid number", input)
+		}
+	}
+}
+
+func TestValidateMessageContent(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		content     string
+		valid       bool
+	}{
+		{"text", ContentTypeText, "hello", true},
+		{"location", ContentTypeLocation, `{"lat": -18.8792, "lng": 47.5079, "label": "Antananarivo"}`, true},
+		{"location without label", ContentTypeLocation, `{"lat": 0, "lng": 0}`, true},
+		{"location missing lat", ContentTypeLocation, `{"lng": 47.5, "label": "x"}`, false},
+		{"location out of range", ContentTypeLocation, `{"lat": 91, "lng": 0}`, false},
+		{"location not JSON", ContentTypeLocation, "somewhere", false},
+		{"contact", ContentTypeContact, `{"name": "Bob", "phone": "+12345678900"}`, true},
+		{"contact missing phone", ContentTypeContact, `{"name": "Bob"}`, false},
+		{"audio", ContentTypeAudio, "media/2024/voice-note.ogg", true},
+		{"audio with spaces", ContentTypeAudio, "not a key", false},
+		{"unknown type", "video", "clip", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateMessageContent(tt.contentType, tt.content)
+			if tt.valid && err != nil {
+				t.Fatalf("expected valid content, got %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestValidateEmoji(t *testing.T) {
+	valid := []string{"👍", "❤️", "👍🏽", "👨‍👩‍👧‍👦", "1️⃣", "🇲🇬"}
+	for _, emoji := range valid {
+		if err := ValidateEmoji(emoji); err != nil {
+			t.Errorf("ValidateEmoji(%q) = %v, want nil", emoji, err)
+		}
+	}
+
+	invalid := []string{"", "lol", ":)", "👍 ", "a👍", "👍👍👍👍👍👍👍👍👍👍👍"}
+	for _, emoji := range invalid {
+		if err := ValidateEmoji(emoji); err == nil {
+			t.Errorf("ValidateEmoji(%q) accepted an invalid reaction", emoji)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package websocket
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"mms-backend/utils"
+)
+
+// Delivery describes a message whose receiver acknowledged it
+type Delivery struct {
+	MessageID   uuid.UUID
+	SenderID    uuid.UUID
+	DeliveredAt time.Time
+}
+
+// DeliveryAcknowledger records that a message reached its receiver's client
+type DeliveryAcknowledger interface {
+	// AcknowledgeDelivery marks messageID delivered if receiverID received it. It returns
+	// nil when there is nothing to report, e.g. the message was already delivered.
+	AcknowledgeDelivery(receiverID, messageID uuid.UUID) (*Delivery, error)
+}
+
+// ackEvent is a client's acknowledgment of a message it received
+type ackEvent struct {
+	userID    uuid.UUID
+	messageID uuid.UUID
+}
+
+// SetDeliveryAcknowledger sets what records acknowledged messages. Without one acks are ignored.
+func (h *Hub) SetDeliveryAcknowledger(acknowledger DeliveryAcknowledger) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.acknowledger = acknowledger
+}
+
+// Ack queues userID's acknowledgment that their client received messageID
+func (h *Hub) Ack(userID, messageID uuid.UUID) {
+	h.ack <- &ackEvent{userID: userID, messageID: messageID}
+}
+
+// acknowledge records an ack and sends the message's sender a delivery_receipt
+func (h *Hub) acknowledge(event *ackEvent) {
+	h.mu.RLock()
+	acknowledger := h.acknowledger
+	h.mu.RUnlock()
+	if acknowledger == nil {
+		return
+	}
+
+	delivery, err := acknowledger.AcknowledgeDelivery(event.userID, event.messageID)
+	if err != nil {
+		utils.Logger.Error("Failed to acknowledge message", "message_id", event.messageID, "user_id", event.userID, "error", err)
+		return
+	}
+	if delivery == nil {
+		return
+	}
+
+	_ = h.SendToUser(delivery.SenderID, &Message{
+		Type:       "delivery_receipt",
+		SenderID:   event.userID, // The one who received the message
+		ReceiverID: delivery.SenderID,
+		MessageID:  &delivery.MessageID,
+		// The same fields as a message's delivery status, so clients can update it in place
+		Data: map[string]interface{}{
+			"message_id":   delivery.MessageID,
+			"is_delivered": true,
+			"delivered_at": delivery.DeliveredAt,
+		},
+		Timestamp: time.Now(),
+	})
+}
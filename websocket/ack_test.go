@@ -0,0 +1,74 @@
+package websocket
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// fakeAcknowledger delivers each known message once
+type fakeAcknowledger struct {
+	mu       sync.Mutex
+	senders  map[uuid.UUID]uuid.UUID // Message ID -> sender ID
+	receiver uuid.UUID
+}
+
+func (a *fakeAcknowledger) AcknowledgeDelivery(receiverID, messageID uuid.UUID) (*Delivery, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	senderID, ok := a.senders[messageID]
+	if !ok || receiverID != a.receiver {
+		return nil, nil
+	}
+	delete(a.senders, messageID)
+	return &Delivery{MessageID: messageID, SenderID: senderID, DeliveredAt: time.Now()}, nil
+}
+
+func TestHub_AckSendsDeliveryReceipt(t *testing.T) {
+	hub := startHub(t)
+	alice := newFakeClient(hub, "alice")
+	bob := newFakeClient(hub, "bob")
+	registerClient(t, hub, alice)
+	registerClient(t, hub, bob)
+
+	messageID := uuid.New()
+	hub.SetDeliveryAcknowledger(&fakeAcknowledger{
+		senders:  map[uuid.UUID]uuid.UUID{messageID: alice.UserID},
+		receiver: bob.UserID,
+	})
+
+	// Only the receiver's ack counts
+	hub.Ack(alice.UserID, messageID)
+	assertNoType(t, alice, "delivery_receipt")
+
+	hub.Ack(bob.UserID, messageID)
+	msg := receiveType(t, alice, "delivery_receipt")
+	if msg.MessageID == nil || *msg.MessageID != messageID {
+		t.Fatalf("unexpected message_id: %v", msg.MessageID)
+	}
+	if msg.SenderID != bob.UserID {
+		t.Fatalf("expected receipt from bob, got %s", msg.SenderID)
+	}
+	if _, ok := msg.Data["delivered_at"]; !ok {
+		t.Fatal("expected delivered_at in receipt data")
+	}
+	if msg.Data["message_id"] != messageID.String() || msg.Data["is_delivered"] != true {
+		t.Fatalf("unexpected receipt data: %v", msg.Data)
+	}
+
+	// A repeated ack has nothing new to report
+	hub.Ack(bob.UserID, messageID)
+	assertNoType(t, alice, "delivery_receipt")
+}
+
+func TestHub_AckWithoutAcknowledger(t *testing.T) {
+	hub := startHub(t)
+	alice := newFakeClient(hub, "alice")
+	registerClient(t, hub, alice)
+
+	hub.Ack(alice.UserID, uuid.New())
+	assertNoType(t, alice, "delivery_receipt")
+}
@@ -2,11 +2,11 @@ package websocket
 
 import (
 	"encoding/json"
-	"log"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
+	"mms-backend/utils"
 )
 
 const (
@@ -30,6 +30,19 @@ type Client struct {
 	Send     chan []byte
 	UserID   uuid.UUID
 	Username string
+
+	compression CompressionOptions
+
+	// closed is set, under Hub.mu, once the hub closed Send; dropped when it did so because
+	// the buffer was full
+	closed  bool
+	dropped bool
+
+	// While catchingUp, the hub holds what it delivers in caughtUp so the pending_messages event
+	// goes first; registered is closed once the hub lists the client. See Handler.catchUp.
+	catchingUp bool
+	caughtUp   [][]byte
+	registered chan struct{}
 }
 
 // Message represents a WebSocket message
@@ -40,6 +53,9 @@ type Message struct {
 	GroupID    uuid.UUID              `json:"group_id,omitempty"`
 	Content    string                 `json:"content,omitempty"`
 	Data       map[string]interface{} `json:"data,omitempty"`
+	ReadAt     *time.Time             `json:"read_at,omitempty"`
+	MessageIDs []uuid.UUID            `json:"message_ids,omitempty"`
+	MessageID  *uuid.UUID             `json:"message_id,omitempty"` // Set on new_message events, and by clients acknowledging one
 	Timestamp  time.Time              `json:"timestamp"`
 }
 
@@ -61,7 +77,7 @@ func (c *Client) readPump() {
 		_, messageData, err := c.Conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("WebSocket error: %v", err)
+				utils.Logger.Warn("WebSocket error", "user_id", c.UserID, "error", err)
 			}
 			break
 		}
@@ -69,7 +85,7 @@ func (c *Client) readPump() {
 		// Parse message
 		var msg Message
 		if err := json.Unmarshal(messageData, &msg); err != nil {
-			log.Printf("Error parsing message: %v", err)
+			utils.Logger.Warn("Failed to parse WebSocket message", "user_id", c.UserID, "error", err)
 			continue
 		}
 
@@ -79,32 +95,40 @@ func (c *Client) readPump() {
 
 		// Handle different message types
 		switch msg.Type {
-		case "new_message":
-			// Direct message - route to specific user
-			c.Hub.directMessage <- &msg
-		case "new_group_message":
-			// Group message - broadcast to group members
-			c.Hub.groupMessage <- &msg
-		case "message_read":
-			// Read receipt - notify specific user
-			c.Hub.directMessage <- &msg
-		case "typing":
-			// Typing indicator
-			c.Hub.broadcast <- messageData
+		case "typing_start", "typing_stop":
+			// Typing indicator - only the conversation partner needs it
+			if msg.ReceiverID != uuid.Nil {
+				c.Hub.PublishTyping(&TypingEvent{
+					SenderID:   c.UserID,
+					ReceiverID: msg.ReceiverID,
+					IsTyping:   msg.Type == "typing_start",
+				})
+			}
+		case "ack":
+			// Delivery acknowledgment of a new_message event
+			if msg.MessageID != nil {
+				c.Hub.Ack(c.UserID, *msg.MessageID)
+			}
 		case "ping":
 			// Heartbeat
-			pongMsg := Message{
-				Type:      "pong",
-				Timestamp: time.Now(),
-			}
-			pongData, _ := json.Marshal(pongMsg)
-			c.Send <- pongData
+			c.sendPong()
 		default:
-			log.Printf("Unknown message type: %s", msg.Type)
+			utils.Logger.Warn("Unknown WebSocket message type", "user_id", c.UserID, "type", msg.Type)
 		}
 	}
 }
 
+// sendPong answers a client's ping through the hub, which may have closed Send already, e.g.
+// when a newer connection evicted this one
+func (c *Client) sendPong() {
+	pongMsg := Message{
+		Type:      "pong",
+		Timestamp: time.Now(),
+	}
+	pongData, _ := json.Marshal(pongMsg)
+	c.Hub.sendToClient(c, pongData)
+}
+
 // writePump pumps messages from the hub to the websocket connection
 func (c *Client) writePump() {
 	ticker := time.NewTicker(pingPeriod)
@@ -113,6 +137,14 @@ func (c *Client) writePump() {
 		c.Conn.Close()
 	}()
 
+	// Only takes effect when permessage-deflate was negotiated during the upgrade
+	if c.compression.Enabled {
+		c.Conn.EnableWriteCompression(true)
+		if err := c.Conn.SetCompressionLevel(c.compression.Level); err != nil {
+			utils.Logger.Warn("Invalid WebSocket compression level", "level", c.compression.Level, "error", err)
+		}
+	}
+
 	for {
 		select {
 		case message, ok := <-c.Send:
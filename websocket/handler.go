@@ -1,36 +1,49 @@
 package websocket
 
 import (
-	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	"mms-backend/middleware"
+	"mms-backend/utils"
 )
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		// In production, you should check the origin
-		return true
-	},
+// CompressionOptions controls permessage-deflate on WebSocket connections
+type CompressionOptions struct {
+	Enabled bool
+	Level   int // flate level, from flate.BestSpeed to flate.BestCompression
 }
 
 // Handler handles WebSocket connections
 type Handler struct {
-	hub *Hub
+	hub         *Hub
+	upgrader    websocket.Upgrader
+	compression CompressionOptions
+	missed      MissedMessagesLoader
 }
 
 // NewHandler creates a new WebSocket handler
-func NewHandler(hub *Hub) *Handler {
+func NewHandler(hub *Hub, compression CompressionOptions) *Handler {
 	return &Handler{
 		hub: hub,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin: func(r *http.Request) bool {
+				// In production, you should check the origin
+				return true
+			},
+			// Negotiate permessage-deflate with clients that offer it
+			EnableCompression: compression.Enabled,
+		},
+		compression: compression,
 	}
 }
 
-// HandleWebSocket handles WebSocket upgrade and connection
+// HandleWebSocket handles WebSocket upgrade and connection. A client reconnecting with the
+// last_seen_message_id query parameter first receives the messages it missed since then.
 func (h *Handler) HandleWebSocket(c *gin.Context) {
 	// Get user info from auth middleware
 	userID, exists := middleware.GetUserID(c)
@@ -47,10 +60,22 @@ func (h *Handler) HandleWebSocket(c *gin.Context) {
 		usernameStr = "Unknown"
 	}
 
+	var lastSeenMessageID uuid.UUID
+	if raw := c.Query("last_seen_message_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid last_seen_message_id",
+			})
+			return
+		}
+		lastSeenMessageID = id
+	}
+
 	// Upgrade HTTP connection to WebSocket
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
-		log.Printf("Failed to upgrade connection: %v", err)
+		utils.LoggerFromContext(c.Request.Context()).Warn("Failed to upgrade connection", "error", err)
 		return
 	}
 
@@ -61,10 +86,20 @@ func (h *Handler) HandleWebSocket(c *gin.Context) {
 		Send:     make(chan []byte, 256),
 		UserID:   userID,
 		Username: usernameStr,
+
+		compression: h.compression,
 	}
 
-	// Register client
+	// Register before loading the missed messages, so none sent in between is lost
+	catchUp := lastSeenMessageID != uuid.Nil && h.missed != nil
+	if catchUp {
+		client.catchingUp = true
+		client.registered = make(chan struct{})
+	}
 	client.Hub.register <- client
+	if catchUp {
+		h.catchUp(client, lastSeenMessageID)
+	}
 
 	// Start goroutines for reading and writing
 	go client.writePump()
@@ -0,0 +1,400 @@
+package websocket
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// newTestServer serves the handler at / and authenticates every request as a fresh user
+func newTestServer(t testing.TB, hub *Hub, compression CompressionOptions) *httptest.Server {
+	t.Helper()
+	return serveHandler(t, NewHandler(hub, compression))
+}
+
+// serveHandler serves handler at / and authenticates every request as a fresh user
+func serveHandler(t testing.TB, handler *Handler) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/", func(c *gin.Context) {
+		c.Set("user_id", uuid.New())
+		c.Set("username", "tester")
+		handler.HandleWebSocket(c)
+	})
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// dial connects to the test server, offering permessage-deflate
+func dial(t testing.TB, server *httptest.Server) (*websocket.Conn, *http.Response) {
+	t.Helper()
+	dialer := websocket.Dialer{EnableCompression: true}
+	conn, resp, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	return conn, resp
+}
+
+func TestHandler_NegotiatesCompression(t *testing.T) {
+	hub := startHub(t)
+	server := newTestServer(t, hub, CompressionOptions{Enabled: true, Level: flate.BestSpeed})
+
+	conn, resp := dial(t, server)
+	defer conn.Close()
+
+	if ext := resp.Header.Get("Sec-WebSocket-Extensions"); !strings.Contains(ext, "permessage-deflate") {
+		t.Fatalf("expected permessage-deflate extension, got %q", ext)
+	}
+}
+
+func TestHandler_CompressionDisabled(t *testing.T) {
+	hub := startHub(t)
+	server := newTestServer(t, hub, CompressionOptions{Enabled: false})
+
+	conn, resp := dial(t, server)
+	defer conn.Close()
+
+	if ext := resp.Header.Get("Sec-WebSocket-Extensions"); ext != "" {
+		t.Fatalf("expected no extensions when compression is disabled, got %q", ext)
+	}
+}
+
+// benchPayload is a 10 KB system message, roughly the size of a large group broadcast
+// fakeMissedLoader returns a fixed payload and records what it was asked for
+type fakeMissedLoader struct {
+	lastSeen chan uuid.UUID
+}
+
+func (f *fakeMissedLoader) LoadMissedMessages(userID, lastSeenMessageID uuid.UUID) (map[string]interface{}, error) {
+	f.lastSeen <- lastSeenMessageID
+	return map[string]interface{}{"messages": []string{"missed"}}, nil
+}
+
+func TestHandler_SendsMissedMessagesFirst(t *testing.T) {
+	hub := startHub(t)
+	loader := &fakeMissedLoader{lastSeen: make(chan uuid.UUID, 1)}
+	handler := NewHandler(hub, CompressionOptions{})
+	handler.SetMissedMessagesLoader(loader)
+	server := serveHandler(t, handler)
+
+	lastSeen := uuid.New()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/?last_seen_message_id="+lastSeen.String(), nil)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	if got := <-loader.lastSeen; got != lastSeen {
+		t.Fatalf("expected loader to be asked for %s, got %s", lastSeen, got)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+	// The write pump may batch several events into one frame, one per line
+	var msg Message
+	if err := json.Unmarshal(bytes.SplitN(data, []byte{'\n'}, 2)[0], &msg); err != nil {
+		t.Fatalf("invalid payload %s: %v", data, err)
+	}
+	if msg.Type != "pending_messages" {
+		t.Fatalf("expected pending_messages first, got %s", msg.Type)
+	}
+	if messages, ok := msg.Data["messages"].([]interface{}); !ok || len(messages) != 1 {
+		t.Fatalf("unexpected pending_messages data: %v", msg.Data)
+	}
+}
+
+func TestHandler_MissedMessagesParameter(t *testing.T) {
+	hub := startHub(t)
+	loader := &fakeMissedLoader{lastSeen: make(chan uuid.UUID, 1)}
+	handler := NewHandler(hub, CompressionOptions{})
+	handler.SetMissedMessagesLoader(loader)
+	server := serveHandler(t, handler)
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	_, resp, err := websocket.DefaultDialer.Dial(url+"/?last_seen_message_id=not-a-uuid", nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid id, got %v", resp)
+	}
+
+	// Without the parameter nothing is loaded
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer conn.Close()
+	select {
+	case id := <-loader.lastSeen:
+		t.Fatalf("unexpected load for %s", id)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// racingLoader delivers two messages while the missed messages load, one of them in the result
+type racingLoader struct {
+	hub          *Hub
+	loaded, late uuid.UUID
+}
+
+func (l *racingLoader) LoadMissedMessages(userID, lastSeenMessageID uuid.UUID) (map[string]interface{}, error) {
+	for _, id := range []uuid.UUID{l.loaded, l.late} {
+		id := id
+		if err := l.hub.SendToUser(userID, &Message{Type: "new_message", MessageID: &id}); err != nil {
+			return nil, err
+		}
+	}
+	return map[string]interface{}{"messages": []map[string]uuid.UUID{{"id": l.loaded}}}, nil
+}
+
+func TestHandler_MessagesSentWhileCatchingUp(t *testing.T) {
+	hub := startHub(t)
+	loader := &racingLoader{hub: hub, loaded: uuid.New(), late: uuid.New()}
+	handler := NewHandler(hub, CompressionOptions{})
+	handler.SetMissedMessagesLoader(loader)
+	server := serveHandler(t, handler)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/?last_seen_message_id="+uuid.New().String(), nil)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	// Sent after registration, both arrive: the loaded one in pending_messages only.
+	// Presence events are not messages, so they are left out.
+	var events []Message
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for len(events) == 0 || events[len(events)-1].Type != "new_message" {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("failed to read: %v (got %v)", err, events)
+		}
+		for _, line := range bytes.Split(data, []byte{'\n'}) {
+			var msg Message
+			if err := json.Unmarshal(line, &msg); err != nil {
+				t.Fatalf("invalid payload %s: %v", line, err)
+			}
+			if msg.Type == "pending_messages" || msg.Type == "new_message" {
+				events = append(events, msg)
+			}
+		}
+	}
+	if len(events) != 2 || events[0].Type != "pending_messages" {
+		t.Fatalf("expected pending_messages then one new_message, got %v", events)
+	}
+	if got := events[1].MessageID; got == nil || *got != loader.late {
+		t.Fatalf("expected the message missing from pending_messages, got %v", got)
+	}
+}
+
+// dialedUser waits for the only connected user of hub, the one a test just dialed
+func dialedUser(t *testing.T, hub *Hub) uuid.UUID {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if users := hub.GetOnlineUsers(); len(users) == 1 {
+			return users[0]
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("timeout waiting for the dialed client to register")
+	return uuid.Nil
+}
+
+// pingPong sends a ping and waits for the pong, so every frame written before it was handled
+func pingPong(t *testing.T, conn *websocket.Conn) {
+	t.Helper()
+	if err := conn.WriteJSON(map[string]string{"type": "ping"}); err != nil {
+		t.Fatalf("failed to write ping: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("failed to read pong: %v", err)
+		}
+		if bytes.Contains(data, []byte(`"type":"pong"`)) {
+			return
+		}
+	}
+}
+
+func TestHandler_IgnoresClientGroupMessages(t *testing.T) {
+	hub := startHub(t)
+	server := newTestServer(t, hub, CompressionOptions{})
+
+	conn, _ := dial(t, server)
+	defer conn.Close()
+	senderID := dialedUser(t, hub)
+
+	member := newFakeClient(hub, "member")
+	registerClient(t, hub, member)
+	groupID := uuid.New()
+	hub.SyncGroupMembers(groupID, []uuid.UUID{senderID, member.UserID})
+
+	// Group messages are only sent through GroupService, which checks membership and permissions
+	if err := conn.WriteJSON(map[string]interface{}{
+		"type":     "new_group_message",
+		"group_id": groupID,
+		"content":  "unchecked",
+	}); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	pingPong(t, conn)
+	assertNoType(t, member, "new_group_message")
+}
+
+func TestHandler_IgnoresClientDirectMessages(t *testing.T) {
+	hub := startHub(t)
+	server := newTestServer(t, hub, CompressionOptions{})
+
+	conn, _ := dial(t, server)
+	defer conn.Close()
+	dialedUser(t, hub)
+
+	receiver := newFakeClient(hub, "receiver")
+	registerClient(t, hub, receiver)
+
+	// Messages and read receipts are only sent through MessageService, which checks blocks
+	// and that the messages exist
+	for _, eventType := range []string{"new_message", "message_read"} {
+		if err := conn.WriteJSON(map[string]interface{}{
+			"type":        eventType,
+			"receiver_id": receiver.UserID,
+			"content":     "unchecked",
+			"message_ids": []uuid.UUID{uuid.New()},
+		}); err != nil {
+			t.Fatalf("failed to write: %v", err)
+		}
+		pingPong(t, conn)
+		assertNoType(t, receiver, eventType)
+	}
+}
+
+func TestHandler_PingAfterEviction(t *testing.T) {
+	hub := NewHub()
+	hub.SetMaxConnectionsPerUser(1)
+	go hub.Run()
+
+	// Every request is the same user, so each connection evicts the previous one
+	userID := uuid.New()
+	handler := NewHandler(hub, CompressionOptions{})
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/", func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Set("username", "tester")
+		handler.HandleWebSocket(c)
+	})
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	oldest, _ := dial(t, server)
+	defer oldest.Close()
+	pingPong(t, oldest)
+
+	newest, _ := dial(t, server)
+	defer newest.Close()
+	pingPong(t, newest)
+
+	// The hub closed the evicted connection's Send channel; answering its ping must not panic
+	_ = oldest.WriteJSON(map[string]string{"type": "ping"})
+	oldest.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		_, _, err := oldest.ReadMessage()
+		if err == nil {
+			continue
+		}
+		var netErr interface{ Timeout() bool }
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			t.Fatal("expected the evicted connection to be closed")
+		}
+		break
+	}
+
+	pingPong(t, newest)
+}
+
+func benchPayload(b *testing.B) []byte {
+	b.Helper()
+	content := strings.Repeat("Quarterly planning notes for the whole team. ", 10*1024/45)
+	payload, err := json.Marshal(Message{
+		Type:      "bench_broadcast",
+		Content:   content,
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		b.Fatalf("failed to marshal payload: %v", err)
+	}
+	return payload
+}
+
+// benchmarkHubBroadcast broadcasts a 10 KB payload to 100 connected clients per iteration
+func benchmarkHubBroadcast(b *testing.B, compression CompressionOptions) {
+	const clients = 100
+
+	hub := NewHub()
+	go hub.Run()
+	server := newTestServer(b, hub, compression)
+
+	marker := []byte(`"type":"bench_broadcast"`)
+	received := make(chan struct{}, clients)
+	for i := 0; i < clients; i++ {
+		conn, _ := dial(b, server)
+		defer conn.Close()
+		go func() {
+			for {
+				_, frame, err := conn.ReadMessage()
+				if err != nil {
+					return
+				}
+				for n := bytes.Count(frame, marker); n > 0; n-- {
+					received <- struct{}{}
+				}
+			}
+		}()
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(hub.GetOnlineUsers()) < clients {
+		if time.Now().After(deadline) {
+			b.Fatal("timeout waiting for clients to register")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	payload := benchPayload(b)
+	b.SetBytes(int64(len(payload) * clients))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		hub.BroadcastToAll(payload)
+		for j := 0; j < clients; j++ {
+			<-received
+		}
+	}
+}
+
+func BenchmarkHubBroadcastCompressed(b *testing.B) {
+	benchmarkHubBroadcast(b, CompressionOptions{Enabled: true, Level: flate.BestSpeed})
+}
+
+func BenchmarkHubBroadcastUncompressed(b *testing.B) {
+	benchmarkHubBroadcast(b, CompressionOptions{Enabled: false})
+}
@@ -2,24 +2,44 @@ package websocket
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
+	"mms-backend/metrics"
+	"mms-backend/utils"
 )
 
+// DefaultMaxConnectionsPerUser is how many connections a user may keep open unless configured
+const DefaultMaxConnectionsPerUser = 5
+
+// ErrOffline is returned when an event is not delivered because the user is not connected
+var ErrOffline = errors.New("user is offline")
+
 // Hub maintains the set of active clients and broadcasts messages to clients
 type Hub struct {
-	// Registered clients (mapped by user ID)
-	clients map[uuid.UUID]*Client
+	// Registered clients (mapped by user ID), oldest connection first
+	clients map[uuid.UUID][]*Client
+
+	// Connections a user may keep open; registering past it evicts the oldest
+	maxConnectionsPerUser int
 
 	// Inbound messages from the clients
 	broadcast chan []byte
 
-	// Direct messages to specific users
-	directMessage chan *Message
+	// Typing indicators, delivered to their receiver only
+	typing chan *TypingEvent
 
-	// Group messages
-	groupMessage chan *Message
+	// When each typing_start was last delivered, for debouncing; only used by Run
+	typingSent map[typingPair]time.Time
+
+	// Delivery acknowledgments from receivers' clients
+	ack          chan *ackEvent
+	acknowledger DeliveryAcknowledger
+
+	// Stops typing indicators between users when either blocked the other
+	blockChecker BlockChecker
 
 	// Register requests from the clients
 	register chan *Client
@@ -27,161 +47,332 @@ type Hub struct {
 	// Unregister requests from clients
 	unregister chan *Client
 
-	// Group memberships (groupID -> []userID)
-	groups map[uuid.UUID][]uuid.UUID
+	// Group memberships (groupID -> []userID), kept in sync by GroupService
+	groups   map[uuid.UUID][]uuid.UUID
+	groupsMu sync.RWMutex
+
+	// Guards clients, acknowledger and blockChecker, which are also accessed from
+	// service and client goroutines
+	mu sync.RWMutex
+
+	// Decides who sees user_joined/user_left/user_presence; nil broadcasts to everyone
+	presenceLoader PresenceSubscribersLoader
+	presenceCache  map[uuid.UUID]presenceCacheEntry
+	presenceMu     sync.Mutex
+
+	// OnClientConnect and OnClientDisconnect are called when a user's first connection opens
+	// and their last one closes, e.g. to persist their online status. Set them before Run.
+	OnClientConnect    func(userID uuid.UUID)
+	OnClientDisconnect func(userID uuid.UUID)
+
+	// Connection changes waiting for the callbacks above, handled in order
+	connectionChanges chan connectionChange
+
+	// now is replaceable so tests can expire the presence cache
+	now func() time.Time
 }
 
 // NewHub creates a new Hub
 func NewHub() *Hub {
 	return &Hub{
 		broadcast:     make(chan []byte),
-		directMessage: make(chan *Message),
-		groupMessage:  make(chan *Message),
+		typing:        make(chan *TypingEvent),
+		ack:           make(chan *ackEvent),
 		register:      make(chan *Client),
 		unregister:    make(chan *Client),
-		clients:       make(map[uuid.UUID]*Client),
+		clients:       make(map[uuid.UUID][]*Client),
 		groups:        make(map[uuid.UUID][]uuid.UUID),
+		typingSent:    make(map[typingPair]time.Time),
+		presenceCache: make(map[uuid.UUID]presenceCacheEntry),
+		now:           time.Now,
+
+		connectionChanges: make(chan connectionChange, connectionChangeBuffer),
+
+		maxConnectionsPerUser: DefaultMaxConnectionsPerUser,
 	}
 }
 
+// SetMaxConnectionsPerUser sets how many connections a user may keep open; values below one
+// are ignored. Call before Run.
+func (h *Hub) SetMaxConnectionsPerUser(max int) {
+	if max < 1 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.maxConnectionsPerUser = max
+}
+
 // Run starts the hub
 func (h *Hub) Run() {
+	go h.runConnectionCallbacks()
+
 	for {
 		select {
 		case client := <-h.register:
-			h.clients[client.UserID] = client
-			log.Printf("Client connected: %s (UserID: %s)", client.Username, client.UserID)
-
-			// Send user_joined event to all clients
-			joinedMsg := Message{
-				Type: "user_joined",
-				Data: map[string]interface{}{
-					"user_id":  client.UserID,
-					"username": client.Username,
-				},
+			h.mu.Lock()
+			firstConnection := len(h.clients[client.UserID]) == 0
+			h.addClient(client)
+			h.updateConnectedClients()
+			connections := len(h.clients[client.UserID])
+			h.mu.Unlock()
+			utils.Logger.Info("Client connected", "user_id", client.UserID, "username", client.Username, "connections", connections)
+			if client.registered != nil {
+				close(client.registered)
 			}
-			if data, err := json.Marshal(joinedMsg); err == nil {
-				h.BroadcastToAll(data)
+
+			// Tell the users allowed to see this user's presence, unless they already saw them online
+			if firstConnection {
+				h.connectionChanges <- connectionChange{userID: client.UserID, online: true}
+				h.publishPresence(client, true)
 			}
 
 		case client := <-h.unregister:
-			if _, ok := h.clients[client.UserID]; ok {
-				delete(h.clients, client.UserID)
-				close(client.Send)
-				log.Printf("Client disconnected: %s (UserID: %s)", client.Username, client.UserID)
-
-				// Send user_left event to all clients
-				leftMsg := Message{
-					Type: "user_left",
-					Data: map[string]interface{}{
-						"user_id":  client.UserID,
-						"username": client.Username,
-					},
-				}
-				if data, err := json.Marshal(leftMsg); err == nil {
-					h.BroadcastToAll(data)
-				}
+			h.mu.Lock()
+			// The client may already be gone, e.g. evicted by a newer connection or dropped for
+			// a full buffer. Only a dropped one still has to report the user going offline.
+			removed := h.removeClient(client)
+			if removed {
+				h.closeConnection(client)
+			}
+			lastConnection := (removed || client.dropped) && len(h.clients[client.UserID]) == 0
+			h.updateConnectedClients()
+			h.mu.Unlock()
+
+			if removed || client.dropped {
+				utils.Logger.Info("Client disconnected", "user_id", client.UserID, "username", client.Username)
+			}
+			if lastConnection {
+				h.forgetTyping(client.UserID)
+
+				h.connectionChanges <- connectionChange{userID: client.UserID, online: false}
+				h.publishPresence(client, false)
 			}
 
 		case message := <-h.broadcast:
 			// Broadcast to all connected clients
 			h.BroadcastToAll(message)
 
-		case message := <-h.directMessage:
-			// Send message to specific user
-			if message.ReceiverID != uuid.Nil {
-				h.SendToUser(message.ReceiverID, message)
-			}
+		case event := <-h.typing:
+			h.deliverTyping(event)
 
-		case message := <-h.groupMessage:
-			// Broadcast to all group members
-			if message.GroupID != uuid.Nil {
-				h.BroadcastToGroup(message.GroupID, message)
+		case event := <-h.ack:
+			// Recording the delivery hits the database, so keep it off the hub loop
+			go h.acknowledge(event)
+		}
+	}
+}
+
+// Register queues a client for registration with the hub
+func (h *Hub) Register(client *Client) {
+	h.register <- client
+}
+
+// Unregister queues a client for removal from the hub
+func (h *Hub) Unregister(client *Client) {
+	h.unregister <- client
+}
+
+// addClient registers a connection, evicting the user's oldest ones past the per-user limit.
+// Callers must hold h.mu.
+func (h *Hub) addClient(client *Client) {
+	clients := append(h.clients[client.UserID], client)
+	for len(clients) > h.maxConnectionsPerUser {
+		evicted := clients[0]
+		clients = clients[1:]
+
+		// Tell the connection why it is being closed; a full buffer just gets the close
+		if data, err := json.Marshal(Message{Type: "connection_evicted", Timestamp: time.Now()}); err == nil {
+			select {
+			case evicted.Send <- data:
+			default:
 			}
 		}
+		h.closeConnection(evicted)
+		utils.Logger.Info("Evicted oldest connection", "user_id", evicted.UserID, "username", evicted.Username)
 	}
+	h.clients[client.UserID] = clients
 }
 
-// BroadcastToAll sends a message to all connected clients
-func (h *Hub) BroadcastToAll(message []byte) {
-	for _, client := range h.clients {
-		select {
-		case client.Send <- message:
-		default:
-			close(client.Send)
+// closeConnection closes a connection's Send channel, so its writePump flushes what is queued
+// and closes the connection, and stops its reads right away so its readPump unregisters it.
+// Callers must hold h.mu.
+func (h *Hub) closeConnection(client *Client) {
+	close(client.Send)
+	client.closed = true
+	if client.Conn != nil {
+		client.Conn.SetReadDeadline(time.Now())
+	}
+}
+
+// sendToClient delivers data to one connection, e.g. a pong, unless the hub already closed it.
+// Data for a full buffer is dropped.
+func (h *Hub) sendToClient(client *Client, data []byte) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if client.closed {
+		return
+	}
+	select {
+	case client.Send <- data:
+	default:
+	}
+}
+
+// removeClient forgets a connection and reports whether it was registered.
+// Callers must hold h.mu.
+func (h *Hub) removeClient(client *Client) bool {
+	clients := h.clients[client.UserID]
+	for i, c := range clients {
+		if c != client {
+			continue
+		}
+		remaining := append(clients[:i:i], clients[i+1:]...)
+		if len(remaining) == 0 {
 			delete(h.clients, client.UserID)
+		} else {
+			h.clients[client.UserID] = remaining
 		}
+		return true
+	}
+	return false
+}
+
+// updateConnectedClients sets the websocket_connected_clients gauge to the number of open
+// connections. Callers must hold h.mu.
+func (h *Hub) updateConnectedClients() {
+	count := 0
+	for _, clients := range h.clients {
+		count += len(clients)
 	}
+	metrics.WebSocketConnectedClients.Set(float64(count))
 }
 
-// SendToUser sends a message to a specific user
-func (h *Hub) SendToUser(userID uuid.UUID, message *Message) {
-	client, ok := h.clients[userID]
+// sendToConnections delivers data to every connection of userID, dropping connections whose
+// buffer is full, and reports whether the user had any. Callers must hold h.mu.
+func (h *Hub) sendToConnections(userID uuid.UUID, data []byte) bool {
+	clients, ok := h.clients[userID]
 	if !ok {
-		log.Printf("User %s is not connected", userID)
-		return
+		return false
+	}
+	for _, client := range clients {
+		if client.catchingUp {
+			if len(client.caughtUp) < cap(client.Send) {
+				client.caughtUp = append(client.caughtUp, data)
+			} else {
+				h.dropConnection(client)
+			}
+			continue
+		}
+		select {
+		case client.Send <- data:
+		default:
+			h.dropConnection(client)
+		}
 	}
+	return true
+}
+
+// dropConnection closes and forgets a connection whose send buffer is full. Its unregister,
+// once the pumps notice the closed channel, reports the disconnect. Callers must hold h.mu.
+func (h *Hub) dropConnection(client *Client) {
+	h.closeConnection(client)
+	h.removeClient(client)
+	client.dropped = true
+	utils.Logger.Warn("Dropped connection: send buffer full", "user_id", client.UserID)
+}
 
+// BroadcastToAll sends a message to all connected clients
+func (h *Hub) BroadcastToAll(message []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for userID := range h.clients {
+		h.sendToConnections(userID, message)
+	}
+}
+
+// SendToUser sends a message to a specific user.
+// If the user is not connected the message is dropped and ErrOffline is returned: clients
+// catch up from the database when they reconnect, see Handler.SetMissedMessagesLoader.
+func (h *Hub) SendToUser(userID uuid.UUID, message *Message) error {
 	data, err := json.Marshal(message)
 	if err != nil {
-		log.Printf("Error marshaling message: %v", err)
-		return
+		utils.Logger.Error("Failed to marshal message", "error", err)
+		return err
 	}
 
-	select {
-	case client.Send <- data:
-	default:
-		close(client.Send)
-		delete(h.clients, userID)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.sendToConnections(userID, data) {
+		return ErrOffline
 	}
+	return nil
 }
 
-// BroadcastToGroup sends a message to all members of a group
+// BroadcastToGroup sends a message to the connected members of a group
 func (h *Hub) BroadcastToGroup(groupID uuid.UUID, message *Message) {
-	// Get group members
+	h.groupsMu.RLock()
 	members, ok := h.groups[groupID]
+	h.groupsMu.RUnlock()
 	if !ok {
-		log.Printf("Group %s not found in hub", groupID)
-		// In a real implementation, you would fetch members from the database
+		utils.Logger.Debug("Group not found in hub", "group_id", groupID)
 		return
 	}
 
 	data, err := json.Marshal(message)
 	if err != nil {
-		log.Printf("Error marshaling message: %v", err)
+		utils.Logger.Error("Failed to marshal message", "error", err)
 		return
 	}
 
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
 	// Send to all group members
 	for _, memberID := range members {
-		if client, ok := h.clients[memberID]; ok {
-			select {
-			case client.Send <- data:
-			default:
-				close(client.Send)
-				delete(h.clients, memberID)
-			}
-		}
+		h.sendToConnections(memberID, data)
 	}
 }
 
+// SyncGroupMembers replaces the members of a group used for message routing; an empty list
+// forgets the group
+func (h *Hub) SyncGroupMembers(groupID uuid.UUID, memberIDs []uuid.UUID) {
+	h.groupsMu.Lock()
+	defer h.groupsMu.Unlock()
+
+	if len(memberIDs) == 0 {
+		delete(h.groups, groupID)
+		return
+	}
+	// Copy so the caller's slice can't change the routing table
+	h.groups[groupID] = append([]uuid.UUID(nil), memberIDs...)
+}
+
 // AddUserToGroup adds a user to a group for message routing
 func (h *Hub) AddUserToGroup(groupID, userID uuid.UUID) {
-	if members, ok := h.groups[groupID]; ok {
-		// Check if user is already in the group
-		for _, id := range members {
-			if id == userID {
-				return
-			}
+	h.groupsMu.Lock()
+	defer h.groupsMu.Unlock()
+
+	members := h.groups[groupID]
+	for _, id := range members {
+		if id == userID {
+			return
 		}
-		h.groups[groupID] = append(members, userID)
-	} else {
-		h.groups[groupID] = []uuid.UUID{userID}
 	}
+	// Appending to a fresh slice keeps the one BroadcastToGroup may be reading untouched
+	updated := make([]uuid.UUID, len(members), len(members)+1)
+	copy(updated, members)
+	h.groups[groupID] = append(updated, userID)
 }
 
 // RemoveUserFromGroup removes a user from a group
 func (h *Hub) RemoveUserFromGroup(groupID, userID uuid.UUID) {
+	h.groupsMu.Lock()
+	defer h.groupsMu.Unlock()
+
 	if members, ok := h.groups[groupID]; ok {
 		newMembers := make([]uuid.UUID, 0)
 		for _, id := range members {
@@ -199,6 +390,9 @@ func (h *Hub) RemoveUserFromGroup(groupID, userID uuid.UUID) {
 
 // GetOnlineUsers returns a list of online user IDs
 func (h *Hub) GetOnlineUsers() []uuid.UUID {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
 	users := make([]uuid.UUID, 0, len(h.clients))
 	for userID := range h.clients {
 		users = append(users, userID)
@@ -208,7 +402,9 @@ func (h *Hub) GetOnlineUsers() []uuid.UUID {
 
 // IsUserOnline checks if a user is online
 func (h *Hub) IsUserOnline(userID uuid.UUID) bool {
-	_, ok := h.clients[userID]
-	return ok
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.clients[userID]) > 0
 }
 
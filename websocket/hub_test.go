@@ -0,0 +1,400 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"mms-backend/metrics"
+)
+
+// newFakeClient returns a client with a buffered Send channel and no real connection
+func newFakeClient(hub *Hub, username string) *Client {
+	return &Client{
+		Hub:      hub,
+		Send:     make(chan []byte, 32),
+		UserID:   uuid.New(),
+		Username: username,
+	}
+}
+
+func startHub(t *testing.T) *Hub {
+	t.Helper()
+	hub := NewHub()
+	go hub.Run()
+	return hub
+}
+
+func registerClient(t *testing.T, hub *Hub, client *Client) {
+	t.Helper()
+	hub.Register(client)
+	waitForOnline(t, hub, client.UserID, true)
+}
+
+func waitForOnline(t *testing.T, hub *Hub, userID uuid.UUID, online bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if hub.IsUserOnline(userID) == online {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("timeout waiting for user %s online=%v", userID, online)
+}
+
+// receiveType reads from the client's Send channel until a message of the given type arrives
+func receiveType(t *testing.T, client *Client, msgType string) Message {
+	t.Helper()
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case data, ok := <-client.Send:
+			if !ok {
+				t.Fatalf("send channel closed while waiting for %s", msgType)
+			}
+			var msg Message
+			if err := json.Unmarshal(data, &msg); err != nil {
+				t.Fatalf("invalid message payload: %v", err)
+			}
+			if msg.Type == msgType {
+				return msg
+			}
+		case <-deadline:
+			t.Fatalf("timeout waiting for %s", msgType)
+		}
+	}
+}
+
+// assertNoType verifies that no message of the given type is pending on the client
+func assertNoType(t *testing.T, client *Client, msgType string) {
+	t.Helper()
+	deadline := time.After(100 * time.Millisecond)
+	for {
+		select {
+		case data := <-client.Send:
+			var msg Message
+			if err := json.Unmarshal(data, &msg); err == nil && msg.Type == msgType {
+				t.Fatalf("unexpected %s message for %s", msgType, client.Username)
+			}
+		case <-deadline:
+			return
+		}
+	}
+}
+
+func TestHub_RegisterAndUnregister(t *testing.T) {
+	hub := startHub(t)
+	client := newFakeClient(hub, "alice")
+
+	registerClient(t, hub, client)
+	if !hub.IsUserOnline(client.UserID) {
+		t.Fatal("expected client to be online after register")
+	}
+
+	hub.Unregister(client)
+	waitForOnline(t, hub, client.UserID, false)
+
+	if hub.IsUserOnline(client.UserID) {
+		t.Fatal("expected client to be offline after unregister")
+	}
+}
+
+func TestHub_SendToUser(t *testing.T) {
+	hub := startHub(t)
+	client := newFakeClient(hub, "alice")
+	registerClient(t, hub, client)
+
+	err := hub.SendToUser(client.UserID, &Message{Type: "new_message", Content: "hello"})
+	if err != nil {
+		t.Fatalf("SendToUser returned error: %v", err)
+	}
+
+	msg := receiveType(t, client, "new_message")
+	if msg.Content != "hello" {
+		t.Fatalf("unexpected content: %q", msg.Content)
+	}
+}
+
+func TestHub_SendReadReceipt(t *testing.T) {
+	hub := startHub(t)
+	client := newFakeClient(hub, "alice")
+	registerClient(t, hub, client)
+
+	readAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	ids := []uuid.UUID{uuid.New(), uuid.New()}
+	err := hub.SendToUser(client.UserID, &Message{Type: "message_read", ReadAt: &readAt, MessageIDs: ids})
+	if err != nil {
+		t.Fatalf("SendToUser returned error: %v", err)
+	}
+
+	msg := receiveType(t, client, "message_read")
+	if msg.ReadAt == nil || !msg.ReadAt.Equal(readAt) {
+		t.Fatalf("unexpected read_at: %v", msg.ReadAt)
+	}
+	if len(msg.MessageIDs) != 2 || msg.MessageIDs[0] != ids[0] || msg.MessageIDs[1] != ids[1] {
+		t.Fatalf("unexpected message_ids: %v", msg.MessageIDs)
+	}
+}
+
+func TestHub_BroadcastToAll(t *testing.T) {
+	hub := startHub(t)
+	clients := []*Client{
+		newFakeClient(hub, "alice"),
+		newFakeClient(hub, "bob"),
+		newFakeClient(hub, "carol"),
+	}
+	for _, client := range clients {
+		registerClient(t, hub, client)
+	}
+
+	data, _ := json.Marshal(Message{Type: "announcement", Content: "hi all"})
+	hub.BroadcastToAll(data)
+
+	for _, client := range clients {
+		msg := receiveType(t, client, "announcement")
+		if msg.Content != "hi all" {
+			t.Fatalf("unexpected content for %s: %q", client.Username, msg.Content)
+		}
+	}
+}
+
+func TestHub_BroadcastToGroup(t *testing.T) {
+	hub := startHub(t)
+	alice := newFakeClient(hub, "alice")
+	bob := newFakeClient(hub, "bob")
+	carol := newFakeClient(hub, "carol")
+	for _, client := range []*Client{alice, bob, carol} {
+		registerClient(t, hub, client)
+	}
+
+	groupID := uuid.New()
+	hub.AddUserToGroup(groupID, alice.UserID)
+	hub.AddUserToGroup(groupID, bob.UserID)
+
+	hub.BroadcastToGroup(groupID, &Message{Type: "new_group_message", GroupID: groupID, Content: "team"})
+
+	receiveType(t, alice, "new_group_message")
+	receiveType(t, bob, "new_group_message")
+	assertNoType(t, carol, "new_group_message")
+}
+
+func TestHub_SyncGroupMembers(t *testing.T) {
+	hub := startHub(t)
+	alice := newFakeClient(hub, "alice")
+	bob := newFakeClient(hub, "bob")
+	for _, client := range []*Client{alice, bob} {
+		registerClient(t, hub, client)
+	}
+
+	groupID := uuid.New()
+	members := []uuid.UUID{alice.UserID, bob.UserID}
+	hub.SyncGroupMembers(groupID, members)
+	members[1] = uuid.New() // The hub keeps its own copy
+
+	hub.BroadcastToGroup(groupID, &Message{Type: "new_group_message", GroupID: groupID})
+	receiveType(t, alice, "new_group_message")
+	receiveType(t, bob, "new_group_message")
+
+	hub.RemoveUserFromGroup(groupID, alice.UserID)
+	hub.BroadcastToGroup(groupID, &Message{Type: "new_group_message", GroupID: groupID})
+	receiveType(t, bob, "new_group_message")
+	assertNoType(t, alice, "new_group_message")
+
+	// An empty list forgets the group
+	hub.SyncGroupMembers(groupID, nil)
+	hub.BroadcastToGroup(groupID, &Message{Type: "new_group_message", GroupID: groupID})
+	assertNoType(t, bob, "new_group_message")
+}
+
+func TestHub_GroupMembershipConcurrentAccess(t *testing.T) {
+	hub := startHub(t)
+	alice := newFakeClient(hub, "alice")
+	registerClient(t, hub, alice)
+
+	groupID := uuid.New()
+	hub.AddUserToGroup(groupID, alice.UserID)
+
+	// Membership changes come from service goroutines while messages are being routed;
+	// run with -race to catch unguarded access
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			userID := uuid.New()
+			hub.AddUserToGroup(groupID, userID)
+			hub.RemoveUserFromGroup(groupID, userID)
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		hub.BroadcastToGroup(groupID, &Message{Type: "new_group_message", GroupID: groupID})
+		receiveType(t, alice, "new_group_message")
+	}
+	wg.Wait()
+}
+
+func TestHub_OfflineEventsNotQueued(t *testing.T) {
+	hub := startHub(t)
+	client := newFakeClient(hub, "alice")
+
+	err := hub.SendToUser(client.UserID, &Message{Type: "new_message", Content: "while offline"})
+	if !errors.Is(err, ErrOffline) {
+		t.Fatalf("expected ErrOffline, got %v", err)
+	}
+
+	// Missed messages come from the database on reconnect, not from memory
+	registerClient(t, hub, client)
+	assertNoType(t, client, "new_message")
+}
+
+// newConnection returns another connection of an existing client's user
+func newConnection(hub *Hub, of *Client) *Client {
+	client := newFakeClient(hub, of.Username)
+	client.UserID = of.UserID
+	return client
+}
+
+// registerConnection registers a client and waits for the hub to list that connection, which
+// registerClient can't tell apart from the user's other connections
+func registerConnection(t *testing.T, hub *Hub, client *Client) {
+	t.Helper()
+	hub.Register(client)
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		hub.mu.RLock()
+		connections := hub.clients[client.UserID]
+		hub.mu.RUnlock()
+		for _, c := range connections {
+			if c == client {
+				return
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("timeout waiting for a connection of %s", client.UserID)
+}
+
+func TestHub_MultipleConnectionsPerUser(t *testing.T) {
+	hub := startHub(t)
+	phone := newFakeClient(hub, "alice")
+	laptop := newConnection(hub, phone)
+	registerConnection(t, hub, phone)
+	registerConnection(t, hub, laptop)
+
+	if err := hub.SendToUser(phone.UserID, &Message{Type: "new_message", Content: "hello"}); err != nil {
+		t.Fatalf("SendToUser returned error: %v", err)
+	}
+	receiveType(t, phone, "new_message")
+	receiveType(t, laptop, "new_message")
+
+	// Closing one connection keeps the user online on the other
+	hub.Unregister(phone)
+	time.Sleep(50 * time.Millisecond)
+	if !hub.IsUserOnline(laptop.UserID) {
+		t.Fatal("expected user to stay online with a connection left")
+	}
+	if online := hub.GetOnlineUsers(); len(online) != 1 || online[0] != laptop.UserID {
+		t.Fatalf("expected the user listed once, got %v", online)
+	}
+
+	hub.Unregister(laptop)
+	waitForOnline(t, hub, laptop.UserID, false)
+}
+
+func TestHub_ConnectionLimitEvictsOldest(t *testing.T) {
+	hub := NewHub()
+	hub.SetMaxConnectionsPerUser(2)
+	go hub.Run()
+
+	first := newFakeClient(hub, "alice")
+	second := newConnection(hub, first)
+	third := newConnection(hub, first)
+	for _, client := range []*Client{first, second, third} {
+		registerConnection(t, hub, client)
+	}
+
+	receiveType(t, first, "connection_evicted")
+	select {
+	case _, ok := <-first.Send:
+		if ok {
+			t.Fatal("expected the evicted connection's channel to be closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for the evicted connection to close")
+	}
+
+	// The evicted connection's own unregister is a no-op
+	hub.Unregister(first)
+	if err := hub.SendToUser(first.UserID, &Message{Type: "new_message"}); err != nil {
+		t.Fatalf("SendToUser returned error: %v", err)
+	}
+	receiveType(t, second, "new_message")
+	receiveType(t, third, "new_message")
+}
+
+func TestHub_PongAfterEviction(t *testing.T) {
+	hub := NewHub()
+	hub.SetMaxConnectionsPerUser(1)
+	go hub.Run()
+
+	first := newFakeClient(hub, "alice")
+	second := newConnection(hub, first)
+	registerConnection(t, hub, first)
+	registerConnection(t, hub, second)
+	receiveType(t, first, "connection_evicted")
+
+	// Its readPump is still running until it notices; a ping then must not send on the closed channel
+	first.sendPong()
+	if _, ok := <-first.Send; ok {
+		t.Fatal("expected the evicted connection's channel to be closed")
+	}
+
+	second.sendPong()
+	receiveType(t, second, "pong")
+}
+
+func TestHub_PresenceFollowsFirstAndLastConnection(t *testing.T) {
+	hub := startHub(t)
+	bob := newFakeClient(hub, "bob")
+	registerClient(t, hub, bob)
+	receiveType(t, bob, "user_joined") // Without a presence loader bob is told about its own join too
+
+	phone := newFakeClient(hub, "alice")
+	laptop := newConnection(hub, phone)
+	registerConnection(t, hub, phone)
+	receiveType(t, bob, "user_joined")
+
+	registerConnection(t, hub, laptop)
+	assertNoType(t, bob, "user_joined")
+
+	hub.Unregister(phone)
+	assertNoType(t, bob, "user_left")
+
+	hub.Unregister(laptop)
+	receiveType(t, bob, "user_left")
+}
+
+func TestHub_ConnectedClientsGauge(t *testing.T) {
+	hub := startHub(t)
+	phone := newFakeClient(hub, "alice")
+	laptop := newConnection(hub, phone)
+	bob := newFakeClient(hub, "bob")
+
+	for _, client := range []*Client{phone, laptop, bob} {
+		registerConnection(t, hub, client)
+	}
+	if got := testutil.ToFloat64(metrics.WebSocketConnectedClients); got != 3 {
+		t.Fatalf("expected 3 connections, got %v", got)
+	}
+
+	hub.Unregister(bob)
+	waitForOnline(t, hub, bob.UserID, false)
+	if got := testutil.ToFloat64(metrics.WebSocketConnectedClients); got != 2 {
+		t.Fatalf("expected 2 connections, got %v", got)
+	}
+}
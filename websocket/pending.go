@@ -0,0 +1,119 @@
+package websocket
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"mms-backend/utils"
+)
+
+// MissedMessagesLoader returns what a reconnecting user missed after the message they saw last
+type MissedMessagesLoader interface {
+	// LoadMissedMessages returns the payload of the pending_messages event sent to userID
+	LoadMissedMessages(userID, lastSeenMessageID uuid.UUID) (map[string]interface{}, error)
+}
+
+// SetMissedMessagesLoader sets what loads the messages a client asks for with last_seen_message_id.
+// Without one the parameter is ignored.
+func (h *Handler) SetMissedMessagesLoader(loader MissedMessagesLoader) {
+	h.missed = loader
+}
+
+// catchUp sends the client what it missed after lastSeenMessageID as a pending_messages event,
+// then what the hub delivered while it was loaded. The client is registered, with catchingUp
+// set, before the load, so a message sent meanwhile is in the event or delivered after it,
+// and one that is in both is only sent once.
+func (h *Handler) catchUp(client *Client, lastSeenMessageID uuid.UUID) {
+	<-client.registered
+
+	var payload []byte
+	data, err := h.missed.LoadMissedMessages(client.UserID, lastSeenMessageID)
+	if err != nil {
+		utils.Logger.Error("Failed to load missed messages", "user_id", client.UserID, "error", err)
+	} else {
+		payload, err = json.Marshal(&Message{
+			Type:       "pending_messages",
+			ReceiverID: client.UserID,
+			Data:       data,
+			Timestamp:  time.Now(),
+		})
+		if err != nil {
+			utils.Logger.Error("Failed to marshal message", "error", err)
+		}
+	}
+	h.hub.finishCatchUp(client, payload)
+}
+
+// finishCatchUp queues pending, when set, and the events held while the client caught up,
+// skipping the messages pending already holds, and then delivers to the client directly
+func (h *Hub) finishCatchUp(client *Client, pending []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	held := client.caughtUp
+	client.caughtUp = nil
+	client.catchingUp = false
+	if client.closed {
+		return
+	}
+
+	seen := pendingMessageIDs(pending)
+	if pending != nil {
+		held = append([][]byte{pending}, held...)
+	}
+	for _, data := range held {
+		if id := liveMessageID(data); id != uuid.Nil && seen[id] {
+			continue
+		}
+		select {
+		case client.Send <- data:
+		default:
+			h.dropConnection(client)
+			return
+		}
+	}
+}
+
+// pendingMessageIDs returns the IDs of the direct and group messages in a pending_messages event
+func pendingMessageIDs(pending []byte) map[uuid.UUID]bool {
+	var event struct {
+		Data struct {
+			Messages      []struct{ ID uuid.UUID } `json:"messages"`
+			GroupMessages []struct{ ID uuid.UUID } `json:"group_messages"`
+		} `json:"data"`
+	}
+	ids := make(map[uuid.UUID]bool)
+	if pending == nil || json.Unmarshal(pending, &event) != nil {
+		return ids
+	}
+	for _, m := range event.Data.Messages {
+		ids[m.ID] = true
+	}
+	for _, m := range event.Data.GroupMessages {
+		ids[m.ID] = true
+	}
+	return ids
+}
+
+// liveMessageID returns the message a new_message or new_group_message event delivers, or
+// uuid.Nil for any other event
+func liveMessageID(data []byte) uuid.UUID {
+	var msg Message
+	if json.Unmarshal(data, &msg) != nil {
+		return uuid.Nil
+	}
+	switch msg.Type {
+	case "new_message":
+		if msg.MessageID != nil {
+			return *msg.MessageID
+		}
+	case "new_group_message":
+		if raw, ok := msg.Data["message_id"].(string); ok {
+			if id, err := uuid.Parse(raw); err == nil {
+				return id
+			}
+		}
+	}
+	return uuid.Nil
+}
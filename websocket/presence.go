@@ -0,0 +1,149 @@
+package websocket
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"mms-backend/utils"
+)
+
+// presenceCacheTTL is how long a user's presence subscribers are cached
+const presenceCacheTTL = 60 * time.Second
+
+// connectionChangeBuffer is how many connection changes may wait for the connect/disconnect
+// callbacks before the hub loop blocks
+const connectionChangeBuffer = 256
+
+// connectionChange records a user coming online or going offline
+type connectionChange struct {
+	userID uuid.UUID
+	online bool
+}
+
+// PresenceSubscribersLoader returns the users allowed to see a user's online status
+type PresenceSubscribersLoader interface {
+	LoadPresenceSubscribers(userID uuid.UUID) ([]uuid.UUID, error)
+}
+
+// presenceCacheEntry is a cached subscriber list
+type presenceCacheEntry struct {
+	subscribers []uuid.UUID
+	expires     time.Time
+}
+
+// SetPresenceLoader restricts user_joined, user_left and user_presence events to the subscribers returned
+// by loader. Without a loader presence events go to every connected client. Call before Run.
+func (h *Hub) SetPresenceLoader(loader PresenceSubscribersLoader) {
+	h.presenceMu.Lock()
+	defer h.presenceMu.Unlock()
+	h.presenceLoader = loader
+	h.presenceCache = make(map[uuid.UUID]presenceCacheEntry)
+}
+
+// InvalidatePresenceCache forgets the cached subscribers of the given users, e.g. after
+// their group memberships or conversations change
+func (h *Hub) InvalidatePresenceCache(userIDs ...uuid.UUID) {
+	h.presenceMu.Lock()
+	defer h.presenceMu.Unlock()
+	for _, userID := range userIDs {
+		delete(h.presenceCache, userID)
+	}
+}
+
+// presenceSubscribers returns the cached subscribers of userID, loading them when missing or expired
+func (h *Hub) presenceSubscribers(userID uuid.UUID) ([]uuid.UUID, error) {
+	h.presenceMu.Lock()
+	loader := h.presenceLoader
+	entry, ok := h.presenceCache[userID]
+	h.presenceMu.Unlock()
+
+	if ok && h.now().Before(entry.expires) {
+		return entry.subscribers, nil
+	}
+
+	subscribers, err := loader.LoadPresenceSubscribers(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	h.presenceMu.Lock()
+	h.presenceCache[userID] = presenceCacheEntry{subscribers: subscribers, expires: h.now().Add(presenceCacheTTL)}
+	h.presenceMu.Unlock()
+	return subscribers, nil
+}
+
+// runConnectionCallbacks calls OnClientConnect and OnClientDisconnect for each connection
+// change. They may hit the database, so they run off the hub loop, one at a time so a quick
+// reconnect can't leave the user marked offline.
+func (h *Hub) runConnectionCallbacks() {
+	for change := range h.connectionChanges {
+		callback := h.OnClientDisconnect
+		if change.online {
+			callback = h.OnClientConnect
+		}
+		if callback != nil {
+			callback(change.userID)
+		}
+	}
+}
+
+// publishPresence tells the users allowed to see client's presence that it came online or went
+// offline, with both a user_joined/user_left event and a user_presence one
+func (h *Hub) publishPresence(client *Client, online bool) {
+	eventType := "user_left"
+	if online {
+		eventType = "user_joined"
+	}
+	joinedOrLeft, err := json.Marshal(Message{
+		Type: eventType,
+		Data: map[string]interface{}{
+			"user_id":  client.UserID,
+			"username": client.Username,
+		},
+	})
+	if err != nil {
+		return
+	}
+	now := h.now()
+	presence, err := json.Marshal(Message{
+		Type: "user_presence",
+		Data: map[string]interface{}{
+			"user_id":   client.UserID,
+			"is_online": online,
+			"last_seen": now,
+		},
+		Timestamp: now,
+	})
+	if err != nil {
+		return
+	}
+
+	h.presenceMu.Lock()
+	hasLoader := h.presenceLoader != nil
+	h.presenceMu.Unlock()
+	if !hasLoader {
+		h.BroadcastToAll(joinedOrLeft)
+		h.BroadcastToAll(presence)
+		return
+	}
+
+	// Loading subscribers may hit the database, so keep it off the hub loop
+	go func() {
+		subscribers, err := h.presenceSubscribers(client.UserID)
+		if err != nil {
+			utils.Logger.Error("Failed to load presence subscribers", "user_id", client.UserID, "error", err)
+			return
+		}
+
+		// Presence is only interesting live, so offline subscribers are skipped rather than queued
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		for _, subscriberID := range subscribers {
+			if subscriberID != client.UserID {
+				h.sendToConnections(subscriberID, joinedOrLeft)
+				h.sendToConnections(subscriberID, presence)
+			}
+		}
+	}()
+}
@@ -0,0 +1,207 @@
+package websocket
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// fakePresenceLoader serves fixed subscriber lists and counts lookups
+type fakePresenceLoader struct {
+	mu          sync.Mutex
+	subscribers map[uuid.UUID][]uuid.UUID
+	calls       map[uuid.UUID]int
+}
+
+func (l *fakePresenceLoader) LoadPresenceSubscribers(userID uuid.UUID) ([]uuid.UUID, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.calls == nil {
+		l.calls = make(map[uuid.UUID]int)
+	}
+	l.calls[userID]++
+	return l.subscribers[userID], nil
+}
+
+func (l *fakePresenceLoader) callCount(userID uuid.UUID) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.calls[userID]
+}
+
+func TestHub_PresenceOnlyToSubscribers(t *testing.T) {
+	hub := NewHub()
+	alice := newFakeClient(hub, "alice")
+	bob := newFakeClient(hub, "bob")
+	carol := newFakeClient(hub, "carol")
+	loader := &fakePresenceLoader{subscribers: map[uuid.UUID][]uuid.UUID{
+		alice.UserID: {bob.UserID},
+	}}
+	hub.SetPresenceLoader(loader)
+	go hub.Run()
+
+	registerClient(t, hub, bob)
+	registerClient(t, hub, carol)
+	registerClient(t, hub, alice)
+
+	joined := receiveType(t, bob, "user_joined")
+	if joined.Data["user_id"] != alice.UserID.String() {
+		t.Fatalf("unexpected user_joined payload: %v", joined.Data)
+	}
+	assertNoType(t, carol, "user_joined")
+
+	hub.Unregister(alice)
+	receiveType(t, bob, "user_left")
+	assertNoType(t, carol, "user_left")
+
+	if calls := loader.callCount(alice.UserID); calls != 1 {
+		t.Fatalf("expected subscribers to be cached, loader called %d times", calls)
+	}
+}
+
+func TestHub_PresenceCacheExpiry(t *testing.T) {
+	hub := NewHub()
+	alice := newFakeClient(hub, "alice")
+	bob := newFakeClient(hub, "bob")
+	loader := &fakePresenceLoader{subscribers: map[uuid.UUID][]uuid.UUID{
+		alice.UserID: {bob.UserID},
+	}}
+	hub.SetPresenceLoader(loader)
+	now := time.Now()
+	hub.now = func() time.Time { return now }
+	go hub.Run()
+
+	registerClient(t, hub, bob)
+	registerClient(t, hub, alice)
+	receiveType(t, bob, "user_joined")
+
+	// Invalidation forces the next event to reload
+	hub.InvalidatePresenceCache(alice.UserID)
+	hub.Unregister(alice)
+	receiveType(t, bob, "user_left")
+	if calls := loader.callCount(alice.UserID); calls != 2 {
+		t.Fatalf("expected reload after invalidation, loader called %d times", calls)
+	}
+
+	// So does the TTL running out
+	now = now.Add(presenceCacheTTL + time.Second)
+	// Unregister closed the old Send channel, so reconnect with a fresh client
+	reconnected := newFakeClient(hub, "alice")
+	reconnected.UserID = alice.UserID
+	registerClient(t, hub, reconnected)
+	receiveType(t, bob, "user_joined")
+	if calls := loader.callCount(alice.UserID); calls != 3 {
+		t.Fatalf("expected reload after expiry, loader called %d times", calls)
+	}
+}
+
+func TestHub_UserPresenceEvent(t *testing.T) {
+	hub := NewHub()
+	alice := newFakeClient(hub, "alice")
+	bob := newFakeClient(hub, "bob")
+	carol := newFakeClient(hub, "carol")
+	hub.SetPresenceLoader(&fakePresenceLoader{subscribers: map[uuid.UUID][]uuid.UUID{
+		alice.UserID: {bob.UserID},
+	}})
+	go hub.Run()
+
+	registerClient(t, hub, bob)
+	registerClient(t, hub, carol)
+	registerClient(t, hub, alice)
+
+	online := receiveType(t, bob, "user_presence")
+	if online.Data["user_id"] != alice.UserID.String() || online.Data["is_online"] != true {
+		t.Fatalf("unexpected user_presence payload: %v", online.Data)
+	}
+	if _, ok := online.Data["last_seen"]; !ok {
+		t.Fatal("expected last_seen in user_presence data")
+	}
+	assertNoType(t, carol, "user_presence")
+
+	hub.Unregister(alice)
+	offline := receiveType(t, bob, "user_presence")
+	if offline.Data["is_online"] != false {
+		t.Fatalf("unexpected user_presence payload: %v", offline.Data)
+	}
+	assertNoType(t, carol, "user_presence")
+}
+
+func TestHub_ConnectionCallbacks(t *testing.T) {
+	hub := NewHub()
+	changes := make(chan connectionChange, 4)
+	hub.OnClientConnect = func(userID uuid.UUID) { changes <- connectionChange{userID: userID, online: true} }
+	hub.OnClientDisconnect = func(userID uuid.UUID) { changes <- connectionChange{userID: userID, online: false} }
+	go hub.Run()
+
+	expect := func(want connectionChange) {
+		t.Helper()
+		select {
+		case got := <-changes:
+			if got != want {
+				t.Fatalf("expected %+v, got %+v", want, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for %+v", want)
+		}
+	}
+	expectNone := func() {
+		t.Helper()
+		select {
+		case got := <-changes:
+			t.Fatalf("unexpected %+v", got)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	// Only the first connection and the last disconnection count
+	phone := newFakeClient(hub, "alice")
+	laptop := newConnection(hub, phone)
+	registerConnection(t, hub, phone)
+	expect(connectionChange{userID: phone.UserID, online: true})
+	registerConnection(t, hub, laptop)
+	expectNone()
+
+	hub.Unregister(phone)
+	expectNone()
+	hub.Unregister(laptop)
+	expect(connectionChange{userID: phone.UserID, online: false})
+}
+
+func TestHub_DroppedConnectionGoesOffline(t *testing.T) {
+	hub := NewHub()
+	disconnected := make(chan uuid.UUID, 1)
+	hub.OnClientDisconnect = func(userID uuid.UUID) { disconnected <- userID }
+	go hub.Run()
+
+	observer := newFakeClient(hub, "alice")
+	slow := newFakeClient(hub, "bob")
+	slow.Send = make(chan []byte, 1)
+	hub.SetPresenceLoader(&fakePresenceLoader{subscribers: map[uuid.UUID][]uuid.UUID{
+		slow.UserID: {observer.UserID},
+	}})
+	registerClient(t, hub, observer)
+	registerClient(t, hub, slow)
+	receiveType(t, observer, "user_presence")
+
+	// The second event doesn't fit in the buffer, so the hub drops the connection
+	_ = hub.SendToUser(slow.UserID, &Message{Type: "new_message", Content: "first"})
+	_ = hub.SendToUser(slow.UserID, &Message{Type: "new_message", Content: "second"})
+	waitForOnline(t, hub, slow.UserID, false)
+
+	// ...and its pumps then unregister it, which must still report the user offline
+	hub.Unregister(slow)
+	select {
+	case userID := <-disconnected:
+		if userID != slow.UserID {
+			t.Fatalf("expected a disconnect of %s, got %s", slow.UserID, userID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for OnClientDisconnect")
+	}
+	offline := receiveType(t, observer, "user_presence")
+	if offline.Data["is_online"] != false {
+		t.Fatalf("unexpected user_presence payload: %v", offline.Data)
+	}
+}
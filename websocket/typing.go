@@ -0,0 +1,104 @@
+package websocket
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"mms-backend/utils"
+)
+
+// typingDebounce suppresses repeated typing_start events for the same conversation
+const typingDebounce = 3 * time.Second
+
+// TypingEvent reports that a user started or stopped typing to another user
+type TypingEvent struct {
+	SenderID   uuid.UUID
+	ReceiverID uuid.UUID
+	IsTyping   bool
+}
+
+// typingPair identifies who is typing to whom
+type typingPair struct {
+	senderID   uuid.UUID
+	receiverID uuid.UUID
+}
+
+// BlockChecker reports whether a user blocked another
+type BlockChecker interface {
+	IsBlocked(blockerID, blockedID uuid.UUID) (bool, error)
+}
+
+// SetBlockChecker sets what decides whether two users blocked each other. Without one typing
+// indicators are never filtered.
+func (h *Hub) SetBlockChecker(checker BlockChecker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.blockChecker = checker
+}
+
+// PublishTyping queues a typing event for delivery to its receiver, unless either user blocked
+// the other. The check may hit the database, so it runs on the caller's goroutine.
+func (h *Hub) PublishTyping(event *TypingEvent) {
+	if h.typingBlocked(event) {
+		return
+	}
+	h.typing <- event
+}
+
+// typingBlocked reports whether the sender or the receiver of event blocked the other. When
+// that can't be told the event is dropped.
+func (h *Hub) typingBlocked(event *TypingEvent) bool {
+	h.mu.RLock()
+	checker := h.blockChecker
+	h.mu.RUnlock()
+	if checker == nil {
+		return false
+	}
+
+	for _, pair := range [][2]uuid.UUID{{event.ReceiverID, event.SenderID}, {event.SenderID, event.ReceiverID}} {
+		blocked, err := checker.IsBlocked(pair[0], pair[1])
+		if err != nil {
+			utils.Logger.Error("Failed to check block for typing indicator", "sender_id", event.SenderID, "receiver_id", event.ReceiverID, "error", err)
+			return true
+		}
+		if blocked {
+			return true
+		}
+	}
+	return false
+}
+
+// deliverTyping forwards a typing event to its receiver only. A typing_start repeated within
+// typingDebounce of the previous one is dropped; a typing_stop always goes through.
+// Only called from Run, which owns typingSent.
+func (h *Hub) deliverTyping(event *TypingEvent) {
+	pair := typingPair{senderID: event.SenderID, receiverID: event.ReceiverID}
+
+	eventType := "typing_stop"
+	if event.IsTyping {
+		now := h.now()
+		if last, ok := h.typingSent[pair]; ok && now.Sub(last) < typingDebounce {
+			return
+		}
+		h.typingSent[pair] = now
+		eventType = "typing_start"
+	} else {
+		delete(h.typingSent, pair)
+	}
+
+	_ = h.SendToUser(event.ReceiverID, &Message{
+		Type:       eventType,
+		SenderID:   event.SenderID,
+		ReceiverID: event.ReceiverID,
+		Timestamp:  time.Now(),
+	})
+}
+
+// forgetTyping drops the debounce state of a user who disconnected
+func (h *Hub) forgetTyping(userID uuid.UUID) {
+	for pair := range h.typingSent {
+		if pair.senderID == userID {
+			delete(h.typingSent, pair)
+		}
+	}
+}
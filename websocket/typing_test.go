@@ -0,0 +1,123 @@
+package websocket
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// fakeClock is a hub clock the test can move forward while the hub reads it
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestHub_TypingDeliveredToReceiverOnly(t *testing.T) {
+	hub := startHub(t)
+	alice := newFakeClient(hub, "alice")
+	bob := newFakeClient(hub, "bob")
+	carol := newFakeClient(hub, "carol")
+	for _, client := range []*Client{alice, bob, carol} {
+		registerClient(t, hub, client)
+	}
+
+	hub.PublishTyping(&TypingEvent{SenderID: alice.UserID, ReceiverID: bob.UserID, IsTyping: true})
+	msg := receiveType(t, bob, "typing_start")
+	if msg.SenderID != alice.UserID {
+		t.Fatalf("unexpected sender: %s", msg.SenderID)
+	}
+	assertNoType(t, carol, "typing_start")
+	assertNoType(t, alice, "typing_start")
+
+	hub.PublishTyping(&TypingEvent{SenderID: alice.UserID, ReceiverID: bob.UserID, IsTyping: false})
+	receiveType(t, bob, "typing_stop")
+}
+
+func TestHub_TypingDebounce(t *testing.T) {
+	hub := NewHub()
+	clock := &fakeClock{now: time.Now()}
+	hub.now = clock.Now
+	go hub.Run()
+
+	alice := newFakeClient(hub, "alice")
+	bob := newFakeClient(hub, "bob")
+	registerClient(t, hub, alice)
+	registerClient(t, hub, bob)
+
+	start := &TypingEvent{SenderID: alice.UserID, ReceiverID: bob.UserID, IsTyping: true}
+	hub.PublishTyping(start)
+	receiveType(t, bob, "typing_start")
+
+	// Keystrokes within the debounce window are suppressed
+	clock.Advance(typingDebounce - time.Millisecond)
+	hub.PublishTyping(start)
+	assertNoType(t, bob, "typing_start")
+
+	// ...until the window has passed
+	clock.Advance(typingDebounce)
+	hub.PublishTyping(start)
+	receiveType(t, bob, "typing_start")
+
+	// Stopping resets the window
+	hub.PublishTyping(&TypingEvent{SenderID: alice.UserID, ReceiverID: bob.UserID, IsTyping: false})
+	receiveType(t, bob, "typing_stop")
+	hub.PublishTyping(start)
+	receiveType(t, bob, "typing_start")
+
+	// Another conversation has its own window
+	hub.PublishTyping(&TypingEvent{SenderID: bob.UserID, ReceiverID: alice.UserID, IsTyping: true})
+	receiveType(t, alice, "typing_start")
+}
+
+func TestHub_TypingNotQueuedForOfflineUser(t *testing.T) {
+	hub := startHub(t)
+	alice := newFakeClient(hub, "alice")
+	bob := newFakeClient(hub, "bob")
+	registerClient(t, hub, alice)
+
+	hub.PublishTyping(&TypingEvent{SenderID: alice.UserID, ReceiverID: bob.UserID, IsTyping: true})
+
+	registerClient(t, hub, bob)
+	assertNoType(t, bob, "typing_start")
+}
+
+// fakeBlockChecker blocks the listed blocker -> blocked pairs
+type fakeBlockChecker map[[2]uuid.UUID]bool
+
+func (f fakeBlockChecker) IsBlocked(blockerID, blockedID uuid.UUID) (bool, error) {
+	return f[[2]uuid.UUID{blockerID, blockedID}], nil
+}
+
+func TestHub_TypingBlockedEitherWay(t *testing.T) {
+	hub := startHub(t)
+	alice := newFakeClient(hub, "alice")
+	bob := newFakeClient(hub, "bob")
+	carol := newFakeClient(hub, "carol")
+	for _, client := range []*Client{alice, bob, carol} {
+		registerClient(t, hub, client)
+	}
+	// Bob blocked Alice
+	hub.SetBlockChecker(fakeBlockChecker{{bob.UserID, alice.UserID}: true})
+
+	hub.PublishTyping(&TypingEvent{SenderID: alice.UserID, ReceiverID: bob.UserID, IsTyping: true})
+	assertNoType(t, bob, "typing_start")
+	hub.PublishTyping(&TypingEvent{SenderID: bob.UserID, ReceiverID: alice.UserID, IsTyping: true})
+	assertNoType(t, alice, "typing_start")
+
+	hub.PublishTyping(&TypingEvent{SenderID: carol.UserID, ReceiverID: bob.UserID, IsTyping: true})
+	receiveType(t, bob, "typing_start")
+}